@@ -0,0 +1,53 @@
+# Copyright 2024 The Inspektor Gadget authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Collects the files read and written on the host for some time and writes
+the busiest ones to a CSV file, e.g.
+
+    python3 top_file.py --duration 30 --output files.csv
+"""
+
+import argparse
+import csv
+from collections import Counter
+
+from inspektor_gadget import Client
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--host", default="unix:///var/run/ig/ig.socket")
+    parser.add_argument("--image", default="ghcr.io/inspektor-gadget/gadget/top_file:latest")
+    parser.add_argument("--duration", type=float, default=10)
+    parser.add_argument("--output", default="files.csv")
+    args = parser.parse_args()
+
+    reads = Counter()
+    writes = Counter()
+    with Client(args.host) as client:
+        # top gadgets emit an array with the statistics of each interval
+        for event in client.run(args.image, duration=args.duration):
+            for entry in event.data:
+                reads[entry["file"]] += entry["rbytes"]
+                writes[entry["file"]] += entry["wbytes"]
+
+    with open(args.output, "w", newline="") as f:
+        writer = csv.writer(f)
+        writer.writerow(["file", "read_bytes", "written_bytes"])
+        for file, _ in (reads + writes).most_common(100):
+            writer.writerow([file, reads[file], writes[file]])
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,45 @@
+# Copyright 2024 The Inspektor Gadget authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Prints the programs executed on the host, e.g.
+
+    sudo ig daemon &
+    python3 trace_exec.py --host unix:///var/run/ig/ig.socket
+"""
+
+import argparse
+import signal
+
+from inspektor_gadget import Client
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--host", default="unix:///var/run/ig/ig.socket")
+    parser.add_argument("--image", default="ghcr.io/inspektor-gadget/gadget/trace_exec:latest")
+    args = parser.parse_args()
+
+    with Client(args.host) as client:
+        # Parameters use the same keys as the gadget service, the ones of the
+        # eBPF program are prefixed by operator.oci.ebpf.
+        run = client.run(args.image, params={"operator.oci.ebpf.paths": "true"})
+        signal.signal(signal.SIGINT, lambda *_: run.stop())
+
+        for event in run:
+            proc = event.data["proc"]
+            print(f"{proc['pid']:>8} {proc['comm']:<16} {event.data['args']}")
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,218 @@
+# Copyright 2024 The Inspektor Gadget authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Client of the gadget service of ig and the gadget pods."""
+
+import logging
+import queue
+from dataclasses import dataclass
+from typing import Any, Dict, Iterator, List, Optional
+
+import grpc
+
+from .api import api_pb2, api_pb2_grpc
+from .datasource import TYPE_ARRAY, DataSource
+
+# Constants of pkg/gadget-service/api/consts.go
+DEFAULT_TARGET = "unix:///var/run/ig/ig.socket"
+VERSION_GADGET_INFO = 1
+VERSION_GADGET_RUN_PROTOCOL = 1
+
+EVENT_TYPE_GADGET_PAYLOAD = 0
+EVENT_TYPE_GADGET_RESULT = 1
+EVENT_TYPE_GADGET_DONE = 2
+EVENT_TYPE_GADGET_JOB_ID = 3
+EVENT_TYPE_GADGET_INFO = 4
+EVENT_LOG_SHIFT = 16
+
+# Log levels of logrus, used by pkg/logger
+_LOG_LEVELS = {
+    0: logging.CRITICAL,
+    1: logging.CRITICAL,
+    2: logging.ERROR,
+    3: logging.WARNING,
+    4: logging.INFO,
+}
+
+log = logging.getLogger("inspektor_gadget")
+
+
+@dataclass
+class Event:
+    """An event emitted by a data source of a gadget.
+
+    data is a dictionary for single data sources and a list of dictionaries for
+    array data sources, like the ones of top gadgets.
+    """
+
+    datasource: str
+    node: str
+    seq: int
+    data: Any
+
+
+class GadgetRun:
+    """A running gadget. Iterating over it returns its events until the gadget
+    is done or stop() is called."""
+
+    def __init__(self, stub, request):
+        self._control = queue.Queue()
+        self._control.put(request)
+        self._stream = stub.RunGadget(self._requests())
+        self._datasources: Dict[int, DataSource] = {}
+        self.info = None
+        self.dropped = 0
+
+    def _requests(self):
+        while True:
+            req = self._control.get()
+            if req is None:
+                return
+            yield req
+
+    def stop(self):
+        """Asks the gadget to stop. The pending events can still be read."""
+        self._control.put(
+            api_pb2.GadgetControlRequest(stopRequest=api_pb2.GadgetStopRequest())
+        )
+        self._control.put(None)
+
+    def cancel(self):
+        """Stops the gadget and closes the connection right away."""
+        self._control.put(None)
+        self._stream.cancel()
+
+    def __iter__(self) -> Iterator[Event]:
+        expected_seq = 1
+        try:
+            for ev in self._stream:
+                if ev.type == EVENT_TYPE_GADGET_PAYLOAD:
+                    ds = self._datasources.get(ev.dataSourceID)
+                    if ds is None:
+                        log.warning("received payload without being initialized")
+                        continue
+                    if ev.seq != expected_seq:
+                        self.dropped += ev.seq - expected_seq
+                    expected_seq = ev.seq + 1
+                    yield self._decode(ds, ev)
+                elif ev.type == EVENT_TYPE_GADGET_INFO:
+                    self.info = api_pb2.GadgetInfo()
+                    self.info.ParseFromString(ev.payload)
+                    self._datasources = {
+                        ds.id: DataSource(ds) for ds in self.info.dataSources
+                    }
+                elif ev.type == EVENT_TYPE_GADGET_DONE:
+                    return
+                elif ev.type >= 1 << EVENT_LOG_SHIFT:
+                    level = _LOG_LEVELS.get(ev.type >> EVENT_LOG_SHIFT, logging.DEBUG)
+                    log.log(level, ev.payload.decode(errors="replace"))
+        except grpc.RpcError as e:
+            if e.code() != grpc.StatusCode.CANCELLED:
+                raise
+        finally:
+            self._control.put(None)
+
+    @staticmethod
+    def _decode(ds, ev):
+        if ds.type == TYPE_ARRAY:
+            packet = api_pb2.GadgetDataArray()
+            packet.ParseFromString(ev.payload)
+            data = [ds.decode(list(el.payload)) for el in packet.dataArray]
+        else:
+            packet = api_pb2.GadgetData()
+            packet.ParseFromString(ev.payload)
+            data = ds.decode(list(packet.data.payload))
+        return Event(datasource=ds.name, node=packet.node, seq=ev.seq, data=data)
+
+
+class Client:
+    """Client of the gadget service.
+
+    target is a gRPC target, like the unix socket of the ig daemon (the
+    default) or tcp://host:port. channel can be given instead to use a
+    custom channel, e.g. with TLS credentials.
+    """
+
+    def __init__(self, target: str = DEFAULT_TARGET, channel=None):
+        if channel is None:
+            if target.startswith("tcp://"):
+                target = target[len("tcp://") :]
+            channel = grpc.insecure_channel(target)
+        self._channel = channel
+        self._manager = api_pb2_grpc.GadgetManagerStub(channel)
+        self._instances = api_pb2_grpc.GadgetInstanceManagerStub(channel)
+
+    def close(self):
+        self._channel.close()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def gadget_info(self, image: str, params: Optional[Dict[str, str]] = None):
+        """Returns the GadgetInfo of the image, describing its data sources and
+        parameters."""
+        resp = self._manager.GetGadgetInfo(
+            api_pb2.GetGadgetInfoRequest(
+                imageName=image,
+                paramValues=params or {},
+                version=VERSION_GADGET_INFO,
+            )
+        )
+        return resp.gadgetInfo
+
+    def run(
+        self,
+        image: str,
+        params: Optional[Dict[str, str]] = None,
+        args: Optional[List[str]] = None,
+        duration: float = 0,
+        log_level: int = 4,
+    ) -> GadgetRun:
+        """Runs the gadget image and returns its events.
+
+        params use the same keys as the gadget service, e.g.
+        "operator.oci.ebpf.paths" or "operator.LocalManager.containername".
+        duration is in seconds, 0 runs the gadget until stop() is called.
+        """
+        req = api_pb2.GadgetControlRequest(
+            runRequest=api_pb2.GadgetRunRequest(
+                imageName=image,
+                paramValues=params or {},
+                args=args or [],
+                logLevel=log_level,
+                timeout=int(duration * 1e9),
+                version=VERSION_GADGET_RUN_PROTOCOL,
+            )
+        )
+        return GadgetRun(self._manager, req)
+
+    def attach(self, instance_id: str) -> GadgetRun:
+        """Returns the events of a gadget instance running in the
+        background."""
+        req = api_pb2.GadgetControlRequest(
+            attachRequest=api_pb2.GadgetAttachRequest(
+                id=instance_id, version=VERSION_GADGET_RUN_PROTOCOL
+            )
+        )
+        return GadgetRun(self._manager, req)
+
+    def list_instances(self):
+        """Returns the gadget instances running in the background."""
+        resp = self._instances.ListGadgetInstances(
+            api_pb2.ListGadgetInstancesRequest()
+        )
+        return list(resp.gadgetInstances)
@@ -0,0 +1,146 @@
+# Copyright 2024 The Inspektor Gadget authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+"""Decoding of the payloads sent by the gadget service.
+
+The layout of the payloads is described by the data sources of the GadgetInfo
+sent before the events, see pkg/datasource in the Go code.
+"""
+
+import struct
+
+# Kinds, see the Kind enum in pkg/gadget-service/api/api.proto
+KIND_BOOL = 1
+KIND_INT8 = 2
+KIND_INT16 = 3
+KIND_INT32 = 4
+KIND_INT64 = 5
+KIND_UINT8 = 6
+KIND_UINT16 = 7
+KIND_UINT32 = 8
+KIND_UINT64 = 9
+KIND_FLOAT32 = 10
+KIND_FLOAT64 = 11
+KIND_STRING = 12
+KIND_CSTRING = 13
+KIND_BYTES = 14
+
+# KIND_FLAG_ARRAY is set on the kind of fields holding arrays, see
+# pkg/gadget-service/api/consts.go
+KIND_FLAG_ARRAY = 0x10000000
+
+# Field flags, see pkg/datasource/field.go
+FIELD_FLAG_EMPTY = 1 << 0
+FIELD_FLAG_CONTAINER = 1 << 1
+FIELD_FLAG_HIDDEN = 1 << 2
+FIELD_FLAG_HAS_PARENT = 1 << 3
+FIELD_FLAG_STATIC_MEMBER = 1 << 4
+FIELD_FLAG_UNREFERENCED = 1 << 5
+
+# Data source types and flags, see pkg/datasource/datasource.go
+TYPE_SINGLE = 1
+TYPE_ARRAY = 2
+
+DATASOURCE_FLAG_BIG_ENDIAN = 1 << 0
+
+_NUMBER_FORMATS = {
+    KIND_BOOL: "?",
+    KIND_INT8: "b",
+    KIND_INT16: "h",
+    KIND_INT32: "i",
+    KIND_INT64: "q",
+    KIND_UINT8: "B",
+    KIND_UINT16: "H",
+    KIND_UINT32: "I",
+    KIND_UINT64: "Q",
+    KIND_FLOAT32: "f",
+    KIND_FLOAT64: "d",
+}
+
+
+class DataSource:
+    """Decodes the payloads of a data source into dictionaries.
+
+    Nested fields, like the ones of an eBPF struct, are returned as nested
+    dictionaries, like the json output mode of ig does. Hidden fields are
+    returned too.
+    """
+
+    def __init__(self, ds):
+        self.id = ds.id
+        self.name = ds.name
+        self.type = ds.type
+        self.annotations = dict(ds.annotations)
+        self._order = ">" if ds.flags & DATASOURCE_FLAG_BIG_ENDIAN else "<"
+
+        fields = [f for f in ds.fields if not f.flags & FIELD_FLAG_UNREFERENCED]
+        self._children = {}
+        for f in fields:
+            parent = f.parent if f.flags & FIELD_FLAG_HAS_PARENT else None
+            self._children.setdefault(parent, []).append(f)
+        for children in self._children.values():
+            children.sort(key=lambda f: f.name)
+
+    def decode(self, payload):
+        """Returns the fields of a single element as a dictionary.
+
+        payload is the list of buffers of the element, as in DataElement.
+        Fields whose payload wasn't sent, e.g. because they weren't requested
+        with the fields parameter of the cli operator, are set to None.
+        """
+        return self._decode_fields(None, payload)
+
+    def _decode_fields(self, parent, payload):
+        ret = {}
+        for f in self._children.get(parent, []):
+            if f.index in self._children:
+                ret[f.name] = self._decode_fields(f.index, payload)
+                continue
+            if f.flags & (FIELD_FLAG_EMPTY | FIELD_FLAG_CONTAINER):
+                continue
+            ret[f.name] = self._decode_value(f, payload)
+        return ret
+
+    def _decode_value(self, f, payload):
+        if f.payloadIndex >= len(payload):
+            return None
+        buf = payload[f.payloadIndex]
+        if f.size > 0:
+            if len(buf) < f.offs + f.size:
+                return None
+            buf = buf[f.offs : f.offs + f.size]
+        return decode_kind(f.kind, buf, self._order)
+
+
+def decode_kind(kind, buf, order="<"):
+    """Decodes the content of a field of the given kind."""
+    if kind & KIND_FLAG_ARRAY:
+        fmt = _NUMBER_FORMATS.get(kind & ~KIND_FLAG_ARRAY)
+        if fmt is None:
+            return bytes(buf)
+        size = struct.calcsize(fmt)
+        count = len(buf) // size
+        return list(struct.unpack(f"{order}{count}{fmt}", buf[: count * size]))
+
+    if kind == KIND_CSTRING:
+        return bytes(buf).split(b"\0", 1)[0].decode(errors="replace")
+    if kind == KIND_STRING:
+        return bytes(buf).decode(errors="replace")
+    if kind == KIND_BYTES:
+        return bytes(buf)
+
+    fmt = _NUMBER_FORMATS.get(kind)
+    if fmt is None or len(buf) != struct.calcsize(fmt):
+        return bytes(buf)
+    return struct.unpack(order + fmt, buf)[0]
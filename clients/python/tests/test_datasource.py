@@ -0,0 +1,124 @@
+# Copyright 2024 The Inspektor Gadget authors
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+import struct
+import unittest
+from types import SimpleNamespace
+
+from inspektor_gadget.datasource import (
+    DATASOURCE_FLAG_BIG_ENDIAN,
+    FIELD_FLAG_CONTAINER,
+    FIELD_FLAG_EMPTY,
+    FIELD_FLAG_HAS_PARENT,
+    FIELD_FLAG_HIDDEN,
+    FIELD_FLAG_STATIC_MEMBER,
+    FIELD_FLAG_UNREFERENCED,
+    KIND_CSTRING,
+    KIND_FLAG_ARRAY,
+    KIND_INT32,
+    KIND_STRING,
+    KIND_UINT16,
+    KIND_UINT64,
+    TYPE_SINGLE,
+    DataSource,
+    decode_kind,
+)
+
+
+def field(index, name, kind=0, payload_index=0, offs=0, size=0, flags=0, parent=0):
+    return SimpleNamespace(
+        index=index,
+        name=name,
+        kind=kind,
+        payloadIndex=payload_index,
+        offs=offs,
+        size=size,
+        flags=flags,
+        parent=parent,
+    )
+
+
+def datasource(fields, flags=0):
+    return SimpleNamespace(
+        id=0,
+        name="test",
+        type=TYPE_SINGLE,
+        annotations={},
+        flags=flags,
+        fields=fields,
+    )
+
+
+# Layout of a data source created from an eBPF struct:
+# struct { u64 mntns_id; struct { u32 pid; char comm[8]; } proc; }
+# plus a dynamically sized string added by an operator
+FIELDS = [
+    field(0, "mntns_id", KIND_UINT64, offs=0, size=8, flags=FIELD_FLAG_STATIC_MEMBER),
+    field(1, "proc", flags=FIELD_FLAG_STATIC_MEMBER | FIELD_FLAG_EMPTY),
+    field(2, "pid", KIND_INT32, offs=8, size=4,
+          flags=FIELD_FLAG_STATIC_MEMBER | FIELD_FLAG_HAS_PARENT, parent=1),
+    field(3, "comm", KIND_CSTRING, offs=12, size=8,
+          flags=FIELD_FLAG_STATIC_MEMBER | FIELD_FLAG_HAS_PARENT | FIELD_FLAG_HIDDEN, parent=1),
+    field(4, "k8s", KIND_STRING, payload_index=1),
+    field(5, "removed", KIND_STRING, payload_index=2, flags=FIELD_FLAG_UNREFERENCED),
+    field(6, "container", payload_index=3, size=4, flags=FIELD_FLAG_CONTAINER),
+]
+
+
+class TestDataSource(unittest.TestCase):
+    def test_decode(self):
+        ds = DataSource(datasource(FIELDS))
+        static = struct.pack("<Qi8s", 42, 1234, b"cat\0xyz")
+        got = ds.decode([static, b"default/mypod", b"", b"\0\0\0\0"])
+        self.assertEqual(
+            got,
+            {
+                "mntns_id": 42,
+                "proc": {"pid": 1234, "comm": "cat"},
+                "k8s": "default/mypod",
+            },
+        )
+
+    def test_decode_big_endian(self):
+        ds = DataSource(datasource(FIELDS, flags=DATASOURCE_FLAG_BIG_ENDIAN))
+        static = struct.pack(">Qi8s", 42, -1, b"bash")
+        got = ds.decode([static, b"", b"", b""])
+        self.assertEqual(got["mntns_id"], 42)
+        self.assertEqual(got["proc"]["pid"], -1)
+
+    def test_decode_pruned(self):
+        # Payloads of the fields that weren't requested aren't sent
+        ds = DataSource(datasource(FIELDS))
+        got = ds.decode([b""])
+        self.assertEqual(
+            got,
+            {
+                "mntns_id": None,
+                "proc": {"pid": None, "comm": None},
+                "k8s": None,
+            },
+        )
+
+
+class TestDecodeKind(unittest.TestCase):
+    def test_array(self):
+        buf = struct.pack("<3H", 1, 2, 3)
+        self.assertEqual(decode_kind(KIND_UINT16 | KIND_FLAG_ARRAY, buf), [1, 2, 3])
+
+    def test_invalid_size(self):
+        self.assertEqual(decode_kind(KIND_UINT64, b"\x01"), b"\x01")
+
+
+if __name__ == "__main__":
+    unittest.main()
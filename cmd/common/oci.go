@@ -37,10 +37,13 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	clioperator "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/cli"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/combiner"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/execallowlist"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/k8saudit"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/limiter"
 	ocihandler "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/oci-handler"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/otel-metrics"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sort"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/summary"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
 	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
@@ -110,6 +113,8 @@ func NewRunCommand(rootCmd *cobra.Command, runtime runtime.Runtime, hiddenColumn
 	paramLookup := map[string]*params.Param{}
 
 	var timeoutSeconds int
+	var rounds int
+	var roundsDuration time.Duration
 	var gadgetInstanceID string
 
 	var inFile string
@@ -367,9 +372,22 @@ func NewRunCommand(rootCmd *cobra.Command, runtime runtime.Runtime, hiddenColumn
 			}
 		}
 
+		// Let remote nodes only send the fields given explicitly
+		if f := cmd.Flags().Lookup(clioperator.ParamFields); f != nil && f.Changed {
+			paramValueMap["operator.cli."+clioperator.ParamRequestedFields] = f.Value.String()
+		}
+
 		// Also copy special oci params
 		ociParams.CopyToMap(paramValueMap, "operator.oci.")
 
+		if rounds != 0 {
+			if err := setRoundsParams(paramValueMap, rounds, roundsDuration); err != nil {
+				return err
+			}
+		} else if f := cmd.Flags().Lookup("duration"); f != nil && f.Changed {
+			return fmt.Errorf("--duration can only be used with --rounds")
+		}
+
 		err := runtime.RunGadget(gadgetCtx, runtimeParams, paramValueMap)
 		if err != nil {
 			return err
@@ -409,6 +427,18 @@ func NewRunCommand(rootCmd *cobra.Command, runtime runtime.Runtime, hiddenColumn
 	if commandMode != CommandModeAttach {
 		AddOCIFlags(cmd, ociParams, skipParams, runtime)
 		cmd.PersistentFlags().StringVarP(&inFile, "file", "f", "", "path to gadget runtime manifest to apply")
+		cmd.PersistentFlags().IntVar(
+			&rounds,
+			"rounds",
+			0,
+			"Number of rounds to run gadgets iterating over eBPF maps, like histograms, for. The maps are cleared after each round and the gadget stops after the last one",
+		)
+		cmd.PersistentFlags().DurationVar(
+			&roundsDuration,
+			"duration",
+			defaultRoundsDuration,
+			"Duration of each round when using --rounds",
+		)
 	}
 
 	AddOCIFlags(cmd, runtimeGlobalParams, skipParams, runtime)
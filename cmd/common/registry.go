@@ -34,6 +34,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	clioperator "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/cli"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
@@ -182,6 +183,9 @@ func buildCommandFromGadget(
 	var filters []string
 	var timeout int
 
+	// formatFlags holds the flags selecting output formats, by format key
+	formatFlags := make(map[string]*bool)
+
 	var skipParams []params.ValueHint
 	if skipParamsInterface, ok := gadgetDesc.(gadgets.GadgetDescSkipParams); ok {
 		skipParams = skipParamsInterface.SkipParams()
@@ -272,6 +276,18 @@ func buildCommandFromGadget(
 					Description: "The output of the gadget is returned as YAML",
 					Transform:   nil,
 				},
+				utils.OutputModeJSONPath: {
+					Name: "JSONPath",
+					Description: "The fields selected by a JSONPath template, like the jsonpath output of kubectl.\n  " +
+						"Use '-o jsonpath={.field1} {.field2}'",
+					Transform: nil,
+				},
+				utils.OutputModeCustomColumns: {
+					Name: "Custom Columns",
+					Description: "Columns whose values are selected by JSONPath expressions, like the custom-columns output of kubectl.\n  " +
+						"Use '-o custom-columns=HEADER1:.field1,HEADER2:.field2'",
+					Transform: nil,
+				},
 			})
 			defaultOutputFormat = utils.OutputModeJSON
 
@@ -303,6 +319,17 @@ func buildCommandFromGadget(
 				formats, defaultFormat := outputFormatInterface.OutputFormats()
 				outputFormats.Append(formats)
 				defaultOutputFormat = defaultFormat
+
+				for key, format := range formats {
+					if format.Flag == "" {
+						continue
+					}
+					formatFlags[key] = cmd.PersistentFlags().Bool(
+						format.Flag,
+						false,
+						fmt.Sprintf("Use the %s output format, same as --output %s", strings.ToLower(format.Name), key),
+					)
+				}
 			}
 
 			outputFormatsHelp := buildOutputFormatsHelp(outputFormats)
@@ -382,6 +409,16 @@ func buildCommandFromGadget(
 			)
 			defer gadgetCtx.Cancel()
 
+			for key, enabled := range formatFlags {
+				if !*enabled {
+					continue
+				}
+				if cmd.Flags().Changed("output") && outputMode != key {
+					return fmt.Errorf("--%s can't be used together with --output %s", outputFormats[key].Flag, outputMode)
+				}
+				outputMode = key
+			}
+
 			outputModeInfo := strings.SplitN(outputMode, "=", 2)
 			outputModeName := outputModeInfo[0]
 			outputModeParams := ""
@@ -428,6 +465,21 @@ func buildCommandFromGadget(
 						}
 						return []byte("---\n" + string(d)), nil
 					}
+				case utils.OutputModeJSONPath, utils.OutputModeCustomColumns:
+					printer, err := clioperator.NewEventPrinter(outputMode)
+					if err != nil {
+						return utils.WrapInErrInvalidArg("--output", err)
+					}
+					transformResult = func(result any) ([]byte, error) {
+						var out bytes.Buffer
+						if header := printer.Header(); header != "" {
+							out.WriteString(header + "\n")
+						}
+						if err := printer.Print(&out, result.([]byte)); err != nil {
+							return []byte{}, fmt.Errorf("transforming %+v: %w", result, err)
+						}
+						return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+					}
 				}
 
 				if timeout == 0 && gType != gadgets.TypeTrace && gType != gadgets.TypeTraceIntervals {
@@ -608,6 +660,11 @@ func buildCommandFromGadget(
 						fe.Logf(logger.WarnLevel, "could not transform event: %v", err)
 						return
 					}
+					// Like for the columns, periodic gadgets replace the
+					// previous output
+					if gType.IsPeriodic() {
+						fe.Clear()
+					}
 					fe.Output(string(transformed))
 				})
 			case utils.OutputModeColumns:
@@ -646,6 +703,17 @@ func buildCommandFromGadget(
 			case utils.OutputModeYAML:
 				yamlCallback := printEventAsYAMLFn(fe)
 				parser.SetEventCallback(yamlCallback)
+			case utils.OutputModeJSONPath, utils.OutputModeCustomColumns:
+				printer, err := clioperator.NewEventPrinter(outputMode)
+				if err != nil {
+					return utils.WrapInErrInvalidArg("--output", err)
+				}
+				// Like for the columns, periodic gadgets print the header
+				// for every interval
+				if header := printer.Header(); header != "" && !gType.IsPeriodic() {
+					fe.Output(header)
+				}
+				parser.SetEventCallback(printEventWithPrinterFn(fe, printer, gType.IsPeriodic()))
 			}
 
 			// Gadgets with parser don't return anything, they provide the
@@ -764,6 +832,31 @@ func printEventAsJSONFn(fe frontends.Frontend) func(ev any) {
 	}
 }
 
+// printEventWithPrinterFn prints the events with a printer of the cli
+// operator, clearing the screen and printing the header before each event
+// when periodic
+func printEventWithPrinterFn(fe frontends.Frontend, printer clioperator.EventPrinter, periodic bool) func(ev any) {
+	return func(ev any) {
+		d, err := json.Marshal(ev)
+		if err != nil {
+			fe.Logf(logger.WarnLevel, "marshaling %+v: %s", ev, err)
+			return
+		}
+		var out bytes.Buffer
+		if err := printer.Print(&out, d); err != nil {
+			fe.Logf(logger.WarnLevel, "printing %+v: %s", ev, err)
+			return
+		}
+		if periodic {
+			fe.Clear()
+			if header := printer.Header(); header != "" {
+				fe.Output(header)
+			}
+		}
+		fe.Output(strings.TrimSuffix(out.String(), "\n"))
+	}
+}
+
 func printEventAsJSONPrettyFn(fe frontends.Frontend) func(ev any) {
 	return func(ev any) {
 		d, err := json.MarshalIndent(ev, "", "  ")
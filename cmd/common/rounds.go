@@ -0,0 +1,54 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	otelmetrics "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/otel-metrics"
+)
+
+// The ebpf operator isn't imported here, as importing it registers it in the
+// clients too
+const (
+	mapFetchIntervalKey   = "operator.oci.ebpf.map-fetch-interval"
+	mapFetchCountKey      = "operator.oci.ebpf.map-fetch-count"
+	metricsPrintInterval  = "operator.otel-metrics." + otelmetrics.ParamOtelMetricsPrintInterval
+	defaultRoundsDuration = 10 * time.Second
+)
+
+// setRoundsParams sets the params of gadgets fetching eBPF maps, like the
+// histogram gadgets, to run the given number of rounds. The maps are fetched
+// and cleared at the end of each round, the metrics aggregate all the rounds
+// and the gadget stops after the last one.
+func setRoundsParams(paramValues map[string]string, rounds int, duration time.Duration) error {
+	if rounds < 0 {
+		return fmt.Errorf("invalid number of rounds %d", rounds)
+	}
+	if duration < otelmetrics.MinPrintInterval {
+		return fmt.Errorf("invalid round duration %s: expected at least %s", duration, otelmetrics.MinPrintInterval)
+	}
+	if _, ok := paramValues[mapFetchCountKey]; !ok {
+		return fmt.Errorf("rounds are only supported by gadgets iterating over eBPF maps")
+	}
+
+	paramValues[mapFetchIntervalKey] = duration.String()
+	paramValues[mapFetchCountKey] = strconv.Itoa(rounds)
+	// Print the aggregated metrics once per round
+	paramValues[metricsPrintInterval] = duration.String()
+	return nil
+}
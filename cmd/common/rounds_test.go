@@ -0,0 +1,48 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRoundsParams(t *testing.T) {
+	t.Parallel()
+
+	paramValues := map[string]string{
+		mapFetchIntervalKey: "1000ms",
+		mapFetchCountKey:    "0",
+	}
+	err := setRoundsParams(paramValues, 5, 30*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		mapFetchIntervalKey:  "30s",
+		mapFetchCountKey:     "5",
+		metricsPrintInterval: "30s",
+	}, paramValues)
+
+	err = setRoundsParams(paramValues, -1, 30*time.Second)
+	require.Error(t, err)
+
+	err = setRoundsParams(paramValues, 5, time.Millisecond)
+	require.Error(t, err)
+
+	// Gadgets without map iterators
+	err = setRoundsParams(map[string]string{}, 5, 30*time.Second)
+	require.Error(t, err)
+}
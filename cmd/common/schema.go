@@ -0,0 +1,103 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/eventschema"
+	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+)
+
+// NewSchemaCmd returns a command printing the JSON Schema of the events of
+// the built-in gadgets, as printed with -o json.
+func NewSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema [CATEGORY] [NAME]",
+		Short: "Print the JSON Schema of the events of the built-in gadgets",
+		Long: `Print the JSON Schema of the events the built-in gadgets print with -o json.
+
+With a category and a name, or only a name for the gadgets without a
+category, the schema of that gadget is printed. Otherwise, a list with the
+schemas of all the gadgets, or of the gadgets of the given category, is
+printed. Each schema has its version in its $id and in
+x-schema-version, it changes when fields are removed, renamed or change type.`,
+		Example: `  # Print the schema of the events of trace exec
+  schema trace exec`,
+		Args:         cobra.MaximumNArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var descs []gadgets.GadgetDesc
+			single := true
+			switch len(args) {
+			case 2:
+				desc := gadgetregistry.Get(args[0], args[1])
+				if desc == nil {
+					return fmt.Errorf("gadget %s %s not found", args[0], args[1])
+				}
+				descs = append(descs, desc)
+			case 1:
+				// Gadgets without a category are given by name only
+				if desc := gadgetregistry.Get("", args[0]); desc != nil {
+					descs = append(descs, desc)
+					break
+				}
+				single = false
+				for _, desc := range gadgetregistry.GetAll() {
+					if desc.Category() == args[0] {
+						descs = append(descs, desc)
+					}
+				}
+				if len(descs) == 0 {
+					return fmt.Errorf("no gadget found in category %s", args[0])
+				}
+			default:
+				single = false
+				descs = gadgetregistry.GetAll()
+			}
+
+			schemas := make([]*eventschema.Schema, 0, len(descs))
+			for _, desc := range descs {
+				schema, err := eventschema.ForGadget(desc)
+				if err != nil {
+					if single {
+						return err
+					}
+					// Gadgets without events, e.g. the ones printing a
+					// report, are left out of the lists
+					continue
+				}
+				schemas = append(schemas, schema)
+			}
+
+			var out any = schemas
+			if single {
+				out = schemas[0]
+			}
+
+			b, err := json.MarshalIndent(out, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling schemas: %w", err)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), string(b))
+
+			return nil
+		},
+	}
+}
@@ -34,6 +34,11 @@ const (
 	OutputModeJSON       = "json"
 	OutputModeJSONPretty = "jsonpretty"
 	OutputModeYAML       = "yaml"
+
+	// OutputModeJSONPath and OutputModeCustomColumns take an argument, like
+	// jsonpath=<template> and custom-columns=<header>:<jsonpath>,...
+	OutputModeJSONPath      = "jsonpath"
+	OutputModeCustomColumns = "custom-columns"
 )
 
 var SupportedOutputModes = []string{OutputModeJSON, OutputModeColumns}
@@ -44,9 +44,17 @@ type GadgetData struct {
 	Description string
 	OutputModes []string
 	Operations  []GadgetOperation
+	Params      []GadgetParam
 	Factory     gadgets.TraceFactory
 }
 
+type GadgetParam struct {
+	Key          string
+	Description  string
+	DefaultValue string
+	Mandatory    bool
+}
+
 type GadgetOperation struct {
 	Name  string
 	Doc   string
@@ -128,6 +136,17 @@ func main() {
 			}
 		})
 
+		if factory, ok := gadget.Factory.(gadgets.TraceFactoryWithParams); ok {
+			for _, p := range factory.ParamDescs() {
+				gadget.Params = append(gadget.Params, GadgetParam{
+					Key:          p.Key,
+					Description:  p.Description,
+					DefaultValue: p.DefaultValue,
+					Mandatory:    p.IsMandatory,
+				})
+			}
+		}
+
 		f, err := os.Create(filepath.Join(repo, "docs/legacy/crds/gadgets", gadget.Name+".md"))
 		if err != nil {
 			panic(err)
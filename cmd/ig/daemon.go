@@ -15,7 +15,6 @@
 package main
 
 import (
-	"crypto/tls"
 	"fmt"
 	"os"
 	"os/user"
@@ -34,6 +33,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	instancemanager "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/instance-manager"
 	filestore "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/store/file-store"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/trigger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
 	gadgettls "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/tls"
 )
@@ -52,6 +52,7 @@ func newDaemonCommand(runtime runtime.Runtime) *cobra.Command {
 	var serverKey string
 	var serverCert string
 	var clientCA string
+	var prefetchGadgets []string
 
 	daemonCmd.PersistentFlags().StringVarP(
 		&group,
@@ -93,6 +94,12 @@ func newDaemonCommand(runtime runtime.Runtime) *cobra.Command {
 		"",
 		"Path to CA certificate for client validation")
 
+	daemonCmd.PersistentFlags().StringSliceVar(
+		&prefetchGadgets,
+		"prefetch-gadgets",
+		nil,
+		"Gadget images to pull and prepare at startup so they start faster, e.g. trace_exec,trace_dns")
+
 	service := gadgetservice.NewService(log.StandardLogger())
 
 	for _, params := range service.GetOperatorMap() {
@@ -148,23 +155,13 @@ All these options should be set at the same time to enable TLS connection`,
 		var options []grpc.ServerOption
 
 		if tlsOptionsSet == 3 {
-			cert, err := gadgettls.LoadTLSCert(serverCert, serverKey)
-			if err != nil {
-				return fmt.Errorf("creating TLS certificate: %w", err)
-			}
-
-			ca, err := gadgettls.LoadTLSCA(clientCA)
+			// Certificates are reloaded when they change on disk
+			reloader, err := gadgettls.NewReloader(serverCert, serverKey, clientCA)
 			if err != nil {
-				return fmt.Errorf("creating TLS certificate authority: %w", err)
-			}
-
-			tlsConfig := &tls.Config{
-				ClientAuth:   tls.RequireAndVerifyClientCert,
-				Certificates: []tls.Certificate{cert},
-				ClientCAs:    ca,
+				return fmt.Errorf("loading TLS configuration: %w", err)
 			}
 
-			options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+			options = append(options, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
 
 			log.Debugf("TLS is enabled using %v, %v and %v", serverKey, serverCert, clientCA)
 		} else if !strings.HasPrefix(socketPath, "unix") {
@@ -183,11 +180,13 @@ All these options should be set at the same time to enable TLS connection`,
 
 		service.SetStore(store)
 		service.SetInstanceManager(mgr)
+		trigger.SetRunner(mgr)
 
 		return service.Run(gadgetservice.RunConfig{
-			SocketType: socketType,
-			SocketPath: socketPath,
-			SocketGID:  gid,
+			SocketType:      socketType,
+			SocketPath:      socketPath,
+			SocketGID:       gid,
+			PrefetchGadgets: prefetchGadgets,
 		}, options...)
 	}
 
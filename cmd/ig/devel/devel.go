@@ -0,0 +1,32 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package devel contains the commands helping to develop gadgets
+package devel
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewDevelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "devel",
+		Short: "Tools to develop gadgets",
+	}
+
+	cmd.AddCommand(NewNewGadgetCmd())
+	cmd.AddCommand(NewRunCmd())
+
+	return cmd
+}
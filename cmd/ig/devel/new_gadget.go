@@ -0,0 +1,244 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devel
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+const (
+	modulePath     = "github.com/inspektor-gadget/inspektor-gadget"
+	allGadgetsFile = "pkg/all-gadgets/allgadgets.go"
+)
+
+var gadgetNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// scaffoldCategories are the categories a gadget can be scaffolded for
+var scaffoldCategories = []string{"trace"}
+
+type newGadgetOpts struct {
+	name    string
+	repoDir string
+}
+
+type gadgetTemplateData struct {
+	Category      string
+	CategoryTitle string
+	Name          string
+	Title         string
+	Upper         string
+	Year          int
+}
+
+// scaffoldFile maps a template to the file it generates, relative to the root
+// of the repository
+type scaffoldFile struct {
+	template string
+	path     string
+}
+
+func NewNewGadgetCmd() *cobra.Command {
+	opts := &newGadgetOpts{}
+
+	cmd := &cobra.Command{
+		Use:          "new-gadget",
+		Short:        "Generate the skeleton of a new built-in gadget",
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := scaffoldGadget(opts.repoDir, opts.name)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+			fmt.Fprintf(out, "Gadget %q created:\n", opts.name)
+			for _, f := range files {
+				fmt.Fprintf(out, "  %s\n", f)
+			}
+			fmt.Fprintf(out, "\nRun \"make ebpf-objects\" to compile the eBPF program, then look for the TODOs in the generated files.\n")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.name, "name", "", "Name of the gadget, including its category (e.g. trace/foo)")
+	cmd.Flags().StringVar(&opts.repoDir, "repo", ".", "Path to the root of the Inspektor Gadget repository")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func parseGadgetName(name string) (string, string, error) {
+	category, gadgetName, ok := strings.Cut(name, "/")
+	if !ok {
+		return "", "", fmt.Errorf("invalid gadget name %q: expected CATEGORY/NAME", name)
+	}
+	found := false
+	for _, c := range scaffoldCategories {
+		if c == category {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", "", fmt.Errorf("unsupported category %q: supported categories are %s",
+			category, strings.Join(scaffoldCategories, ", "))
+	}
+	if !gadgetNameRegexp.MatchString(gadgetName) {
+		return "", "", fmt.Errorf("invalid gadget name %q: only lowercase letters and digits are allowed", gadgetName)
+	}
+	return category, gadgetName, nil
+}
+
+func title(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// scaffoldGadget generates the files of a new gadget in the repository at
+// repoDir and registers it in the list of all gadgets. It returns the paths of
+// the created and modified files.
+func scaffoldGadget(repoDir, name string) ([]string, error) {
+	category, gadgetName, err := parseGadgetName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	allGadgetsPath := filepath.Join(repoDir, allGadgetsFile)
+	if _, err := os.Stat(allGadgetsPath); err != nil {
+		return nil, fmt.Errorf("%q doesn't look like the Inspektor Gadget repository: %w", repoDir, err)
+	}
+
+	gadgetDir := filepath.Join("pkg", "gadgets", category, gadgetName)
+	if _, err := os.Stat(filepath.Join(repoDir, gadgetDir)); err == nil {
+		return nil, fmt.Errorf("gadget %q already exists in %s", name, gadgetDir)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	data := gadgetTemplateData{
+		Category:      category,
+		CategoryTitle: title(category),
+		Name:          gadgetName,
+		Title:         title(gadgetName),
+		Upper:         strings.ToUpper(gadgetName),
+		Year:          time.Now().Year(),
+	}
+
+	files := []scaffoldFile{
+		{"bpf.c.tmpl", filepath.Join(gadgetDir, "tracer", "bpf", gadgetName+".bpf.c")},
+		{"bpf.h.tmpl", filepath.Join(gadgetDir, "tracer", "bpf", gadgetName+".h")},
+		{"tracer.go.tmpl", filepath.Join(gadgetDir, "tracer", "tracer.go")},
+		{"gadget.go.tmpl", filepath.Join(gadgetDir, "tracer", "gadget.go")},
+		{"types.go.tmpl", filepath.Join(gadgetDir, "types", "types.go")},
+		{"integration_test.go.tmpl", filepath.Join("integration", "k8s", fmt.Sprintf("%s_%s_test.go", category, gadgetName))},
+	}
+
+	var created []string
+	for _, f := range files {
+		content, err := renderTemplate(filepath.Join("templates", category, f.template), data)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(f.path, ".go") {
+			content, err = format.Source(content)
+			if err != nil {
+				return nil, fmt.Errorf("formatting %s: %w", f.path, err)
+			}
+		}
+
+		path := filepath.Join(repoDir, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return nil, err
+		}
+		created = append(created, f.path)
+	}
+
+	importPath := fmt.Sprintf("%s/pkg/gadgets/%s/%s/tracer", modulePath, category, gadgetName)
+	if err := addGadgetImport(allGadgetsPath, category, importPath); err != nil {
+		return nil, fmt.Errorf("registering gadget in %s: %w", allGadgetsFile, err)
+	}
+	created = append(created, allGadgetsFile)
+
+	return created, nil
+}
+
+func renderTemplate(path string, data gadgetTemplateData) ([]byte, error) {
+	tmpl, err := template.ParseFS(templatesFS, path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", path, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("executing template %s: %w", path, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// addGadgetImport adds a blank import of importPath to the imports of the
+// given category in the file listing all gadgets, keeping them sorted.
+func addGadgetImport(path, category, importPath string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	categoryPrefix := fmt.Sprintf("\t_ \"%s/pkg/gadgets/%s/", modulePath, category)
+	newLine := fmt.Sprintf("\t_ \"%s\"", importPath)
+
+	lines := strings.Split(string(content), "\n")
+	var categoryLines []int
+	for i, line := range lines {
+		if line == newLine {
+			return nil
+		}
+		if strings.HasPrefix(line, categoryPrefix) {
+			categoryLines = append(categoryLines, i)
+		}
+	}
+	if len(categoryLines) == 0 {
+		return fmt.Errorf("no gadget of category %q found", category)
+	}
+
+	// Insert it before the first import that sorts after it
+	pos := categoryLines[len(categoryLines)-1] + 1
+	idx := sort.Search(len(categoryLines), func(i int) bool {
+		return lines[categoryLines[i]] > newLine
+	})
+	if idx < len(categoryLines) {
+		pos = categoryLines[idx]
+	}
+
+	lines = append(lines[:pos], append([]string{newLine}, lines[pos:]...)...)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644)
+}
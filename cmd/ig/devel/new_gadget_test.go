@@ -0,0 +1,87 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAllGadgets = `package allgadgets
+
+import (
+	// Top Category
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/file/tracer"
+
+	// Trace Category
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/bind/tracer"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/signal/tracer"
+)
+`
+
+func TestParseGadgetName(t *testing.T) {
+	category, name, err := parseGadgetName("trace/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "trace", category)
+	assert.Equal(t, "foo", name)
+
+	for _, invalid := range []string{"foo", "unknown/foo", "trace/Foo", "trace/foo-bar", "trace/"} {
+		_, _, err := parseGadgetName(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestScaffoldGadget(t *testing.T) {
+	repoDir := t.TempDir()
+	allGadgetsPath := filepath.Join(repoDir, allGadgetsFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(allGadgetsPath), 0o755))
+	require.NoError(t, os.WriteFile(allGadgetsPath, []byte(testAllGadgets), 0o644))
+
+	files, err := scaffoldGadget(repoDir, "trace/foo")
+	require.NoError(t, err)
+	assert.Contains(t, files, "pkg/gadgets/trace/foo/tracer/bpf/foo.bpf.c")
+	assert.Contains(t, files, "integration/k8s/trace_foo_test.go")
+
+	for _, f := range files {
+		assert.FileExists(t, filepath.Join(repoDir, f))
+	}
+
+	tracer, err := os.ReadFile(filepath.Join(repoDir, "pkg/gadgets/trace/foo/tracer/tracer.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(tracer), "spec, err := loadFoo()")
+	assert.Contains(t, string(tracer), "-type event foo ./bpf/foo.bpf.c")
+
+	// The gadget is registered with the other ones of its category, in order
+	allGadgets, err := os.ReadFile(allGadgetsPath)
+	require.NoError(t, err)
+	bind := strings.Index(string(allGadgets), "trace/bind/tracer")
+	foo := strings.Index(string(allGadgets), "trace/foo/tracer")
+	signal := strings.Index(string(allGadgets), "trace/signal/tracer")
+	assert.True(t, bind < foo && foo < signal, "unexpected order:\n%s", allGadgets)
+
+	// An existing gadget isn't overwritten
+	_, err = scaffoldGadget(repoDir, "trace/foo")
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestScaffoldGadgetNotRepo(t *testing.T) {
+	_, err := scaffoldGadget(t.TempDir(), "trace/foo")
+	assert.ErrorContains(t, err, "doesn't look like the Inspektor Gadget repository")
+}
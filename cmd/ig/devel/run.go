@@ -0,0 +1,335 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultBuildFile  = "build.yaml"
+	defaultEBPFSource = "program.bpf.c"
+
+	// changes happening within this interval are handled as a single one, as
+	// editors usually write files in several steps
+	watchDebounce = 300 * time.Millisecond
+
+	// time given to the gadget to stop before killing it
+	stopTimeout = 5 * time.Second
+)
+
+type runOpts struct {
+	watch        bool
+	local        bool
+	builderImage string
+	image        string
+}
+
+// gadgetSource describes the sources of a gadget being developed
+type gadgetSource struct {
+	// dir is the directory containing the sources of the gadget
+	dir string
+	// buildFile is the build file to use, empty to use the one of dir
+	buildFile string
+	// name is used to derive the name of the image
+	name string
+}
+
+func NewRunCmd() *cobra.Command {
+	opts := &runOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "run PATH [-- GADGET FLAGS]",
+		Short: "Build and run a gadget from its sources",
+		Long: `Build and run a gadget from its sources.
+
+PATH is either the directory of the gadget or its eBPF source file. With
+--watch, the gadget is rebuilt and restarted each time a file of that
+directory changes. Flags after "--" are passed to "ig run".`,
+		SilenceUsage: true,
+		Args:         cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cmd.ArgsLenAtDash() > 1 {
+				return fmt.Errorf("expected a single PATH before \"--\"")
+			}
+
+			src, err := resolveGadgetSource(args[0])
+			if err != nil {
+				return err
+			}
+			if src.buildFile != "" {
+				defer os.Remove(src.buildFile)
+			}
+
+			if opts.image == "" {
+				opts.image = fmt.Sprintf("devel/%s:latest", src.name)
+			}
+
+			ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer cancel()
+
+			return runDevel(ctx, opts, src, args[1:])
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.watch, "watch", "w", false, "Rebuild and restart the gadget when its sources change")
+	cmd.Flags().BoolVarP(&opts.local, "local", "l", false, "Build using local tools instead of the builder image")
+	cmd.Flags().StringVar(&opts.builderImage, "builder-image", "", "Builder image to use")
+	cmd.Flags().StringVarP(&opts.image, "tag", "t", "", "Name of the built image (defaults to devel/NAME:latest)")
+
+	return cmd
+}
+
+// resolveGadgetSource finds the directory and build file of the gadget at
+// path. If path is an eBPF source file and the directory has no build file, a
+// temporary one using it is created.
+func resolveGadgetSource(path string) (*gadgetSource, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return &gadgetSource{dir: path, name: imageName(filepath.Base(path))}, nil
+	}
+
+	src := &gadgetSource{
+		dir:  filepath.Dir(path),
+		name: imageName(strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".c"), ".bpf")),
+	}
+
+	_, err = os.Stat(filepath.Join(src.dir, defaultBuildFile))
+	switch {
+	case err == nil:
+		return src, nil
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	case filepath.Base(path) == defaultEBPFSource:
+		return src, nil
+	}
+
+	content, err := yaml.Marshal(map[string]string{"ebpfsource": filepath.Base(path)})
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "gadget-devel-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("creating build file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("writing build file: %w", err)
+	}
+	src.buildFile = f.Name()
+	return src, nil
+}
+
+// imageName converts s to a valid image name component
+func imageName(s string) string {
+	s = strings.ToLower(s)
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+func buildArgs(opts *runOpts, src *gadgetSource) []string {
+	args := []string{"image", "build", src.dir, "--tag", opts.image}
+	if src.buildFile != "" {
+		args = append(args, "--file", src.buildFile)
+	}
+	if opts.local {
+		args = append(args, "--local")
+	}
+	if opts.builderImage != "" {
+		args = append(args, "--builder-image", opts.builderImage)
+	}
+	return args
+}
+
+func runArgs(opts *runOpts, gadgetArgs []string) []string {
+	return append([]string{"run", opts.image}, gadgetArgs...)
+}
+
+// isRelevantChange returns whether ev should trigger a rebuild. Metadata
+// changes and the temporary files of editors are ignored.
+func isRelevantChange(ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+	base := filepath.Base(ev.Name)
+	return !strings.HasPrefix(base, ".") &&
+		!strings.HasPrefix(base, "#") &&
+		!strings.HasSuffix(base, "~") &&
+		!strings.HasSuffix(base, ".swp")
+}
+
+func runDevel(ctx context.Context, opts *runOpts, src *gadgetSource, gadgetArgs []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("getting executable: %w", err)
+	}
+
+	if !opts.watch {
+		if err := runChild(ctx, exe, buildArgs(opts, src)); err != nil {
+			return fmt.Errorf("building gadget: %w", err)
+		}
+		return runChild(ctx, exe, runArgs(opts, gadgetArgs))
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(src.dir); err != nil {
+		return fmt.Errorf("watching %s: %w", src.dir, err)
+	}
+
+	for {
+		var gadget *exec.Cmd
+		var gadgetDone chan error
+
+		if err := runChild(ctx, exe, buildArgs(opts, src)); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Errorf("Building gadget: %s", err)
+		} else {
+			gadget, gadgetDone, err = startChild(exe, runArgs(opts, gadgetArgs))
+			if err != nil {
+				return fmt.Errorf("starting gadget: %w", err)
+			}
+		}
+		log.Infof("Watching %s for changes", src.dir)
+
+		changed, err := waitForChange(ctx, watcher, gadgetDone)
+		if gadget != nil {
+			stopChild(gadget, gadgetDone)
+		}
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		log.Infof("Sources changed, rebuilding gadget")
+	}
+}
+
+// waitForChange blocks until the sources change or ctx is done. If the gadget
+// exits in the meantime, it keeps waiting for changes.
+func waitForChange(ctx context.Context, watcher *fsnotify.Watcher, gadgetDone chan error) (bool, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case err := <-gadgetDone:
+			if err != nil {
+				log.Warnf("Gadget exited: %s", err)
+			} else {
+				log.Infof("Gadget exited")
+			}
+			gadgetDone = nil
+		case err := <-watcher.Errors:
+			return false, fmt.Errorf("watching sources: %w", err)
+		case ev := <-watcher.Events:
+			if !isRelevantChange(ev) {
+				continue
+			}
+			// Wait for the burst of events to end
+			timer := time.NewTimer(watchDebounce)
+		debounce:
+			for {
+				select {
+				case <-watcher.Events:
+					timer.Reset(watchDebounce)
+				case <-timer.C:
+					break debounce
+				case <-ctx.Done():
+					timer.Stop()
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+	}
+}
+
+func runChild(ctx context.Context, exe string, args []string) error {
+	cmd, done, err := startChild(exe, args)
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		stopChild(cmd, done)
+		return ctx.Err()
+	}
+}
+
+func startChild(exe string, args []string) (*exec.Cmd, chan error, error) {
+	log.Debugf("Running %s %s", exe, strings.Join(args, " "))
+	cmd := exec.Command(exe, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	// done is closed after sending the result, so stopChild doesn't block
+	// if the result was already received
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+		close(done)
+	}()
+	return cmd, done, nil
+}
+
+// stopChild interrupts cmd and kills it if it doesn't stop in time. done is
+// the channel returned by startChild.
+func stopChild(cmd *exec.Cmd, done chan error) {
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		cmd.Process.Kill()
+		<-done
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package devel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveGadgetSource(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "My_Gadget")
+	require.NoError(t, os.Mkdir(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.bpf.c"), nil, 0o644))
+
+	// Directory
+	src, err := resolveGadgetSource(dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, src.dir)
+	assert.Equal(t, "my_gadget", src.name)
+	assert.Empty(t, src.buildFile)
+
+	// Source file without build file
+	src, err = resolveGadgetSource(filepath.Join(dir, "foo.bpf.c"))
+	require.NoError(t, err)
+	assert.Equal(t, dir, src.dir)
+	assert.Equal(t, "foo", src.name)
+	require.NotEmpty(t, src.buildFile)
+	defer os.Remove(src.buildFile)
+	content, err := os.ReadFile(src.buildFile)
+	require.NoError(t, err)
+	assert.Equal(t, "ebpfsource: foo.bpf.c\n", string(content))
+
+	opts := &runOpts{image: "devel/foo:latest", local: true}
+	assert.Equal(t,
+		[]string{"image", "build", dir, "--tag", "devel/foo:latest", "--file", src.buildFile, "--local"},
+		buildArgs(opts, src))
+	assert.Equal(t,
+		[]string{"run", "devel/foo:latest", "--verify-image=false"},
+		runArgs(opts, []string{"--verify-image=false"}))
+
+	// Source file with build file
+	require.NoError(t, os.WriteFile(filepath.Join(dir, defaultBuildFile), nil, 0o644))
+	src, err = resolveGadgetSource(filepath.Join(dir, "foo.bpf.c"))
+	require.NoError(t, err)
+	assert.Empty(t, src.buildFile)
+
+	_, err = resolveGadgetSource(filepath.Join(dir, "missing.bpf.c"))
+	assert.Error(t, err)
+}
+
+func TestIsRelevantChange(t *testing.T) {
+	assert.True(t, isRelevantChange(fsnotify.Event{Name: "/gadget/program.bpf.c", Op: fsnotify.Write}))
+	assert.True(t, isRelevantChange(fsnotify.Event{Name: "/gadget/gadget.yaml", Op: fsnotify.Create}))
+	assert.False(t, isRelevantChange(fsnotify.Event{Name: "/gadget/program.bpf.c", Op: fsnotify.Chmod}))
+	assert.False(t, isRelevantChange(fsnotify.Event{Name: "/gadget/.program.bpf.c.swp", Op: fsnotify.Write}))
+	assert.False(t, isRelevantChange(fsnotify.Event{Name: "/gadget/program.bpf.c~", Op: fsnotify.Create}))
+}
@@ -34,6 +34,7 @@ func NewAdviseCmd(gadgetNamespaceIn string) *cobra.Command {
 
 	cmd.AddCommand(newNetworkPolicyCmd(gadgetNamespace))
 	cmd.AddCommand(newSeccompProfileCmd(gadgetNamespace))
+	cmd.AddCommand(newAllCmd())
 
 	return cmd
 }
@@ -0,0 +1,464 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/advise/networkpolicy/advisor"
+	capabilitiestypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
+)
+
+var (
+	allWorkload  string
+	allDuration  time.Duration
+	allOutputDir string
+)
+
+func newAllCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "all",
+		Short: "Run all the advisors on a workload and generate a hardening report",
+		Long: `Run the seccomp-profile, network-policy and capabilities advisors concurrently on
+the pods of a workload and generate a single report with the recommended
+manifests and a markdown summary.`,
+		Example: `  kubectl gadget advise all --workload deploy/foo --duration 10m
+  kubectl gadget advise all -n prod --workload sts/db --output-dir ./db-hardening`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         runAdviseAll,
+	}
+
+	cmd.Flags().StringVar(&allWorkload, "workload", "",
+		"Workload to advise on, as kind/name (e.g. deploy/foo). Supported kinds are pod, deploy, sts, ds, rs and job")
+	cmd.Flags().DurationVarP(&allDuration, "duration", "d", 10*time.Minute, "Duration of the observation")
+	cmd.Flags().StringVar(&allOutputDir, "output-dir", "",
+		"Directory where the report and the manifests are written. By default, the report is printed with the manifests embedded")
+
+	return cmd
+}
+
+// workloadKinds maps the names accepted in --workload to kinds
+var workloadKinds = map[string]string{
+	"po":           "Pod",
+	"pod":          "Pod",
+	"pods":         "Pod",
+	"deploy":       "Deployment",
+	"deployment":   "Deployment",
+	"deployments":  "Deployment",
+	"sts":          "StatefulSet",
+	"statefulset":  "StatefulSet",
+	"statefulsets": "StatefulSet",
+	"ds":           "DaemonSet",
+	"daemonset":    "DaemonSet",
+	"daemonsets":   "DaemonSet",
+	"rs":           "ReplicaSet",
+	"replicaset":   "ReplicaSet",
+	"replicasets":  "ReplicaSet",
+	"job":          "Job",
+	"jobs":         "Job",
+}
+
+func parseWorkload(s string) (kind string, name string, err error) {
+	k, name, ok := strings.Cut(s, "/")
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("%q should have the kind/name format", s)
+	}
+	kind, ok = workloadKinds[strings.ToLower(k)]
+	if !ok {
+		return "", "", fmt.Errorf("kind %q is not supported", k)
+	}
+	return kind, name, nil
+}
+
+func containerNames(spec *corev1.PodSpec) []string {
+	names := make([]string, 0, len(spec.Containers))
+	for _, c := range spec.Containers {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func selectorLabels(selector *metav1.LabelSelector) (map[string]string, error) {
+	if selector != nil && len(selector.MatchExpressions) > 0 {
+		return nil, errors.New("label selectors with expressions aren't supported")
+	}
+	if selector == nil || len(selector.MatchLabels) == 0 {
+		return nil, errors.New("the workload has no label selector")
+	}
+	return selector.MatchLabels, nil
+}
+
+// resolveWorkload gets the labels selecting the pods of the workload and
+// the names of their containers
+func resolveWorkload(ctx context.Context, client kubernetes.Interface, namespace, kind, name string) (*workload, error) {
+	w := &workload{
+		Kind:       kind,
+		APIVersion: "apps/v1",
+		Name:       name,
+		Namespace:  namespace,
+	}
+
+	var selector *metav1.LabelSelector
+	var template *corev1.PodTemplateSpec
+
+	switch kind {
+	case "Pod":
+		pod, err := client.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.APIVersion = "v1"
+		w.Containers = containerNames(&pod.Spec)
+		return w, nil
+	case "Deployment":
+		obj, err := client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		selector, template = obj.Spec.Selector, &obj.Spec.Template
+	case "StatefulSet":
+		obj, err := client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		selector, template = obj.Spec.Selector, &obj.Spec.Template
+	case "DaemonSet":
+		obj, err := client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		selector, template = obj.Spec.Selector, &obj.Spec.Template
+	case "ReplicaSet":
+		obj, err := client.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		selector, template = obj.Spec.Selector, &obj.Spec.Template
+	case "Job":
+		obj, err := client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		w.APIVersion = "batch/v1"
+		selector, template = obj.Spec.Selector, &obj.Spec.Template
+	default:
+		return nil, fmt.Errorf("kind %q is not supported", kind)
+	}
+
+	labels, err := selectorLabels(selector)
+	if err != nil {
+		return nil, err
+	}
+	w.Labels = labels
+	w.Containers = containerNames(&template.Spec)
+	return w, nil
+}
+
+// seccompPod returns the pod traced by the seccomp advisor: the seccomp
+// gadget generates profiles for a single pod
+func seccompPod(ctx context.Context, client kubernetes.Interface, w *workload) (string, error) {
+	if w.Kind == "Pod" {
+		return w.Name, nil
+	}
+	pods, err := client.CoreV1().Pods(w.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{MatchLabels: w.Labels}),
+	})
+	if err != nil {
+		return "", commonutils.WrapInErrListPods(err)
+	}
+	var running []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning {
+			running = append(running, pod.Name)
+		}
+	}
+	if len(running) == 0 {
+		return "", fmt.Errorf("no running pod found for %s", w)
+	}
+	sort.Strings(running)
+	return running[0], nil
+}
+
+// traceTracker deletes the traces created by the advisors when the command
+// is interrupted
+type traceTracker struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (t *traceTracker) create(config *utils.TraceConfig) (string, error) {
+	id, err := utils.CreateTrace(config)
+	if err != nil {
+		return "", err
+	}
+	t.mu.Lock()
+	t.ids = append(t.ids, id)
+	t.mu.Unlock()
+	return id, nil
+}
+
+func (t *traceTracker) handleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-c
+		t.mu.Lock()
+		for _, id := range t.ids {
+			utils.DeleteTrace(gadgetNamespace, id)
+		}
+		t.mu.Unlock()
+		os.Exit(1)
+	}()
+}
+
+// streamTrace runs a stream trace during the observation window and calls
+// handle with each line
+func (t *traceTracker) streamTrace(gadgetName string, flags *utils.CommonFlags, parameters map[string]string, handle func(string)) error {
+	config := &utils.TraceConfig{
+		GadgetName:       gadgetName,
+		GadgetNamespace:  gadgetNamespace,
+		Operation:        gadgetv1alpha1.OperationStart,
+		TraceOutputMode:  gadgetv1alpha1.TraceOutputModeStream,
+		TraceOutputState: gadgetv1alpha1.TraceStateStarted,
+		CommonFlags:      flags,
+		Parameters:       parameters,
+	}
+	id, err := t.create(config)
+	if err != nil {
+		return err
+	}
+	defer utils.DeleteTrace(gadgetNamespace, id)
+
+	var mu sync.Mutex
+	return utils.PrintTraceOutputFromStream(gadgetNamespace, id, string(config.TraceOutputState), flags,
+		func(line string) string {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, l := range strings.Split(line, "\n") {
+				if l = strings.TrimSpace(l); l != "" {
+					handle(l)
+				}
+			}
+			return ""
+		})
+}
+
+// seccompTrace records the syscalls of a container during the observation
+// window
+func (t *traceTracker) seccompTrace(namespace, podName, containerName string, duration time.Duration) ([]string, error) {
+	config := &utils.TraceConfig{
+		GadgetName:        "seccomp",
+		GadgetNamespace:   gadgetNamespace,
+		Operation:         gadgetv1alpha1.OperationStart,
+		TraceOutputMode:   gadgetv1alpha1.TraceOutputModeStatus,
+		TraceInitialState: gadgetv1alpha1.TraceStateStarted,
+		CommonFlags: &utils.CommonFlags{
+			Namespace:     namespace,
+			Podname:       podName,
+			Containername: containerName,
+		},
+	}
+	id, err := t.create(config)
+	if err != nil {
+		return nil, err
+	}
+	defer utils.DeleteTrace(gadgetNamespace, id)
+
+	time.Sleep(duration)
+
+	if err := utils.SetTraceOperation(gadgetNamespace, id, string(gadgetv1alpha1.OperationGenerate)); err != nil {
+		return nil, commonutils.WrapInErrGenGadgetOutput(err)
+	}
+	if err := utils.SetTraceOperation(gadgetNamespace, id, string(gadgetv1alpha1.OperationStop)); err != nil {
+		return nil, commonutils.WrapInErrStopGadget(err)
+	}
+
+	var syscalls []string
+	err = utils.PrintTraceOutputFromStatus(gadgetNamespace, id, string(gadgetv1alpha1.TraceStateStopped),
+		func(_ string, results []string) error {
+			// Only the node running the pod generates a profile
+			for _, r := range results {
+				if r == "" {
+					continue
+				}
+				var err error
+				syscalls, err = syscallsFromLinuxSeccomp(r)
+				return err
+			}
+			return errors.New("no profile generated")
+		})
+	if err != nil {
+		return nil, commonutils.WrapInErrGetGadgetOutput(err)
+	}
+	return syscalls, nil
+}
+
+func runAdviseAll(cmd *cobra.Command, args []string) error {
+	if allWorkload == "" {
+		return commonutils.WrapInErrMissingArgs("--workload")
+	}
+	kind, name, err := parseWorkload(allWorkload)
+	if err != nil {
+		return commonutils.WrapInErrInvalidArg("--workload", err)
+	}
+	if allDuration < time.Second {
+		return commonutils.WrapInErrInvalidArg("--duration", errors.New("must be at least 1s"))
+	}
+
+	client, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
+	if err != nil {
+		return commonutils.WrapInErrSetupK8sClient(err)
+	}
+
+	namespace, _ := utils.GetNamespace()
+	ctx := context.Background()
+	w, err := resolveWorkload(ctx, client, namespace, kind, name)
+	if err != nil {
+		return fmt.Errorf("getting workload %s: %w", allWorkload, err)
+	}
+
+	report := newHardeningReport(w, allDuration.String())
+
+	flags := &utils.CommonFlags{
+		Namespace: namespace,
+		Labels:    w.Labels,
+		Timeout:   int(allDuration.Seconds()),
+	}
+	if w.Kind == "Pod" {
+		flags.Podname = w.Name
+	}
+
+	tracker := &traceTracker{}
+	tracker.handleSignals()
+
+	var mu sync.Mutex
+	addError := func(advisorName string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %s", advisorName, err))
+	}
+
+	fmt.Fprintf(os.Stderr, "Observing %s in namespace %q during %s...\n", w, namespace, allDuration)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var lines []string
+		err := tracker.streamTrace("network-graph", flags, nil, func(line string) {
+			lines = append(lines, line)
+		})
+		if err != nil {
+			addError("network-policy", err)
+			return
+		}
+		adv := advisor.NewAdvisor()
+		if err := adv.LoadBuffer([]byte(strings.Join(lines, "\n"))); err != nil {
+			addError("network-policy", err)
+			return
+		}
+		adv.GeneratePolicies()
+		mu.Lock()
+		report.NetworkPolicies = adv.Policies
+		mu.Unlock()
+	}()
+
+	caps := newCapabilitiesRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		params := map[string]string{capabilitiestypes.UniqueParam: "true"}
+		err := tracker.streamTrace("capabilities", flags, params, func(line string) {
+			if err := caps.addLine(line); err != nil {
+				addError("capabilities", fmt.Errorf("parsing event: %w", err))
+			}
+		})
+		if err != nil {
+			addError("capabilities", err)
+		}
+	}()
+
+	pod, err := seccompPod(ctx, client, w)
+	if err != nil {
+		addError("seccomp-profile", err)
+	} else {
+		report.SeccompPod = pod
+		for _, c := range report.Containers {
+			wg.Add(1)
+			go func(c *containerAdvice) {
+				defer wg.Done()
+				syscalls, err := tracker.seccompTrace(namespace, pod, c.Name, allDuration)
+				if err != nil {
+					addError("seccomp-profile", fmt.Errorf("container %q: %w", c.Name, err))
+					return
+				}
+				mu.Lock()
+				c.Syscalls = syscalls
+				mu.Unlock()
+			}(c)
+		}
+	}
+
+	wg.Wait()
+	caps.apply(report)
+	sort.Strings(report.Errors)
+
+	manifests, err := report.manifests()
+	if err != nil {
+		return err
+	}
+
+	if allOutputDir == "" {
+		fmt.Print(report.markdown(manifests, true))
+		return nil
+	}
+
+	if err := os.MkdirAll(allOutputDir, 0o755); err != nil {
+		return fmt.Errorf("creating directory %q: %w", allOutputDir, err)
+	}
+	files := map[string]string{"report.md": report.markdown(manifests, false)}
+	for name, content := range manifests {
+		files[name] = content
+	}
+	for name, content := range files {
+		filename := filepath.Join(allOutputDir, name)
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("writing %q: %w", filename, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Report written to %q\n", filepath.Join(allOutputDir, "report.md"))
+	return nil
+}
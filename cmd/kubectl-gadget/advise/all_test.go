@@ -0,0 +1,183 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advise
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseWorkload(t *testing.T) {
+	kind, name, err := parseWorkload("deploy/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "Deployment", kind)
+	assert.Equal(t, "foo", name)
+
+	kind, _, err = parseWorkload("STS/db")
+	require.NoError(t, err)
+	assert.Equal(t, "StatefulSet", kind)
+
+	for _, invalid := range []string{"foo", "deploy/", "cronjob/foo"} {
+		_, _, err := parseWorkload(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResolveWorkload(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "prod"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "foo"}},
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}},
+					},
+				},
+			},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "prod"},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "app", Operator: metav1.LabelSelectorOpExists},
+					},
+				},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "prod"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "shell"}},
+			},
+		},
+	)
+
+	w, err := resolveWorkload(ctx, client, "prod", "Deployment", "foo")
+	require.NoError(t, err)
+	assert.Equal(t, &workload{
+		Kind:       "Deployment",
+		APIVersion: "apps/v1",
+		Name:       "foo",
+		Namespace:  "prod",
+		Labels:     map[string]string{"app": "foo"},
+		Containers: []string{"app", "sidecar"},
+	}, w)
+
+	w, err = resolveWorkload(ctx, client, "prod", "Pod", "debug")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", w.APIVersion)
+	assert.Nil(t, w.Labels)
+	assert.Equal(t, []string{"shell"}, w.Containers)
+
+	_, err = resolveWorkload(ctx, client, "prod", "DaemonSet", "agent")
+	assert.ErrorContains(t, err, "expressions")
+
+	_, err = resolveWorkload(ctx, client, "default", "Deployment", "foo")
+	assert.Error(t, err)
+}
+
+func TestSeccompPod(t *testing.T) {
+	ctx := context.Background()
+	pod := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prod", Labels: map[string]string{"app": "foo"}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("foo-c", corev1.PodRunning),
+		pod("foo-a", corev1.PodPending),
+		pod("foo-b", corev1.PodRunning),
+	)
+	w := &workload{Kind: "Deployment", Name: "foo", Namespace: "prod", Labels: map[string]string{"app": "foo"}}
+
+	name, err := seccompPod(ctx, client, w)
+	require.NoError(t, err)
+	assert.Equal(t, "foo-b", name)
+
+	w.Labels = map[string]string{"app": "bar"}
+	_, err = seccompPod(ctx, client, w)
+	assert.Error(t, err)
+}
+
+func TestHardeningReport(t *testing.T) {
+	w := &workload{
+		Kind:       "Deployment",
+		APIVersion: "apps/v1",
+		Name:       "foo",
+		Namespace:  "prod",
+		Labels:     map[string]string{"app": "foo"},
+		Containers: []string{"app", "sidecar"},
+	}
+	r := newHardeningReport(w, "10m0s")
+
+	syscalls, err := syscallsFromLinuxSeccomp(`{
+  "defaultAction": "SCMP_ACT_ERRNO",
+  "syscalls": [{"names": ["write", "read", "write"], "action": "SCMP_ACT_ALLOW"}]
+}`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, syscalls)
+	r.Containers[0].Syscalls = syscalls
+
+	caps := newCapabilitiesRecorder()
+	for _, line := range []string{
+		`{"k8s":{"namespace":"prod","podName":"foo-b","containerName":"app"},"capName":"NET_BIND_SERVICE","verdict":"Allow"}`,
+		`{"k8s":{"namespace":"prod","podName":"foo-b","containerName":"app"},"capName":"CHOWN","verdict":"Allow"}`,
+		`{"k8s":{"namespace":"prod","podName":"foo-b","containerName":"app"},"capName":"SYS_ADMIN","verdict":"Deny"}`,
+		`{"k8s":{"namespace":"prod","podName":"foo-b","containerName":"app"},"capName":"CHOWN","verdict":"Allow"}`,
+	} {
+		require.NoError(t, caps.addLine(line))
+	}
+	require.Error(t, caps.addLine("not json"))
+	caps.apply(r)
+
+	assert.Equal(t, []string{"CHOWN", "NET_BIND_SERVICE"}, r.Containers[0].Capabilities)
+	assert.Equal(t, []string{"SYS_ADMIN"}, r.Containers[0].DeniedCapabilities)
+	assert.Empty(t, r.Containers[1].Capabilities)
+
+	manifests, err := r.manifests()
+	require.NoError(t, err)
+	assert.NotContains(t, manifests, "network-policies.yaml")
+
+	assert.Contains(t, manifests["seccomp-profiles.yaml"], "name: foo-app")
+	assert.NotContains(t, manifests["seccomp-profiles.yaml"], "foo-sidecar")
+
+	patch := manifests["security-context-patch.yaml"]
+	assert.Contains(t, patch, "kind: Deployment")
+	assert.Contains(t, patch, "localhostProfile: operator/prod/foo-app.json")
+	assert.Contains(t, patch, "- NET_BIND_SERVICE")
+	assert.Equal(t, 1, strings.Count(patch, "localhostProfile"))
+
+	md := r.markdown(manifests, true)
+	assert.Contains(t, md, "# Hardening report for deployment/foo")
+	assert.Contains(t, md, "| app | 2 | `CHOWN`, `NET_BIND_SERVICE` | `SYS_ADMIN` |")
+	assert.Contains(t, md, "| sidecar | not traced | none | none |")
+	assert.Contains(t, md, "```yaml\n")
+
+	md = r.markdown(manifests, false)
+	assert.Contains(t, md, "- [seccomp-profiles.yaml](seccomp-profiles.yaml)")
+	assert.NotContains(t, md, "```yaml")
+}
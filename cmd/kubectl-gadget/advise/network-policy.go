@@ -16,17 +16,22 @@ package advise
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/advise/networkpolicy/advisor"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 )
 
 var networkPolicyMonitorCmd = &cobra.Command{
@@ -44,6 +49,7 @@ var networkPolicyReportCmd = &cobra.Command{
 var (
 	inputFileName  string
 	outputFileName string
+	diffPolicies   bool
 )
 
 func newNetworkPolicyCmd(gadgetNamespace string) *cobra.Command {
@@ -59,6 +65,7 @@ func newNetworkPolicyCmd(gadgetNamespace string) *cobra.Command {
 	networkPolicyCmd.AddCommand(networkPolicyReportCmd)
 	networkPolicyReportCmd.PersistentFlags().StringVarP(&inputFileName, "input", "", "", "File with recorded network activity")
 	networkPolicyReportCmd.PersistentFlags().StringVarP(&outputFileName, "output", "", "-", "File name output")
+	networkPolicyReportCmd.PersistentFlags().BoolVarP(&diffPolicies, "diff", "", false, "Compare the generated policies to the network policies already applied to the same pods")
 
 	return networkPolicyCmd
 }
@@ -133,13 +140,23 @@ func runNetworkPolicyReport(cmd *cobra.Command, args []string) error {
 
 	adv.GeneratePolicies()
 
+	out := adv.FormatPolicies()
+	if diffPolicies {
+		existing, err := existingNetworkPolicies(adv.Policies)
+		if err != nil {
+			return err
+		}
+		color := outputFileName == "-" && term.IsTerminal(int(os.Stdout.Fd()))
+		out = advisor.FormatDiffs(advisor.DiffPolicies(adv.Policies, existing), color)
+	}
+
 	w, closure, err := newWriter(outputFileName)
 	if err != nil {
 		return fmt.Errorf("creating file %q: %w", outputFileName, err)
 	}
 	defer closure()
 
-	_, err = w.Write([]byte(adv.FormatPolicies()))
+	_, err = w.Write([]byte(out))
 	if err != nil {
 		return fmt.Errorf("writing file %q: %w", outputFileName, err)
 	}
@@ -150,3 +167,28 @@ func runNetworkPolicyReport(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// existingNetworkPolicies returns the network policies of the namespaces of
+// the generated policies
+func existingNetworkPolicies(generated []networkingv1.NetworkPolicy) ([]networkingv1.NetworkPolicy, error) {
+	client, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
+	if err != nil {
+		return nil, commonutils.WrapInErrSetupK8sClient(err)
+	}
+
+	var existing []networkingv1.NetworkPolicy
+	listed := make(map[string]struct{})
+	for _, p := range generated {
+		if _, ok := listed[p.Namespace]; ok {
+			continue
+		}
+		listed[p.Namespace] = struct{}{}
+
+		list, err := client.NetworkingV1().NetworkPolicies(p.Namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("listing network policies in namespace %q: %w", p.Namespace, err)
+		}
+		existing = append(existing, list.Items...)
+	}
+	return existing, nil
+}
@@ -0,0 +1,366 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advise
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	commonseccomp "github.com/containers/common/pkg/seccomp"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	seccompprofile "sigs.k8s.io/security-profiles-operator/api/seccompprofile/v1beta1"
+	k8syaml "sigs.k8s.io/yaml"
+
+	capabilitiestypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/types"
+)
+
+// workload is the resource whose pods are advised on
+type workload struct {
+	// Kind is the kind of the resource, e.g. Deployment
+	Kind       string
+	APIVersion string
+	Name       string
+	Namespace  string
+
+	// Labels select the pods of the workload. Empty for pods.
+	Labels map[string]string
+
+	// Containers are the names of the containers of the pods
+	Containers []string
+}
+
+func (w *workload) String() string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(w.Kind), w.Name)
+}
+
+// containerAdvice is what the advisors found for a container
+type containerAdvice struct {
+	Name string
+
+	// Syscalls is nil when the seccomp advisor didn't trace the container
+	Syscalls []string
+
+	Capabilities       []string
+	DeniedCapabilities []string
+}
+
+// hardeningReport is the consolidated output of the advisors for a workload
+type hardeningReport struct {
+	Workload *workload
+	Duration string
+
+	// SeccompPod is the pod whose containers were traced by the seccomp
+	// advisor, as it doesn't support selecting pods by labels
+	SeccompPod string
+
+	Containers      []*containerAdvice
+	NetworkPolicies []networkingv1.NetworkPolicy
+
+	// Errors are the advisors that failed, the report is still generated
+	// with the others
+	Errors []string
+}
+
+func newHardeningReport(w *workload, duration string) *hardeningReport {
+	r := &hardeningReport{
+		Workload: w,
+		Duration: duration,
+	}
+	for _, name := range w.Containers {
+		r.Containers = append(r.Containers, &containerAdvice{Name: name})
+	}
+	return r
+}
+
+// capabilitiesRecorder collects the capabilities checked in each container
+type capabilitiesRecorder struct {
+	mu      sync.Mutex
+	allowed map[string]map[string]struct{}
+	denied  map[string]map[string]struct{}
+}
+
+func newCapabilitiesRecorder() *capabilitiesRecorder {
+	return &capabilitiesRecorder{
+		allowed: make(map[string]map[string]struct{}),
+		denied:  make(map[string]map[string]struct{}),
+	}
+}
+
+func addToSet(sets map[string]map[string]struct{}, key, value string) {
+	set, ok := sets[key]
+	if !ok {
+		set = make(map[string]struct{})
+		sets[key] = set
+	}
+	set[value] = struct{}{}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// addLine records the capability of an event printed by the capabilities
+// gadget
+func (c *capabilitiesRecorder) addLine(line string) error {
+	var event capabilitiestypes.Event
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return err
+	}
+	if event.CapName == "" || event.K8s.ContainerName == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if event.Verdict == "Deny" {
+		addToSet(c.denied, event.K8s.ContainerName, event.CapName)
+	} else {
+		addToSet(c.allowed, event.K8s.ContainerName, event.CapName)
+	}
+	return nil
+}
+
+func (c *capabilitiesRecorder) apply(r *hardeningReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, ca := range r.Containers {
+		ca.Capabilities = sortedKeys(c.allowed[ca.Name])
+		ca.DeniedCapabilities = sortedKeys(c.denied[ca.Name])
+	}
+}
+
+// syscallsFromLinuxSeccomp returns the syscalls allowed by the profile
+// generated by the seccomp advisor
+func syscallsFromLinuxSeccomp(output string) ([]string, error) {
+	var policy specs.LinuxSeccomp
+	if err := json.Unmarshal([]byte(output), &policy); err != nil {
+		return nil, err
+	}
+	syscalls := []string{}
+	for _, s := range policy.Syscalls {
+		if s.Action != specs.ActAllow {
+			continue
+		}
+		syscalls = append(syscalls, s.Names...)
+	}
+	slices.Sort(syscalls)
+	return slices.Compact(syscalls), nil
+}
+
+func (r *hardeningReport) seccompProfileName(container string) string {
+	return fmt.Sprintf("%s-%s", r.Workload.Name, container)
+}
+
+func (r *hardeningReport) seccompProfiles() []*seccompprofile.SeccompProfile {
+	var profiles []*seccompprofile.SeccompProfile
+	for _, c := range r.Containers {
+		if c.Syscalls == nil {
+			continue
+		}
+		profiles = append(profiles, &seccompprofile.SeccompProfile{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: seccompprofile.GroupVersion.String(),
+				Kind:       "SeccompProfile",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      r.seccompProfileName(c.Name),
+				Namespace: r.Workload.Namespace,
+			},
+			Spec: seccompprofile.SeccompProfileSpec{
+				DefaultAction: commonseccomp.ActErrno,
+				Syscalls: []*seccompprofile.Syscall{
+					{
+						Names:  c.Syscalls,
+						Action: commonseccomp.ActAllow,
+					},
+				},
+			},
+		})
+	}
+	return profiles
+}
+
+// securityContextPatch returns a strategic merge patch for the workload
+// dropping the capabilities that weren't used and using the seccomp profiles
+// installed by the Security Profiles Operator
+func (r *hardeningReport) securityContextPatch() map[string]any {
+	containers := []map[string]any{}
+	for _, c := range r.Containers {
+		sc := corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{
+				Drop: []corev1.Capability{"ALL"},
+			},
+		}
+		for _, capName := range c.Capabilities {
+			sc.Capabilities.Add = append(sc.Capabilities.Add, corev1.Capability(capName))
+		}
+		if c.Syscalls != nil {
+			profile := fmt.Sprintf("operator/%s/%s.json", r.Workload.Namespace, r.seccompProfileName(c.Name))
+			sc.SeccompProfile = &corev1.SeccompProfile{
+				Type:             corev1.SeccompProfileTypeLocalhost,
+				LocalhostProfile: &profile,
+			}
+		}
+		containers = append(containers, map[string]any{
+			"name":            c.Name,
+			"securityContext": sc,
+		})
+	}
+
+	podSpec := map[string]any{"containers": containers}
+	patch := map[string]any{
+		"apiVersion": r.Workload.APIVersion,
+		"kind":       r.Workload.Kind,
+		"metadata": map[string]any{
+			"name":      r.Workload.Name,
+			"namespace": r.Workload.Namespace,
+		},
+	}
+	if r.Workload.Kind == "Pod" {
+		patch["spec"] = podSpec
+	} else {
+		patch["spec"] = map[string]any{"template": map[string]any{"spec": podSpec}}
+	}
+	return patch
+}
+
+func marshalManifests[T any](objs []T) (string, error) {
+	var parts []string
+	for _, obj := range objs {
+		out, err := k8syaml.Marshal(obj)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(out))
+	}
+	return strings.Join(parts, "---\n"), nil
+}
+
+// manifests returns the YAML manifests of the report by file name
+func (r *hardeningReport) manifests() (map[string]string, error) {
+	ret := make(map[string]string)
+
+	profiles, err := marshalManifests(r.seccompProfiles())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling seccomp profiles: %w", err)
+	}
+	if profiles != "" {
+		ret["seccomp-profiles.yaml"] = profiles
+	}
+
+	policies, err := marshalManifests(r.NetworkPolicies)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling network policies: %w", err)
+	}
+	if policies != "" {
+		ret["network-policies.yaml"] = policies
+	}
+
+	patch, err := k8syaml.Marshal(r.securityContextPatch())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling security context patch: %w", err)
+	}
+	ret["security-context-patch.yaml"] = string(patch)
+
+	return ret, nil
+}
+
+var manifestFiles = []string{
+	"security-context-patch.yaml",
+	"seccomp-profiles.yaml",
+	"network-policies.yaml",
+}
+
+func orNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return "`" + strings.Join(values, "`, `") + "`"
+}
+
+// markdown returns the summary of the report. The manifests are embedded
+// when they aren't written to separate files.
+func (r *hardeningReport) markdown(manifests map[string]string, embed bool) string {
+	var sb strings.Builder
+	w := r.Workload
+
+	fmt.Fprintf(&sb, "# Hardening report for %s\n\n", w)
+	fmt.Fprintf(&sb, "- Namespace: `%s`\n", w.Namespace)
+	fmt.Fprintf(&sb, "- Observation window: %s\n", r.Duration)
+	if r.SeccompPod != "" {
+		fmt.Fprintf(&sb, "- Pod traced by the seccomp advisor: `%s`\n", r.SeccompPod)
+	}
+	sb.WriteString("\nThe recommendations only cover the behaviors observed during the window. Review\n")
+	sb.WriteString("them and exercise all the code paths of the workload before enforcing them.\n")
+
+	if len(r.Errors) > 0 {
+		sb.WriteString("\n## Errors\n\n")
+		for _, e := range r.Errors {
+			fmt.Fprintf(&sb, "- %s\n", e)
+		}
+	}
+
+	sb.WriteString("\n## Containers\n\n")
+	sb.WriteString("| Container | Syscalls | Capabilities used | Capabilities denied |\n")
+	sb.WriteString("|-----------|----------|-------------------|---------------------|\n")
+	for _, c := range r.Containers {
+		syscalls := "not traced"
+		if c.Syscalls != nil {
+			syscalls = fmt.Sprintf("%d", len(c.Syscalls))
+		}
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n", c.Name, syscalls,
+			orNone(c.Capabilities), orNone(c.DeniedCapabilities))
+	}
+	sb.WriteString("\nDenied capabilities were requested by the processes but not granted. They\n")
+	sb.WriteString("usually aren't needed, otherwise the workload would already be failing.\n")
+
+	sb.WriteString("\n## Network policies\n\n")
+	if len(r.NetworkPolicies) == 0 {
+		sb.WriteString("No network traffic was observed.\n")
+	} else {
+		for _, p := range r.NetworkPolicies {
+			fmt.Fprintf(&sb, "- `%s`: %d ingress and %d egress rules\n",
+				p.Name, len(p.Spec.Ingress), len(p.Spec.Egress))
+		}
+	}
+
+	sb.WriteString("\n## Manifests\n\n")
+	for _, name := range manifestFiles {
+		content, ok := manifests[name]
+		if !ok {
+			continue
+		}
+		if !embed {
+			fmt.Fprintf(&sb, "- [%s](%s)\n", name, name)
+			continue
+		}
+		fmt.Fprintf(&sb, "### %s\n\n```yaml\n%s```\n\n", name, content)
+	}
+
+	return strings.TrimRight(sb.String(), "\n") + "\n"
+}
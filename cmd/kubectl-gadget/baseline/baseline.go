@@ -0,0 +1,216 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package baseline implements the commands recording the behavior of
+// workloads (executed binaries, connections and opened files) and reporting
+// the behaviors that deviate from such a baseline.
+package baseline
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+type Kind string
+
+const (
+	KindExec    Kind = "exec"
+	KindConnect Kind = "connect"
+	KindOpen    Kind = "open"
+)
+
+// Behavior is something a workload was seen doing, like executing a binary
+type Behavior struct {
+	Workload string `json:"workload"`
+	Kind     Kind   `json:"kind"`
+	Value    string `json:"value"`
+}
+
+// Behaviors are the behaviors of a workload. Values can contain the wildcards
+// supported by path.Match, to allow editing a recorded baseline to cover
+// values that change between runs.
+type Behaviors struct {
+	Execs    []string `json:"execs,omitempty"`
+	Connects []string `json:"connects,omitempty"`
+	Opens    []string `json:"opens,omitempty"`
+}
+
+func (b *Behaviors) values(kind Kind) *[]string {
+	switch kind {
+	case KindExec:
+		return &b.Execs
+	case KindConnect:
+		return &b.Connects
+	case KindOpen:
+		return &b.Opens
+	}
+	return nil
+}
+
+// Baseline is the content of a baseline file
+type Baseline struct {
+	Recorded  time.Time             `json:"recorded"`
+	Duration  string                `json:"duration"`
+	Workloads map[string]*Behaviors `json:"workloads"`
+}
+
+// workloadKey identifies the workload a container belongs to. Containers of
+// pods created by a controller are identified by the controller, as the name
+// of their pods changes between runs.
+func workloadKey(namespace, ownerKind, ownerName, podName, containerName string) string {
+	if ownerKind == "" || ownerName == "" {
+		ownerKind = "Pod"
+		ownerName = podName
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", namespace, ownerKind, ownerName, containerName)
+}
+
+// normalizePath replaces the numeric components of a path, like pids in
+// /proc/$pid/status, with a wildcard
+func normalizePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		if part != "" && strings.Trim(part, "0123456789") == "" {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if p == value {
+			return true
+		}
+		if ok, _ := path.Match(p, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns whether the behavior is part of the baseline
+func (b *Baseline) Contains(be Behavior) bool {
+	behaviors, ok := b.Workloads[be.Workload]
+	if !ok {
+		return false
+	}
+	values := behaviors.values(be.Kind)
+	return values != nil && matchAny(*values, be.Value)
+}
+
+// recorder collects the distinct behaviors seen
+type recorder struct {
+	mu        sync.Mutex
+	workloads map[string]map[Kind]map[string]struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{workloads: make(map[string]map[Kind]map[string]struct{})}
+}
+
+// add records the behavior and returns whether it wasn't seen before
+func (r *recorder) add(be Behavior) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kinds, ok := r.workloads[be.Workload]
+	if !ok {
+		kinds = make(map[Kind]map[string]struct{})
+		r.workloads[be.Workload] = kinds
+	}
+	values, ok := kinds[be.Kind]
+	if !ok {
+		values = make(map[string]struct{})
+		kinds[be.Kind] = values
+	}
+	if _, ok := values[be.Value]; ok {
+		return false
+	}
+	values[be.Value] = struct{}{}
+	return true
+}
+
+// count returns the number of distinct behaviors recorded
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, kinds := range r.workloads {
+		for _, values := range kinds {
+			n += len(values)
+		}
+	}
+	return n
+}
+
+func (r *recorder) baseline(recorded time.Time, duration time.Duration) *Baseline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &Baseline{
+		Recorded:  recorded.UTC(),
+		Duration:  duration.String(),
+		Workloads: make(map[string]*Behaviors),
+	}
+	for workload, kinds := range r.workloads {
+		behaviors := &Behaviors{}
+		for kind, values := range kinds {
+			dst := behaviors.values(kind)
+			for value := range values {
+				*dst = append(*dst, value)
+			}
+			slices.Sort(*dst)
+		}
+		b.Workloads[workload] = behaviors
+	}
+	return b
+}
+
+func loadBaseline(filename string) (*Baseline, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	b := &Baseline{}
+	if err := yaml.UnmarshalStrict(content, b); err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", filename, err)
+	}
+	for workload, behaviors := range b.Workloads {
+		if behaviors == nil {
+			b.Workloads[workload] = &Behaviors{}
+		}
+	}
+	return b, nil
+}
+
+func writeBaseline(filename string, b *Baseline) error {
+	content, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if filename == "-" {
+		_, err = os.Stdout.Write(content)
+		return err
+	}
+	return os.WriteFile(filename, content, 0o644)
+}
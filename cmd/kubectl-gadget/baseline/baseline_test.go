@@ -0,0 +1,126 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkloadKey(t *testing.T) {
+	assert.Equal(t, "default/Deployment/nginx/app", workloadKey("default", "Deployment", "nginx", "nginx-7c5b8f6d4-x2k9p", "app"))
+	assert.Equal(t, "default/Pod/debug/shell", workloadKey("default", "", "", "debug", "shell"))
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"/etc/passwd":          "/etc/passwd",
+		"/proc/1234/status":    "/proc/*/status",
+		"/proc/self/fd/3":      "/proc/self/fd/*",
+		"/var/lib/app2/v1.db":  "/var/lib/app2/v1.db",
+		"relative/path/42":     "relative/path/*",
+		"/usr/lib/libssl.so.3": "/usr/lib/libssl.so.3",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, normalizePath(in), in)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := newRecorder()
+
+	const workload = "default/Deployment/nginx/app"
+	assert.True(t, r.add(Behavior{Workload: workload, Kind: KindExec, Value: "/usr/sbin/nginx"}))
+	assert.False(t, r.add(Behavior{Workload: workload, Kind: KindExec, Value: "/usr/sbin/nginx"}))
+	assert.True(t, r.add(Behavior{Workload: workload, Kind: KindExec, Value: "/bin/sh"}))
+	assert.True(t, r.add(Behavior{Workload: workload, Kind: KindOpen, Value: "/bin/sh"}))
+	assert.True(t, r.add(Behavior{Workload: workload, Kind: KindConnect, Value: "svc default/db:5432"}))
+	assert.Equal(t, 4, r.count())
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := r.baseline(start, time.Minute)
+	assert.Equal(t, &Baseline{
+		Recorded: start,
+		Duration: "1m0s",
+		Workloads: map[string]*Behaviors{
+			workload: {
+				Execs:    []string{"/bin/sh", "/usr/sbin/nginx"},
+				Connects: []string{"svc default/db:5432"},
+				Opens:    []string{"/bin/sh"},
+			},
+		},
+	}, b)
+}
+
+func TestContains(t *testing.T) {
+	b := &Baseline{
+		Workloads: map[string]*Behaviors{
+			"default/Deployment/nginx/app": {
+				Execs:    []string{"/usr/sbin/nginx"},
+				Connects: []string{"pod default/*:8080"},
+				Opens:    []string{"/etc/nginx/*", "/proc/*/status"},
+			},
+		},
+	}
+
+	tests := []struct {
+		behavior Behavior
+		expected bool
+	}{
+		{Behavior{"default/Deployment/nginx/app", KindExec, "/usr/sbin/nginx"}, true},
+		{Behavior{"default/Deployment/nginx/app", KindExec, "/bin/sh"}, false},
+		{Behavior{"default/Deployment/nginx/app", KindConnect, "pod default/*:8080"}, true},
+		{Behavior{"default/Deployment/nginx/app", KindConnect, "10.0.0.1:8080"}, false},
+		{Behavior{"default/Deployment/nginx/app", KindOpen, "/etc/nginx/nginx.conf"}, true},
+		{Behavior{"default/Deployment/nginx/app", KindOpen, "/etc/nginx/conf.d/default.conf"}, false},
+		{Behavior{"default/Deployment/nginx/app", KindOpen, "/proc/*/status"}, true},
+		{Behavior{"default/Deployment/other/app", KindExec, "/usr/sbin/nginx"}, false},
+		{Behavior{"default/Deployment/nginx/app", Kind("unknown"), "/usr/sbin/nginx"}, false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, b.Contains(test.behavior), "%+v", test.behavior)
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	dir := t.TempDir()
+
+	r := newRecorder()
+	r.add(Behavior{Workload: "default/Pod/debug/shell", Kind: KindExec, Value: "/bin/sh"})
+	expected := r.baseline(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), time.Minute)
+
+	filename := filepath.Join(dir, "baseline.yaml")
+	require.NoError(t, writeBaseline(filename, expected))
+	b, err := loadBaseline(filename)
+	require.NoError(t, err)
+	assert.Equal(t, expected, b)
+
+	// Workloads without behaviors are allowed
+	empty := filepath.Join(dir, "empty.yaml")
+	require.NoError(t, os.WriteFile(empty, []byte("workloads:\n  default/Pod/idle/app:\n"), 0o644))
+	b, err = loadBaseline(empty)
+	require.NoError(t, err)
+	assert.False(t, b.Contains(Behavior{Workload: "default/Pod/idle/app", Kind: KindExec, Value: "/bin/sh"}))
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("unknown: field\n"), 0o644))
+	_, err = loadBaseline(invalid)
+	require.Error(t, err)
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
+)
+
+const (
+	OutputModeText = "text"
+	OutputModeJSON = "json"
+)
+
+// selection are the flags selecting the containers of the workloads
+type selection struct {
+	allNamespaces bool
+	podName       string
+	selector      string
+	containerName string
+}
+
+func (s *selection) addFlags(cmd *cobra.Command) {
+	// No 'namespace' flag because it's added to the root command by
+	// KubernetesConfigFlags
+	cmd.Flags().BoolVarP(&s.allNamespaces, "all-namespaces", "A", false, "Use data from pods in all namespaces")
+	cmd.Flags().StringVarP(&s.podName, "podname", "p", "", "Use data from pods with that name")
+	cmd.Flags().StringVarP(&s.selector, "selector", "l", "", "Labels selector to filter on. Only '=' is supported (e.g. key1=value1,key2=value2).")
+	cmd.Flags().StringVarP(&s.containerName, "containername", "c", "", "Use data from containers with that name")
+}
+
+func (s *selection) paramValues() map[string]string {
+	params := map[string]string{
+		"operator.KubeManager.podname":       s.podName,
+		"operator.KubeManager.selector":      s.selector,
+		"operator.KubeManager.containername": s.containerName,
+	}
+	if s.allNamespaces {
+		params["operator.KubeManager.all-namespaces"] = "true"
+	} else {
+		params["operator.KubeManager.namespace"], _ = utils.GetNamespace()
+	}
+	return params
+}
+
+func NewBaselineCmd(rt runtime.Runtime) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Record the behavior of workloads and report deviations from it",
+	}
+	cmd.AddCommand(newRecordCmd(rt))
+	cmd.AddCommand(newCompareCmd(rt))
+	return cmd
+}
+
+func newRecordCmd(rt runtime.Runtime) *cobra.Command {
+	var sel selection
+	var duration time.Duration
+	var outputFile string
+
+	cmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record the executables, connections and opened files of workloads",
+		Long: `Record the executables, connections and opened files of workloads during the
+given duration and write them as a baseline to be used with "baseline compare".`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if duration <= 0 {
+				return commonutils.WrapInErrInvalidArg("--duration", fmt.Errorf("must be greater than 0"))
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			rec := newRecorder()
+			start := time.Now()
+			err := run(ctx, rt, sel.paramValues(), duration, func(be Behavior) {
+				rec.add(be)
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := writeBaseline(outputFile, rec.baseline(start, time.Since(start).Round(time.Second))); err != nil {
+				return fmt.Errorf("writing baseline: %w", err)
+			}
+			if outputFile != "-" {
+				fmt.Fprintf(os.Stderr, "%d behaviors written to %q\n", rec.count(), outputFile)
+			}
+			return nil
+		},
+	}
+
+	sel.addFlags(cmd)
+	cmd.Flags().DurationVarP(&duration, "duration", "d", 5*time.Minute, "Duration of the recording")
+	cmd.Flags().StringVarP(&outputFile, "file", "f", "-", "File where the baseline is written")
+	return cmd
+}
+
+func newCompareCmd(rt runtime.Runtime) *cobra.Command {
+	var sel selection
+	var duration time.Duration
+	var inputFile string
+	var outputMode string
+
+	cmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Report the behaviors of workloads that aren't in a baseline",
+		Long: `Trace the executables, connections and opened files of workloads and report the
+ones that aren't in the baseline recorded by "baseline record". Each new
+behavior is reported once.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if inputFile == "" {
+				return commonutils.WrapInErrMissingArgs("--file")
+			}
+			if outputMode != OutputModeText && outputMode != OutputModeJSON {
+				return commonutils.WrapInErrOutputModeNotSupported(outputMode)
+			}
+
+			b, err := loadBaseline(inputFile)
+			if err != nil {
+				return fmt.Errorf("loading baseline: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			if outputMode == OutputModeText {
+				fmt.Printf("%-48s %-8s %s\n", "WORKLOAD", "KIND", "VALUE")
+			}
+
+			// Only report each new behavior once
+			seen := newRecorder()
+			err = run(ctx, rt, sel.paramValues(), duration, func(be Behavior) {
+				if b.Contains(be) || !seen.add(be) {
+					return
+				}
+				switch outputMode {
+				case OutputModeJSON:
+					d, _ := json.Marshal(be)
+					fmt.Println(string(d))
+				default:
+					fmt.Printf("%-48s %-8s %s\n", be.Workload, be.Kind, be.Value)
+				}
+			})
+			if err != nil {
+				return err
+			}
+
+			if outputMode == OutputModeText {
+				fmt.Fprintf(os.Stderr, "%d new behaviors found\n", seen.count())
+			}
+			return nil
+		},
+	}
+
+	sel.addFlags(cmd)
+	cmd.Flags().DurationVarP(&duration, "duration", "d", 0, "Duration of the comparison. By default, it runs until interrupted")
+	cmd.Flags().StringVarP(&inputFile, "file", "f", "", "File with the baseline")
+	cmd.Flags().StringVarP(&outputMode, "output", "o", OutputModeText, fmt.Sprintf("Output mode: %q or %q", OutputModeText, OutputModeJSON))
+	return cmd
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/internal/version"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
+)
+
+// opPriority makes the handlers run after all the other operators
+const opPriority = 50000
+
+// extractor returns the function getting the value of a behavior from the
+// events of the data source, or nil if the data source isn't supported
+type extractor func(ds datasource.DataSource) func(data datasource.Data) (string, bool)
+
+// source is a gadget whose events are aggregated into behaviors of a kind
+type source struct {
+	kind    Kind
+	gadget  string
+	params  map[string]string
+	extract extractor
+}
+
+var sources = []source{
+	{
+		kind:   KindExec,
+		gadget: "trace_exec",
+		params: map[string]string{
+			"operator.oci.ebpf.paths": "true",
+		},
+		extract: execValue,
+	},
+	{
+		kind:    KindConnect,
+		gadget:  "trace_tcpconnect",
+		extract: connectValue,
+	},
+	{
+		kind:    KindOpen,
+		gadget:  "trace_open",
+		extract: openValue,
+	},
+}
+
+// gadgetImage returns the image of the gadget matching the version of the
+// client, or the latest one for development builds
+func gadgetImage(gadget string) string {
+	v := version.Version()
+	if v.Major == 0 && v.Minor == 0 && v.Patch == 0 {
+		return gadget
+	}
+	return fmt.Sprintf("%s:v%s", gadget, v.String())
+}
+
+func failed(f datasource.FieldAccessor, data datasource.Data) bool {
+	if f == nil {
+		return false
+	}
+	errno, _ := f.Uint32(data)
+	return errno != 0
+}
+
+// execValue uses the path of the executable, or the command name when the
+// path isn't available
+func execValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	exepath := ds.GetField("exepath")
+	comm := ds.GetField("proc.comm")
+	if exepath == nil && comm == nil {
+		return nil
+	}
+	errRaw := ds.GetField("error_raw")
+	return func(data datasource.Data) (string, bool) {
+		if failed(errRaw, data) {
+			return "", false
+		}
+		if exepath != nil {
+			if v, _ := exepath.String(data); v != "" {
+				return v, true
+			}
+		}
+		if comm != nil {
+			if v, _ := comm.String(data); v != "" {
+				return v, true
+			}
+		}
+		return "", false
+	}
+}
+
+// connectValue identifies the destination by the Kubernetes service when
+// known, by the namespace for pods, as their name and address change between
+// runs, and by the address otherwise
+func connectValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	addr := ds.GetField("dst.addr")
+	port := ds.GetField("dst.port")
+	if addr == nil || port == nil {
+		return nil
+	}
+	k8sKind := ds.GetField("dst.k8s.kind")
+	k8sName := ds.GetField("dst.k8s.name")
+	k8sNamespace := ds.GetField("dst.k8s.namespace")
+	errRaw := ds.GetField("error_raw")
+	return func(data datasource.Data) (string, bool) {
+		if failed(errRaw, data) {
+			return "", false
+		}
+		p, _ := port.Uint16(data)
+		ps := strconv.FormatUint(uint64(p), 10)
+
+		var kind, name, namespace string
+		if k8sKind != nil && k8sName != nil && k8sNamespace != nil {
+			kind, _ = k8sKind.String(data)
+			name, _ = k8sName.String(data)
+			namespace, _ = k8sNamespace.String(data)
+		}
+		switch kind {
+		case "svc":
+			return fmt.Sprintf("svc %s/%s:%s", namespace, name, ps), true
+		case "pod":
+			return fmt.Sprintf("pod %s/*:%s", namespace, ps), true
+		}
+		a, _ := addr.String(data)
+		if a == "" {
+			return "", false
+		}
+		return net.JoinHostPort(a, ps), true
+	}
+}
+
+func openValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	fname := ds.GetField("fname")
+	if fname == nil {
+		return nil
+	}
+	errRaw := ds.GetField("error_raw")
+	return func(data datasource.Data) (string, bool) {
+		if failed(errRaw, data) {
+			return "", false
+		}
+		v, _ := fname.String(data)
+		if v == "" {
+			return "", false
+		}
+		return normalizePath(v), true
+	}
+}
+
+type workloadFields struct {
+	namespace     datasource.FieldAccessor
+	ownerKind     datasource.FieldAccessor
+	ownerName     datasource.FieldAccessor
+	podName       datasource.FieldAccessor
+	containerName datasource.FieldAccessor
+}
+
+func getWorkloadFields(ds datasource.DataSource) *workloadFields {
+	wf := &workloadFields{
+		namespace:     ds.GetField("k8s.namespace"),
+		ownerKind:     ds.GetField("k8s.owner.kind"),
+		ownerName:     ds.GetField("k8s.owner.name"),
+		podName:       ds.GetField("k8s.podName"),
+		containerName: ds.GetField("k8s.containerName"),
+	}
+	if wf.namespace == nil || wf.ownerKind == nil || wf.ownerName == nil ||
+		wf.podName == nil || wf.containerName == nil {
+		return nil
+	}
+	return wf
+}
+
+// workload returns the key of the workload of the event, or an empty string
+// for events not coming from a pod
+func (wf *workloadFields) workload(data datasource.Data) string {
+	namespace, _ := wf.namespace.String(data)
+	podName, _ := wf.podName.String(data)
+	if namespace == "" || podName == "" {
+		return ""
+	}
+	ownerKind, _ := wf.ownerKind.String(data)
+	ownerName, _ := wf.ownerName.String(data)
+	containerName, _ := wf.containerName.String(data)
+	return workloadKey(namespace, ownerKind, ownerName, podName, containerName)
+}
+
+func (s *source) operator(handle func(Behavior)) operators.DataOperator {
+	return simple.New("baseline-"+string(s.kind), simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+		found := false
+		for _, ds := range gadgetCtx.GetDataSources() {
+			wf := getWorkloadFields(ds)
+			if wf == nil {
+				continue
+			}
+			value := s.extract(ds)
+			if value == nil {
+				continue
+			}
+			found = true
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				workload := wf.workload(data)
+				if workload == "" {
+					return nil
+				}
+				v, ok := value(data)
+				if !ok {
+					return nil
+				}
+				handle(Behavior{Workload: workload, Kind: s.kind, Value: v})
+				return nil
+			}, opPriority)
+		}
+		if !found {
+			return fmt.Errorf("gadget %q has no data source with the expected fields", s.gadget)
+		}
+		return nil
+	}))
+}
+
+// run runs the gadgets of all the sources until the context is done or the
+// timeout expires, calling handle for each behavior seen. handle can be called
+// concurrently.
+func run(
+	ctx context.Context,
+	rt runtime.Runtime,
+	paramValues map[string]string,
+	timeout time.Duration,
+	handle func(Behavior),
+) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(sources))
+
+	for i := range sources {
+		s := &sources[i]
+
+		params := make(map[string]string, len(paramValues)+len(s.params))
+		for k, v := range paramValues {
+			params[k] = v
+		}
+		for k, v := range s.params {
+			params[k] = v
+		}
+
+		gadgetCtx := gadgetcontext.New(
+			ctx,
+			gadgetImage(s.gadget),
+			gadgetcontext.WithDataOperators(s.operator(handle)),
+			gadgetcontext.WithTimeout(timeout),
+		)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := rt.RunGadget(gadgetCtx, rt.ParamDescs().ToParams(), params); err != nil {
+				errs[i] = fmt.Errorf("running %s: %w", s.gadget, err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,290 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	gadgetbuilder "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-builder"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
+	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
+)
+
+const buildPollInterval = 2 * time.Second
+
+type buildFile struct {
+	EBPFSource string `json:"ebpfsource"`
+	Wasm       string `json:"wasm"`
+	Metadata   string `json:"metadata"`
+	CFlags     string `json:"cflags"`
+}
+
+type buildOpts struct {
+	image        string
+	file         string
+	node         string
+	builderImage string
+	timeout      time.Duration
+}
+
+func NewBuildCmd() *cobra.Command {
+	opts := &buildOpts{}
+
+	cmd := &cobra.Command{
+		Use:   "build [PATH]",
+		Short: "Build a gadget in the cluster against the BTF of each node",
+		Long: `Build a gadget in the cluster against the BTF of each node
+
+The sources are compiled by a Job on each node and the resulting image is
+imported into the local store of the gadget pod of that node. It requires
+"builder.enabled" to be set in the configuration of Inspektor Gadget.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := "."
+			if len(args) == 1 {
+				path = args[0]
+			}
+			return runBuild(cmd, path, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.image, "tag", "t", "", "Name for the built image (format name:tag)")
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "build.yaml", "Path to build.yaml")
+	cmd.Flags().StringVar(&opts.node, "node", "", "Build only on this node")
+	cmd.Flags().StringVar(&opts.builderImage, "builder-image", gadgetbuilder.DefaultBuilderImage, "Builder image to use")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 5*time.Minute, "Maximum time to wait for the builds")
+	cmd.MarkFlagRequired("tag")
+
+	return cmd
+}
+
+// collectSources returns the build info and the files to send to the
+// builder: the eBPF source, the metadata file (if any) and all headers of
+// the directory.
+func collectSources(path, file string) (*gadgetbuilder.BuildInfo, map[string][]byte, error) {
+	conf := &buildFile{
+		EBPFSource: "program.bpf.c",
+		Metadata:   "gadget.yaml",
+	}
+
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(path, file)
+	}
+	buildContent, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("reading build file: %w", err)
+	}
+	if err := yaml.Unmarshal(buildContent, conf); err != nil {
+		return nil, nil, fmt.Errorf("unmarshal build.yaml: %w", err)
+	}
+	if conf.Wasm != "" {
+		return nil, nil, errors.New("gadgets with a wasm module can't be built in the cluster")
+	}
+
+	files := make(map[string][]byte)
+	addFile := func(name string) error {
+		if filepath.Base(name) != name {
+			return fmt.Errorf("%q must be in the gadget directory", name)
+		}
+		content, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return err
+		}
+		files[name] = content
+		return nil
+	}
+
+	if err := addFile(conf.EBPFSource); err != nil {
+		return nil, nil, fmt.Errorf("reading eBPF source: %w", err)
+	}
+	metadata := conf.Metadata
+	if err := addFile(conf.Metadata); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("reading metadata: %w", err)
+		}
+		metadata = ""
+	}
+
+	headers, err := filepath.Glob(filepath.Join(path, "*.h"))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, header := range headers {
+		if err := addFile(filepath.Base(header)); err != nil {
+			return nil, nil, fmt.Errorf("reading header: %w", err)
+		}
+	}
+
+	return &gadgetbuilder.BuildInfo{
+		EBPFSource: conf.EBPFSource,
+		Metadata:   metadata,
+		CFlags:     conf.CFlags,
+	}, files, nil
+}
+
+// getGadgetNodes returns the nodes running a gadget pod
+func getGadgetNodes(ctx context.Context, client kubernetes.Interface, gadgetNamespace string) ([]string, error) {
+	pods, err := client.CoreV1().Pods(gadgetNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "k8s-app=gadget",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing gadget pods: %w", err)
+	}
+
+	var nodes []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Spec.NodeName != "" {
+			nodes = append(nodes, pod.Spec.NodeName)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+func runBuild(cmd *cobra.Command, path string, opts *buildOpts) error {
+	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
+
+	info, files, err := collectSources(path, opts.file)
+	if err != nil {
+		return err
+	}
+	info.Image = opts.image
+
+	client, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
+	if err != nil {
+		return fmt.Errorf("creating RESTConfig: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+	defer cancel()
+
+	nodes, err := getGadgetNodes(ctx, client, gadgetNamespace)
+	if err != nil {
+		return err
+	}
+	if opts.node != "" {
+		if !slices.Contains(nodes, opts.node) {
+			return fmt.Errorf("no gadget pod running on node %q", opts.node)
+		}
+		nodes = []string{opts.node}
+	}
+	if len(nodes) == 0 {
+		return errors.New("no gadget pod running")
+	}
+
+	cm, err := gadgetbuilder.NewConfigMap("", gadgetNamespace, info, files)
+	if err != nil {
+		return err
+	}
+	cm.GenerateName = "gadget-build-"
+	cm, err = client.CoreV1().ConfigMaps(gadgetNamespace).Create(ctx, cm, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("creating build ConfigMap: %w", err)
+	}
+	defer func() {
+		// Jobs are deleted with the ConfigMap owning them
+		propagation := metav1.DeletePropagationBackground
+		err := client.CoreV1().ConfigMaps(gadgetNamespace).Delete(context.Background(), cm.Name,
+			metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: deleting build ConfigMap %s: %s\n", cm.Name, err)
+		}
+	}()
+
+	jobs := make(map[string]string, len(nodes))
+	for i, node := range nodes {
+		job := gadgetbuilder.NewJob(cm, info, fmt.Sprintf("%s-%d", cm.Name, i), node, opts.builderImage)
+		if _, err := client.BatchV1().Jobs(gadgetNamespace).Create(ctx, job, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating build job for node %q: %w", node, err)
+		}
+		jobs[node] = job.Name
+	}
+
+	fmt.Fprintf(os.Stderr, "Building %q on %d node(s)...\n", opts.image, len(nodes))
+
+	results, err := waitForResults(ctx, client, cm, nodes)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	failed := 0
+	for _, node := range nodes {
+		result, ok := results[node]
+		switch {
+		case !ok:
+			failed++
+			fmt.Printf("%s: no result after %s. Check \"kubectl logs -n %s job/%s\" and that %q is enabled in the gadget configuration\n",
+				node, opts.timeout, gadgetNamespace, jobs[node], "builder.enabled")
+		case result.Error != "":
+			failed++
+			fmt.Printf("%s: %s\n", node, result.Error)
+		default:
+			fmt.Printf("%s: built %s (%s)\n", node, opts.image, result.Digest)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("building gadget failed on %d node(s)", failed)
+	}
+	return nil
+}
+
+// waitForResults waits until all nodes recorded their result in cm
+func waitForResults(ctx context.Context, client kubernetes.Interface, cm *corev1.ConfigMap, nodes []string) (map[string]*gadgetbuilder.Result, error) {
+	ticker := time.NewTicker(buildPollInterval)
+	defer ticker.Stop()
+
+	results := map[string]*gadgetbuilder.Result{}
+	for {
+		select {
+		case <-ctx.Done():
+			return results, ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := client.CoreV1().ConfigMaps(cm.Namespace).Get(ctx, cm.Name, metav1.GetOptions{})
+		if err != nil {
+			if ctx.Err() != nil {
+				return results, ctx.Err()
+			}
+			return nil, fmt.Errorf("getting build ConfigMap: %w", err)
+		}
+		results = gadgetbuilder.GetResults(current)
+
+		missing := false
+		for _, node := range nodes {
+			if _, ok := results[node]; !ok {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			return results, nil
+		}
+	}
+}
@@ -15,16 +15,27 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/yaml"
+
+	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 )
 
 func getGadgetPodsDebug(client *kubernetes.Clientset, gadgetNamespace string) string {
@@ -114,3 +125,144 @@ func getEvents(client *kubernetes.Clientset, gadgetNamespace string) string {
 
 	return sb.String()
 }
+
+// nodeContainers are the containers known by the gadget pod of a node
+type nodeContainers struct {
+	Node       string                               `json:"node"`
+	Containers []*gadgettracermanager.ContainerDump `json:"containers"`
+}
+
+func NewDebugCmd(gadgetNamespace string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Show the internal state of Inspektor Gadget for troubleshooting",
+	}
+	cmd.AddCommand(newDebugContainersCmd(gadgetNamespace))
+	return cmd
+}
+
+func newDebugContainersCmd(gadgetNamespace string) *cobra.Command {
+	var node string
+	var outputMode string
+
+	cmd := &cobra.Command{
+		Use:   "containers",
+		Short: "List the containers known by the gadget pods, as used to enrich events",
+		Long: `List the containers known by the gadget pods, as used to enrich events.
+
+Events of containers that aren't listed, or listed with wrong namespaces or
+Kubernetes metadata, can't be enriched properly. The source column tells how
+each container was detected.`,
+		SilenceUsage: true,
+		Args:         cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			switch outputMode {
+			case commonutils.OutputModeColumns, commonutils.OutputModeJSON, commonutils.OutputModeYAML:
+			default:
+				return commonutils.WrapInErrOutputModeNotSupported(outputMode)
+			}
+
+			client, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
+			if err != nil {
+				return commonutils.WrapInErrSetupK8sClient(err)
+			}
+
+			nodes, err := gadgetNodes(client, gadgetNamespace, node)
+			if err != nil {
+				return err
+			}
+
+			var all []*nodeContainers
+			for _, n := range nodes {
+				var stdout, stderr bytes.Buffer
+				err := utils.ExecPod(client, n, gadgetNamespace,
+					"/bin/gadgettracermanager -dump containers", &stdout, &stderr)
+				if err != nil {
+					return fmt.Errorf("dumping containers on node %q: %w: %s", n, err, stderr.String())
+				}
+				containers, err := gadgettracermanager.ParseContainersDump(stdout.String())
+				if err != nil {
+					return fmt.Errorf("dumping containers on node %q: %w", n, err)
+				}
+				all = append(all, &nodeContainers{Node: n, Containers: containers})
+			}
+
+			switch outputMode {
+			case commonutils.OutputModeJSON:
+				b, err := json.MarshalIndent(all, "", "  ")
+				if err != nil {
+					return commonutils.WrapInErrMarshalOutput(err)
+				}
+				fmt.Println(string(b))
+			case commonutils.OutputModeYAML:
+				b, err := yaml.Marshal(all)
+				if err != nil {
+					return commonutils.WrapInErrMarshalOutput(err)
+				}
+				fmt.Print(string(b))
+			default:
+				printContainers(os.Stdout, all)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&node, "node", "", "Show only the containers of this node")
+	cmd.Flags().StringVarP(&outputMode, "output", "o", commonutils.OutputModeColumns,
+		fmt.Sprintf("Output mode: %q, %q or %q", commonutils.OutputModeColumns, commonutils.OutputModeJSON, commonutils.OutputModeYAML))
+	return cmd
+}
+
+// gadgetNodes returns the nodes running a gadget pod, or only node if set
+func gadgetNodes(client *kubernetes.Clientset, gadgetNamespace, node string) ([]string, error) {
+	if node != "" {
+		return []string{node}, nil
+	}
+
+	pods, err := client.CoreV1().Pods(gadgetNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: "k8s-app=gadget",
+		FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		return nil, commonutils.WrapInErrListPods(err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, commonutils.ErrGadgetPodNotFound
+	}
+
+	nodes := make([]string, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		nodes = append(nodes, pod.Spec.NodeName)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+func printContainers(out io.Writer, all []*nodeContainers) {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "NODE\tNAMESPACE\tPOD\tCONTAINER\tRUNTIME\tCONTAINERID\tPID\tMNTNS\tNETNS\tCGROUPID\tSOURCE")
+	for _, n := range all {
+		for _, c := range n.Containers {
+			name := c.K8sContainerName
+			if name == "" {
+				name = c.ContainerName
+			}
+			id := c.ContainerID
+			if len(id) > 13 {
+				id = id[:13]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+				n.Node, orDash(c.Namespace), orDash(c.PodName), orDash(name), orDash(c.RuntimeName),
+				orDash(id), c.Pid, c.Mntns, c.Netns, c.CgroupID, orDash(c.Source))
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -71,6 +71,12 @@ import (
 const (
 	gadgetPullSecret = "gadget-pull-secret"
 	configYamlKey    = "config.yaml"
+
+	// otelMetricsPort is the port of the default listen address of the
+	// otel-metrics operator
+	otelMetricsPort = 2224
+
+	renderKustomize = "kustomize"
 )
 
 var deployCmd = &cobra.Command{
@@ -111,10 +117,38 @@ var (
 	allowedGadgets      []string
 	insecureRegistries  []string
 	disallowGadgetsPull bool
+	hardened            bool
+	otelMetricsListen   bool
+	render              string
+	renderDir           string
 )
 
 var supportedHooks = []string{"auto", "crio", "podinformer", "nri", "fanotify", "fanotify+ebpf"}
 
+// hardenedCapabilities are the capabilities given to the gadget container when
+// deploying with --hardened. CAP_SYS_ADMIN is replaced by CAP_BPF and
+// CAP_PERFMON (Linux 5.8+), see "Hardened deployment" in
+// docs/reference/install-kubernetes.md for the trade-offs.
+var hardenedCapabilities = []v1.Capability{
+	// bpf(): loading programs and creating maps
+	"BPF",
+	// perf_event_open(), kprobes, tracepoints and reading kernel memory
+	// from eBPF programs
+	"PERFMON",
+	// Accessing /proc/$pid/ns/* and /proc/$pid/root of other processes
+	"SYS_PTRACE",
+	// Raising RLIMIT_MEMLOCK on kernels older than 5.11
+	"SYS_RESOURCE",
+	// Reading addresses from /proc/kallsyms
+	"SYSLOG",
+	// mmap() of locked memory
+	"IPC_LOCK",
+	// Raw sockets used by network gadgets
+	"NET_RAW",
+	// qdiscs and filters used by tc based gadgets
+	"NET_ADMIN",
+}
+
 var clusterImagePolicyKind = schema.GroupVersionKind{
 	Group:   "policy.sigstore.dev",
 	Version: "v1beta1",
@@ -258,6 +292,22 @@ func init() {
 	deployCmd.PersistentFlags().BoolVar(
 		&disallowGadgetsPull,
 		"disallow-gadgets-pulling", false, "Disallow pulling gadgets from registries")
+	deployCmd.PersistentFlags().BoolVar(
+		&hardened,
+		"hardened", false,
+		"Deploy using fine-grained capabilities instead of CAP_SYS_ADMIN and the runtime default seccomp profile. Some gadgets and hook modes are not available in this mode")
+	deployCmd.PersistentFlags().BoolVar(
+		&otelMetricsListen,
+		"otel-metrics-listen", false,
+		fmt.Sprintf("Enable the OpenTelemetry metrics listener of the gadget pods on port %d", otelMetricsPort))
+	deployCmd.PersistentFlags().StringVar(
+		&render,
+		"render", "",
+		fmt.Sprintf("Write the configuration to deploy to files instead of deploying it. Supported values are: %s", renderKustomize))
+	deployCmd.PersistentFlags().StringVar(
+		&renderDir,
+		"render-dir", "inspektor-gadget",
+		"Directory where the files are written when using --render")
 	rootCmd.AddCommand(deployCmd)
 }
 
@@ -446,6 +496,46 @@ func createAppArmorProfile(profile string) (*v1.AppArmorProfile, error) {
 	return ret, nil
 }
 
+// applyHardenedProfile replaces CAP_SYS_ADMIN by fine-grained capabilities and
+// sets the runtime default seccomp profile, unless a custom one was given.
+func applyHardenedProfile(daemonSet *appsv1.DaemonSet) {
+	podSpec := &daemonSet.Spec.Template.Spec
+	if podSpec.SecurityContext == nil {
+		podSpec.SecurityContext = &v1.PodSecurityContext{}
+	}
+	if podSpec.SecurityContext.SeccompProfile == nil {
+		podSpec.SecurityContext.SeccompProfile = &v1.SeccompProfile{
+			Type: v1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+
+	gadgetContainer := &podSpec.Containers[0]
+	if gadgetContainer.SecurityContext == nil {
+		gadgetContainer.SecurityContext = &v1.SecurityContext{}
+	}
+	privileged := false
+	allowPrivilegeEscalation := false
+	gadgetContainer.SecurityContext.Privileged = &privileged
+	gadgetContainer.SecurityContext.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+	gadgetContainer.SecurityContext.Capabilities = &v1.Capabilities{
+		Drop: []v1.Capability{"ALL"},
+		Add:  slices.Clone(hardenedCapabilities),
+	}
+}
+
+// applyHardenedNamespaceLabels sets the Pod Security Admission labels of the
+// gadget namespace. The gadget pod needs hostPath volumes, which are only
+// allowed by the privileged level, hence it's enforced explicitly so the
+// deployment isn't rejected by a stricter cluster-wide default. Violations of
+// the baseline level are still reported as warnings and audit annotations.
+func applyHardenedNamespaceLabels(ns *v1.Namespace) {
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels["pod-security.kubernetes.io/enforce"] = "privileged"
+	ns.Labels["pod-security.kubernetes.io/audit"] = "baseline"
+}
+
 // createAffinity returns the affinity to be used for the DaemonSet.
 func createAffinity(client *kubernetes.Clientset) (*v1.Affinity, error) {
 	nodes, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: nodeSelector})
@@ -471,61 +561,72 @@ func createAffinity(client *kubernetes.Clientset) (*v1.Affinity, error) {
 	return affinity, nil
 }
 
-func runDeploy(cmd *cobra.Command, args []string) error {
-	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
-	if !printOnly {
-		gadgetNamespaces, err := utils.GetRunningGadgetNamespaces()
-		if err != nil {
-			return fmt.Errorf("searching for running Inspektor Gadget instances: %w", err)
-		}
-		if len(gadgetNamespaces) != 0 && gadgetNamespaces[0] != gadgetNamespace {
-			// Inspektor Gadget is the program name and therefore capitalized (Lint error ST1005)
-			//nolint:all
-			return fmt.Errorf("Inspektor Gadget is already deployed to the following namespaces: %v. Only a single instance is allowed", gadgetNamespaces)
-		}
+// checkHardenedFlags checks the flags used with --hardened and switches the
+// hook mode to one that doesn't need CAP_SYS_ADMIN.
+func checkHardenedFlags() error {
+	switch hookMode {
+	case "fanotify", "fanotify+ebpf":
+		return fmt.Errorf("--hook-mode=%s requires CAP_SYS_ADMIN and can't be used with --hardened", hookMode)
+	case "auto":
+		// fanotify isn't available without CAP_SYS_ADMIN, don't try it
+		info("Using the podinformer hook mode in hardened mode\n")
+		hookMode = "podinformer"
 	}
-
-	found := false
-	for _, supportedHook := range supportedHooks {
-		if hookMode == supportedHook {
-			found = true
-			break
-		}
+	if legacyHostPID {
+		return fmt.Errorf("--legacy-host-pid can't be used with --hardened")
 	}
+	return nil
+}
 
-	if !found {
-		return fmt.Errorf("invalid argument %q for --hook-mode=[%s]", hookMode, strings.Join(supportedHooks, ","))
-	}
+// deployEnv is what was found on the cluster and is needed to render the
+// objects to deploy.
+type deployEnv struct {
+	namespace string
 
-	if quiet && debug {
-		return fmt.Errorf("it's not possible to use --quiet and --debug together")
-	}
+	// serverVersion is nil when the objects are only printed
+	serverVersion *k8sversion.Version
+
+	isPullSecretPresent       bool
+	isPolicyControllerPresent bool
 
+	// affinity is set when --node-selector is used
+	affinity *v1.Affinity
+}
+
+// renderObjects returns the objects to deploy, customized using the flags.
+func renderObjects(env *deployEnv) ([]runtime.Object, error) {
 	objects, err := parseK8sYaml(resources.GadgetDeployment)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	traceObjects, err := parseK8sYaml(resources.TracesCustomResource)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	objects = append(objects, traceObjects...)
 
+	traceScheduleObjects, err := parseK8sYaml(resources.TraceSchedulesCustomResource)
+	if err != nil {
+		return nil, err
+	}
+
+	objects = append(objects, traceScheduleObjects...)
+
 	if seccompProfile != "" {
 		content, err := os.ReadFile(seccompProfile)
 		if err != nil {
-			return fmt.Errorf("reading %s: %w", seccompProfile, err)
+			return nil, fmt.Errorf("reading %s: %w", seccompProfile, err)
 		}
 
 		seccompProfileObject, err := parseK8sYaml(string(content))
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if len(seccompProfileObject) > 1 {
-			return fmt.Errorf("created seccomp profile has several objects")
+			return nil, fmt.Errorf("created seccomp profile has several objects")
 		}
 
 		// We need to create the seccomp profile before the daemonset but after the
@@ -534,288 +635,386 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		objects[1] = seccompProfileObject[0]
 	}
 
-	config, err := utils.KubernetesConfigFlags.ToRESTConfig()
-	if err != nil {
-		return fmt.Errorf("creating RESTConfig: %w", err)
-	}
+	if env.isPolicyControllerPresent {
+		encodedKey := base64.StdEncoding.EncodeToString([]byte(publicKey))
 
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
-	if err != nil {
-		return err
-	}
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
-
-	dynamicClient, err := dynamic.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("creating dynamic client: %w", err)
-	}
-
-	k8sClient, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
-	if err != nil {
-		return commonutils.WrapInErrSetupK8sClient(err)
-	}
-
-	var isPullSecretPresent bool
-	if _, err = k8sClient.CoreV1().Secrets(gadgetNamespace).Get(context.TODO(), gadgetPullSecret, metav1.GetOptions{}); err == nil {
-		isPullSecretPresent = true
-	}
+		ref, err := reference.Parse(image)
+		if err != nil {
+			return nil, fmt.Errorf("parsing image name %q: %w", image, err)
+		}
 
-	var isPolicyControllerPresent bool
-	if verifyImage {
-		if _, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "cosign-system", metav1.GetOptions{}); err == nil {
-			isPolicyControllerPresent = true
+		// We cannot use tag as image for admission controller, as the tested image
+		// will use digest:
+		// Error: problem while creating resource: creating "DaemonSet": admission webhook "policy.sigstore.dev" denied the request: validation failed: no matching policies: spec.template.spec.containers[0].image
+		// ghcr.io/inspektor-gadget/inspektor-gadget@sha256:a6c2b00174013789d4af0cc48ba5e269426ff44f27dcb9b84f489537280e0871
+		// So, if users gave a digest, we use it directly.
+		// Otherwise, i.e. user gave a tag or the image itself, we extract the
+		// repository from it and add "**" to glob it.
+		admissionImage := ""
+		if digested, ok := ref.(reference.Digested); ok {
+			admissionImage = digested.String()
+		} else if named, ok := ref.(reference.Named); ok {
+			admissionImage = fmt.Sprintf("%s**", reference.TrimNamed(named).String())
 		} else {
-			log.Warnf("No policy controller found, the container image will not be verified")
+			return nil, fmt.Errorf("reference is neither reference.Digested nor reference.Named but %T", ref)
 		}
 
-		if isPolicyControllerPresent {
-			encodedKey := base64.StdEncoding.EncodeToString([]byte(publicKey))
-
-			ref, err := reference.Parse(image)
-			if err != nil {
-				return fmt.Errorf("parsing image name %q: %w", image, err)
-			}
-
-			// We cannot use tag as image for admission controller, as the tested image
-			// will use digest:
-			// Error: problem while creating resource: creating "DaemonSet": admission webhook "policy.sigstore.dev" denied the request: validation failed: no matching policies: spec.template.spec.containers[0].image
-			// ghcr.io/inspektor-gadget/inspektor-gadget@sha256:a6c2b00174013789d4af0cc48ba5e269426ff44f27dcb9b84f489537280e0871
-			// So, if users gave a digest, we use it directly.
-			// Otherwise, i.e. user gave a tag or the image itself, we extract the
-			// repository from it and add "**" to glob it.
-			admissionImage := ""
-			if digested, ok := ref.(reference.Digested); ok {
-				admissionImage = digested.String()
-			} else if named, ok := ref.(reference.Named); ok {
-				admissionImage = fmt.Sprintf("%s**", reference.TrimNamed(named).String())
-			} else {
-				return fmt.Errorf("reference is neither reference.Digested nor reference.Named but %T", ref)
-			}
+		admissionControllerYAML := fmt.Sprintf(admissionControllerFormat, env.namespace, admissionImage, encodedKey)
 
-			admissionControllerYAML := fmt.Sprintf(admissionControllerFormat, gadgetNamespace, admissionImage, encodedKey)
+		admissionControllerObject, err := parseK8sYaml(admissionControllerYAML)
+		if err != nil {
+			return nil, err
+		}
 
-			admissionControllerObject, err := parseK8sYaml(admissionControllerYAML)
-			if err != nil {
-				return err
-			}
+		objects = append(admissionControllerObject, objects...)
+	}
 
-			objects = append(admissionControllerObject, objects...)
+	for _, object := range objects {
+		if err := customizeObject(object, env); err != nil {
+			return nil, err
 		}
-	} else {
-		log.Warnf("You used --verify-image=false, the container image will not be verified")
 	}
 
-	for _, object := range objects {
-		var currentGadgetDS *appsv1.DaemonSet
+	return objects, nil
+}
 
-		daemonSet, handlingDaemonSet := object.(*appsv1.DaemonSet)
-		if handlingDaemonSet {
-			daemonSet.Spec.Template.Annotations["inspektor-gadget.kinvolk.io/option-hook-mode"] = hookMode
+// customizeObject applies the flags to an object to deploy.
+func customizeObject(object runtime.Object, env *deployEnv) error {
+	gadgetNamespace := env.namespace
 
-			daemonSet.Namespace = gadgetNamespace
+	if daemonSet, isDaemonSet := object.(*appsv1.DaemonSet); isDaemonSet {
+		daemonSet.Spec.Template.Annotations["inspektor-gadget.kinvolk.io/option-hook-mode"] = hookMode
 
-			// Inspektor Gadget used to require hostPID=true. This is no longer
-			// required, so keep hostPID=false unless the user explicitly
-			// requests it for compatibility with older clusters.
-			daemonSet.Spec.Template.Spec.HostPID = legacyHostPID
+		daemonSet.Namespace = gadgetNamespace
 
-			if seccompProfile != "" {
-				path := "operator/gadget/profile.json"
-				daemonSet.Spec.Template.Spec.SecurityContext = &v1.PodSecurityContext{
-					SeccompProfile: &v1.SeccompProfile{
-						Type:             v1.SeccompProfileTypeLocalhost,
-						LocalhostProfile: &path,
-					},
-				}
+		// Inspektor Gadget used to require hostPID=true. This is no longer
+		// required, so keep hostPID=false unless the user explicitly
+		// requests it for compatibility with older clusters.
+		daemonSet.Spec.Template.Spec.HostPID = legacyHostPID
+
+		if seccompProfile != "" {
+			path := "operator/gadget/profile.json"
+			daemonSet.Spec.Template.Spec.SecurityContext = &v1.PodSecurityContext{
+				SeccompProfile: &v1.SeccompProfile{
+					Type:             v1.SeccompProfileTypeLocalhost,
+					LocalhostProfile: &path,
+				},
 			}
+		}
 
-			if !printOnly {
-				serverInfo, err := discoveryClient.ServerVersion()
-				if err != nil {
-					return fmt.Errorf("getting server version: %w", err)
-				}
+		if serverVersion := env.serverVersion; serverVersion != nil {
+			// The "kubernetes.io/os" node label was introduced in v1.14.0
+			// (https://github.com/kubernetes/kubernetes/blob/master/CHANGELOG/CHANGELOG-1.14.md.)
+			// Remove this if the cluster is older than that to allow Inspektor Gadget to work there.
+			if serverVersion.LessThan(k8sversion.MustParseSemantic("v1.14.0")) {
+				delete(daemonSet.Spec.Template.Spec.NodeSelector, "kubernetes.io/os")
+			}
 
-				serverVersion := k8sversion.MustParseSemantic(serverInfo.String())
+			// Before 1.30, AppArmor profile was set as annotation, but since 1.30
+			// it has specific types:
+			// https://kubernetes.io/docs/tutorials/security/apparmor/#securing-a-pod
+			if serverVersion.AtLeast(k8sversion.MustParseSemantic("v1.30.0")) {
+				delete(daemonSet.Spec.Template.Annotations, "container.apparmor.security.beta.kubernetes.io/gadget")
 
-				// The "kubernetes.io/os" node label was introduced in v1.14.0
-				// (https://github.com/kubernetes/kubernetes/blob/master/CHANGELOG/CHANGELOG-1.14.md.)
-				// Remove this if the cluster is older than that to allow Inspektor Gadget to work there.
-				if serverVersion.LessThan(k8sversion.MustParseSemantic("v1.14.0")) {
-					delete(daemonSet.Spec.Template.Spec.NodeSelector, "kubernetes.io/os")
+				profile, err := createAppArmorProfile(appArmorprofile)
+				if err != nil {
+					return fmt.Errorf("creating AppArmor profile: %w", err)
 				}
 
-				// Before 1.30, AppArmor profile was set as annotation, but since 1.30
-				// it has specific types:
-				// https://kubernetes.io/docs/tutorials/security/apparmor/#securing-a-pod
-				if serverVersion.AtLeast(k8sversion.MustParseSemantic("v1.30.0")) {
-					delete(daemonSet.Spec.Template.Annotations, "container.apparmor.security.beta.kubernetes.io/gadget")
-
-					profile, err := createAppArmorProfile(appArmorprofile)
-					if err != nil {
-						return fmt.Errorf("creating AppArmor profile: %w", err)
-					}
-
-					if daemonSet.Spec.Template.Spec.SecurityContext == nil {
-						daemonSet.Spec.Template.Spec.SecurityContext = &v1.PodSecurityContext{}
-					}
-
-					daemonSet.Spec.Template.Spec.SecurityContext.AppArmorProfile = profile
-				} else {
-					daemonSet.Spec.Template.Annotations["container.apparmor.security.beta.kubernetes.io/gadget"] = appArmorprofile
+				if daemonSet.Spec.Template.Spec.SecurityContext == nil {
+					daemonSet.Spec.Template.Spec.SecurityContext = &v1.PodSecurityContext{}
 				}
+
+				daemonSet.Spec.Template.Spec.SecurityContext.AppArmorProfile = profile
+			} else {
+				daemonSet.Spec.Template.Annotations["container.apparmor.security.beta.kubernetes.io/gadget"] = appArmorprofile
 			}
+		}
 
-			gadgetContainer := &daemonSet.Spec.Template.Spec.Containers[0]
+		gadgetContainer := &daemonSet.Spec.Template.Spec.Containers[0]
 
-			gadgetContainer.Image = image
+		gadgetContainer.Image = image
 
-			policy, err := stringToPullPolicy(imagePullPolicy)
-			if err != nil {
-				return err
-			}
-			gadgetContainer.ImagePullPolicy = policy
+		policy, err := stringToPullPolicy(imagePullPolicy)
+		if err != nil {
+			return err
+		}
+		gadgetContainer.ImagePullPolicy = policy
 
-			if !livenessProbe {
-				gadgetContainer.LivenessProbe = nil
-			}
+		if !livenessProbe {
+			gadgetContainer.LivenessProbe = nil
+		}
 
-			for i := range gadgetContainer.Env {
-				switch gadgetContainer.Env[i].Name {
-				case "GADGET_IMAGE":
-					gadgetContainer.Env[i].Value = image
-				case "INSPEKTOR_GADGET_VERSION":
-					gadgetContainer.Env[i].Value = version.Version().String()
-				case "INSPEKTOR_GADGET_OPTION_HOOK_MODE":
-					gadgetContainer.Env[i].Value = hookMode
-				case "INSPEKTOR_GADGET_OPTION_FALLBACK_POD_INFORMER":
-					gadgetContainer.Env[i].Value = strconv.FormatBool(fallbackPodInformer)
-				case utils.GadgetEnvironmentContainerdSocketpath:
-					gadgetContainer.Env[i].Value = runtimesConfig.Containerd
-				case utils.GadgetEnvironmentCRIOSocketpath:
-					gadgetContainer.Env[i].Value = runtimesConfig.Crio
-				case utils.GadgetEnvironmentDockerSocketpath:
-					gadgetContainer.Env[i].Value = runtimesConfig.Docker
-				case utils.GadgetEnvironmentPodmanSocketpath:
-					gadgetContainer.Env[i].Value = runtimesConfig.Podman
-				case experimental.EnvName:
-					value := experimental.Enabled() || experimentalVar
-					gadgetContainer.Env[i].Value = strconv.FormatBool(value)
-				case "EVENTS_BUFFER_LENGTH":
-					gadgetContainer.Env[i].Value = strconv.FormatUint(eventBufferLength, 10)
-				case "GADGET_TRACER_MANAGER_LOG_LEVEL":
-					if !slices.Contains(strLevels, daemonLogLevel) {
-						return fmt.Errorf("invalid log level %q, valid levels are: %v", daemonLogLevel, strings.Join(strLevels, ", "))
-					}
-					gadgetContainer.Env[i].Value = daemonLogLevel
+		for i := range gadgetContainer.Env {
+			switch gadgetContainer.Env[i].Name {
+			case "GADGET_IMAGE":
+				gadgetContainer.Env[i].Value = image
+			case "INSPEKTOR_GADGET_VERSION":
+				gadgetContainer.Env[i].Value = version.Version().String()
+			case "INSPEKTOR_GADGET_OPTION_HOOK_MODE":
+				gadgetContainer.Env[i].Value = hookMode
+			case "INSPEKTOR_GADGET_OPTION_FALLBACK_POD_INFORMER":
+				gadgetContainer.Env[i].Value = strconv.FormatBool(fallbackPodInformer)
+			case utils.GadgetEnvironmentContainerdSocketpath:
+				gadgetContainer.Env[i].Value = runtimesConfig.Containerd
+			case utils.GadgetEnvironmentCRIOSocketpath:
+				gadgetContainer.Env[i].Value = runtimesConfig.Crio
+			case utils.GadgetEnvironmentDockerSocketpath:
+				gadgetContainer.Env[i].Value = runtimesConfig.Docker
+			case utils.GadgetEnvironmentPodmanSocketpath:
+				gadgetContainer.Env[i].Value = runtimesConfig.Podman
+			case experimental.EnvName:
+				value := experimental.Enabled() || experimentalVar
+				gadgetContainer.Env[i].Value = strconv.FormatBool(value)
+			case "EVENTS_BUFFER_LENGTH":
+				gadgetContainer.Env[i].Value = strconv.FormatUint(eventBufferLength, 10)
+			case "GADGET_TRACER_MANAGER_LOG_LEVEL":
+				if !slices.Contains(strLevels, daemonLogLevel) {
+					return fmt.Errorf("invalid log level %q, valid levels are: %v", daemonLogLevel, strings.Join(strLevels, ", "))
 				}
+				gadgetContainer.Env[i].Value = daemonLogLevel
 			}
+		}
 
-			if nodeSelector != "" {
-				affinity, err := createAffinity(k8sClient)
-				if err != nil {
-					return fmt.Errorf("creating affinity: %w", err)
-				}
-				daemonSet.Spec.Template.Spec.Affinity = affinity
-			}
+		if otelMetricsListen {
+			gadgetContainer.Ports = append(gadgetContainer.Ports, v1.ContainerPort{
+				Name:          "metrics",
+				ContainerPort: otelMetricsPort,
+				Protocol:      v1.ProtocolTCP,
+			})
+		}
 
-			// skip SELinux options if the user explicitly requests it
-			if legacyHostPID || skipSELinuxOpts {
-				gadgetContainer.SecurityContext.SELinuxOptions = nil
-			}
+		if env.affinity != nil {
+			daemonSet.Spec.Template.Spec.Affinity = env.affinity.DeepCopy()
+		}
 
-			// Get gadget daemon set (if any) to check if it was modified
-			currentGadgetDS, _ = k8sClient.AppsV1().DaemonSets(gadgetNamespace).Get(
-				context.TODO(), "gadget", metav1.GetOptions{},
-			)
+		// skip SELinux options if the user explicitly requests it
+		if legacyHostPID || skipSELinuxOpts {
+			gadgetContainer.SecurityContext.SELinuxOptions = nil
+		}
+
+		if hardened {
+			applyHardenedProfile(daemonSet)
+		}
 
-			// handle pull secret
-			if isPullSecretPresent {
-				daemonSet.Spec.Template.Spec.Volumes = append(daemonSet.Spec.Template.Spec.Volumes, v1.Volume{
-					Name: "pull-secret",
-					VolumeSource: v1.VolumeSource{
-						Secret: &v1.SecretVolumeSource{
-							SecretName: gadgetPullSecret,
-							Items: []v1.KeyToPath{
-								{
-									Key:  ".dockerconfigjson",
-									Path: "config.json",
-								},
+		// handle pull secret
+		if env.isPullSecretPresent {
+			daemonSet.Spec.Template.Spec.Volumes = append(daemonSet.Spec.Template.Spec.Volumes, v1.Volume{
+				Name: "pull-secret",
+				VolumeSource: v1.VolumeSource{
+					Secret: &v1.SecretVolumeSource{
+						SecretName: gadgetPullSecret,
+						Items: []v1.KeyToPath{
+							{
+								Key:  ".dockerconfigjson",
+								Path: "config.json",
 							},
 						},
 					},
-				})
-				gadgetContainer.VolumeMounts = append(gadgetContainer.VolumeMounts, v1.VolumeMount{
-					Name:      "pull-secret",
-					MountPath: "/var/run/secrets/gadget/pull-secret",
-					ReadOnly:  true,
-				})
-			}
+				},
+			})
+			gadgetContainer.VolumeMounts = append(gadgetContainer.VolumeMounts, v1.VolumeMount{
+				Name:      "pull-secret",
+				MountPath: "/var/run/secrets/gadget/pull-secret",
+				ReadOnly:  true,
+			})
 		}
+	}
 
-		if ns, isNs := object.(*v1.Namespace); isNs {
-			ns.Name = gadgetNamespace
+	if ns, isNs := object.(*v1.Namespace); isNs {
+		ns.Name = gadgetNamespace
 
-			if verifyImage && isPolicyControllerPresent {
-				if ns.Labels != nil {
-					ns.Labels["policy.sigstore.dev/include"] = "true"
-				} else {
-					ns.Labels = map[string]string{"policy.sigstore.dev/include": "true"}
-				}
+		if verifyImage && env.isPolicyControllerPresent {
+			if ns.Labels != nil {
+				ns.Labels["policy.sigstore.dev/include"] = "true"
+			} else {
+				ns.Labels = map[string]string{"policy.sigstore.dev/include": "true"}
 			}
 		}
-		if sa, isSa := object.(*v1.ServiceAccount); isSa {
-			sa.Namespace = gadgetNamespace
+
+		if hardened {
+			applyHardenedNamespaceLabels(ns)
 		}
-		if crBinding, isCrBinding := object.(*rbacv1.ClusterRoleBinding); isCrBinding {
-			if len(crBinding.Subjects) == 1 {
-				crBinding.Subjects[0].Namespace = gadgetNamespace
-			}
+	}
+	if sa, isSa := object.(*v1.ServiceAccount); isSa {
+		sa.Namespace = gadgetNamespace
+	}
+	if crBinding, isCrBinding := object.(*rbacv1.ClusterRoleBinding); isCrBinding {
+		if len(crBinding.Subjects) == 1 {
+			crBinding.Subjects[0].Namespace = gadgetNamespace
 		}
-		if role, isRole := object.(*rbacv1.Role); isRole {
-			role.Namespace = gadgetNamespace
+	}
+	if role, isRole := object.(*rbacv1.Role); isRole {
+		role.Namespace = gadgetNamespace
+	}
+	if rBinding, isRole := object.(*rbacv1.RoleBinding); isRole {
+		rBinding.Namespace = gadgetNamespace
+	}
+
+	if cm, isCm := object.(*v1.ConfigMap); isCm {
+		cm.Namespace = gadgetNamespace
+		cfgData, ok := cm.Data[configYamlKey]
+		if !ok {
+			return fmt.Errorf("%q not found in ConfigMap %q", configYamlKey, cm.Name)
 		}
-		if rBinding, isRole := object.(*rbacv1.RoleBinding); isRole {
-			rBinding.Namespace = gadgetNamespace
+		cfg := make(map[string]interface{}, len(cm.Data))
+		err := yaml.Unmarshal([]byte(cfgData), &cfg)
+		if err != nil {
+			return fmt.Errorf("unmarshaling config.yaml: %w", err)
 		}
 
-		if cm, isCm := object.(*v1.ConfigMap); isCm {
-			cm.Namespace = gadgetNamespace
-			cfgData, ok := cm.Data[configYamlKey]
-			if !ok {
-				return fmt.Errorf("%q not found in ConfigMap %q", configYamlKey, cm.Name)
-			}
-			cfg := make(map[string]interface{}, len(cm.Data))
-			err = yaml.Unmarshal([]byte(cfgData), &cfg)
-			if err != nil {
-				return fmt.Errorf("unmarshaling config.yaml: %w", err)
-			}
+		cfg[gadgettracermanagerconfig.HookModeKey] = hookMode
+		cfg[gadgettracermanagerconfig.FallbackPodInformerKey] = fallbackPodInformer
+		cfg[gadgettracermanagerconfig.EventsBufferLengthKey] = eventBufferLength
+		cfg[gadgettracermanagerconfig.ContainerdSocketPath] = runtimesConfig.Containerd
+		cfg[gadgettracermanagerconfig.CrioSocketPath] = runtimesConfig.Crio
+		cfg[gadgettracermanagerconfig.DockerSocketPath] = runtimesConfig.Docker
+		cfg[gadgettracermanagerconfig.PodmanSocketPath] = runtimesConfig.Podman
+
+		opCfg, ok := cfg[gadgettracermanagerconfig.Operator].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s not found in config.yaml", gadgettracermanagerconfig.Operator)
+		}
+		opOciCfg, ok := opCfg[gadgettracermanagerconfig.Oci].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s.%s not found in config.yaml", gadgettracermanagerconfig.Operator, gadgettracermanagerconfig.Oci)
+		}
 
-			cfg[gadgettracermanagerconfig.HookModeKey] = hookMode
-			cfg[gadgettracermanagerconfig.FallbackPodInformerKey] = fallbackPodInformer
-			cfg[gadgettracermanagerconfig.EventsBufferLengthKey] = eventBufferLength
-			cfg[gadgettracermanagerconfig.ContainerdSocketPath] = runtimesConfig.Containerd
-			cfg[gadgettracermanagerconfig.CrioSocketPath] = runtimesConfig.Crio
-			cfg[gadgettracermanagerconfig.DockerSocketPath] = runtimesConfig.Docker
-			cfg[gadgettracermanagerconfig.PodmanSocketPath] = runtimesConfig.Podman
-
-			opOciCfg, ok := cfg[gadgettracermanagerconfig.Operator].(map[string]interface{})[gadgettracermanagerconfig.Oci].(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("%s.%s not found in config.yaml", gadgettracermanagerconfig.Operator, gadgettracermanagerconfig.Oci)
+		opOciCfg[gadgettracermanagerconfig.VerifyImage] = verifyGadgets
+		opOciCfg[gadgettracermanagerconfig.PublicKeys] = strings.Split(gadgetsPublicKeys, ",")
+		opOciCfg[gadgettracermanagerconfig.AllowedGadgets] = allowedGadgets
+		opOciCfg[gadgettracermanagerconfig.InsecureRegistries] = insecureRegistries
+		opOciCfg[gadgettracermanagerconfig.DisallowPulling] = disallowGadgetsPull
+
+		if otelMetricsListen {
+			opOtelMetricsCfg, _ := opCfg[gadgettracermanagerconfig.OtelMetrics].(map[string]interface{})
+			if opOtelMetricsCfg == nil {
+				opOtelMetricsCfg = map[string]interface{}{}
+				opCfg[gadgettracermanagerconfig.OtelMetrics] = opOtelMetricsCfg
 			}
+			opOtelMetricsCfg[gadgettracermanagerconfig.OtelMetricsListen] = true
+		}
 
-			opOciCfg[gadgettracermanagerconfig.VerifyImage] = verifyGadgets
-			opOciCfg[gadgettracermanagerconfig.PublicKeys] = strings.Split(gadgetsPublicKeys, ",")
-			opOciCfg[gadgettracermanagerconfig.AllowedGadgets] = allowedGadgets
-			opOciCfg[gadgettracermanagerconfig.InsecureRegistries] = insecureRegistries
-			opOciCfg[gadgettracermanagerconfig.DisallowPulling] = disallowGadgetsPull
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf("marshaling config.yaml: %w", err)
+		}
+		cm.Data[configYamlKey] = string(data)
+	}
 
-			data, err := yaml.Marshal(cfg)
-			if err != nil {
-				return fmt.Errorf("marshaling config.yaml: %w", err)
-			}
-			cm.Data[configYamlKey] = string(data)
+	return nil
+}
+
+func runDeploy(cmd *cobra.Command, args []string) error {
+	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
+
+	switch render {
+	case "":
+	case renderKustomize:
+		// Nothing is deployed, only the files are written
+		printOnly = true
+	default:
+		return fmt.Errorf("invalid argument %q for --render=[%s]", render, renderKustomize)
+	}
+
+	if !printOnly {
+		gadgetNamespaces, err := utils.GetRunningGadgetNamespaces()
+		if err != nil {
+			return fmt.Errorf("searching for running Inspektor Gadget instances: %w", err)
+		}
+		if len(gadgetNamespaces) != 0 && gadgetNamespaces[0] != gadgetNamespace {
+			// Inspektor Gadget is the program name and therefore capitalized (Lint error ST1005)
+			//nolint:all
+			return fmt.Errorf("Inspektor Gadget is already deployed to the following namespaces: %v. Only a single instance is allowed", gadgetNamespaces)
+		}
+	}
+
+	found := false
+	for _, supportedHook := range supportedHooks {
+		if hookMode == supportedHook {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("invalid argument %q for --hook-mode=[%s]", hookMode, strings.Join(supportedHooks, ","))
+	}
+
+	// With --render, --hardened is only used by its overlay
+	if hardened && render == "" {
+		if err := checkHardenedFlags(); err != nil {
+			return err
+		}
+	}
+
+	if quiet && debug {
+		return fmt.Errorf("it's not possible to use --quiet and --debug together")
+	}
+
+	config, err := utils.KubernetesConfigFlags.ToRESTConfig()
+	if err != nil {
+		return fmt.Errorf("creating RESTConfig: %w", err)
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("creating dynamic client: %w", err)
+	}
+
+	k8sClient, err := k8sutil.NewClientsetFromConfigFlags(utils.KubernetesConfigFlags)
+	if err != nil {
+		return commonutils.WrapInErrSetupK8sClient(err)
+	}
+
+	env := &deployEnv{namespace: gadgetNamespace}
+
+	if _, err = k8sClient.CoreV1().Secrets(gadgetNamespace).Get(context.TODO(), gadgetPullSecret, metav1.GetOptions{}); err == nil {
+		env.isPullSecretPresent = true
+	}
+
+	if verifyImage {
+		if _, err = k8sClient.CoreV1().Namespaces().Get(context.TODO(), "cosign-system", metav1.GetOptions{}); err == nil {
+			env.isPolicyControllerPresent = true
+		} else {
+			log.Warnf("No policy controller found, the container image will not be verified")
 		}
+	} else {
+		log.Warnf("You used --verify-image=false, the container image will not be verified")
+	}
+
+	if !printOnly {
+		serverInfo, err := discoveryClient.ServerVersion()
+		if err != nil {
+			return fmt.Errorf("getting server version: %w", err)
+		}
+
+		env.serverVersion = k8sversion.MustParseSemantic(serverInfo.String())
+	}
+
+	if nodeSelector != "" {
+		env.affinity, err = createAffinity(k8sClient)
+		if err != nil {
+			return fmt.Errorf("creating affinity: %w", err)
+		}
+	}
 
+	if render == renderKustomize {
+		return renderKustomizeFiles(renderDir, env)
+	}
+
+	objects, err := renderObjects(env)
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
 		if printOnly {
 			bytes, err := yaml.Marshal(object)
 			if err != nil {
@@ -825,6 +1024,16 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
+		var currentGadgetDS *appsv1.DaemonSet
+
+		_, handlingDaemonSet := object.(*appsv1.DaemonSet)
+		if handlingDaemonSet {
+			// Get gadget daemon set (if any) to check if it was modified
+			currentGadgetDS, _ = k8sClient.AppsV1().DaemonSets(gadgetNamespace).Get(
+				context.TODO(), "gadget", metav1.GetOptions{},
+			)
+		}
+
 		obj, err := createOrUpdateResource(dynamicClient, mapper, object)
 		if err != nil {
 			return fmt.Errorf("problem while creating resource: %w", err)
@@ -0,0 +1,297 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+
+	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+)
+
+const (
+	kustomizeBaseDir      = "base"
+	kustomizeOverlaysDir  = "overlays"
+	kustomizationFile     = "kustomization.yaml"
+	kustomizeResourceFile = "inspektor-gadget.yaml"
+)
+
+type kustomization struct {
+	APIVersion string                  `json:"apiVersion"`
+	Kind       string                  `json:"kind"`
+	Resources  []string                `json:"resources,omitempty"`
+	Patches    []kustomizationPatchRef `json:"patches,omitempty"`
+}
+
+type kustomizationPatchRef struct {
+	Path string `json:"path"`
+}
+
+// renderFlags are the flags changed by the overlays. They keep their default
+// value in the base.
+type renderFlags struct {
+	hardened          bool
+	hookMode          string
+	otelMetricsListen bool
+	runtimesConfig    commonutils.RuntimesSocketPathConfig
+}
+
+func getRenderFlags() renderFlags {
+	return renderFlags{
+		hardened:          hardened,
+		hookMode:          hookMode,
+		otelMetricsListen: otelMetricsListen,
+		runtimesConfig:    runtimesConfig,
+	}
+}
+
+func (f renderFlags) set() {
+	hardened = f.hardened
+	hookMode = f.hookMode
+	otelMetricsListen = f.otelMetricsListen
+	runtimesConfig = f.runtimesConfig
+}
+
+var defaultRuntimesConfig = commonutils.RuntimesSocketPathConfig{
+	Docker:     runtimeclient.DockerDefaultSocketPath,
+	Containerd: runtimeclient.ContainerdDefaultSocketPath,
+	Crio:       runtimeclient.CrioDefaultSocketPath,
+	Podman:     runtimeclient.PodmanDefaultSocketPath,
+}
+
+// kustomizeOverlay is a variant of the deployment written as patches of the
+// base.
+type kustomizeOverlay struct {
+	name string
+	// apply changes the flags of the base to the ones of the overlay, user
+	// are the flags given by the user. It returns false if the overlay
+	// doesn't apply.
+	apply func(user renderFlags) (bool, error)
+}
+
+var kustomizeOverlays = []kustomizeOverlay{
+	{
+		name: "hardened",
+		apply: func(renderFlags) (bool, error) {
+			hardened = true
+			return true, checkHardenedFlags()
+		},
+	},
+	{
+		name: "metrics-enabled",
+		apply: func(renderFlags) (bool, error) {
+			otelMetricsListen = true
+			return true, nil
+		},
+	},
+	{
+		name: "custom-runtime-sockets",
+		apply: func(user renderFlags) (bool, error) {
+			if user.runtimesConfig == defaultRuntimesConfig {
+				info("Skipping the custom-runtime-sockets overlay: use the --*-socketpath flags to set the paths of the sockets\n")
+				return false, nil
+			}
+			runtimesConfig = user.runtimesConfig
+			return true, nil
+		},
+	},
+}
+
+// renderKustomizeFiles writes a kustomize base with the objects to deploy and
+// an overlay for each kustomizeOverlays.
+func renderKustomizeFiles(dir string, env *deployEnv) error {
+	user := getRenderFlags()
+	defer user.set()
+
+	base := user
+	base.hardened = false
+	base.otelMetricsListen = false
+	base.runtimesConfig = defaultRuntimesConfig
+	base.set()
+
+	baseObjects, err := renderObjects(env)
+	if err != nil {
+		return fmt.Errorf("rendering base: %w", err)
+	}
+
+	var resources bytes.Buffer
+	for _, object := range baseObjects {
+		b, err := yaml.Marshal(object)
+		if err != nil {
+			return fmt.Errorf("marshaling object: %w", err)
+		}
+		fmt.Fprintf(&resources, "---\n%s", b)
+	}
+
+	baseDir := filepath.Join(dir, kustomizeBaseDir)
+	err = writeKustomization(baseDir, kustomization{Resources: []string{kustomizeResourceFile}},
+		map[string][]byte{kustomizeResourceFile: resources.Bytes()})
+	if err != nil {
+		return err
+	}
+
+	for _, overlay := range kustomizeOverlays {
+		base.set()
+		ok, err := overlay.apply(user)
+		if err != nil {
+			return fmt.Errorf("overlay %s: %w", overlay.name, err)
+		}
+		if !ok {
+			continue
+		}
+
+		objects, err := renderObjects(env)
+		if err != nil {
+			return fmt.Errorf("rendering overlay %s: %w", overlay.name, err)
+		}
+		if len(objects) != len(baseObjects) {
+			return fmt.Errorf("overlay %s: expected %d objects, got %d", overlay.name, len(baseObjects), len(objects))
+		}
+
+		k := kustomization{
+			Resources: []string{"../../" + kustomizeBaseDir},
+		}
+		files := make(map[string][]byte)
+		for i := range objects {
+			patch, err := kustomizePatch(baseObjects[i], objects[i])
+			if err != nil {
+				return fmt.Errorf("overlay %s: %w", overlay.name, err)
+			}
+			if patch == nil {
+				continue
+			}
+
+			b, err := yaml.Marshal(patch)
+			if err != nil {
+				return fmt.Errorf("marshaling patch: %w", err)
+			}
+			name := kustomizePatchFileName(patch)
+			files[name] = b
+			k.Patches = append(k.Patches, kustomizationPatchRef{Path: name})
+		}
+
+		err = writeKustomization(filepath.Join(dir, kustomizeOverlaysDir, overlay.name), k, files)
+		if err != nil {
+			return err
+		}
+	}
+
+	info("Kustomize base and overlays written to %s\n", dir)
+	return nil
+}
+
+// kustomizePatch returns the strategic merge patch changing base to overlay
+// or nil if they are equal
+func kustomizePatch(base, overlay runtime.Object) (map[string]any, error) {
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayJSON, err := json.Marshal(overlay)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(baseJSON, overlayJSON) {
+		return nil, nil
+	}
+
+	gvk := base.GetObjectKind().GroupVersionKind()
+	accessor, err := meta.Accessor(base)
+	if err != nil {
+		return nil, err
+	}
+
+	patchJSON, err := strategicpatch.CreateTwoWayMergePatch(baseJSON, overlayJSON, base)
+	if err != nil {
+		return nil, fmt.Errorf("creating patch for %s %q: %w", gvk.Kind, accessor.GetName(), err)
+	}
+
+	patch := make(map[string]any)
+	if err := json.Unmarshal(patchJSON, &patch); err != nil {
+		return nil, err
+	}
+	removeSetElementOrder(patch)
+
+	// kustomize finds the object to patch using its kind, name and namespace
+	metadata, _ := patch["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = make(map[string]any)
+		patch["metadata"] = metadata
+	}
+	metadata["name"] = accessor.GetName()
+	if ns := accessor.GetNamespace(); ns != "" {
+		metadata["namespace"] = ns
+	}
+	patch["apiVersion"] = gvk.GroupVersion().String()
+	patch["kind"] = gvk.Kind
+
+	return patch, nil
+}
+
+// removeSetElementOrder removes the $setElementOrder directives, they only
+// keep the order of the lists and make the patches harder to read
+func removeSetElementOrder(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if strings.HasPrefix(k, "$setElementOrder/") {
+				delete(v, k)
+				continue
+			}
+			removeSetElementOrder(e)
+		}
+	case []any:
+		for _, e := range v {
+			removeSetElementOrder(e)
+		}
+	}
+}
+
+func kustomizePatchFileName(patch map[string]any) string {
+	kind, _ := patch["kind"].(string)
+	name, _ := patch["metadata"].(map[string]any)["name"].(string)
+	return fmt.Sprintf("%s-%s.yaml", strings.ToLower(kind), name)
+}
+
+func writeKustomization(dir string, k kustomization, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating directory: %w", err)
+	}
+
+	k.APIVersion = "kustomize.config.k8s.io/v1beta1"
+	k.Kind = "Kustomization"
+	b, err := yaml.Marshal(k)
+	if err != nil {
+		return fmt.Errorf("marshaling kustomization: %w", err)
+	}
+	files[kustomizationFile] = b
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -16,8 +16,17 @@ package main
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
 	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
 )
@@ -37,3 +46,145 @@ func TestPrintOnly(t *testing.T) {
 		t.Fatalf("Error while running command: %s", stdErr.String())
 	}
 }
+
+func TestApplyHardenedProfile(t *testing.T) {
+	privileged := true
+	daemonSet := &appsv1.DaemonSet{
+		Spec: appsv1.DaemonSetSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						SecurityContext: &v1.SecurityContext{
+							Privileged: &privileged,
+							Capabilities: &v1.Capabilities{
+								Add: []v1.Capability{"SYS_ADMIN"},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	applyHardenedProfile(daemonSet)
+
+	podSpec := daemonSet.Spec.Template.Spec
+	require.NotNil(t, podSpec.SecurityContext.SeccompProfile)
+	require.Equal(t, v1.SeccompProfileTypeRuntimeDefault, podSpec.SecurityContext.SeccompProfile.Type)
+
+	secCtx := podSpec.Containers[0].SecurityContext
+	require.False(t, *secCtx.Privileged)
+	require.False(t, *secCtx.AllowPrivilegeEscalation)
+	require.Equal(t, []v1.Capability{"ALL"}, secCtx.Capabilities.Drop)
+	require.NotContains(t, secCtx.Capabilities.Add, v1.Capability("SYS_ADMIN"))
+	require.Contains(t, secCtx.Capabilities.Add, v1.Capability("BPF"))
+	require.Contains(t, secCtx.Capabilities.Add, v1.Capability("PERFMON"))
+
+	// A custom seccomp profile is kept
+	localhostProfile := "operator/gadget/profile.json"
+	podSpec.SecurityContext.SeccompProfile = &v1.SeccompProfile{
+		Type:             v1.SeccompProfileTypeLocalhost,
+		LocalhostProfile: &localhostProfile,
+	}
+	daemonSet.Spec.Template.Spec = podSpec
+	applyHardenedProfile(daemonSet)
+	require.Equal(t, v1.SeccompProfileTypeLocalhost, daemonSet.Spec.Template.Spec.SecurityContext.SeccompProfile.Type)
+}
+
+func buildKustomization(t *testing.T, dir string) resmap.ResMap {
+	t.Helper()
+
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	require.NoError(t, err)
+	return resources
+}
+
+func kustomizedDaemonSet(t *testing.T, resources resmap.ResMap) *appsv1.DaemonSet {
+	t.Helper()
+
+	for _, res := range resources.Resources() {
+		if res.GetKind() != "DaemonSet" {
+			continue
+		}
+		b, err := res.AsYAML()
+		require.NoError(t, err)
+		objects, err := parseK8sYaml(string(b))
+		require.NoError(t, err)
+		return objects[0].(*appsv1.DaemonSet)
+	}
+	t.Fatal("DaemonSet not found")
+	return nil
+}
+
+func kustomizedConfig(t *testing.T, resources resmap.ResMap) string {
+	t.Helper()
+
+	for _, res := range resources.Resources() {
+		if res.GetKind() == "ConfigMap" {
+			data := res.GetDataMap()
+			return data[configYamlKey]
+		}
+	}
+	t.Fatal("ConfigMap not found")
+	return ""
+}
+
+func TestRenderKustomize(t *testing.T) {
+	flags := getRenderFlags()
+	t.Cleanup(flags.set)
+
+	dir := t.TempDir()
+	runtimesConfig.Containerd = "/run/k3s/containerd/containerd.sock"
+	hardened = true
+
+	err := renderKustomizeFiles(dir, &deployEnv{namespace: "gadget"})
+	require.NoError(t, err)
+
+	// The flags given by the user are restored
+	require.True(t, hardened)
+	require.Equal(t, "auto", hookMode)
+
+	base := buildKustomization(t, filepath.Join(dir, kustomizeBaseDir))
+	baseDS := kustomizedDaemonSet(t, base)
+	require.Contains(t, baseDS.Spec.Template.Spec.Containers[0].SecurityContext.Capabilities.Add, v1.Capability("SYS_ADMIN"))
+	require.Contains(t, kustomizedConfig(t, base), "containerd-socketpath: /run/containerd/containerd.sock")
+
+	hardenedResources := buildKustomization(t, filepath.Join(dir, kustomizeOverlaysDir, "hardened"))
+	hardenedDS := kustomizedDaemonSet(t, hardenedResources)
+	secCtx := hardenedDS.Spec.Template.Spec.Containers[0].SecurityContext
+	require.False(t, *secCtx.Privileged)
+	require.Equal(t, []v1.Capability{"ALL"}, secCtx.Capabilities.Drop)
+	require.Equal(t, hardenedCapabilities, secCtx.Capabilities.Add)
+	require.Equal(t, "podinformer", hardenedDS.Spec.Template.Annotations["inspektor-gadget.kinvolk.io/option-hook-mode"])
+	require.Contains(t, kustomizedConfig(t, hardenedResources), "hook-mode: podinformer")
+	// The other settings of the container are kept
+	require.Len(t, hardenedDS.Spec.Template.Spec.Containers[0].Env, len(baseDS.Spec.Template.Spec.Containers[0].Env))
+	require.Equal(t, baseDS.Spec.Template.Spec.Containers[0].VolumeMounts, hardenedDS.Spec.Template.Spec.Containers[0].VolumeMounts)
+
+	metricsResources := buildKustomization(t, filepath.Join(dir, kustomizeOverlaysDir, "metrics-enabled"))
+	metricsDS := kustomizedDaemonSet(t, metricsResources)
+	require.Contains(t, metricsDS.Spec.Template.Spec.Containers[0].Ports, v1.ContainerPort{
+		Name:          "metrics",
+		ContainerPort: otelMetricsPort,
+		Protocol:      v1.ProtocolTCP,
+	})
+	require.Contains(t, kustomizedConfig(t, metricsResources), "otel-metrics-listen: true")
+
+	socketsResources := buildKustomization(t, filepath.Join(dir, kustomizeOverlaysDir, "custom-runtime-sockets"))
+	require.Contains(t, kustomizedConfig(t, socketsResources), "containerd-socketpath: /run/k3s/containerd/containerd.sock")
+}
+
+func TestRenderKustomizeDefaultSockets(t *testing.T) {
+	flags := getRenderFlags()
+	t.Cleanup(flags.set)
+
+	dir := t.TempDir()
+	runtimesConfig = defaultRuntimesConfig
+
+	err := renderKustomizeFiles(dir, &deployEnv{namespace: "gadget"})
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, kustomizeOverlaysDir, "custom-runtime-sockets"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
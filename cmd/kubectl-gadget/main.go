@@ -28,8 +28,11 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/common"
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/advise"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/baseline"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/startup"
 	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
 	igconfig "github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/errcodes"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
@@ -53,6 +56,24 @@ func init() {
 	utils.FlagInit(rootCmd)
 }
 
+// addCategoryCommand adds cmd to the command of a gadget category, creating it
+// if no gadget of that category was added from the registry
+func addCategoryCommand(rootCmd *cobra.Command, category string, cmd *cobra.Command) {
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == category {
+			c.AddCommand(cmd)
+			return
+		}
+	}
+
+	categoryCmd := &cobra.Command{
+		Use:   category,
+		Short: gadgets.GetCategories()[category],
+	}
+	categoryCmd.AddCommand(cmd)
+	rootCmd.AddCommand(categoryCmd)
+}
+
 func main() {
 	if experimental.Enabled() {
 		log.Info("Experimental features enabled")
@@ -73,7 +94,7 @@ func main() {
 	// Need to loop through all arguments to skip flags...
 	for _, arg := range os.Args[1:] {
 		switch arg {
-		case "completion", "deploy", "version":
+		case "completion", "deploy", "schema", "version":
 			needGadgetNamespace = false
 		case "--help", "-h", "help":
 			isHelp = true
@@ -163,12 +184,21 @@ func main() {
 	// Advise and traceloop category is still being handled by CRs for now
 	rootCmd.AddCommand(advise.NewAdviseCmd(gadgetNamespace))
 	rootCmd.AddCommand(NewTraceloopCmd(gadgetNamespace))
+	rootCmd.AddCommand(NewTracesCmd(gadgetNamespace))
+	rootCmd.AddCommand(NewDebugCmd(gadgetNamespace))
+	rootCmd.AddCommand(NewBuildCmd())
+	rootCmd.AddCommand(baseline.NewBaselineCmd(grpcRuntime))
+	addCategoryCommand(rootCmd, gadgets.CategoryProfile, startup.NewStartupCmd(grpcRuntime))
 	rootCmd.AddCommand(common.NewSyncCommand(grpcRuntime))
 	rootCmd.AddCommand(common.NewRunCommand(rootCmd, grpcRuntime, hiddenColumnTags, common.CommandModeRun))
 	rootCmd.AddCommand(common.NewRunCommand(rootCmd, grpcRuntime, hiddenColumnTags, common.CommandModeAttach))
 	rootCmd.AddCommand(common.NewConfigCmd(grpcRuntime, rootFlags))
+	rootCmd.AddCommand(common.NewSchemaCmd())
 
 	if err := rootCmd.Execute(); err != nil {
+		if hint := errcodes.HintFor(err); hint != "" {
+			fmt.Fprintf(os.Stderr, "Hint: %s\n", hint)
+		}
 		os.Exit(1)
 	}
 }
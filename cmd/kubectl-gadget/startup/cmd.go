@@ -0,0 +1,175 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package startup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
+)
+
+const (
+	OutputModeText = "text"
+	OutputModeJSON = "json"
+
+	// flushDelay is the time we wait for the events of the gadgets to arrive
+	// before stopping them
+	flushDelay = 2 * time.Second
+)
+
+func parseKinds(s string) ([]Kind, error) {
+	var kinds []Kind
+	for _, k := range strings.Split(s, ",") {
+		switch kind := Kind(strings.TrimSpace(k)); kind {
+		case KindExec, KindOpen, KindConnect, KindDNS:
+			kinds = append(kinds, kind)
+		default:
+			return nil, fmt.Errorf("unknown kind %q", k)
+		}
+	}
+	return kinds, nil
+}
+
+func NewStartupCmd(rt runtime.Runtime) *cobra.Command {
+	var podName, selector, containerName string
+	var duration time.Duration
+	var kindsStr string
+	var waits int
+	var outputMode string
+
+	cmd := &cobra.Command{
+		Use:   "startup",
+		Short: "Profile the startup of containers as a timeline of their executions, opens, connections and DNS requests",
+		Long: `Trace the executables run, the files opened, the TCP connections made and the
+DNS requests done by the selected containers during the first --duration after
+they start, and render them as a timeline showing where the startup time goes.
+
+Run it before creating or restarting the pods: containers that were already
+running when the profiling started are skipped. It stops once all the
+containers that started were profiled during --duration, or when interrupted.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if podName == "" && selector == "" {
+				return commonutils.WrapInErrMissingArgs("--podname or --selector")
+			}
+			if duration <= 0 {
+				return commonutils.WrapInErrInvalidArg("--duration", fmt.Errorf("must be greater than 0"))
+			}
+			if outputMode != OutputModeText && outputMode != OutputModeJSON {
+				return commonutils.WrapInErrOutputModeNotSupported(outputMode)
+			}
+			kinds, err := parseKinds(kindsStr)
+			if err != nil {
+				return commonutils.WrapInErrInvalidArg("--kinds", err)
+			}
+			// The start of the containers is detected by the execution of
+			// their entrypoint
+			if !slices.Contains(kinds, KindExec) {
+				kinds = append(kinds, KindExec)
+			}
+
+			paramValues := map[string]string{
+				"operator.KubeManager.podname":       podName,
+				"operator.KubeManager.selector":      selector,
+				"operator.KubeManager.containername": containerName,
+			}
+			paramValues["operator.KubeManager.namespace"], _ = utils.GetNamespace()
+
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			col := newCollector()
+
+			// Keep running until the last container that started was
+			// profiled during the whole duration
+			var mu sync.Mutex
+			var timer *time.Timer
+			started := make(map[string]struct{})
+			handle := func(container string, timestamp uint64, kind Kind, value string) {
+				col.add(container, timestamp, kind, value)
+				if kind != KindExec {
+					return
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if _, ok := started[container]; ok {
+					return
+				}
+				started[container] = struct{}{}
+				fmt.Fprintf(os.Stderr, "Profiling %s\n", container)
+				if timer == nil {
+					timer = time.AfterFunc(duration+flushDelay, cancel)
+				} else {
+					timer.Reset(duration + flushDelay)
+				}
+			}
+
+			fmt.Fprintf(os.Stderr, "Waiting for containers to start...\n")
+			if err := run(ctx, rt, paramValues, kinds, handle); err != nil {
+				return err
+			}
+
+			timelines, running := col.timelines(duration)
+			for _, container := range running {
+				fmt.Fprintf(os.Stderr, "Skipping %s: it was already running\n", container)
+			}
+
+			for i, t := range timelines {
+				switch outputMode {
+				case OutputModeJSON:
+					d, err := json.Marshal(t)
+					if err != nil {
+						return fmt.Errorf("marshaling timeline: %w", err)
+					}
+					fmt.Println(string(d))
+				default:
+					if i > 0 {
+						fmt.Println()
+					}
+					printTimeline(os.Stdout, t, waits)
+				}
+			}
+			return nil
+		},
+	}
+
+	// No 'namespace' flag because it's added to the root command by
+	// KubernetesConfigFlags
+	cmd.Flags().StringVarP(&podName, "podname", "p", "", "Profile the containers of pods with that name")
+	cmd.Flags().StringVarP(&selector, "selector", "l", "", "Labels selector to filter on. Only '=' is supported (e.g. key1=value1,key2=value2).")
+	cmd.Flags().StringVarP(&containerName, "containername", "c", "", "Profile the containers with that name")
+	cmd.Flags().DurationVarP(&duration, "duration", "d", 30*time.Second, "Duration of the profiling after the start of each container")
+	cmd.Flags().StringVar(&kindsStr, "kinds", "exec,open,connect,dns", "Comma-separated kinds of events to trace: exec, open, connect and dns. exec is always traced")
+	cmd.Flags().IntVar(&waits, "waits", 5, "Number of longest waits between events to show for each container")
+	cmd.Flags().StringVarP(&outputMode, "output", "o", OutputModeText, fmt.Sprintf("Output mode: %q or %q", OutputModeText, OutputModeJSON))
+	return cmd
+}
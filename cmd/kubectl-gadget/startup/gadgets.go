@@ -0,0 +1,316 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package startup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/internal/version"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime"
+)
+
+// opPriority makes the handlers run after all the other operators
+const opPriority = 50000
+
+// extractor returns the function getting the value of an event from the
+// events of the data source, or nil if the data source isn't supported
+type extractor func(ds datasource.DataSource) func(data datasource.Data) (string, bool)
+
+// source is a gadget whose events are added to the timeline with a kind
+type source struct {
+	kind    Kind
+	gadget  string
+	extract extractor
+}
+
+var sources = []source{
+	{
+		kind:    KindExec,
+		gadget:  "trace_exec",
+		extract: execValue,
+	},
+	{
+		kind:    KindOpen,
+		gadget:  "trace_open",
+		extract: openValue,
+	},
+	{
+		kind:    KindConnect,
+		gadget:  "trace_tcpconnect",
+		extract: connectValue,
+	},
+	{
+		kind:    KindDNS,
+		gadget:  "trace_dns",
+		extract: dnsValue,
+	},
+}
+
+// gadgetImage returns the image of the gadget matching the version of the
+// client, or the latest one for development builds
+func gadgetImage(gadget string) string {
+	v := version.Version()
+	if v.Major == 0 && v.Minor == 0 && v.Patch == 0 {
+		return gadget
+	}
+	return fmt.Sprintf("%s:v%s", gadget, v.String())
+}
+
+// withError appends the error of the event to the value, if any
+func withError(errF datasource.FieldAccessor, data datasource.Data, value string) string {
+	if errF == nil {
+		return value
+	}
+	if e, _ := errF.String(data); e != "" {
+		return fmt.Sprintf("%s (%s)", value, e)
+	}
+	return value
+}
+
+// execValue uses the arguments of the execution, or the command name when
+// they aren't available
+func execValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	args := ds.GetField("args")
+	comm := ds.GetField("proc.comm")
+	if args == nil && comm == nil {
+		return nil
+	}
+	errF := ds.GetField("error")
+	return func(data datasource.Data) (string, bool) {
+		var v string
+		if args != nil {
+			v, _ = args.String(data)
+		}
+		if v == "" && comm != nil {
+			v, _ = comm.String(data)
+		}
+		if v == "" {
+			return "", false
+		}
+		return withError(errF, data, v), true
+	}
+}
+
+func openValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	fname := ds.GetField("fname")
+	if fname == nil {
+		return nil
+	}
+	errF := ds.GetField("error")
+	return func(data datasource.Data) (string, bool) {
+		v, _ := fname.String(data)
+		if v == "" {
+			return "", false
+		}
+		return withError(errF, data, v), true
+	}
+}
+
+// connectValue uses the Kubernetes name of the destination when known, as it
+// tells more than the address about what the container is connecting to
+func connectValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	addr := ds.GetField("dst.addr")
+	port := ds.GetField("dst.port")
+	if addr == nil || port == nil {
+		return nil
+	}
+	k8sKind := ds.GetField("dst.k8s.kind")
+	k8sName := ds.GetField("dst.k8s.name")
+	k8sNamespace := ds.GetField("dst.k8s.namespace")
+	errF := ds.GetField("error")
+	return func(data datasource.Data) (string, bool) {
+		a, _ := addr.String(data)
+		if a == "" {
+			return "", false
+		}
+		p, _ := port.Uint16(data)
+		v := net.JoinHostPort(a, strconv.FormatUint(uint64(p), 10))
+
+		if k8sKind != nil && k8sName != nil && k8sNamespace != nil {
+			kind, _ := k8sKind.String(data)
+			name, _ := k8sName.String(data)
+			namespace, _ := k8sNamespace.String(data)
+			if (kind == "svc" || kind == "pod") && name != "" {
+				v = fmt.Sprintf("%s (%s %s/%s)", v, kind, namespace, name)
+			}
+		}
+		return withError(errF, data, v), true
+	}
+}
+
+// dnsValue uses the name of the queries, and adds the response code and the
+// latency to the responses
+func dnsValue(ds datasource.DataSource) func(data datasource.Data) (string, bool) {
+	name := ds.GetField("name")
+	qr := ds.GetField("qr")
+	if name == nil || qr == nil {
+		return nil
+	}
+	qtype := ds.GetField("qtype")
+	rcode := ds.GetField("rcode")
+	latency := ds.GetField("latency_ns")
+	return func(data datasource.Data) (string, bool) {
+		n, _ := name.String(data)
+		if n == "" {
+			return "", false
+		}
+		if qtype != nil {
+			if t, _ := qtype.String(data); t != "" {
+				n = fmt.Sprintf("%s %s", n, t)
+			}
+		}
+
+		if q, _ := qr.String(data); q != "R" {
+			return "query " + n, true
+		}
+		v := "response " + n
+		if rcode != nil {
+			if c, _ := rcode.String(data); c != "" {
+				v = fmt.Sprintf("%s %s", v, c)
+			}
+		}
+		if latency != nil {
+			if l, _ := latency.Uint64(data); l != 0 {
+				v = fmt.Sprintf("%s in %s", v, time.Duration(l).Round(time.Microsecond))
+			}
+		}
+		return v, true
+	}
+}
+
+type containerFields struct {
+	namespace     datasource.FieldAccessor
+	podName       datasource.FieldAccessor
+	containerName datasource.FieldAccessor
+}
+
+func getContainerFields(ds datasource.DataSource) *containerFields {
+	cf := &containerFields{
+		namespace:     ds.GetField("k8s.namespace"),
+		podName:       ds.GetField("k8s.podName"),
+		containerName: ds.GetField("k8s.containerName"),
+	}
+	if cf.namespace == nil || cf.podName == nil || cf.containerName == nil {
+		return nil
+	}
+	return cf
+}
+
+// container returns "namespace/pod/container" for the container of the
+// event, or an empty string for events not coming from a pod
+func (cf *containerFields) container(data datasource.Data) string {
+	namespace, _ := cf.namespace.String(data)
+	podName, _ := cf.podName.String(data)
+	containerName, _ := cf.containerName.String(data)
+	if namespace == "" || podName == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/%s", namespace, podName, containerName)
+}
+
+// handler is called for each event of the containers with the timestamp of
+// the event in nanoseconds since the boot of the node
+type handler func(container string, timestamp uint64, kind Kind, value string)
+
+func (s *source) operator(handle handler) operators.DataOperator {
+	return simple.New("startup-"+string(s.kind), simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+		found := false
+		for _, ds := range gadgetCtx.GetDataSources() {
+			cf := getContainerFields(ds)
+			timestamp := ds.GetField("timestamp_raw")
+			if cf == nil || timestamp == nil {
+				continue
+			}
+			value := s.extract(ds)
+			if value == nil {
+				continue
+			}
+			found = true
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				container := cf.container(data)
+				if container == "" {
+					return nil
+				}
+				v, ok := value(data)
+				if !ok {
+					return nil
+				}
+				ts, _ := timestamp.Uint64(data)
+				handle(container, ts, s.kind, v)
+				return nil
+			}, opPriority)
+		}
+		if !found {
+			return fmt.Errorf("gadget %q has no data source with the expected fields", s.gadget)
+		}
+		return nil
+	}))
+}
+
+// run runs the gadgets of the given kinds until the context is done, calling
+// handle for each event. handle can be called concurrently.
+func run(
+	ctx context.Context,
+	rt runtime.Runtime,
+	paramValues map[string]string,
+	kinds []Kind,
+	handle handler,
+) error {
+	var wg sync.WaitGroup
+	var errsMu sync.Mutex
+	var errs []error
+
+	for i := range sources {
+		s := &sources[i]
+		if !slices.Contains(kinds, s.kind) {
+			continue
+		}
+
+		params := make(map[string]string, len(paramValues))
+		for k, v := range paramValues {
+			params[k] = v
+		}
+
+		gadgetCtx := gadgetcontext.New(
+			ctx,
+			gadgetImage(s.gadget),
+			gadgetcontext.WithDataOperators(s.operator(handle)),
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := rt.RunGadget(gadgetCtx, rt.ParamDescs().ToParams(), params); err != nil {
+				errsMu.Lock()
+				errs = append(errs, fmt.Errorf("running %s: %w", s.gadget, err))
+				errsMu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package startup implements the command profiling the startup of containers:
+// it records the executables run, the files opened, the connections made and
+// the DNS requests done during the first seconds of a container and renders
+// them as a timeline.
+package startup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Kind string
+
+const (
+	KindExec    Kind = "exec"
+	KindOpen    Kind = "open"
+	KindConnect Kind = "connect"
+	KindDNS     Kind = "dns"
+)
+
+// Event is something a container did during its startup
+type Event struct {
+	// Offset is the time since the first event of the container
+	Offset time.Duration `json:"-"`
+	// Gap is the time since the previous event of the container
+	Gap   time.Duration `json:"-"`
+	Kind  Kind          `json:"kind"`
+	Value string        `json:"value"`
+}
+
+// MarshalJSON writes the durations in microseconds
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(struct {
+		event
+		Offset int64 `json:"offset_us"`
+		Gap    int64 `json:"gap_us"`
+	}{
+		event:  event(e),
+		Offset: e.Offset.Microseconds(),
+		Gap:    e.Gap.Microseconds(),
+	})
+}
+
+// Wait is the time a container didn't do anything traced after an event,
+// like waiting for the answer of a server it connected to
+type Wait struct {
+	Duration time.Duration
+	After    Event
+}
+
+// Timeline contains the events of a container during its startup
+type Timeline struct {
+	// Container is "namespace/pod/container"
+	Container string  `json:"container"`
+	Events    []Event `json:"events"`
+}
+
+// Duration returns the time between the first and the last events
+func (t *Timeline) Duration() time.Duration {
+	if len(t.Events) == 0 {
+		return 0
+	}
+	return t.Events[len(t.Events)-1].Offset
+}
+
+// LongestWaits returns the n longest waits between two events, longest first
+func (t *Timeline) LongestWaits(n int) []Wait {
+	var waits []Wait
+	for i := 1; i < len(t.Events); i++ {
+		waits = append(waits, Wait{Duration: t.Events[i].Gap, After: t.Events[i-1]})
+	}
+	sort.SliceStable(waits, func(i, j int) bool {
+		return waits[i].Duration > waits[j].Duration
+	})
+	if len(waits) > n {
+		waits = waits[:n]
+	}
+	return waits
+}
+
+type rawEvent struct {
+	// timestamp is the time in nanoseconds since the boot of the node
+	timestamp uint64
+	kind      Kind
+	value     string
+}
+
+// collector collects the events of the containers
+type collector struct {
+	mu         sync.Mutex
+	containers map[string][]rawEvent
+}
+
+func newCollector() *collector {
+	return &collector{containers: make(map[string][]rawEvent)}
+}
+
+func (c *collector) add(container string, timestamp uint64, kind Kind, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.containers[container] = append(c.containers[container], rawEvent{
+		timestamp: timestamp,
+		kind:      kind,
+		value:     value,
+	})
+}
+
+// timelines returns the timeline of each container, sorted by name, keeping
+// the events of the first duration after its start. The containers whose
+// first event isn't the execution of their entrypoint were already running
+// before the profiling started, their names are returned apart.
+func (c *collector) timelines(duration time.Duration) (timelines []*Timeline, running []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for container, events := range c.containers {
+		// The gadgets send their events independently
+		slices.SortStableFunc(events, func(a, b rawEvent) int {
+			switch {
+			case a.timestamp < b.timestamp:
+				return -1
+			case a.timestamp > b.timestamp:
+				return 1
+			}
+			return 0
+		})
+		if events[0].kind != KindExec {
+			running = append(running, container)
+			continue
+		}
+
+		t := &Timeline{Container: container}
+		start := events[0].timestamp
+		prev := start
+		for _, e := range events {
+			offset := time.Duration(e.timestamp - start)
+			if offset > duration {
+				break
+			}
+			t.Events = append(t.Events, Event{
+				Offset: offset,
+				Gap:    time.Duration(e.timestamp - prev),
+				Kind:   e.kind,
+				Value:  e.value,
+			})
+			prev = e.timestamp
+		}
+		timelines = append(timelines, t)
+	}
+
+	sort.Slice(timelines, func(i, j int) bool {
+		return timelines[i].Container < timelines[j].Container
+	})
+	sort.Strings(running)
+	return timelines, running
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
+
+// printTimeline writes the events of the timeline followed by the longest
+// waits, which usually tell where the startup time goes
+func printTimeline(w io.Writer, t *Timeline, waits int) {
+	fmt.Fprintf(w, "%s: %d events in %s\n", t.Container, len(t.Events), formatDuration(t.Duration()))
+	fmt.Fprintf(w, "%-10s %-10s %-8s %s\n", "OFFSET", "GAP", "KIND", "VALUE")
+	for _, e := range t.Events {
+		fmt.Fprintf(w, "%-10s %-10s %-8s %s\n", formatDuration(e.Offset), formatDuration(e.Gap), e.Kind, e.Value)
+	}
+
+	longest := t.LongestWaits(waits)
+	if len(longest) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nLongest waits:\n")
+	for _, wait := range longest {
+		fmt.Fprintf(w, "  %-10s after %s %s\n", formatDuration(wait.Duration), wait.After.Kind, wait.After.Value)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package startup
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKinds(t *testing.T) {
+	kinds, err := parseKinds("exec, dns")
+	require.NoError(t, err)
+	assert.Equal(t, []Kind{KindExec, KindDNS}, kinds)
+
+	_, err = parseKinds("exec,write")
+	require.Error(t, err)
+}
+
+func TestTimelines(t *testing.T) {
+	const app = "default/web/app"
+	const sidecar = "default/web/sidecar"
+	const ms = uint64(time.Millisecond)
+
+	c := newCollector()
+	// Events of different gadgets arrive out of order
+	c.add(app, 1000*ms+10*ms, KindOpen, "/etc/app/config.yaml")
+	c.add(app, 1000*ms, KindExec, "/app/server --config /etc/app/config.yaml")
+	c.add(app, 1000*ms+20*ms, KindDNS, "query db.default.svc.cluster.local A")
+	c.add(app, 1000*ms+25*ms, KindConnect, "10.96.0.12:5432 (svc default/db)")
+	c.add(app, 1000*ms+1525*ms, KindOpen, "/var/lib/app/cache")
+	// After the duration
+	c.add(app, 1000*ms+5000*ms, KindOpen, "/var/lib/app/late")
+	// It was running before
+	c.add(sidecar, 500*ms, KindOpen, "/etc/sidecar.conf")
+	c.add(sidecar, 600*ms, KindExec, "/bin/sh -c date")
+
+	timelines, running := c.timelines(2 * time.Second)
+	assert.Equal(t, []string{sidecar}, running)
+	require.Len(t, timelines, 1)
+
+	tl := timelines[0]
+	assert.Equal(t, app, tl.Container)
+	assert.Equal(t, []Event{
+		{Offset: 0, Gap: 0, Kind: KindExec, Value: "/app/server --config /etc/app/config.yaml"},
+		{Offset: 10 * time.Millisecond, Gap: 10 * time.Millisecond, Kind: KindOpen, Value: "/etc/app/config.yaml"},
+		{Offset: 20 * time.Millisecond, Gap: 10 * time.Millisecond, Kind: KindDNS, Value: "query db.default.svc.cluster.local A"},
+		{Offset: 25 * time.Millisecond, Gap: 5 * time.Millisecond, Kind: KindConnect, Value: "10.96.0.12:5432 (svc default/db)"},
+		{Offset: 1525 * time.Millisecond, Gap: 1500 * time.Millisecond, Kind: KindOpen, Value: "/var/lib/app/cache"},
+	}, tl.Events)
+	assert.Equal(t, 1525*time.Millisecond, tl.Duration())
+
+	waits := tl.LongestWaits(2)
+	require.Len(t, waits, 2)
+	assert.Equal(t, 1500*time.Millisecond, waits[0].Duration)
+	assert.Equal(t, KindConnect, waits[0].After.Kind)
+	assert.Equal(t, 10*time.Millisecond, waits[1].Duration)
+	assert.Equal(t, KindExec, waits[1].After.Kind)
+
+	var buf bytes.Buffer
+	printTimeline(&buf, tl, 1)
+	assert.Equal(t, `default/web/app: 5 events in 1.525s
+OFFSET     GAP        KIND     VALUE
+0.000s     0.000s     exec     /app/server --config /etc/app/config.yaml
+0.010s     0.010s     open     /etc/app/config.yaml
+0.020s     0.010s     dns      query db.default.svc.cluster.local A
+0.025s     0.005s     connect  10.96.0.12:5432 (svc default/db)
+1.525s     1.500s     open     /var/lib/app/cache
+
+Longest waits:
+  1.500s     after connect 10.96.0.12:5432 (svc default/db)
+`, buf.String())
+}
+
+func TestEventJSON(t *testing.T) {
+	d, err := json.Marshal(Event{Offset: 1500 * time.Microsecond, Gap: 2 * time.Millisecond, Kind: KindExec, Value: "/bin/sh"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"offset_us":1500,"gap_us":2000,"kind":"exec","value":"/bin/sh"}`, string(d))
+}
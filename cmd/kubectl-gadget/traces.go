@@ -0,0 +1,134 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/cmd/kubectl-gadget/utils"
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
+)
+
+var tracesCmd = &cobra.Command{
+	Use:   "traces",
+	Short: "Manage the traces streaming their events",
+}
+
+var tracesListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List the traces streaming their events",
+	Args:         cobra.NoArgs,
+	RunE:         runTracesList,
+	SilenceUsage: true,
+}
+
+var tracesAttachCmd = &cobra.Command{
+	Use:   "attach <trace-id>",
+	Short: "Attach to a trace streaming its events",
+	Long: `Print the events of a trace created earlier, for instance by a command whose
+session was lost. The trace keeps running once detached with Ctrl-C or
+--timeout. The last events of the trace are printed first.`,
+	Example:      `  kubectl gadget traces attach qz5Jr7BFnD0KuM2x`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runTracesAttach,
+	SilenceUsage: true,
+}
+
+var skipHistory bool
+
+func NewTracesCmd(gadgetNamespace string) *cobra.Command {
+	utils.AddCommonFlags(tracesCmd, &params, gadgetNamespace)
+
+	tracesAttachCmd.Flags().BoolVar(&skipHistory,
+		"skip-history", false,
+		"Only print the events published after attaching, not the last ones of the trace")
+
+	tracesCmd.AddCommand(tracesListCmd)
+	tracesCmd.AddCommand(tracesAttachCmd)
+
+	return tracesCmd
+}
+
+func runTracesList(cmd *cobra.Command, args []string) error {
+	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
+
+	traceList, err := utils.GetTraceListFromOptions(gadgetNamespace, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("outputMode=%s", gadgetv1alpha1.TraceOutputModeStream),
+	})
+	if err != nil {
+		return err
+	}
+
+	type traceInfo struct {
+		gadget string
+		nodes  []string
+		states []string
+	}
+
+	traces := map[string]*traceInfo{}
+	for _, trace := range traceList.Items {
+		id, ok := trace.ObjectMeta.Labels[utils.GlobalTraceID]
+		if !ok {
+			continue
+		}
+		if params.Node != "" && trace.Spec.Node != params.Node {
+			continue
+		}
+
+		info, ok := traces[id]
+		if !ok {
+			info = &traceInfo{gadget: trace.Spec.Gadget}
+			traces[id] = info
+		}
+		info.nodes = append(info.nodes, trace.Spec.Node)
+		if state := string(trace.Status.State); !slices.Contains(info.states, state) {
+			info.states = append(info.states, state)
+		}
+	}
+
+	ids := make([]string, 0, len(traces))
+	for id := range traces {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "GADGET\tNODE(S)\tSTATE\tTRACEID")
+	for _, id := range ids {
+		info := traces[id]
+		sort.Strings(info.nodes)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.gadget, strings.Join(info.nodes, ","),
+			strings.Join(info.states, ","), id)
+	}
+
+	return nil
+}
+
+func runTracesAttach(cmd *cobra.Command, args []string) error {
+	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
+
+	return utils.AttachTrace(gadgetNamespace, args[0], &params, skipHistory)
+}
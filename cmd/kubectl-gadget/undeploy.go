@@ -114,6 +114,15 @@ func runUndeploy(cmd *cobra.Command, args []string) error {
 	gadgetNamespace := runtimeGlobalParams.Get(grpcruntime.ParamGadgetNamespace).AsString()
 	imagePolicyName := fmt.Sprintf("%s-image-policy", gadgetNamespace)
 
+	// Remove the trace schedules first, so they don't create new traces
+	fmt.Println("Removing trace schedules...")
+	err = traceClient.GadgetV1alpha1().TraceSchedules(gadgetNamespace).DeleteCollection(
+		context.TODO(), metav1.DeleteOptions{}, metav1.ListOptions{},
+	)
+	if err != nil && !errors.IsNotFound(err) {
+		errs = append(errs, fmt.Sprintf("failed to remove the trace schedules: %s", err))
+	}
+
 again:
 	fmt.Println("Removing traces...")
 	err = traceClient.GadgetV1alpha1().Traces(gadgetNamespace).DeleteCollection(
@@ -150,15 +159,17 @@ again:
 		}
 	}
 
-	// 2. remove crd
-	fmt.Println("Removing CRD...")
-	err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Delete(
-		context.TODO(), "traces.gadget.kinvolk.io", metav1.DeleteOptions{},
-	)
-	if err != nil && !errors.IsNotFound(err) {
-		errs = append(
-			errs, fmt.Sprintf("failed to remove \"traces.gadget.kinvolk.io\" CRD: %s", err),
+	// 2. remove crds
+	fmt.Println("Removing CRDs...")
+	for _, crd := range []string{"traces.gadget.kinvolk.io", "traceschedules.gadget.kinvolk.io"} {
+		err = crdClient.ApiextensionsV1().CustomResourceDefinitions().Delete(
+			context.TODO(), crd, metav1.DeleteOptions{},
 		)
+		if err != nil && !errors.IsNotFound(err) {
+			errs = append(
+				errs, fmt.Sprintf("failed to remove %q CRD: %s", crd, err),
+			)
+		}
 	}
 
 	// 3. gadget cluster role binding
@@ -30,22 +30,30 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/factory"
 )
 
-func ExecPod(client *kubernetes.Clientset, node string, namespace string, podCmd string, cmdStdout io.Writer, cmdStderr io.Writer) error {
+// getGadgetPodName returns the name of the running gadget pod of node
+func getGadgetPodName(client *kubernetes.Clientset, node string, namespace string) (string, error) {
 	listOptions := metav1.ListOptions{
 		LabelSelector: "k8s-app=gadget",
 		FieldSelector: "spec.nodeName=" + node + ",status.phase=Running",
 	}
 	pods, err := client.CoreV1().Pods(namespace).List(context.TODO(), listOptions)
 	if err != nil {
-		return commonutils.WrapInErrListPods(err)
+		return "", commonutils.WrapInErrListPods(err)
 	}
 	if len(pods.Items) == 0 {
-		return commonutils.ErrGadgetPodNotFound
+		return "", commonutils.ErrGadgetPodNotFound
 	}
 	if len(pods.Items) != 1 {
-		return commonutils.ErrMultipleGadgetPodFound
+		return "", commonutils.ErrMultipleGadgetPodFound
+	}
+	return pods.Items[0].Name, nil
+}
+
+func ExecPod(client *kubernetes.Clientset, node string, namespace string, podCmd string, cmdStdout io.Writer, cmdStderr io.Writer) error {
+	podName, err := getGadgetPodName(client, node, namespace)
+	if err != nil {
+		return err
 	}
-	podName := pods.Items[0].Name
 
 	restConfig, err := KubernetesConfigFlags.ToRESTConfig()
 	if err != nil {
@@ -16,14 +16,17 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
+	gadgetstream "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 )
 
@@ -49,6 +52,10 @@ type CommonFlags struct {
 	// Node allows to filter containers by node name
 	Node string
 
+	// NodeSelector is a label selector choosing the nodes the gadget runs
+	// on, e.g. kubernetes.io/arch=arm64. It can't be used with Node.
+	NodeSelector string
+
 	// Namespace allows to filter by Kubernetes namespace. Ignored if
 	// AllNamespaces is true
 	Namespace string
@@ -69,6 +76,47 @@ type CommonFlags struct {
 
 	// Number of seconds that the gadget will run for
 	Timeout int
+
+	// Number of events after which the gadget is stopped
+	MaxEvents int
+
+	// LowLatency disables the batching of events on the nodes
+	LowLatency bool
+
+	// StreamConnection is how the events are received from the nodes:
+	// StreamConnectionExec or StreamConnectionPortForward
+	StreamConnection string
+
+	// StreamPort is the port of the stream service of the gadget pods
+	StreamPort uint16
+
+	// StreamTLSCertFile, StreamTLSKeyFile and StreamTLSServerCAFile are the
+	// files used to authenticate to the stream service of the gadget pods
+	StreamTLSCertFile     string
+	StreamTLSKeyFile      string
+	StreamTLSServerCAFile string
+}
+
+const (
+	// StreamConnectionExec receives the events by executing
+	// gadgettracermanager in the gadget pods
+	StreamConnectionExec = "exec"
+	// StreamConnectionPortForward receives the events from the stream service
+	// of the gadget pods through a port-forward
+	StreamConnectionPortForward = "port-forward"
+)
+
+// parseNodeSelector checks a label selector of nodes and returns it in its
+// canonical form
+func parseNodeSelector(raw string) (string, error) {
+	selector, err := labels.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if selector.Empty() {
+		return "", errors.New("selects all the nodes")
+	}
+	return selector.String(), nil
 }
 
 // GetNamespace returns the namespace specified by '-n' or the default
@@ -100,6 +148,19 @@ func AddCommonFlags(command *cobra.Command, params *CommonFlags, gadgetNamespace
 			}
 		}
 
+		// Node selector
+		if params.NodeSelector != "" {
+			if params.Node != "" {
+				return commonutils.WrapInErrInvalidArg("--node-selector",
+					errors.New("can't be used together with --node"))
+			}
+			selector, err := parseNodeSelector(params.NodeSelector)
+			if err != nil {
+				return commonutils.WrapInErrInvalidArg("--node-selector", err)
+			}
+			params.NodeSelector = selector
+		}
+
 		// Verify that there is a gadget pod running on the node
 		// specified in the filter.
 		if params.Node != "" {
@@ -125,6 +186,20 @@ func AddCommonFlags(command *cobra.Command, params *CommonFlags, gadgetNamespace
 			}
 		}
 
+		// Stream connection
+		switch params.StreamConnection {
+		case StreamConnectionExec:
+		case StreamConnectionPortForward:
+			if params.StreamTLSCertFile == "" || params.StreamTLSKeyFile == "" || params.StreamTLSServerCAFile == "" {
+				return commonutils.WrapInErrInvalidArg("--stream-connection",
+					fmt.Errorf("%q requires --stream-tls-cert-file, --stream-tls-key-file and --stream-tls-server-ca-file",
+						StreamConnectionPortForward))
+			}
+		default:
+			return commonutils.WrapInErrInvalidArg("--stream-connection",
+				fmt.Errorf("should be %q or %q", StreamConnectionExec, StreamConnectionPortForward))
+		}
+
 		// Output Mode
 		if err := params.ParseOutputConfig(); err != nil {
 			return err
@@ -156,6 +231,13 @@ func AddCommonFlags(command *cobra.Command, params *CommonFlags, gadgetNamespace
 		"Show only data from pods running in that node",
 	)
 
+	command.PersistentFlags().StringVar(
+		&params.NodeSelector,
+		"node-selector",
+		"",
+		"Run the gadget only on the nodes matching this label selector (e.g. kubernetes.io/arch=arm64)",
+	)
+
 	command.PersistentFlags().StringVarP(
 		&params.Podname,
 		"podname",
@@ -186,4 +268,54 @@ func AddCommonFlags(command *cobra.Command, params *CommonFlags, gadgetNamespace
 		0,
 		"Number of seconds that the gadget will run for",
 	)
+
+	command.PersistentFlags().IntVar(
+		&params.MaxEvents,
+		"max-events",
+		0,
+		"Number of events after which the gadget is stopped",
+	)
+
+	command.PersistentFlags().BoolVar(
+		&params.LowLatency,
+		"low-latency",
+		false,
+		"Send each event as soon as it's available instead of batching them on the nodes",
+	)
+
+	command.PersistentFlags().StringVar(
+		&params.StreamConnection,
+		"stream-connection",
+		StreamConnectionExec,
+		fmt.Sprintf("How to receive the events from the nodes: %q or %q (requires the stream service of the gadget pods)",
+			StreamConnectionExec, StreamConnectionPortForward),
+	)
+
+	command.PersistentFlags().Uint16Var(
+		&params.StreamPort,
+		"stream-port",
+		gadgetstream.DefaultServicePort,
+		"Port of the stream service of the gadget pods",
+	)
+
+	command.PersistentFlags().StringVar(
+		&params.StreamTLSCertFile,
+		"stream-tls-cert-file",
+		"",
+		"TLS client certificate used to connect to the stream service",
+	)
+
+	command.PersistentFlags().StringVar(
+		&params.StreamTLSKeyFile,
+		"stream-tls-key-file",
+		"",
+		"TLS client key used to connect to the stream service",
+	)
+
+	command.PersistentFlags().StringVar(
+		&params.StreamTLSServerCAFile,
+		"stream-tls-server-ca-file",
+		"",
+		"TLS server CA certificate used to verify the stream service",
+	)
 }
@@ -0,0 +1,52 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseNodeSelector(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		raw      string
+		expected string
+		wantErr  bool
+	}{
+		{raw: "kubernetes.io/arch=arm64", expected: "kubernetes.io/arch=arm64"},
+		{raw: " pool = workers ", expected: "pool=workers"},
+		{raw: "pool in (a,b),!spot", expected: "pool in (a,b),!spot"},
+		{raw: "", wantErr: true},
+		{raw: "pool=a=b", wantErr: true},
+	}
+
+	for _, test := range tests {
+		selector, err := parseNodeSelector(test.raw)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", test.raw, selector)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.raw, err)
+			continue
+		}
+		if selector != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.raw, test.expected, selector)
+		}
+	}
+}
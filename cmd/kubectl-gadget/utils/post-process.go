@@ -19,10 +19,13 @@ import (
 	"io"
 	"strings"
 	"sync/atomic"
+
+	gadgetstream "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
 )
 
 type PostProcess struct {
 	firstLinePrinted uint64
+	linesPrinted     uint64
 	OutStreams       []*postProcessSingle
 	ErrStreams       []*postProcessSingle
 }
@@ -33,9 +36,16 @@ type postProcessSingle struct {
 	callback         func(line string, node string)
 	transform        func(line string) string
 	firstLinePrinted *uint64
+	linesPrinted     *uint64
+	maxLines         uint64
+	onMaxLines       func()
 	buffer           string // buffer to save incomplete strings
 	skipFirstLine    bool
 	verbose          bool
+
+	// gaps finds the events lost by the node, they are reported on errOut
+	gaps   *gadgetstream.GapDetector
+	errOut io.Writer
 }
 
 type PostProcessConfig struct {
@@ -58,6 +68,13 @@ type PostProcessConfig struct {
 
 	// Verbose mode
 	Verbose bool
+
+	// Maximum number of lines to process across all flows; further lines
+	// are dropped. 0 means no limit.
+	MaxLines uint64
+
+	// Function to be called once MaxLines lines were processed.
+	OnMaxLines func()
 }
 
 func NewPostProcess(config *PostProcessConfig) *PostProcess {
@@ -73,8 +90,13 @@ func NewPostProcess(config *PostProcessConfig) *PostProcess {
 			callback:         config.Callback,
 			transform:        config.Transform,
 			firstLinePrinted: &p.firstLinePrinted,
+			linesPrinted:     &p.linesPrinted,
+			maxLines:         config.MaxLines,
+			onMaxLines:       config.OnMaxLines,
 			skipFirstLine:    config.SkipFirstLine,
 			verbose:          config.Verbose,
+			gaps:             &gadgetstream.GapDetector{},
+			errOut:           config.ErrStream,
 		}
 
 		p.ErrStreams[i] = &postProcessSingle{
@@ -87,6 +109,12 @@ func NewPostProcess(config *PostProcessConfig) *PostProcess {
 	return p
 }
 
+// dropPartialLine forgets the incomplete line buffered, e.g. when the stream
+// it was part of broke
+func (post *postProcessSingle) dropPartialLine() {
+	post.buffer = ""
+}
+
 func (post *postProcessSingle) Write(p []byte) (n int, err error) {
 	asStr := post.buffer + string(p)
 
@@ -98,12 +126,29 @@ func (post *postProcessSingle) Write(p []byte) (n int, err error) {
 	// Print all complete lines
 	for _, line := range lines[0 : len(lines)-1] {
 		// Skip printing the first line (header) multiple times if requested by the caller
+		header := false
 		if post.skipFirstLine {
 			post.skipFirstLine = false // we already processed the first line. Don't care about it anymore.
 			if atomic.AddUint64(post.firstLinePrinted, 1) != 1 {
 				// first line already printed by another stream, skip it.
 				continue
 			}
+			header = true
+		}
+
+		if post.gaps != nil && post.errOut != nil {
+			if lost := post.gaps.Observe(line); lost > 0 {
+				fmt.Fprintf(post.errOut, "Warning: %d events lost on node %q\n", lost, post.Node)
+			}
+		}
+
+		// The header doesn't count as a line
+		var printed uint64
+		if post.maxLines > 0 && !header {
+			printed = atomic.AddUint64(post.linesPrinted, 1)
+			if printed > post.maxLines {
+				continue
+			}
 		}
 
 		if post.callback != nil {
@@ -117,6 +162,10 @@ func (post *postProcessSingle) Write(p []byte) (n int, err error) {
 				fmt.Fprintf(post.orig, "%s\n", line)
 			}
 		}
+
+		if !header && post.maxLines > 0 && printed == post.maxLines && post.onMaxLines != nil {
+			post.onMaxLines()
+		}
 	}
 
 	post.buffer = lines[len(lines)-1] // Buffer last line to print in next iteration
@@ -15,6 +15,7 @@
 package utils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -174,3 +175,60 @@ func TestSkipFirstLineFalse(t *testing.T) {
 		t.Fatalf("%v != %v", string(mock.output), expected)
 	}
 }
+
+func TestMaxLines(t *testing.T) {
+	mock := &mockWriter{[]byte{}}
+	maxReached := 0
+	postProcess := NewPostProcess(&PostProcessConfig{
+		Flows:         2,
+		OutStream:     mock,
+		ErrStream:     mock,
+		SkipFirstLine: true,
+		MaxLines:      2,
+		OnMaxLines:    func() { maxReached++ },
+	})
+
+	postProcess.OutStreams[0].Write([]byte("COMM  PID\n"))
+	postProcess.OutStreams[1].Write([]byte("COMM  PID\n"))
+	postProcess.OutStreams[0].Write([]byte("curl  100000\n"))
+	postProcess.OutStreams[1].Write([]byte("wget  200000\nmkdir 199679\n"))
+	postProcess.OutStreams[0].Write([]byte("cat   300000\n"))
+
+	expected := `
+COMM  PID
+curl  100000
+wget  200000
+`
+	if "\n"+string(mock.output) != expected {
+		t.Fatalf("%v != %v", string(mock.output), expected)
+	}
+	if maxReached != 1 {
+		t.Fatalf("OnMaxLines called %d times, expected 1", maxReached)
+	}
+}
+
+// TestLostEvents tests that the events missing from the stream of a node are
+// reported
+func TestLostEvents(t *testing.T) {
+	out := &mockWriter{[]byte{}}
+	errOut := &mockWriter{[]byte{}}
+
+	postProcess := NewPostProcess(&PostProcessConfig{
+		Flows:     2,
+		OutStream: out,
+		ErrStream: errOut,
+	})
+	postProcess.OutStreams[0].Node = "node1"
+	postProcess.OutStreams[1].Node = "node2"
+
+	postProcess.OutStreams[0].Write([]byte(`{"seq":1,"eventID":"a"}` + "\n" + `{"seq":4,"eventID":"b"}` + "\n"))
+	postProcess.OutStreams[1].Write([]byte(`{"seq":7,"eventID":"c"}` + "\n" + `{"seq":8,"eventID":"d"}` + "\n"))
+
+	expected := "Warning: 2 events lost on node \"node1\"\n"
+	if string(errOut.output) != expected {
+		t.Fatalf("%v != %v", string(errOut.output), expected)
+	}
+	if len(strings.Split(string(out.output), "\n")) != 5 {
+		t.Fatalf("unexpected output: %v", string(out.output))
+	}
+}
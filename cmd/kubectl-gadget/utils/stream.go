@@ -0,0 +1,170 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"k8s.io/client-go/kubernetes"
+
+	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+	gadgetstream "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
+	grpcruntime "github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/grpc"
+	gadgettls "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/tls"
+)
+
+// receiveStreamPortForward receives the stream of a trace from the stream
+// service of the gadget pod of node through a port-forward. The connection is
+// established again if it breaks, e.g. when the API server closes the
+// port-forward.
+func receiveStreamPortForward(
+	client *kubernetes.Clientset,
+	node string,
+	gadgetNamespace string,
+	tracerID string,
+	params *CommonFlags,
+	skipHistory bool,
+	stdout io.Writer,
+	stderr io.Writer,
+) error {
+	restConfig, err := KubernetesConfigFlags.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+
+	cert, err := gadgettls.LoadTLSCert(params.StreamTLSCertFile, params.StreamTLSKeyFile)
+	if err != nil {
+		return fmt.Errorf("creating TLS certificate: %w", err)
+	}
+	ca, err := gadgettls.LoadTLSCA(params.StreamTLSServerCAFile)
+	if err != nil {
+		return fmt.Errorf("creating TLS certificate authority: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		ServerName:   grpcruntime.DefaultTLSServerName,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      ca,
+	}
+
+	timeout := grpcruntime.ConnectTimeout * time.Second
+
+	// The pod is looked up at each connection, so a restarted gadget pod is
+	// found again
+	//nolint:staticcheck
+	conn, err := grpc.Dial("passthrough:///"+node,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			podName, err := getGadgetPodName(client, node, gadgetNamespace)
+			if err != nil {
+				return nil, err
+			}
+			return grpcruntime.NewK8SPortFwdConnToPod(ctx, restConfig, gadgetNamespace,
+				podName, params.StreamPort, timeout)
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("dialing stream service on node %q: %w", node, err)
+	}
+	defer conn.Close()
+
+	// Lines are written in batches unless low latency is requested, like
+	// gadgettracermanager does when called with -call receive-stream
+	interval, size := gadgetstream.DefaultBatchInterval, gadgetstream.DefaultBatchSize
+	if params.LowLatency {
+		interval, size = 0, 1
+	}
+	writer := gadgetstream.NewBatchWriter(stdout, interval, size)
+
+	err = gadgetstream.Receive(context.Background(), pb.NewGadgetTracerManagerClient(conn),
+		gadgetstream.ReceiveConfig{
+			TracerID:    tracerID,
+			SkipHistory: skipHistory,
+			MaxRetries:  gadgetstream.DefaultMaxRetries,
+			OnReconnect: func(err error) {
+				fmt.Fprintf(stderr, "Connection to node %q lost (%v), reconnecting\n", node, err)
+			},
+		},
+		writer.WriteLine,
+	)
+	if ferr := writer.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// receiveStreamExec receives the stream of a trace by running
+// gadgettracermanager in the gadget pod of node. The command is run again,
+// without the history, if it fails, e.g. because the connection broke or the
+// gadget pod restarted.
+func receiveStreamExec(
+	client *kubernetes.Clientset,
+	node string,
+	gadgetNamespace string,
+	tracerID string,
+	params *CommonFlags,
+	skipHistory bool,
+	stdout *postProcessSingle,
+	stderr io.Writer,
+) error {
+	return gadgetstream.Retry(context.Background(),
+		gadgetstream.ReceiveConfig{
+			TracerID:    tracerID,
+			SkipHistory: skipHistory,
+			MaxRetries:  gadgetstream.DefaultMaxRetries,
+			OnReconnect: func(err error) {
+				fmt.Fprintf(stderr, "Connection to node %q lost (%v), reconnecting\n", node, err)
+			},
+		},
+		func(_ context.Context, skipHistory bool) (bool, error) {
+			cmd := "/bin/gadgettracermanager -call receive-stream -tracerid " + tracerID
+			if params.LowLatency {
+				cmd += " -low-latency"
+			}
+			if skipHistory {
+				cmd += " -skip-history"
+			}
+
+			out := &countingWriter{w: stdout}
+			err := ExecPod(client, node, gadgetNamespace, cmd, out, stderr)
+			if err != nil {
+				// The last line could have been cut by the failure
+				stdout.dropPartialLine()
+			}
+			return out.n > 0, err
+		},
+		// The errors of the command can't be told apart from the ones of the
+		// connection
+		func(error, bool) bool { return true },
+	)
+}
@@ -24,6 +24,7 @@ import (
 	"os/signal"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"text/tabwriter"
@@ -45,15 +46,22 @@ import (
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
 	clientset "github.com/inspektor-gadget/inspektor-gadget/pkg/client/clientset/versioned"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/errcodes"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
 )
 
 const (
 	GadgetOperation = "gadget.kinvolk.io/operation"
+	// GadgetOperationID is set together with GadgetOperation and echoed in
+	// Status.OperationID once the operation was applied.
+	GadgetOperationID = "gadget.kinvolk.io/operation-id"
 	// We name it "global" as if one trace is created on several nodes, then each
 	// copy of the trace on each node will share the same id.
 	GlobalTraceID = "global-trace-id"
 	TraceTimeout  = 5 * time.Second
+	// TraceTTLMargin is added to the timeout of a trace to get its TTL, so
+	// it's only garbage collected if we didn't delete it ourselves.
+	TraceTTLMargin = time.Minute
 )
 
 // TraceConfig is used to contain information used to manage a trace.
@@ -119,6 +127,25 @@ func randomTraceID() string {
 	return string(output)
 }
 
+// lastOperationIDs contains the ID of the last operation we requested on each
+// trace ID.
+var (
+	lastOperationIDsMu sync.Mutex
+	lastOperationIDs   = make(map[string]string)
+)
+
+func setLastOperationID(traceID, operationID string) {
+	lastOperationIDsMu.Lock()
+	defer lastOperationIDsMu.Unlock()
+	lastOperationIDs[traceID] = operationID
+}
+
+func lastOperationID(traceID string) string {
+	lastOperationIDsMu.Lock()
+	defer lastOperationIDsMu.Unlock()
+	return lastOperationIDs[traceID]
+}
+
 // If all the elements in the map have the same value, it is returned.
 // Otherwise, an empty string is returned.
 func getIdenticalValue(m map[string]string) string {
@@ -135,6 +162,21 @@ func getIdenticalValue(m map[string]string) string {
 
 // If there are more than one element in the map and the Error/Warning is
 // the same for all the nodes, printTraceFeedback will print it only once.
+// traceErrorMessage returns the operation error of the trace along with its
+// error code and the hint to fix it, if any.
+func traceErrorMessage(trace *gadgetv1alpha1.Trace) string {
+	msg := trace.Status.OperationError
+	code := errcodes.Code(trace.Status.OperationErrorCode)
+	if code == "" {
+		return msg
+	}
+	msg = fmt.Sprintf("%s [%s]", msg, code)
+	if hint := code.Hint(); hint != "" {
+		msg = fmt.Sprintf("%s\nHint: %s", msg, hint)
+	}
+	return msg
+}
+
 func printTraceFeedback(prefix string, m map[string]string, totalNodes int) {
 	// Do not print `len(m)` times the same message if it's the same from all nodes
 	if len(m) > 1 && len(m) == totalNodes {
@@ -204,8 +246,9 @@ func printVersionSkewWarning(pods *corev1.PodList) {
 
 // createTraces creates a trace using Kubernetes REST API.
 // Note that, this function will create the trace on all existing node if
-// trace.Spec.Node is empty.
-func createTraces(gadgetNamespace string, trace *gadgetv1alpha1.Trace) error {
+// trace.Spec.Node is empty, or on the nodes matching nodeSelector if it's
+// given.
+func createTraces(gadgetNamespace string, trace *gadgetv1alpha1.Trace, nodeSelector string) error {
 	client, err := k8sutil.NewClientsetFromConfigFlags(KubernetesConfigFlags)
 	if err != nil {
 		return commonutils.WrapInErrSetupK8sClient(err)
@@ -228,11 +271,31 @@ func createTraces(gadgetNamespace string, trace *gadgetv1alpha1.Trace) error {
 
 	printVersionSkewWarning(pods)
 
+	var selectedNodes map[string]struct{}
+	if nodeSelector != "" {
+		nodes, err := client.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{LabelSelector: nodeSelector})
+		if err != nil {
+			return fmt.Errorf("listing nodes matching %q: %w", nodeSelector, err)
+		}
+		if len(nodes.Items) == 0 {
+			return fmt.Errorf("no nodes match the node selector %q", nodeSelector)
+		}
+
+		selectedNodes = make(map[string]struct{}, len(nodes.Items))
+		for _, node := range nodes.Items {
+			selectedNodes[node.Name] = struct{}{}
+		}
+	}
+
 	traceNode := trace.Spec.Node
+	created := 0
 	for _, pod := range pods.Items {
 		if traceNode != "" && pod.Spec.NodeName != traceNode {
 			continue
 		}
+		if _, ok := selectedNodes[pod.Spec.NodeName]; selectedNodes != nil && !ok {
+			continue
+		}
 
 		ready := false
 
@@ -270,6 +333,11 @@ func createTraces(gadgetNamespace string, trace *gadgetv1alpha1.Trace) error {
 
 			return fmt.Errorf("creating trace on node %q: %w", pod.Spec.NodeName, err)
 		}
+		created++
+	}
+
+	if selectedNodes != nil && created == 0 {
+		return fmt.Errorf("no ready gadget pods on the nodes matching %q", nodeSelector)
 	}
 
 	return nil
@@ -277,7 +345,7 @@ func createTraces(gadgetNamespace string, trace *gadgetv1alpha1.Trace) error {
 
 // updateTraceOperation updates operation for an already existing trace using
 // Kubernetes REST API.
-func updateTraceOperation(gadgetNamespace string, trace *gadgetv1alpha1.Trace, operation string) error {
+func updateTraceOperation(gadgetNamespace string, trace *gadgetv1alpha1.Trace, operation, operationID string) error {
 	traceClient, err := getTraceClient()
 	if err != nil {
 		return err
@@ -297,7 +365,8 @@ func updateTraceOperation(gadgetNamespace string, trace *gadgetv1alpha1.Trace, o
 	patch := JSONMergePatch{
 		ObjectMeta: ObjectMeta{
 			Annotations{
-				GadgetOperation: operation,
+				GadgetOperation:   operation,
+				GadgetOperationID: operationID,
 			},
 		},
 	}
@@ -325,6 +394,7 @@ func updateTraceOperation(gadgetNamespace string, trace *gadgetv1alpha1.Trace, o
 // succeed only if the trace was created and goes into the requested state.
 func CreateTrace(config *TraceConfig) (string, error) {
 	traceID := randomTraceID()
+	operationID := randomTraceID()
 
 	var filter *gadgetv1alpha1.ContainerFilter
 
@@ -344,7 +414,8 @@ func CreateTrace(config *TraceConfig) (string, error) {
 			GenerateName: config.GadgetName + "-",
 			Namespace:    config.GadgetNamespace,
 			Annotations: map[string]string{
-				GadgetOperation: string(config.Operation),
+				GadgetOperation:   string(config.Operation),
+				GadgetOperationID: operationID,
 			},
 			Labels: map[string]string{
 				GlobalTraceID: traceID,
@@ -373,6 +444,25 @@ func CreateTrace(config *TraceConfig) (string, error) {
 		},
 	}
 
+	// Let the node stop the trace by itself, so it doesn't keep running if
+	// we go away before deleting it.
+	if config.TraceOutputMode == gadgetv1alpha1.TraceOutputModeStream {
+		if config.CommonFlags.Timeout > 0 {
+			trace.Spec.Timeout = &metav1.Duration{
+				Duration: time.Duration(config.CommonFlags.Timeout) * time.Second,
+			}
+		}
+		if config.CommonFlags.MaxEvents > 0 {
+			trace.Spec.MaxEvents = int64(config.CommonFlags.MaxEvents)
+		}
+	}
+
+	// Let the node delete the trace if we are killed before deleting it
+	if config.CommonFlags.Timeout > 0 {
+		ttl := int64(config.CommonFlags.Timeout) + int64(TraceTTLMargin.Seconds())
+		trace.Spec.TTLSecondsAfterCreation = &ttl
+	}
+
 	for key, value := range config.AdditionalLabels {
 		v, ok := trace.ObjectMeta.Labels[key]
 		if ok {
@@ -382,10 +472,11 @@ func CreateTrace(config *TraceConfig) (string, error) {
 		trace.ObjectMeta.Labels[key] = value
 	}
 
-	err := createTraces(config.GadgetNamespace, trace)
+	err := createTraces(config.GadgetNamespace, trace, config.CommonFlags.NodeSelector)
 	if err != nil {
 		return "", err
 	}
+	setLastOperationID(traceID, operationID)
 
 	if config.TraceInitialState != "" {
 		// Once the traces are created, we wait for them to be in
@@ -454,12 +545,14 @@ func SetTraceOperation(gadgetNamespace string, traceID string, operation string)
 		return err
 	}
 
+	operationID := randomTraceID()
 	for _, trace := range traces.Items {
-		localError := updateTraceOperation(gadgetNamespace, &trace, operation)
+		localError := updateTraceOperation(gadgetNamespace, &trace, operation, operationID)
 		if localError != nil {
 			err = fmt.Errorf("%w\nError updating trace operation for %q: %w", err, traceID, localError)
 		}
 	}
+	setLastOperationID(traceID, operationID)
 
 	return err
 }
@@ -503,6 +596,19 @@ func waitForCondition(gadgetNamespace string, traceID string, conditionFunction
 	nodeWarnings := make(map[string]string)
 	nodeErrors := make(map[string]string)
 
+	// If we requested an operation on these traces, their status (including
+	// errors) is only meaningful once the controller applied that specific
+	// operation, and not a previous identical one.
+	operationID := lastOperationID(traceID)
+	// Transient errors are retried by the controller, so they aren't final
+	// either.
+	operationApplied := func(trace *gadgetv1alpha1.Trace) bool {
+		if trace.Status.OperationErrorTransient {
+			return false
+		}
+		return operationID == "" || trace.Status.OperationID == operationID
+	}
+
 	traceList, err := getTraceListFromID(gadgetNamespace, traceID)
 	if err != nil {
 		return nil, err
@@ -510,6 +616,10 @@ func waitForCondition(gadgetNamespace string, traceID string, conditionFunction
 
 	// Maybe some traces already satisfy conditionFunction?
 	for i, trace := range traceList.Items {
+		if !operationApplied(&trace) {
+			continue
+		}
+
 		if trace.Status.OperationWarning != "" {
 			// The trace can have a warning but satisfies conditionFunction.
 			// So, we do not add it to the map here.
@@ -602,6 +712,10 @@ func waitForCondition(gadgetNamespace string, traceID string, conditionFunction
 
 			trace, _ := event.Object.(*gadgetv1alpha1.Trace)
 
+			if !operationApplied(trace) {
+				return false, nil
+			}
+
 			if trace.Status.OperationWarning != "" {
 				// The trace can have a warning but satisfies conditionFunction.
 				// So, we do not add it to the map here.
@@ -631,7 +745,10 @@ func waitForCondition(gadgetNamespace string, traceID string, conditionFunction
 	}
 
 	for _, trace := range erroredTraces {
-		nodeErrors[trace.Spec.Node] = trace.Status.OperationError
+		nodeErrors[trace.Spec.Node] = traceErrorMessage(trace)
+		if trace.Status.VerifierLog != "" {
+			log.Debugf("Verifier log on node %q:\n%s", trace.Spec.Node, trace.Status.VerifierLog)
+		}
 	}
 
 	// We print errors whatever happened.
@@ -696,7 +813,8 @@ var sigIntReceivedNumber = 0
 
 // SigHandler installs a handler for all signals which cause termination as
 // their default behavior.
-// On reception of this signal, the given trace will be deleted.
+// On reception of this signal, the given trace will be deleted, except for
+// SIGHUP.
 // This function fixes trace not being deleted when calling:
 // kubectl gadget process-collector -A | head -n0
 func SigHandler(gadgetNamespace string, traceID *string, printTerminationMessage bool) {
@@ -719,7 +837,10 @@ func SigHandler(gadgetNamespace string, traceID *string, printTerminationMessage
 			SigHandler(gadgetNamespace, traceID, printTerminationMessage)
 		}
 
-		if *traceID != "" {
+		// The terminal went away, like with a dropped SSH session: the trace
+		// keeps running so that "kubectl gadget traces attach" can follow it
+		// again. It's still stopped and deleted by the node after --timeout.
+		if *traceID != "" && sig != syscall.SIGHUP {
 			DeleteTrace(gadgetNamespace, *traceID)
 		}
 		if sig == syscall.SIGINT {
@@ -745,7 +866,31 @@ func PrintTraceOutputFromStream(gadgetNamespace string, traceID string, expected
 		return err
 	}
 
-	return genericStreams(gadgetNamespace, params, traces, nil, transformLine)
+	return genericStreams(gadgetNamespace, params, traces, nil, transformLine, false)
+}
+
+// AttachTrace prints the events of an existing trace with TraceOutputMode set
+// to Stream. Unlike RunTraceAndPrintStream, it neither creates nor deletes the
+// trace: the trace keeps running once we detach from it. The events kept in
+// the history of the streams are printed first unless skipHistory is set.
+func AttachTrace(gadgetNamespace string, traceID string, params *CommonFlags, skipHistory bool) error {
+	traces, err := getTraceListFromID(gadgetNamespace, traceID)
+	if err != nil {
+		return err
+	}
+
+	for _, trace := range traces.Items {
+		if trace.Spec.OutputMode != gadgetv1alpha1.TraceOutputModeStream {
+			return fmt.Errorf("trace %q doesn't stream its events, its output mode is %q",
+				traceID, trace.Spec.OutputMode)
+		}
+		if trace.Status.State != gadgetv1alpha1.TraceStateStarted {
+			return fmt.Errorf("trace %q isn't running on node %q, its state is %q",
+				traceID, trace.Spec.Node, trace.Status.State)
+		}
+	}
+
+	return genericStreams(gadgetNamespace, params, traces, nil, nil, skipHistory)
 }
 
 // PrintTraceOutputFromStatus is used to print trace output using function
@@ -943,7 +1088,7 @@ func RunTraceStreamCallback(gadgetNamespace string, config *TraceConfig, callbac
 		return err
 	}
 
-	return genericStreams(config.GadgetNamespace, config.CommonFlags, traces, callback, nil)
+	return genericStreams(config.GadgetNamespace, config.CommonFlags, traces, callback, nil, false)
 }
 
 // RunTraceAndPrintStatusOutput creates a trace, prints its output and deletes
@@ -980,6 +1125,7 @@ func genericStreams(
 	results *gadgetv1alpha1.TraceList,
 	callback func(line string, node string),
 	transform func(line string) string,
+	skipHistory bool,
 ) error {
 	completion := make(chan string)
 
@@ -994,6 +1140,8 @@ func genericStreams(
 		verbose = true
 	}
 
+	maxEventsReached := make(chan struct{})
+
 	config := &PostProcessConfig{
 		Flows:     len(results.Items),
 		OutStream: os.Stdout,
@@ -1002,6 +1150,10 @@ func genericStreams(
 		Transform: transform,
 		Verbose:   verbose,
 	}
+	if params.MaxEvents > 0 {
+		config.MaxLines = uint64(params.MaxEvents)
+		config.OnMaxLines = func() { close(maxEventsReached) }
+	}
 
 	postProcess := NewPostProcess(config)
 
@@ -1012,11 +1164,17 @@ func genericStreams(
 		}
 		atomic.AddInt32(&streamCount, 1)
 		go func(nodeName, namespace, name string, index int) {
-			cmd := fmt.Sprintf("/bin/gadgettracermanager -call receive-stream -tracerid trace_%s_%s",
-				namespace, name)
+			tracerID := fmt.Sprintf("trace_%s_%s", namespace, name)
 			postProcess.OutStreams[index].Node = nodeName
-			err := ExecPod(client, nodeName, gadgetNamespace, cmd,
-				postProcess.OutStreams[index], postProcess.ErrStreams[index])
+
+			var err error
+			if params.StreamConnection == StreamConnectionPortForward {
+				err = receiveStreamPortForward(client, nodeName, gadgetNamespace, tracerID,
+					params, skipHistory, postProcess.OutStreams[index], postProcess.ErrStreams[index])
+			} else {
+				err = receiveStreamExec(client, nodeName, gadgetNamespace, tracerID,
+					params, skipHistory, postProcess.OutStreams[index], postProcess.ErrStreams[index])
+			}
 			if err == nil {
 				completion <- fmt.Sprintf("Trace completed on node %q", nodeName)
 			} else {
@@ -1043,6 +1201,8 @@ func genericStreams(
 			}
 		case <-exit:
 			return nil
+		case <-maxEventsReached:
+			return nil
 		}
 	}
 }
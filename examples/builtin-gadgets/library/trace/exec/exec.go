@@ -0,0 +1,96 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+
+	gadgetlibrary "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-library"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	dnsTracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/tracer"
+	dnsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+	execTracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/tracer"
+	execTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/types"
+)
+
+func main() {
+	var containerName string
+	flag.StringVar(&containerName, "containername", "", "Show only data from containers with that name")
+	flag.Parse()
+
+	// The library detects the containers and enriches the events with their
+	// metadata
+	lib, err := gadgetlibrary.New()
+	if err != nil {
+		fmt.Printf("failed to create library: %s\n", err)
+		return
+	}
+	defer lib.Close()
+
+	var traceOpts []gadgetlibrary.TraceOption
+	if containerName != "" {
+		traceOpts = append(traceOpts, gadgetlibrary.WithContainerName(containerName))
+	}
+
+	// Tracers filtering the events by mount namespace are created by a
+	// function receiving the mount namespace map and the enricher
+	execTrace, err := gadgetlibrary.Start(lib,
+		func(mountnsMap *ebpf.Map, enricher gadgets.DataEnricherByMntNs, cb func(*execTypes.Event)) (gadgetlibrary.Stopper, error) {
+			return execTracer.NewTracer(&execTracer.Config{MountnsMap: mountnsMap}, enricher, cb)
+		},
+		traceOpts...,
+	)
+	if err != nil {
+		fmt.Printf("failed to start exec tracer: %s\n", err)
+		return
+	}
+	defer execTrace.Stop()
+
+	// Network tracers are attached to the containers by the library
+	tracer, err := dnsTracer.NewTracer(&dnsTracer.Config{})
+	if err != nil {
+		fmt.Printf("failed to create dns tracer: %s\n", err)
+		return
+	}
+	dnsTrace, err := gadgetlibrary.StartNetwork[dnsTypes.Event](lib, tracer, traceOpts...)
+	if err != nil {
+		fmt.Printf("failed to start dns tracer: %s\n", err)
+		return
+	}
+	defer dnsTrace.Stop()
+
+	// Graceful shutdown
+	exit := make(chan os.Signal, 1)
+	signal.Notify(exit, syscall.SIGINT, syscall.SIGTERM)
+
+	for {
+		select {
+		case event := <-execTrace.Events():
+			fmt.Printf("A new %q process with pid %d was executed in container %q\n",
+				event.Comm, event.Pid, event.Runtime.ContainerName)
+		case event := <-dnsTrace.Events():
+			fmt.Printf("A dns %s about %s was observed in container %q\n",
+				event.Qr, event.DNSName, event.Runtime.ContainerName)
+		case <-exit:
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
+	gadgetbuilder "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-builder"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/k8sutil"
+)
+
+const (
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	defaultGadgetNamespace      = "gadget"
+)
+
+// startGadgetBuilder starts importing the gadgets built in-cluster if it's
+// enabled in the configuration. It returns nil otherwise.
+func startGadgetBuilder(node string) *gadgetbuilder.Importer {
+	if !config.Config.GetBool(gadgettracermanagerconfig.BuilderEnabledKey) {
+		return nil
+	}
+
+	clientset, err := k8sutil.NewClientset("")
+	if err != nil {
+		log.Errorf("Creating clientset for in-cluster builds: %v", err)
+		return nil
+	}
+
+	namespace := defaultGadgetNamespace
+	if ns, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	importer := gadgetbuilder.NewImporter(clientset, namespace, node)
+	importer.Start()
+	return importer
+}
@@ -31,6 +31,8 @@ import (
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	gadgetkinvolkiov1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/controllers"
 	gadgetcollection "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
@@ -54,10 +56,17 @@ func startController(node string, tracerManager *gadgettracermanager.GadgetTrace
 		}
 	}
 
+	// The metrics server exposes the metrics of traces with a "Metrics" sink.
+	// It's disabled with "0".
+	metricsAddress := config.Config.GetString(gadgettracermanagerconfig.TraceMetricsListenAddressKey)
+	if metricsAddress == "" {
+		metricsAddress = "0"
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
-			BindAddress: "0", // TCP port can be set to "0" to disable the metrics serving
+			BindAddress: metricsAddress,
 		},
 	})
 	if err != nil {
@@ -79,6 +88,15 @@ func startController(node string, tracerManager *gadgettracermanager.GadgetTrace
 		log.Errorf("unable to create trace controller: %s", err)
 		os.Exit(1)
 	}
+
+	if err = (&controllers.TraceScheduleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Node:   node,
+	}).SetupWithManager(mgr); err != nil {
+		log.Errorf("unable to create trace schedule controller: %s", err)
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
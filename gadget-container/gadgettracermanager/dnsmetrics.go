@@ -0,0 +1,45 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
+	dnsmetrics "github.com/inspektor-gadget/inspektor-gadget/pkg/dns-metrics"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
+)
+
+// startDNSMetrics starts collecting the DNS metrics if it's enabled in the
+// configuration. It returns nil otherwise. The metrics are served with the ones
+// of the traces.
+func startDNSMetrics(tracerManager *gadgettracermanager.GadgetTracerManager) *dnsmetrics.Collector {
+	if !config.Config.GetBool(gadgettracermanagerconfig.DNSMetricsEnabledKey) {
+		return nil
+	}
+
+	metricsAddress := config.Config.GetString(gadgettracermanagerconfig.TraceMetricsListenAddressKey)
+	if metricsAddress == "" || metricsAddress == "0" {
+		log.Warnf("DNS metrics enabled but %q is not set, they won't be served", gadgettracermanagerconfig.TraceMetricsListenAddressKey)
+	}
+
+	collector := dnsmetrics.New(&tracerManager.ContainerCollection)
+	if err := collector.Start(); err != nil {
+		log.Errorf("Starting DNS metrics: %v", err)
+		return nil
+	}
+	return collector
+}
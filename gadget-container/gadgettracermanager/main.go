@@ -32,6 +32,7 @@ import (
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health"
 	healthpb "google.golang.org/grpc/health/grpc_health_v1"
@@ -49,17 +50,25 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	ocihandler "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/oci-handler"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/trigger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/experimental"
 
 	// Blank import for some operators
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/btfgen"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/evictionrisk"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/exechash"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/fileprovenance"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/filter"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/formatters"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/kubeipresolver"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/kubemanager"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/kubevolume"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/limiter"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/mesh"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/otel-metrics"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/otel-traces"
+	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/redactor"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/socketenricher"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sort"
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/uidgidresolver"
@@ -69,8 +78,10 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
 	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+	gadgetstream "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/gadgettracermanagerloglevel"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+	gadgettls "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/tls"
 )
 
 var (
@@ -85,6 +96,8 @@ var (
 	method              string
 	label               string
 	tracerid            string
+	lowLatency          bool
+	skipHistory         bool
 	containerID         string
 	namespace           string
 	podname             string
@@ -105,6 +118,8 @@ func init() {
 	flag.StringVar(&method, "call", "", "Call a method (add-tracer, remove-tracer, receive-stream, add-container, remove-container)")
 	flag.StringVar(&label, "label", "", "key=value,key=value labels to use in add-tracer")
 	flag.StringVar(&tracerid, "tracerid", "", "tracerid to use in receive-stream")
+	flag.BoolVar(&lowLatency, "low-latency", false, "Write each line in receive-stream right away instead of batching them")
+	flag.BoolVar(&skipHistory, "skip-history", false, "Only receive the lines published after calling receive-stream")
 	flag.StringVar(&containerID, "containerid", "", "container id to use in add-container or remove-container")
 	flag.StringVar(&namespace, "namespace", "", "namespace to use in add-container")
 	flag.StringVar(&podname, "podname", "", "podname to use in add-container")
@@ -174,20 +189,34 @@ func main() {
 
 	case "receive-stream":
 		stream, err := client.ReceiveStream(context.Background(), &pb.TracerID{
-			Id: tracerid,
+			Id:          tracerid,
+			SkipHistory: skipHistory,
 		})
 		if err != nil {
 			log.Fatalf("%v", err)
 		}
+
+		// Lines are written in batches unless low latency is requested
+		interval, size := gadgetstream.DefaultBatchInterval, gadgetstream.DefaultBatchSize
+		if lowLatency {
+			interval, size = 0, 1
+		}
+		writer := gadgetstream.NewBatchWriter(os.Stdout, interval, size)
 		for {
 			line, err := stream.Recv()
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			if err != nil {
+				writer.Flush()
 				log.Fatalf("%v.ReceiveStream(_) = _, %v", client, err)
 			}
-			fmt.Println(line.Line)
+			if err := writer.WriteLine(line.Line); err != nil {
+				log.Fatalf("writing stream: %v", err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			log.Fatalf("writing stream: %v", err)
 		}
 
 		os.Exit(0)
@@ -320,6 +349,8 @@ func main() {
 			log.Fatalf("Environment variable NODE_NAME not set")
 		}
 
+		stopSelfTracing := startSelfTracing(node)
+
 		lis, err := net.Listen("unix", socketfile)
 		if err != nil {
 			log.Fatalf("failed to listen: %v", err)
@@ -351,6 +382,11 @@ func main() {
 			go startController(node, tracerManager)
 		}
 
+		profiler := startContinuousProfiler(tracerManager)
+		dnsMetrics := startDNSMetrics(tracerManager)
+		builder := startGadgetBuilder(node)
+		streamService := startStreamService(tracerManager)
+
 		stringBufferLength := config.Config.GetString(gadgettracermanagerconfig.EventsBufferLengthKey)
 		if stringBufferLength == "" {
 			log.Warnf("EVENTS_BUFFER_LENGTH is deprecated. Use %q instead in configmap", gadgettracermanagerconfig.EventsBufferLengthKey)
@@ -379,16 +415,38 @@ func main() {
 
 		service.SetStore(store)
 		service.SetInstanceManager(mgr)
+		trigger.SetRunner(mgr)
 
 		socketType, socketPath, err := api.ParseSocketAddress(gadgetServiceHost)
 		if err != nil {
 			log.Fatalf("invalid service host: %v", err)
 		}
+
+		var serviceOpts []grpc.ServerOption
+		tlsCert := config.Config.GetString(gadgettracermanagerconfig.TLSCertFileKey)
+		tlsKey := config.Config.GetString(gadgettracermanagerconfig.TLSKeyFileKey)
+		tlsClientCA := config.Config.GetString(gadgettracermanagerconfig.TLSClientCAFileKey)
+		if tlsCert != "" || tlsKey != "" || tlsClientCA != "" {
+			if tlsCert == "" || tlsKey == "" || tlsClientCA == "" {
+				log.Fatalf("%s, %s and %s must be set at the same time to enable mTLS",
+					gadgettracermanagerconfig.TLSCertFileKey,
+					gadgettracermanagerconfig.TLSKeyFileKey,
+					gadgettracermanagerconfig.TLSClientCAFileKey)
+			}
+			reloader, err := gadgettls.NewReloader(tlsCert, tlsKey, tlsClientCA)
+			if err != nil {
+				log.Fatalf("loading TLS configuration: %v", err)
+			}
+			serviceOpts = append(serviceOpts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig())))
+			log.Infof("mTLS enabled for the gadget service using %s, %s and %s", tlsCert, tlsKey, tlsClientCA)
+		}
+
 		go func() {
 			err := service.Run(gadgetservice.RunConfig{
-				SocketType: socketType,
-				SocketPath: socketPath,
-			})
+				SocketType:      socketType,
+				SocketPath:      socketPath,
+				PrefetchGadgets: config.Config.GetStringSlice(gadgettracermanagerconfig.PrefetchGadgetsKey),
+			}, serviceOpts...)
 			if err != nil {
 				log.Fatalf("starting gadget service: %v", err)
 			}
@@ -399,6 +457,19 @@ func main() {
 		<-exitSignal
 
 		service.Close()
+		if profiler != nil {
+			profiler.Stop()
+		}
+		if dnsMetrics != nil {
+			dnsMetrics.Stop()
+		}
+		if builder != nil {
+			builder.Stop()
+		}
+		if streamService != nil {
+			streamService.Stop()
+		}
 		tracerManager.Close()
+		stopSelfTracing()
 	}
 }
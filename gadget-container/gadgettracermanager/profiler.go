@@ -0,0 +1,60 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
+	continuousprofiler "github.com/inspektor-gadget/inspektor-gadget/pkg/continuous-profiler"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
+	profileexporter "github.com/inspektor-gadget/inspektor-gadget/pkg/profile-exporter"
+)
+
+const defaultContinuousProfilingAppName = "inspektor-gadget"
+
+// startContinuousProfiler starts the continuous profiler if it's enabled in the
+// configuration. It returns nil otherwise.
+func startContinuousProfiler(tracerManager *gadgettracermanager.GadgetTracerManager) *continuousprofiler.Profiler {
+	if !config.Config.GetBool(gadgettracermanagerconfig.ContinuousProfilingEnabledKey) {
+		return nil
+	}
+
+	pushURL := config.Config.GetString(gadgettracermanagerconfig.ContinuousProfilingPushURLKey)
+	if pushURL == "" {
+		log.Errorf("Continuous profiling enabled but %q is not set", gadgettracermanagerconfig.ContinuousProfilingPushURLKey)
+		return nil
+	}
+
+	appName := config.Config.GetString(gadgettracermanagerconfig.ContinuousProfilingAppNameKey)
+	if appName == "" {
+		appName = defaultContinuousProfilingAppName
+	}
+
+	exporter, err := profileexporter.New(pushURL, appName)
+	if err != nil {
+		log.Errorf("Creating continuous profiling exporter: %v", err)
+		return nil
+	}
+
+	profiler := continuousprofiler.New(&tracerManager.ContainerCollection, exporter, continuousprofiler.Config{
+		SampleFreq:         config.Config.GetUint64(gadgettracermanagerconfig.ContinuousProfilingFrequencyKey),
+		ContainersPerRound: config.Config.GetInt(gadgettracermanagerconfig.ContinuousProfilingContainersPerRoundKey),
+		RoundDuration:      config.Config.GetDuration(gadgettracermanagerconfig.ContinuousProfilingRoundDurationKey),
+	})
+	profiler.Start()
+	return profiler
+}
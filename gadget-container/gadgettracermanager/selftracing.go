@@ -0,0 +1,65 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/selftracing"
+)
+
+const (
+	selfTracingShutdownTimeout = 5 * time.Second
+	defaultSelfTracingRatio    = 1.0
+)
+
+// startSelfTracing exports the spans of the operations of the gadget pod if
+// an endpoint is set in the configuration. It returns a function stopping it.
+func startSelfTracing(node string) func() {
+	endpoint := config.Config.GetString(gadgettracermanagerconfig.SelfTracingEndpointKey)
+	if endpoint == "" {
+		return func() {}
+	}
+
+	sampleRatio := defaultSelfTracingRatio
+	if config.Config.IsSet(gadgettracermanagerconfig.SelfTracingSampleRatioKey) {
+		sampleRatio = config.Config.GetFloat64(gadgettracermanagerconfig.SelfTracingSampleRatioKey)
+	}
+
+	shutdown, err := selftracing.Start(context.Background(), selftracing.Config{
+		Endpoint:    endpoint,
+		Insecure:    config.Config.GetBool(gadgettracermanagerconfig.SelfTracingInsecureKey),
+		SampleRatio: sampleRatio,
+		NodeName:    node,
+	})
+	if err != nil {
+		log.Errorf("Starting self-tracing: %v", err)
+		return func() {}
+	}
+	log.Infof("Exporting self-tracing spans to %s", endpoint)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), selfTracingShutdownTimeout)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Warnf("Flushing self-tracing spans: %v", err)
+		}
+	}
+}
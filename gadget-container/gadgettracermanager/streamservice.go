@@ -0,0 +1,99 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config/gadgettracermanagerconfig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
+	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+	gadgettls "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/tls"
+)
+
+// streamServer only exposes ReceiveStream: the other methods change the state
+// of the node and are only available on the local socket.
+type streamServer struct {
+	pb.UnimplementedGadgetTracerManagerServer
+	tracerManager *gadgettracermanager.GadgetTracerManager
+}
+
+func (s *streamServer) ReceiveStream(tracerID *pb.TracerID, stream pb.GadgetTracerManager_ReceiveStreamServer) error {
+	return s.tracerManager.ReceiveStream(tracerID, stream)
+}
+
+// startStreamService serves the streams of the traces on the address set in
+// the configuration, so clients can receive them through a port-forward or a
+// service. Clients must authenticate with a certificate signed by the client
+// CA, the service isn't started if mTLS isn't configured. It returns nil if
+// the service is disabled.
+func startStreamService(tracerManager *gadgettracermanager.GadgetTracerManager) *grpc.Server {
+	address := config.Config.GetString(gadgettracermanagerconfig.StreamServiceListenAddressKey)
+	if address == "" || address == "0" {
+		return nil
+	}
+
+	tlsCert := config.Config.GetString(gadgettracermanagerconfig.TLSCertFileKey)
+	tlsKey := config.Config.GetString(gadgettracermanagerconfig.TLSKeyFileKey)
+	tlsClientCA := config.Config.GetString(gadgettracermanagerconfig.TLSClientCAFileKey)
+	if tlsCert == "" || tlsKey == "" || tlsClientCA == "" {
+		log.Errorf("Stream service requires mTLS: %s, %s and %s must be set",
+			gadgettracermanagerconfig.TLSCertFileKey,
+			gadgettracermanagerconfig.TLSKeyFileKey,
+			gadgettracermanagerconfig.TLSClientCAFileKey)
+		return nil
+	}
+	reloader, err := gadgettls.NewReloader(tlsCert, tlsKey, tlsClientCA)
+	if err != nil {
+		log.Errorf("Loading TLS configuration of the stream service: %v", err)
+		return nil
+	}
+
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		log.Errorf("Starting stream service: %v", err)
+		return nil
+	}
+
+	// Flow control is the one of HTTP/2: a slow client blocks the sending of
+	// its stream only, and the lines it can't keep up with are dropped and
+	// reported as lost by the tracer stream. Keepalives detect the clients
+	// that went away without closing the connection, e.g. when the
+	// port-forward breaks.
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(reloader.ServerConfig())),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	pb.RegisterGadgetTracerManagerServer(grpcServer, &streamServer{tracerManager: tracerManager})
+
+	log.Infof("Serving the streams of the traces on %s", address)
+	go grpcServer.Serve(lis)
+
+	return grpcServer
+}
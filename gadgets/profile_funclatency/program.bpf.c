@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/bits.bpf.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#ifndef PROFILER_MAX_SLOTS
+#define PROFILER_MAX_SLOTS 27
+#endif /* !PROFILER_MAX_SLOTS */
+
+#define MAX_ENTRIES 10240
+
+const volatile bool targ_ms = false;
+GADGET_PARAM(targ_ms);
+
+const volatile pid_t targ_pid = 0;
+GADGET_PARAM(targ_pid);
+
+struct hist_key {
+	gadget_mntns_id mntns_id;
+};
+
+struct hist_value {
+	gadget_counter__u64 calls;
+	gadget_histogram_slot__u32 latency[PROFILER_MAX_SLOTS];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct hist_key);
+	__type(value, struct hist_value);
+} hists SEC(".maps");
+
+GADGET_MAPITER(funclatency, hists);
+
+/* start timestamp of the calls in progress, by thread */
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, u32); // tid
+	__type(value, u64);
+} start SEC(".maps");
+
+static struct hist_value initial_hist;
+
+/*
+ * The section names are placeholders: the actual function is given by the
+ * user with the --function parameter, see params.attach in gadget.yaml.
+ */
+SEC("uprobe/libc:getpid")
+int ig_funclat_entry(struct pt_regs *ctx)
+{
+	u64 pid_tgid = bpf_get_current_pid_tgid();
+	u32 tid = (u32)pid_tgid;
+	u64 ts;
+
+	if (targ_pid && targ_pid != pid_tgid >> 32)
+		return 0;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	ts = bpf_ktime_get_ns();
+	bpf_map_update_elem(&start, &tid, &ts, BPF_ANY);
+	return 0;
+}
+
+SEC("uretprobe/libc:getpid")
+int ig_funclat_exit(struct pt_regs *ctx)
+{
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct hist_key hkey = {};
+	struct hist_value *histp;
+	u64 slot, *tsp;
+	s64 delta;
+
+	tsp = bpf_map_lookup_elem(&start, &tid);
+	if (!tsp)
+		return 0;
+	delta = (s64)(bpf_ktime_get_ns() - *tsp);
+	bpf_map_delete_elem(&start, &tid);
+	if (delta < 0)
+		return 0;
+
+	hkey.mntns_id = gadget_get_mntns_id();
+	histp = bpf_map_lookup_elem(&hists, &hkey);
+	if (!histp) {
+		bpf_map_update_elem(&hists, &hkey, &initial_hist, BPF_NOEXIST);
+		histp = bpf_map_lookup_elem(&hists, &hkey);
+		if (!histp)
+			return 0;
+	}
+
+	if (targ_ms)
+		delta /= 1000000U;
+	else
+		delta /= 1000U;
+	slot = log2l(delta);
+	if (slot >= PROFILER_MAX_SLOTS)
+		slot = PROFILER_MAX_SLOTS - 1;
+	__sync_fetch_and_add(&histp->latency[slot], 1);
+	__sync_fetch_and_add(&histp->calls, 1);
+
+	return 0;
+}
+
+/*
+ * Threads exiting between the uprobe and the uretprobe would leave entries
+ * behind
+ */
+SEC("tracepoint/sched/sched_process_exit")
+int ig_funclat_sched_exit(void *ctx)
+{
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+
+	bpf_map_delete_elem(&start, &tid);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,123 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type profileFuncLatencyEntry struct {
+	eventtypes.CommonData
+
+	MntNsID uint64   `json:"mntns_id"`
+	Calls   uint64   `json:"calls"`
+	Latency []uint32 `json:"latency"`
+}
+
+func TestProfileFuncLatency(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-profile-funclatency"
+	// busybox is static, use an image whose tools are linked against libc
+	containerImage := "docker.io/library/debian:bookworm-slim"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-profile-funclatency")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// Each execution of sleep calls setlocale() once
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		"while true; do sleep 0.1; done",
+		containerOpts...,
+	)
+
+	testContainer.Start(t)
+	t.Cleanup(func() {
+		testContainer.Stop(t)
+	})
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(testContainer.ID()),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts,
+			igrunner.WithFlags(
+				fmt.Sprintf("-r=%s", utils.Runtime),
+				fmt.Sprintf("-c=%s", containerName),
+			),
+		)
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithFlags("--function=libc:setlocale", "--map-fetch-interval=1s", "--timeout=5"),
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntry := &profileFuncLatencyEntry{
+					CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+
+					// Check the existence of the following fields
+					MntNsID: utils.NormalizedInt,
+					Calls:   utils.NormalizedInt,
+
+					// The slots used depend on the latency of the calls
+					Latency: nil,
+				}
+
+				normalize := func(e *profileFuncLatencyEntry) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeInt(&e.MntNsID)
+					utils.NormalizeInt(&e.Calls)
+					e.Latency = nil
+				}
+
+				match.MatchEntries(t, match.JSONMultiArrayMode, output, normalize, expectedEntry)
+			},
+		),
+	)
+
+	profileFuncLatencyCmd := igrunner.New("profile_funclatency", runnerOpts...)
+
+	igtesting.RunTestSteps([]igtesting.TestStep{profileFuncLatencyCmd}, t, testingOpts...)
+}
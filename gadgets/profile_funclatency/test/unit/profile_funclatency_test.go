@@ -0,0 +1,136 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+)
+
+type ExpectedProfileFuncLatencyEvent struct {
+	MntNsID uint64   `json:"mntns_id"`
+	Calls   uint64   `json:"calls"`
+	Latency []uint32 `json:"latency"`
+}
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	pid            string
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+	validateEvent  func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []ExpectedProfileFuncLatencyEvent)
+}
+
+const (
+	// setlocale() is called once by each execution of sleep
+	function = "libc:setlocale"
+	runs     = 3
+)
+
+func TestProfileFuncLatencyGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+	runnerConfig := &utilstest.RunnerConfig{}
+
+	testCases := map[string]testDef{
+		"captures_calls": {
+			runnerConfig: runnerConfig,
+			pid:          "0",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []ExpectedProfileFuncLatencyEvent) {
+				// The map is cleared each time it's fetched, add up all the
+				// histograms of the mount namespace of the runner
+				var calls, slots uint64
+				for _, event := range events {
+					require.Equal(t, info.MountNsID, event.MntNsID, "mntns_id of the event")
+					calls += event.Calls
+					for _, slot := range event.Latency {
+						slots += uint64(slot)
+					}
+				}
+				require.GreaterOrEqual(t, calls, uint64(runs), "number of calls")
+				require.Equal(t, calls, slots, "every call is in a slot of the histogram")
+			},
+		},
+		"ignores_other_processes": {
+			runnerConfig: runnerConfig,
+			// PID 1 doesn't run in the mount namespace of the runner
+			pid: "1",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectNoEvent[ExpectedProfileFuncLatencyEvent, int],
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.map-fetch-interval": "1000ms",
+				"operator.oci.ebpf.function":           function,
+				"operator.oci.ebpf.pid":                testCase.pid,
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				// Uprobes are only attached to the libraries of containers
+				err := gadgetrunner.AttachContainer(gadgetCtx, "test-profile-funclatency",
+					runner.Info.Pid, runner.Info.MountNsID)
+				if err != nil {
+					return err
+				}
+				utilstest.RunWithRunner(t, runner, generateEvent)
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedProfileFuncLatencyEvent]{
+				Image:          "profile_funclatency",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, 0, gadgetRunner.CapturedEvents)
+		})
+	}
+}
+
+// generateEvent runs sleep several times from the runner, so the processes
+// are created in its mount namespace
+func generateEvent() error {
+	for i := 0; i < runs; i++ {
+		if err := exec.Command("/bin/sleep", "0").Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
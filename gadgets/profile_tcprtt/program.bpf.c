@@ -28,6 +28,7 @@ const volatile __u32 targ_daddr = 0;
 const volatile __u8 targ_saddr_v6[IPV6_LEN] = {};
 const volatile __u8 targ_daddr_v6[IPV6_LEN] = {};
 const volatile bool targ_ms = false;
+const volatile bool targ_by_raddr = false;
 
 GADGET_PARAM(targ_sport);
 GADGET_PARAM(targ_dport);
@@ -46,25 +47,22 @@ GADGET_PARAM(targ_dport);
  */
 
 GADGET_PARAM(targ_ms);
+GADGET_PARAM(targ_by_raddr);
 
 #define MAX_ENTRIES 10240
 
 struct hist_key {
-	u8 unused;
 	/*
-	 * TODO We do not support having array of bytes in the key structure.
-	 * So, for now, we will not enable grouping histogram by local or remote
-	 * address.
-	 * Later on, we can add back these two fields which are present in the
-	 * built-in version:
-	 * __u16 family;
-	 * __u8 addr[IPV6_LEN];
+	 * Remote address of the connections, only set with targ_by_raddr.
+	 * Otherwise, all the connections share the same histogram.
 	 */
+	struct gadget_l3endpoint_t raddr;
 };
 
 // hist_value is used as value for profiler hash map.
 struct hist_value {
 	gadget_histogram_slot__u32 latency[PROFILER_MAX_SLOTS];
+	gadget_counter__u64 retransmits;
 };
 
 struct {
@@ -103,37 +101,50 @@ static bool inline ipv6_are_different(const volatile __u8 a[IPV6_LEN],
 	return false;
 }
 
-static int handle_tcp_rcv_established(struct sock *sk)
+static void fill_key(struct hist_key *key, const struct sock *sk, u16 family)
+{
+	if (!targ_by_raddr)
+		return;
+
+	if (family == AF_INET) {
+		key->raddr.version = 4;
+		key->raddr.addr_raw.v4 =
+			BPF_CORE_READ(sk, __sk_common.skc_daddr);
+	} else {
+		key->raddr.version = 6;
+		BPF_CORE_READ_INTO(&key->raddr.addr_raw.v6, sk,
+				   __sk_common.skc_v6_daddr.in6_u.u6_addr32);
+	}
+}
+
+/*
+ * filter_sock returns true when the connection matches the ports and
+ * addresses given by the user.
+ */
+static bool filter_sock(const struct sock *sk, u16 family)
 {
 	const struct inet_sock *inet = (struct inet_sock *)(sk);
-	struct tcp_sock *ts;
-	struct hist_value *histp;
-	struct hist_key key = {};
-	u64 slot;
-	u32 srtt;
-	u16 family;
 
 	if (targ_sport && targ_sport != BPF_CORE_READ(inet, inet_sport))
-		return 0;
+		return false;
 
 	if (targ_dport &&
 	    targ_dport != BPF_CORE_READ(sk, __sk_common.skc_dport))
-		return 0;
+		return false;
 
-	family = BPF_CORE_READ(sk, __sk_common.skc_family);
 	switch (family) {
 	case AF_INET:
 		/* If we set any of IPv6 address, we do not care about IPv4 ones. */
 		if (ipv6_is_not_zero(targ_saddr_v6) ||
 		    ipv6_is_not_zero(targ_daddr_v6))
-			return 0;
+			return false;
 
 		if (targ_saddr && targ_saddr != BPF_CORE_READ(inet, inet_saddr))
-			return 0;
+			return false;
 
 		if (targ_daddr &&
 		    targ_daddr != BPF_CORE_READ(sk, __sk_common.skc_daddr))
-			return 0;
+			return false;
 
 		break;
 	case AF_INET6:
@@ -142,13 +153,13 @@ static int handle_tcp_rcv_established(struct sock *sk)
 		 * about IPv6 ones.
 		 */
 		if (targ_saddr || targ_daddr)
-			return 0;
+			return false;
 
 		if (ipv6_is_not_zero(targ_saddr_v6) &&
 		    ipv6_are_different(targ_saddr_v6,
 				       BPF_CORE_READ(inet, pinet6,
 						     saddr.in6_u.u6_addr8)))
-			return 0;
+			return false;
 
 		if (ipv6_is_not_zero(targ_daddr_v6) &&
 		    ipv6_are_different(
@@ -156,13 +167,30 @@ static int handle_tcp_rcv_established(struct sock *sk)
 			    BPF_CORE_READ(
 				    sk,
 				    __sk_common.skc_v6_daddr.in6_u.u6_addr8)))
-			return 0;
+			return false;
 
 		break;
 	default:
-		return 0;
+		return false;
 	}
 
+	return true;
+}
+
+static int handle_tcp_rcv_established(struct sock *sk)
+{
+	struct tcp_sock *ts;
+	struct hist_value *histp;
+	struct hist_key key = {};
+	u64 slot;
+	u32 srtt;
+	u16 family;
+
+	family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	if (!filter_sock(sk, family))
+		return 0;
+
+	fill_key(&key, sk, family);
 	histp = bpf_map_lookup_or_try_init(&hists, &key, &zero);
 	if (!histp)
 		return 0;
@@ -177,10 +205,34 @@ static int handle_tcp_rcv_established(struct sock *sk)
 	return 0;
 }
 
+static int handle_tcp_retransmit(const struct sock *sk)
+{
+	struct hist_value *histp;
+	struct hist_key key = {};
+	u16 family;
+
+	family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	if (!filter_sock(sk, family))
+		return 0;
+
+	fill_key(&key, sk, family);
+	histp = bpf_map_lookup_or_try_init(&hists, &key, &zero);
+	if (!histp)
+		return 0;
+	__sync_fetch_and_add(&histp->retransmits, 1);
+	return 0;
+}
+
 SEC("kprobe/tcp_rcv_established")
 int BPF_KPROBE(ig_tcprcvest_kp, struct sock *sk)
 {
 	return handle_tcp_rcv_established(sk);
 }
 
+SEC("tracepoint/tcp/tcp_retransmit_skb")
+int ig_tcprtt_retrans(struct trace_event_raw_tcp_event_sk_skb *ctx)
+{
+	return handle_tcp_retransmit(ctx->skaddr);
+}
+
 char LICENSE[] SEC("license") = "GPL";
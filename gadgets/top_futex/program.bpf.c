@@ -0,0 +1,167 @@
+/* SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause) */
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/common.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/macros.h>
+#include <gadget/user_stack_map.h>
+
+#define MAX_ENTRIES 10240
+
+// Futex operations, see include/uapi/linux/futex.h
+#define FUTEX_WAIT 0
+#define FUTEX_LOCK_PI 6
+#define FUTEX_WAIT_BITSET 9
+#define FUTEX_WAIT_REQUEUE_PI 11
+#define FUTEX_LOCK_PI2 13
+#define FUTEX_PRIVATE_FLAG 128
+#define FUTEX_CLOCK_REALTIME 256
+#define FUTEX_CMD_MASK ~(FUTEX_PRIVATE_FLAG | FUTEX_CLOCK_REALTIME)
+
+#define EAGAIN 11
+
+struct futex_key {
+	gadget_mntns_id mntns_id;
+	__u32 __tgid;
+	// -1 when the stacks aren't collected, so the waits are aggregated by
+	// address only
+	gadget_user_stack ustack_raw;
+	__u64 uaddr;
+};
+
+struct futex_stats {
+	// First process that waited on the futex with this stack
+	struct gadget_process proc;
+	__u64 waits;
+	__u64 total_wait_us;
+	__u64 max_wait_us;
+};
+
+struct futex_wait {
+	__u64 ts;
+	__u64 uaddr;
+	gadget_user_stack ustack;
+};
+
+const volatile pid_t target_pid = 0;
+GADGET_PARAM(target_pid);
+
+const volatile bool collect_stack = false;
+GADGET_PARAM(collect_stack);
+
+const volatile __u64 min_lat_us = 0;
+GADGET_PARAM(min_lat_us);
+
+static const struct futex_stats zero_value = {};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct futex_key);
+	__type(value, struct futex_stats);
+} stats SEC(".maps");
+
+GADGET_MAPITER(futex, stats);
+
+// Waits in progress, by thread id
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u32);
+	__type(value, struct futex_wait);
+} start SEC(".maps");
+
+static __always_inline bool is_wait_op(int op)
+{
+	switch (op & FUTEX_CMD_MASK) {
+	case FUTEX_WAIT:
+	case FUTEX_LOCK_PI:
+	case FUTEX_WAIT_BITSET:
+	case FUTEX_WAIT_REQUEUE_PI:
+	case FUTEX_LOCK_PI2:
+		return true;
+	default:
+		return false;
+	}
+}
+
+SEC("tracepoint/syscalls/sys_enter_futex")
+int ig_futex_e(struct syscall_trace_enter *ctx)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u32 pid = pid_tgid >> 32;
+	__u32 tid = (__u32)pid_tgid;
+	struct futex_wait wait = {};
+
+	if (!is_wait_op((int)ctx->args[1]))
+		return 0;
+
+	if (target_pid && target_pid != pid)
+		return 0;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	wait.ts = bpf_ktime_get_ns();
+	wait.uaddr = ctx->args[0];
+	wait.ustack = collect_stack ? gadget_get_user_stack(ctx) : -1;
+
+	bpf_map_update_elem(&start, &tid, &wait, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_exit_futex")
+int ig_futex_x(struct syscall_trace_exit *ctx)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u32 tid = (__u32)pid_tgid;
+	struct futex_key key = {};
+	struct futex_stats *valuep;
+	struct futex_wait *wait;
+	__u64 delta_us;
+
+	wait = bpf_map_lookup_elem(&start, &tid);
+	if (!wait)
+		return 0;
+
+	// The value of the futex changed before the thread went to sleep: there
+	// was no contention
+	if (ctx->ret == -EAGAIN)
+		goto cleanup;
+
+	delta_us = (bpf_ktime_get_ns() - wait->ts) / 1000;
+	if (delta_us < min_lat_us)
+		goto cleanup;
+
+	key.mntns_id = gadget_get_mntns_id();
+	key.__tgid = pid_tgid >> 32;
+	key.ustack_raw = wait->ustack;
+	key.uaddr = wait->uaddr;
+
+	valuep = bpf_map_lookup_elem(&stats, &key);
+	if (!valuep) {
+		bpf_map_update_elem(&stats, &key, &zero_value, BPF_NOEXIST);
+		valuep = bpf_map_lookup_elem(&stats, &key);
+		if (!valuep)
+			goto cleanup;
+
+		gadget_process_populate(&valuep->proc);
+	}
+
+	__sync_fetch_and_add(&valuep->waits, 1);
+	__sync_fetch_and_add(&valuep->total_wait_us, delta_us);
+	if (delta_us > valuep->max_wait_us)
+		valuep->max_wait_us = delta_us;
+
+cleanup:
+	bpf_map_delete_elem(&start, &tid);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -0,0 +1,155 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"golang.org/x/sys/unix"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTopFutexEvent struct {
+	Proc        ebpftypes.Process `json:"proc"`
+	Uaddr       uint64            `json:"uaddr"`
+	Waits       uint64            `json:"waits"`
+	TotalWaitUs uint64            `json:"total_wait_us"`
+	MaxWaitUs   uint64            `json:"max_wait_us"`
+}
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	minLatUs       string
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+	validateEvent  func(t *testing.T, info *utilstest.RunnerInfo, uaddr uint64, events []ExpectedTopFutexEvent)
+}
+
+const (
+	waits       = 3
+	waitTimeout = 20 * time.Millisecond
+
+	// See include/uapi/linux/futex.h
+	futexWait        = 0
+	futexPrivateFlag = 128
+)
+
+func TestTopFutexGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+	runnerConfig := &utilstest.RunnerConfig{}
+
+	testCases := map[string]testDef{
+		"captures_waits": {
+			runnerConfig: runnerConfig,
+			minLatUs:     "0",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, uaddr uint64, events []ExpectedTopFutexEvent) {
+				utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, pid int) *ExpectedTopFutexEvent {
+					return &ExpectedTopFutexEvent{
+						Proc:        info.Proc,
+						Uaddr:       uaddr,
+						Waits:       waits,
+						TotalWaitUs: utils.NormalizedInt,
+						MaxWaitUs:   utils.NormalizedInt,
+					}
+				})(t, info, 0, events)
+			},
+		},
+		"ignores_short_waits": {
+			runnerConfig: runnerConfig,
+			minLatUs:     "1000000",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, uaddr uint64, events []ExpectedTopFutexEvent) {
+				utilstest.ExpectNoEvent(t, info, uaddr, events)
+			},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var uaddr uint64
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.map-fetch-interval": "1000ms",
+				"operator.oci.ebpf.min":                testCase.minLatUs,
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			normalizeEvent := func(event *ExpectedTopFutexEvent) {
+				utils.NormalizeProc(&event.Proc)
+				utils.NormalizeInt(&event.TotalWaitUs)
+				utils.NormalizeInt(&event.MaxWaitUs)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				utilstest.RunWithRunner(t, runner, func() error {
+					var err error
+					uaddr, err = generateEvent()
+					return err
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTopFutexEvent]{
+				Image:          "top_futex",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, uaddr, gadgetRunner.CapturedEvents)
+		})
+	}
+}
+
+// generateEvent waits several times on a futex nobody wakes up, until the
+// timeout expires, and returns its address
+func generateEvent() (uint64, error) {
+	futex := new(uint32)
+	timeout := unix.NsecToTimespec(waitTimeout.Nanoseconds())
+
+	for i := 0; i < waits; i++ {
+		_, _, errno := unix.Syscall6(unix.SYS_FUTEX,
+			uintptr(unsafe.Pointer(futex)),
+			futexWait|futexPrivateFlag,
+			0, // the value of the futex, so the thread sleeps
+			uintptr(unsafe.Pointer(&timeout)),
+			0, 0)
+		if !errors.Is(errno, unix.ETIMEDOUT) {
+			return 0, errno
+		}
+	}
+	return uint64(uintptr(unsafe.Pointer(futex))), nil
+}
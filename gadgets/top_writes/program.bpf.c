@@ -0,0 +1,135 @@
+/* SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause) */
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/common.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/filesystem.h>
+#include <gadget/types.h>
+#include <gadget/macros.h>
+
+#define PREFIX_LEN 128
+#define FILE_LEN 256
+#define MAX_ENTRIES 10240
+
+#define S_IFMT 00170000
+#define S_IFREG 0100000
+#define S_ISREG(m) (((m) & S_IFMT) == S_IFREG)
+
+struct prefix_key {
+	gadget_mntns_id mntns_id;
+	char prefix[PREFIX_LEN];
+};
+
+struct write_stats {
+	__u64 writes;
+	__u64 bytes;
+	// Last process and file that wrote under the prefix
+	gadget_pid pid;
+	gadget_comm comm[TASK_COMM_LEN];
+	char file[FILE_LEN];
+};
+
+const volatile pid_t target_pid = 0;
+GADGET_PARAM(target_pid);
+
+const volatile __u32 prefix_depth = 3;
+GADGET_PARAM(prefix_depth);
+
+static const struct write_stats zero_value = {};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct prefix_key);
+	__type(value, struct write_stats);
+} stats SEC(".maps");
+
+GADGET_MAPITER(writes, stats);
+
+// The full path doesn't fit in the stack
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, char[MAX_STRING_SIZE]);
+} paths SEC(".maps");
+
+// path_prefix copies the first prefix_depth components of path to prefix. A
+// depth of 0 keeps the whole path, truncated to PREFIX_LEN.
+static __always_inline void path_prefix(char *prefix, const char *path)
+{
+	__u32 slashes = 0;
+
+	for (int i = 0; i < PREFIX_LEN - 1; i++) {
+		char c = path[i];
+
+		if (c == '\0')
+			break;
+		if (c == '/' && i > 0 && prefix_depth &&
+		    ++slashes >= prefix_depth)
+			break;
+		prefix[i] = c;
+	}
+}
+
+SEC("kprobe/vfs_write")
+int BPF_KPROBE(ig_topwr_e, struct file *file, const char *buf, size_t count,
+	       loff_t *pos)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u32 pid = pid_tgid >> 32;
+	struct prefix_key key = {};
+	struct write_stats *valuep;
+	struct path f_path;
+	char *c_path;
+	char *path;
+	__u32 zero = 0;
+	int mode;
+
+	if (target_pid && target_pid != pid)
+		return 0;
+
+	key.mntns_id = gadget_get_mntns_id();
+	if (gadget_should_discard_mntns_id(key.mntns_id))
+		return 0;
+
+	// Only writes to regular files can fill up the disk
+	mode = BPF_CORE_READ(file, f_inode, i_mode);
+	if (!S_ISREG(mode))
+		return 0;
+
+	path = bpf_map_lookup_elem(&paths, &zero);
+	if (!path)
+		return 0;
+
+	f_path = BPF_CORE_READ(file, f_path);
+	c_path = get_path_str(&f_path);
+	if (!c_path)
+		return 0;
+	bpf_probe_read_kernel_str(path, MAX_STRING_SIZE, c_path);
+
+	path_prefix(key.prefix, path);
+
+	valuep = bpf_map_lookup_elem(&stats, &key);
+	if (!valuep) {
+		bpf_map_update_elem(&stats, &key, &zero_value, BPF_NOEXIST);
+		valuep = bpf_map_lookup_elem(&stats, &key);
+		if (!valuep)
+			return 0;
+	}
+
+	__sync_fetch_and_add(&valuep->writes, 1);
+	__sync_fetch_and_add(&valuep->bytes, count);
+	valuep->pid = pid;
+	bpf_get_current_comm(valuep->comm, sizeof(valuep->comm));
+	bpf_probe_read_kernel_str(valuep->file, sizeof(valuep->file), path);
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
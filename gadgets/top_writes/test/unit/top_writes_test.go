@@ -0,0 +1,150 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTopWritesEvent struct {
+	Prefix string `json:"prefix"`
+	Writes uint64 `json:"writes"`
+	Bytes  uint64 `json:"bytes"`
+	Pid    uint32 `json:"pid"`
+	File   string `json:"file"`
+}
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	prefixDepth    int
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+}
+
+func TestTopWritesGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+	runnerConfig := &utilstest.RunnerConfig{}
+
+	testCases := map[string]testDef{
+		"aggregates_by_prefix": {
+			runnerConfig: runnerConfig,
+			prefixDepth:  2,
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+		},
+		"aggregates_by_file": {
+			runnerConfig: runnerConfig,
+			prefixDepth:  0,
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var path string
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.map-fetch-interval": "1000ms",
+				"operator.oci.ebpf.prefix-depth":       strconv.Itoa(testCase.prefixDepth),
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			normalizeEvent := func(event *ExpectedTopWritesEvent) {
+				utils.NormalizeInt(&event.Writes)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				utilstest.RunWithRunner(t, runner, func() error {
+					var err error
+					path, err = generateEvent()
+					return err
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTopWritesEvent]{
+				Image:          "top_writes",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, pid int) *ExpectedTopWritesEvent {
+				return &ExpectedTopWritesEvent{
+					Prefix: pathPrefix(path, testCase.prefixDepth),
+					Bytes:  10240,
+					Pid:    uint32(info.Pid),
+					File:   path,
+
+					// Only check the existence.
+					Writes: utils.NormalizedInt,
+				}
+			})(t, runner.Info, 0, gadgetRunner.CapturedEvents)
+		})
+	}
+}
+
+// pathPrefix returns the first depth components of path, as the gadget does
+func pathPrefix(path string, depth int) string {
+	if depth == 0 {
+		return path
+	}
+	components := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(components) > depth {
+		components = components[:depth]
+	}
+	return "/" + strings.Join(components, "/")
+}
+
+func generateEvent() (string, error) {
+	temp, err := os.MkdirTemp("", "test")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(temp, "foo")
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	buf := make([]byte, 10240)
+	if _, err := file.Write(buf); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -14,6 +14,7 @@
 #include <gadget/buffer.h>
 #include <gadget/common.h>
 #include <gadget/kernel_stack_map.h>
+#include <gadget/user_stack_map.h>
 #include <gadget/macros.h>
 #include <gadget/mntns_filter.h>
 #include <gadget/types.h>
@@ -88,6 +89,7 @@ struct cap_event {
 	int insetid;
 	gadget_syscall syscall_raw;
 	gadget_kernel_stack kstack_raw;
+	gadget_user_stack ustack_raw;
 };
 
 #define MAX_ENTRIES 10240
@@ -162,8 +164,8 @@ struct {
 GADGET_TRACER_MAP(events, 1024 * 256);
 GADGET_TRACER(capabilities, events, cap_event);
 
-const volatile bool print_stack = true;
-GADGET_PARAM(print_stack);
+const volatile bool collect_stack = false;
+GADGET_PARAM(collect_stack);
 
 SEC("kprobe/cap_capable")
 int BPF_KPROBE(ig_trace_cap_e, const struct cred *cred,
@@ -261,7 +263,13 @@ int BPF_KRETPROBE(ig_trace_cap_x)
 	event->cap_raw = ap->cap;
 	// ret=0 means the process has the requested capability, otherwise ret=-EPERM
 	event->capable = PT_REGS_RC(ctx) == 0;
-	event->kstack_raw = gadget_get_kernel_stack(ctx);
+	if (collect_stack) {
+		event->kstack_raw = gadget_get_kernel_stack(ctx);
+		event->ustack_raw = gadget_get_user_stack(ctx);
+	} else {
+		event->kstack_raw = -1;
+		event->ustack_raw = -1;
+	}
 	event->timestamp_raw = bpf_ktime_get_boot_ns();
 
 	if (LINUX_KERNEL_VERSION >= KERNEL_VERSION(5, 1, 0)) {
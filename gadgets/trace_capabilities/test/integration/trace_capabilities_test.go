@@ -91,6 +91,7 @@ func TestTraceCapabilities(t *testing.T) {
 		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
 	}
 
+	runnerOpts = append(runnerOpts, igrunner.WithFlags("--stack"))
 	runnerOpts = append(runnerOpts, igrunner.WithValidateOutput(
 		func(t *testing.T, output string) {
 			expectedEntries := []*traceCapabilitiesEvent{
@@ -0,0 +1,390 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/filesystem.h>
+
+// Defined in include/uapi/linux/stat.h
+#define S_IFMT 00170000
+#define S_IFSOCK 0140000
+
+/* Define here, because there are conflicts with include files */
+#define AF_INET 2
+#define AF_INET6 10
+
+#define MAX_ENTRIES 10240
+
+// The CPU usage of processes is computed over windows of 10 seconds
+#define WINDOW_NS (10ULL * 1000 * 1000 * 1000)
+
+// Only the first sends of each process are inspected to find a stratum
+// handshake, miners send it right after connecting to the pool
+#define MAX_INSPECTED_SENDS 32
+#define PAYLOAD_SIZE 256
+#define MAX_PATTERN_SIZE 32
+
+#define SIGNAL_SUSTAINED_CPU (1 << 0)
+#define SIGNAL_MANY_THREADS (1 << 1)
+#define SIGNAL_POOL_PORT (1 << 2)
+#define SIGNAL_STRATUM (1 << 3)
+
+enum severity : u8 {
+	low,
+	medium,
+	high,
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	struct gadget_l4endpoint_t remote;
+	__u32 cpu_percent;
+	__u32 threads;
+	bool sustained_cpu;
+	bool many_threads;
+	bool pool_port;
+	bool stratum;
+	enum severity severity_raw;
+};
+
+struct proc_state {
+	__u64 window_start;
+	__u64 cpu_ns;
+	__u32 busy_windows;
+	__u32 cpu_percent;
+	__u32 threads;
+	__u32 inspected_sends;
+	__u8 signals;
+	__u8 alerted_signals;
+	struct gadget_l4endpoint_t remote;
+};
+
+// we need this to make sure the compiler doesn't remove our struct
+const enum severity unused_severity __attribute__((unused));
+
+const volatile __u32 cpu_percent = 80;
+const volatile __u32 cpu_windows = 6;
+const volatile __u32 min_threads = 8;
+const volatile __u32 min_signals = 2;
+
+GADGET_PARAM(cpu_percent);
+GADGET_PARAM(cpu_windows);
+GADGET_PARAM(min_threads);
+GADGET_PARAM(min_signals);
+
+static const struct proc_state empty_state = {};
+
+// key: tgid
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, u32);
+	__type(value, struct proc_state);
+} procs SEC(".maps");
+
+// key: tid, value: time the thread was scheduled in
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, u32);
+	__type(value, u64);
+} oncpu SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, char[PAYLOAD_SIZE + MAX_PATTERN_SIZE]);
+} payloads SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(cryptominer, events, event);
+
+static __always_inline struct proc_state *get_state(u32 tgid, u64 now)
+{
+	struct proc_state *st;
+
+	st = bpf_map_lookup_elem(&procs, &tgid);
+	if (st)
+		return st;
+
+	bpf_map_update_elem(&procs, &tgid, &empty_state, BPF_NOEXIST);
+	st = bpf_map_lookup_elem(&procs, &tgid);
+	if (st && st->window_start == 0)
+		st->window_start = now;
+	return st;
+}
+
+static __always_inline int count_signals(__u8 signals)
+{
+	return !!(signals & SIGNAL_SUSTAINED_CPU) +
+	       !!(signals & SIGNAL_MANY_THREADS) +
+	       !!(signals & SIGNAL_POOL_PORT) + !!(signals & SIGNAL_STRATUM);
+}
+
+// maybe_alert emits an event when the process shows at least min_signals
+// signals, or a stratum handshake that is enough alone. Once a process was
+// reported, new events are only emitted when more signals show up. It must be
+// called from the context of the process.
+static __always_inline void maybe_alert(void *ctx, struct proc_state *st)
+{
+	__u8 signals = st->signals;
+	struct event *event;
+	int count;
+
+	if (signals == st->alerted_signals)
+		return;
+
+	count = count_signals(signals);
+	if (!(signals & SIGNAL_STRATUM) && count < min_signals)
+		return;
+
+	st->alerted_signals = signals;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return;
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->remote = st->remote;
+	event->cpu_percent = st->cpu_percent;
+	event->threads = st->threads;
+	event->sustained_cpu = signals & SIGNAL_SUSTAINED_CPU;
+	event->many_threads = signals & SIGNAL_MANY_THREADS;
+	event->pool_port = signals & SIGNAL_POOL_PORT;
+	event->stratum = signals & SIGNAL_STRATUM;
+	event->severity_raw =
+		(signals & SIGNAL_STRATUM) || count >= 3 ? high : medium;
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+}
+
+// account adds the time spent on the CPU by a thread of the current process
+// and evaluates the CPU and threads signals at the end of each window
+static __always_inline void account(void *ctx, struct task_struct *task,
+				    u64 delta, u64 now)
+{
+	struct proc_state *st;
+	u64 elapsed;
+	bool busy;
+
+	st = get_state(BPF_CORE_READ(task, tgid), now);
+	if (!st)
+		return;
+
+	__sync_fetch_and_add(&st->cpu_ns, delta);
+
+	elapsed = now - st->window_start;
+	if (elapsed < WINDOW_NS)
+		return;
+
+	st->cpu_percent = st->cpu_ns * 100 / elapsed;
+	st->threads = BPF_CORE_READ(task, signal, nr_threads);
+	st->window_start = now;
+	st->cpu_ns = 0;
+
+	busy = st->cpu_percent >= cpu_percent;
+	st->busy_windows = busy ? st->busy_windows + 1 : 0;
+
+	if (st->busy_windows >= cpu_windows)
+		st->signals |= SIGNAL_SUSTAINED_CPU;
+	// Many threads are only suspicious when they keep the CPUs busy
+	if (busy && st->threads >= min_threads)
+		st->signals |= SIGNAL_MANY_THREADS;
+
+	maybe_alert(ctx, st);
+}
+
+SEC("tp_btf/sched_switch")
+int BPF_PROG(ig_miner_sched, bool preempt, struct task_struct *prev,
+	     struct task_struct *next)
+{
+	u64 now = bpf_ktime_get_ns();
+	u64 *start;
+	u32 tid;
+
+	// prev is still the current task here
+	tid = BPF_CORE_READ(prev, pid);
+	start = bpf_map_lookup_elem(&oncpu, &tid);
+	if (start) {
+		account(ctx, prev, now - *start, now);
+		bpf_map_delete_elem(&oncpu, &tid);
+	}
+
+	tid = BPF_CORE_READ(next, pid);
+	// idle task
+	if (tid == 0)
+		return 0;
+	if (gadget_should_discard_mntns_id(
+		    BPF_CORE_READ(next, nsproxy, mnt_ns, ns.inum)))
+		return 0;
+
+	bpf_map_update_elem(&oncpu, &tid, &now, BPF_ANY);
+	return 0;
+}
+
+// Ports used by well known mining pools, mostly for the stratum protocol
+static __always_inline bool is_pool_port(u16 port)
+{
+	switch (port) {
+	case 3333:
+	case 3334:
+	case 3357:
+	case 4444:
+	case 5555:
+	case 5556:
+	case 7777:
+	case 9999:
+	case 14433:
+	case 14444:
+	case 45560:
+	case 45700:
+		return true;
+	default:
+		return false;
+	}
+}
+
+SEC("kprobe/tcp_connect")
+int BPF_KPROBE(ig_miner_connect, struct sock *sk)
+{
+	u64 pid_tgid = bpf_get_current_pid_tgid();
+	struct proc_state *st;
+	u16 family, port;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	port = bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+	if (!is_pool_port(port))
+		return 0;
+
+	st = get_state(pid_tgid >> 32, bpf_ktime_get_ns());
+	if (!st)
+		return 0;
+
+	family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	if (family == AF_INET) {
+		st->remote.version = 4;
+		BPF_CORE_READ_INTO(&st->remote.addr_raw.v4, sk,
+				   __sk_common.skc_daddr);
+	} else if (family == AF_INET6) {
+		st->remote.version = 6;
+		BPF_CORE_READ_INTO(&st->remote.addr_raw.v6, sk,
+				   __sk_common.skc_v6_daddr.in6_u.u6_addr32);
+	}
+	st->remote.port = port;
+	st->remote.proto_raw = IPPROTO_TCP;
+	st->signals |= SIGNAL_POOL_PORT;
+
+	maybe_alert(ctx, st);
+	return 0;
+}
+
+static __always_inline bool has_prefix(const char *s, const char *prefix,
+				       int len)
+{
+	for (int i = 0; i < len; i++) {
+		if (s[i] != prefix[i])
+			return false;
+	}
+	return true;
+}
+
+// contains looks for pattern in the first len bytes of buf. pattern is a
+// string literal.
+#define contains(buf, len, pattern)                                        \
+	({                                                                 \
+		bool __found = false;                                      \
+		for (int __i = 0; __i < PAYLOAD_SIZE; __i++) {             \
+			if (__i + sizeof(pattern) - 1 > (len))             \
+				break;                                     \
+			if (has_prefix(&(buf)[__i], pattern,               \
+				       sizeof(pattern) - 1)) {             \
+				__found = true;                            \
+				break;                                     \
+			}                                                  \
+		}                                                          \
+		__found;                                                   \
+	})
+
+// inspect_send looks for the first message of the stratum protocol: the
+// mining.subscribe and mining.authorize methods, or login for the variant
+// used by Monero miners
+static __always_inline void inspect_send(void *ctx, int fd, const void *data,
+					 size_t count)
+{
+	u64 pid_tgid = bpf_get_current_pid_tgid();
+	struct proc_state *st;
+	struct file *file;
+	umode_t mode;
+	u32 zero = 0;
+	char *buf;
+	u32 len;
+
+	if (count == 0)
+		return;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return;
+
+	st = get_state(pid_tgid >> 32, bpf_ktime_get_ns());
+	if (!st)
+		return;
+	if ((st->signals & SIGNAL_STRATUM) ||
+	    st->inspected_sends >= MAX_INSPECTED_SENDS)
+		return;
+
+	file = get_struct_file_for_fd(fd);
+	if (!file)
+		return;
+	mode = BPF_CORE_READ(file, f_inode, i_mode);
+	if ((mode & S_IFMT) != S_IFSOCK)
+		return;
+
+	st->inspected_sends++;
+
+	buf = bpf_map_lookup_elem(&payloads, &zero);
+	if (!buf)
+		return;
+	len = count < PAYLOAD_SIZE ? count : PAYLOAD_SIZE;
+	if (bpf_probe_read_user(buf, len, data))
+		return;
+
+	if (!contains(buf, len, "\"mining.") &&
+	    !contains(buf, len, "\"method\":\"login\""))
+		return;
+
+	st->signals |= SIGNAL_STRATUM;
+	maybe_alert(ctx, st);
+}
+
+SEC("tracepoint/syscalls/sys_enter_write")
+int ig_miner_write(struct syscall_trace_enter *ctx)
+{
+	inspect_send(ctx, (int)ctx->args[0], (const void *)ctx->args[1],
+		     (size_t)ctx->args[2]);
+	return 0;
+}
+
+SEC("tracepoint/syscalls/sys_enter_sendto")
+int ig_miner_sendto(struct syscall_trace_enter *ctx)
+{
+	inspect_send(ctx, (int)ctx->args[0], (const void *)ctx->args[1],
+		     (size_t)ctx->args[2]);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
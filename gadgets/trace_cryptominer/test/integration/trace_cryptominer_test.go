@@ -0,0 +1,138 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceCryptominerEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Remote       utils.L4Endpoint `json:"remote"`
+	CPUPercent   uint32           `json:"cpu_percent"`
+	Threads      uint32           `json:"threads"`
+	SustainedCPU bool             `json:"sustained_cpu"`
+	ManyThreads  bool             `json:"many_threads"`
+	PoolPort     bool             `json:"pool_port"`
+	Stratum      bool             `json:"stratum"`
+	Severity     string           `json:"severity"`
+}
+
+func TestTraceCryptominer(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-cryptominer"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+		containers.WithStartAndStop(),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-cryptominer")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// nc connects to a fake pool on a port used by mining pools and sends a
+	// stratum handshake
+	listener := "while true ; do nc -l -p 3333 > /dev/null ; done"
+	client := `while true ; do echo '{"id":1,"method":"mining.subscribe","params":[]}' | nc 127.0.0.1 3333 ; sleep 1 ; done`
+	cmd := fmt.Sprintf("%s & sleep 1 ; %s", listener, client)
+
+	testContainer := containerFactory.NewContainer(containerName, cmd, containerOpts...)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(utils.NormalizedStr),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime)))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntries := []*traceCryptominerEvent{
+					{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("nc", 0, 0),
+						Remote: utils.L4Endpoint{
+							Addr:    "127.0.0.1",
+							Version: 4,
+							Port:    3333,
+							Proto:   "TCP",
+						},
+						PoolPort: true,
+						Stratum:  true,
+						Severity: "high",
+
+						// nc doesn't run long enough to complete a CPU
+						// window, so cpu_percent and threads are 0
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+					},
+				}
+				normalize := func(e *traceCryptominerEvent) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeString(&e.Runtime.ContainerID)
+					utils.NormalizeString(&e.Timestamp)
+					utils.NormalizeProc(&e.Proc)
+				}
+				match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntries...)
+			},
+		))
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop())
+	traceCryptominerCmd := igrunner.New("trace_cryptominer", runnerOpts...)
+
+	steps := []igtesting.TestStep{
+		traceCryptominerCmd,
+		// wait to ensure ig or kubectl-gadget has started
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+	igtesting.RunTestSteps(steps, t, testingOpts...)
+}
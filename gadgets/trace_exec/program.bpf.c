@@ -11,6 +11,8 @@
 #include <gadget/mntns_filter.h>
 #include <gadget/types.h>
 #include <gadget/filesystem.h>
+#include <gadget/kernel_stack_map.h>
+#include <gadget/user_stack_map.h>
 
 // Defined in include/uapi/linux/magic.h
 #define OVERLAYFS_SUPER_MAGIC 0x794c7630
@@ -35,7 +37,10 @@ struct event {
 	bool upper_layer;
 	bool pupper_layer;
 	unsigned int args_size;
+	gadget_kernel_stack kstack_raw;
+	gadget_user_stack ustack_raw;
 	char cwd[MAX_STRING_SIZE];
+	char exepath[MAX_STRING_SIZE];
 	char args[FULL_MAX_ARGS_ARR];
 };
 
@@ -43,10 +48,12 @@ const volatile bool ignore_failed = true;
 const volatile uid_t targ_uid = INVALID_UID;
 const volatile int max_args = DEFAULT_MAXARGS;
 const volatile bool paths = false;
+const volatile bool collect_stack = false;
 
 GADGET_PARAM(ignore_failed);
 GADGET_PARAM(targ_uid);
 GADGET_PARAM(paths);
+GADGET_PARAM(collect_stack);
 
 static const struct event empty_event = {};
 
@@ -118,6 +125,16 @@ int ig_execve_e(struct syscall_trace_enter *ctx)
 	event->args_count = 0;
 	event->args_size = 0;
 
+	// The stacks are the ones of the caller of execve, they don't exist
+	// anymore once the new program is executed
+	if (collect_stack) {
+		event->kstack_raw = gadget_get_kernel_stack(ctx);
+		event->ustack_raw = gadget_get_user_stack(ctx);
+	} else {
+		event->kstack_raw = -1;
+		event->ustack_raw = -1;
+	}
+
 	if (paths) {
 		struct fs_struct *fs = BPF_CORE_READ(task, fs);
 		char *cwd = get_path_str(&fs->pwd);
@@ -197,10 +214,17 @@ int ig_sched_exec(struct trace_event_raw_sched_process_exec *ctx)
 	if (!event)
 		return 0;
 
-	struct inode *inode = BPF_CORE_READ(task, mm, exe_file, f_inode);
+	struct file *exe_file = BPF_CORE_READ(task, mm, exe_file);
+	struct inode *inode = BPF_CORE_READ(exe_file, f_inode);
 	if (inode)
 		event->upper_layer = has_upper_layer(inode);
 
+	if (paths && exe_file) {
+		char *exepath = get_path_str(&exe_file->f_path);
+		bpf_probe_read_kernel_str(event->exepath, MAX_STRING_SIZE,
+					  exepath);
+	}
+
 	struct inode *pinode = BPF_CORE_READ(parent, mm, exe_file, f_inode);
 	if (pinode)
 		event->pupper_layer = has_upper_layer(pinode);
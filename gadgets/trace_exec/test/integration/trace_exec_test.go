@@ -44,6 +44,7 @@ type traceExecEvent struct {
 	UpperLayer  bool   `json:"upper_layer"`
 	PupperLayer bool   `json:"pupper_layer"`
 	Cwd         string `json:"cwd"`
+	Exepath     string `json:"exepath"`
 	Args        string `json:"args"`
 }
 
@@ -106,6 +107,7 @@ func TestTraceExec(t *testing.T) {
 						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
 						Proc:       utils.BuildProc("sh", 0, 0),
 						Cwd:        "/",
+						Exepath:    "/bin/sh",
 						Args:       strings.Join(shArgs, " "),
 						UpperLayer: false,
 
@@ -119,6 +121,7 @@ func TestTraceExec(t *testing.T) {
 						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
 						Proc:       utils.BuildProc("sh", 1000, 1111),
 						Cwd:        "/",
+						Exepath:    "/usr/bin/sh",
 						Args:       strings.Join(innerShArgs, " "),
 						UpperLayer: true,
 
@@ -132,6 +135,7 @@ func TestTraceExec(t *testing.T) {
 						CommonData:  utils.BuildCommonData(containerName, commonDataOpts...),
 						Proc:        utils.BuildProc("sleep", 1000, 1111),
 						Cwd:         "/tmp",
+						Exepath:     "/bin/sleep",
 						Args:        strings.Join(sleepArgs, " "),
 						UpperLayer:  false,
 						PupperLayer: true,
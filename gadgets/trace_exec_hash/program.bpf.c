@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/filesystem.h>
+
+// Defined in include/uapi/linux/magic.h
+#define OVERLAYFS_SUPER_MAGIC 0x794c7630
+
+#define NAME_SIZE 256
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	bool upper_layer;
+	bool deleted;
+	char filename[NAME_SIZE];
+	char exepath[MAX_STRING_SIZE];
+};
+
+const volatile bool only_upper_layer = false;
+
+GADGET_PARAM(only_upper_layer);
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(exec, events, event);
+
+// has_upper_layer returns whether the file is in the upper layer of an
+// overlay filesystem, i.e. it was created or modified after the container
+// started. See trace_exec.
+static __always_inline bool has_upper_layer(struct inode *inode)
+{
+	unsigned long sb_magic = BPF_CORE_READ(inode, i_sb, s_magic);
+	struct dentry *upperdentry;
+
+	if (sb_magic != OVERLAYFS_SUPER_MAGIC)
+		return false;
+
+	// struct ovl_inode isn't available in BTF, we only rely on vfs_inode
+	// and __upperdentry relative positions
+	bpf_probe_read_kernel(&upperdentry, sizeof(upperdentry),
+			      ((void *)inode) +
+				      bpf_core_type_size(struct inode));
+
+	return upperdentry != NULL;
+}
+
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(ig_exec_hash, struct task_struct *p, pid_t old_pid,
+	     struct linux_binprm *bprm)
+{
+	struct event *event;
+	struct file *file;
+	struct inode *inode;
+	bool upper_layer;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	file = BPF_CORE_READ(bprm, file);
+	inode = BPF_CORE_READ(file, f_inode);
+	upper_layer = has_upper_layer(inode);
+
+	if (only_upper_layer && !upper_layer)
+		return 0;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return 0;
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->upper_layer = upper_layer;
+	event->deleted = BPF_CORE_READ(inode, __i_nlink) == 0;
+	bpf_probe_read_kernel_str(event->filename, sizeof(event->filename),
+				  BPF_CORE_READ(bprm, filename));
+	bpf_probe_read_kernel_str(event->exepath, sizeof(event->exepath),
+				  get_path_str(&file->f_path));
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
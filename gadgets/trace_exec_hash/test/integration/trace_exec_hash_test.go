@@ -0,0 +1,126 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceExecHashEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	UpperLayer    bool   `json:"upper_layer"`
+	Deleted       bool   `json:"deleted"`
+	Filename      string `json:"filename"`
+	Exepath       string `json:"exepath"`
+	ExepathSource string `json:"exepath_source"`
+	Sha256        string `json:"sha256"`
+}
+
+func TestTraceExecHash(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-exec-hash"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+		containers.WithStartAndStop(),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-exec-hash")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// /tmp/sleep is a copy of busybox, so it's in the writable layer. It runs
+	// long enough to be hashed.
+	cmd := "cp /bin/busybox /tmp/sleep ; while true ; do /tmp/sleep 1 ; done"
+
+	testContainer := containerFactory.NewContainer(containerName, cmd, containerOpts...)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(utils.NormalizedStr),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime), "--only-upper-layer"))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns), "--only-upper-layer"))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntry := &traceExecHashEvent{
+					CommonData:    utils.BuildCommonData(containerName, commonDataOpts...),
+					Proc:          utils.BuildProc("sleep", 0, 0),
+					UpperLayer:    true,
+					Filename:      "/tmp/sleep",
+					Exepath:       "/tmp/sleep",
+					ExepathSource: "writable-layer",
+
+					// Check the existence of the following fields
+					Timestamp: utils.NormalizedStr,
+					Sha256:    utils.NormalizedStr,
+				}
+				normalize := func(e *traceExecHashEvent) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeString(&e.Runtime.ContainerID)
+					utils.NormalizeString(&e.Timestamp)
+					utils.NormalizeString(&e.Sha256)
+					utils.NormalizeProc(&e.Proc)
+				}
+				match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+			},
+		))
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop())
+	traceExecHashCmd := igrunner.New("trace_exec_hash", runnerOpts...)
+
+	steps := []igtesting.TestStep{
+		traceExecHashCmd,
+		// wait to ensure ig or kubectl-gadget has started
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+	igtesting.RunTestSteps(steps, t, testingOpts...)
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/filesystem.h>
+
+// Defined in include/uapi/linux/stat.h
+#define S_ISUID 0004000
+#define S_ISGID 0002000
+#define S_IXGRP 00010
+
+#define NAME_SIZE 256
+
+// Number of arguments inspected to find the script run by an interpreter,
+// including argv[0]
+#define MAX_SCRIPT_ARGS 4
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	gadget_uid euid;
+	bool setuid;
+	bool setgid;
+	bool deleted;
+	bool tmp_script;
+	char filename[NAME_SIZE];
+	char script[NAME_SIZE];
+	char exepath[MAX_STRING_SIZE];
+};
+
+const volatile bool ignore_setuid = false;
+const volatile bool ignore_deleted = false;
+const volatile bool ignore_tmp_script = false;
+
+GADGET_PARAM(ignore_setuid);
+GADGET_PARAM(ignore_deleted);
+GADGET_PARAM(ignore_tmp_script);
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(exec, events, event);
+
+static __always_inline bool has_prefix(const char *s, const char *prefix,
+				       int len)
+{
+	for (int i = 0; i < len; i++) {
+		if (s[i] != prefix[i])
+			return false;
+	}
+	return true;
+}
+
+// comm_is matches the whole comm, comm_starts only its beginning to match
+// versioned binaries like python3.12
+#define comm_is(comm, name) has_prefix(comm, name, sizeof(name))
+#define comm_starts(comm, name) has_prefix(comm, name, sizeof(name) - 1)
+
+static __always_inline bool is_interpreter(const char *comm)
+{
+	return comm_is(comm, "sh") || comm_is(comm, "ash") ||
+	       comm_is(comm, "bash") || comm_is(comm, "dash") ||
+	       comm_is(comm, "ksh") || comm_is(comm, "zsh") ||
+	       comm_starts(comm, "python") || comm_starts(comm, "perl") ||
+	       comm_starts(comm, "ruby") || comm_starts(comm, "php") ||
+	       comm_starts(comm, "lua") || comm_is(comm, "node");
+}
+
+// in_dir returns whether path is dir or is inside it. dir doesn't have a
+// trailing slash.
+static __always_inline bool in_dir(const char *path, const char *dir, int len)
+{
+	if (!has_prefix(path, dir, len))
+		return false;
+	return path[len] == '\0' || path[len] == '/';
+}
+
+#define in_tmp_dir(path)                          \
+	(in_dir(path, "/tmp", sizeof("/tmp") - 1) || \
+	 in_dir(path, "/dev/shm", sizeof("/dev/shm") - 1))
+
+// find_script looks for the script passed to the interpreter in the arguments
+// of the new program and stores it in event->script. Options are skipped and
+// code passed inline with -c, -e or -m isn't a script.
+static __always_inline bool find_script(struct task_struct *task,
+					struct event *event)
+{
+	unsigned long arg = BPF_CORE_READ(task, mm, arg_start);
+	unsigned long arg_end = BPF_CORE_READ(task, mm, arg_end);
+	long ret;
+
+	for (int i = 0; i < MAX_SCRIPT_ARGS; i++) {
+		if (arg >= arg_end)
+			return false;
+
+		ret = bpf_probe_read_user_str(event->script,
+					      sizeof(event->script),
+					      (const void *)arg);
+		if (ret <= 0)
+			return false;
+		arg += ret;
+
+		// argv[0]
+		if (i == 0)
+			continue;
+
+		if (event->script[0] == '-') {
+			if (event->script[1] == 'c' || event->script[1] == 'e' ||
+			    event->script[1] == 'm')
+				return false;
+			continue;
+		}
+		return true;
+	}
+	return false;
+}
+
+static __always_inline bool script_in_tmp_dir(struct task_struct *task,
+					      struct event *event)
+{
+	char cwd[sizeof("/dev/shm/")] = {};
+	char *path;
+
+	if (event->script[0] == '/')
+		return in_tmp_dir(event->script);
+
+	// Relative path, check the current working directory
+	struct fs_struct *fs = BPF_CORE_READ(task, fs);
+	path = get_path_str(&fs->pwd);
+	bpf_probe_read_kernel_str(cwd, sizeof(cwd), path);
+	return in_tmp_dir(cwd);
+}
+
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(ig_exec_susp, struct task_struct *p, pid_t old_pid,
+	     struct linux_binprm *bprm)
+{
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+	struct event *event;
+	struct file *file;
+	struct inode *inode;
+	umode_t mode;
+	bool setuid, setgid, deleted, script;
+	char comm[TASK_COMM_LEN];
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	file = BPF_CORE_READ(bprm, file);
+	inode = BPF_CORE_READ(file, f_inode);
+	mode = BPF_CORE_READ(inode, i_mode);
+
+	setuid = !ignore_setuid && (mode & S_ISUID);
+	setgid = !ignore_setuid && (mode & S_ISGID) && (mode & S_IXGRP);
+
+	// The binary was removed after being started, like when it's executed
+	// through /proc/$pid/exe, or it's a memfd
+	deleted = !ignore_deleted && BPF_CORE_READ(inode, __i_nlink) == 0;
+
+	// binfmt_script and binfmt_misc change interp, so the file passed to
+	// execve() was run by an interpreter
+	bpf_get_current_comm(comm, sizeof(comm));
+	script = !ignore_tmp_script &&
+		 (BPF_CORE_READ(bprm, interp) != BPF_CORE_READ(bprm, filename) ||
+		  is_interpreter(comm));
+
+	if (!setuid && !setgid && !deleted && !script)
+		return 0;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return 0;
+
+	event->tmp_script = false;
+	if (script && find_script(task, event))
+		event->tmp_script = script_in_tmp_dir(task, event);
+	if (!event->tmp_script) {
+		if (!setuid && !setgid && !deleted) {
+			gadget_discard_buf(event);
+			return 0;
+		}
+		event->script[0] = '\0';
+	}
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->euid = BPF_CORE_READ(task, cred, euid.val);
+	event->setuid = setuid;
+	event->setgid = setgid;
+	event->deleted = deleted;
+	bpf_probe_read_kernel_str(event->filename, sizeof(event->filename),
+				  BPF_CORE_READ(bprm, filename));
+	bpf_probe_read_kernel_str(event->exepath, sizeof(event->exepath),
+				  get_path_str(&file->f_path));
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,142 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceExecSuspiciousEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Euid      uint32 `json:"euid"`
+	Setuid    bool   `json:"setuid"`
+	Setgid    bool   `json:"setgid"`
+	Deleted   bool   `json:"deleted"`
+	TmpScript bool   `json:"tmp_script"`
+	Filename  string `json:"filename"`
+	Script    string `json:"script"`
+	Exepath   string `json:"exepath"`
+}
+
+func TestTraceExecSuspicious(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-exec-suspicious"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+		containers.WithStartAndStop(),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-exec-suspicious")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// /tmp/suid is a copy of busybox with the setuid bit, executed by an
+	// unprivileged user to get euid 0
+	setup := "echo true > /tmp/payload.sh ; cp /bin/busybox /tmp/suid ; chmod u+s /tmp/suid"
+	loop := "while true ; do sh /tmp/payload.sh ; setuidgid 1000:1111 /tmp/suid true ; sleep 1 ; done"
+	cmd := fmt.Sprintf("%s ; %s", setup, loop)
+
+	testContainer := containerFactory.NewContainer(containerName, cmd, containerOpts...)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(utils.NormalizedStr),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime)))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntries := []*traceExecSuspiciousEvent{
+					{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("sh", 0, 0),
+						Euid:       0,
+						TmpScript:  true,
+						Filename:   "/bin/sh",
+						Script:     "/tmp/payload.sh",
+						Exepath:    "/bin/sh",
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+					},
+					{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("suid", 1000, 1111),
+						Euid:       0,
+						Setuid:     true,
+						Filename:   "/tmp/suid",
+						Exepath:    "/tmp/suid",
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+					},
+				}
+				normalize := func(e *traceExecSuspiciousEvent) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeString(&e.Runtime.ContainerID)
+					utils.NormalizeString(&e.Timestamp)
+					utils.NormalizeProc(&e.Proc)
+				}
+				match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntries...)
+			},
+		))
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop())
+	traceExecSuspiciousCmd := igrunner.New("trace_exec_suspicious", runnerOpts...)
+
+	steps := []igtesting.TestStep{
+		traceExecSuspiciousCmd,
+		// wait to ensure ig or kubectl-gadget has started
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+	igtesting.RunTestSteps(steps, t, testingOpts...)
+}
@@ -15,6 +15,9 @@
 package main
 
 import (
+	"strconv"
+	"strings"
+
 	api "github.com/inspektor-gadget/inspektor-gadget/wasmapi/go"
 )
 
@@ -73,6 +76,134 @@ var fsConfMap = map[string]fsConf{
 	},
 }
 
+type prefixKey struct {
+	prefix string
+	op     string
+}
+
+type prefixStats struct {
+	count   uint64
+	totalUs uint64
+	maxUs   uint64
+}
+
+var (
+	prefixDepth int
+	prefixes    = map[prefixKey]*prefixStats{}
+
+	prefixesDs     api.DataSource
+	prefixF        api.Field
+	prefixOpF      api.Field
+	prefixCountF   api.Field
+	prefixTotalUsF api.Field
+	prefixMaxUsF   api.Field
+)
+
+// pathPrefix returns the first depth components of path
+func pathPrefix(path string, depth int) string {
+	components := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if len(components) > depth {
+		components = components[:depth]
+	}
+	return "/" + strings.Join(components, "/")
+}
+
+//export gadgetInit
+func gadgetInit() int {
+	value, err := api.GetParamValue("prefix-depth")
+	if err != nil {
+		api.Errorf("failed to get param value: %s", err)
+		return 1
+	}
+	prefixDepth, err = strconv.Atoi(value)
+	if err != nil || prefixDepth < 0 {
+		api.Errorf("invalid prefix depth %q", value)
+		return 1
+	}
+	if prefixDepth == 0 {
+		return 0
+	}
+
+	ds, err := api.GetDataSource("malloc")
+	if err != nil {
+		api.Errorf("failed to get datasource: %s", err)
+		return 1
+	}
+
+	fullpathF, err := ds.GetField("fullpath")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	opF, err := ds.GetField("op")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	deltaF, err := ds.GetField("delta_us")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	prefixesDs, err = api.NewDataSource("prefixes", api.DataSourceTypeArray)
+	if err != nil {
+		api.Errorf("failed to create datasource: %s", err)
+		return 1
+	}
+
+	fields := []struct {
+		f    *api.Field
+		name string
+		kind api.FieldKind
+	}{
+		{&prefixF, "prefix", api.Kind_String},
+		{&prefixOpF, "op", api.Kind_String},
+		{&prefixCountF, "count", api.Kind_Uint64},
+		{&prefixTotalUsF, "total_us", api.Kind_Uint64},
+		{&prefixMaxUsF, "max_us", api.Kind_Uint64},
+	}
+	for _, field := range fields {
+		*field.f, err = prefixesDs.AddField(field.name, field.kind)
+		if err != nil {
+			api.Errorf("failed to add field: %s", err)
+			return 1
+		}
+	}
+
+	ds.Subscribe(func(source api.DataSource, data api.Data) {
+		fullpath, err := fullpathF.String(data)
+		if err != nil || fullpath == "" {
+			// statfs or --paths not set
+			return
+		}
+		op, err := opF.String(data)
+		if err != nil {
+			api.Warnf("failed to get op: %s", err)
+			return
+		}
+		delta, err := deltaF.Uint64(data)
+		if err != nil {
+			api.Warnf("failed to get delta: %s", err)
+			return
+		}
+
+		key := prefixKey{prefix: pathPrefix(fullpath, prefixDepth), op: op}
+		stats, ok := prefixes[key]
+		if !ok {
+			stats = &prefixStats{}
+			prefixes[key] = stats
+		}
+		stats.count++
+		stats.totalUs += delta
+		stats.maxUs = max(stats.maxUs, delta)
+	}, 0)
+
+	return 0
+}
+
 //export gadgetPreStart
 func gadgetPreStart() int {
 	value, err := api.GetParamValue("filesystem")
@@ -101,4 +232,30 @@ func gadgetPreStart() int {
 	return 0
 }
 
+//export gadgetStop
+func gadgetStop() int {
+	if prefixDepth == 0 {
+		return 0
+	}
+
+	packet, err := prefixesDs.NewPacketArray()
+	if err != nil {
+		api.Errorf("failed to create packet: %s", err)
+		return 1
+	}
+	arr := api.DataArray(packet)
+	for key, stats := range prefixes {
+		data := arr.New()
+		prefixF.SetString(data, key.prefix)
+		prefixOpF.SetString(data, key.op)
+		prefixCountF.SetUint64(data, stats.count)
+		prefixTotalUsF.SetUint64(data, stats.totalUs)
+		prefixMaxUsF.SetUint64(data, stats.maxUs)
+		arr.Append(data)
+	}
+	prefixesDs.EmitAndRelease(api.Packet(packet))
+
+	return 0
+}
+
 func main() {}
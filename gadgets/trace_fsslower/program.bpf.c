@@ -9,6 +9,7 @@
 
 #include <gadget/buffer.h>
 #include <gadget/common.h>
+#include <gadget/filesystem.h>
 #include <gadget/macros.h>
 #include <gadget/mntns_filter.h>
 
@@ -32,15 +33,27 @@ struct event {
 	__u64 size; // TODO: use result of https://github.com/inspektor-gadget/inspektor-gadget/issues/3392
 	enum fs_file_op op_raw;
 	char file[FILE_NAME_LEN];
+	char fullpath[MAX_STRING_SIZE];
 };
 
 #define MAX_ENTRIES 8192
 
 const volatile pid_t target_pid = 0;
 const volatile __u64 min_lat_ms = 0;
+// Thresholds per operation, min_lat_ms is used when they are 0
+const volatile __u64 min_lat_read_ms = 0;
+const volatile __u64 min_lat_write_ms = 0;
+const volatile __u64 min_lat_open_ms = 0;
+const volatile __u64 min_lat_fsync_ms = 0;
+const volatile bool paths = false;
 
 GADGET_PARAM(target_pid);
 GADGET_PARAM(min_lat_ms);
+GADGET_PARAM(min_lat_read_ms);
+GADGET_PARAM(min_lat_write_ms);
+GADGET_PARAM(min_lat_open_ms);
+GADGET_PARAM(min_lat_fsync_ms);
+GADGET_PARAM(paths);
 
 GADGET_TRACER_MAP(events, 1024 * 256);
 GADGET_TRACER(malloc, events, event);
@@ -61,6 +74,8 @@ struct data {
 	loff_t start;
 	loff_t end;
 	struct dentry *dentry;
+	// NULL for statfs, the full path isn't available then
+	struct file *file;
 };
 
 struct {
@@ -70,8 +85,35 @@ struct {
 	__type(value, struct data);
 } starts SEC(".maps");
 
-static int probe_entry(struct dentry *dentry, enum fs_file_op op, loff_t start,
-		       loff_t end)
+static __always_inline __u64 min_lat_ns(enum fs_file_op op)
+{
+	__u64 ms;
+
+	switch (op) {
+	case F_READ:
+		ms = min_lat_read_ms;
+		break;
+	case F_WRITE:
+		ms = min_lat_write_ms;
+		break;
+	case F_OPEN:
+		ms = min_lat_open_ms;
+		break;
+	case F_FSYNC:
+		ms = min_lat_fsync_ms;
+		break;
+	default:
+		ms = 0;
+	}
+
+	if (ms == 0)
+		ms = min_lat_ms;
+
+	return ms * 1000 * 1000;
+}
+
+static int probe_entry(struct dentry *dentry, struct file *file,
+		       enum fs_file_op op, loff_t start, loff_t end)
 {
 	__u64 pid_tgid = bpf_get_current_pid_tgid();
 	__u32 pid = pid_tgid >> 32;
@@ -92,6 +134,7 @@ static int probe_entry(struct dentry *dentry, enum fs_file_op op, loff_t start,
 	data.start = start;
 	data.end = end;
 	data.dentry = dentry;
+	data.file = file;
 	bpf_map_update_elem(&starts, &key, &data, BPF_ANY);
 	return 0;
 }
@@ -107,6 +150,7 @@ static int probe_exit(void *ctx, enum fs_file_op op, ssize_t size)
 	struct event *event;
 	struct data_key key = { .tid = tid, .op = op };
 	struct dentry *dentry;
+	struct file *file;
 
 	if (target_pid && target_pid != pid)
 		return 0;
@@ -119,7 +163,7 @@ static int probe_exit(void *ctx, enum fs_file_op op, ssize_t size)
 
 	end_ns = bpf_ktime_get_ns();
 	delta_ns = end_ns - datap->ts;
-	if (delta_ns <= 1000 * 1000 * min_lat_ms)
+	if (delta_ns <= min_lat_ns(op))
 		return 0;
 
 	event = gadget_reserve_buf(&events, sizeof(*event));
@@ -136,6 +180,18 @@ static int probe_exit(void *ctx, enum fs_file_op op, ssize_t size)
 	file_name = BPF_CORE_READ(dentry, d_name.name);
 	bpf_probe_read_kernel_str(&event->file, sizeof(event->file), file_name);
 
+	event->fullpath[0] = '\0';
+	file = datap->file;
+	if (paths && file) {
+		struct path f_path = BPF_CORE_READ(file, f_path);
+		char *c_path = get_path_str(&f_path);
+
+		if (c_path)
+			bpf_probe_read_kernel_str(event->fullpath,
+						  sizeof(event->fullpath),
+						  c_path);
+	}
+
 	gadget_submit_buf(ctx, &events, event, sizeof(*event));
 
 	return 0;
@@ -144,10 +200,11 @@ static int probe_exit(void *ctx, enum fs_file_op op, ssize_t size)
 SEC("kprobe/dummy_file_read")
 int BPF_KPROBE(ig_fssl_read_e, struct kiocb *iocb)
 {
-	struct dentry *dentry = BPF_CORE_READ(iocb, ki_filp, f_path.dentry);
+	struct file *file = BPF_CORE_READ(iocb, ki_filp);
+	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
 	loff_t start = BPF_CORE_READ(iocb, ki_pos);
 
-	return probe_entry(dentry, F_READ, start, 0);
+	return probe_entry(dentry, file, F_READ, start, 0);
 }
 
 SEC("kretprobe/dummy_file_read")
@@ -159,10 +216,11 @@ int BPF_KRETPROBE(ig_fssl_read_x, ssize_t ret)
 SEC("kprobe/dummy_file_write")
 int BPF_KPROBE(ig_fssl_wr_e, struct kiocb *iocb)
 {
-	struct dentry *dentry = BPF_CORE_READ(iocb, ki_filp, f_path.dentry);
+	struct file *file = BPF_CORE_READ(iocb, ki_filp);
+	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
 	loff_t start = BPF_CORE_READ(iocb, ki_pos);
 
-	return probe_entry(dentry, F_WRITE, start, 0);
+	return probe_entry(dentry, file, F_WRITE, start, 0);
 }
 
 SEC("kretprobe/dummy_file_write")
@@ -175,7 +233,7 @@ SEC("kprobe/dummy_file_open")
 int BPF_KPROBE(ig_fssl_open_e, struct inode *inode, struct file *file)
 {
 	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
-	return probe_entry(dentry, F_OPEN, 0, 0);
+	return probe_entry(dentry, file, F_OPEN, 0, 0);
 }
 
 SEC("kretprobe/dummy_file_open")
@@ -188,7 +246,7 @@ SEC("kprobe/dummy_file_sync")
 int BPF_KPROBE(ig_fssl_sync_e, struct file *file, loff_t start, loff_t end)
 {
 	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
-	return probe_entry(dentry, F_FSYNC, start, end);
+	return probe_entry(dentry, file, F_FSYNC, start, end);
 }
 
 SEC("kretprobe/dummy_file_sync")
@@ -200,7 +258,7 @@ int BPF_KRETPROBE(ig_fssl_sync_x)
 SEC("kprobe/dummy_file_statfs")
 int BPF_KPROBE(ig_fssl_statfs_e, struct dentry *dentry, struct kstatfs *buf)
 {
-	return probe_entry(dentry, F_STATFS, 0, 0);
+	return probe_entry(dentry, NULL, F_STATFS, 0, 0);
 }
 
 SEC("kretprobe/dummy_file_statfs")
@@ -36,11 +36,12 @@ type traceFSSlowerEvent struct {
 	Timestamp string            `json:"timestamp"`
 	Proc      ebpftypes.Process `json:"proc"`
 
-	Delta  uint64 `json:"delta_us"`
-	Offset uint64 `json:"offset"`
-	Size   uint64 `json:"size"`
-	Op     string `json:"op"`
-	File   string `json:"file"`
+	Delta    uint64 `json:"delta_us"`
+	Offset   uint64 `json:"offset"`
+	Size     uint64 `json:"size"`
+	Op       string `json:"op"`
+	File     string `json:"file"`
+	Fullpath string `json:"fullpath"`
 }
 
 func TestTraceFSSlower(t *testing.T) {
@@ -90,7 +91,7 @@ func TestTraceFSSlower(t *testing.T) {
 		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
 	}
 
-	runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("--filesystem=%s", fsType), "--min=0"))
+	runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("--filesystem=%s", fsType), "--min=0", "--paths"))
 
 	runnerOpts = append(runnerOpts, igrunner.WithValidateOutput(
 		func(t *testing.T, output string) {
@@ -98,6 +99,7 @@ func TestTraceFSSlower(t *testing.T) {
 				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
 				Proc:       utils.BuildProc("cat", 0, 0),
 				File:       "foo",
+				Fullpath:   "/foo",
 				Op:         "F_OPEN",
 				Offset:     0,
 				Size:       0,
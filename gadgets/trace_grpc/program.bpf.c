@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/golang.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define MAX_METHOD_LEN 128
+#define MAX_ENTRIES 10240
+
+/* Indexes of the fields in the goOffsets section of gadget.yaml */
+#define OFF_STREAM_METHOD 0
+#define OFF_SERVER_STREAM_STREAM 1
+#define OFF_STATUS_S 2
+#define OFF_SPB_STATUS_CODE 3
+
+/* google.golang.org/grpc/codes */
+enum grpc_code {
+	OK,
+	Canceled,
+	Unknown,
+	InvalidArgument,
+	DeadlineExceeded,
+	NotFound,
+	AlreadyExists,
+	PermissionDenied,
+	ResourceExhausted,
+	FailedPrecondition,
+	Aborted,
+	OutOfRange,
+	Unimplemented,
+	Internal,
+	Unavailable,
+	DataLoss,
+	Unauthenticated,
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	__u64 latency_ns;
+	enum grpc_code status_raw;
+	char method[MAX_METHOD_LEN];
+};
+
+/* used for context between the start and the end of a call */
+struct call {
+	__u64 start_time;
+	char method[MAX_METHOD_LEN];
+};
+
+/* stream addresses are only unique within a process */
+struct call_key {
+	__u32 tgid;
+	__u32 pad;
+	__u64 stream;
+};
+
+/* LRU, since the status isn't written for calls whose connection is closed */
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct call_key);
+	__type(value, struct call);
+} calls SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+GADGET_TRACER(grpc, events, event);
+
+/* Returns the address of the transport.Stream, which is embedded in
+ * transport.ServerStream since grpc-go 1.67 */
+static __always_inline void *get_stream(struct pt_regs *ctx, void *stream)
+{
+	__s64 off;
+
+	off = gadget_go_offset(ctx, OFF_SERVER_STREAM_STREAM);
+	if (off < 0)
+		return stream;
+	if (bpf_probe_read_user(&stream, sizeof(stream), stream + off))
+		return NULL;
+	return stream;
+}
+
+/* func (s *Server) handleStream(t transport.ServerTransport, stream *transport.Stream) */
+SEC("uprobe/:google.golang.org/grpc.(*Server).handleStream")
+int ig_grpc_handle(struct pt_regs *ctx)
+{
+	void *server_stream = (void *)GO_PARAM4(ctx);
+	struct call_key key = {};
+	struct call call = {};
+	void *stream;
+	__s64 off;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	key.tgid = bpf_get_current_pid_tgid() >> 32;
+	key.stream = (__u64)server_stream;
+	call.start_time = bpf_ktime_get_ns();
+
+	off = gadget_go_offset(ctx, OFF_STREAM_METHOD);
+	stream = get_stream(ctx, server_stream);
+	if (off >= 0 && stream)
+		gadget_go_read_string(stream + off, call.method,
+				      sizeof(call.method));
+
+	bpf_map_update_elem(&calls, &key, &call, BPF_ANY);
+	return 0;
+}
+
+/* func (t *http2Server) WriteStatus(s *Stream, st *status.Status) error */
+SEC("uprobe/:google.golang.org/grpc/internal/transport.(*http2Server).WriteStatus")
+int ig_grpc_status(struct pt_regs *ctx)
+{
+	void *st = (void *)GO_PARAM3(ctx);
+	struct call_key key = {};
+	struct event *event;
+	struct call *call;
+	__s64 off_s, off_code;
+	__s32 code = -1;
+	void *spb;
+
+	key.tgid = bpf_get_current_pid_tgid() >> 32;
+	key.stream = (__u64)GO_PARAM2(ctx);
+	call = bpf_map_lookup_elem(&calls, &key);
+	if (!call)
+		return 0;
+
+	/* A nil *spb.Status means OK */
+	off_s = gadget_go_offset(ctx, OFF_STATUS_S);
+	off_code = gadget_go_offset(ctx, OFF_SPB_STATUS_CODE);
+	if (st && off_s >= 0 && off_code >= 0 &&
+	    !bpf_probe_read_user(&spb, sizeof(spb), st + off_s)) {
+		if (!spb)
+			code = OK;
+		else
+			bpf_probe_read_user(&code, sizeof(code),
+					    spb + off_code);
+	}
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		goto clean;
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->latency_ns = bpf_ktime_get_ns() - call->start_time;
+	event->status_raw = code;
+	__builtin_memcpy(event->method, call->method, sizeof(event->method));
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+clean:
+	bpf_map_delete_elem(&calls, &key);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
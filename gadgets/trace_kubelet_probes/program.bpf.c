@@ -0,0 +1,452 @@
+/* SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause) */
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define GADGET_TYPE_TRACING
+#include <gadget/sockets-map.h>
+
+/* Define here, because there are conflicts with include files */
+#define AF_INET 2
+#define AF_INET6 10
+#define ETH_P_IP 0x0800
+#define ETH_P_IPV6 0x86DD
+
+#define MAX_ENTRIES 10240
+
+enum probe_outcome {
+	// The app answered the request, or the connection was established for a
+	// TCP probe
+	ok,
+	// The connection was reset: nothing listened on the port
+	refused,
+	// The SYNs got no answer: the accept queue of the app was full or they
+	// were dropped on the way to the pod
+	syn_dropped,
+	// The kubelet sent the request but the app didn't accept the connection
+	// before it was closed
+	not_accepted,
+	// The app accepted the connection but didn't answer the request before
+	// it was closed, usually because the kubelet timed out
+	no_response,
+	// The app closed the connection without answering the request
+	app_closed,
+};
+
+enum probe_closer {
+	nobody,
+	kubelet,
+	app,
+};
+
+// conn_key identifies a probe connection from the side of the kubelet
+struct conn_key {
+	__u8 saddr[16];
+	__u8 daddr[16];
+	__u16 sport;
+	__u16 dport;
+	__u16 family;
+};
+
+struct probe {
+	__u64 connect_ts;
+	__u64 established_ts;
+	__u64 accept_ts;
+	__u64 request_ts;
+	__u64 response_ts;
+	__u64 close_ts;
+	struct gadget_process proc;
+	gadget_netns_id netns_id;
+	__u32 syn_retrans;
+	__u32 syns_received;
+	enum probe_closer closed_by;
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	gadget_netns_id netns_id;
+	// The process of the app owning the socket the kubelet connected to
+	struct gadget_process proc;
+
+	// The kubelet
+	struct gadget_l4endpoint_t src;
+	// The pod
+	struct gadget_l4endpoint_t dst;
+
+	enum probe_outcome outcome_raw;
+	enum probe_closer closed_by_raw;
+	__u32 syn_retrans;
+	__u32 syns_received;
+
+	// Time between connect() and the establishment of the connection
+	__u64 connect_us;
+	// Time between the establishment and the accept() of the connection
+	__u64 accept_us;
+	// Time between the request and the first byte of the response
+	__u64 response_us;
+	// Time between connect() and the close of the connection
+	__u64 duration_us;
+};
+
+const volatile bool failed_only = false;
+GADGET_PARAM(failed_only);
+
+// The probe connections of the kubelet in progress
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct conn_key);
+	__type(value, struct probe);
+} probes SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+GADGET_TRACER(probes, events, event);
+
+static const char kubelet_comm[] = "kubelet";
+
+static __always_inline bool is_kubelet(void)
+{
+	char comm[TASK_COMM_LEN];
+
+	bpf_get_current_comm(comm, sizeof(comm));
+	for (int i = 0; i < sizeof(kubelet_comm); i++) {
+		if (comm[i] != kubelet_comm[i])
+			return false;
+	}
+	return true;
+}
+
+// fill_key reads the addresses and ports of the socket. reverse is used for
+// the sockets of the app, whose local address is the destination of the
+// kubelet.
+static __always_inline int fill_key(const struct sock *sk, struct conn_key *key,
+				    bool reverse)
+{
+	struct inet_sock *sockp = (struct inet_sock *)sk;
+	__u16 local_port, remote_port;
+	__u8 *local = reverse ? key->daddr : key->saddr;
+	__u8 *remote = reverse ? key->saddr : key->daddr;
+
+	key->family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	switch (key->family) {
+	case AF_INET:
+		BPF_CORE_READ_INTO(local, sk, __sk_common.skc_rcv_saddr);
+		BPF_CORE_READ_INTO(remote, sk, __sk_common.skc_daddr);
+		break;
+	case AF_INET6:
+		BPF_CORE_READ_INTO(local, sk,
+				   __sk_common.skc_v6_rcv_saddr.in6_u.u6_addr32);
+		BPF_CORE_READ_INTO(remote, sk,
+				   __sk_common.skc_v6_daddr.in6_u.u6_addr32);
+		break;
+	default:
+		return -1;
+	}
+
+	local_port = bpf_ntohs(BPF_CORE_READ(sockp, inet_sport));
+	remote_port = bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+	key->sport = reverse ? remote_port : local_port;
+	key->dport = reverse ? local_port : remote_port;
+	return 0;
+}
+
+// fill_key_from_skb reads the addresses and ports of a packet sent by the
+// kubelet
+static __always_inline int fill_key_from_skb(struct sk_buff *skb,
+					     struct conn_key *key)
+{
+	unsigned char *head = BPF_CORE_READ(skb, head);
+	__u16 network_header = BPF_CORE_READ(skb, network_header);
+	__u16 transport_header = BPF_CORE_READ(skb, transport_header);
+	__u16 protocol = bpf_ntohs(BPF_CORE_READ(skb, protocol));
+	struct tcphdr tcph;
+
+	switch (protocol) {
+	case ETH_P_IP: {
+		struct iphdr iph;
+
+		if (bpf_probe_read_kernel(&iph, sizeof(iph),
+					  head + network_header))
+			return -1;
+		key->family = AF_INET;
+		__builtin_memcpy(key->saddr, &iph.saddr, 4);
+		__builtin_memcpy(key->daddr, &iph.daddr, 4);
+		break;
+	}
+	case ETH_P_IPV6: {
+		struct ipv6hdr ip6h;
+
+		if (bpf_probe_read_kernel(&ip6h, sizeof(ip6h),
+					  head + network_header))
+			return -1;
+		key->family = AF_INET6;
+		__builtin_memcpy(key->saddr, &ip6h.saddr, 16);
+		__builtin_memcpy(key->daddr, &ip6h.daddr, 16);
+		break;
+	}
+	default:
+		return -1;
+	}
+
+	if (bpf_probe_read_kernel(&tcph, sizeof(tcph), head + transport_header))
+		return -1;
+	key->sport = bpf_ntohs(tcph.source);
+	key->dport = bpf_ntohs(tcph.dest);
+	return 0;
+}
+
+static __always_inline void fill_endpoint(struct gadget_l4endpoint_t *endpoint,
+					  __u8 *addr, __u16 port, __u16 family)
+{
+	endpoint->proto_raw = IPPROTO_TCP;
+	endpoint->port = port;
+	if (family == AF_INET) {
+		endpoint->version = 4;
+		__builtin_memcpy(&endpoint->addr_raw.v4, addr, 4);
+	} else {
+		endpoint->version = 6;
+		__builtin_memcpy(endpoint->addr_raw.v6, addr, 16);
+	}
+}
+
+static __always_inline enum probe_outcome get_outcome(struct probe *p)
+{
+	if (!p->established_ts)
+		return p->syn_retrans ? syn_dropped : refused;
+	// TCP probes don't send anything, the connection is enough
+	if (!p->request_ts || p->response_ts)
+		return ok;
+	if (p->closed_by == app)
+		return app_closed;
+	if (!p->accept_ts)
+		return not_accepted;
+	return no_response;
+}
+
+static __always_inline __u64 elapsed_us(__u64 from, __u64 to)
+{
+	if (!from || !to || to < from)
+		return 0;
+	return (to - from) / 1000;
+}
+
+static __always_inline void submit_probe(void *ctx, struct conn_key *key,
+					 struct probe *p)
+{
+	enum probe_outcome outcome = get_outcome(p);
+	struct event *event;
+
+	if (failed_only && outcome == ok)
+		return;
+
+	// The probes that didn't reach a socket of the app can't be attributed
+	// to a container
+	if (p->proc.mntns_id ? gadget_should_discard_mntns_id(p->proc.mntns_id) :
+			       gadget_filter_by_mntns)
+		return;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return;
+
+	event->timestamp_raw = p->connect_ts;
+	event->netns_id = p->netns_id;
+	event->proc = p->proc;
+	fill_endpoint(&event->src, key->saddr, key->sport, key->family);
+	fill_endpoint(&event->dst, key->daddr, key->dport, key->family);
+
+	event->outcome_raw = outcome;
+	event->closed_by_raw = p->closed_by;
+	event->syn_retrans = p->syn_retrans;
+	event->syns_received = p->syns_received;
+
+	event->connect_us = elapsed_us(p->connect_ts, p->established_ts);
+	event->accept_us = elapsed_us(p->established_ts, p->accept_ts);
+	event->response_us = elapsed_us(p->request_ts, p->response_ts);
+	event->duration_us = elapsed_us(p->connect_ts, p->close_ts);
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+}
+
+// The addresses and the source port of the socket are set when tcp_connect()
+// is called, unlike when the socket enters TCP_SYN_SENT
+SEC("kprobe/tcp_connect")
+int BPF_KPROBE(ig_probe_conn, struct sock *sk)
+{
+	struct conn_key key = {};
+	struct probe p = {};
+
+	if (!is_kubelet())
+		return 0;
+
+	if (fill_key(sk, &key, false))
+		return 0;
+
+	p.connect_ts = bpf_ktime_get_boot_ns();
+	bpf_map_update_elem(&probes, &key, &p, BPF_ANY);
+	return 0;
+}
+
+SEC("tracepoint/tcp/tcp_retransmit_skb")
+int ig_probe_retrans(struct trace_event_raw_tcp_event_sk_skb *ctx)
+{
+	const struct sock *sk = ctx->skaddr;
+	struct conn_key key = {};
+	struct probe *p;
+
+	if (ctx->state != TCP_SYN_SENT)
+		return 0;
+
+	if (fill_key(sk, &key, false))
+		return 0;
+
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (p)
+		__sync_fetch_and_add(&p->syn_retrans, 1);
+	return 0;
+}
+
+// A SYN of the kubelet reached the listening socket of the app
+SEC("kprobe/tcp_conn_request")
+int BPF_KPROBE(ig_probe_syn, struct request_sock_ops *rsk_ops,
+	       const struct tcp_request_sock_ops *af_ops, struct sock *sk,
+	       struct sk_buff *skb)
+{
+	struct conn_key key = {};
+	struct sockets_value *skb_val;
+	struct probe *p;
+
+	if (fill_key_from_skb(skb, &key))
+		return 0;
+
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (!p)
+		return 0;
+
+	__sync_fetch_and_add(&p->syns_received, 1);
+	BPF_CORE_READ_INTO(&p->netns_id, sk, __sk_common.skc_net.net, ns.inum);
+	if (p->proc.mntns_id)
+		return 0;
+
+	skb_val = gadget_socket_lookup(sk, p->netns_id);
+	if (skb_val)
+		gadget_process_populate_from_socket(skb_val, &p->proc);
+	return 0;
+}
+
+SEC("kretprobe/inet_csk_accept")
+int BPF_KRETPROBE(ig_probe_accept, struct sock *sk)
+{
+	struct conn_key key = {};
+	struct probe *p;
+
+	if (!sk)
+		return 0;
+
+	if (fill_key(sk, &key, true))
+		return 0;
+
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (!p || p->accept_ts)
+		return 0;
+
+	p->accept_ts = bpf_ktime_get_boot_ns();
+	// Prefer the process that accepted the connection to the one that
+	// created the listening socket
+	gadget_process_populate(&p->proc);
+	return 0;
+}
+
+SEC("kprobe/tcp_sendmsg")
+int BPF_KPROBE(ig_probe_send, struct sock *sk)
+{
+	struct conn_key key = {};
+	struct probe *p;
+
+	if (fill_key(sk, &key, false))
+		return 0;
+
+	// The kubelet sends the request
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (p) {
+		if (!p->request_ts)
+			p->request_ts = bpf_ktime_get_boot_ns();
+		return 0;
+	}
+
+	// The app sends the response
+	if (fill_key(sk, &key, true))
+		return 0;
+
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (p && !p->response_ts)
+		p->response_ts = bpf_ktime_get_boot_ns();
+	return 0;
+}
+
+SEC("tracepoint/sock/inet_sock_set_state")
+int ig_probe_state(struct trace_event_raw_inet_sock_set_state *ctx)
+{
+	const struct sock *sk = ctx->skaddr;
+	struct conn_key key = {};
+	struct probe *p;
+	__u64 now;
+
+	if (ctx->protocol != IPPROTO_TCP)
+		return 0;
+
+	if (fill_key(sk, &key, false))
+		return 0;
+
+	now = bpf_ktime_get_boot_ns();
+
+	p = bpf_map_lookup_elem(&probes, &key);
+	if (!p) {
+		// The socket of the app is established
+		if (ctx->newstate != TCP_ESTABLISHED ||
+		    fill_key(sk, &key, true))
+			return 0;
+		p = bpf_map_lookup_elem(&probes, &key);
+		if (p && !p->netns_id)
+			BPF_CORE_READ_INTO(&p->netns_id, sk,
+					   __sk_common.skc_net.net, ns.inum);
+		return 0;
+	}
+
+	switch (ctx->newstate) {
+	case TCP_ESTABLISHED:
+		p->established_ts = now;
+		break;
+	case TCP_FIN_WAIT1:
+		if (!p->close_ts) {
+			p->close_ts = now;
+			p->closed_by = kubelet;
+		}
+		break;
+	case TCP_CLOSE_WAIT:
+		if (!p->close_ts) {
+			p->close_ts = now;
+			p->closed_by = app;
+		}
+		break;
+	case TCP_CLOSE:
+		if (!p->close_ts)
+			p->close_ts = now;
+		submit_probe(ctx, &key, p);
+		bpf_map_delete_elem(&probes, &key);
+		break;
+	}
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,141 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceKubeletProbesEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	NetNsID   uint64            `json:"netns_id"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Src      utils.L4Endpoint `json:"src"`
+	Dst      utils.L4Endpoint `json:"dst"`
+	Outcome  string           `json:"outcome"`
+	ClosedBy string           `json:"closed_by"`
+}
+
+const probePort = 8080
+
+func TestTraceKubeletProbes(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	if utils.CurrentTestComponent != utils.KubectlGadgetTestComponent && utils.CurrentTestComponent != utils.IgK8sTestComponent {
+		// The probes are run by the kubelet
+		t.Skip("Test only runs for kubectl-gadget and ig-k8s")
+	}
+
+	containerFactory := &containers.K8sManager{}
+	containerName := "test-trace-kubelet-probes"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{containers.WithContainerImage(containerImage)}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-kubelet-probes")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	containerOpts = append(containerOpts, containers.WithReadinessProbe(probePort))
+
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		fmt.Sprintf("mkdir /www && echo ok > /www/index.html && httpd -f -p %d -h /www", probePort),
+		containerOpts...,
+	)
+
+	testContainer.Start(t)
+	t.Cleanup(func() {
+		testContainer.Stop(t)
+	})
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{utils.WithContainerImageName(containerImage)}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgK8sTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime), "--timeout=5"))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns), "--timeout=5"))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts, igrunner.WithValidateOutput(
+		func(t *testing.T, output string) {
+			expectedEntry := &traceKubeletProbesEvent{
+				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+				// httpd accepts the connections of the probes
+				Proc: utils.BuildProc("httpd", 0, 0),
+				Src: utils.L4Endpoint{
+					Addr:    utils.NormalizedStr,
+					Version: 4,
+					Port:    utils.NormalizedInt,
+					Proto:   "TCP",
+				},
+				Dst: utils.L4Endpoint{
+					Addr:    utils.NormalizedStr,
+					Version: 4,
+					Port:    probePort,
+					Proto:   "TCP",
+				},
+				Outcome: "ok",
+
+				// Check the existence of the following fields
+				Timestamp: utils.NormalizedStr,
+				NetNsID:   utils.NormalizedInt,
+				ClosedBy:  utils.NormalizedStr,
+			}
+			expectedEntry.Runtime.ContainerID = utils.NormalizedStr
+
+			normalize := func(e *traceKubeletProbesEvent) {
+				utils.NormalizeCommonData(&e.CommonData)
+				utils.NormalizeString(&e.Runtime.ContainerID)
+				utils.NormalizeString(&e.Timestamp)
+				utils.NormalizeInt(&e.NetNsID)
+				utils.NormalizeProc(&e.Proc)
+				utils.NormalizeString(&e.Src.Addr)
+				utils.NormalizeInt(&e.Src.Port)
+				utils.NormalizeString(&e.Dst.Addr)
+				// Depending on the version of the kubelet, it or httpd closes
+				// the connection first
+				utils.NormalizeString(&e.ClosedBy)
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	))
+
+	traceKubeletProbesCmd := igrunner.New("trace_kubelet_probes", runnerOpts...)
+
+	igtesting.RunTestSteps([]igtesting.TestStep{traceKubeletProbesCmd}, t, testingOpts...)
+}
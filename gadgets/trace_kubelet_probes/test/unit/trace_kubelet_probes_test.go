@@ -0,0 +1,282 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTraceKubeletProbesEvent struct {
+	Proc ebpftypes.Process `json:"proc"`
+
+	NetNsID    uint64           `json:"netns_id"`
+	Src        utils.L4Endpoint `json:"src"`
+	Dst        utils.L4Endpoint `json:"dst"`
+	Outcome    string           `json:"outcome"`
+	ClosedBy   string           `json:"closed_by"`
+	SynRetrans uint32           `json:"syn_retrans"`
+}
+
+type probeKind int
+
+const (
+	// The app answers the request
+	probeAnswered probeKind = iota
+	// The app accepts the connection but doesn't answer the request
+	probeUnanswered
+	// Nothing listens on the port
+	probeRefused
+)
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	probe          probeKind
+	failedOnly     string
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+	validateEvent  func(t *testing.T, info *utilstest.RunnerInfo, port int, events []ExpectedTraceKubeletProbesEvent)
+}
+
+func TestTraceKubeletProbesGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+	runnerConfig := &utilstest.RunnerConfig{}
+
+	testCases := map[string]testDef{
+		"captures_successful_probes": {
+			runnerConfig: runnerConfig,
+			probe:        probeAnswered,
+			failedOnly:   "false",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectOneEvent(func(info *utilstest.RunnerInfo, port int) *ExpectedTraceKubeletProbesEvent {
+				return &ExpectedTraceKubeletProbesEvent{
+					Proc:     info.Proc,
+					NetNsID:  info.NetworkNsID,
+					Src:      endpoint(utils.NormalizedInt),
+					Dst:      endpoint(port),
+					Outcome:  "ok",
+					ClosedBy: "kubelet",
+				}
+			}),
+		},
+		"captures_unanswered_probes": {
+			runnerConfig: runnerConfig,
+			probe:        probeUnanswered,
+			failedOnly:   "true",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectOneEvent(func(info *utilstest.RunnerInfo, port int) *ExpectedTraceKubeletProbesEvent {
+				return &ExpectedTraceKubeletProbesEvent{
+					Proc:     info.Proc,
+					NetNsID:  info.NetworkNsID,
+					Src:      endpoint(utils.NormalizedInt),
+					Dst:      endpoint(port),
+					Outcome:  "no_response",
+					ClosedBy: "kubelet",
+				}
+			}),
+		},
+		"ignores_successful_probes_with_failed_only": {
+			runnerConfig: runnerConfig,
+			probe:        probeAnswered,
+			failedOnly:   "true",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectNoEvent[ExpectedTraceKubeletProbesEvent, int],
+		},
+		"captures_refused_probes": {
+			runnerConfig: runnerConfig,
+			probe:        probeRefused,
+			failedOnly:   "false",
+			// The probe doesn't reach any socket, so it can't be attributed
+			// to a container and is only reported without filter
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, port int, events []ExpectedTraceKubeletProbesEvent) {
+				expected := ExpectedTraceKubeletProbesEvent{
+					Src:      endpoint(utils.NormalizedInt),
+					Dst:      endpoint(port),
+					Outcome:  "refused",
+					ClosedBy: "nobody",
+				}
+				// Other probes could run on the host
+				for _, event := range events {
+					if event.Dst.Port == uint16(port) {
+						require.Equal(t, expected, event)
+						return
+					}
+				}
+				t.Fatalf("Event wasn't captured")
+			},
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var port int
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.failed-only": testCase.failedOnly,
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			normalizeEvent := func(event *ExpectedTraceKubeletProbesEvent) {
+				utils.NormalizeInt(&event.Src.Port)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				utilstest.RunWithRunner(t, runner, func() error {
+					var err error
+					port, err = generateEvent(testCase.probe)
+					return err
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTraceKubeletProbesEvent]{
+				Image:          "trace_kubelet_probes",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, port, gadgetRunner.CapturedEvents)
+		})
+	}
+}
+
+func endpoint(port int) utils.L4Endpoint {
+	return utils.L4Endpoint{
+		Addr:    "127.0.0.1",
+		Version: 4,
+		Port:    uint16(port),
+		Proto:   "TCP",
+	}
+}
+
+// setThreadName sets the comm of the calling thread and returns the previous
+// one
+func setThreadName(name string) (string, error) {
+	var prev [16]byte
+	if err := unix.Prctl(unix.PR_GET_NAME, uintptr(unsafe.Pointer(&prev[0])), 0, 0, 0); err != nil {
+		return "", fmt.Errorf("getting thread name: %w", err)
+	}
+	newName, err := unix.BytePtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	if err := unix.Prctl(unix.PR_SET_NAME, uintptr(unsafe.Pointer(newName)), 0, 0, 0); err != nil {
+		return "", fmt.Errorf("setting thread name: %w", err)
+	}
+	return string(bytes.TrimRight(prev[:], "\x00")), nil
+}
+
+// dialAsKubelet connects to addr from a thread named like the kubelet. It
+// must be called from a thread locked by the runner, so the connect() happens
+// on it.
+func dialAsKubelet(addr string) (net.Conn, error) {
+	prev, err := setThreadName("kubelet")
+	if err != nil {
+		return nil, err
+	}
+	conn, dialErr := net.Dial("tcp", addr)
+	if _, err := setThreadName(prev); err != nil {
+		if conn != nil {
+			conn.Close()
+		}
+		return nil, err
+	}
+	return conn, dialErr
+}
+
+// generateEvent runs an HTTP probe against a server listening on the loopback
+// interface and returns the port probed. The server accepts the connection on
+// the thread of the runner, so it's the process of the app.
+func generateEvent(probe probeKind) (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	if probe == probeRefused {
+		ln.Close()
+		conn, err := dialAsKubelet(ln.Addr().String())
+		if err == nil {
+			conn.Close()
+			return 0, fmt.Errorf("connecting to a closed port succeeded")
+		}
+		return port, nil
+	}
+	defer ln.Close()
+
+	conn, err := dialAsKubelet(ln.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	app, err := ln.Accept()
+	if err != nil {
+		return 0, err
+	}
+	defer app.Close()
+
+	if _, err := conn.Write([]byte("GET /healthz HTTP/1.1\r\nHost: 127.0.0.1\r\n\r\n")); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 512)
+	if _, err := app.Read(buf); err != nil {
+		return 0, err
+	}
+	if probe == probeAnswered {
+		if _, err := app.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+			return 0, err
+		}
+		if _, err := conn.Read(buf); err != nil {
+			return 0, err
+		}
+	}
+
+	// The kubelet closes the connection first, like when it times out
+	if err := conn.Close(); err != nil {
+		return 0, err
+	}
+	return port, nil
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+// direct: the allocation stalled because the free memory of the node is below
+// the min watermark.
+// memcg: the allocation stalled because the cgroup of the process reached its
+// memory.high or memory.max limit.
+enum reclaim_type { direct, memcg };
+
+struct start_t {
+	__u64 ts;
+	enum reclaim_type type;
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	enum reclaim_type type_raw;
+	__u64 delta_us;
+	__u64 nr_reclaimed;
+};
+
+const volatile __u64 min_lat_us = 1000;
+
+GADGET_PARAM(min_lat_us);
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 10240);
+	__type(key, u32);
+	__type(value, struct start_t);
+} start SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(reclaims, events, event);
+
+static __always_inline int reclaim_begin(enum reclaim_type type)
+{
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct start_t s = {};
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	s.ts = bpf_ktime_get_ns();
+	s.type = type;
+	bpf_map_update_elem(&start, &tid, &s, 0);
+	return 0;
+}
+
+static __always_inline int reclaim_end(void *ctx, __u64 nr_reclaimed)
+{
+	u32 tid = (u32)bpf_get_current_pid_tgid();
+	struct start_t *s;
+	struct event *event;
+	__u64 delta_us;
+
+	s = bpf_map_lookup_elem(&start, &tid);
+	if (!s)
+		return 0;
+
+	delta_us = (bpf_ktime_get_ns() - s->ts) / 1000;
+	if (delta_us < min_lat_us)
+		goto cleanup;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		goto cleanup;
+
+	gadget_process_populate(&event->proc);
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	event->type_raw = s->type;
+	event->delta_us = delta_us;
+	event->nr_reclaimed = nr_reclaimed;
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+cleanup:
+	bpf_map_delete_elem(&start, &tid);
+	return 0;
+}
+
+SEC("tracepoint/vmscan/mm_vmscan_direct_reclaim_begin")
+int ig_direct_reclaim_begin(void *ctx)
+{
+	return reclaim_begin(direct);
+}
+
+SEC("tracepoint/vmscan/mm_vmscan_direct_reclaim_end")
+int ig_direct_reclaim_end(
+	struct trace_event_raw_mm_vmscan_direct_reclaim_end_template *ctx)
+{
+	return reclaim_end(ctx, ctx->nr_reclaimed);
+}
+
+SEC("tracepoint/vmscan/mm_vmscan_memcg_reclaim_begin")
+int ig_memcg_reclaim_begin(void *ctx)
+{
+	return reclaim_begin(memcg);
+}
+
+SEC("tracepoint/vmscan/mm_vmscan_memcg_reclaim_end")
+int ig_memcg_reclaim_end(
+	struct trace_event_raw_mm_vmscan_direct_reclaim_end_template *ctx)
+{
+	return reclaim_end(ctx, ctx->nr_reclaimed);
+}
+
+char LICENSE[] SEC("license") = "GPL";
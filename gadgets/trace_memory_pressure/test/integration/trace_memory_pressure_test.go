@@ -0,0 +1,139 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceMemoryPressureEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Type           string `json:"type"`
+	DeltaUs        uint64 `json:"delta_us"`
+	NrReclaimed    uint64 `json:"nr_reclaimed"`
+	QoSClass       string `json:"qos_class"`
+	MemoryLimit    uint64 `json:"memory_limit"`
+	EvictionRisk   string `json:"eviction_risk"`
+	EvictionReason string `json:"eviction_reason"`
+}
+
+func TestTraceMemoryPressure(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	if utils.CurrentTestComponent != utils.KubectlGadgetTestComponent && utils.CurrentTestComponent != utils.IgK8sTestComponent {
+		// We have no general way to enforce memory limits for all container runtimes
+		t.Skip("Test only runs for kubectl-gadget and ig-k8s")
+	}
+
+	containerFactory := &containers.K8sManager{}
+	containerName := "test-trace-memory-pressure"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{containers.WithContainerImage(containerImage)}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-memory-pressure")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	containerOpts = append(containerOpts, containers.WithLimits(map[string]string{"memory": "128Mi"}), containers.WithStartAndStop())
+
+	// Keep the cgroup close to its limit so the allocations stall reclaiming
+	// the page cache
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		"while true; do head -c 120m /dev/zero > /tmp/fill; cat /tmp/fill > /dev/null; rm /tmp/fill; done",
+		containerOpts...,
+	)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{utils.WithContainerImageName(containerImage)}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgK8sTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime), "--min=0"))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns), "--min=0"))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop(), igrunner.WithValidateOutput(
+		func(t *testing.T, output string) {
+			expectedEntry := &traceMemoryPressureEvent{
+				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+				Type:       "memcg",
+				// Requests default to the limits, so the pod is Guaranteed
+				QoSClass:    "Guaranteed",
+				MemoryLimit: 128 * 1024 * 1024,
+
+				// Check the existence of the following fields
+				Timestamp:      utils.NormalizedStr,
+				Proc:           utils.BuildProc(utils.NormalizedStr, 0, 0),
+				DeltaUs:        utils.NormalizedInt,
+				NrReclaimed:    utils.NormalizedInt,
+				EvictionRisk:   utils.NormalizedStr,
+				EvictionReason: utils.NormalizedStr,
+			}
+			expectedEntry.Runtime.ContainerID = utils.NormalizedStr
+
+			normalize := func(e *traceMemoryPressureEvent) {
+				utils.NormalizeCommonData(&e.CommonData)
+				utils.NormalizeString(&e.Runtime.ContainerID)
+				utils.NormalizeString(&e.Timestamp)
+				utils.NormalizeProc(&e.Proc)
+				utils.NormalizeString(&e.Proc.Comm)
+				// NormalizeInt only normalizes if the value is not 0, and
+				// both might be 0 with --min=0
+				e.DeltaUs = utils.NormalizedInt
+				e.NrReclaimed = utils.NormalizedInt
+				utils.NormalizeString(&e.EvictionRisk)
+				// The reason is empty while the working set is below 90% of
+				// the limit
+				e.EvictionReason = utils.NormalizedStr
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	))
+
+	traceMemoryPressureCmd := igrunner.New("trace_memory_pressure", runnerOpts...)
+
+	testSteps := []igtesting.TestStep{
+		traceMemoryPressureCmd,
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+
+	igtesting.RunTestSteps(testSteps, t, testingOpts...)
+}
@@ -5,9 +5,11 @@
 #include <bpf/bpf_core_read.h>
 #include <gadget/buffer.h>
 #include <gadget/common.h>
+#include <gadget/kernel_stack_map.h>
 #include <gadget/macros.h>
 #include <gadget/mntns_filter.h>
 #include <gadget/types.h>
+#include <gadget/user_stack_map.h>
 
 #define MAX_ENTRIES 10240
 #define FS_NAME_LEN 8
@@ -76,11 +78,15 @@ struct event {
 	char dest[PATH_MAX];
 	char data[DATA_LEN];
 	enum op op_raw;
+	gadget_kernel_stack kstack_raw;
+	gadget_user_stack ustack_raw;
 };
 
 const volatile pid_t target_pid = 0;
+const volatile bool collect_stack = false;
 
 GADGET_PARAM(target_pid);
+GADGET_PARAM(collect_stack);
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
@@ -145,6 +151,13 @@ static int probe_exit(void *ctx, int ret)
 	eventp->flags_raw = argp->flags;
 	eventp->error_raw = -ret;
 	eventp->op_raw = argp->op;
+	if (collect_stack) {
+		eventp->kstack_raw = gadget_get_kernel_stack(ctx);
+		eventp->ustack_raw = gadget_get_user_stack(ctx);
+	} else {
+		eventp->kstack_raw = -1;
+		eventp->ustack_raw = -1;
+	}
 	if (argp->src)
 		bpf_probe_read_user_str(eventp->src, sizeof(eventp->src),
 					argp->src);
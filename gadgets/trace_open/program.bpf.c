@@ -8,9 +8,11 @@
 
 #include <gadget/buffer.h>
 #include <gadget/common.h>
+#include <gadget/kernel_stack_map.h>
 #include <gadget/macros.h>
 #include <gadget/mntns_filter.h>
 #include <gadget/types.h>
+#include <gadget/user_stack_map.h>
 
 #define TASK_RUNNING 0
 #define NAME_MAX 255
@@ -30,6 +32,8 @@ struct event {
 	__u32 fd;
 	int flags_raw;
 	__u16 mode_raw;
+	gadget_kernel_stack kstack_raw;
+	gadget_user_stack ustack_raw;
 	char fname[NAME_MAX];
 };
 
@@ -37,10 +41,12 @@ const volatile pid_t targ_pid = 0;
 const volatile pid_t targ_tgid = 0;
 const volatile uid_t targ_uid = INVALID_UID;
 const volatile bool targ_failed = false;
+const volatile bool collect_stack = false;
 
 GADGET_PARAM(targ_tgid);
 GADGET_PARAM(targ_uid);
 GADGET_PARAM(targ_failed);
+GADGET_PARAM(collect_stack);
 
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
@@ -158,6 +164,13 @@ static __always_inline int trace_exit(struct syscall_trace_exit *ctx)
 	event->error_raw = errval;
 	event->fd = fd;
 	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	if (collect_stack) {
+		event->kstack_raw = gadget_get_kernel_stack(ctx);
+		event->ustack_raw = gadget_get_user_stack(ctx);
+	} else {
+		event->kstack_raw = -1;
+		event->ustack_raw = -1;
+	}
 
 	/* emit event */
 	gadget_submit_buf(ctx, &events, event, sizeof(*event));
@@ -0,0 +1,544 @@
+/* SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause) */
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+
+#include <gadget/buffer.h>
+#include <gadget/macros.h>
+#include <gadget/types.h>
+
+/* Define here, because there are conflicts with include files */
+#define IPPROTO_TCP 6
+#define IPPROTO_UDP 17
+#define EPERM 1
+
+#define TC_ACT_SHOT 2
+#define TC_ACT_STOLEN 4
+#define TC_ACT_QUEUED 5
+#define TC_ACT_REDIRECT 7
+#define TC_ACT_TRAP 8
+
+#define NF_ACCEPT 1
+
+#define MAX_ENTRIES 10240
+
+// The kernel hooks a packet goes through, in the order they are usually
+// traversed
+enum stage {
+	// Received on an interface
+	receive,
+	// Classified by the tc programs and filters of an interface
+	tc,
+	// Went through a netfilter hook, like iptables chains
+	netfilter,
+	// Routed after being received
+	routing,
+	// Forwarded to another interface
+	forwarding,
+	// Delivered to a local socket
+	local_delivery,
+	// Sent by a local socket or forwarded
+	output,
+	// Queued for transmission on an interface
+	transmit,
+	// Dropped by the kernel
+	drop,
+};
+
+enum verdict {
+	pass,
+	dropped,
+	// Taken by the hook, like queued to user space or consumed by a tc
+	// program
+	stolen,
+	// Redirected to another interface by a tc program
+	redirected,
+};
+
+// The netfilter hooks, shifted by one from the kernel ones so 0 means none
+enum nf_hook {
+	no_hook,
+	prerouting,
+	local_in,
+	forward,
+	local_out,
+	postrouting,
+};
+
+enum nat {
+	no_nat,
+	snat,
+	dnat,
+	snat_dnat,
+};
+
+// flow is the headers of a packet
+struct flow {
+	struct gadget_l4endpoint_t src;
+	struct gadget_l4endpoint_t dst;
+};
+
+// hook_args is saved between the entry and the return of a hook
+struct hook_args {
+	struct sk_buff *skb;
+	struct flow flow;
+	__u8 nf_hook;
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	gadget_netns_id netns_id;
+
+	// Identifies the packet across the events
+	__u64 skb_id;
+
+	struct gadget_l4endpoint_t src;
+	struct gadget_l4endpoint_t dst;
+
+	enum stage stage_raw;
+	enum verdict verdict_raw;
+	// Only set for the netfilter stage
+	enum nf_hook hook_raw;
+	enum nat nat_raw;
+	// Only set for the drop stage
+	enum skb_drop_reason reason_raw;
+
+	__u32 ifindex;
+	char ifname[16];
+};
+
+// The packets are selected by the host they come from or go to, by one of
+// their ports and by their protocol. 0.0.0.0 or 0 match everything.
+const volatile struct gadget_l3endpoint_t host = {};
+const volatile __u16 port = 0;
+const volatile __u16 proto = 0;
+
+GADGET_PARAM(host);
+GADGET_PARAM(port);
+GADGET_PARAM(proto);
+
+// The packets selected, by the address of their skb. They're followed even
+// when their headers change, after being NATed for instance.
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u64);
+	__type(value, __u8);
+} skbs SEC(".maps");
+
+// The arguments of the hooks whose verdict is known when they return, by
+// thread
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u64);
+	__type(value, struct hook_args);
+} nf_args SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u64);
+	__type(value, struct hook_args);
+} tc_args SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u64);
+	__type(value, struct hook_args);
+} route_args SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+GADGET_TRACER(packets, events, event);
+
+// parse_flow reads the IP and TCP/UDP headers of skb. from_data is used when
+// the network header isn't set yet, right after the packet was received.
+static __always_inline int parse_flow(struct sk_buff *skb, struct flow *flow,
+				      bool from_data)
+{
+	unsigned char *head = BPF_CORE_READ(skb, head);
+	unsigned char *l3;
+	unsigned char *l4;
+	__u8 version, l4proto;
+
+	if (from_data)
+		l3 = BPF_CORE_READ(skb, data);
+	else
+		l3 = head + BPF_CORE_READ(skb, network_header);
+
+	if (bpf_probe_read_kernel(&version, sizeof(version), l3))
+		return -1;
+	version >>= 4;
+
+	__builtin_memset(flow, 0, sizeof(*flow));
+
+	switch (version) {
+	case 4: {
+		struct iphdr iph;
+
+		if (bpf_probe_read_kernel(&iph, sizeof(iph), l3))
+			return -1;
+		flow->src.addr_raw.v4 = iph.saddr;
+		flow->dst.addr_raw.v4 = iph.daddr;
+		l4proto = iph.protocol;
+		l4 = l3 + iph.ihl * 4;
+		break;
+	}
+	case 6: {
+		struct ipv6hdr ip6h;
+
+		if (bpf_probe_read_kernel(&ip6h, sizeof(ip6h), l3))
+			return -1;
+		bpf_probe_read_kernel(flow->src.addr_raw.v6,
+				      sizeof(flow->src.addr_raw.v6),
+				      &ip6h.saddr);
+		bpf_probe_read_kernel(flow->dst.addr_raw.v6,
+				      sizeof(flow->dst.addr_raw.v6),
+				      &ip6h.daddr);
+		// Extension headers aren't followed
+		l4proto = ip6h.nexthdr;
+		l4 = l3 + sizeof(ip6h);
+		break;
+	}
+	default:
+		return -1;
+	}
+
+	flow->src.version = flow->dst.version = version;
+	flow->src.proto_raw = flow->dst.proto_raw = l4proto;
+
+	if (l4proto == IPPROTO_TCP || l4proto == IPPROTO_UDP) {
+		__be16 ports[2];
+
+		if (bpf_probe_read_kernel(ports, sizeof(ports), l4) == 0) {
+			flow->src.port = bpf_ntohs(ports[0]);
+			flow->dst.port = bpf_ntohs(ports[1]);
+		}
+	}
+
+	return 0;
+}
+
+static __always_inline bool is_any_addr(void)
+{
+	for (int i = 0; i < sizeof(host.addr_raw.v6); i++) {
+		if (host.addr_raw.v6[i])
+			return false;
+	}
+	return true;
+}
+
+static __always_inline bool addr_equal(const struct gadget_l4endpoint_t *ep)
+{
+	if (ep->version != host.version)
+		return false;
+	if (ep->version == 4)
+		return ep->addr_raw.v4 == host.addr_raw.v4;
+	for (int i = 0; i < sizeof(host.addr_raw.v6); i++) {
+		if (ep->addr_raw.v6[i] != host.addr_raw.v6[i])
+			return false;
+	}
+	return true;
+}
+
+static __always_inline bool flow_selected(const struct flow *flow)
+{
+	if (proto && flow->src.proto_raw != proto)
+		return false;
+	if (port && flow->src.port != port && flow->dst.port != port)
+		return false;
+	if (!is_any_addr() && !addr_equal(&flow->src) &&
+	    !addr_equal(&flow->dst))
+		return false;
+	return true;
+}
+
+// follow tells whether the packet is traced, and starts following it if it's
+// selected
+static __always_inline bool follow(struct sk_buff *skb, struct flow *flow)
+{
+	__u64 skb_id = (__u64)skb;
+	__u8 one = 1;
+
+	if (bpf_map_lookup_elem(&skbs, &skb_id))
+		return true;
+	if (!flow_selected(flow))
+		return false;
+	bpf_map_update_elem(&skbs, &skb_id, &one, BPF_ANY);
+	return true;
+}
+
+// submit sends an event for the packet. Its interface isn't read when it was
+// dropped or stolen, as skb may be freed.
+static __always_inline void submit(void *ctx, struct sk_buff *skb,
+				   const struct flow *flow, enum stage stage,
+				   enum verdict verdict, enum nf_hook hook,
+				   enum nat nat)
+{
+	struct net_device *dev = NULL;
+	struct event *event;
+
+	if (verdict == pass || verdict == redirected)
+		dev = BPF_CORE_READ(skb, dev);
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return;
+
+	__builtin_memset(event, 0, sizeof(*event));
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	event->skb_id = (__u64)skb;
+	event->src = flow->src;
+	event->dst = flow->dst;
+	event->stage_raw = stage;
+	event->verdict_raw = verdict;
+	event->hook_raw = hook;
+	event->nat_raw = nat;
+
+	if (dev) {
+		event->ifindex = BPF_CORE_READ(dev, ifindex);
+		bpf_probe_read_kernel_str(event->ifname, sizeof(event->ifname),
+					  dev->name);
+		event->netns_id = BPF_CORE_READ(dev, nd_net.net, ns.inum);
+	} else if (verdict == pass) {
+		event->netns_id =
+			BPF_CORE_READ(skb, sk, __sk_common.skc_net.net, ns.inum);
+	}
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+}
+
+static __always_inline int trace_stage(void *ctx, struct sk_buff *skb,
+				       enum stage stage, bool from_data)
+{
+	struct flow flow;
+
+	if (!skb || parse_flow(skb, &flow, from_data))
+		return 0;
+	if (!follow(skb, &flow))
+		return 0;
+
+	submit(ctx, skb, &flow, stage, pass, no_hook, no_nat);
+	return 0;
+}
+
+// save_args saves the packet and its headers when a hook whose verdict is
+// known when it returns is entered
+static __always_inline int save_args(void *map, struct sk_buff *skb,
+				     __u8 nf_hook)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	struct hook_args args = {};
+
+	if (!skb || parse_flow(skb, &args.flow, false))
+		return 0;
+	if (!follow(skb, &args.flow))
+		return 0;
+
+	args.skb = skb;
+	args.nf_hook = nf_hook;
+	bpf_map_update_elem(map, &pid_tgid, &args, BPF_ANY);
+	return 0;
+}
+
+static __always_inline struct hook_args *pop_args(void *map)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	struct hook_args *args;
+
+	args = bpf_map_lookup_elem(map, &pid_tgid);
+	if (!args)
+		return NULL;
+	bpf_map_delete_elem(map, &pid_tgid);
+	return args;
+}
+
+static __always_inline bool same_endpoint(const struct gadget_l4endpoint_t *a,
+				      const struct gadget_l4endpoint_t *b)
+{
+	for (int i = 0; i < sizeof(a->addr_raw.v6); i++) {
+		if (a->addr_raw.v6[i] != b->addr_raw.v6[i])
+			return false;
+	}
+	return a->port == b->port;
+}
+
+SEC("tracepoint/net/netif_receive_skb")
+int ig_pp_receive(struct trace_event_raw_net_dev_template *ctx)
+{
+	return trace_stage(ctx, (struct sk_buff *)ctx->skbaddr, receive, true);
+}
+
+SEC("tracepoint/net/net_dev_queue")
+int ig_pp_transmit(struct trace_event_raw_net_dev_template *ctx)
+{
+	return trace_stage(ctx, (struct sk_buff *)ctx->skbaddr, transmit,
+			   false);
+}
+
+SEC("kprobe/ip_forward")
+int BPF_KPROBE(ig_pp_forward, struct sk_buff *skb)
+{
+	return trace_stage(ctx, skb, forwarding, false);
+}
+
+SEC("kprobe/ip_local_deliver")
+int BPF_KPROBE(ig_pp_deliver, struct sk_buff *skb)
+{
+	return trace_stage(ctx, skb, local_delivery, false);
+}
+
+SEC("kprobe/ip_output")
+int BPF_KPROBE(ig_pp_output, struct net *net, struct sock *sk,
+	       struct sk_buff *skb)
+{
+	return trace_stage(ctx, skb, output, false);
+}
+
+SEC("kprobe/nf_hook_slow")
+int BPF_KPROBE(ig_pp_nf, struct sk_buff *skb, struct nf_hook_state *state)
+{
+	return save_args(&nf_args, skb, BPF_CORE_READ(state, hook) + 1);
+}
+
+// nf_hook_slow returns 1 if the packet was accepted, -EPERM if it was dropped
+// and 0 if it was stolen or queued. A NAT rule changes the headers of the
+// packet.
+SEC("kretprobe/nf_hook_slow")
+int BPF_KRETPROBE(ig_pp_nf_ret, int ret)
+{
+	struct hook_args *args = pop_args(&nf_args);
+	struct sk_buff *skb;
+	struct flow flow;
+	enum verdict verdict;
+	enum nat nat = no_nat;
+
+	if (!args)
+		return 0;
+
+	skb = args->skb;
+	if (ret == NF_ACCEPT)
+		verdict = pass;
+	else if (ret == -EPERM)
+		verdict = dropped;
+	else
+		verdict = stolen;
+
+	// The packet can't be read anymore if it was dropped or stolen
+	if (verdict != pass || parse_flow(skb, &flow, false))
+		flow = args->flow;
+
+	if (!same_endpoint(&flow.src, &args->flow.src))
+		nat = snat;
+	if (!same_endpoint(&flow.dst, &args->flow.dst))
+		nat = nat == snat ? snat_dnat : dnat;
+
+	submit(ctx, skb, &flow, netfilter, verdict, args->nf_hook, nat);
+	return 0;
+}
+
+SEC("kprobe/tcf_classify")
+int BPF_KPROBE(ig_pp_tc, struct sk_buff *skb)
+{
+	return save_args(&tc_args, skb, no_hook);
+}
+
+SEC("kretprobe/tcf_classify")
+int BPF_KRETPROBE(ig_pp_tc_ret, int ret)
+{
+	struct hook_args *args = pop_args(&tc_args);
+	enum verdict verdict;
+
+	if (!args)
+		return 0;
+
+	switch (ret) {
+	case TC_ACT_SHOT:
+		verdict = dropped;
+		break;
+	case TC_ACT_STOLEN:
+	case TC_ACT_QUEUED:
+	case TC_ACT_TRAP:
+		verdict = stolen;
+		break;
+	case TC_ACT_REDIRECT:
+		verdict = redirected;
+		break;
+	default:
+		verdict = pass;
+		break;
+	}
+
+	submit(ctx, args->skb, &args->flow, tc, verdict, no_hook, no_nat);
+	return 0;
+}
+
+SEC("kprobe/ip_route_input_noref")
+int BPF_KPROBE(ig_pp_route, struct sk_buff *skb)
+{
+	return save_args(&route_args, skb, no_hook);
+}
+
+// ip_route_input_noref returns an error, or a drop reason on recent kernels,
+// when no route was found for the packet
+SEC("kretprobe/ip_route_input_noref")
+int BPF_KRETPROBE(ig_pp_route_ret, int ret)
+{
+	struct hook_args *args = pop_args(&route_args);
+
+	if (!args)
+		return 0;
+
+	submit(ctx, args->skb, &args->flow, routing, ret ? dropped : pass,
+	       no_hook, no_nat);
+	return 0;
+}
+
+SEC("tracepoint/skb/kfree_skb")
+int ig_pp_drop(struct trace_event_raw_kfree_skb *ctx)
+{
+	struct sk_buff *skb = ctx->skbaddr;
+	__u64 skb_id = (__u64)skb;
+	struct flow flow;
+	struct event *event;
+
+	if (!bpf_map_lookup_elem(&skbs, &skb_id))
+		return 0;
+	bpf_map_delete_elem(&skbs, &skb_id);
+
+	if (parse_flow(skb, &flow, false))
+		__builtin_memset(&flow, 0, sizeof(flow));
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return 0;
+
+	__builtin_memset(event, 0, sizeof(*event));
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	event->skb_id = skb_id;
+	event->src = flow.src;
+	event->dst = flow.dst;
+	event->stage_raw = drop;
+	event->verdict_raw = dropped;
+	event->reason_raw = ctx->reason;
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+	return 0;
+}
+
+// The packets that went through the stack successfully are consumed
+SEC("tracepoint/skb/consume_skb")
+int ig_pp_consume(struct trace_event_raw_consume_skb *ctx)
+{
+	__u64 skb_id = (__u64)ctx->skbaddr;
+
+	bpf_map_delete_elem(&skbs, &skb_id);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
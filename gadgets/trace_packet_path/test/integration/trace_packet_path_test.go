@@ -0,0 +1,134 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type tracePacketPathEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string `json:"timestamp"`
+	NetNsID   uint64 `json:"netns_id"`
+	SkbID     uint64 `json:"skb_id"`
+
+	Src     utils.L4Endpoint `json:"src"`
+	Dst     utils.L4Endpoint `json:"dst"`
+	Stage   string           `json:"stage"`
+	Verdict string           `json:"verdict"`
+	Ifindex uint32           `json:"ifindex"`
+	Ifname  string           `json:"ifname"`
+}
+
+func TestTracePacketPath(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-packet-path"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{containers.WithContainerImage(containerImage)}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-packet-path")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// The datagrams stay in the network namespace of the container
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		"while true; do echo hello | nc -u -w 1 127.0.0.1 9090; sleep 0.1; done",
+		containerOpts...,
+	)
+
+	testContainer.Start(t)
+	t.Cleanup(func() {
+		testContainer.Stop(t)
+	})
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{utils.WithContainerImageName(containerImage), utils.WithContainerID(testContainer.ID())}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime), "--timeout=5"))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns), "--timeout=5"))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts, igrunner.WithFlags("--port=9090", "--proto=17"))
+	runnerOpts = append(runnerOpts, igrunner.WithValidateOutput(
+		func(t *testing.T, output string) {
+			expectedEntry := &tracePacketPathEvent{
+				// The events are enriched with the container owning the
+				// network namespace of the interface
+				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+				Src: utils.L4Endpoint{
+					Addr:    "127.0.0.1",
+					Version: 4,
+					Port:    utils.NormalizedInt,
+					Proto:   "UDP",
+				},
+				Dst: utils.L4Endpoint{
+					Addr:    "127.0.0.1",
+					Version: 4,
+					Port:    9090,
+					Proto:   "UDP",
+				},
+				Stage:   "transmit",
+				Verdict: "pass",
+				Ifindex: 1,
+				Ifname:  "lo",
+
+				// Check the existence of the following fields
+				Timestamp: utils.NormalizedStr,
+				NetNsID:   utils.NormalizedInt,
+				SkbID:     utils.NormalizedInt,
+			}
+
+			normalize := func(e *tracePacketPathEvent) {
+				utils.NormalizeCommonData(&e.CommonData)
+				utils.NormalizeString(&e.Timestamp)
+				utils.NormalizeInt(&e.NetNsID)
+				utils.NormalizeInt(&e.SkbID)
+				utils.NormalizeInt(&e.Src.Port)
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	))
+
+	tracePacketPathCmd := igrunner.New("trace_packet_path", runnerOpts...)
+
+	igtesting.RunTestSteps([]igtesting.TestStep{tracePacketPathCmd}, t, testingOpts...)
+}
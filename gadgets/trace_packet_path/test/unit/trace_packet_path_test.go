@@ -0,0 +1,174 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTracePacketPathEvent struct {
+	NetNsID uint64 `json:"netns_id"`
+	SkbID   uint64 `json:"skb_id"`
+
+	Src     utils.L4Endpoint `json:"src"`
+	Dst     utils.L4Endpoint `json:"dst"`
+	Stage   string           `json:"stage"`
+	Verdict string           `json:"verdict"`
+	Hook    string           `json:"hook"`
+	Nat     string           `json:"nat"`
+	Ifindex uint32           `json:"ifindex"`
+	Ifname  string           `json:"ifname"`
+}
+
+type testDef struct {
+	port          int
+	proto         string
+	runnerConfig  *utilstest.RunnerConfig
+	validateEvent func(t *testing.T, info *utilstest.RunnerInfo, port int, events []ExpectedTracePacketPathEvent)
+}
+
+// expectStage expects a packet sent to port on the loopback interface of the
+// runner to go through stage
+func expectStage(stage string) func(t *testing.T, info *utilstest.RunnerInfo, port int, events []ExpectedTracePacketPathEvent) {
+	return utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, port int) *ExpectedTracePacketPathEvent {
+		return &ExpectedTracePacketPathEvent{
+			NetNsID: info.NetworkNsID,
+			SkbID:   utils.NormalizedInt,
+			Src: utils.L4Endpoint{
+				Addr:    "127.0.0.1",
+				Version: 4,
+				Port:    utils.NormalizedInt,
+				Proto:   "UDP",
+			},
+			Dst: utils.L4Endpoint{
+				Addr:    "127.0.0.1",
+				Version: 4,
+				Port:    uint16(port),
+				Proto:   "UDP",
+			},
+			Stage:   stage,
+			Verdict: "pass",
+			Hook:    "no_hook",
+			Nat:     "no_nat",
+			// The runner has its own network namespace, where lo is the
+			// first interface
+			Ifindex: 1,
+			Ifname:  "lo",
+		}
+	})
+}
+
+func TestTracePacketPathGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	testCases := map[string]testDef{
+		"captures_transmit": {
+			port:          9080,
+			proto:         "17",
+			runnerConfig:  &utilstest.RunnerConfig{},
+			validateEvent: expectStage("transmit"),
+		},
+		"captures_receive": {
+			port:          9081,
+			proto:         "17",
+			runnerConfig:  &utilstest.RunnerConfig{},
+			validateEvent: expectStage("receive"),
+		},
+		"captures_local_delivery": {
+			port:          9082,
+			proto:         "17",
+			runnerConfig:  &utilstest.RunnerConfig{},
+			validateEvent: expectStage("local_delivery"),
+		},
+		"ignores_other_protocols": {
+			port:          9083,
+			proto:         "6",
+			runnerConfig:  &utilstest.RunnerConfig{},
+			validateEvent: utilstest.ExpectNoEvent[ExpectedTracePacketPathEvent, int],
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.port":  fmt.Sprint(testCase.port),
+				"operator.oci.ebpf.proto": testCase.proto,
+			}
+
+			normalizeEvent := func(event *ExpectedTracePacketPathEvent) {
+				utils.NormalizeInt(&event.SkbID)
+				utils.NormalizeInt(&event.Src.Port)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				utilstest.RunWithRunner(t, runner, func() error {
+					return generateEvent(testCase.port)
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTracePacketPathEvent]{
+				Image:          "trace_packet_path",
+				Timeout:        5 * time.Second,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, testCase.port, gadgetRunner.CapturedEvents)
+		})
+	}
+}
+
+// generateEvent sends an UDP datagram to port on the loopback interface and
+// waits for it to be received
+func generateEvent(port int) error {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+
+	server, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	client, err := net.DialUDP("udp4", nil, addr)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		return err
+	}
+
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 16)
+	if _, _, err := server.ReadFromUDP(buf); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define NAME_MAX 255
+
+// Defined in include/uapi/asm-generic/errno-base.h
+#define EROFS 30
+
+// Defined in include/uapi/asm-generic/fcntl.h
+#define O_WRONLY 00000001
+#define O_RDWR 00000002
+#define O_CREAT 00000100
+#define O_TRUNC 00001000
+
+// Defined in include/uapi/linux/fcntl.h
+#define AT_REMOVEDIR 0x200
+
+enum op { open, truncate, unlink, rmdir, mkdir, rename, chmod, chown };
+
+struct args_t {
+	const char *path;
+	enum op op;
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	enum op op_raw;
+	char path[NAME_MAX];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, 10240);
+	__type(key, u32);
+	__type(value, struct args_t);
+} start SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(writes, events, event);
+
+static __always_inline int trace_enter(const char *path, enum op op)
+{
+	u32 pid = (u32)bpf_get_current_pid_tgid();
+	struct args_t args = {};
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	args.path = path;
+	args.op = op;
+	bpf_map_update_elem(&start, &pid, &args, 0);
+	return 0;
+}
+
+static __always_inline int trace_open_enter(const char *path, __u64 flags)
+{
+	if (!(flags & (O_WRONLY | O_RDWR | O_CREAT | O_TRUNC)))
+		return 0;
+	return trace_enter(path, open);
+}
+
+// Projected volumes (Secrets, ConfigMaps, downward API and service account
+// tokens) are mounted read-only, so write attempts fail with EROFS. Only
+// these failures are reported.
+static __always_inline int trace_exit(struct syscall_trace_exit *ctx)
+{
+	u32 pid = (u32)bpf_get_current_pid_tgid();
+	struct event *event;
+	struct args_t *ap;
+
+	ap = bpf_map_lookup_elem(&start, &pid);
+	if (!ap)
+		return 0;
+	if (ctx->ret != -EROFS)
+		goto cleanup;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		goto cleanup;
+
+	gadget_process_populate(&event->proc);
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	event->op_raw = ap->op;
+	bpf_probe_read_user_str(event->path, sizeof(event->path), ap->path);
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+cleanup:
+	bpf_map_delete_elem(&start, &pid);
+	return 0;
+}
+
+#ifndef __TARGET_ARCH_arm64
+SEC("tracepoint/syscalls/sys_enter_open")
+int ig_open_e(struct syscall_trace_enter *ctx)
+{
+	return trace_open_enter((const char *)ctx->args[0], ctx->args[1]);
+}
+
+SEC("tracepoint/syscalls/sys_enter_creat")
+int ig_creat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], open);
+}
+
+SEC("tracepoint/syscalls/sys_enter_unlink")
+int ig_unlink_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], unlink);
+}
+
+SEC("tracepoint/syscalls/sys_enter_rmdir")
+int ig_rmdir_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], rmdir);
+}
+
+SEC("tracepoint/syscalls/sys_enter_mkdir")
+int ig_mkdir_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], mkdir);
+}
+
+SEC("tracepoint/syscalls/sys_enter_rename")
+int ig_rename_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], rename);
+}
+
+SEC("tracepoint/syscalls/sys_enter_chmod")
+int ig_chmod_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], chmod);
+}
+
+SEC("tracepoint/syscalls/sys_enter_chown")
+int ig_chown_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], chown);
+}
+
+SEC("tracepoint/syscalls/sys_enter_lchown")
+int ig_lchown_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], chown);
+}
+
+SEC("tracepoint/syscalls/sys_enter_renameat")
+int ig_renameat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[1], rename);
+}
+#endif /* !__TARGET_ARCH_arm64 */
+
+SEC("tracepoint/syscalls/sys_enter_openat")
+int ig_openat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_open_enter((const char *)ctx->args[1], ctx->args[2]);
+}
+
+SEC("tracepoint/syscalls/sys_enter_openat2")
+int ig_openat2_e(struct syscall_trace_enter *ctx)
+{
+	struct open_how *how = (struct open_how *)ctx->args[2];
+	__u64 flags = 0;
+
+	bpf_probe_read_user(&flags, sizeof(flags), &how->flags);
+	return trace_open_enter((const char *)ctx->args[1], flags);
+}
+
+SEC("tracepoint/syscalls/sys_enter_truncate")
+int ig_truncate_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[0], truncate);
+}
+
+SEC("tracepoint/syscalls/sys_enter_unlinkat")
+int ig_unlinkat_e(struct syscall_trace_enter *ctx)
+{
+	enum op op = (ctx->args[2] & AT_REMOVEDIR) ? rmdir : unlink;
+
+	return trace_enter((const char *)ctx->args[1], op);
+}
+
+SEC("tracepoint/syscalls/sys_enter_mkdirat")
+int ig_mkdirat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[1], mkdir);
+}
+
+SEC("tracepoint/syscalls/sys_enter_renameat2")
+int ig_renameat2_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[1], rename);
+}
+
+SEC("tracepoint/syscalls/sys_enter_fchmodat")
+int ig_fchmodat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[1], chmod);
+}
+
+SEC("tracepoint/syscalls/sys_enter_fchownat")
+int ig_fchownat_e(struct syscall_trace_enter *ctx)
+{
+	return trace_enter((const char *)ctx->args[1], chown);
+}
+
+// All syscalls share the same exit handler, as the operation was stored
+// when entering them
+#define EXIT_PROG(name)                                          \
+	SEC("tracepoint/syscalls/sys_exit_" #name)               \
+	int ig_##name##_x(struct syscall_trace_exit *ctx)        \
+	{                                                        \
+		return trace_exit(ctx);                          \
+	}
+
+#ifndef __TARGET_ARCH_arm64
+EXIT_PROG(open)
+EXIT_PROG(creat)
+EXIT_PROG(unlink)
+EXIT_PROG(rmdir)
+EXIT_PROG(mkdir)
+EXIT_PROG(rename)
+EXIT_PROG(chmod)
+EXIT_PROG(chown)
+EXIT_PROG(lchown)
+EXIT_PROG(renameat)
+#endif /* !__TARGET_ARCH_arm64 */
+
+EXIT_PROG(openat)
+EXIT_PROG(openat2)
+EXIT_PROG(truncate)
+EXIT_PROG(unlinkat)
+EXIT_PROG(mkdirat)
+EXIT_PROG(renameat2)
+EXIT_PROG(fchmodat)
+EXIT_PROG(fchownat)
+
+char LICENSE[] SEC("license") = "GPL";
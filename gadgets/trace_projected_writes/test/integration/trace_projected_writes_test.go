@@ -0,0 +1,143 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceProjectedWritesEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Op     string `json:"op"`
+	Path   string `json:"path"`
+	Volume string `json:"volume"`
+}
+
+// kubeAPIAccessVolume is the prefix of the name of the projected volume with
+// the service account token, the rest is random
+const kubeAPIAccessVolume = "projected/kube-api-access-"
+
+func TestTraceProjectedWrites(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-projected-writes"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+		containers.WithStartAndStop(),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-projected-writes")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// /proc/sys is read-only in unprivileged containers. The service account
+	// token is only mounted on Kubernetes, elsewhere rm fails with ENOENT and
+	// isn't reported.
+	cmd := "while true ; do echo x > /proc/sys/kernel/domainname ; rm -f /var/run/secrets/kubernetes.io/serviceaccount/token ; sleep 1 ; done"
+
+	testContainer := containerFactory.NewContainer(containerName, cmd, containerOpts...)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(utils.NormalizedStr),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime)))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntries := []*traceProjectedWritesEvent{
+					{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("sh", 0, 0),
+						Op:         "open",
+						Path:       "/proc/sys/kernel/domainname",
+						Volume:     "",
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+					},
+				}
+				if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+					expectedEntries = append(expectedEntries, &traceProjectedWritesEvent{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("rm", 0, 0),
+						Op:         "unlink",
+						Path:       "/var/run/secrets/kubernetes.io/serviceaccount/token",
+						Volume:     kubeAPIAccessVolume,
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+					})
+				}
+				normalize := func(e *traceProjectedWritesEvent) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeString(&e.Runtime.ContainerID)
+					utils.NormalizeString(&e.Timestamp)
+					utils.NormalizeProc(&e.Proc)
+					if strings.HasPrefix(e.Volume, kubeAPIAccessVolume) {
+						e.Volume = kubeAPIAccessVolume
+					}
+				}
+				match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntries...)
+			},
+		))
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop())
+	traceProjectedWritesCmd := igrunner.New("trace_projected_writes", runnerOpts...)
+
+	steps := []igtesting.TestStep{
+		traceProjectedWritesCmd,
+		// wait to ensure ig or kubectl-gadget has started
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+	igtesting.RunTestSteps(steps, t, testingOpts...)
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/filesystem.h>
+
+// Defined in include/uapi/linux/stat.h
+#define S_IFMT 00170000
+#define S_IFSOCK 0140000
+
+/* Define here, because there are conflicts with include files */
+#define AF_INET 2
+#define AF_INET6 10
+
+#define NAME_SIZE 256
+
+enum severity : u8 {
+	low,
+	medium,
+	high,
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+	gadget_netns_id netns_id;
+
+	struct gadget_l4endpoint_t remote;
+	bool stdin_socket;
+	bool stdout_socket;
+	bool stderr_socket;
+	enum severity severity_raw;
+	char filename[NAME_SIZE];
+};
+
+// we need this to make sure the compiler doesn't remove our struct
+const enum severity unused_severity __attribute__((unused));
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(reverseshell, events, event);
+
+static __always_inline bool has_prefix(const char *s, const char *prefix,
+				       int len)
+{
+	for (int i = 0; i < len; i++) {
+		if (s[i] != prefix[i])
+			return false;
+	}
+	return true;
+}
+
+#define comm_is(comm, name) has_prefix(comm, name, sizeof(name))
+
+static __always_inline bool is_shell(const char *comm)
+{
+	return comm_is(comm, "sh") || comm_is(comm, "ash") ||
+	       comm_is(comm, "bash") || comm_is(comm, "dash") ||
+	       comm_is(comm, "ksh") || comm_is(comm, "mksh") ||
+	       comm_is(comm, "zsh") || comm_is(comm, "csh") ||
+	       comm_is(comm, "tcsh") || comm_is(comm, "fish");
+}
+
+// inet_socket returns the socket behind fd if it's an IPv4 or IPv6 socket.
+// Unix sockets are ignored, they are commonly used by legitimate tools.
+static __always_inline struct socket *inet_socket(int fd)
+{
+	struct file *file = get_struct_file_for_fd(fd);
+	struct socket *socket;
+	umode_t mode;
+	u16 family;
+
+	if (!file)
+		return NULL;
+
+	mode = BPF_CORE_READ(file, f_inode, i_mode);
+	if ((mode & S_IFMT) != S_IFSOCK)
+		return NULL;
+
+	socket = BPF_CORE_READ(file, private_data);
+	if (!socket)
+		return NULL;
+
+	family = BPF_CORE_READ(socket, sk, __sk_common.skc_family);
+	if (family != AF_INET && family != AF_INET6)
+		return NULL;
+
+	return socket;
+}
+
+static __always_inline void fill_remote(struct event *event,
+					struct socket *socket)
+{
+	struct sock *sk = BPF_CORE_READ(socket, sk);
+	u16 family = BPF_CORE_READ(sk, __sk_common.skc_family);
+	short type = BPF_CORE_READ(socket, type);
+
+	if (family == AF_INET) {
+		event->remote.version = 4;
+		BPF_CORE_READ_INTO(&event->remote.addr_raw.v4, sk,
+				   __sk_common.skc_daddr);
+	} else {
+		event->remote.version = 6;
+		BPF_CORE_READ_INTO(&event->remote.addr_raw.v6, sk,
+				   __sk_common.skc_v6_daddr.in6_u.u6_addr32);
+	}
+	event->remote.port =
+		bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+	event->remote.proto_raw = type == SOCK_DGRAM ? IPPROTO_UDP :
+						       IPPROTO_TCP;
+	event->netns_id = BPF_CORE_READ(sk, __sk_common.skc_net.net, ns.inum);
+}
+
+SEC("tp_btf/sched_process_exec")
+int BPF_PROG(ig_revshell_exec, struct task_struct *p, pid_t old_pid,
+	     struct linux_binprm *bprm)
+{
+	struct socket *stdin_sock, *stdout_sock, *stderr_sock, *remote;
+	struct event *event;
+	char comm[TASK_COMM_LEN];
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	bpf_get_current_comm(comm, sizeof(comm));
+	if (!is_shell(comm))
+		return 0;
+
+	// The file descriptors closed on exec are already gone at this point
+	stdin_sock = inet_socket(0);
+	stdout_sock = inet_socket(1);
+	stderr_sock = inet_socket(2);
+	if (!stdin_sock && !stdout_sock)
+		return 0;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return 0;
+
+	__builtin_memset(&event->remote, 0, sizeof(event->remote));
+	event->netns_id = 0;
+	remote = stdin_sock ? stdin_sock : stdout_sock;
+	fill_remote(event, remote);
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->stdin_socket = stdin_sock != NULL;
+	event->stdout_socket = stdout_sock != NULL;
+	event->stderr_socket = stderr_sock != NULL;
+
+	// A shell reading its commands from a socket and writing the results
+	// back to it is what reverse shells do. A single redirected stream is
+	// suspicious but has legitimate uses, like piping to netcat.
+	event->severity_raw = stdin_sock && stdout_sock ? high : medium;
+
+	bpf_probe_read_kernel_str(event->filename, sizeof(event->filename),
+				  BPF_CORE_READ(bprm, filename));
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "GPL";
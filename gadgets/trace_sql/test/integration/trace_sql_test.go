@@ -0,0 +1,118 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceSQLEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	Operation string `json:"operation"`
+	LatencyNs uint64 `json:"latency_ns"`
+	Failed    bool   `json:"failed"`
+	Query     string `json:"query"`
+}
+
+func TestTraceSQL(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-sql"
+	// psql uses PQexec() to run the queries given with -c until PostgreSQL 15
+	containerImage := "docker.io/library/postgres:14-alpine"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{containers.WithContainerImage(containerImage)}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-sql")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// Run the server in the same container, listening on a unix socket only
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		"su postgres -c 'initdb -D /tmp/db > /dev/null && pg_ctl -D /tmp/db -o \"-k /tmp -c listen_addresses=\" -w start > /dev/null' && "+
+			"while true; do psql -h /tmp -U postgres -c \"SELECT usename FROM pg_user WHERE usesysid = 10\" > /dev/null; sleep 0.1; done",
+		containerOpts...,
+	)
+
+	testContainer.Start(t)
+	t.Cleanup(func() {
+		testContainer.Stop(t)
+	})
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{utils.WithContainerImageName(containerImage), utils.WithContainerID(testContainer.ID())}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime), "--timeout=5"))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns), "--timeout=5"))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts, igrunner.WithFlags("--redact=true"))
+	runnerOpts = append(runnerOpts, igrunner.WithValidateOutput(
+		func(t *testing.T, output string) {
+			expectedEntry := &traceSQLEvent{
+				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+				Proc:       utils.BuildProc("psql", 0, 0),
+				Operation:  "libpq_PQexec",
+				Failed:     false,
+				Query:      "SELECT usename FROM pg_user WHERE usesysid = ?",
+
+				// Check the existence of the following fields
+				Timestamp: utils.NormalizedStr,
+				LatencyNs: utils.NormalizedInt,
+			}
+
+			normalize := func(e *traceSQLEvent) {
+				utils.NormalizeCommonData(&e.CommonData)
+				utils.NormalizeString(&e.Timestamp)
+				utils.NormalizeInt(&e.LatencyNs)
+				utils.NormalizeProc(&e.Proc)
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	))
+
+	traceSQLCmd := igrunner.New("trace_sql", runnerOpts...)
+
+	igtesting.RunTestSteps([]igtesting.TestStep{traceSQLCmd}, t, testingOpts...)
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTraceSQLEvent struct {
+	Proc ebpftypes.Process `json:"proc"`
+
+	Operation string `json:"operation"`
+	LatencyNs uint64 `json:"latency_ns"`
+	Failed    bool   `json:"failed"`
+	Query     string `json:"query"`
+}
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	redact         string
+	minLatMs       string
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+	validateEvent  func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []ExpectedTraceSQLEvent)
+}
+
+const query = "SELECT * FROM pg_user WHERE usename = 'postgres' AND usesysid > 10"
+
+// queryScript runs the query with libpq without connecting to any server:
+// PQexec() fails right away when the connection is NULL.
+var queryScript = fmt.Sprintf(`import ctypes
+libpq = ctypes.CDLL("libpq.so.5")
+libpq.PQexec.restype = ctypes.c_void_p
+libpq.PQexec(None, b"%s")
+`, query)
+
+func TestTraceSQLGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	if err := exec.Command("python3", "-c", queryScript).Run(); err != nil {
+		t.Skipf("Skipping test as python3 or libpq aren't available: %s", err)
+	}
+
+	runnerConfig := &utilstest.RunnerConfig{}
+
+	testCases := map[string]testDef{
+		"captures_queries": {
+			runnerConfig: runnerConfig,
+			redact:       "false",
+			minLatMs:     "0",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectOneEvent(func(info *utilstest.RunnerInfo, _ int) *ExpectedTraceSQLEvent {
+				return &ExpectedTraceSQLEvent{
+					Proc:      utils.BuildProc("python3", 0, 0),
+					Operation: "libpq_PQexec",
+					LatencyNs: utils.NormalizedInt,
+					Failed:    true,
+					Query:     query,
+				}
+			}),
+		},
+		"redacts_queries": {
+			runnerConfig: runnerConfig,
+			redact:       "true",
+			minLatMs:     "0",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectOneEvent(func(info *utilstest.RunnerInfo, _ int) *ExpectedTraceSQLEvent {
+				return &ExpectedTraceSQLEvent{
+					Proc:      utils.BuildProc("python3", 0, 0),
+					Operation: "libpq_PQexec",
+					LatencyNs: utils.NormalizedInt,
+					Failed:    true,
+					Query:     "SELECT * FROM pg_user WHERE usename = ? AND usesysid > ?",
+				}
+			}),
+		},
+		"ignores_fast_queries": {
+			runnerConfig: runnerConfig,
+			redact:       "false",
+			minLatMs:     "1000",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectNoEvent[ExpectedTraceSQLEvent, int],
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.ebpf.min":    testCase.minLatMs,
+				"operator.oci.wasm.redact": testCase.redact,
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			normalizeEvent := func(event *ExpectedTraceSQLEvent) {
+				utils.NormalizeProc(&event.Proc)
+				utils.NormalizeInt(&event.LatencyNs)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				// Uprobes are only attached to the libraries of containers
+				err := gadgetrunner.AttachContainer(gadgetCtx, "test-trace-sql",
+					runner.Info.Pid, runner.Info.MountNsID)
+				if err != nil {
+					return err
+				}
+				utilstest.RunWithRunner(t, runner, func() error {
+					return exec.Command("python3", "-c", queryScript).Run()
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTraceSQLEvent]{
+				Image:          "trace_sql",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, 0, gadgetRunner.CapturedEvents)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	api "github.com/inspektor-gadget/inspektor-gadget/wasmapi/go"
+)
+
+var (
+	redactData    bool
+	redactQuery   bool
+	redactHeaders [][]byte
+)
+
+var httpMethods = []string{
+	"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH",
+}
+
+type httpInfo struct {
+	method string
+	path   string
+	status string
+}
+
+// parseHTTP extracts the request or status line of an HTTP/1.x message at the
+// start of buf. Other protocols, like HTTP/2, are ignored.
+func parseHTTP(buf []byte) (info httpInfo, lineEnd int) {
+	lineEnd = bytes.Index(buf, []byte("\r\n"))
+	if lineEnd < 0 {
+		// The line could be truncated
+		lineEnd = len(buf)
+	}
+	fields := strings.Fields(string(buf[:lineEnd]))
+
+	if len(fields) >= 2 && strings.HasPrefix(fields[0], "HTTP/1.") {
+		if len(fields[1]) == 3 {
+			info.status = fields[1]
+		}
+		return info, lineEnd
+	}
+
+	if len(fields) >= 2 && (len(fields) == 2 || strings.HasPrefix(fields[2], "HTTP/1.")) {
+		for _, m := range httpMethods {
+			if fields[0] == m {
+				info.method = fields[0]
+				info.path = fields[1]
+				break
+			}
+		}
+	}
+	return info, lineEnd
+}
+
+// redactBuffer masks the query string of the request line (if redactQuery is
+// set) and the values of the redacted headers in place
+func redactBuffer(buf []byte, info httpInfo, lineEnd int) {
+	if info.method == "" && info.status == "" {
+		return
+	}
+
+	if redactQuery && info.method != "" {
+		line := buf[:lineEnd]
+		if start := bytes.IndexByte(line, '?'); start >= 0 {
+			end := bytes.IndexByte(line[start:], ' ')
+			if end < 0 {
+				end = len(line) - start
+			}
+			mask(line[start+1 : start+end])
+		}
+	}
+
+	for pos := lineEnd + 2; pos < len(buf); {
+		end := bytes.Index(buf[pos:], []byte("\r\n"))
+		if end < 0 {
+			end = len(buf) - pos
+		}
+		if end == 0 {
+			// End of the headers
+			return
+		}
+		header := buf[pos : pos+end]
+		if colon := bytes.IndexByte(header, ':'); colon > 0 {
+			name := bytes.TrimSpace(header[:colon])
+			for _, h := range redactHeaders {
+				if bytes.EqualFold(name, h) {
+					mask(bytes.TrimLeft(header[colon+1:], " "))
+					break
+				}
+			}
+		}
+		pos += end + 2
+	}
+}
+
+func mask(b []byte) {
+	for i := range b {
+		b[i] = '*'
+	}
+}
+
+//export gadgetInit
+func gadgetInit() int {
+	ds, err := api.GetDataSource("ssl")
+	if err != nil {
+		api.Errorf("failed to get datasource: %s", err)
+		return 1
+	}
+
+	bufF, err := ds.GetField("buf")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	lenF, err := ds.GetField("len")
+	if err != nil {
+		api.Errorf("failed to get field: %s", err)
+		return 1
+	}
+
+	methodF, err := ds.AddField("http_method", api.Kind_String)
+	if err != nil {
+		api.Errorf("failed to add field: %s", err)
+		return 1
+	}
+
+	pathF, err := ds.AddField("http_path", api.Kind_String)
+	if err != nil {
+		api.Errorf("failed to add field: %s", err)
+		return 1
+	}
+
+	statusF, err := ds.AddField("http_status", api.Kind_String)
+	if err != nil {
+		api.Errorf("failed to add field: %s", err)
+		return 1
+	}
+
+	ds.Subscribe(func(source api.DataSource, data api.Data) {
+		buf, err := bufF.Bytes(data)
+		if err != nil {
+			api.Warnf("failed to get buf: %s", err)
+			return
+		}
+		// The buffer is padded with zeros
+		n, err := lenF.Uint32(data)
+		if err != nil {
+			api.Warnf("failed to get len: %s", err)
+			return
+		}
+		content := buf
+		if int(n) < len(content) {
+			content = content[:n]
+		}
+
+		info, lineEnd := parseHTTP(content)
+		if redactQuery {
+			if i := strings.IndexByte(info.path, '?'); i >= 0 {
+				info.path = info.path[:i]
+			}
+		}
+		methodF.SetString(data, info.method)
+		pathF.SetString(data, info.path)
+		statusF.SetString(data, info.status)
+
+		switch {
+		case redactData:
+			clear(buf)
+		case len(redactHeaders) > 0 || redactQuery:
+			redactBuffer(content, info, lineEnd)
+		default:
+			return
+		}
+		bufF.SetBytes(data, buf)
+	}, 0)
+
+	return 0
+}
+
+func getBoolParam(key string) (bool, error) {
+	value, err := api.GetParamValue(key)
+	if err != nil {
+		return false, err
+	}
+	return value == "true", nil
+}
+
+//export gadgetPreStart
+func gadgetPreStart() int {
+	var err error
+
+	if redactData, err = getBoolParam("redact-data"); err != nil {
+		api.Errorf("failed to get param value: %s", err)
+		return 1
+	}
+	if redactQuery, err = getBoolParam("redact-query"); err != nil {
+		api.Errorf("failed to get param value: %s", err)
+		return 1
+	}
+
+	headers, err := api.GetParamValue("redact-headers")
+	if err != nil {
+		api.Errorf("failed to get param value: %s", err)
+		return 1
+	}
+	for _, h := range strings.Split(headers, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			redactHeaders = append(redactHeaders, []byte(h))
+		}
+	}
+
+	return 0
+}
+
+func main() {}
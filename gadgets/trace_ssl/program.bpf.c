@@ -66,6 +66,9 @@ GADGET_TRACER(ssl, events, event);
 const volatile bool record_data = true;
 GADGET_PARAM(record_data);
 
+const volatile u32 max_data_len = MAX_BUF_SIZE;
+GADGET_PARAM(max_data_len);
+
 /* used for context between uprobes and uretprobes of ssl operations */
 struct ssl_data {
 	u64 start_time;
@@ -152,6 +155,8 @@ static __always_inline int probe_ssl_rw_exit(struct pt_regs *ctx,
 	// MAX_BUF_SIZE is a power of two, so &=MAX_BUF_SIZE-1 makes sure
 	// buf_copy_size does not go above the upper limit
 	buf_copy_size &= MAX_BUF_SIZE - 1;
+	if (buf_copy_size > max_data_len)
+		buf_copy_size = max_data_len;
 
 	event = gadget_reserve_buf(&events, sizeof(struct event));
 	if (!event)
@@ -0,0 +1,170 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/gadgetrunner"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+)
+
+type ExpectedTraceSSLEvent struct {
+	Proc ebpftypes.Process `json:"proc"`
+
+	Operation  string `json:"operation"`
+	LatencyNs  uint64 `json:"latency_ns"`
+	Len        uint32 `json:"len"`
+	HTTPMethod string `json:"http_method"`
+	HTTPPath   string `json:"http_path"`
+	HTTPStatus string `json:"http_status"`
+}
+
+type testDef struct {
+	runnerConfig   *utilstest.RunnerConfig
+	redactQuery    string
+	mntnsFilterMap func(info *utilstest.RunnerInfo) *ebpf.Map
+	validateEvent  func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []ExpectedTraceSSLEvent)
+}
+
+const path = "/inspektor?token=secret"
+
+func TestTraceSSLGadget(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	curl, err := exec.LookPath("curl")
+	if err != nil {
+		t.Skipf("Skipping test as curl isn't available: %s", err)
+	}
+
+	// The server uses the TLS implementation of Go, so only the requests of
+	// curl, that uses OpenSSL, are traced
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	// The server listens in the network namespace of the host
+	runnerConfig := &utilstest.RunnerConfig{HostNetwork: true}
+
+	testCases := map[string]testDef{
+		"captures_requests": {
+			runnerConfig: runnerConfig,
+			redactQuery:  "false",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, _ int) *ExpectedTraceSSLEvent {
+				return &ExpectedTraceSSLEvent{
+					Proc:       utils.BuildProc("curl", 0, 0),
+					Operation:  "libssl_SSL_write",
+					LatencyNs:  utils.NormalizedInt,
+					Len:        utils.NormalizedInt,
+					HTTPMethod: "GET",
+					HTTPPath:   path,
+				}
+			}),
+		},
+		"captures_responses": {
+			runnerConfig: runnerConfig,
+			redactQuery:  "false",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, _ int) *ExpectedTraceSSLEvent {
+				return &ExpectedTraceSSLEvent{
+					Proc:       utils.BuildProc("curl", 0, 0),
+					Operation:  "libssl_SSL_read",
+					LatencyNs:  utils.NormalizedInt,
+					Len:        utils.NormalizedInt,
+					HTTPStatus: "200",
+				}
+			}),
+		},
+		"redacts_query_string": {
+			runnerConfig: runnerConfig,
+			redactQuery:  "true",
+			mntnsFilterMap: func(info *utilstest.RunnerInfo) *ebpf.Map {
+				return utilstest.CreateMntNsFilterMap(t, info.MountNsID)
+			},
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, _ int) *ExpectedTraceSSLEvent {
+				return &ExpectedTraceSSLEvent{
+					Proc:       utils.BuildProc("curl", 0, 0),
+					Operation:  "libssl_SSL_write",
+					LatencyNs:  utils.NormalizedInt,
+					Len:        utils.NormalizedInt,
+					HTTPMethod: "GET",
+					HTTPPath:   "/inspektor",
+				}
+			}),
+		},
+	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := utilstest.NewRunnerWithTest(t, testCase.runnerConfig)
+			params := map[string]string{
+				"operator.oci.wasm.redact-query": testCase.redactQuery,
+			}
+
+			var mntnsFilterMap *ebpf.Map
+			if testCase.mntnsFilterMap != nil {
+				mntnsFilterMap = testCase.mntnsFilterMap(runner.Info)
+			}
+			normalizeEvent := func(event *ExpectedTraceSSLEvent) {
+				utils.NormalizeProc(&event.Proc)
+				utils.NormalizeInt(&event.LatencyNs)
+				utils.NormalizeInt(&event.Len)
+			}
+			onGadgetRun := func(gadgetCtx operators.GadgetContext) error {
+				// Uprobes are only attached to the libraries of containers
+				err := gadgetrunner.AttachContainer(gadgetCtx, "test-trace-ssl",
+					runner.Info.Pid, runner.Info.MountNsID)
+				if err != nil {
+					return err
+				}
+				utilstest.RunWithRunner(t, runner, func() error {
+					// --http1.1 as the gadget only parses HTTP/1.x
+					return exec.Command(curl, "-k", "-s", "--http1.1", "-o", "/dev/null", server.URL+path).Run()
+				})
+				return nil
+			}
+			opts := gadgetrunner.GadgetRunnerOpts[ExpectedTraceSSLEvent]{
+				Image:          "trace_ssl",
+				Timeout:        5 * time.Second,
+				MntnsFilterMap: mntnsFilterMap,
+				ParamValues:    params,
+				OnGadgetRun:    onGadgetRun,
+				NormalizeEvent: normalizeEvent,
+			}
+
+			gadgetRunner := gadgetrunner.NewGadgetRunner(t, opts)
+
+			gadgetRunner.RunGadget()
+
+			testCase.validateEvent(t, runner.Info, 0, gadgetRunner.CapturedEvents)
+		})
+	}
+}
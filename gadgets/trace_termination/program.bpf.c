@@ -0,0 +1,230 @@
+/* SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause) */
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+
+#define SIGKILL 9
+#define SIGTERM 15
+
+#define SIG_DFL 0
+#define SIG_IGN 1
+
+#define MAX_ENTRIES 10240
+
+enum term_handler {
+	// SIGTERM wasn't delivered before the process exited
+	not_delivered,
+	sig_dfl,
+	sig_ign,
+	custom,
+};
+
+enum term_outcome {
+	// The process exited by itself after SIGTERM
+	graceful,
+	// The process was terminated by the default action of SIGTERM
+	terminated,
+	// The process was killed by SIGKILL after SIGTERM
+	killed,
+	// The process was killed by another signal
+	signaled,
+};
+
+struct termination {
+	__u64 sigterm_ts;
+	__u64 deliver_ts;
+	__u64 sigkill_ts;
+	gadget_pid sender_pid;
+	gadget_comm sender_comm[TASK_COMM_LEN];
+	enum term_handler handler;
+};
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	gadget_pid sender_pid;
+	gadget_comm sender_comm[TASK_COMM_LEN];
+
+	enum term_handler handler_raw;
+	enum term_outcome outcome_raw;
+	__u32 exit_code;
+	gadget_signal exit_signal_raw;
+
+	// Time between the generation and the delivery of SIGTERM
+	__u64 delivery_us;
+	// Time between SIGTERM and the exit of the process
+	__u64 shutdown_ms;
+	// Time between SIGTERM and SIGKILL, 0 if it wasn't killed
+	__u64 sigkill_after_ms;
+};
+
+const volatile pid_t target_pid = 0;
+GADGET_PARAM(target_pid);
+
+// The processes that received SIGTERM, by tgid
+struct {
+	__uint(type, BPF_MAP_TYPE_LRU_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, __u32);
+	__type(value, struct termination);
+} terminations SEC(".maps");
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+
+GADGET_TRACER(termination, events, event);
+
+static const struct termination empty_termination = {};
+
+// signal_generate runs in the context of the sender: the container of the
+// target is used to filter the signals sent by the container runtime
+SEC("tp_btf/signal_generate")
+int BPF_PROG(ig_term_generate, int sig, struct kernel_siginfo *info,
+	     struct task_struct *task, int group, int result)
+{
+	struct termination *term;
+	__u64 mntns_id;
+	__u32 tgid;
+
+	if (sig != SIGTERM && sig != SIGKILL)
+		return 0;
+
+	tgid = BPF_CORE_READ(task, tgid);
+	if (target_pid && target_pid != tgid)
+		return 0;
+
+	mntns_id = BPF_CORE_READ(task, nsproxy, mnt_ns, ns.inum);
+	if (gadget_should_discard_mntns_id(mntns_id))
+		return 0;
+
+	if (sig == SIGKILL) {
+		// Only the processes asked to terminate before are reported
+		term = bpf_map_lookup_elem(&terminations, &tgid);
+		if (term && !term->sigkill_ts)
+			term->sigkill_ts = bpf_ktime_get_ns();
+		return 0;
+	}
+
+	// Keep the first SIGTERM, the runtime can send several ones
+	if (bpf_map_lookup_elem(&terminations, &tgid))
+		return 0;
+
+	bpf_map_update_elem(&terminations, &tgid, &empty_termination,
+			    BPF_NOEXIST);
+	term = bpf_map_lookup_elem(&terminations, &tgid);
+	if (!term)
+		return 0;
+
+	term->sigterm_ts = bpf_ktime_get_ns();
+	term->sender_pid = bpf_get_current_pid_tgid() >> 32;
+	bpf_get_current_comm(&term->sender_comm, sizeof(term->sender_comm));
+
+	// The signal is dropped when the process ignores it, like the init
+	// process of a container without handler for SIGTERM
+	if (result == TRACE_SIGNAL_IGNORED)
+		term->handler = sig_ign;
+
+	return 0;
+}
+
+// signal_deliver runs in the context of the target, when it dequeues the
+// signal
+SEC("tp_btf/signal_deliver")
+int BPF_PROG(ig_term_deliver, int sig, struct kernel_siginfo *info,
+	     struct k_sigaction *ka)
+{
+	struct termination *term;
+	__u32 tgid;
+	__u64 handler;
+
+	if (sig != SIGTERM)
+		return 0;
+
+	tgid = bpf_get_current_pid_tgid() >> 32;
+	term = bpf_map_lookup_elem(&terminations, &tgid);
+	if (!term || term->deliver_ts)
+		return 0;
+
+	term->deliver_ts = bpf_ktime_get_ns();
+
+	handler = (__u64)BPF_CORE_READ(ka, sa.sa_handler);
+	if (handler == SIG_DFL)
+		term->handler = sig_dfl;
+	else if (handler == SIG_IGN)
+		term->handler = sig_ign;
+	else
+		term->handler = custom;
+
+	return 0;
+}
+
+SEC("tracepoint/sched/sched_process_exit")
+int ig_term_exit(void *ctx)
+{
+	struct task_struct *task = (struct task_struct *)bpf_get_current_task();
+	struct termination *term;
+	struct event *event;
+	__u32 tgid, exit_code, exit_signal;
+	__u64 now;
+
+	// Only report the exit of the last thread of the process
+	if (BPF_CORE_READ(task, signal, live.counter) != 0)
+		return 0;
+
+	tgid = bpf_get_current_pid_tgid() >> 32;
+	term = bpf_map_lookup_elem(&terminations, &tgid);
+	if (!term)
+		return 0;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		goto cleanup;
+
+	now = bpf_ktime_get_ns();
+
+	gadget_process_populate(&event->proc);
+	event->timestamp_raw = bpf_ktime_get_boot_ns();
+	event->sender_pid = term->sender_pid;
+	__builtin_memcpy(event->sender_comm, term->sender_comm,
+			 sizeof(event->sender_comm));
+	event->handler_raw = term->handler;
+
+	exit_code = BPF_CORE_READ(task, exit_code);
+	exit_signal = exit_code & 0x7f;
+	event->exit_signal_raw = exit_signal;
+	event->exit_code = exit_signal ? 0 : (exit_code >> 8) & 0xff;
+
+	if (exit_signal == SIGKILL)
+		event->outcome_raw = killed;
+	else if (exit_signal == SIGTERM)
+		event->outcome_raw = terminated;
+	else if (exit_signal)
+		event->outcome_raw = signaled;
+	else
+		event->outcome_raw = graceful;
+
+	if (term->deliver_ts)
+		event->delivery_us =
+			(term->deliver_ts - term->sigterm_ts) / 1000;
+	event->shutdown_ms = (now - term->sigterm_ts) / 1000000;
+	if (term->sigkill_ts)
+		event->sigkill_after_ms =
+			(term->sigkill_ts - term->sigterm_ts) / 1000000;
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+cleanup:
+	bpf_map_delete_elem(&terminations, &tgid);
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
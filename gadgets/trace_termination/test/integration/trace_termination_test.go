@@ -0,0 +1,139 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	gadgettesting "github.com/inspektor-gadget/inspektor-gadget/gadgets/testing"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	igtesting "github.com/inspektor-gadget/inspektor-gadget/pkg/testing"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/containers"
+	igrunner "github.com/inspektor-gadget/inspektor-gadget/pkg/testing/ig"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/utils"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type traceTerminationEvent struct {
+	eventtypes.CommonData
+
+	Timestamp string            `json:"timestamp"`
+	Proc      ebpftypes.Process `json:"proc"`
+
+	SenderPid      uint32 `json:"sender_pid"`
+	SenderComm     string `json:"sender_comm"`
+	Handler        string `json:"handler"`
+	Outcome        string `json:"outcome"`
+	ExitCode       uint32 `json:"exit_code"`
+	ExitSignal     string `json:"exit_signal"`
+	DeliveryUs     uint64 `json:"delivery_us"`
+	ShutdownMs     uint64 `json:"shutdown_ms"`
+	SigkillAfterMs uint64 `json:"sigkill_after_ms"`
+}
+
+func TestTraceTermination(t *testing.T) {
+	gadgettesting.RequireEnvironmentVariables(t)
+	utils.InitTest(t)
+
+	containerFactory, err := containers.NewContainerFactory(utils.Runtime)
+	require.NoError(t, err, "new container factory")
+	containerName := "test-trace-termination"
+	containerImage := "docker.io/library/busybox:latest"
+
+	var ns string
+	containerOpts := []containers.ContainerOption{containers.WithContainerImage(containerImage)}
+
+	if utils.CurrentTestComponent == utils.KubectlGadgetTestComponent {
+		ns = utils.GenerateTestNamespaceName(t, "test-trace-termination")
+		containerOpts = append(containerOpts, containers.WithContainerNamespace(ns))
+	}
+
+	// The container is stopped while the gadget is still running, so the
+	// shell receives SIGTERM and exits through its handler
+	containerOpts = append(containerOpts, containers.WithStartAndStop())
+
+	testContainer := containerFactory.NewContainer(
+		containerName,
+		"trap 'exit 0' TERM; while true; do sleep 1; done",
+		containerOpts...,
+	)
+
+	var runnerOpts []igrunner.Option
+	var testingOpts []igtesting.Option
+	commonDataOpts := []utils.CommonDataOption{utils.WithContainerImageName(containerImage)}
+
+	switch utils.CurrentTestComponent {
+	case utils.IgLocalTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-r=%s", utils.Runtime)))
+	case utils.KubectlGadgetTestComponent:
+		runnerOpts = append(runnerOpts, igrunner.WithFlags(fmt.Sprintf("-n=%s", ns)))
+		testingOpts = append(testingOpts, igtesting.WithCbBeforeCleanup(utils.PrintLogsFn(ns)))
+		commonDataOpts = append(commonDataOpts, utils.WithK8sNamespace(ns))
+	}
+
+	runnerOpts = append(runnerOpts, igrunner.WithStartAndStop(), igrunner.WithValidateOutput(
+		func(t *testing.T, output string) {
+			expectedEntry := &traceTerminationEvent{
+				CommonData: utils.BuildCommonData(containerName, commonDataOpts...),
+				Proc:       utils.BuildProc("sh", 0, 0),
+				Handler:    "custom",
+				Outcome:    "graceful",
+				ExitCode:   0,
+
+				// Check the existence of the following fields
+				Timestamp:      utils.NormalizedStr,
+				SenderPid:      utils.NormalizedInt,
+				SenderComm:     utils.NormalizedStr,
+				ExitSignal:     utils.NormalizedStr,
+				DeliveryUs:     utils.NormalizedInt,
+				ShutdownMs:     utils.NormalizedInt,
+				SigkillAfterMs: 0,
+			}
+			expectedEntry.Runtime.ContainerID = utils.NormalizedStr
+
+			normalize := func(e *traceTerminationEvent) {
+				utils.NormalizeCommonData(&e.CommonData)
+				utils.NormalizeString(&e.Runtime.ContainerID)
+				utils.NormalizeString(&e.Timestamp)
+				utils.NormalizeProc(&e.Proc)
+				utils.NormalizeInt(&e.SenderPid)
+				utils.NormalizeString(&e.SenderComm)
+				// The signal is empty when the process exits by itself
+				e.ExitSignal = utils.NormalizedStr
+				// NormalizeInt only normalizes if the value is not 0, and
+				// both might be 0 as the shell exits right away
+				e.DeliveryUs = utils.NormalizedInt
+				e.ShutdownMs = utils.NormalizedInt
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	))
+
+	traceTerminationCmd := igrunner.New("trace_termination", runnerOpts...)
+
+	testSteps := []igtesting.TestStep{
+		traceTerminationCmd,
+		utils.Sleep(10 * time.Second),
+		testContainer,
+	}
+
+	igtesting.RunTestSteps(testSteps, t, testingOpts...)
+}
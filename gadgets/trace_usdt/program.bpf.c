@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+#include <gadget/buffer.h>
+#include <gadget/common.h>
+#include <gadget/macros.h>
+#include <gadget/mntns_filter.h>
+#include <gadget/types.h>
+#include <gadget/usdt_argument.h>
+
+struct event {
+	gadget_timestamp timestamp_raw;
+	struct gadget_process proc;
+
+	__u32 arg_cnt;
+	__u64 arg0;
+	__u64 arg1;
+	__u64 arg2;
+	__u64 arg3;
+	__u64 arg4;
+	__u64 arg5;
+};
+
+GADGET_TRACER_MAP(events, 1024 * 256);
+GADGET_TRACER(usdt, events, event);
+
+/* The probe is set by the --probe parameter, this section name is only a
+ * placeholder */
+SEC("usdt/libc:libc:setjmp")
+int ig_usdt(struct pt_regs *ctx)
+{
+	struct event *event;
+	int arg_cnt;
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	arg_cnt = gadget_usdt_get_arg_cnt(ctx);
+	if (arg_cnt < 0)
+		return 0;
+
+	event = gadget_reserve_buf(&events, sizeof(*event));
+	if (!event)
+		return 0;
+
+	event->timestamp_raw = bpf_ktime_get_ns();
+	gadget_process_populate(&event->proc);
+
+	/* Arguments that don't exist are left to 0 */
+	event->arg_cnt = arg_cnt;
+	gadget_usdt_get_arg(ctx, 0, &event->arg0);
+	gadget_usdt_get_arg(ctx, 1, &event->arg1);
+	gadget_usdt_get_arg(ctx, 2, &event->arg2);
+	gadget_usdt_get_arg(ctx, 3, &event->arg3);
+	gadget_usdt_get_arg(ctx, 4, &event->arg4);
+	gadget_usdt_get_arg(ctx, 5, &event->arg5);
+
+	gadget_submit_buf(ctx, &events, event, sizeof(*event));
+
+	return 0;
+}
+
+char LICENSE[] SEC("license") = "Dual BSD/GPL";
@@ -11,6 +11,62 @@
 #define MAX_EVENT_SIZE		10240
 #endif
 
+// gadget_sample_rate and gadget_max_events_per_second are set from the
+// sample-rate and max-events-per-second params of the ebpf operator. The
+// events dropped by them are never written to the buffer, which reduces the
+// overhead of the gadgets and the lost events on busy systems.
+// Keep in sync with SampleRateName and MaxEventsPerSecondName in
+// pkg/gadgets/consts.go.
+const volatile __u32 gadget_sample_rate = 1;
+const volatile __u32 gadget_max_events_per_second = 0;
+
+struct gadget_rate_limit {
+	__u64 window_start;
+	__u64 count;
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct gadget_rate_limit);
+} gadget_rate_limit_map SEC(".maps");
+
+// gadget_should_drop_event returns 1 if the event has to be dropped to keep
+// one event out of gadget_sample_rate and at most
+// gadget_max_events_per_second events per second. Events reserved and then
+// discarded by the gadget count in the rate too.
+static __always_inline int gadget_should_drop_event(void)
+{
+	static const __u32 zero = 0;
+	struct gadget_rate_limit *rl;
+	__u64 now;
+
+	if (gadget_sample_rate > 1 &&
+	    bpf_get_prandom_u32() % gadget_sample_rate != 0)
+		return 1;
+
+	if (gadget_max_events_per_second == 0)
+		return 0;
+
+	rl = bpf_map_lookup_elem(&gadget_rate_limit_map, &zero);
+	if (!rl)
+		return 0;
+
+	// The window is reset without synchronization: a few more events can
+	// go through when CPUs race, which is fine for a rate limit.
+	now = bpf_ktime_get_boot_ns();
+	if (now - rl->window_start >= 1000000000ULL) {
+		rl->window_start = now;
+		rl->count = 0;
+	}
+
+	// The value returned by __sync_fetch_and_add() requires BPF atomics,
+	// which aren't available in all the kernels supported
+	__sync_fetch_and_add(&rl->count, 1);
+	return rl->count > gadget_max_events_per_second;
+}
+
 #define GADGET_TRACER_MAP(name, size)			\
 	struct {					\
 		__uint(type, BPF_MAP_TYPE_RINGBUF);	\
@@ -30,6 +86,9 @@ static __always_inline void *gadget_reserve_buf(void *map, __u64 size)
 {
 	static const int zero = 0;
 
+	if (gadget_should_drop_event())
+		return NULL;
+
 	if (bpf_core_enum_value_exists(enum bpf_func_id, BPF_FUNC_ringbuf_reserve))
 		return bpf_ringbuf_reserve(map, size, 0);
 
@@ -55,6 +114,9 @@ static __always_inline long gadget_submit_buf(void *ctx, void *map, void *buf, _
 
 static __always_inline long gadget_output_buf(void *ctx, void *map, void *buf, __u64 size)
 {
+	if (gadget_should_drop_event())
+		return 0;
+
 	if (bpf_core_enum_value_exists(enum bpf_func_id, BPF_FUNC_ringbuf_output)) {
 		bpf_ringbuf_output(map, buf, size, 0);
 		return 0;
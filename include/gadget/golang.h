@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+// Copyright (c) 2024 The Inspektor Gadget authors
+
+#ifndef __GOLANG_H
+#define __GOLANG_H
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+// Helpers for uprobes attached to Go functions
+
+// Arguments of Go functions, following the register-based calling convention
+// used since Go 1.17. The receiver of a method is the first argument, and
+// interfaces and strings use two registers.
+#if defined(__TARGET_ARCH_x86)
+#define GO_PARAM1(x) ((x)->ax)
+#define GO_PARAM2(x) ((x)->bx)
+#define GO_PARAM3(x) ((x)->cx)
+#define GO_PARAM4(x) ((x)->di)
+#define GO_PARAM5(x) ((x)->si)
+#define GO_PARAM6(x) ((x)->r8)
+#elif defined(__TARGET_ARCH_arm64)
+#define GO_PARAM1(x) ((x)->regs[0])
+#define GO_PARAM2(x) ((x)->regs[1])
+#define GO_PARAM3(x) ((x)->regs[2])
+#define GO_PARAM4(x) ((x)->regs[3])
+#define GO_PARAM5(x) ((x)->regs[4])
+#define GO_PARAM6(x) ((x)->regs[5])
+#endif
+
+// Keep this aligned with pkg/uprobetracer/golang.go
+
+#define GADGET_GO_MAX_OFFSETS 16
+#define GADGET_GO_MAX_BINARIES 64
+
+struct gadget_go_offsets {
+	__s64 offsets[GADGET_GO_MAX_OFFSETS];
+};
+
+// The offsets of the struct fields listed in the goOffsets section of the
+// metadata file are written by user space for each binary the programs are
+// attached to, the attach cookie of a program is the key of its offsets.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, GADGET_GO_MAX_BINARIES);
+	__type(key, __u32);
+	__type(value, struct gadget_go_offsets);
+} gadget_go_offsets SEC(".maps");
+
+/* Returns the offset of the idx-th field of the goOffsets section in the
+ * binary the program is attached to, negative if it's unknown */
+static __always_inline __s64 gadget_go_offset(struct pt_regs *ctx, __u32 idx)
+{
+	struct gadget_go_offsets *offsets;
+	__u32 id;
+
+	if (idx >= GADGET_GO_MAX_OFFSETS)
+		return -1;
+
+	id = bpf_get_attach_cookie(ctx);
+	offsets = bpf_map_lookup_elem(&gadget_go_offsets, &id);
+	if (!offsets)
+		return -1;
+
+	return offsets->offsets[idx];
+}
+
+struct gadget_go_string {
+	const char *ptr;
+	__s64 len;
+};
+
+/* Reads the Go string at addr into buf, as a NUL terminated string truncated
+ * to size - 1 bytes. Returns 0 on success, negative on failure */
+static __always_inline long gadget_go_read_string(void *addr, char *buf,
+						  __u32 size)
+{
+	struct gadget_go_string str;
+	__s64 len;
+	long err;
+
+	buf[0] = 0;
+
+	err = bpf_probe_read_user(&str, sizeof(str), addr);
+	if (err)
+		return err;
+
+	len = str.len;
+	if (len <= 0)
+		return 0;
+	if (len > size - 1)
+		len = size - 1;
+
+	err = bpf_probe_read_user(buf, len, str.ptr);
+	if (err)
+		return err;
+	buf[len] = 0;
+	return 0;
+}
+
+#endif /* __GOLANG_H */
@@ -59,6 +59,11 @@ typedef __u64 gadget_syscall;
 
 typedef __u32 gadget_kernel_stack;
 
+// gadget_user_stack is the id of a stack in ig_ustack. The addresses are
+// resolved using the process whose pid is in the field set by the
+// ebpf.formatter.ustack.pid annotation or the first gadget_pid field.
+typedef __u32 gadget_user_stack;
+
 #ifndef TASK_COMM_LEN
 #define TASK_COMM_LEN 16
 #endif
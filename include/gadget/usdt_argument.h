@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: (LGPL-2.1 OR BSD-2-Clause)
+// Copyright (c) 2024 The Inspektor Gadget authors
+
+#ifndef __USDT_ARGUMENT_H
+#define __USDT_ARGUMENT_H
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_tracing.h>
+
+// Keep this aligned with pkg/uprobetracer/usdt_args.go
+
+#define GADGET_USDT_MAX_ARGS 12
+#define GADGET_USDT_MAX_SPECS 256
+
+enum gadget_usdt_arg_type {
+	GADGET_USDT_ARG_CONST,
+	GADGET_USDT_ARG_REG,
+	GADGET_USDT_ARG_REG_DEREF,
+};
+
+struct gadget_usdt_arg_spec {
+	__u64 val_off;
+	enum gadget_usdt_arg_type arg_type;
+	short reg_off;
+	bool arg_signed;
+	char arg_bitshift;
+};
+
+struct gadget_usdt_spec {
+	struct gadget_usdt_arg_spec args[GADGET_USDT_MAX_ARGS];
+	short arg_cnt;
+};
+
+// The argument specs are written by user space when the probes are attached,
+// the attach cookie of a probe is the key of its spec.
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, GADGET_USDT_MAX_SPECS);
+	__type(key, __u32);
+	__type(value, struct gadget_usdt_spec);
+} gadget_usdt_specs SEC(".maps");
+
+/* Returns the number of arguments of the USDT probe, negative on failure */
+static __always_inline int gadget_usdt_get_arg_cnt(struct pt_regs *ctx)
+{
+	struct gadget_usdt_spec *spec;
+	__u32 spec_id;
+
+	spec_id = bpf_get_attach_cookie(ctx);
+	spec = bpf_map_lookup_elem(&gadget_usdt_specs, &spec_id);
+	if (!spec)
+		return -1;
+
+	return spec->arg_cnt;
+}
+
+/* Stores the idx-th argument (starting at 0) of the USDT probe in res, sign or
+ * zero extended to 64 bits. Returns 0 on success, negative on failure */
+static __always_inline int gadget_usdt_get_arg(struct pt_regs *ctx, __u64 idx,
+					       __u64 *res)
+{
+	struct gadget_usdt_arg_spec *arg_spec;
+	struct gadget_usdt_spec *spec;
+	__u32 spec_id;
+	__u64 val;
+	long err;
+
+	*res = 0;
+
+	spec_id = bpf_get_attach_cookie(ctx);
+	spec = bpf_map_lookup_elem(&gadget_usdt_specs, &spec_id);
+	if (!spec)
+		return -1;
+
+	if (idx >= GADGET_USDT_MAX_ARGS || idx >= spec->arg_cnt)
+		return -1;
+
+	arg_spec = &spec->args[idx];
+	switch (arg_spec->arg_type) {
+	case GADGET_USDT_ARG_CONST:
+		val = arg_spec->val_off;
+		break;
+	case GADGET_USDT_ARG_REG:
+		err = bpf_probe_read_kernel(&val, sizeof(val),
+					    (void *)ctx + arg_spec->reg_off);
+		if (err)
+			return err;
+		break;
+	case GADGET_USDT_ARG_REG_DEREF:
+		err = bpf_probe_read_kernel(&val, sizeof(val),
+					    (void *)ctx + arg_spec->reg_off);
+		if (err)
+			return err;
+		err = bpf_probe_read_user(&val, sizeof(val),
+					  (void *)val + arg_spec->val_off);
+		if (err)
+			return err;
+#if __BYTE_ORDER__ == __ORDER_BIG_ENDIAN__
+		val >>= arg_spec->arg_bitshift;
+#endif
+		break;
+	default:
+		return -1;
+	}
+
+	// Drop the bits that don't belong to the argument and extend it
+	val <<= arg_spec->arg_bitshift;
+	if (arg_spec->arg_signed)
+		val = ((__s64)val) >> arg_spec->arg_bitshift;
+	else
+		val = val >> arg_spec->arg_bitshift;
+	*res = val;
+	return 0;
+}
+
+#endif /* __USDT_ARGUMENT_H */
@@ -87,6 +87,8 @@ func TestSnapshotSocket(t *testing.T) {
 				normalize := func(e *snapshotsocketTypes.Event) {
 					e.InodeNumber = 0
 					e.NetNsID = 0
+					e.Pid = 0
+					e.Comm = ""
 
 					e.K8s.ContainerName = ""
 					// TODO: Verify container runtime and container name
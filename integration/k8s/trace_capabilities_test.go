@@ -33,16 +33,18 @@ func TestTraceCapabilities(t *testing.T) {
 
 	var extraArgs string
 	expectedEntry := &capabilitiesTypes.Event{
-		Comm:          "nice",
-		CapName:       "SYS_NICE",
-		Cap:           23,
-		Syscall:       "setpriority",
-		Audit:         1,
-		Verdict:       "Deny",
-		CurrentUserNs: 1,
-		TargetUserNs:  1,
-		Caps:          1,
-		CapsNames:     []string{"x"},
+		Comm:               "nice",
+		CapName:            "SYS_NICE",
+		Cap:                23,
+		Syscall:            "setpriority",
+		Audit:              1,
+		Verdict:            "Deny",
+		CurrentUserNs:      1,
+		TargetUserNs:       1,
+		CurrentUserNsOwner: "host",
+		TargetUserNsOwner:  "host",
+		Caps:               1,
+		CapsNames:          []string{"x"},
 	}
 
 	switch DefaultTestComponent {
@@ -66,6 +68,7 @@ func TestTraceCapabilities(t *testing.T) {
 				e.Timestamp = 0
 				e.Pid = 0
 				e.Uid = 0
+				e.ContainerUid = 0
 				e.MountNsID = 0
 				// Do not check InsetID to avoid introducing dependency on the kernel version
 				e.InsetID = nil
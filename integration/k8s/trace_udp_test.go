@@ -0,0 +1,99 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/inspektor-gadget/inspektor-gadget/integration"
+	traceudpTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/udp/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/testing/match"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestTraceUDP(t *testing.T) {
+	t.Parallel()
+	ns := GenerateTestNamespaceName("test-trace-udp")
+
+	var extraArgs string
+	expectedEntry := &traceudpTypes.Event{
+		Operation: "send",
+		Comm:      "nc",
+		Uid:       1000,
+		Gid:       1111,
+		IPVersion: 4,
+		LocalEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{
+				Addr:    "127.0.0.1",
+				Version: 4,
+			},
+		},
+		RemoteEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{
+				Addr:    "127.0.0.1",
+				Version: 4,
+			},
+			Port: 9090,
+		},
+		// "hello\n"
+		Len: 6,
+	}
+
+	switch DefaultTestComponent {
+	case IgTestComponent:
+		extraArgs = fmt.Sprintf("--runtimes=%s", containerRuntime)
+		expectedEntry.Event = BuildBaseEvent(ns,
+			WithRuntimeMetadata(containerRuntime),
+			WithContainerImageName("docker.io/library/busybox:latest", isDockerRuntime),
+			WithPodLabels("test-pod", ns, isCrioRuntime),
+		)
+	case InspektorGadgetTestComponent:
+		extraArgs = fmt.Sprintf("-n %s", ns)
+		expectedEntry.Event = BuildBaseEventK8s(ns, WithContainerImageName("docker.io/library/busybox:latest", isDockerRuntime))
+		expectedEntry.LocalEndpoint.L3Endpoint.Kind = eventtypes.EndpointKindRaw
+		expectedEntry.RemoteEndpoint.L3Endpoint.Kind = eventtypes.EndpointKindRaw
+	}
+
+	traceUDPCmd := &Command{
+		Name:         "StartTraceUDPGadget",
+		Cmd:          fmt.Sprintf("%s trace udp -o json %s", DefaultTestComponent, extraArgs),
+		StartAndStop: true,
+		ValidateOutput: func(t *testing.T, output string) {
+			normalize := func(e *traceudpTypes.Event) {
+				e.Timestamp = 0
+				e.Pid = 0
+				e.MountNsID = 0
+				// The local port is chosen by the kernel
+				e.LocalEndpoint.Port = 0
+
+				normalizeCommonData(&e.CommonData, ns)
+			}
+
+			match.MatchEntries(t, match.JSONMultiObjectMode, output, normalize, expectedEntry)
+		},
+	}
+
+	commands := []TestStep{
+		CreateTestNamespaceCommand(ns),
+		traceUDPCmd,
+		SleepForSecondsCommand(2), // wait to ensure ig or kubectl-gadget has started
+		BusyboxPodRepeatCommand(ns, "echo hello | setuidgid 1000:1111 nc -u -w 1 127.0.0.1 9090"),
+		WaitUntilTestPodReadyCommand(ns),
+		DeleteTestNamespaceCommand(ns),
+	}
+
+	RunTestSteps(commands, t, WithCbBeforeCleanup(PrintLogsFn(ns)))
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TraceScheduleSpec defines the desired state of TraceSchedule
+type TraceScheduleSpec struct {
+	// Schedule is the schedule in Cron format, see
+	// https://en.wikipedia.org/wiki/Cron.
+	Schedule string `json:"schedule"`
+
+	// Duration is how long each scheduled trace runs before it's stopped.
+	Duration metav1.Duration `json:"duration"`
+
+	// StopOperation is the operation applied to the trace once Duration
+	// elapsed. It defaults to "stop"; gadgets generating their output at the
+	// end (like the seccomp advisor) may need "generate" instead.
+	StopOperation Operation `json:"stopOperation,omitempty"`
+
+	// Suspend tells the controller to suspend subsequent runs. It does not
+	// apply to already started traces.
+	Suspend bool `json:"suspend,omitempty"`
+
+	// HistoryLimit is the number of finished traces to keep per node.
+	// Defaults to 3.
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+
+	// Trace is the spec of the traces created on each activation. If
+	// Trace.Node is set, traces are only created on that node; otherwise
+	// one trace per node is created.
+	Trace TraceSpec `json:"trace"`
+}
+
+// TraceScheduleStatus defines the observed state of TraceSchedule
+type TraceScheduleStatus struct {
+	// LastScheduleTime is the last time a trace was started.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Error is set when the schedule can't be processed, e.g. because the
+	// cron expression is invalid.
+	Error string `json:"error,omitempty"`
+}
+
+// +genclient
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Schedule",type=string,JSONPath=`.spec.schedule`
+//+kubebuilder:printcolumn:name="Gadget",type=string,JSONPath=`.spec.trace.gadget`
+//+kubebuilder:printcolumn:name="Suspend",type=boolean,JSONPath=`.spec.suspend`
+//+kubebuilder:printcolumn:name="Last Schedule",type=date,JSONPath=`.status.lastScheduleTime`
+
+// TraceSchedule is the Schema for the traceschedules API
+type TraceSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TraceScheduleSpec   `json:"spec,omitempty"`
+	Status TraceScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// TraceScheduleList contains a list of TraceSchedule
+type TraceScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TraceSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TraceSchedule{}, &TraceScheduleList{})
+}
@@ -15,6 +15,7 @@
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -29,6 +30,14 @@ const (
 	OperationStart Operation = "start"
 	// OperationStop indicates to stop the trace
 	OperationStop Operation = "stop"
+	// OperationPause indicates to stop reading the output of the trace while
+	// keeping the data already collected in the kernel
+	OperationPause Operation = "pause"
+	// OperationResume indicates to resume a paused trace
+	OperationResume Operation = "resume"
+	// OperationClear indicates to reset the data aggregated by the trace
+	// without stopping it
+	OperationClear Operation = "clear"
 	// OperationGenerate indicates to generate the trace
 	// output e.g seccomp profile
 	OperationGenerate Operation = "generate"
@@ -68,6 +77,54 @@ const (
 	TraceOutputModeExternalResource TraceOutputMode = "ExternalResource"
 )
 
+// TraceSinkType defines the kind of a trace sink
+// +kubebuilder:validation:Enum=File;Metrics
+type TraceSinkType string
+
+const (
+	// TraceSinkTypeFile indicates to append the events to a file on the node
+	TraceSinkTypeFile TraceSinkType = "File"
+	// TraceSinkTypeMetrics indicates to count the events in the
+	// gadget_trace_events_total metric of the gadget pod
+	TraceSinkTypeMetrics TraceSinkType = "Metrics"
+)
+
+// TraceSink is an additional destination for the events of a trace
+type TraceSink struct {
+	// Type is "File" or "Metrics"
+	Type TraceSinkType `json:"type"`
+
+	// Path is only used with Type=File. It's the file, relative to
+	// /var/lib/inspektor-gadget/traces on the node, the events are
+	// appended to (one JSON event per line). It can be a template like
+	// Output and defaults to "{{.Namespace}}/{{.Trace}}.json".
+	Path string `json:"path,omitempty"`
+
+	// Rotation is only used with Type=File. It configures the rotation of
+	// the file.
+	Rotation *TraceFileRotation `json:"rotation,omitempty"`
+}
+
+// TraceFileRotation configures when the file the events of a trace are
+// written to is rotated. The rotated files are kept next to it, with the time
+// of the rotation appended to their name.
+type TraceFileRotation struct {
+	// MaxSize is the size, like "100Mi", the file is rotated at
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+
+	// MaxAge is how long the events are written to the same file. Once it
+	// elapsed, the file is rotated before writing the next event.
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+
+	// MaxFiles is the number of rotated files kept. The oldest ones are
+	// removed. All of them are kept if it's 0.
+	// +kubebuilder:validation:Minimum=0
+	MaxFiles int `json:"maxFiles,omitempty"`
+
+	// Compress compresses the rotated files with gzip
+	Compress bool `json:"compress,omitempty"`
+}
+
 // ContainerFilter filters events based on different criteria
 type ContainerFilter struct {
 	// Namespace selects events from this pod namespace
@@ -109,27 +166,61 @@ type TraceSpec struct {
 	// Output allows a gadget to output the results in the specified
 	// location.
 	// * With OutputMode=Status|Stream, Output is unused
-	// * With OutputMode=File, Output specifies the file path
+	// * With OutputMode=File, Output specifies the file path. For the
+	//   gadgets streaming their events, it's relative to
+	//   /var/lib/inspektor-gadget/traces on the node and defaults to
+	//   "{{.Namespace}}/{{.Trace}}.json"
 	// * With OutputMode=ExternalResource, Output specifies the external
 	//   resource (such as
 	//   seccompprofiles.security-profiles-operator.x-k8s.io for the
 	//   seccomp gadget)
+	// With OutputMode=File|ExternalResource, Output can be a template like
+	// "seccomp-{{.Namespace}}-{{.Pod}}-{{.Date}}" rendered when the output
+	// is generated. The variables are Namespace, Pod, Container, Node, Trace,
+	// Date, Time and Timestamp.
 	Output string `json:"output,omitempty"`
 
+	// Rotation configures the rotation of the file the events are written
+	// to with OutputMode=File, for the gadgets streaming their events.
+	Rotation *TraceFileRotation `json:"rotation,omitempty"`
+
 	// TODO: Ideally it should be a map[string]interface{} but it's not
 	// supported: https://github.com/kubernetes-sigs/controller-tools/issues/636
 
 	// Parameters contains gadget specific configurations.
 	Parameters map[string]string `json:"parameters,omitempty"`
+
+	// Timeout is how long the trace runs once started. The stop operation
+	// is applied afterwards, even if the client that created the trace is
+	// gone.
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+
+	// MaxEvents is the number of events after which the stop operation is
+	// applied. It's only used with OutputMode=Stream.
+	MaxEvents int64 `json:"maxEvents,omitempty"`
+
+	// Sinks are additional destinations the events are sent to while they
+	// are streamed. It's only used with OutputMode=Stream, or OutputMode=File
+	// for the gadgets streaming their events.
+	Sinks []TraceSink `json:"sinks,omitempty"`
+
+	// TTLSecondsAfterCreation is the number of seconds after the creation
+	// of the trace once it's deleted by the node, stopping it if it's still
+	// running. It allows to garbage collect the traces left behind by a
+	// client that went away without deleting them.
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterCreation *int64 `json:"ttlSecondsAfterCreation,omitempty"`
 }
 
 // TraceState defines state for the trace
-// +kubebuilder:validation:Enum=Started;Stopped;Completed
+// +kubebuilder:validation:Enum=Started;Paused;Stopped;Completed
 type TraceState string
 
 const (
 	// TraceStateStarted indicates trace is in started state
 	TraceStateStarted TraceState = "Started"
+	// TraceStatePaused indicates trace is in paused state
+	TraceStatePaused TraceState = "Paused"
 	// TraceStateStopped indicates trace is in stopped state
 	TraceStateStopped TraceState = "Stopped"
 	// TraceStateCompleted indicates trace is in completed state
@@ -141,7 +232,7 @@ type TraceStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// State is "Started", "Stopped" or "Completed"
+	// State is "Started", "Paused", "Stopped" or "Completed"
 	State TraceState `json:"state,omitempty"`
 
 	// Output is the output of the gadget
@@ -151,11 +242,47 @@ type TraceStatus struct {
 	// annotation gadget.kinvolk.io/operation=
 	OperationError string `json:"operationError,omitempty"`
 
+	// OperationErrorCode identifies the kind of OperationError, e.g.
+	// "IG-E-BTF-MISSING". It's empty if the error isn't a known one.
+	OperationErrorCode string `json:"operationErrorCode,omitempty"`
+
+	// VerifierLog contains the last lines of the eBPF verifier log when
+	// OperationError was caused by the verifier rejecting a program
+	VerifierLog string `json:"verifierLog,omitempty"`
+
 	// OperationWarning is returned by the gadget to notify about a malfunction
 	// when applying the annotation gadget.kinvolk.io/operation=. Unlike the
 	// OperationError that represents a fatal error, the OperationWarning could
 	// be ignored according to the context.
 	OperationWarning string `json:"operationWarning,omitempty"`
+
+	// OperationErrorTransient is set when OperationError is likely to go
+	// away by retrying the operation, e.g. because the verifier was busy.
+	// The controller retries such operations a bounded number of times; it
+	// clears this field once it gives up.
+	OperationErrorTransient bool `json:"operationErrorTransient,omitempty"`
+
+	// OperationRetries is the number of times the last operation was retried
+	OperationRetries int32 `json:"operationRetries,omitempty"`
+
+	// OperationID is the value of the annotation
+	// gadget.kinvolk.io/operation-id of the last applied operation that had
+	// one. Clients use it to know that their own operation was applied and
+	// not a previous identical one.
+	OperationID string `json:"operationID,omitempty"`
+
+	// ObservedGeneration is the generation of the trace when the last
+	// operation was applied.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// StartedAt is when the trace was last started. Spec.Timeout is counted
+	// from it, so it's still applied after the gadget pod restarted.
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// BPFMemory is the memory in bytes used by the eBPF programs and maps
+	// created when the trace was started. It's reset once the trace is
+	// stopped.
+	BPFMemory int64 `json:"bpfMemory,omitempty"`
 }
 
 // +genclient
@@ -0,0 +1,194 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfstats
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cilium/ebpf/rlimit"
+	"golang.org/x/sys/unix"
+)
+
+type MemlockMode int
+
+const (
+	// MemlockUnlimited means that RLIMIT_MEMLOCK was already unlimited
+	MemlockUnlimited MemlockMode = iota
+
+	// MemlockRaised means that RLIMIT_MEMLOCK was raised to unlimited
+	MemlockRaised
+
+	// MemlockCgroup means that the kernel (>= 5.11) charges the memory of
+	// eBPF objects to the memory cgroup instead of RLIMIT_MEMLOCK, so it
+	// wasn't changed
+	MemlockCgroup
+)
+
+func (m MemlockMode) String() string {
+	switch m {
+	case MemlockUnlimited:
+		return "unlimited"
+	case MemlockRaised:
+		return "raised"
+	case MemlockCgroup:
+		return "cgroup"
+	default:
+		return fmt.Sprintf("MemlockMode(%d)", int(m))
+	}
+}
+
+// SetupMemlock makes sure that creating eBPF objects isn't limited by
+// RLIMIT_MEMLOCK. The limit is only raised on kernels that don't account the
+// memory of eBPF objects to the memory cgroup.
+func SetupMemlock() (MemlockMode, error) {
+	var before unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &before); err != nil {
+		return 0, fmt.Errorf("getting memlock limit: %w", err)
+	}
+	if before.Cur == unix.RLIM_INFINITY {
+		return MemlockUnlimited, nil
+	}
+
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return 0, err
+	}
+
+	var after unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &after); err != nil {
+		return 0, fmt.Errorf("getting memlock limit: %w", err)
+	}
+	if after.Cur == unix.RLIM_INFINITY {
+		return MemlockRaised, nil
+	}
+	return MemlockCgroup, nil
+}
+
+type ObjectType string
+
+const (
+	ObjectTypeProg ObjectType = "prog"
+	ObjectTypeMap  ObjectType = "map"
+)
+
+// Object is an eBPF program or map
+type Object struct {
+	Type ObjectType
+	ID   uint32
+
+	// Memlock is the memory used by the object, in bytes
+	Memlock uint64
+}
+
+// ProcessObjects returns the eBPF programs and maps the current process has a
+// file descriptor for. Objects with several file descriptors are only
+// returned once.
+func ProcessObjects() ([]Object, error) {
+	return objectsFromFdinfo("/proc/self/fdinfo")
+}
+
+// Memlock returns the memory used by objects, in bytes
+func Memlock(objects []Object) uint64 {
+	var total uint64
+	for _, o := range objects {
+		total += o.Memlock
+	}
+	return total
+}
+
+// NewObjects returns the objects in after that aren't in before
+func NewObjects(before, after []Object) []Object {
+	known := make(map[Object]struct{}, len(before))
+	for _, o := range before {
+		known[Object{Type: o.Type, ID: o.ID}] = struct{}{}
+	}
+
+	var res []Object
+	for _, o := range after {
+		if _, ok := known[Object{Type: o.Type, ID: o.ID}]; !ok {
+			res = append(res, o)
+		}
+	}
+	return res
+}
+
+func objectsFromFdinfo(dir string) ([]Object, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", dir, err)
+	}
+
+	seen := make(map[Object]struct{})
+	var objects []Object
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// The file descriptor was closed in the meantime
+			continue
+		}
+		o, ok := parseFdinfo(content)
+		if !ok {
+			continue
+		}
+		key := Object{Type: o.Type, ID: o.ID}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		objects = append(objects, o)
+	}
+	return objects, nil
+}
+
+// parseFdinfo parses the content of /proc/$pid/fdinfo/$fd. It returns false
+// if the file descriptor isn't for an eBPF program or map.
+func parseFdinfo(content []byte) (Object, bool) {
+	var o Object
+	found := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimSpace(value)
+
+		switch string(key) {
+		case "prog_id", "map_id":
+			id, err := strconv.ParseUint(string(value), 10, 32)
+			if err != nil {
+				return Object{}, false
+			}
+			o.ID = uint32(id)
+			o.Type = ObjectTypeProg
+			if string(key) == "map_id" {
+				o.Type = ObjectTypeMap
+			}
+			found = true
+		case "memlock":
+			memlock, err := strconv.ParseUint(string(value), 10, 64)
+			if err != nil {
+				return Object{}, false
+			}
+			o.Memlock = memlock
+		}
+	}
+	return o, found
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpfstats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectsFromFdinfo(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		// regular file
+		"0": "pos:\t0\nflags:\t02\nmnt_id:\t25\nino:\t1234\n",
+		"3": "pos:\t0\nflags:\t02000002\nmnt_id:\t15\nino:\t2068\nlink_type:\ttracing\n",
+		"4": "pos:\t0\nflags:\t02000002\nmnt_id:\t15\nino:\t2068\nprog_type:\t2\nprog_jited:\t1\nprog_tag:\t5fd7ee0d5a5a7c2b\nmemlock:\t4096\nprog_id:\t42\n",
+		"5": "pos:\t0\nflags:\t02000002\nmnt_id:\t15\nino:\t2068\nmap_type:\t1\nkey_size:\t4\nvalue_size:\t8\nmax_entries:\t1024\nmap_flags:\t0x0\nmap_extra:\t0x0\nmemlock:\t90112\nmap_id:\t7\nfrozen:\t0\n",
+		// second file descriptor for map 7
+		"6": "pos:\t0\nflags:\t02000002\nmnt_id:\t15\nino:\t2068\nmap_type:\t1\nmemlock:\t90112\nmap_id:\t7\n",
+		// a program with the same ID as the map
+		"7": "prog_type:\t5\nmemlock:\t8192\nprog_id:\t7\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	objects, err := objectsFromFdinfo(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Object{
+		{Type: ObjectTypeProg, ID: 42, Memlock: 4096},
+		{Type: ObjectTypeMap, ID: 7, Memlock: 90112},
+		{Type: ObjectTypeProg, ID: 7, Memlock: 8192},
+	}, objects)
+	assert.Equal(t, uint64(4096+90112+8192), Memlock(objects))
+}
+
+func TestNewObjects(t *testing.T) {
+	before := []Object{
+		{Type: ObjectTypeMap, ID: 1, Memlock: 10},
+		{Type: ObjectTypeProg, ID: 2, Memlock: 20},
+	}
+	after := []Object{
+		// Memlock of maps can change over time
+		{Type: ObjectTypeMap, ID: 1, Memlock: 15},
+		{Type: ObjectTypeMap, ID: 2, Memlock: 30},
+		{Type: ObjectTypeProg, ID: 3, Memlock: 40},
+	}
+
+	assert.Equal(t, []Object{
+		{Type: ObjectTypeMap, ID: 2, Memlock: 30},
+		{Type: ObjectTypeProg, ID: 3, Memlock: 40},
+	}, NewObjects(before, after))
+	assert.Empty(t, NewObjects(after, before[:1]))
+}
+
+func TestMemlockModeString(t *testing.T) {
+	assert.Equal(t, "unlimited", MemlockUnlimited.String())
+	assert.Equal(t, "raised", MemlockRaised.String())
+	assert.Equal(t, "cgroup", MemlockCgroup.String())
+}
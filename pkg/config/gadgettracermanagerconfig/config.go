@@ -31,4 +31,44 @@ const (
 	AllowedGadgets         = "allowed-gadgets"
 	InsecureRegistries     = "insecure-registries"
 	DisallowPulling        = "disallow-pulling"
+	PrefetchGadgetsKey     = "prefetch-gadgets"
+	OtelMetrics            = "otel-metrics"
+	OtelMetricsListen      = "otel-metrics-listen"
+)
+
+const (
+	TLSCertFileKey     = "tls.cert-file"
+	TLSKeyFileKey      = "tls.key-file"
+	TLSClientCAFileKey = "tls.client-ca-file"
+)
+
+const (
+	ContinuousProfilingEnabledKey            = "continuous-profiling.enabled"
+	ContinuousProfilingPushURLKey            = "continuous-profiling.push-url"
+	ContinuousProfilingAppNameKey            = "continuous-profiling.app-name"
+	ContinuousProfilingFrequencyKey          = "continuous-profiling.frequency"
+	ContinuousProfilingContainersPerRoundKey = "continuous-profiling.containers-per-round"
+	ContinuousProfilingRoundDurationKey      = "continuous-profiling.round-duration"
+)
+
+const (
+	BuilderEnabledKey = "builder.enabled"
+)
+
+const (
+	TraceMetricsListenAddressKey = "trace-metrics.listen-address"
+)
+
+const (
+	StreamServiceListenAddressKey = "stream-service.listen-address"
+)
+
+const (
+	DNSMetricsEnabledKey = "dns-metrics.enabled"
+)
+
+const (
+	SelfTracingEndpointKey    = "self-tracing.endpoint"
+	SelfTracingInsecureKey    = "self-tracing.insecure"
+	SelfTracingSampleRatioKey = "self-tracing.sample-ratio"
 )
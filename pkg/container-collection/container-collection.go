@@ -432,6 +432,7 @@ func (cc *ContainerCollection) EnrichByMntNs(event *eventtypes.CommonData, mount
 		event.Runtime.ContainerID = container.Runtime.ContainerID
 		event.Runtime.ContainerImageName = container.Runtime.ContainerImageName
 		event.Runtime.ContainerImageDigest = container.Runtime.ContainerImageDigest
+		event.Runtime.ContainerParents = container.Runtime.ContainerParents
 	}
 }
 
@@ -442,6 +443,7 @@ func (cc *ContainerCollection) EnrichByNetNs(event *eventtypes.CommonData, netns
 	if len(containers) == 0 && cc.cachedContainers != nil {
 		containers = lookupContainersByNetns(cc.cachedContainers, netnsid)
 	}
+	containers = innermostContainers(containers)
 	if len(containers) == 0 {
 		return
 	}
@@ -461,6 +463,7 @@ func (cc *ContainerCollection) EnrichByNetNs(event *eventtypes.CommonData, netns
 		event.Runtime.ContainerID = containers[0].Runtime.ContainerID
 		event.Runtime.ContainerImageName = containers[0].Runtime.ContainerImageName
 		event.Runtime.ContainerImageDigest = containers[0].Runtime.ContainerImageDigest
+		event.Runtime.ContainerParents = containers[0].Runtime.ContainerParents
 		return
 	}
 	if containers[0].K8s.PodName != "" && containers[0].K8s.Namespace != "" {
@@ -23,6 +23,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
 	types "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -241,3 +242,116 @@ func TestWithTracerCollection(t *testing.T) {
 	cc.EnrichByNetNs(&ev, containers[0].Netns)
 	require.Equal(t, expected, ev, "events should be equal")
 }
+
+func TestUserNsResolver(t *testing.T) {
+	t.Parallel()
+
+	newK8sContainer := func(id, pod, name string, mntns, userns uint64) *Container {
+		c := &Container{
+			Runtime: RuntimeMetadata{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+					ContainerID:   id,
+					ContainerName: name,
+				},
+			},
+			Mntns:  mntns,
+			Userns: userns,
+			UIDMap: containerutils.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}},
+			GIDMap: containerutils.IDMap{{ContainerID: 0, HostID: 200000, Size: 65536}},
+		}
+		c.K8s.Namespace = "default"
+		c.K8s.PodName = pod
+		c.K8s.ContainerName = name
+		return c
+	}
+
+	cc := ContainerCollection{}
+	cc.AddContainer(&Container{
+		Runtime: RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				ContainerID:   "host-container",
+				ContainerName: "host-container",
+			},
+		},
+		Mntns:      1,
+		Userns:     10,
+		HostUserns: true,
+	})
+	cc.AddContainer(newK8sContainer("c1", "pod1", "app", 2, 20))
+	cc.AddContainer(newK8sContainer("c2", "pod1", "sidecar", 3, 20))
+	cc.AddContainer(newK8sContainer("c3", "pod2", "app", 4, 30))
+
+	require.Equal(t, UserNsHost, cc.UserNsOwner(10))
+	require.Equal(t, "default/pod1", cc.UserNsOwner(20))
+	require.Equal(t, "default/pod2/app", cc.UserNsOwner(30))
+	require.Equal(t, "", cc.UserNsOwner(40))
+	require.Equal(t, "", cc.UserNsOwner(0))
+
+	uid, gid := cc.ContainerIDs(2, 101000, 200005)
+	require.Equal(t, uint32(1000), uid)
+	require.Equal(t, uint32(5), gid)
+
+	// IDs not mapped in the user namespace of the container
+	uid, gid = cc.ContainerIDs(4, 1000, 1000)
+	require.Equal(t, uint32(containerutils.OverflowID), uid)
+	require.Equal(t, uint32(containerutils.OverflowID), gid)
+
+	// IDs of the host and of unknown mount namespaces are kept
+	uid, gid = cc.ContainerIDs(1, 1000, 1000)
+	require.Equal(t, uint32(1000), uid)
+	require.Equal(t, uint32(1000), gid)
+	uid, gid = cc.ContainerIDs(5, 1000, 1000)
+	require.Equal(t, uint32(1000), uid)
+	require.Equal(t, uint32(1000), gid)
+}
+
+func TestNestedContainers(t *testing.T) {
+	t.Parallel()
+
+	newContainer := func(id string, netns uint64, parentID string) *Container {
+		return &Container{
+			Runtime: RuntimeMetadata{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+					ContainerID:   id,
+					ContainerName: id,
+				},
+			},
+			Mntns:             uint64(len(id)),
+			Netns:             netns,
+			ParentContainerID: parentID,
+		}
+	}
+
+	cc := ContainerCollection{}
+	node := newContainer("kind-node", 10, "")
+	cc.AddContainer(node)
+
+	builder := newContainer("dind-builder", 20, "kind-node")
+	cc.setParentContainer(builder)
+	require.Equal(t, "kind-node", builder.Runtime.ContainerParents)
+	cc.AddContainer(builder)
+
+	build := newContainer("build", 30, "dind-builder")
+	cc.setParentContainer(build)
+	require.Equal(t, "kind-node/dind-builder", build.Runtime.ContainerParents)
+	cc.AddContainer(build)
+
+	// A container using the network namespace of its parent, like a pod
+	// using the host network in a kind node
+	hostNetworkPod := newContainer("host-network-pod", 10, "kind-node")
+	cc.setParentContainer(hostNetworkPod)
+	cc.AddContainer(hostNetworkPod)
+
+	event := &types.CommonData{}
+	cc.EnrichByNetNs(event, 10)
+	require.Equal(t, "host-network-pod", event.Runtime.ContainerName)
+	require.Equal(t, "kind-node", event.Runtime.ContainerParents)
+
+	event = &types.CommonData{}
+	cc.EnrichByMntNs(event, build.Mntns)
+	require.Equal(t, "build", event.Runtime.ContainerName)
+	require.Equal(t, "kind-node/dind-builder", event.Runtime.ContainerParents)
+
+	require.Equal(t, []*Container{hostNetworkPod}, innermostContainers([]*Container{node, hostNetworkPod}))
+	require.Equal(t, []*Container{node}, innermostContainers([]*Container{node}))
+}
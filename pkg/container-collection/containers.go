@@ -27,6 +27,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -50,17 +51,32 @@ type Container struct {
 	// SandboxId is the sandbox id for the corresponding pod
 	SandboxId string `json:"sandboxId,omitempty"`
 
+	// ParentContainerID is the id of the container this container is nested
+	// in, like a kind node or a Docker-in-Docker builder
+	ParentContainerID string `json:"parentContainerId,omitempty"`
+
 	// Linux metadata can be derived from the pid via /proc/$pid/...
 	Mntns       uint64 `json:"mntns,omitempty" column:"mntns,template:ns"`
 	Netns       uint64 `json:"netns,omitempty" column:"netns,template:ns"`
 	HostNetwork bool   `json:"hostNetwork,omitempty" column:"hostNetwork,width:11,fixed,hide"`
 	CgroupPath  string `json:"cgroupPath,omitempty"`
 	CgroupID    uint64 `json:"cgroupID,omitempty"`
+	Userns      uint64 `json:"userns,omitempty" column:"userns,template:ns,hide"`
+	HostUserns  bool   `json:"hostUserns,omitempty" column:"hostUserns,width:10,fixed,hide"`
+	// UIDMap and GIDMap are the ID mappings of the user namespace of
+	// containers with remapped IDs. They are empty for the containers
+	// running in the user namespace of the host.
+	UIDMap containerutils.IDMap `json:"uidMap,omitempty"`
+	GIDMap containerutils.IDMap `json:"gidMap,omitempty"`
 	// Data required to find the container to Pod association in the
 	// gadgettracermanager.
 	CgroupV1 string `json:"cgroupV1,omitempty"`
 	CgroupV2 string `json:"cgroupV2,omitempty"`
 
+	// Source is how the container was detected. Useful to debug containers
+	// missing or wrongly enriched.
+	Source ContainerSource `json:"source,omitempty"`
+
 	// We keep an open file descriptor of the containers mount and net namespaces to be sure the
 	// kernel doesn't reuse the inode id before we get rid of this container. This logic avoids
 	// a race condition when the ns inode id is reused by a new container and we erroneously
@@ -73,6 +89,35 @@ type Container struct {
 	deletionTimestamp time.Time
 }
 
+// ContainerSource tells how a container was added to the collection
+type ContainerSource string
+
+const (
+	// SourceRuntime containers were running when the collection was
+	// initialized and were listed from the container runtime
+	SourceRuntime ContainerSource = "runtime"
+	// SourceKubernetes containers were running when the collection was
+	// initialized and were listed from the Kubernetes API
+	SourceKubernetes ContainerSource = "kubernetes"
+	// SourcePodInformer containers were added by the pod informer
+	SourcePodInformer ContainerSource = "pod-informer"
+	// SourceFallbackPodInformer containers were missed by the main hook and
+	// added by the fallback pod informer
+	SourceFallbackPodInformer ContainerSource = "fallback-pod-informer"
+	// SourceRuncFanotify containers were detected by fanotify on runc
+	SourceRuncFanotify ContainerSource = "runc-fanotify"
+	// SourceFanotifyEbpf containers were detected by fanotify and eBPF
+	SourceFanotifyEbpf ContainerSource = "fanotify-ebpf"
+	// SourceHook containers were added through the AddContainer API of the
+	// gadget tracer manager, like by the OCI and NRI hooks
+	SourceHook ContainerSource = "hook"
+	// SourceHost is the virtual container of the host
+	SourceHost ContainerSource = "host"
+	// SourceNestedRuntime containers run inside another container and were
+	// listed from the container runtime running in it
+	SourceNestedRuntime ContainerSource = "nested-runtime"
+)
+
 // close releases any resources (like  file descriptors) the container is using.
 func (c *Container) close() {
 	if c.mntNsFd != 0 {
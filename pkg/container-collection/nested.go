@@ -0,0 +1,270 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// nestedRuntimesInterval is how often the container runtimes running inside
+// containers are polled for new nested containers
+const nestedRuntimesInterval = 5 * time.Second
+
+// nestedRuntimes are the container runtimes looked for inside the containers:
+// containerd for kind nodes and Docker for Docker-in-Docker builders
+var nestedRuntimes = []struct {
+	name       types.RuntimeName
+	socketPath string
+}{
+	{types.RuntimeNameContainerd, runtimeclient.ContainerdDefaultSocketPath},
+	{types.RuntimeNameDocker, runtimeclient.DockerDefaultSocketPath},
+	{types.RuntimeNameCrio, runtimeclient.CrioDefaultSocketPath},
+}
+
+// WithNestedContainers detects the containers running inside other
+// containers, like the ones of kind nodes or Docker-in-Docker builders. The
+// container runtimes running in the containers are polled to add their
+// containers to the collection, and the containers nested in another one get
+// the ID of their parent in ParentContainerID and the names of all their
+// parents in Runtime.ContainerParents, so events are attributed to the
+// innermost container.
+//
+// This requires execution in the host pid namespace as the pids of the
+// nested containers are looked for in the host proc filesystem.
+//
+// ContainerCollection.Initialize(WithNestedContainers())
+func WithNestedContainers() ContainerCollectionOption {
+	return func(cc *ContainerCollection) error {
+		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
+			cc.setParentContainer(container)
+
+			for _, r := range nestedRuntimes {
+				socketPath := filepath.Join(host.HostProcFs, fmt.Sprint(container.ContainerPid()), "root", r.socketPath)
+				if _, err := os.Stat(socketPath); err != nil {
+					continue
+				}
+
+				runtimeClient, err := containerutils.NewContainerRuntimeClient(&containerutilsTypes.RuntimeConfig{
+					Name:       r.name,
+					SocketPath: socketPath,
+				})
+				if err != nil {
+					log.Debugf("nested containers: failed to connect to %s in container %s: %s",
+						r.name, container.Runtime.ContainerID, err)
+					continue
+				}
+				log.Debugf("nested containers: watching %s containers in container %s", r.name, container.Runtime.ContainerID)
+				go cc.watchNestedRuntime(container, r.name, runtimeClient)
+			}
+			return true
+		})
+		return nil
+	}
+}
+
+// setParentContainer sets the parent of the container, looking for the
+// container whose first process is an ancestor of the first process of the
+// container if it's not known yet
+func (cc *ContainerCollection) setParentContainer(container *Container) {
+	var parent *Container
+	if container.ParentContainerID != "" {
+		parent = cc.GetContainer(container.ParentContainerID)
+	} else {
+		parent = cc.findParentContainer(container)
+	}
+	if parent == nil {
+		return
+	}
+
+	container.ParentContainerID = parent.Runtime.ContainerID
+	container.Runtime.ContainerParents = parent.Runtime.ContainerName
+	if parent.Runtime.ContainerParents != "" {
+		container.Runtime.ContainerParents = parent.Runtime.ContainerParents + "/" + parent.Runtime.ContainerName
+	}
+}
+
+func (cc *ContainerCollection) findParentContainer(container *Container) *Container {
+	containersByPid := map[int]*Container{}
+	cc.containers.Range(func(key, value interface{}) bool {
+		c := value.(*Container)
+		if c.Runtime.ContainerID != container.Runtime.ContainerID && c.ContainerPid() != 0 {
+			containersByPid[int(c.ContainerPid())] = c
+		}
+		return true
+	})
+	if len(containersByPid) == 0 {
+		return nil
+	}
+
+	pid := int(container.ContainerPid())
+	for pid > 1 {
+		ppid, err := containerutils.GetParentPid(pid)
+		if err != nil {
+			return nil
+		}
+		if parent, ok := containersByPid[ppid]; ok {
+			return parent
+		}
+		pid = ppid
+	}
+	return nil
+}
+
+// watchNestedRuntime adds the containers of a container runtime running in
+// the parent container until the parent container is removed
+func (cc *ContainerCollection) watchNestedRuntime(
+	parent *Container,
+	runtimeName types.RuntimeName,
+	runtimeClient runtimeclient.ContainerRuntimeClient,
+) {
+	defer runtimeClient.Close()
+
+	nested := map[string]struct{}{}
+	removeNested := func() {
+		for id := range nested {
+			cc.RemoveContainer(id)
+		}
+	}
+
+	ticker := time.NewTicker(nestedRuntimesInterval)
+	defer ticker.Stop()
+
+	// The parent container is added to the collection after its enrichers
+	// ran, give it some time before stopping. It could have been dropped by
+	// another enricher too.
+	parentSeen := false
+	waits := 0
+	for {
+		select {
+		case <-cc.done:
+			return
+		case <-ticker.C:
+		}
+
+		if cc.GetContainer(parent.Runtime.ContainerID) != parent {
+			waits++
+			if parentSeen || waits > 3 {
+				removeNested()
+				return
+			}
+			continue
+		}
+		parentSeen = true
+
+		containers, err := runtimeClient.GetContainers()
+		if err != nil {
+			log.Debugf("nested containers: failed to list %s containers in container %s: %s",
+				runtimeName, parent.Runtime.ContainerID, err)
+			continue
+		}
+
+		running := map[string]struct{}{}
+		for _, c := range containers {
+			if c.Runtime.State != runtimeclient.StateRunning {
+				continue
+			}
+			running[c.Runtime.ContainerID] = struct{}{}
+			if _, ok := nested[c.Runtime.ContainerID]; ok {
+				continue
+			}
+
+			container, err := newNestedContainer(parent, runtimeClient, c.Runtime.ContainerID)
+			if errors.Is(err, runtimeclient.ErrPauseContainer) {
+				// Don't look at it again
+				nested[c.Runtime.ContainerID] = struct{}{}
+				continue
+			}
+			if err != nil {
+				log.Debugf("nested containers: skipping %s container %s in container %s: %s",
+					runtimeName, c.Runtime.ContainerID, parent.Runtime.ContainerID, err)
+				continue
+			}
+			nested[c.Runtime.ContainerID] = struct{}{}
+			cc.AddContainer(container)
+		}
+
+		for id := range nested {
+			if _, ok := running[id]; !ok {
+				cc.RemoveContainer(id)
+				delete(nested, id)
+			}
+		}
+	}
+}
+
+func newNestedContainer(parent *Container, runtimeClient runtimeclient.ContainerRuntimeClient, id string) (*Container, error) {
+	details, err := runtimeClient.GetContainerDetails(id)
+	if err != nil {
+		return nil, fmt.Errorf("getting container details: %w", err)
+	}
+
+	// The runtime reports the pid in the pid namespace of the parent
+	// container
+	if details.Pid <= 0 || details.Pid > math.MaxInt32 {
+		return nil, fmt.Errorf("invalid pid %d", details.Pid)
+	}
+	pid, err := containerutils.HostPidFromNsPid(int(parent.ContainerPid()), details.Pid)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Container{
+		ParentContainerID: parent.Runtime.ContainerID,
+		Source:            SourceNestedRuntime,
+	}
+	c.Runtime.ContainerPID = uint32(pid)
+	enrichContainerWithContainerData(&details.ContainerData, c)
+	return c, nil
+}
+
+// innermostContainers removes the containers that are parents of other
+// containers of the slice, like a kind node sharing its network namespace
+// with the pods using the host network
+func innermostContainers(containers []*Container) []*Container {
+	if len(containers) < 2 {
+		return containers
+	}
+
+	parents := map[string]struct{}{}
+	for _, c := range containers {
+		if c.ParentContainerID != "" {
+			parents[c.ParentContainerID] = struct{}{}
+		}
+	}
+	if len(parents) == 0 {
+		return containers
+	}
+
+	ret := make([]*Container, 0, len(containers))
+	for _, c := range containers {
+		if _, ok := parents[c.Runtime.ContainerID]; !ok {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
@@ -39,6 +39,7 @@ func (cc *ContainerCollection) EnrichEventByNetNs(event operators.ContainerInfoF
 	if len(containers) == 0 {
 		containers = lookupContainersByNetns(cc.cachedContainers, netNsId)
 	}
+	containers = innermostContainers(containers)
 	if len(containers) == 0 || containers[0].HostNetwork {
 		return
 	}
@@ -228,6 +228,7 @@ func WithContainerRuntimeEnrichment(runtime *containerutilsTypes.RuntimeConfig)
 
 			var c Container
 			c.Runtime.ContainerPID = uint32(pid)
+			c.Source = SourceRuntime
 			enrichContainerWithContainerData(&containerDetails.ContainerData, &c)
 			cc.initialContainers = append(cc.initialContainers, &c)
 		}
@@ -327,10 +328,12 @@ func withPodInformer(nodeName string, fallbackMode bool) ContainerCollectionOpti
 						// each iteration of the loop
 						newContainer := Container{}
 						newContainer = container
+						newContainer.Source = SourcePodInformer
 						if fallbackMode {
 							if cc.GetContainer(container.Runtime.ContainerID) != nil {
 								continue // container is already there. All good!
 							}
+							newContainer.Source = SourceFallbackPodInformer
 							log.Warnf("container %s/%s/%s wasn't detected by the main hook! The fallback pod informer will add it.",
 								container.K8s.Namespace, container.K8s.PodName, container.K8s.ContainerName)
 						}
@@ -352,6 +355,7 @@ func WithHost() ContainerCollectionOption {
 		newContainer.CgroupID = 1
 		newContainer.Runtime.ContainerPID = 1
 		newContainer.HostNetwork = true
+		newContainer.Source = SourceHost
 		cc.initialContainers = append(cc.initialContainers, &newContainer)
 		return nil
 	}
@@ -380,6 +384,7 @@ func WithInitialKubernetesContainers(nodeName string) ContainerCollectionOption
 			// each iteration of the loop
 			newContainer := Container{}
 			newContainer = container
+			newContainer.Source = SourceKubernetes
 			cc.initialContainers = append(cc.initialContainers,
 				&newContainer)
 		}
@@ -517,6 +522,12 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 
 		// Future containers
 		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
+			// Nested containers, like the ones of a Docker-in-Docker
+			// builder, aren't known by Kubernetes
+			if container.ParentContainerID != "" {
+				return true
+			}
+
 			// Skip enriching if basic k8s fields are already known.
 			// This is an optimization and to make sure to avoid erasing the fields in case of error.
 			if !runtimeclient.IsEnrichedWithK8sMetadata(container.K8s.BasicK8sMetadata) {
@@ -607,6 +618,7 @@ func WithRuncFanotify() ContainerCollectionOption {
 						},
 					},
 					OciConfig: notif.ContainerConfig,
+					Source:    SourceRuncFanotify,
 				}
 				cc.AddContainer(container)
 			case runcfanotify.EventTypeRemoveContainer:
@@ -654,6 +666,7 @@ func WithContainerFanotifyEbpf() ContainerCollectionOption {
 						},
 					},
 					OciConfig: notif.ContainerConfig,
+					Source:    SourceFanotifyEbpf,
 				}
 				cc.AddContainer(container)
 			case containerhook.EventTypeRemoveContainer:
@@ -718,6 +731,10 @@ func WithLinuxNamespaceEnrichment() ContainerCollectionOption {
 		if err != nil {
 			return fmt.Errorf("getting host net ns inode: %w", err)
 		}
+		usernsHost, err := containerutils.GetUserNs(1)
+		if err != nil {
+			return fmt.Errorf("getting host user ns inode: %w", err)
+		}
 
 		cc.containerEnrichers = append(cc.containerEnrichers, func(container *Container) bool {
 			pid := int(container.ContainerPid())
@@ -740,6 +757,28 @@ func WithLinuxNamespaceEnrichment() ContainerCollectionOption {
 			}
 			container.Netns = netns
 			container.HostNetwork = netns == netnsHost
+
+			userns, err := containerutils.GetUserNs(pid)
+			if err != nil {
+				log.Errorf("namespace enricher: failed to get user namespace on container %s: %s", container.Runtime.ContainerID, err)
+				return true
+			}
+			container.Userns = userns
+			container.HostUserns = userns == usernsHost
+			if container.HostUserns {
+				return true
+			}
+
+			// The IDs of the container are remapped, keep the mappings to
+			// translate the IDs of the events
+			container.UIDMap, err = containerutils.GetUIDMap(pid)
+			if err != nil {
+				log.Errorf("namespace enricher: failed to get uid map on container %s: %s", container.Runtime.ContainerID, err)
+			}
+			container.GIDMap, err = containerutils.GetGIDMap(pid)
+			if err != nil {
+				log.Errorf("namespace enricher: failed to get gid map on container %s: %s", container.Runtime.ContainerID, err)
+			}
 			return true
 		})
 		return nil
@@ -0,0 +1,100 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containercollection
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UserNsHost is the owner of the user namespace of the host
+const UserNsHost = "host"
+
+// LookupContainersByUserns returns a slice of containers that run in a given
+// user namespace. Or an empty slice if there are no containers running in
+// that user namespace.
+func (cc *ContainerCollection) LookupContainersByUserns(usernsid uint64) []*Container {
+	containers := lookupContainersByUserns(&cc.containers, usernsid)
+	if len(containers) == 0 && cc.cachedContainers != nil {
+		containers = lookupContainersByUserns(cc.cachedContainers, usernsid)
+	}
+	return containers
+}
+
+func lookupContainersByUserns(m *sync.Map, usernsid uint64) (containers []*Container) {
+	m.Range(func(key, value interface{}) bool {
+		c := value.(*Container)
+		if c.Userns == usernsid {
+			containers = append(containers, c)
+		}
+		return true
+	})
+	return containers
+}
+
+// UserNsOwner returns UserNsHost for the user namespace of the host, the
+// namespace and name of the pod for the Kubernetes containers sharing a user
+// namespace, or the names of the containers running in it.
+func (cc *ContainerCollection) UserNsOwner(usernsid uint64) string {
+	if usernsid == 0 {
+		return ""
+	}
+
+	containers := cc.LookupContainersByUserns(usernsid)
+	if len(containers) == 0 {
+		return ""
+	}
+	if containers[0].HostUserns {
+		return UserNsHost
+	}
+
+	names := make([]string, 0, len(containers))
+	samePod := true
+	for _, c := range containers {
+		if c.K8s.PodName == "" || c.K8s.Namespace != containers[0].K8s.Namespace ||
+			c.K8s.PodName != containers[0].K8s.PodName {
+			samePod = false
+		}
+		if c.K8s.PodName != "" {
+			names = append(names, c.K8s.Namespace+"/"+c.K8s.PodName+"/"+c.K8s.ContainerName)
+		} else {
+			names = append(names, c.Runtime.ContainerName)
+		}
+	}
+	if samePod && len(containers) > 1 {
+		return containers[0].K8s.Namespace + "/" + containers[0].K8s.PodName
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ContainerIDs maps the uid and gid of the host to the ones in the user
+// namespace of the container running in the mount namespace. The IDs are
+// returned as they are for processes not running in a container or running
+// in the user namespace of the host.
+func (cc *ContainerCollection) ContainerIDs(mountnsid uint64, uid, gid uint32) (uint32, uint32) {
+	container := cc.LookupContainerByMntns(mountnsid)
+	if container == nil && cc.cachedContainers != nil {
+		container = lookupContainerByMntns(cc.cachedContainers, mountnsid)
+	}
+	if container == nil || container.HostUserns {
+		return uid, gid
+	}
+
+	uid, _ = container.UIDMap.ToContainer(uid)
+	gid, _ = container.GIDMap.ToContainer(gid)
+	return uid, gid
+}
@@ -103,6 +103,10 @@ func GetNetNs(pid int) (uint64, error) {
 	return getNamespaceInode(pid, "net")
 }
 
+func GetUserNs(pid int) (uint64, error) {
+	return getNamespaceInode(pid, "user")
+}
+
 func ParseOCIState(stateBuf []byte) (id string, pid int, err error) {
 	ociState := &ocispec.State{}
 	err = json.Unmarshal(stateBuf, ociState)
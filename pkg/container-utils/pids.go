@@ -0,0 +1,146 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// procStatus contains the fields of /proc/<pid>/status used to follow the
+// processes across pid namespaces
+type procStatus struct {
+	ppid int
+	// nsPids are the pids of the process in each pid namespace it belongs
+	// to, from the pid namespace of the reader to the one of the process.
+	nsPids []int
+}
+
+func parseProcStatus(reader io.Reader) (*procStatus, error) {
+	status := &procStatus{}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "PPid":
+			ppid, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("parsing PPid %q: %w", value, err)
+			}
+			status.ppid = ppid
+		case "NSpid":
+			for _, field := range strings.Fields(value) {
+				pid, err := strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("parsing NSpid %q: %w", value, err)
+				}
+				status.nsPids = append(status.nsPids, pid)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func readProcStatus(pid int) (*procStatus, error) {
+	f, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "status"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseProcStatus(f)
+}
+
+// GetParentPid returns the pid of the parent of the process pid. Both pids
+// are in the host pid namespace.
+func GetParentPid(pid int) (int, error) {
+	status, err := readProcStatus(pid)
+	if err != nil {
+		return 0, err
+	}
+	return status.ppid, nil
+}
+
+// IsDescendant returns true if the process pid is a descendant of the
+// process ancestor. Both pids are in the host pid namespace.
+func IsDescendant(pid, ancestor int) bool {
+	for pid > 1 {
+		ppid, err := GetParentPid(pid)
+		if err != nil {
+			return false
+		}
+		if ppid == ancestor {
+			return true
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// HostPidFromNsPid returns the pid in the host pid namespace of the process
+// whose pid is nsPid in the pid namespace of the process refPid, like the
+// pids of the nested containers reported by a container runtime running in
+// a container. refPid is in the host pid namespace and must be the first
+// process of its pid namespace.
+func HostPidFromNsPid(refPid, nsPid int) (int, error) {
+	ref, err := readProcStatus(refPid)
+	if err != nil {
+		return 0, err
+	}
+	if len(ref.nsPids) == 0 {
+		return 0, fmt.Errorf("NSpid not available for pid %d", refPid)
+	}
+	level := len(ref.nsPids) - 1
+	if level == 0 {
+		return nsPid, nil
+	}
+
+	entries, err := os.ReadDir(host.HostProcFs)
+	if err != nil {
+		return 0, err
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		status, err := readProcStatus(pid)
+		if err != nil || len(status.nsPids) <= level || status.nsPids[level] != nsPid {
+			continue
+		}
+		// Sibling pid namespaces at the same level use the same pids, only
+		// keep the processes of the pid namespace of refPid. Orphans are
+		// reparented to refPid, so all of them are its descendants.
+		if pid == refPid || IsDescendant(pid, refPid) {
+			return pid, nil
+		}
+	}
+	return 0, fmt.Errorf("pid %d not found in the pid namespace of pid %d", nsPid, refPid)
+}
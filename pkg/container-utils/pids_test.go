@@ -0,0 +1,61 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerutils
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcStatus(t *testing.T) {
+	t.Parallel()
+
+	status, err := parseProcStatus(strings.NewReader(`Name:	containerd
+Umask:	0022
+State:	S (sleeping)
+Tgid:	4242
+Ngid:	0
+Pid:	4242
+PPid:	4200
+TracerPid:	0
+NStgid:	4242	215	1
+NSpid:	4242	215	1
+NSpgid:	4242	215	1
+`))
+	require.NoError(t, err)
+	require.Equal(t, 4200, status.ppid)
+	require.Equal(t, []int{4242, 215, 1}, status.nsPids)
+
+	_, err = parseProcStatus(strings.NewReader("PPid:\tfoo\n"))
+	require.Error(t, err)
+}
+
+func TestHostPidFromNsPid(t *testing.T) {
+	t.Parallel()
+
+	// The test process runs in the same pid namespace as the reader, so the
+	// pids are kept as they are
+	pid := os.Getpid()
+	if _, err := readProcStatus(pid); err != nil {
+		t.Skipf("proc filesystem not available: %s", err)
+	}
+
+	hostPid, err := HostPidFromNsPid(pid, 1234)
+	require.NoError(t, err)
+	require.Equal(t, 1234, hostPid)
+}
@@ -17,16 +17,21 @@ package podman
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
 )
 
 const (
@@ -35,28 +40,96 @@ const (
 	containerInspectURL      = "http://d/v4.0.0/libpod/containers/%s/json"
 )
 
+var errContainerNotFound = errors.New("container not found")
+
+// PodmanClient talks to the API of rootful Podman and, when the default
+// socket is used, to the APIs of rootless Podman of all the users, as each of
+// them has its own containers.
 type PodmanClient struct {
-	client http.Client
+	socketPath      string
+	rootlessGlob    string
+	clientsMu       sync.Mutex
+	clientsBySocket map[string]*http.Client
 }
 
 func NewPodmanClient(socketPath string) runtimeclient.ContainerRuntimeClient {
+	defaultSocketPath := filepath.Join(host.HostRoot, runtimeclient.PodmanDefaultSocketPath)
 	if socketPath == "" {
-		socketPath = runtimeclient.PodmanDefaultSocketPath
+		socketPath = defaultSocketPath
+	}
+
+	p := &PodmanClient{
+		socketPath:      socketPath,
+		clientsBySocket: make(map[string]*http.Client),
+	}
+
+	// A socket given explicitly, e.g. the one of a rootless user, is the only
+	// one used
+	if filepath.Clean(socketPath) == defaultSocketPath {
+		p.rootlessGlob = filepath.Join(host.HostRoot, runtimeclient.PodmanRootlessSocketPattern)
+	}
+
+	return p
+}
+
+// sockets returns the paths of the sockets to query. The rootless ones are
+// looked up every time, as users can start Podman at any time.
+func (p *PodmanClient) sockets() []string {
+	sockets := []string{p.socketPath}
+	if p.rootlessGlob == "" {
+		return sockets
 	}
 
-	return &PodmanClient{
-		client: http.Client{
-			Transport: &http.Transport{
-				DialContext: func(ctx context.Context, _, _ string) (conn net.Conn, err error) {
-					return net.Dial("unix", socketPath)
-				},
+	// The only possible error is a malformed pattern
+	rootless, _ := filepath.Glob(p.rootlessGlob)
+	for _, socket := range rootless {
+		if socket != p.socketPath {
+			sockets = append(sockets, socket)
+		}
+	}
+	return sockets
+}
+
+func (p *PodmanClient) client(socketPath string) *http.Client {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	if client, ok := p.clientsBySocket[socketPath]; ok {
+		return client
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (conn net.Conn, err error) {
+				return net.Dial("unix", socketPath)
 			},
-			Timeout: defaultConnectionTimeout,
 		},
+		Timeout: defaultConnectionTimeout,
 	}
+	p.clientsBySocket[socketPath] = client
+	return client
 }
 
-func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.ContainerData, error) {
+// forEachSocket calls fn with the client of each socket. Errors are only
+// reported if the API couldn't be reached through any socket, as rootful
+// Podman doesn't need to run to trace rootless containers and vice versa.
+func (p *PodmanClient) forEachSocket(fn func(client *http.Client) error) error {
+	var errs error
+	succeeded := false
+	for _, socket := range p.sockets() {
+		if err := fn(p.client(socket)); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", socket, err))
+			continue
+		}
+		succeeded = true
+	}
+	if succeeded {
+		return nil
+	}
+	return errs
+}
+
+func listContainers(client *http.Client, containerID string) ([]*runtimeclient.ContainerData, error) {
 	var filters string
 	if containerID != "" {
 		f, err := json.Marshal(map[string][]string{"id": {containerID}})
@@ -66,7 +139,7 @@ func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.Cont
 		filters = "&filters=" + url.QueryEscape(string(f))
 	}
 
-	resp, err := p.client.Get(containerListAllURL + filters)
+	resp, err := client.Get(containerListAllURL + filters)
 	if err != nil {
 		return nil, fmt.Errorf("listing containers: %w", err)
 	}
@@ -79,6 +152,7 @@ func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.Cont
 	var containers []struct {
 		ID    string   `json:"Id"`
 		Names []string `json:"Names"`
+		Image string   `json:"Image"`
 		State string   `json:"State"`
 	}
 	if err = json.NewDecoder(resp.Body).Decode(&containers); err != nil {
@@ -87,18 +161,36 @@ func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.Cont
 
 	ret := make([]*runtimeclient.ContainerData, len(containers))
 	for i, c := range containers {
+		var name string
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
 		ret[i] = &runtimeclient.ContainerData{
 			Runtime: runtimeclient.RuntimeContainerData{
-				ContainerID:   c.ID,
-				ContainerName: c.Names[0],
-				RuntimeName:   types.RuntimeNamePodman,
-				State:         containerStatusStateToRuntimeClientState(c.State),
+				ContainerID:        c.ID,
+				ContainerName:      name,
+				ContainerImageName: c.Image,
+				RuntimeName:        types.RuntimeNamePodman,
+				State:              containerStatusStateToRuntimeClientState(c.State),
 			},
 		}
 	}
 	return ret, nil
 }
 
+func (p *PodmanClient) listContainers(containerID string) ([]*runtimeclient.ContainerData, error) {
+	var ret []*runtimeclient.ContainerData
+	err := p.forEachSocket(func(client *http.Client) error {
+		containers, err := listContainers(client, containerID)
+		if err != nil {
+			return err
+		}
+		ret = append(ret, containers...)
+		return nil
+	})
+	return ret, err
+}
+
 func (p *PodmanClient) GetContainers() ([]*runtimeclient.ContainerData, error) {
 	return p.listContainers("")
 }
@@ -118,56 +210,104 @@ func (p *PodmanClient) GetContainer(containerID string) (*runtimeclient.Containe
 	return containers[0], nil
 }
 
-func (p *PodmanClient) GetContainerDetails(containerID string) (*runtimeclient.ContainerDetailsData, error) {
-	resp, err := p.client.Get(fmt.Sprintf(containerInspectURL, containerID))
+func getContainerDetails(client *http.Client, containerID string) (*runtimeclient.ContainerDetailsData, error) {
+	resp, err := client.Get(fmt.Sprintf(containerInspectURL, containerID))
 	if err != nil {
 		return nil, fmt.Errorf("inspecting container %q: %w", containerID, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("inspecting container %q: %w", containerID, errContainerNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("inspecting container via rest api %q: %s", containerID, resp.Status)
 	}
 
 	var container struct {
-		ID    string `json:"Id"`
-		Name  string `json:"Name"`
-		State struct {
+		ID          string `json:"Id"`
+		Name        string `json:"Name"`
+		ImageName   string `json:"ImageName"`
+		ImageDigest string `json:"ImageDigest"`
+		State       struct {
 			Status     string `json:"Status"`
 			Pid        int    `json:"Pid"`
 			CgroupPath string `json:"CgroupPath"`
 		} `json:"State"`
+		Mounts []struct {
+			Source      string `json:"Source"`
+			Destination string `json:"Destination"`
+		} `json:"Mounts"`
 	}
 
 	if err = json.NewDecoder(resp.Body).Decode(&container); err != nil {
 		return nil, fmt.Errorf("decoding container %q: %w", containerID, err)
 	}
 
-	return &runtimeclient.ContainerDetailsData{
+	details := &runtimeclient.ContainerDetailsData{
 		ContainerData: runtimeclient.ContainerData{
 			Runtime: runtimeclient.RuntimeContainerData{
-				ContainerID:   container.ID,
-				ContainerName: container.Name,
-				RuntimeName:   types.RuntimeNamePodman,
-				State:         containerStatusStateToRuntimeClientState(container.State.Status),
+				ContainerID:          container.ID,
+				ContainerName:        container.Name,
+				ContainerImageName:   container.ImageName,
+				ContainerImageDigest: container.ImageDigest,
+				RuntimeName:          types.RuntimeNamePodman,
+				State:                containerStatusStateToRuntimeClientState(container.State.Status),
 			},
 		},
 		Pid:         container.State.Pid,
 		CgroupsPath: container.State.CgroupPath,
-	}, nil
+	}
+	for _, m := range container.Mounts {
+		details.Mounts = append(details.Mounts, runtimeclient.ContainerMountData{
+			Source:      m.Source,
+			Destination: m.Destination,
+		})
+	}
+	return details, nil
+}
+
+func (p *PodmanClient) GetContainerDetails(containerID string) (*runtimeclient.ContainerDetailsData, error) {
+	containerID, err := runtimeclient.ParseContainerID(types.RuntimeNamePodman, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the Podman owning the container knows it
+	var errs error
+	for _, socket := range p.sockets() {
+		details, err := getContainerDetails(p.client(socket), containerID)
+		if err == nil {
+			return details, nil
+		}
+		if !errors.Is(err, errContainerNotFound) {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", socket, err))
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, fmt.Errorf("container %q not found", containerID)
 }
 
 func (p *PodmanClient) Close() error {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	for socket, client := range p.clientsBySocket {
+		client.CloseIdleConnections()
+		delete(p.clientsBySocket, socket)
+	}
 	return nil
 }
 
 func containerStatusStateToRuntimeClientState(containerState string) string {
-	switch containerState {
-	case "created":
+	switch strings.ToLower(containerState) {
+	case "created", "configured":
 		return runtimeclient.StateCreated
 	case "running":
 		return runtimeclient.StateRunning
-	case "exited":
+	case "exited", "stopped":
 		return runtimeclient.StateExited
 	case "dead":
 		return runtimeclient.StateExited
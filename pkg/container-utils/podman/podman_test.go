@@ -0,0 +1,145 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package podman
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
+)
+
+type fakeContainer struct {
+	id    string
+	name  string
+	image string
+	pid   int
+}
+
+// startFakePodman serves the part of the libpod API used by the client on
+// socketPath.
+func startFakePodman(t *testing.T, socketPath string, containers ...fakeContainer) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(socketPath), 0o700))
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v4.0.0/libpod/containers/json", func(w http.ResponseWriter, r *http.Request) {
+		var list []string
+		for _, c := range containers {
+			if f := r.URL.Query().Get("filters"); f != "" && !strings.Contains(f, c.id) {
+				continue
+			}
+			list = append(list, fmt.Sprintf(`{"Id":%q,"Names":[%q],"Image":%q,"State":"running"}`,
+				c.id, c.name, c.image))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(list, ","))
+	})
+	mux.HandleFunc("/v4.0.0/libpod/containers/{id}/json", func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range containers {
+			if c.id == r.PathValue("id") {
+				fmt.Fprintf(w, `{"Id":%q,"Name":%q,"ImageName":%q,"ImageDigest":"sha256:1234",`+
+					`"State":{"Status":"running","Pid":%d,"CgroupPath":"/user.slice/%s"},`+
+					`"Mounts":[{"Source":"/src","Destination":"/dst"}]}`,
+					c.id, c.name, c.image, c.pid, c.id)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	})
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+}
+
+func TestPodmanClient(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rootful := filepath.Join(dir, "run/podman/podman.sock")
+	rootless := filepath.Join(dir, "run/user/1000/podman/podman.sock")
+
+	startFakePodman(t, rootful, fakeContainer{"aaaa", "rootful", "docker.io/library/nginx:latest", 100})
+	startFakePodman(t, rootless, fakeContainer{"bbbb", "rootless", "docker.io/library/busybox:latest", 200})
+
+	client := &PodmanClient{
+		socketPath:      rootful,
+		rootlessGlob:    filepath.Join(dir, runtimeclient.PodmanRootlessSocketPattern),
+		clientsBySocket: make(map[string]*http.Client),
+	}
+	t.Cleanup(func() { client.Close() })
+
+	containers, err := client.GetContainers()
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+	require.Equal(t, "rootful", containers[0].Runtime.ContainerName)
+	require.Equal(t, "rootless", containers[1].Runtime.ContainerName)
+	require.Equal(t, "docker.io/library/busybox:latest", containers[1].Runtime.ContainerImageName)
+
+	container, err := client.GetContainer("bbbb")
+	require.NoError(t, err)
+	require.Equal(t, "rootless", container.Runtime.ContainerName)
+
+	details, err := client.GetContainerDetails("bbbb")
+	require.NoError(t, err)
+	require.Equal(t, 200, details.Pid)
+	require.Equal(t, "/user.slice/bbbb", details.CgroupsPath)
+	require.Equal(t, "docker.io/library/busybox:latest", details.Runtime.ContainerImageName)
+	require.Equal(t, "sha256:1234", details.Runtime.ContainerImageDigest)
+	require.Equal(t, runtimeclient.StateRunning, details.Runtime.State)
+	require.Equal(t, []runtimeclient.ContainerMountData{{Source: "/src", Destination: "/dst"}}, details.Mounts)
+
+	_, err = client.GetContainerDetails("cccc")
+	require.Error(t, err)
+}
+
+func TestPodmanClientWithoutRootful(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	rootless := filepath.Join(dir, "run/user/1000/podman/podman.sock")
+	startFakePodman(t, rootless, fakeContainer{"bbbb", "rootless", "busybox", 200})
+
+	client := &PodmanClient{
+		socketPath:      filepath.Join(dir, "run/podman/podman.sock"),
+		rootlessGlob:    filepath.Join(dir, runtimeclient.PodmanRootlessSocketPattern),
+		clientsBySocket: make(map[string]*http.Client),
+	}
+	t.Cleanup(func() { client.Close() })
+
+	containers, err := client.GetContainers()
+	require.NoError(t, err)
+	require.Len(t, containers, 1)
+
+	details, err := client.GetContainerDetails("bbbb")
+	require.NoError(t, err)
+	require.Equal(t, 200, details.Pid)
+
+	// A socket given explicitly is the only one used
+	explicit := NewPodmanClient(filepath.Join(dir, "run/podman/podman.sock"))
+	t.Cleanup(func() { explicit.Close() })
+	_, err = explicit.GetContainers()
+	require.Error(t, err)
+}
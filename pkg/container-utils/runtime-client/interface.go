@@ -29,6 +29,10 @@ const (
 	ContainerdDefaultSocketPath = "/run/containerd/containerd.sock"
 	DockerDefaultSocketPath     = "/run/docker.sock"
 	CriDockerDefaultSocketPath  = "/run/cri-dockerd.sock"
+
+	// PodmanRootlessSocketPattern matches the API sockets of rootless Podman,
+	// one per user running it.
+	PodmanRootlessSocketPattern = "/run/user/*/podman/podman.sock"
 )
 
 var ErrPauseContainer = errors.New("it is a pause container")
@@ -76,7 +76,7 @@ func (c *K8sContainer) Start(t *testing.T) {
 
 	igtesting.RunTestSteps([]igtesting.TestStep{
 		createTestNamespaceCommand(c.options.namespace),
-		podCommand(t, c.name, c.options.image, c.options.namespace, `["/bin/sh", "-c"]`, c.cmd, c.options.limits),
+		podCommand(t, c.name, c.options.image, c.options.namespace, `["/bin/sh", "-c"]`, c.cmd, c.options.limits, c.options.readinessPort),
 		sleepForSecondsCommand(2),
 		waitCommand,
 	}, t)
@@ -100,7 +100,7 @@ const (
 	namespaceLabelValue string = "ig-integration-tests"
 )
 
-func createPodYaml(podname, image, namespace, cmd, commandArgs string, limits map[string]string) string {
+func createPodYaml(podname, image, namespace, cmd, commandArgs string, limits map[string]string, readinessPort int) string {
 	yamlStr := fmt.Sprintf(`apiVersion: v1
 kind: Pod
 metadata:
@@ -133,12 +133,16 @@ spec:
 		}
 	}
 
+	if readinessPort != 0 {
+		yamlStr = yamlStr + fmt.Sprintf("    readinessProbe:\n      httpGet:\n        path: /\n        port: %d\n      periodSeconds: 1\n", readinessPort)
+	}
+
 	return yamlStr
 }
 
 // podCommand returns a Command that starts a pod with a specified image, command and args
-func podCommand(t *testing.T, podname, image, namespace, cmd, commandArgs string, limits map[string]string) *command.Command {
-	podYaml := createPodYaml(podname, image, namespace, cmd, commandArgs, limits)
+func podCommand(t *testing.T, podname, image, namespace, cmd, commandArgs string, limits map[string]string, readinessPort int) *command.Command {
+	podYaml := createPodYaml(podname, image, namespace, cmd, commandArgs, limits, readinessPort)
 
 	cmdStr := fmt.Sprintf(`kubectl apply -f - <<"EOF"
 %s
@@ -31,6 +31,8 @@ func TestCreatePodYaml(t *testing.T) {
 		cmd       string
 		args      string
 		limits    map[string]string
+
+		readinessPort int
 	}
 
 	tests := []testCases{
@@ -213,11 +215,37 @@ spec:
 				"memory": "2Gi",
 			},
 		},
+		{
+			testName: "WithReadinessProbe",
+			expected: []string{`apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+  namespace: ns
+  labels:
+    run: foo
+spec:
+  restartPolicy: Never
+  terminationGracePeriodSeconds: 0
+  containers:
+  - name: foo
+    image: foo-image
+    readinessProbe:
+      httpGet:
+        path: /
+        port: 8080
+      periodSeconds: 1
+`},
+			podName:       "foo",
+			imageName:     "foo-image",
+			namespace:     "ns",
+			readinessPort: 8080,
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.testName, func(t *testing.T) {
-			actual := createPodYaml(tc.podName, tc.imageName, tc.namespace, tc.cmd, tc.args, tc.limits)
+			actual := createPodYaml(tc.podName, tc.imageName, tc.namespace, tc.cmd, tc.args, tc.limits, tc.readinessPort)
 			foundEqual := false
 			for _, e := range tc.expected {
 				if e == actual {
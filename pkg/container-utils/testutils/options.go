@@ -42,6 +42,7 @@ type containerOptions struct {
 	portBindings     nat.PortMap
 	privileged       bool
 	limits           map[string]string
+	readinessPort    int
 
 	// forceDelete is mostly used for debugging purposes, when a container
 	// fails to be deleted and we want to force it.
@@ -156,3 +157,11 @@ func WithLimits(limits map[string]string) Option {
 		opts.limits = limits
 	}
 }
+
+// WithReadinessProbe adds an HTTP readiness probe on the given port to the
+// container. Only supported by Kubernetes.
+func WithReadinessProbe(port int) Option {
+	return func(opts *containerOptions) {
+		opts.readinessPort = port
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// OverflowID is the ID reported by the kernel for the IDs of the host that
+// aren't mapped in a user namespace
+const OverflowID = 65534
+
+// IDMapping is a line of /proc/<pid>/uid_map or /proc/<pid>/gid_map: Size
+// IDs starting from HostID in the parent user namespace are mapped to the
+// IDs starting from ContainerID in the user namespace of the process.
+type IDMapping struct {
+	ContainerID uint32 `json:"containerID"`
+	HostID      uint32 `json:"hostID"`
+	Size        uint32 `json:"size"`
+}
+
+// IDMap is the UID or GID mapping of a user namespace
+type IDMap []IDMapping
+
+// IsIdentity returns true if the IDs are the same inside and outside of the
+// user namespace, like in the initial user namespace.
+func (m IDMap) IsIdentity() bool {
+	for _, mapping := range m {
+		if mapping.ContainerID != mapping.HostID {
+			return false
+		}
+	}
+	return true
+}
+
+// ToContainer returns the ID in the user namespace corresponding to the ID
+// of the host. It returns OverflowID and false if the ID isn't mapped.
+func (m IDMap) ToContainer(hostID uint32) (uint32, bool) {
+	if len(m) == 0 {
+		return hostID, true
+	}
+	for _, mapping := range m {
+		if hostID >= mapping.HostID && uint64(hostID) < uint64(mapping.HostID)+uint64(mapping.Size) {
+			return mapping.ContainerID + hostID - mapping.HostID, true
+		}
+	}
+	return OverflowID, false
+}
+
+// ToHost returns the ID of the host corresponding to the ID in the user
+// namespace. It returns OverflowID and false if the ID isn't mapped.
+func (m IDMap) ToHost(containerID uint32) (uint32, bool) {
+	if len(m) == 0 {
+		return containerID, true
+	}
+	for _, mapping := range m {
+		if containerID >= mapping.ContainerID && uint64(containerID) < uint64(mapping.ContainerID)+uint64(mapping.Size) {
+			return mapping.HostID + containerID - mapping.ContainerID, true
+		}
+	}
+	return OverflowID, false
+}
+
+// ParseIDMap parses the content of a uid_map or gid_map file
+func ParseIDMap(reader io.Reader) (IDMap, error) {
+	var m IDMap
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		// 0     100000      65536
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid id mapping %q", scanner.Text())
+		}
+
+		var values [3]uint32
+		for i, field := range fields {
+			v, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid id mapping %q: %w", scanner.Text(), err)
+			}
+			values[i] = uint32(v)
+		}
+		m = append(m, IDMapping{ContainerID: values[0], HostID: values[1], Size: values[2]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func readIDMap(pid int, file string) (IDMap, error) {
+	f, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseIDMap(f)
+}
+
+// GetUIDMap returns the UID mapping of the user namespace of the process
+// pid. It needs a pid in the host pid namespace.
+func GetUIDMap(pid int) (IDMap, error) {
+	return readIDMap(pid, "uid_map")
+}
+
+// GetGIDMap returns the GID mapping of the user namespace of the process
+// pid. It needs a pid in the host pid namespace.
+func GetGIDMap(pid int) (IDMap, error) {
+	return readIDMap(pid, "gid_map")
+}
@@ -0,0 +1,85 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containerutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDMap(t *testing.T) {
+	t.Parallel()
+
+	m, err := ParseIDMap(strings.NewReader("         0     100000      65536\n     65536     300000         10\n"))
+	require.NoError(t, err)
+	require.Equal(t, IDMap{
+		{ContainerID: 0, HostID: 100000, Size: 65536},
+		{ContainerID: 65536, HostID: 300000, Size: 10},
+	}, m)
+	require.False(t, m.IsIdentity())
+
+	_, err = ParseIDMap(strings.NewReader("0 100000\n"))
+	require.Error(t, err)
+
+	m, err = ParseIDMap(strings.NewReader("         0          0 4294967295\n"))
+	require.NoError(t, err)
+	require.True(t, m.IsIdentity())
+}
+
+func TestIDMap(t *testing.T) {
+	t.Parallel()
+
+	m := IDMap{
+		{ContainerID: 0, HostID: 100000, Size: 65536},
+		{ContainerID: 65536, HostID: 300000, Size: 10},
+	}
+
+	tests := []struct {
+		hostID      uint32
+		containerID uint32
+		mapped      bool
+	}{
+		{hostID: 100000, containerID: 0, mapped: true},
+		{hostID: 101000, containerID: 1000, mapped: true},
+		{hostID: 165535, containerID: 65535, mapped: true},
+		{hostID: 300009, containerID: 65545, mapped: true},
+		{hostID: 1000, containerID: OverflowID, mapped: false},
+		{hostID: 165536, containerID: OverflowID, mapped: false},
+	}
+	for _, test := range tests {
+		id, ok := m.ToContainer(test.hostID)
+		require.Equal(t, test.mapped, ok, "host id %d", test.hostID)
+		require.Equal(t, test.containerID, id, "host id %d", test.hostID)
+
+		if test.mapped {
+			id, ok = m.ToHost(test.containerID)
+			require.True(t, ok)
+			require.Equal(t, test.hostID, id)
+		}
+	}
+
+	// An identity map covering all the IDs mustn't overflow
+	identity := IDMap{{ContainerID: 0, HostID: 0, Size: 4294967295}}
+	id, ok := identity.ToContainer(4294967294)
+	require.True(t, ok)
+	require.Equal(t, uint32(4294967294), id)
+
+	// No map keeps the IDs as they are
+	id, ok = IDMap(nil).ToContainer(1000)
+	require.True(t, ok)
+	require.Equal(t, uint32(1000), id)
+}
@@ -0,0 +1,168 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package continuousprofiler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf"
+	log "github.com/sirupsen/logrus"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	cputracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/cpu/tracer"
+	profileexporter "github.com/inspektor-gadget/inspektor-gadget/pkg/profile-exporter"
+)
+
+const (
+	DefaultSampleFreq         = 19
+	DefaultContainersPerRound = 1
+	DefaultRoundDuration      = 60 * time.Second
+)
+
+// Config bounds the overhead of the profiler: at most ContainersPerRound
+// containers are sampled at SampleFreq Hz at the same time.
+type Config struct {
+	SampleFreq         uint64
+	ContainersPerRound int
+	RoundDuration      time.Duration
+}
+
+type Profiler struct {
+	cc       *containercollection.ContainerCollection
+	exporter profileexporter.Exporter
+	config   Config
+
+	// cursor is the ID of the last container profiled
+	cursor string
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(cc *containercollection.ContainerCollection, exporter profileexporter.Exporter, config Config) *Profiler {
+	if config.SampleFreq == 0 {
+		config.SampleFreq = DefaultSampleFreq
+	}
+	if config.ContainersPerRound <= 0 {
+		config.ContainersPerRound = DefaultContainersPerRound
+	}
+	if config.RoundDuration <= 0 {
+		config.RoundDuration = DefaultRoundDuration
+	}
+	return &Profiler{
+		cc:       cc,
+		exporter: exporter,
+		config:   config,
+	}
+}
+
+// Start starts profiling rounds in the background until Stop is called
+func (p *Profiler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	log.Infof("Starting continuous profiler: %d container(s) every %s at %dHz",
+		p.config.ContainersPerRound, p.config.RoundDuration, p.config.SampleFreq)
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			if err := p.round(ctx); err != nil {
+				log.Warnf("continuous profiler: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+		}
+	}()
+}
+
+func (p *Profiler) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	p.wg.Wait()
+	p.exporter.Close()
+}
+
+// round profiles the next batch of containers for RoundDuration and pushes one
+// profile per container
+func (p *Profiler) round(ctx context.Context) error {
+	containers := p.cc.GetContainersBySelector(&containercollection.ContainerSelector{})
+	var selected []*containercollection.Container
+	selected, p.cursor = selectContainers(containers, p.cursor, p.config.ContainersPerRound)
+	if len(selected) == 0 {
+		// Nothing to profile, wait for containers to show up
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.config.RoundDuration):
+		}
+		return nil
+	}
+
+	mntnsMap, err := ebpf.NewMap(&ebpf.MapSpec{
+		Name:       "ig_cont_prof",
+		Type:       ebpf.Hash,
+		KeySize:    8,
+		ValueSize:  4,
+		MaxEntries: uint32(len(selected)),
+	})
+	if err != nil {
+		return fmt.Errorf("creating mount namespace map: %w", err)
+	}
+	defer mntnsMap.Close()
+
+	for _, c := range selected {
+		if err := mntnsMap.Put(c.Mntns, uint32(1)); err != nil {
+			return fmt.Errorf("adding container %s to mount namespace map: %w", c.Runtime.ContainerID, err)
+		}
+	}
+
+	tracer, err := cputracer.NewTracer(p.cc, &cputracer.Config{
+		MountnsMap: mntnsMap,
+		SampleFreq: p.config.SampleFreq,
+	})
+	if err != nil {
+		return fmt.Errorf("creating cpu tracer: %w", err)
+	}
+
+	start := time.Now()
+	select {
+	case <-ctx.Done():
+	case <-time.After(p.config.RoundDuration):
+	}
+	duration := time.Since(start)
+
+	reports, err := tracer.StopAndCollect()
+	if err != nil {
+		return fmt.Errorf("collecting profile: %w", err)
+	}
+
+	// Pushing uses its own context: samples collected until the profiler was
+	// stopped are still worth sending
+	pushCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return profileexporter.PushReports(pushCtx, p.exporter, reports, start, duration, p.config.SampleFreq)
+}
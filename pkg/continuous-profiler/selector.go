@@ -0,0 +1,53 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package continuousprofiler implements an always-on, low overhead CPU
+// profiler: containers of the node are profiled in turns, a few of them at a
+// time and at a low frequency, and the resulting profiles are pushed to a
+// continuous profiling backend.
+package continuousprofiler
+
+import (
+	"sort"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+)
+
+// selectContainers returns up to n containers to profile in the next round,
+// going round-robin (by container ID) over containers and starting after the
+// container with ID cursor. It also returns the cursor for the next round.
+func selectContainers(containers []*containercollection.Container, cursor string, n int) ([]*containercollection.Container, string) {
+	if len(containers) == 0 || n <= 0 {
+		return nil, cursor
+	}
+
+	sorted := make([]*containercollection.Container, len(containers))
+	copy(sorted, containers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Runtime.ContainerID < sorted[j].Runtime.ContainerID
+	})
+
+	first := sort.Search(len(sorted), func(i int) bool {
+		return sorted[i].Runtime.ContainerID > cursor
+	})
+
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	selected := make([]*containercollection.Container, 0, n)
+	for i := 0; i < n; i++ {
+		selected = append(selected, sorted[(first+i)%len(sorted)])
+	}
+	return selected, selected[len(selected)-1].Runtime.ContainerID
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package continuousprofiler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+)
+
+func TestSelectContainers(t *testing.T) {
+	var containers []*containercollection.Container
+	for _, id := range []string{"d", "b", "a", "c"} {
+		c := &containercollection.Container{}
+		c.Runtime.ContainerID = id
+		containers = append(containers, c)
+	}
+
+	ids := func(containers []*containercollection.Container) (ret []string) {
+		for _, c := range containers {
+			ret = append(ret, c.Runtime.ContainerID)
+		}
+		return
+	}
+
+	selected, cursor := selectContainers(containers, "", 3)
+	assert.Equal(t, []string{"a", "b", "c"}, ids(selected))
+	assert.Equal(t, "c", cursor)
+
+	selected, cursor = selectContainers(containers, cursor, 3)
+	assert.Equal(t, []string{"d", "a", "b"}, ids(selected))
+	assert.Equal(t, "b", cursor)
+
+	// The cursor container is gone
+	selected, cursor = selectContainers(containers[1:], "bb", 1)
+	assert.Equal(t, []string{"c"}, ids(selected))
+	assert.Equal(t, "c", cursor)
+
+	// Never select the same container twice in a round
+	selected, _ = selectContainers(containers[:2], "", 5)
+	assert.Equal(t, []string{"b", "d"}, ids(selected))
+
+	selected, cursor = selectContainers(nil, "x", 1)
+	assert.Empty(t, selected)
+	assert.Equal(t, "x", cursor)
+}
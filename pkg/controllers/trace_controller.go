@@ -20,18 +20,24 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/bpfstats"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/selftracing"
 )
 
 const (
@@ -41,6 +47,23 @@ const (
 
 	GadgetOperation = "gadget.kinvolk.io/operation"
 	GadgetFinalizer = "gadget.kinvolk.io/finalizer"
+
+	// GadgetOperationID is an optional annotation set together with
+	// GadgetOperation. Its value is copied to Status.OperationID once the
+	// operation was applied.
+	GadgetOperationID = "gadget.kinvolk.io/operation-id"
+
+	// GadgetRetryAt is set by the controller together with GadgetOperation
+	// when an operation failed with a transient error. It contains the time
+	// (RFC3339) at which the operation is retried.
+	GadgetRetryAt = "gadget.kinvolk.io/retry-at"
+
+	// maxOperationRetries is the number of times an operation failing with a
+	// transient error is retried before reporting the failure
+	maxOperationRetries = 3
+	// operationRetryDelay is the delay before the first retry; it's doubled
+	// for each following retry
+	operationRetryDelay = 500 * time.Millisecond
 )
 
 // TraceReconciler reconciles a Trace object
@@ -52,6 +75,118 @@ type TraceReconciler struct {
 	// TraceFactories contains the trace factories keyed by the gadget name
 	TraceFactories map[string]gadgets.TraceFactory
 	TracerManager  *gadgettracermanager.GadgetTracerManager
+
+	// Now returns the current time; it can be overridden for testing
+	Now func() time.Time
+
+	// BPFObjects returns the eBPF objects of the process; it can be
+	// overridden for testing
+	BPFObjects func() ([]bpfstats.Object, error)
+}
+
+func (r *TraceReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// timeoutRemaining returns how long until the started trace must be stopped
+// according to Spec.Timeout, and false if it doesn't have to be stopped.
+func (r *TraceReconciler) timeoutRemaining(trace *gadgetv1alpha1.Trace, factory gadgets.TraceFactory) (time.Duration, bool) {
+	if trace.Spec.Timeout == nil || trace.Spec.Timeout.Duration <= 0 {
+		return 0, false
+	}
+	if trace.Status.State != gadgetv1alpha1.TraceStateStarted || trace.Status.StartedAt == nil {
+		return 0, false
+	}
+	if _, canStop := factory.Operations()[gadgetv1alpha1.OperationStop]; !canStop {
+		return 0, false
+	}
+
+	return trace.Status.StartedAt.Add(trace.Spec.Timeout.Duration).Sub(r.now()), true
+}
+
+// checkDeadline applies the stop operation to the trace if its timeout
+// expired, otherwise it requeues the trace for when it expires.
+func (r *TraceReconciler) checkDeadline(ctx context.Context, trace *gadgetv1alpha1.Trace,
+	factory gadgets.TraceFactory, nsName types.NamespacedName,
+) (ctrl.Result, error) {
+	remaining, ok := r.timeoutRemaining(trace, factory)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	if remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.Infof("Trace %q timed out", nsName)
+	if err := r.requestOperation(ctx, nsName, gadgetv1alpha1.OperationStop); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// ttlRemaining returns how long until the trace expires according to
+// Spec.TTLSecondsAfterCreation, and false if it doesn't have a TTL.
+func (r *TraceReconciler) ttlRemaining(trace *gadgetv1alpha1.Trace) (time.Duration, bool) {
+	if trace.Spec.TTLSecondsAfterCreation == nil {
+		return 0, false
+	}
+
+	ttl := time.Duration(*trace.Spec.TTLSecondsAfterCreation) * time.Second
+	return trace.CreationTimestamp.Add(ttl).Sub(r.now()), true
+}
+
+// requestOperation sets the operation annotation on the trace, so it's
+// applied in the next reconciliation.
+func (r *TraceReconciler) requestOperation(ctx context.Context, nsName types.NamespacedName, op gadgetv1alpha1.Operation) error {
+	trace := &gadgetv1alpha1.Trace{}
+	if err := r.Client.Get(ctx, nsName, trace); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		log.Errorf("Failed to get Trace %q: %s", nsName, err)
+		return err
+	}
+
+	patch := client.MergeFrom(trace.DeepCopy())
+	annotations := trace.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[GadgetOperation] = string(op)
+	trace.SetAnnotations(annotations)
+	if err := r.Client.Patch(ctx, trace, patch); err != nil {
+		log.Errorf("Failed to request operation %q on trace %q: %s", op, nsName, err)
+		return err
+	}
+	return nil
+}
+
+// setupAutoStop arranges for the stop operation to be applied to a trace that
+// was just started once its timeout expires or it produced MaxEvents events.
+func (r *TraceReconciler) setupAutoStop(trace *gadgetv1alpha1.Trace, factory gadgets.TraceFactory,
+	nsName types.NamespacedName,
+) ctrl.Result {
+	if trace.Spec.MaxEvents > 0 && trace.Spec.OutputMode == gadgetv1alpha1.TraceOutputModeStream && r.TracerManager != nil {
+		err := r.TracerManager.SetStreamLimit(
+			gadgets.TraceNameFromNamespacedName(nsName),
+			uint64(trace.Spec.MaxEvents),
+			func() {
+				log.Infof("Trace %q reached %d events", nsName, trace.Spec.MaxEvents)
+				r.requestOperation(context.Background(), nsName, gadgetv1alpha1.OperationStop)
+			},
+		)
+		if err != nil {
+			log.Warnf("Failed to limit the number of events of trace %q: %s", nsName, err)
+		}
+	}
+
+	if remaining, ok := r.timeoutRemaining(trace, factory); ok {
+		return ctrl.Result{RequeueAfter: max(remaining, 0)}
+	}
+	return ctrl.Result{}
 }
 
 func updateTraceStatus(ctx context.Context, cli client.Client,
@@ -81,6 +216,7 @@ func setTraceOpError(ctx context.Context, cli client.Client,
 ) {
 	patch := client.MergeFrom(trace.DeepCopy())
 	trace.Status.OperationError = strError
+	trace.Status.OperationErrorCode = ""
 	updateTraceStatus(ctx, cli, traceNsName, trace, patch)
 }
 
@@ -97,9 +233,9 @@ func setTraceOpError(ctx context.Context, cli client.Client,
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.8.3/pkg/reconcile
-func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	trace := &gadgetv1alpha1.Trace{}
-	err := r.Client.Get(ctx, req.NamespacedName, trace)
+	err = r.Client.Get(ctx, req.NamespacedName, trace)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			log.Infof("Trace %q has been deleted", req.NamespacedName.String())
@@ -128,6 +264,7 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 			if ok {
 				factory.Delete(req.NamespacedName.String())
 			}
+			deleteTraceBPFMemory(trace)
 
 			if r.TracerManager != nil {
 				err = r.TracerManager.RemoveTracer(
@@ -151,6 +288,26 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 
+	// Delete the trace once it expired. It's stopped by the finalizer above
+	// if it's still running.
+	if remaining, ok := r.ttlRemaining(trace); ok {
+		if remaining <= 0 {
+			log.Infof("Trace %q expired, deleting it", req.NamespacedName)
+			if err := r.Client.Delete(ctx, trace); err != nil && !k8serrors.IsNotFound(err) {
+				log.Errorf("Failed to delete expired trace %q: %s", req.NamespacedName, err)
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+
+		// Make sure we are called again when it expires
+		defer func() {
+			if err == nil && (result.RequeueAfter == 0 || result.RequeueAfter > remaining) {
+				result.RequeueAfter = remaining
+			}
+		}()
+	}
+
 	// Check trace specs before adding the finalizer and registering the trace.
 	// If there is an error updating the Trace, return anyway nil to prevent
 	// the Reconcile() from being called again and again by the controller.
@@ -169,13 +326,21 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		return ctrl.Result{}, nil
 	}
 	outputModes := factory.OutputModesSupported()
-	if _, ok := outputModes[trace.Spec.OutputMode]; !ok {
+	toFile := writesStreamToFile(trace, outputModes)
+	if _, ok := outputModes[trace.Spec.OutputMode]; !ok && !toFile {
 		setTraceOpError(ctx, r.Client, req.NamespacedName.String(),
 			trace, fmt.Sprintf("Unsupported OutputMode %q for gadget %q",
 				trace.Spec.OutputMode, trace.Spec.Gadget))
 
 		return ctrl.Result{}, nil
 	}
+	if err := validateTraceSinks(trace, outputModes); err != nil {
+		setTraceOpError(ctx, r.Client, req.NamespacedName.String(),
+			trace, fmt.Sprintf("Invalid sinks for gadget %q: %s",
+				trace.Spec.Gadget, err))
+
+		return ctrl.Result{}, nil
+	}
 
 	// The Trace is not being deleted and specs are valid, we can register our finalizer
 	beforeFinalizer := trace.DeepCopy()
@@ -187,32 +352,56 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 
 	// Register tracer
 	if r.TracerManager != nil {
+		tracerID := gadgets.TraceNameFromNamespacedName(req.NamespacedName)
 		err = r.TracerManager.AddTracer(
-			gadgets.TraceNameFromNamespacedName(req.NamespacedName),
+			tracerID,
 			*gadgets.ContainerSelectorFromContainerFilter(trace.Spec.Filter),
 		)
 		if err != nil && !errors.Is(err, os.ErrExist) {
 			log.Errorf("Failed to add tracer BPF map: %s", err)
 			return ctrl.Result{}, err
 		}
+
+		// Sinks are only added once, when the tracer is registered
+		if err == nil && (len(trace.Spec.Sinks) > 0 || toFile) {
+			if err := r.addTraceSinks(trace, tracerID, toFile); err != nil {
+				setTraceOpError(ctx, r.Client, req.NamespacedName.String(),
+					trace, fmt.Sprintf("Failed to create sinks: %s", err))
+
+				return ctrl.Result{}, nil
+			}
+		}
 	}
 
 	// Lookup annotations
 	if trace.ObjectMeta.Annotations == nil {
 		log.Info("No annotations. Nothing to do.")
-		return ctrl.Result{}, nil
+		return r.checkDeadline(ctx, trace, factory, req.NamespacedName)
 	}
 
 	// For now, only support control via the GADGET_OPERATION
 	var op string
 	if op, ok = trace.ObjectMeta.Annotations[GadgetOperation]; !ok {
 		log.Info("No operation annotation. Nothing to do.")
-		return ctrl.Result{}, nil
+		return r.checkDeadline(ctx, trace, factory, req.NamespacedName)
+	}
+
+	opID := trace.ObjectMeta.Annotations[GadgetOperationID]
+
+	// Wait until a retry is due
+	retryAtStr, isRetry := trace.ObjectMeta.Annotations[GadgetRetryAt]
+	if isRetry {
+		retryAt, err := time.Parse(time.RFC3339Nano, retryAtStr)
+		if err == nil {
+			if remaining := retryAt.Sub(r.now()); remaining > 0 {
+				return ctrl.Result{RequeueAfter: remaining}, nil
+			}
+		}
 	}
 
 	params := make(map[string]string)
 	for k, v := range trace.ObjectMeta.Annotations {
-		if !strings.HasPrefix(k, GadgetOperation+"-") {
+		if k == GadgetOperationID || !strings.HasPrefix(k, GadgetOperation+"-") {
 			continue
 		}
 		params[strings.TrimPrefix(k, GadgetOperation+"-")] = v
@@ -225,6 +414,8 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	withAnnotation := trace.DeepCopy()
 	annotations := trace.GetAnnotations()
 	delete(annotations, GadgetOperation)
+	delete(annotations, GadgetOperationID)
+	delete(annotations, GadgetRetryAt)
 	for k := range params {
 		delete(annotations, GadgetOperation+"-"+k)
 	}
@@ -238,19 +429,90 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	// Check operation is supported for this specific gadget
 	gadgetOperation, ok := factory.Operations()[gadgetv1alpha1.Operation(op)]
 	if !ok {
-		setTraceOpError(ctx, r.Client, req.NamespacedName.String(),
-			trace, fmt.Sprintf("Unsupported operation %q for gadget %q",
-				op, trace.Spec.Gadget))
+		patch := client.MergeFrom(trace.DeepCopy())
+		trace.Status.OperationError = fmt.Sprintf("Unsupported operation %q for gadget %q",
+			op, trace.Spec.Gadget)
+		trace.Status.OperationErrorCode = ""
+		if opID != "" {
+			trace.Status.OperationID = opID
+		}
+		trace.Status.ObservedGeneration = trace.Generation
+		updateTraceStatus(ctx, r.Client, req.NamespacedName.String(), trace, patch)
 
 		return ctrl.Result{}, nil
 	}
 
+	// Check the parameters if the gadget describes them
+	if factoryWithParams, ok := factory.(gadgets.TraceFactoryWithParams); ok {
+		err := gadgets.ValidateParameters(factoryWithParams.ParamDescs(), trace.Spec.Parameters)
+		if err != nil {
+			patch := client.MergeFrom(trace.DeepCopy())
+			trace.Status.OperationError = fmt.Sprintf("Invalid parameters for gadget %q: %s",
+				trace.Spec.Gadget, err)
+			trace.Status.OperationErrorCode = ""
+			trace.Status.VerifierLog = ""
+			trace.Status.OperationErrorTransient = false
+			if opID != "" {
+				trace.Status.OperationID = opID
+			}
+			trace.Status.ObservedGeneration = trace.Generation
+			updateTraceStatus(ctx, r.Client, req.NamespacedName.String(), trace, patch)
+
+			return ctrl.Result{}, nil
+		}
+	}
+
 	// Call gadget operation
 	traceBeforeOperation := trace.DeepCopy()
 	trace.Status.OperationError = ""
+	trace.Status.OperationErrorCode = ""
+	trace.Status.VerifierLog = ""
+	trace.Status.OperationErrorTransient = false
 	trace.Status.OperationWarning = ""
+	if !isRetry {
+		trace.Status.OperationRetries = 0
+	}
+	// Operations requested without an ID (e.g. by the controller itself)
+	// keep the ID of the last operation requested by a client
+	if opID != "" {
+		trace.Status.OperationID = opID
+	}
+	trace.Status.ObservedGeneration = trace.Generation
 	patch := client.MergeFrom(traceBeforeOperation)
+
+	var objectsBefore []bpfstats.Object
+	var objectsBeforeErr error
+	if gadgetv1alpha1.Operation(op) == gadgetv1alpha1.OperationStart {
+		objectsBefore, objectsBeforeErr = r.bpfObjects()
+	}
+	_, span := selftracing.StartSpan(ctx, "controllers.TraceOperation",
+		attribute.String("trace", req.NamespacedName.String()),
+		attribute.String("gadget", trace.Spec.Gadget),
+		attribute.String("operation", op),
+		attribute.Bool("retry", isRetry),
+	)
 	gadgetOperation.Operation(req.NamespacedName.String(), trace)
+	var opErr error
+	if trace.Status.OperationError != "" {
+		opErr = errors.New(trace.Status.OperationError)
+	}
+	selftracing.End(span, opErr)
+	r.updateTraceBPFMemory(trace, traceBeforeOperation.Status.State, objectsBefore, objectsBeforeErr)
+	if gadgetv1alpha1.Operation(op) == gadgetv1alpha1.OperationStart &&
+		trace.Status.State == gadgetv1alpha1.TraceStateStarted && trace.Status.OperationError == "" {
+		trace.Status.StartedAt = &metav1.Time{Time: r.now()}
+	}
+
+	retry := false
+	if trace.Status.OperationErrorTransient {
+		if trace.Status.OperationRetries < maxOperationRetries {
+			retry = true
+			trace.Status.OperationRetries++
+		} else {
+			trace.Status.OperationErrorTransient = false
+			trace.Status.OperationError += fmt.Sprintf(" (gave up after %d retries)", trace.Status.OperationRetries)
+		}
+	}
 
 	if apiequality.Semantic.DeepEqual(traceBeforeOperation.Status, trace.Status) {
 		log.Info("Gadget completed operation without changing the trace status")
@@ -259,6 +521,38 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		updateTraceStatus(ctx, r.Client, req.NamespacedName.String(), trace, patch)
 	}
 
+	if retry {
+		delay := operationRetryDelay << (trace.Status.OperationRetries - 1)
+		log.Infof("Retrying operation %q on %s in %s: %s", op, req.NamespacedName, delay, trace.Status.OperationError)
+
+		withoutAnnotation := trace.DeepCopy()
+		annotations := trace.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[GadgetOperation] = op
+		if opID != "" {
+			annotations[GadgetOperationID] = opID
+		}
+		for k, v := range params {
+			annotations[GadgetOperation+"-"+k] = v
+		}
+		annotations[GadgetRetryAt] = r.now().Add(delay).UTC().Format(time.RFC3339Nano)
+		trace.SetAnnotations(annotations)
+		if err := r.Client.Patch(ctx, trace, client.MergeFrom(withoutAnnotation)); err != nil {
+			log.Errorf("Failed to schedule retry of operation %q on %s: %s", op, req.NamespacedName, err)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: delay}, nil
+	}
+
+	if gadgetv1alpha1.Operation(op) == gadgetv1alpha1.OperationStart {
+		_, canStop := factory.Operations()[gadgetv1alpha1.OperationStop]
+		if canStop && trace.Status.State == gadgetv1alpha1.TraceStateStarted && trace.Status.OperationError == "" {
+			return r.setupAutoStop(trace, factory, req.NamespacedName), nil
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
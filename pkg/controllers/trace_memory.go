@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/bpfstats"
+)
+
+// traceBPFMemory is the memory used by the eBPF objects created when starting
+// each trace
+var traceBPFMemory = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "gadget_trace_bpf_memory_bytes",
+		Help: "Memory used by the eBPF programs and maps created when the trace was started",
+	},
+	[]string{"namespace", "trace", "gadget"},
+)
+
+// bpfMemory is the memory used by all the eBPF objects of the gadget pod,
+// including the ones of gadgets not run through traces
+var bpfMemory = prometheus.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Name: "gadget_bpf_memory_bytes",
+		Help: "Memory used by all the eBPF programs and maps of the gadget pod",
+	},
+	func() float64 {
+		objects, err := bpfstats.ProcessObjects()
+		if err != nil {
+			log.Warnf("Failed to get eBPF objects: %s", err)
+			return 0
+		}
+		return float64(bpfstats.Memlock(objects))
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(traceBPFMemory, bpfMemory)
+}
+
+func traceBPFMemoryLabels(trace *gadgetv1alpha1.Trace) prometheus.Labels {
+	return prometheus.Labels{
+		"namespace": trace.Namespace,
+		"trace":     trace.Name,
+		"gadget":    trace.Spec.Gadget,
+	}
+}
+
+func (r *TraceReconciler) bpfObjects() ([]bpfstats.Object, error) {
+	if r.BPFObjects != nil {
+		return r.BPFObjects()
+	}
+	return bpfstats.ProcessObjects()
+}
+
+// updateTraceBPFMemory sets the memory used by the eBPF objects of the trace
+// after an operation. before are the eBPF objects of the process before the
+// operation and beforeErr the error getting them.
+func (r *TraceReconciler) updateTraceBPFMemory(
+	trace *gadgetv1alpha1.Trace,
+	stateBefore gadgetv1alpha1.TraceState,
+	before []bpfstats.Object,
+	beforeErr error,
+) {
+	switch {
+	case stateBefore != gadgetv1alpha1.TraceStateStarted && trace.Status.State == gadgetv1alpha1.TraceStateStarted:
+		if beforeErr != nil {
+			log.Warnf("Failed to get eBPF objects before starting trace %s/%s: %s", trace.Namespace, trace.Name, beforeErr)
+			return
+		}
+		after, err := r.bpfObjects()
+		if err != nil {
+			log.Warnf("Failed to get eBPF objects after starting trace %s/%s: %s", trace.Namespace, trace.Name, err)
+			return
+		}
+		// Objects created concurrently by other gadgets are counted too,
+		// so this is an upper bound
+		memory := bpfstats.Memlock(bpfstats.NewObjects(before, after))
+		trace.Status.BPFMemory = int64(memory)
+		traceBPFMemory.With(traceBPFMemoryLabels(trace)).Set(float64(memory))
+	case trace.Status.State == gadgetv1alpha1.TraceStateStopped || trace.Status.State == gadgetv1alpha1.TraceStateCompleted:
+		trace.Status.BPFMemory = 0
+		deleteTraceBPFMemory(trace)
+	}
+}
+
+func deleteTraceBPFMemory(trace *gadgetv1alpha1.Trace) {
+	traceBPFMemory.Delete(traceBPFMemoryLabels(trace))
+}
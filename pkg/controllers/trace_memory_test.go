@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/bpfstats"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
+)
+
+func TestTraceBPFMemory(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "memory",
+			Namespace: "gadget",
+			Annotations: map[string]string{
+				GadgetOperation: string(gadgetv1alpha1.OperationStart),
+			},
+		},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:       "node1",
+			Gadget:     "exec",
+			RunMode:    gadgetv1alpha1.RunModeManual,
+			OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(trace).
+		WithStatusSubresource(trace).
+		Build()
+
+	// The map of the tracer already exists, the start operation creates a
+	// program and a map
+	objects := []bpfstats.Object{
+		{Type: bpfstats.ObjectTypeMap, ID: 1, Memlock: 4096},
+	}
+	r := &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": &startStopFactory{}},
+		BPFObjects: func() ([]bpfstats.Object, error) {
+			res := objects
+			objects = append(objects,
+				bpfstats.Object{Type: bpfstats.ObjectTypeProg, ID: 1, Memlock: 8192},
+				bpfstats.Object{Type: bpfstats.ObjectTypeMap, ID: 2, Memlock: 65536},
+			)
+			return res, nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "memory"}}
+	get := func() *gadgetv1alpha1.Trace {
+		updated := &gadgetv1alpha1.Trace{}
+		require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+		return updated
+	}
+	gauge := traceBPFMemory.WithLabelValues("gadget", "memory", "exec")
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8192+65536), get().Status.BPFMemory)
+	assert.Equal(t, float64(8192+65536), testutil.ToFloat64(gauge))
+
+	updated := get()
+	if updated.Annotations == nil {
+		updated.Annotations = make(map[string]string)
+	}
+	updated.Annotations[GadgetOperation] = string(gadgetv1alpha1.OperationStop)
+	require.NoError(t, cli.Update(ctx, updated))
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, get().Status.BPFMemory)
+	assert.Zero(t, testutil.CollectAndCount(traceBPFMemory))
+}
@@ -0,0 +1,620 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+type startStopFactory struct {
+	gadgets.BaseFactory
+
+	// startErrors are returned by the next start operations
+	startErrors []error
+}
+
+func (f *startStopFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
+	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
+		gadgetv1alpha1.TraceOutputModeStream: {},
+	}
+}
+
+func (f *startStopFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOperation {
+	return map[gadgetv1alpha1.Operation]gadgets.TraceOperation{
+		gadgetv1alpha1.OperationStart: {
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				if len(f.startErrors) > 0 {
+					gadgets.SetOperationError(trace, "failed to create tracer", f.startErrors[0])
+					f.startErrors = f.startErrors[1:]
+					return
+				}
+				trace.Status.State = gadgetv1alpha1.TraceStateStarted
+			},
+		},
+		gadgetv1alpha1.OperationStop: {
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				trace.Status.State = gadgetv1alpha1.TraceStateStopped
+			},
+		},
+	}
+}
+
+func TestTraceTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "exec",
+			Namespace: "gadget",
+			Annotations: map[string]string{
+				GadgetOperation: string(gadgetv1alpha1.OperationStart),
+			},
+		},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:       "node1",
+			Gadget:     "exec",
+			RunMode:    gadgetv1alpha1.RunModeManual,
+			OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+			Timeout:    &metav1.Duration{Duration: time.Minute},
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(trace).
+		WithStatusSubresource(trace).
+		Build()
+
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	r := &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": &startStopFactory{}},
+		Now:            func() time.Time { return now },
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "exec"}}
+	get := func() *gadgetv1alpha1.Trace {
+		updated := &gadgetv1alpha1.Trace{}
+		require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+		return updated
+	}
+
+	// Starting the trace requeues it for when the timeout expires
+	res, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, res.RequeueAfter)
+	assert.Equal(t, gadgetv1alpha1.TraceStateStarted, get().Status.State)
+	require.NotNil(t, get().Status.StartedAt)
+	assert.True(t, now.Equal(get().Status.StartedAt.Time))
+
+	// Nothing happens before the timeout
+	now = now.Add(20 * time.Second)
+	res, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 40*time.Second, res.RequeueAfter)
+	assert.NotContains(t, get().Annotations, GadgetOperation)
+
+	// The timeout is still applied by a new controller, e.g. after the
+	// gadget pod restarted, as it's computed from the trace status
+	r = &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": &startStopFactory{}},
+		Now:            func() time.Time { return now },
+	}
+	now = now.Add(10 * time.Second)
+	res, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, res.RequeueAfter)
+	assert.NotContains(t, get().Annotations, GadgetOperation)
+
+	// The stop operation is requested once the timeout expired
+	now = now.Add(30 * time.Second)
+	res, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, res.RequeueAfter)
+	assert.Equal(t, string(gadgetv1alpha1.OperationStop), get().Annotations[GadgetOperation])
+
+	// And applied in the next reconciliation
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, gadgetv1alpha1.TraceStateStopped, get().Status.State)
+}
+
+func TestTraceTTL(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	ttl := int64(60)
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "exec",
+			Namespace:         "gadget",
+			CreationTimestamp: metav1.NewTime(now.Add(-10 * time.Second)),
+			Annotations: map[string]string{
+				GadgetOperation: string(gadgetv1alpha1.OperationStart),
+			},
+		},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:                    "node1",
+			Gadget:                  "exec",
+			RunMode:                 gadgetv1alpha1.RunModeManual,
+			OutputMode:              gadgetv1alpha1.TraceOutputModeStream,
+			Timeout:                 &metav1.Duration{Duration: time.Hour},
+			TTLSecondsAfterCreation: &ttl,
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(trace).
+		WithStatusSubresource(trace).
+		Build()
+
+	factory := &startStopFactory{}
+	r := &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": factory},
+		Now:            func() time.Time { return now },
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "exec"}}
+
+	// The trace is requeued for when it expires, before its timeout
+	res, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 50*time.Second, res.RequeueAfter)
+
+	// Once expired, it's deleted and the finalizer stops it
+	now = now.Add(50 * time.Second)
+	res, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, res.RequeueAfter)
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	err = cli.Get(ctx, req.NamespacedName, &gadgetv1alpha1.Trace{})
+	assert.True(t, k8serrors.IsNotFound(err), "trace should be deleted: %v", err)
+}
+
+func TestTraceOperationID(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "exec",
+			Namespace:  "gadget",
+			Generation: 2,
+			Annotations: map[string]string{
+				GadgetOperation:   string(gadgetv1alpha1.OperationStart),
+				GadgetOperationID: "first",
+			},
+		},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:       "node1",
+			Gadget:     "exec",
+			RunMode:    gadgetv1alpha1.RunModeManual,
+			OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(trace).
+		WithStatusSubresource(trace).
+		Build()
+
+	r := &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": &startStopFactory{}},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "exec"}}
+	get := func() *gadgetv1alpha1.Trace {
+		updated := &gadgetv1alpha1.Trace{}
+		require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+		return updated
+	}
+	request := func(op gadgetv1alpha1.Operation, id string) {
+		updated := get()
+		if updated.Annotations == nil {
+			updated.Annotations = make(map[string]string)
+		}
+		updated.Annotations[GadgetOperation] = string(op)
+		if id != "" {
+			updated.Annotations[GadgetOperationID] = id
+		}
+		require.NoError(t, cli.Update(ctx, updated))
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	updated := get()
+	assert.Equal(t, "first", updated.Status.OperationID)
+	assert.Equal(t, updated.Generation, updated.Status.ObservedGeneration)
+	assert.NotContains(t, updated.Annotations, GadgetOperationID)
+
+	// The ID isn't passed to the gadget as a parameter and errors are
+	// acknowledged too
+	request("unknown", "second")
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	updated = get()
+	assert.Equal(t, "second", updated.Status.OperationID)
+	assert.Contains(t, updated.Status.OperationError, "Unsupported operation")
+
+	// Operations without ID keep the last one
+	request(gadgetv1alpha1.OperationStop, "")
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	updated = get()
+	assert.Equal(t, "second", updated.Status.OperationID)
+	assert.Equal(t, gadgetv1alpha1.TraceStateStopped, updated.Status.State)
+	assert.Empty(t, updated.Status.OperationError)
+}
+
+func TestTraceTransientError(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	newTrace := func(name string) *gadgetv1alpha1.Trace {
+		return &gadgetv1alpha1.Trace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "gadget",
+				Annotations: map[string]string{
+					GadgetOperation:          string(gadgetv1alpha1.OperationStart),
+					GadgetOperation + "-foo": "bar",
+				},
+			},
+			Spec: gadgetv1alpha1.TraceSpec{
+				Node:       "node1",
+				Gadget:     name,
+				RunMode:    gadgetv1alpha1.RunModeManual,
+				OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+			},
+		}
+	}
+	transient, permanent := newTrace("transient"), newTrace("permanent")
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(transient, permanent).
+		WithStatusSubresource(transient, permanent).
+		Build()
+
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	busy := fmt.Errorf("loading program: %w", unix.EBUSY)
+	r := &TraceReconciler{
+		Client: cli,
+		Scheme: scheme,
+		Node:   "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{
+			"transient": &startStopFactory{startErrors: []error{busy, busy}},
+			"permanent": &startStopFactory{startErrors: []error{unix.EPERM}},
+		},
+		Now: func() time.Time { return now },
+	}
+	get := func(name string) *gadgetv1alpha1.Trace {
+		updated := &gadgetv1alpha1.Trace{}
+		require.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "gadget", Name: name}, updated))
+		return updated
+	}
+	reconcile := func(name string) ctrl.Result {
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: name}})
+		require.NoError(t, err)
+		return res
+	}
+
+	// Transient errors are retried with a backoff
+	res := reconcile("transient")
+	assert.Equal(t, operationRetryDelay, res.RequeueAfter)
+	trace := get("transient")
+	assert.True(t, trace.Status.OperationErrorTransient)
+	assert.Equal(t, int32(1), trace.Status.OperationRetries)
+	assert.Equal(t, string(gadgetv1alpha1.OperationStart), trace.Annotations[GadgetOperation])
+	assert.Equal(t, "bar", trace.Annotations[GadgetOperation+"-foo"])
+
+	// Nothing happens before the retry is due
+	res = reconcile("transient")
+	assert.Equal(t, operationRetryDelay, res.RequeueAfter)
+	assert.Equal(t, int32(1), get("transient").Status.OperationRetries)
+
+	now = now.Add(operationRetryDelay)
+	res = reconcile("transient")
+	assert.Equal(t, 2*operationRetryDelay, res.RequeueAfter)
+	assert.Equal(t, int32(2), get("transient").Status.OperationRetries)
+
+	now = now.Add(2 * operationRetryDelay)
+	reconcile("transient")
+	trace = get("transient")
+	assert.Equal(t, gadgetv1alpha1.TraceStateStarted, trace.Status.State)
+	assert.Empty(t, trace.Status.OperationError)
+	assert.False(t, trace.Status.OperationErrorTransient)
+	assert.NotContains(t, trace.Annotations, GadgetRetryAt)
+
+	// Permanent errors are reported right away
+	res = reconcile("permanent")
+	assert.Zero(t, res.RequeueAfter)
+	trace = get("permanent")
+	assert.Equal(t, "failed to create tracer: operation not permitted", trace.Status.OperationError)
+	assert.False(t, trace.Status.OperationErrorTransient)
+	assert.NotContains(t, trace.Annotations, GadgetOperation)
+}
+
+func TestTraceTransientErrorGiveUp(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "exec",
+			Namespace:   "gadget",
+			Annotations: map[string]string{GadgetOperation: string(gadgetv1alpha1.OperationStart)},
+		},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:       "node1",
+			Gadget:     "exec",
+			RunMode:    gadgetv1alpha1.RunModeManual,
+			OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(trace).
+		WithStatusSubresource(trace).
+		Build()
+
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	errs := make([]error, maxOperationRetries+1)
+	for i := range errs {
+		errs[i] = unix.EAGAIN
+	}
+	r := &TraceReconciler{
+		Client:         cli,
+		Scheme:         scheme,
+		Node:           "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{"exec": &startStopFactory{startErrors: errs}},
+		Now:            func() time.Time { return now },
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "exec"}}
+
+	for i := 0; i <= maxOperationRetries; i++ {
+		res, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+		now = now.Add(res.RequeueAfter)
+	}
+
+	updated := &gadgetv1alpha1.Trace{}
+	require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+	assert.False(t, updated.Status.OperationErrorTransient)
+	assert.Equal(t, int32(maxOperationRetries), updated.Status.OperationRetries)
+	assert.Equal(t, "failed to create tracer: resource temporarily unavailable (gave up after 3 retries)", updated.Status.OperationError)
+	assert.NotContains(t, updated.Annotations, GadgetOperation)
+}
+
+type startStopFactoryWithParams struct {
+	startStopFactory
+}
+
+func (f *startStopFactoryWithParams) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:      "minlatency",
+			TypeHint: params.TypeUint32,
+		},
+	}
+}
+
+func TestTraceParameters(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	tests := []struct {
+		name       string
+		parameters map[string]string
+		expected   string
+	}{
+		{
+			name:       "valid",
+			parameters: map[string]string{"minlatency": "10"},
+		},
+		{
+			name:       "unknown",
+			parameters: map[string]string{"min-latency": "10"},
+			expected:   `Invalid parameters for gadget "fsslower": unknown parameter "min-latency", valid parameters are: minlatency`,
+		},
+		{
+			name:       "invalid",
+			parameters: map[string]string{"minlatency": "-1"},
+			expected:   `Invalid parameters for gadget "fsslower": invalid value "-1" as "minlatency"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trace := &gadgetv1alpha1.Trace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "fsslower",
+					Namespace: "gadget",
+					Annotations: map[string]string{
+						GadgetOperation: string(gadgetv1alpha1.OperationStart),
+					},
+				},
+				Spec: gadgetv1alpha1.TraceSpec{
+					Node:       "node1",
+					Gadget:     "fsslower",
+					RunMode:    gadgetv1alpha1.RunModeManual,
+					OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+					Parameters: test.parameters,
+				},
+			}
+			cli := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(trace).
+				WithStatusSubresource(trace).
+				Build()
+
+			r := &TraceReconciler{
+				Client:         cli,
+				Scheme:         scheme,
+				Node:           "node1",
+				TraceFactories: map[string]gadgets.TraceFactory{"fsslower": &startStopFactoryWithParams{}},
+			}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "fsslower"}}
+			_, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+
+			updated := &gadgetv1alpha1.Trace{}
+			require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+			if test.expected == "" {
+				assert.Empty(t, updated.Status.OperationError)
+				assert.Equal(t, gadgetv1alpha1.TraceStateStarted, updated.Status.State)
+				return
+			}
+			assert.Contains(t, updated.Status.OperationError, test.expected)
+			assert.Empty(t, updated.Status.State)
+		})
+	}
+}
+
+// clearFactory is a gadget aggregating stats that can be cleared
+type clearFactory struct {
+	startStopFactory
+
+	// stats is what is aggregated by the gadget since it was started or
+	// cleared
+	stats int
+}
+
+func (f *clearFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOperation {
+	operations := f.startStopFactory.Operations()
+	operations[gadgetv1alpha1.OperationClear] = gadgets.TraceOperation{
+		Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+			f.stats = 0
+		},
+	}
+	return operations
+}
+
+func TestTraceClear(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	newTrace := func(name string) *gadgetv1alpha1.Trace {
+		return &gadgetv1alpha1.Trace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "gadget",
+				Annotations: map[string]string{
+					GadgetOperation: string(gadgetv1alpha1.OperationStart),
+				},
+			},
+			Spec: gadgetv1alpha1.TraceSpec{
+				Node:       "node1",
+				Gadget:     name,
+				RunMode:    gadgetv1alpha1.RunModeManual,
+				OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+			},
+		}
+	}
+	filetop, exec := newTrace("filetop"), newTrace("exec")
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(filetop, exec).
+		WithStatusSubresource(filetop, exec).
+		Build()
+
+	factory := &clearFactory{}
+	r := &TraceReconciler{
+		Client: cli,
+		Scheme: scheme,
+		Node:   "node1",
+		TraceFactories: map[string]gadgets.TraceFactory{
+			"filetop": factory,
+			"exec":    &startStopFactory{},
+		},
+	}
+	get := func(name string) *gadgetv1alpha1.Trace {
+		updated := &gadgetv1alpha1.Trace{}
+		require.NoError(t, cli.Get(ctx, types.NamespacedName{Namespace: "gadget", Name: name}, updated))
+		return updated
+	}
+	reconcile := func(name string) {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: name}})
+		require.NoError(t, err)
+	}
+	clearTrace := func(name string) {
+		trace := get(name)
+		trace.Annotations = map[string]string{GadgetOperation: string(gadgetv1alpha1.OperationClear)}
+		require.NoError(t, cli.Update(ctx, trace))
+		reconcile(name)
+	}
+
+	reconcile("filetop")
+	reconcile("exec")
+	require.Equal(t, gadgetv1alpha1.TraceStateStarted, get("filetop").Status.State)
+	require.Equal(t, gadgetv1alpha1.TraceStateStarted, get("exec").Status.State)
+
+	// Clearing resets the stats without stopping the trace
+	factory.stats = 42
+	clearTrace("filetop")
+	assert.Zero(t, factory.stats)
+	trace := get("filetop")
+	assert.Equal(t, gadgetv1alpha1.TraceStateStarted, trace.Status.State)
+	assert.Empty(t, trace.Status.OperationError)
+	assert.NotContains(t, trace.Annotations, GadgetOperation)
+
+	// It's rejected by the gadgets that don't aggregate stats
+	clearTrace("exec")
+	trace = get("exec")
+	assert.Equal(t, `Unsupported operation "clear" for gadget "exec"`, trace.Status.OperationError)
+	assert.Equal(t, gadgetv1alpha1.TraceStateStarted, trace.Status.State)
+	assert.NotContains(t, trace.Annotations, GadgetOperation)
+}
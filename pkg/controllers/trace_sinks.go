@@ -0,0 +1,226 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+const (
+	// TraceFilesDir is the directory on the node File sinks write to
+	TraceFilesDir = "/var/lib/inspektor-gadget/traces"
+
+	defaultTraceSinkPath = "{{.Namespace}}/{{.Trace}}.json"
+)
+
+// traceEventsTotal is updated by Metrics sinks. It's served together with the
+// other metrics of the controller manager.
+var traceEventsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "gadget_trace_events_total",
+		Help: "Number of events generated by traces with a Metrics sink",
+	},
+	[]string{"namespace", "trace", "gadget", "type"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(traceEventsTotal)
+}
+
+// writesStreamToFile tells whether the events streamed by the gadget are
+// written to a file on the node: with OutputMode=File, when the gadget
+// doesn't handle this output mode by itself.
+func writesStreamToFile(trace *gadgetv1alpha1.Trace, outputModes map[gadgetv1alpha1.TraceOutputMode]struct{}) bool {
+	if trace.Spec.OutputMode != gadgetv1alpha1.TraceOutputModeFile {
+		return false
+	}
+	_, file := outputModes[gadgetv1alpha1.TraceOutputModeFile]
+	_, stream := outputModes[gadgetv1alpha1.TraceOutputModeStream]
+	return stream && !file
+}
+
+// renderTraceFilePath returns the path of a file the events of the trace are
+// written to on the node (without the host root prefix)
+func renderTraceFilePath(trace *gadgetv1alpha1.Trace, path string, now time.Time) (string, error) {
+	if path == "" {
+		path = defaultTraceSinkPath
+	}
+	path, err := gadgets.RenderOutputName(path,
+		gadgets.NewOutputNameData(trace, trace.Namespace, "", "", now))
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("path %q must be relative to %s and not escape it", path, TraceFilesDir)
+	}
+	return filepath.Join(TraceFilesDir, path), nil
+}
+
+func validateFileRotation(rotation *gadgetv1alpha1.TraceFileRotation) error {
+	if rotation == nil {
+		return nil
+	}
+	if rotation.MaxSize != nil && rotation.MaxSize.Sign() < 0 {
+		return fmt.Errorf("rotation maxSize %q must not be negative", rotation.MaxSize)
+	}
+	if rotation.MaxAge != nil && rotation.MaxAge.Duration < 0 {
+		return fmt.Errorf("rotation maxAge %q must not be negative", rotation.MaxAge.Duration)
+	}
+	if rotation.MaxFiles < 0 {
+		return fmt.Errorf("rotation maxFiles %d must not be negative", rotation.MaxFiles)
+	}
+	return nil
+}
+
+func fileRotation(rotation *gadgetv1alpha1.TraceFileRotation) stream.FileRotation {
+	if rotation == nil {
+		return stream.FileRotation{}
+	}
+	r := stream.FileRotation{
+		MaxFiles: rotation.MaxFiles,
+		Compress: rotation.Compress,
+	}
+	if rotation.MaxSize != nil {
+		r.MaxSize = rotation.MaxSize.Value()
+	}
+	if rotation.MaxAge != nil {
+		r.MaxAge = rotation.MaxAge.Duration
+	}
+	return r
+}
+
+// validateTraceSinks checks the sinks of the trace, and its output file when
+// the events are written to a file, before it's started
+func validateTraceSinks(trace *gadgetv1alpha1.Trace, outputModes map[gadgetv1alpha1.TraceOutputMode]struct{}) error {
+	toFile := writesStreamToFile(trace, outputModes)
+	if toFile {
+		if _, err := renderTraceFilePath(trace, trace.Spec.Output, time.Time{}); err != nil {
+			return fmt.Errorf("output: %w", err)
+		}
+		if err := validateFileRotation(trace.Spec.Rotation); err != nil {
+			return fmt.Errorf("output: %w", err)
+		}
+	} else if trace.Spec.Rotation != nil {
+		return fmt.Errorf("rotation is only supported with OutputMode %q", gadgetv1alpha1.TraceOutputModeFile)
+	}
+
+	if len(trace.Spec.Sinks) == 0 {
+		return nil
+	}
+	if trace.Spec.OutputMode != gadgetv1alpha1.TraceOutputModeStream && !toFile {
+		return fmt.Errorf("sinks are only supported with OutputMode %q", gadgetv1alpha1.TraceOutputModeStream)
+	}
+
+	for i := range trace.Spec.Sinks {
+		sink := &trace.Spec.Sinks[i]
+		switch sink.Type {
+		case gadgetv1alpha1.TraceSinkTypeFile:
+			if _, err := renderTraceFilePath(trace, sink.Path, time.Time{}); err != nil {
+				return fmt.Errorf("sink %d: %w", i, err)
+			}
+			if err := validateFileRotation(sink.Rotation); err != nil {
+				return fmt.Errorf("sink %d: %w", i, err)
+			}
+		case gadgetv1alpha1.TraceSinkTypeMetrics:
+			if sink.Path != "" {
+				return fmt.Errorf("sink %d: path is only supported with type %q", i, gadgetv1alpha1.TraceSinkTypeFile)
+			}
+			if sink.Rotation != nil {
+				return fmt.Errorf("sink %d: rotation is only supported with type %q", i, gadgetv1alpha1.TraceSinkTypeFile)
+			}
+		default:
+			return fmt.Errorf("sink %d: unknown type %q", i, sink.Type)
+		}
+	}
+	return nil
+}
+
+// newFileSink creates a File sink writing to path, relative to TraceFilesDir
+func newFileSink(trace *gadgetv1alpha1.Trace, path string, rotation *gadgetv1alpha1.TraceFileRotation, now time.Time) (stream.Sink, error) {
+	path, err := renderTraceFilePath(trace, path, now)
+	if err != nil {
+		return nil, err
+	}
+	return stream.NewFileSink(filepath.Join(host.HostRoot, path), fileRotation(rotation))
+}
+
+// newTraceSinks creates the sinks of the trace, starting with its output file
+// if toFile is set. The sinks already created are closed if one of them fails.
+func newTraceSinks(trace *gadgetv1alpha1.Trace, toFile bool, now time.Time) ([]stream.Sink, error) {
+	sinks := make([]stream.Sink, 0, len(trace.Spec.Sinks)+1)
+	closeAll := func() {
+		for _, s := range sinks {
+			s.Close()
+		}
+	}
+
+	if toFile {
+		fileSink, err := newFileSink(trace, trace.Spec.Output, trace.Spec.Rotation, now)
+		if err != nil {
+			return nil, fmt.Errorf("output: %w", err)
+		}
+		sinks = append(sinks, fileSink)
+	}
+
+	for i := range trace.Spec.Sinks {
+		sink := &trace.Spec.Sinks[i]
+		switch sink.Type {
+		case gadgetv1alpha1.TraceSinkTypeFile:
+			fileSink, err := newFileSink(trace, sink.Path, sink.Rotation, now)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			sinks = append(sinks, fileSink)
+		case gadgetv1alpha1.TraceSinkTypeMetrics:
+			sinks = append(sinks, stream.NewMetricsSink(traceEventsTotal, prometheus.Labels{
+				"namespace": trace.Namespace,
+				"trace":     trace.Name,
+				"gadget":    trace.Spec.Gadget,
+			}))
+		default:
+			closeAll()
+			return nil, fmt.Errorf("unknown sink type %q", sink.Type)
+		}
+	}
+	return sinks, nil
+}
+
+// addTraceSinks registers the sinks of the trace on its stream
+func (r *TraceReconciler) addTraceSinks(trace *gadgetv1alpha1.Trace, tracerID string, toFile bool) error {
+	sinks, err := newTraceSinks(trace, toFile, r.now())
+	if err != nil {
+		return err
+	}
+	for i, sink := range sinks {
+		if err := r.TracerManager.AddStreamSink(tracerID, sink); err != nil {
+			for _, s := range sinks[i+1:] {
+				s.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
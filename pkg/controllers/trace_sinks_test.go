@@ -0,0 +1,285 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+func newSinkTrace(sinks ...gadgetv1alpha1.TraceSink) *gadgetv1alpha1.Trace {
+	return &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{Name: "exec", Namespace: "gadget"},
+		Spec: gadgetv1alpha1.TraceSpec{
+			Node:       "node1",
+			Gadget:     "exec",
+			OutputMode: gadgetv1alpha1.TraceOutputModeStream,
+			Sinks:      sinks,
+		},
+	}
+}
+
+func newFileTrace(output string, rotation *gadgetv1alpha1.TraceFileRotation, sinks ...gadgetv1alpha1.TraceSink) *gadgetv1alpha1.Trace {
+	trace := newSinkTrace(sinks...)
+	trace.Spec.OutputMode = gadgetv1alpha1.TraceOutputModeFile
+	trace.Spec.Output = output
+	trace.Spec.Rotation = rotation
+	return trace
+}
+
+var streamOutputModes = map[gadgetv1alpha1.TraceOutputMode]struct{}{
+	gadgetv1alpha1.TraceOutputModeStream: {},
+}
+
+func TestValidateTraceSinks(t *testing.T) {
+	maxSize := resource.MustParse("10Mi")
+	negativeSize := resource.MustParse("-1")
+
+	tests := map[string]struct {
+		trace       *gadgetv1alpha1.Trace
+		outputModes map[gadgetv1alpha1.TraceOutputMode]struct{}
+		err         string
+	}{
+		"no sinks": {
+			trace: newSinkTrace(),
+		},
+		"file and metrics": {
+			trace: newSinkTrace(
+				gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeFile},
+				gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeMetrics},
+			),
+		},
+		"templated path": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type: gadgetv1alpha1.TraceSinkTypeFile,
+				Path: "{{.Node}}/{{.Date}}.json",
+			}),
+		},
+		"unknown variable": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type: gadgetv1alpha1.TraceSinkTypeFile,
+				Path: "{{.Foo}}.json",
+			}),
+			err: "sink 0: rendering output name",
+		},
+		"absolute path": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type: gadgetv1alpha1.TraceSinkTypeFile,
+				Path: "/etc/passwd",
+			}),
+			err: "must be relative",
+		},
+		"escaping path": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type: gadgetv1alpha1.TraceSinkTypeFile,
+				Path: "../../../etc/passwd",
+			}),
+			err: "must be relative",
+		},
+		"metrics with path": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type: gadgetv1alpha1.TraceSinkTypeMetrics,
+				Path: "foo",
+			}),
+			err: "path is only supported",
+		},
+		"unknown type": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{Type: "Foo"}),
+			err:   "unknown type",
+		},
+		"status output mode": {
+			trace: func() *gadgetv1alpha1.Trace {
+				trace := newSinkTrace(gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeMetrics})
+				trace.Spec.OutputMode = gadgetv1alpha1.TraceOutputModeStatus
+				return trace
+			}(),
+			err: "only supported with OutputMode",
+		},
+		"file output mode": {
+			trace: newFileTrace("", &gadgetv1alpha1.TraceFileRotation{
+				MaxSize:  &maxSize,
+				MaxAge:   &metav1.Duration{Duration: time.Hour},
+				MaxFiles: 3,
+				Compress: true,
+			}, gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeMetrics}),
+		},
+		"file output mode escaping path": {
+			trace: newFileTrace("../foo.json", nil),
+			err:   "output: path",
+		},
+		"file output mode negative size": {
+			trace: newFileTrace("", &gadgetv1alpha1.TraceFileRotation{MaxSize: &negativeSize}),
+			err:   "output: rotation maxSize",
+		},
+		"file output mode handled by the gadget": {
+			trace: newFileTrace("", &gadgetv1alpha1.TraceFileRotation{MaxFiles: 1}),
+			outputModes: map[gadgetv1alpha1.TraceOutputMode]struct{}{
+				gadgetv1alpha1.TraceOutputModeStream: {},
+				gadgetv1alpha1.TraceOutputModeFile:   {},
+			},
+			err: "rotation is only supported with OutputMode",
+		},
+		"rotation without stream": {
+			trace: newFileTrace("", &gadgetv1alpha1.TraceFileRotation{MaxFiles: 1}),
+			outputModes: map[gadgetv1alpha1.TraceOutputMode]struct{}{
+				gadgetv1alpha1.TraceOutputModeStatus: {},
+			},
+			err: "rotation is only supported with OutputMode",
+		},
+		"sink rotation": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type:     gadgetv1alpha1.TraceSinkTypeFile,
+				Rotation: &gadgetv1alpha1.TraceFileRotation{MaxAge: &metav1.Duration{Duration: time.Minute}},
+			}),
+		},
+		"sink negative max files": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type:     gadgetv1alpha1.TraceSinkTypeFile,
+				Rotation: &gadgetv1alpha1.TraceFileRotation{MaxFiles: -1},
+			}),
+			err: "sink 0: rotation maxFiles",
+		},
+		"metrics with rotation": {
+			trace: newSinkTrace(gadgetv1alpha1.TraceSink{
+				Type:     gadgetv1alpha1.TraceSinkTypeMetrics,
+				Rotation: &gadgetv1alpha1.TraceFileRotation{MaxFiles: 1},
+			}),
+			err: "rotation is only supported with type",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			outputModes := test.outputModes
+			if outputModes == nil {
+				outputModes = streamOutputModes
+			}
+			err := validateTraceSinks(test.trace, outputModes)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestTraceSinksFanOut(t *testing.T) {
+	oldHostRoot := host.HostRoot
+	host.HostRoot = t.TempDir()
+	t.Cleanup(func() { host.HostRoot = oldHostRoot })
+
+	trace := newSinkTrace(
+		gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeFile, Path: "{{.Namespace}}/{{.Trace}}-{{.Date}}.json"},
+		gadgetv1alpha1.TraceSink{Type: gadgetv1alpha1.TraceSinkTypeMetrics},
+	)
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	sinks, err := newTraceSinks(trace, false, now)
+	require.NoError(t, err)
+	require.Len(t, sinks, 2)
+
+	s := stream.NewGadgetStream()
+	for _, sink := range sinks {
+		require.NoError(t, s.AddSink(sink))
+	}
+	ch := s.Subscribe(true)
+
+	lines := []string{
+		`{"type":"normal","comm":"sh"}`,
+		`{"type":"normal","comm":"ls"}`,
+		`{"type":"err","message":"lost"}`,
+	}
+	for _, line := range lines {
+		s.Publish(line)
+	}
+
+	// The stream still gets all the events, numbered
+	received := []string{}
+	for i, line := range lines {
+		r := (<-ch).Line
+		assert.JSONEq(t, line, removeStamp(t, r, uint64(i+1)))
+		received = append(received, r)
+	}
+
+	labels := prometheus.Labels{"namespace": "gadget", "trace": "exec", "gadget": "exec"}
+	labels["type"] = "normal"
+	assert.Equal(t, 2.0, testutil.ToFloat64(traceEventsTotal.With(labels)))
+	labels["type"] = "err"
+	assert.Equal(t, 1.0, testutil.ToFloat64(traceEventsTotal.With(labels)))
+
+	s.Close()
+
+	content, err := os.ReadFile(filepath.Join(host.HostRoot, TraceFilesDir, "gadget", "exec-2024-03-15.json"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(received, "\n")+"\n", string(content))
+
+	// Closing the stream removes the metrics of the trace
+	assert.Zero(t, testutil.CollectAndCount(traceEventsTotal))
+}
+
+func TestTraceOutputFile(t *testing.T) {
+	oldHostRoot := host.HostRoot
+	host.HostRoot = t.TempDir()
+	t.Cleanup(func() { host.HostRoot = oldHostRoot })
+
+	trace := newFileTrace("", &gadgetv1alpha1.TraceFileRotation{MaxFiles: 2})
+	require.True(t, writesStreamToFile(trace, streamOutputModes))
+
+	sinks, err := newTraceSinks(trace, true, time.Now())
+	require.NoError(t, err)
+	require.Len(t, sinks, 1)
+
+	s := stream.NewGadgetStream()
+	require.NoError(t, s.AddSink(sinks[0]))
+	s.Publish(`{"type":"normal","comm":"sh"}`)
+	s.Close()
+
+	content, err := os.ReadFile(filepath.Join(host.HostRoot, TraceFilesDir, "gadget", "exec.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"normal","comm":"sh"}`, removeStamp(t, strings.TrimSuffix(string(content), "\n"), 1))
+}
+
+// removeStamp checks the sequence number and the ID of the event in line and
+// returns it without them
+func removeStamp(t *testing.T, line string, seq uint64) string {
+	t.Helper()
+
+	var event map[string]any
+	require.NoError(t, json.Unmarshal([]byte(line), &event))
+	assert.Equal(t, float64(seq), event[stream.SeqField])
+	assert.NotEmpty(t, event[stream.EventIDField])
+	delete(event, stream.SeqField)
+	delete(event, stream.EventIDField)
+
+	ret, err := json.Marshal(event)
+	require.NoError(t, err)
+	return string(ret)
+}
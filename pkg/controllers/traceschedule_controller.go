@@ -0,0 +1,286 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/cron"
+)
+
+const (
+	// TraceScheduleLabel is set on traces created by a TraceSchedule and
+	// contains the name of the TraceSchedule
+	TraceScheduleLabel = "gadget.kinvolk.io/traceschedule"
+	// TraceScheduleNodeLabel is set on traces created by a TraceSchedule and
+	// contains the node the trace runs on
+	TraceScheduleNodeLabel = "gadget.kinvolk.io/traceschedule-node"
+	// TraceScheduledAtAnnotation contains the activation time (RFC3339) a
+	// trace was created for
+	TraceScheduledAtAnnotation = "gadget.kinvolk.io/scheduled-at"
+	// TraceScheduleFinishedAnnotation is set once the stop operation was
+	// applied to a scheduled trace
+	TraceScheduleFinishedAnnotation = "gadget.kinvolk.io/schedule-finished"
+
+	defaultTraceScheduleHistoryLimit = 3
+)
+
+// TraceScheduleReconciler reconciles a TraceSchedule object. Every node runs
+// its own reconciler, which creates (and stops) the traces for that node.
+type TraceScheduleReconciler struct {
+	Client client.Client
+	Scheme *runtime.Scheme
+	Node   string
+
+	// Now returns the current time; it can be overridden for testing
+	Now func() time.Time
+}
+
+type scheduledTrace struct {
+	trace       *gadgetv1alpha1.Trace
+	scheduledAt time.Time
+}
+
+func (r *TraceScheduleReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *TraceScheduleReconciler) setError(ctx context.Context, schedule *gadgetv1alpha1.TraceSchedule, strError string) {
+	if schedule.Status.Error == strError {
+		return
+	}
+	patch := client.MergeFrom(schedule.DeepCopy())
+	schedule.Status.Error = strError
+	if err := r.Client.Status().Patch(ctx, schedule, patch); err != nil {
+		log.Errorf("Failed to update trace schedule %s/%s status: %s", schedule.Namespace, schedule.Name, err)
+	}
+}
+
+// lastActivation returns the latest activation time of the schedule that is
+// not after now, given the previous activation (or creation) time
+func lastActivation(sched *cron.Schedule, since, now time.Time) time.Time {
+	var last time.Time
+	for t := sched.Next(since); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+		last = t
+	}
+	return last
+}
+
+//+kubebuilder:rbac:groups=gadget.kinvolk.io,resources=traceschedules,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=gadget.kinvolk.io,resources=traceschedules/status,verbs=get;update;patch
+
+// Reconcile creates a new trace for this node whenever the schedule is due and
+// applies the stop operation to traces that ran for the configured duration.
+func (r *TraceScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	schedule := &gadgetv1alpha1.TraceSchedule{}
+	err := r.Client.Get(ctx, req.NamespacedName, schedule)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.Infof("TraceSchedule %q has been deleted", req.NamespacedName.String())
+			return ctrl.Result{}, nil
+		}
+		log.Errorf("Failed to get TraceSchedule %q: %s", req.NamespacedName.String(), err)
+		return ctrl.Result{}, err
+	}
+
+	if !schedule.ObjectMeta.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	// The schedule could be restricted to a single node
+	if schedule.Spec.Trace.Node != "" && schedule.Spec.Trace.Node != r.Node {
+		return ctrl.Result{}, nil
+	}
+
+	sched, err := cron.Parse(schedule.Spec.Schedule)
+	if err != nil {
+		r.setError(ctx, schedule, fmt.Sprintf("Invalid schedule %q: %s", schedule.Spec.Schedule, err))
+		return ctrl.Result{}, nil
+	}
+	if schedule.Spec.Duration.Duration <= 0 {
+		r.setError(ctx, schedule, "Duration must be greater than 0")
+		return ctrl.Result{}, nil
+	}
+
+	traceList := &gadgetv1alpha1.TraceList{}
+	err = r.Client.List(ctx, traceList,
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			TraceScheduleLabel:     schedule.Name,
+			TraceScheduleNodeLabel: r.Node,
+		},
+	)
+	if err != nil {
+		log.Errorf("Failed to list traces of TraceSchedule %q: %s", req.NamespacedName.String(), err)
+		return ctrl.Result{}, err
+	}
+
+	now := r.now()
+	var requeueAt time.Time
+	requeue := func(t time.Time) {
+		if requeueAt.IsZero() || t.Before(requeueAt) {
+			requeueAt = t
+		}
+	}
+
+	lastScheduled := schedule.CreationTimestamp.Time
+	var finished []scheduledTrace
+	for i := range traceList.Items {
+		trace := &traceList.Items[i]
+		scheduledAt, err := time.Parse(time.RFC3339, trace.Annotations[TraceScheduledAtAnnotation])
+		if err != nil {
+			log.Warnf("Trace %s/%s has an invalid %s annotation", trace.Namespace, trace.Name, TraceScheduledAtAnnotation)
+			continue
+		}
+		if scheduledAt.After(lastScheduled) {
+			lastScheduled = scheduledAt
+		}
+
+		if _, ok := trace.Annotations[TraceScheduleFinishedAnnotation]; ok {
+			finished = append(finished, scheduledTrace{trace: trace, scheduledAt: scheduledAt})
+			continue
+		}
+
+		stopAt := scheduledAt.Add(schedule.Spec.Duration.Duration)
+		if now.Before(stopAt) {
+			requeue(stopAt)
+			continue
+		}
+
+		stopOperation := schedule.Spec.StopOperation
+		if stopOperation == "" {
+			stopOperation = gadgetv1alpha1.OperationStop
+		}
+
+		log.Infof("Applying operation %q to scheduled trace %s/%s", stopOperation, trace.Namespace, trace.Name)
+		patch := client.MergeFrom(trace.DeepCopy())
+		trace.Annotations[GadgetOperation] = string(stopOperation)
+		trace.Annotations[TraceScheduleFinishedAnnotation] = now.UTC().Format(time.RFC3339)
+		if err := r.Client.Patch(ctx, trace, patch); err != nil {
+			log.Errorf("Failed to stop scheduled trace %s/%s: %s", trace.Namespace, trace.Name, err)
+			return ctrl.Result{}, err
+		}
+		finished = append(finished, scheduledTrace{trace: trace, scheduledAt: scheduledAt})
+	}
+
+	// Garbage collect the oldest finished traces
+	historyLimit := defaultTraceScheduleHistoryLimit
+	if schedule.Spec.HistoryLimit != nil {
+		historyLimit = int(*schedule.Spec.HistoryLimit)
+	}
+	if len(finished) > historyLimit {
+		sort.Slice(finished, func(i, j int) bool {
+			return finished[i].scheduledAt.Before(finished[j].scheduledAt)
+		})
+		for _, st := range finished[:len(finished)-historyLimit] {
+			log.Infof("Deleting old scheduled trace %s/%s", st.trace.Namespace, st.trace.Name)
+			err := r.Client.Delete(ctx, st.trace)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				log.Errorf("Failed to delete scheduled trace %s/%s: %s", st.trace.Namespace, st.trace.Name, err)
+			}
+		}
+	}
+
+	if schedule.Spec.Suspend {
+		return r.result(requeueAt, now), nil
+	}
+
+	if scheduledAt := lastActivation(sched, lastScheduled, now); !scheduledAt.IsZero() {
+		if err := r.createTrace(ctx, schedule, scheduledAt); err != nil {
+			return ctrl.Result{}, err
+		}
+		lastScheduled = scheduledAt
+		requeue(scheduledAt.Add(schedule.Spec.Duration.Duration))
+
+		patch := client.MergeFrom(schedule.DeepCopy())
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: scheduledAt}
+		schedule.Status.Error = ""
+		if err := r.Client.Status().Patch(ctx, schedule, patch); err != nil {
+			log.Errorf("Failed to update trace schedule %q status: %s", req.NamespacedName.String(), err)
+		}
+	}
+
+	if next := sched.Next(lastScheduled); !next.IsZero() {
+		requeue(next)
+	}
+
+	return r.result(requeueAt, now), nil
+}
+
+func (r *TraceScheduleReconciler) result(requeueAt, now time.Time) ctrl.Result {
+	if requeueAt.IsZero() {
+		return ctrl.Result{}
+	}
+	after := requeueAt.Sub(now)
+	if after < time.Second {
+		after = time.Second
+	}
+	return ctrl.Result{RequeueAfter: after}
+}
+
+func (r *TraceScheduleReconciler) createTrace(ctx context.Context, schedule *gadgetv1alpha1.TraceSchedule, scheduledAt time.Time) error {
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s-%d", schedule.Name, r.Node, scheduledAt.Unix()),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				TraceScheduleLabel:     schedule.Name,
+				TraceScheduleNodeLabel: r.Node,
+			},
+			Annotations: map[string]string{
+				GadgetOperation:            string(gadgetv1alpha1.OperationStart),
+				TraceScheduledAtAnnotation: scheduledAt.UTC().Format(time.RFC3339),
+			},
+		},
+		Spec: *schedule.Spec.Trace.DeepCopy(),
+	}
+	trace.Spec.Node = r.Node
+	trace.Spec.RunMode = gadgetv1alpha1.RunModeManual
+
+	if err := controllerutil.SetControllerReference(schedule, trace, r.Scheme); err != nil {
+		return fmt.Errorf("setting owner of trace: %w", err)
+	}
+
+	log.Infof("Creating scheduled trace %s/%s (gadget %s)", trace.Namespace, trace.Name, trace.Spec.Gadget)
+	err := r.Client.Create(ctx, trace)
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		log.Errorf("Failed to create scheduled trace %s/%s: %s", trace.Namespace, trace.Name, err)
+		return err
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *TraceScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gadgetv1alpha1.TraceSchedule{}).
+		Owns(&gadgetv1alpha1.Trace{}).
+		Complete(r)
+}
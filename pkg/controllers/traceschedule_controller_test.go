@@ -0,0 +1,151 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+)
+
+func TestTraceScheduleReconcile(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	created := time.Date(2024, time.March, 15, 10, 0, 30, 0, time.UTC)
+	historyLimit := int32(1)
+	schedule := &gadgetv1alpha1.TraceSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "nightly",
+			Namespace:         "gadget",
+			CreationTimestamp: metav1.Time{Time: created},
+		},
+		Spec: gadgetv1alpha1.TraceScheduleSpec{
+			Schedule:     "*/10 * * * *",
+			Duration:     metav1.Duration{Duration: 2 * time.Minute},
+			HistoryLimit: &historyLimit,
+			Trace: gadgetv1alpha1.TraceSpec{
+				Gadget:     "capabilities",
+				OutputMode: gadgetv1alpha1.TraceOutputModeStatus,
+			},
+		},
+	}
+
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(schedule).
+		WithStatusSubresource(schedule).
+		Build()
+
+	now := created
+	r := &TraceScheduleReconciler{
+		Client: cli,
+		Scheme: scheme,
+		Node:   "node1",
+		Now:    func() time.Time { return now },
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "nightly"}}
+
+	listTraces := func() []gadgetv1alpha1.Trace {
+		traces := &gadgetv1alpha1.TraceList{}
+		require.NoError(t, cli.List(ctx, traces, client.InNamespace("gadget")))
+		return traces.Items
+	}
+
+	// Nothing to do before the first activation
+	res, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 9*time.Minute+30*time.Second, res.RequeueAfter)
+	assert.Empty(t, listTraces())
+
+	// First activation creates a trace
+	now = created.Add(10 * time.Minute)
+	res, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, 1*time.Minute+30*time.Second, res.RequeueAfter)
+	traces := listTraces()
+	require.Len(t, traces, 1)
+	assert.Equal(t, "node1", traces[0].Spec.Node)
+	assert.Equal(t, gadgetv1alpha1.RunModeManual, traces[0].Spec.RunMode)
+	assert.Equal(t, string(gadgetv1alpha1.OperationStart), traces[0].Annotations[GadgetOperation])
+	assert.Equal(t, "nightly", traces[0].Labels[TraceScheduleLabel])
+
+	// The trace is stopped after the duration
+	now = created.Add(12 * time.Minute)
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	traces = listTraces()
+	require.Len(t, traces, 1)
+	assert.Equal(t, string(gadgetv1alpha1.OperationStop), traces[0].Annotations[GadgetOperation])
+	assert.Contains(t, traces[0].Annotations, TraceScheduleFinishedAnnotation)
+
+	// Next activation and garbage collection of the first trace
+	now = created.Add(22*time.Minute + 30*time.Second)
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	now = now.Add(5 * time.Minute)
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	traces = listTraces()
+	require.Len(t, traces, 1)
+	assert.Equal(t, "2024-03-15T10:20:00Z", traces[0].Annotations[TraceScheduledAtAnnotation])
+
+	updated := &gadgetv1alpha1.TraceSchedule{}
+	require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+	require.NotNil(t, updated.Status.LastScheduleTime)
+	assert.True(t, updated.Status.LastScheduleTime.Time.Equal(created.Add(19*time.Minute+30*time.Second)))
+}
+
+func TestTraceScheduleInvalid(t *testing.T) {
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, gadgetv1alpha1.AddToScheme(scheme))
+
+	schedule := &gadgetv1alpha1.TraceSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "gadget"},
+		Spec: gadgetv1alpha1.TraceScheduleSpec{
+			Schedule: "not a schedule",
+			Duration: metav1.Duration{Duration: time.Minute},
+		},
+	}
+	cli := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(schedule).
+		WithStatusSubresource(schedule).
+		Build()
+
+	r := &TraceScheduleReconciler{Client: cli, Scheme: scheme, Node: "node1"}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "gadget", Name: "invalid"}}
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	updated := &gadgetv1alpha1.TraceSchedule{}
+	require.NoError(t, cli.Get(ctx, req.NamespacedName, updated))
+	assert.Contains(t, updated.Status.Error, "Invalid schedule")
+}
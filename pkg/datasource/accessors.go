@@ -210,8 +210,13 @@ func (a *fieldAccessor) Get(d Data) []byte {
 		return nil
 	}
 	if a.f.Size > 0 {
-		// size and offset must be valid here; checks take place on initialization
-		return d.payload()[a.f.PayloadIndex][a.f.Offs : a.f.Offs+a.f.Size]
+		// size and offset are checked on initialization, but the payload
+		// could have been pruned because the field wasn't requested
+		payload := d.payload()[a.f.PayloadIndex]
+		if uint32(len(payload)) < a.f.Offs+a.f.Size {
+			return nil
+		}
+		return payload[a.f.Offs : a.f.Offs+a.f.Size]
 	}
 	return d.payload()[a.f.PayloadIndex]
 }
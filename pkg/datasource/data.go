@@ -170,6 +170,10 @@ type dataSource struct {
 
 	requestedFields map[string]bool
 
+	// unrequestedPayloads are the payloads only used by fields that weren't
+	// requested, see SetRequestedFields
+	unrequestedPayloads []uint32
+
 	subscriptions []*subscription
 
 	requested bool
@@ -682,6 +686,76 @@ func (ds *dataSource) IsRequestedField(fieldName string) bool {
 	return ds.requestedFields[fieldName]
 }
 
+func (ds *dataSource) SetRequestedFields(names []string) error {
+	ds.lock.Lock()
+	defer ds.lock.Unlock()
+
+	ds.unrequestedPayloads = nil
+	if len(names) == 0 {
+		return nil
+	}
+
+	// A field is requested if it's given by its full name or its name, or if
+	// its parent is requested
+	requested := make([]bool, len(ds.fields))
+	found := false
+	for _, f := range ds.fields {
+		for _, name := range names {
+			if strings.EqualFold(f.FullName, name) || strings.EqualFold(f.Name, name) {
+				requested[f.Index] = true
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("none of the fields %q exist", names)
+	}
+	// Fields are added after their parents
+	for _, f := range ds.fields {
+		if FieldFlagHasParent.In(f.Flags) && requested[f.Parent] {
+			requested[f.Index] = true
+		}
+	}
+
+	usedPayloads := make([]bool, ds.payloadCount)
+	for _, f := range ds.fields {
+		if requested[f.Index] && !FieldFlagEmpty.In(f.Flags) {
+			usedPayloads[f.PayloadIndex] = true
+		}
+	}
+	for idx, used := range usedPayloads {
+		if !used {
+			ds.unrequestedPayloads = append(ds.unrequestedPayloads, uint32(idx))
+		}
+	}
+	return nil
+}
+
+func (ds *dataSource) PruneUnrequested(p Packet) {
+	ds.lock.RLock()
+	defer ds.lock.RUnlock()
+
+	if len(ds.unrequestedPayloads) == 0 {
+		return
+	}
+
+	prune := func(payload [][]byte) {
+		for _, idx := range ds.unrequestedPayloads {
+			payload[idx] = nil
+		}
+	}
+
+	switch p := p.(type) {
+	case *data:
+		prune(p.payload())
+	case *dataArray:
+		for _, d := range p.DataArray {
+			prune((*dataElement)(d).payload())
+		}
+	}
+}
+
 func (ds *dataSource) dumpData(wr io.Writer, data Data) {
 	for _, f := range ds.fields {
 		if f.Offs+f.Size > uint32(len(data.payload()[f.PayloadIndex])) {
@@ -175,6 +175,16 @@ type DataSource interface {
 	SetRequested(bool)
 	IsRequested() bool
 
+	// SetRequestedFields restricts the fields sent to the clients to the ones
+	// with the given names and their sub-fields. All fields are sent if names
+	// is empty.
+	SetRequestedFields(names []string) error
+
+	// PruneUnrequested drops the payloads of Packet that are only used by
+	// fields that weren't requested, to reduce its size before marshaling it.
+	// Pruned fields have empty values.
+	PruneUnrequested(Packet)
+
 	// ByteOrder returns a binary accessor using the byte order of the creator of the DataSource
 	ByteOrder() binary.ByteOrder
 
@@ -797,3 +797,68 @@ func randBytes(n int) []byte {
 	rand.Read(ret)
 	return ret
 }
+
+func TestDataSourceRequestedFields(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	fields := []StaticField{
+		&dummyField{
+			name:   "f1",
+			size:   4,
+			offset: 0,
+		},
+		&dummyField{
+			name:   "f2",
+			size:   4,
+			offset: 4,
+		},
+	}
+	static, err := ds.AddStaticFields(8, fields)
+	require.NoError(t, err)
+
+	own, err := ds.AddField("own", api.Kind_String)
+	require.NoError(t, err)
+
+	err = ds.SetRequestedFields([]string{"unknown"})
+	require.Error(t, err)
+
+	err = ds.SetRequestedFields([]string{"OWN"})
+	require.NoError(t, err)
+
+	f1 := ds.GetField("f1")
+	require.NotNil(t, f1)
+
+	newPacket := func() PacketSingle {
+		d, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, static.Set(d, []byte{1, 2, 3, 4, 5, 6, 7, 8}))
+		require.NoError(t, own.Set(d, []byte("foo")))
+		return d
+	}
+
+	d := newPacket()
+	ds.PruneUnrequested(d)
+	assert.Nil(t, f1.Get(d))
+	assert.Equal(t, []byte("foo"), own.Get(d))
+
+	// Members of static fields keep the whole payload
+	err = ds.SetRequestedFields([]string{"f2"})
+	require.NoError(t, err)
+
+	d = newPacket()
+	ds.PruneUnrequested(d)
+	assert.Equal(t, []byte{1, 2, 3, 4}, f1.Get(d))
+	assert.Nil(t, own.Get(d))
+
+	// An empty selection sends all fields again
+	err = ds.SetRequestedFields(nil)
+	require.NoError(t, err)
+
+	d = newPacket()
+	ds.PruneUnrequested(d)
+	assert.Equal(t, []byte{1, 2, 3, 4}, f1.Get(d))
+	assert.Equal(t, []byte("foo"), own.Get(d))
+}
@@ -0,0 +1,100 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package dnsmetrics
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection/networktracer"
+	dnstracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/tracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// Collector traces the DNS packets of all the containers of the node and
+// updates the metrics with them until it's stopped
+type Collector struct {
+	cc *containercollection.ContainerCollection
+
+	tracer *dnstracer.Tracer
+	conn   *networktracer.ConnectionToContainerCollection
+}
+
+func New(cc *containercollection.ContainerCollection) *Collector {
+	return &Collector{cc: cc}
+}
+
+func (c *Collector) Start() error {
+	var err error
+	c.tracer, err = dnstracer.NewTracer(&dnstracer.Config{})
+	if err != nil {
+		return fmt.Errorf("creating dns tracer: %w", err)
+	}
+	c.tracer.SetEventHandler(c.handleEvent)
+
+	c.conn, err = networktracer.ConnectToContainerCollection(&networktracer.ConnectToContainerCollectionConfig[types.Event]{
+		Tracer:   c.tracer,
+		Resolver: c.cc,
+		Selector: containercollection.ContainerSelector{},
+		Base:     types.Base,
+	})
+	if err != nil {
+		c.Stop()
+		return fmt.Errorf("connecting dns tracer to containers: %w", err)
+	}
+
+	if err := c.tracer.RunWorkaround(); err != nil {
+		c.Stop()
+		return fmt.Errorf("running dns tracer: %w", err)
+	}
+
+	log.Info("Collecting DNS metrics")
+	return nil
+}
+
+func (c *Collector) handleEvent(event *types.Event) {
+	switch event.Type {
+	case eventtypes.NORMAL:
+	case eventtypes.ERR:
+		log.Warnf("DNS metrics: %s", event.Message)
+		return
+	default:
+		return
+	}
+
+	c.cc.EnrichByNetNs(&event.CommonData, event.NetNsID)
+	// The packets of the pods using the network of the node can't be told
+	// apart
+	if event.K8s.HostNetwork {
+		return
+	}
+	observe(event.K8s.Namespace, event)
+}
+
+func (c *Collector) Stop() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	if c.tracer != nil {
+		c.tracer.Close()
+		c.tracer = nil
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dnsmetrics aggregates the DNS traffic of the containers of the node
+// into metrics labelled by Kubernetes namespace only, so that they can be
+// collected all the time as the input of DNS SLOs.
+package dnsmetrics
+
+import (
+	"github.com/gopacket/gopacket/layers"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+)
+
+// rcodeOther is the label of the response codes not in rcodeLabels
+const rcodeOther = "OTHER"
+
+// rcodeLabels maps the response codes, as reported by the dns tracer, to their
+// mnemonic. The other codes are grouped together to bound the cardinality.
+var rcodeLabels = map[string]string{
+	layers.DNSResponseCodeNoErr.String():    "NOERROR",
+	layers.DNSResponseCodeFormErr.String():  "FORMERR",
+	layers.DNSResponseCodeServFail.String(): "SERVFAIL",
+	layers.DNSResponseCodeNXDomain.String(): "NXDOMAIN",
+	layers.DNSResponseCodeNotImp.String():   "NOTIMP",
+	layers.DNSResponseCodeRefused.String():  "REFUSED",
+}
+
+var (
+	queriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gadget_dns_queries_total",
+			Help: "Number of DNS queries sent by the pods",
+		},
+		[]string{"namespace"},
+	)
+	responsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gadget_dns_responses_total",
+			Help: "Number of DNS responses received by the pods, by response code",
+		},
+		[]string{"namespace", "rcode"},
+	)
+	responseLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name: "gadget_dns_response_latency_seconds",
+			Help: "Time between the DNS queries sent by the pods and their responses",
+			// From 1ms to ~4s
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 13),
+		},
+		[]string{"namespace"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(queriesTotal, responsesTotal, responseLatency)
+}
+
+func rcodeLabel(rcode string) string {
+	if label, ok := rcodeLabels[rcode]; ok {
+		return label
+	}
+	return rcodeOther
+}
+
+// observe updates the metrics with a DNS packet of a pod of namespace. Only the
+// packets of the pods acting as clients are counted: the queries they send and
+// the responses they receive.
+func observe(namespace string, event *types.Event) {
+	switch event.Qr {
+	case types.DNSPktTypeQuery:
+		if event.PktType != "OUTGOING" {
+			return
+		}
+		queriesTotal.WithLabelValues(namespace).Inc()
+	case types.DNSPktTypeResponse:
+		if event.PktType != "HOST" {
+			return
+		}
+		responsesTotal.WithLabelValues(namespace, rcodeLabel(event.Rcode)).Inc()
+		// The latency is unknown when the query wasn't seen
+		if event.Latency > 0 {
+			responseLatency.WithLabelValues(namespace).Observe(event.Latency.Seconds())
+		}
+	}
+}
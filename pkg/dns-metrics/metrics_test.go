@@ -0,0 +1,50 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dnsmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+)
+
+func TestObserve(t *testing.T) {
+	events := []*types.Event{
+		// Client side
+		{Qr: types.DNSPktTypeQuery, PktType: "OUTGOING"},
+		{Qr: types.DNSPktTypeQuery, PktType: "OUTGOING"},
+		{Qr: types.DNSPktTypeResponse, PktType: "HOST", Rcode: "No Error", Latency: 3 * time.Millisecond},
+		{Qr: types.DNSPktTypeResponse, PktType: "HOST", Rcode: "Server Failure ", Latency: 2 * time.Second},
+		{Qr: types.DNSPktTypeResponse, PktType: "HOST", Rcode: "Bad OPT Version"},
+		// Server side
+		{Qr: types.DNSPktTypeQuery, PktType: "HOST"},
+		{Qr: types.DNSPktTypeResponse, PktType: "OUTGOING", Rcode: "No Error", Latency: time.Millisecond},
+	}
+	for _, event := range events {
+		observe("default", event)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(queriesTotal.WithLabelValues("default")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("default", "NOERROR")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("default", "SERVFAIL")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(responsesTotal.WithLabelValues("default", rcodeOther)))
+	assert.Equal(t, 3, testutil.CollectAndCount(responsesTotal))
+	// The response without latency isn't observed
+	assert.Equal(t, 1, testutil.CollectAndCount(responseLatency))
+}
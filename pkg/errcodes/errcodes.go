@@ -0,0 +1,175 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package errcodes classifies the errors that happen when loading and
+// attaching gadgets into stable codes (like IG-E-BTF-MISSING) with a one-line
+// remediation hint, so users don't have to search the web for the raw error.
+package errcodes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+)
+
+// Code identifies a class of errors
+type Code string
+
+const (
+	BTFMissing          Code = "IG-E-BTF-MISSING"
+	KprobeDenied        Code = "IG-E-KPROBE-DENIED"
+	KprobeSymbolMissing Code = "IG-E-KPROBE-SYMBOL-MISSING"
+	MemlockLimit        Code = "IG-E-MEMLOCK"
+	VerifierRejected    Code = "IG-E-VERIFIER"
+	TracefsMissing      Code = "IG-E-TRACEFS-MISSING"
+	KernelUnsupported   Code = "IG-E-KERNEL-UNSUPPORTED"
+	PermissionDenied    Code = "IG-E-PERMISSION-DENIED"
+	ResourceBusy        Code = "IG-E-RESOURCE-BUSY"
+)
+
+var hints = map[Code]string{
+	BTFMissing:          "the kernel doesn't provide BTF; enable CONFIG_DEBUG_INFO_BTF or use an ig build with BTFHub support (btfgen)",
+	KprobeDenied:        "attaching kprobes was denied; check that kernel lockdown is disabled and that CAP_PERFMON/CAP_SYS_ADMIN is granted",
+	KprobeSymbolMissing: "the kernel function isn't available on this kernel; the gadget may need a newer kernel or a different build",
+	MemlockLimit:        "the locked memory limit is too low; raise RLIMIT_MEMLOCK (ulimit -l unlimited) or use a kernel >= 5.11",
+	VerifierRejected:    "the eBPF verifier rejected a program; run with --verbose to see the verifier log",
+	TracefsMissing:      "tracefs/debugfs is not mounted; mount it on /sys/kernel/tracing or use --auto-mount-filesystems",
+	KernelUnsupported:   "the kernel lacks a feature required by the gadget; check the requirements for the minimum kernel version",
+	PermissionDenied:    "the operation was denied; run as root or grant CAP_BPF, CAP_PERFMON and CAP_SYS_RESOURCE",
+	ResourceBusy:        "a kernel resource was busy; retrying usually helps",
+}
+
+// Hint returns a one-line remediation hint for the code
+func (c Code) Hint() string {
+	return hints[c]
+}
+
+// Codes returns all the known codes, sorted
+func Codes() []Code {
+	codes := make([]Code, 0, len(hints))
+	for c := range hints {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Error wraps an error with its code. Its message starts with the code in
+// brackets, so the code survives being sent as a string (e.g. over gRPC or in
+// the status of a Trace).
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Code, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Classify returns the code matching err or an empty code if err isn't known
+func Classify(err error) Code {
+	if err == nil {
+		return ""
+	}
+
+	var codedErr *Error
+	if errors.As(err, &codedErr) {
+		return codedErr.Code
+	}
+
+	msg := err.Error()
+	var verifierErr *ebpf.VerifierError
+	switch {
+	case errors.As(err, &verifierErr):
+		return VerifierRejected
+	case strings.Contains(msg, "MEMLOCK"):
+		return MemlockLimit
+	case strings.Contains(msg, "tracefs") || strings.Contains(msg, "debugfs"):
+		return TracefsMissing
+	case errors.Is(err, ebpf.ErrNotSupported) && strings.Contains(msg, "BTF"):
+		return BTFMissing
+	case strings.Contains(msg, "kprobe") && errors.Is(err, os.ErrNotExist):
+		return KprobeSymbolMissing
+	case strings.Contains(msg, "kprobe") && errors.Is(err, os.ErrPermission):
+		return KprobeDenied
+	case errors.Is(err, ebpf.ErrNotSupported):
+		return KernelUnsupported
+	case errors.Is(err, os.ErrPermission):
+		return PermissionDenied
+	case errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EAGAIN):
+		return ResourceBusy
+	}
+	return ""
+}
+
+// Wrap adds the code of err to it. Unknown errors are returned as they are.
+func Wrap(err error) error {
+	var codedErr *Error
+	if errors.As(err, &codedErr) {
+		return err
+	}
+	if code := Classify(err); code != "" {
+		return &Error{Code: code, Err: err}
+	}
+	return err
+}
+
+var codeRegexp = regexp.MustCompile(`\[(IG-E-[A-Z0-9-]+)\]`)
+
+// FromMessage returns the code of an error that was converted to a string
+func FromMessage(msg string) Code {
+	m := codeRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return ""
+	}
+	return Code(m[1])
+}
+
+// HintFor returns the hint for an error, either from its code or from the
+// code found in its message. It returns an empty string if there is none.
+func HintFor(err error) string {
+	if err == nil {
+		return ""
+	}
+	code := Classify(err)
+	if code == "" {
+		code = FromMessage(err.Error())
+	}
+	return code.Hint()
+}
+
+// MaxVerifierLogLines is the number of lines of the verifier log kept by
+// VerifierLog. The last lines are the interesting ones, as they contain the
+// instruction the verifier rejected.
+const MaxVerifierLogLines = 100
+
+// VerifierLog returns the last maxLines lines of the verifier log carried by
+// err, or an empty string if err isn't a verifier error.
+func VerifierLog(err error, maxLines int) string {
+	var verifierErr *ebpf.VerifierError
+	if !errors.As(err, &verifierErr) {
+		return ""
+	}
+	return fmt.Sprintf("%-*v", maxLines, verifierErr)
+}
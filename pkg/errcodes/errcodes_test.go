@@ -0,0 +1,145 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errcodes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected Code
+	}{
+		{
+			name:     "nil",
+			err:      nil,
+			expected: "",
+		},
+		{
+			name:     "unknown",
+			err:      errors.New("something went wrong"),
+			expected: "",
+		},
+		{
+			name:     "memlock",
+			err:      fmt.Errorf("creating map: %w", errors.New("operation not permitted (MEMLOCK may be too low, consider rlimit.RemoveMemlock)")),
+			expected: MemlockLimit,
+		},
+		{
+			name:     "btf",
+			err:      fmt.Errorf("loading kernel BTF: %w", ebpf.ErrNotSupported),
+			expected: BTFMissing,
+		},
+		{
+			name:     "kprobe symbol",
+			err:      fmt.Errorf("creating kprobe: %w", os.ErrNotExist),
+			expected: KprobeSymbolMissing,
+		},
+		{
+			name:     "kprobe denied",
+			err:      fmt.Errorf("attaching kprobe: %w", syscall.EPERM),
+			expected: KprobeDenied,
+		},
+		{
+			name:     "tracefs",
+			err:      errors.New("neither debugfs nor tracefs are mounted"),
+			expected: TracefsMissing,
+		},
+		{
+			name:     "permission",
+			err:      fmt.Errorf("creating eBPF collection: %w", syscall.EPERM),
+			expected: PermissionDenied,
+		},
+		{
+			name:     "busy",
+			err:      fmt.Errorf("loading program: %w", syscall.EAGAIN),
+			expected: ResourceBusy,
+		},
+		{
+			name:     "already coded",
+			err:      fmt.Errorf("running gadget: %w", &Error{Code: VerifierRejected, Err: errors.New("invalid mem access")}),
+			expected: VerifierRejected,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, Classify(test.err))
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	err := fmt.Errorf("attaching kprobe: %w", os.ErrNotExist)
+
+	wrapped := Wrap(err)
+	assert.Equal(t, "[IG-E-KPROBE-SYMBOL-MISSING] attaching kprobe: file does not exist", wrapped.Error())
+	assert.ErrorIs(t, wrapped, os.ErrNotExist)
+
+	// Wrapping twice doesn't add the code again
+	assert.Equal(t, wrapped, Wrap(wrapped))
+
+	// Unknown errors are returned unchanged
+	unknown := errors.New("unknown")
+	assert.Equal(t, unknown, Wrap(unknown))
+}
+
+func TestFromMessage(t *testing.T) {
+	// The code survives the error being converted to a string, e.g. when it's
+	// sent over gRPC
+	msg := fmt.Sprintf("rpc error: %s", Wrap(fmt.Errorf("loading: %w", syscall.EBUSY)))
+	assert.Equal(t, ResourceBusy, FromMessage(msg))
+	assert.Equal(t, ResourceBusy.Hint(), HintFor(errors.New(msg)))
+
+	assert.Equal(t, Code(""), FromMessage("no code here"))
+	assert.Empty(t, HintFor(errors.New("no code here")))
+}
+
+func TestHints(t *testing.T) {
+	codes := Codes()
+	require.NotEmpty(t, codes)
+	for _, code := range codes {
+		assert.NotEmpty(t, code.Hint(), "code %s has no hint", code)
+	}
+}
+
+func TestVerifierLog(t *testing.T) {
+	verifierErr := &ebpf.VerifierError{
+		Cause: syscall.EACCES,
+		Log:   []string{"0: R1=ctx() R10=fp0", "1: (b7) r0 = 0", "R0 !read_ok"},
+	}
+	err := fmt.Errorf("loading maps and programs: %w", verifierErr)
+
+	assert.Equal(t, VerifierRejected, Classify(err))
+
+	verifierLog := VerifierLog(err, 2)
+	assert.Contains(t, verifierLog, "(1 line(s) omitted)")
+	assert.NotContains(t, verifierLog, "R10=fp0")
+	assert.Contains(t, verifierLog, "R0 !read_ok")
+
+	assert.Empty(t, VerifierLog(errors.New("not a verifier error"), 2))
+}
@@ -0,0 +1,279 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventschema generates the JSON Schema of the events the built-in
+// gadgets print with -o json, so log pipelines can validate and parse them.
+//
+// The schema of a gadget is derived by reflection from the Go type of its
+// events, following the rules of encoding/json, and the descriptions come
+// from the columns of the gadget. Every schema carries SchemaVersion, which
+// is increased when the output of a gadget changes in a way that can break
+// consumers, i.e. a field is removed, renamed or changes type. Adding fields
+// doesn't change it.
+package eventschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+)
+
+const (
+	// SchemaVersion is the version of the event schemas, see the package
+	// documentation
+	SchemaVersion = 1
+
+	// Draft is the JSON Schema dialect of the schemas
+	Draft = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// Schema is the subset of JSON Schema used to describe events
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Type is either a string or a list of strings when the value can be
+	// null
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+
+	// Column is the name of the column showing the field, for -o columns
+	// and the filters
+	Column string `json:"x-column,omitempty"`
+	// Version is SchemaVersion, only set at the root
+	Version int `json:"x-schema-version,omitempty"`
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// ID returns the identifier of the schema of the events of a gadget
+func ID(category, name string) string {
+	if category == "" {
+		return fmt.Sprintf("urn:inspektor-gadget:event:%s:v%d", name, SchemaVersion)
+	}
+	return fmt.Sprintf("urn:inspektor-gadget:event:%s:%s:v%d", category, name, SchemaVersion)
+}
+
+// ForGadget returns the schema of the events of gadget. It fails if the
+// gadget doesn't provide the type of its events.
+func ForGadget(gadget gadgets.GadgetDesc) (*Schema, error) {
+	prototype := gadget.EventPrototype()
+	if prototype == nil {
+		return nil, fmt.Errorf("gadget %s %s has no event type", gadget.Category(), gadget.Name())
+	}
+
+	var cols []columns.Attributes
+	if parser := gadget.Parser(); parser != nil {
+		cols = parser.GetColumnAttributes()
+	}
+
+	s := New(reflect.TypeOf(prototype), cols)
+	s.Schema = Draft
+	s.ID = ID(gadget.Category(), gadget.Name())
+	s.Title = strings.TrimSpace(gadget.Category() + " " + gadget.Name())
+	s.Description = gadget.Description()
+	s.Version = SchemaVersion
+
+	return s, nil
+}
+
+// New returns the schema of the JSON encoding of values of type t. The
+// fields whose path matches the name of one of cols get its description.
+func New(t reflect.Type, cols []columns.Attributes) *Schema {
+	g := &generator{
+		columns:  make(map[string]*columns.Attributes, len(cols)),
+		visiting: make(map[reflect.Type]bool),
+	}
+	for i := range cols {
+		g.columns[strings.ToLower(cols[i].Name)] = &cols[i]
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return g.schema(t, "")
+}
+
+type generator struct {
+	columns  map[string]*columns.Attributes
+	visiting map[reflect.Type]bool
+}
+
+func (g *generator) schema(t reflect.Type, path string) *Schema {
+	s := g.typeSchema(t, path)
+	if col, ok := g.columns[strings.ToLower(path)]; ok && s.Properties == nil {
+		s.Column = col.Name
+		s.Description = col.Description
+	}
+	return s
+}
+
+func (g *generator) typeSchema(t reflect.Type, path string) *Schema {
+	// Same order as encoding/json: its own marshalers first
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		// Anything can be produced
+		return &Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := int64(0)
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Pointer:
+		s := g.schema(t.Elem(), path)
+		s.Type = nullable(s.Type)
+		return s
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: []string{"string", "null"}, ContentEncoding: "base64"}
+		}
+		return &Schema{Type: []string{"array", "null"}, Items: g.typeSchema(t.Elem(), path)}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.typeSchema(t.Elem(), path)}
+	case reflect.Map:
+		return &Schema{Type: []string{"object", "null"}, AdditionalProperties: g.typeSchema(t.Elem(), path)}
+	case reflect.Struct:
+		if g.visiting[t] {
+			// Recursive type, don't describe it again
+			return &Schema{Type: "object"}
+		}
+		g.visiting[t] = true
+		defer delete(g.visiting, t)
+
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		g.addFields(s, t, path)
+		return s
+	default:
+		// Interfaces, or types encoding/json can't marshal
+		return &Schema{}
+	}
+}
+
+// addFields adds the fields of the struct t to s, inlining the embedded
+// structs without a JSON name like encoding/json does. As with encoding/json,
+// the fields of t take precedence over the ones of the embedded structs.
+func (g *generator) addFields(s *Schema, t reflect.Type, path string) {
+	var inlined []reflect.Type
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				inlined = append(inlined, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		s.Properties[name] = g.schema(ft, fieldPath)
+
+		omitempty := false
+		for _, opt := range strings.Split(opts, ",") {
+			omitempty = omitempty || opt == "omitempty"
+		}
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	for _, it := range inlined {
+		embedded := &Schema{Properties: make(map[string]*Schema)}
+		g.addFields(embedded, it, path)
+		names := make([]string, 0, len(embedded.Properties))
+		for name := range embedded.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = embedded.Properties[name]
+				if slices.Contains(embedded.Required, name) {
+					s.Required = append(s.Required, name)
+				}
+			}
+		}
+	}
+}
+
+func nullable(typ any) any {
+	switch typ := typ.(type) {
+	case string:
+		return []string{typ, "null"}
+	case []string:
+		for _, t := range typ {
+			if t == "null" {
+				return typ
+			}
+		}
+		return append(typ, "null")
+	default:
+		// No type restriction, null is already allowed
+		return typ
+	}
+}
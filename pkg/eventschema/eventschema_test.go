@@ -0,0 +1,99 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventschema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+)
+
+type embedded struct {
+	Name  string `json:"name"`
+	Extra string `json:"extra,omitempty"`
+}
+
+type node struct {
+	Next *node `json:"next,omitempty"`
+}
+
+type event struct {
+	embedded
+	Name      uint32            `json:"name2"`
+	Shadowed  string            `json:"extra"`
+	Timestamp time.Time         `json:"timestamp"`
+	Args      []string          `json:"args,omitempty"`
+	Data      []byte            `json:"data,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Node      *node             `json:"node,omitempty"`
+	Ignored   string            `json:"-"`
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	s := New(reflect.TypeOf(&event{}), []columns.Attributes{
+		{Name: "name", Description: "The name"},
+	})
+
+	require.Equal(t, "object", s.Type)
+	require.ElementsMatch(t, []string{"name", "name2", "extra", "timestamp", "args", "data", "labels", "node"}, keys(s.Properties))
+	require.Equal(t, []string{"name2", "extra", "timestamp", "name"}, s.Required)
+
+	require.Equal(t, "The name", s.Properties["name"].Description)
+	require.Equal(t, "name", s.Properties["name"].Column)
+
+	require.Equal(t, "integer", s.Properties["name2"].Type)
+	require.Equal(t, int64(0), *s.Properties["name2"].Minimum)
+
+	// Fields of the struct take precedence over embedded ones
+	require.Equal(t, "string", s.Properties["extra"].Type)
+	require.Empty(t, s.Properties["extra"].Column)
+
+	require.Equal(t, "date-time", s.Properties["timestamp"].Format)
+
+	require.Equal(t, []string{"array", "null"}, s.Properties["args"].Type)
+	require.Equal(t, "string", s.Properties["args"].Items.Type)
+
+	require.Equal(t, []string{"string", "null"}, s.Properties["data"].Type)
+	require.Equal(t, "base64", s.Properties["data"].ContentEncoding)
+
+	require.Equal(t, []string{"object", "null"}, s.Properties["labels"].Type)
+	require.Equal(t, "string", s.Properties["labels"].AdditionalProperties.Type)
+
+	// Recursive types stop at the first repetition
+	require.Equal(t, []string{"object", "null"}, s.Properties["node"].Type)
+	require.Equal(t, []string{"object", "null"}, s.Properties["node"].Properties["next"].Type)
+	require.Nil(t, s.Properties["node"].Properties["next"].Properties)
+}
+
+func TestID(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "urn:inspektor-gadget:event:trace:exec:v1", ID("trace", "exec"))
+	require.Equal(t, "urn:inspektor-gadget:event:traceloop:v1", ID("", "traceloop"))
+}
+
+func keys(m map[string]*Schema) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	return ret
+}
@@ -0,0 +1,249 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gadgetbuilder compiles gadgets inside the cluster. The client stores
+// the sources of the gadget in a ConfigMap and starts a Job on each node. The
+// Job compiles the eBPF program against the BTF of its node and writes the
+// object to a host directory, from where the gadget pod of that node imports
+// it as an image into its local OCI store. The result of each node is
+// recorded in the ConfigMap.
+package gadgetbuilder
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConfigMapType is the value of the "type" label of build ConfigMaps
+	ConfigMapType = "gadget-build"
+
+	// BuildsDir is the directory of the host where the Jobs store the
+	// compiled objects
+	BuildsDir = "/var/lib/inspektor-gadget/builds"
+
+	// DefaultBuilderImage contains the toolchain used to compile gadgets
+	DefaultBuilderImage = "ghcr.io/inspektor-gadget/ebpf-builder:latest"
+
+	// MaxSourcesSize is the maximum size of the sources of a gadget, as they
+	// need to fit in a ConfigMap
+	MaxSourcesSize = 900 * 1024
+
+	// InfoKey is the key of the ConfigMap containing the BuildInfo
+	InfoKey = "build.json"
+	// ScriptKey is the key of the ConfigMap containing the build script
+	ScriptKey = "build.sh"
+	// ResultKeyPrefix prefixes the keys of the ConfigMap containing the
+	// Result of each node
+	ResultKeyPrefix = "result."
+
+	// CompleteFile and FailedFile are created by the Job in the build
+	// directory once the compilation succeeded or failed. FailedFile
+	// contains the end of the compiler output.
+	CompleteFile = ".complete"
+	FailedFile   = ".failed"
+
+	typeLabel = "type"
+)
+
+// BuildInfo describes the gadget to build
+type BuildInfo struct {
+	Image      string `json:"image"`
+	EBPFSource string `json:"ebpfSource"`
+	Metadata   string `json:"metadata,omitempty"`
+	CFlags     string `json:"cflags,omitempty"`
+}
+
+// Result is the outcome of a build on a node
+type Result struct {
+	Digest string `json:"digest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// buildScript runs in the builder image. It uses the BTF of the node, if
+// available, instead of the generic vmlinux.h shipped with the builder image.
+const buildScript = `set -eu
+case "$(uname -m)" in
+x86_64) ARCH=amd64; TARGET_ARCH=x86 ;;
+aarch64) ARCH=arm64; TARGET_ARCH=arm64 ;;
+*) echo "unsupported architecture $(uname -m)" | tee /out/.failed; exit 1 ;;
+esac
+mkdir -p /tmp/btf
+if [ -r /sys/kernel/btf/vmlinux ]; then
+	bpftool btf dump file /sys/kernel/btf/vmlinux format c > /tmp/btf/vmlinux.h
+fi
+cp /src/* /out/
+cd /out
+if ! clang -target bpf -Wall -g -O2 ${CFLAGS} -D __TARGET_ARCH_${TARGET_ARCH} -I /tmp/btf \
+	-c "${EBPFSOURCE}" -I /usr/include/gadget/${ARCH}/ -o ${ARCH}.bpf.o > /tmp/build.log 2>&1; then
+	cat /tmp/build.log
+	tail -c 4096 /tmp/build.log > /out/.failed
+	exit 1
+fi
+llvm-strip -g ${ARCH}.bpf.o
+touch /out/.complete
+`
+
+// ResultKey returns the key of the ConfigMap containing the result of node
+func ResultKey(node string) string {
+	return ResultKeyPrefix + node
+}
+
+// NewConfigMap returns the ConfigMap holding the sources of a build
+func NewConfigMap(name, namespace string, info *BuildInfo, files map[string][]byte) (*corev1.ConfigMap, error) {
+	size := 0
+	for _, content := range files {
+		size += len(content)
+	}
+	if size > MaxSourcesSize {
+		return nil, fmt.Errorf("sources are too big (%d bytes, maximum is %d)", size, MaxSourcesSize)
+	}
+
+	infoJSON, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling build info: %w", err)
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				typeLabel: ConfigMapType,
+			},
+		},
+		Data: map[string]string{
+			InfoKey:   string(infoJSON),
+			ScriptKey: buildScript,
+		},
+		BinaryData: files,
+	}, nil
+}
+
+// GetBuildInfo returns the BuildInfo stored in a build ConfigMap
+func GetBuildInfo(cm *corev1.ConfigMap) (*BuildInfo, error) {
+	info := &BuildInfo{}
+	if err := json.Unmarshal([]byte(cm.Data[InfoKey]), info); err != nil {
+		return nil, fmt.Errorf("unmarshaling build info of %s: %w", cm.Name, err)
+	}
+	if info.Image == "" || info.EBPFSource == "" {
+		return nil, fmt.Errorf("build info of %s is incomplete", cm.Name)
+	}
+	return info, nil
+}
+
+// GetResults returns the results recorded in a build ConfigMap by node
+func GetResults(cm *corev1.ConfigMap) map[string]*Result {
+	results := make(map[string]*Result)
+	for key, value := range cm.Data {
+		node, ok := strings.CutPrefix(key, ResultKeyPrefix)
+		if !ok {
+			continue
+		}
+		result := &Result{}
+		if err := json.Unmarshal([]byte(value), result); err != nil {
+			result.Error = fmt.Sprintf("invalid result: %s", err)
+		}
+		results[node] = result
+	}
+	return results
+}
+
+// NewJob returns the Job compiling the sources of cm on node
+func NewJob(cm *corev1.ConfigMap, info *BuildInfo, jobName, node, builderImage string) *batchv1.Job {
+	backoffLimit := int32(0)
+	ttl := int32(300)
+	hostPathDirOrCreate := corev1.HostPathDirectoryOrCreate
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: cm.Namespace,
+			Labels: map[string]string{
+				typeLabel: ConfigMapType,
+			},
+			// Delete the Job with the ConfigMap
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       cm.Name,
+					UID:        cm.UID,
+				},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					NodeName:      node,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Tolerations: []corev1.Toleration{
+						{Operator: corev1.TolerationOpExists},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:    "builder",
+							Image:   builderImage,
+							Command: []string{"/bin/sh", filepath.Join("/src", ScriptKey)},
+							Env: []corev1.EnvVar{
+								{Name: "EBPFSOURCE", Value: info.EBPFSource},
+								{Name: "CFLAGS", Value: info.CFlags},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "src", MountPath: "/src", ReadOnly: true},
+								{Name: "out", MountPath: "/out"},
+								{Name: "btf", MountPath: "/sys/kernel/btf", ReadOnly: true},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "src",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+								},
+							},
+						},
+						{
+							Name: "out",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: filepath.Join(BuildsDir, cm.Name),
+									Type: &hostPathDirOrCreate,
+								},
+							},
+						},
+						{
+							Name: "btf",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: "/sys/kernel/btf",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
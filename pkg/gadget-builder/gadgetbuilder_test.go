@@ -0,0 +1,112 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetbuilder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMap(t *testing.T) {
+	info := &BuildInfo{Image: "mygadget:latest", EBPFSource: "program.bpf.c"}
+
+	_, err := NewConfigMap("build", "gadget", info, map[string][]byte{
+		"program.bpf.c": make([]byte, MaxSourcesSize+1),
+	})
+	require.Error(t, err)
+
+	cm, err := NewConfigMap("build", "gadget", info, map[string][]byte{
+		"program.bpf.c": []byte("int x;"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ConfigMapType, cm.Labels["type"])
+	assert.Contains(t, cm.Data, ScriptKey)
+
+	got, err := GetBuildInfo(cm)
+	require.NoError(t, err)
+	assert.Equal(t, info, got)
+
+	cm.Data[ResultKey("node1")] = `{"digest":"sha256:1234"}`
+	cm.Data[ResultKey("node2")] = `{"error":"compilation failed"}`
+	cm.Data[ResultKey("node3")] = `invalid`
+	results := GetResults(cm)
+	require.Len(t, results, 3)
+	assert.Equal(t, "sha256:1234", results["node1"].Digest)
+	assert.Equal(t, "compilation failed", results["node2"].Error)
+	assert.Contains(t, results["node3"].Error, "invalid result")
+}
+
+func TestJob(t *testing.T) {
+	info := &BuildInfo{Image: "mygadget:latest", EBPFSource: "program.bpf.c", CFlags: "-DFOO"}
+	cm, err := NewConfigMap("build", "gadget", info, nil)
+	require.NoError(t, err)
+	cm.UID = types.UID("1234")
+
+	job := NewJob(cm, info, "build-0", "node1", DefaultBuilderImage)
+	assert.Equal(t, "gadget", job.Namespace)
+	require.Len(t, job.OwnerReferences, 1)
+	assert.Equal(t, cm.UID, job.OwnerReferences[0].UID)
+
+	spec := job.Spec.Template.Spec
+	assert.Equal(t, "node1", spec.NodeName)
+	require.Len(t, spec.Containers, 1)
+	assert.Equal(t, DefaultBuilderImage, spec.Containers[0].Image)
+	assert.Equal(t, "program.bpf.c", spec.Containers[0].Env[0].Value)
+	assert.Equal(t, "-DFOO", spec.Containers[0].Env[1].Value)
+
+	for _, volume := range spec.Volumes {
+		if volume.Name == "out" {
+			assert.Equal(t, filepath.Join(BuildsDir, "build"), volume.HostPath.Path)
+		}
+	}
+}
+
+func TestImporterFailedBuild(t *testing.T) {
+	ctx := context.Background()
+
+	info := &BuildInfo{Image: "mygadget:latest", EBPFSource: "program.bpf.c"}
+	cm, err := NewConfigMap("build", "gadget", info, nil)
+	require.NoError(t, err)
+	client := fake.NewSimpleClientset(cm)
+
+	buildsDir := t.TempDir()
+	buildDir := filepath.Join(buildsDir, "build")
+	require.NoError(t, os.Mkdir(buildDir, 0o755))
+
+	importer := NewImporter(client, "gadget", "node1")
+	importer.buildsDir = buildsDir
+
+	// Builds in progress are left alone
+	importer.poll(ctx)
+	assert.DirExists(t, buildDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(buildDir, FailedFile), []byte("error: unknown type\n"), 0o644))
+	importer.poll(ctx)
+	assert.NoDirExists(t, buildDir)
+
+	cm, err = client.CoreV1().ConfigMaps("gadget").Get(ctx, "build", metav1.GetOptions{})
+	require.NoError(t, err)
+	results := GetResults(cm)
+	require.Contains(t, results, "node1")
+	assert.Equal(t, "compilation failed:\nerror: unknown type", results["node1"].Error)
+}
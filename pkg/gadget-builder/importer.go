@@ -0,0 +1,177 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+const defaultPollInterval = 2 * time.Second
+
+// Importer runs in the gadget pod. It imports the gadgets compiled by the
+// Jobs of its node into the local OCI store and records the results in the
+// build ConfigMaps.
+type Importer struct {
+	client    kubernetes.Interface
+	namespace string
+	node      string
+	buildsDir string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewImporter creates an Importer for the builds of node in namespace
+func NewImporter(client kubernetes.Interface, namespace, node string) *Importer {
+	return &Importer{
+		client:    client,
+		namespace: namespace,
+		node:      node,
+		buildsDir: filepath.Join(host.HostRoot, BuildsDir),
+	}
+}
+
+// Start starts polling for finished builds
+func (i *Importer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	i.cancel = cancel
+	i.done = make(chan struct{})
+
+	go func() {
+		defer close(i.done)
+		ticker := time.NewTicker(defaultPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				i.poll(ctx)
+			}
+		}
+	}()
+}
+
+// Stop stops the Importer and waits for the current import to finish
+func (i *Importer) Stop() {
+	if i.cancel == nil {
+		return
+	}
+	i.cancel()
+	<-i.done
+}
+
+func (i *Importer) poll(ctx context.Context) {
+	entries, err := os.ReadDir(i.buildsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Reading builds directory: %v", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		buildDir := filepath.Join(i.buildsDir, entry.Name())
+		result, finished := i.importBuild(ctx, entry.Name(), buildDir)
+		if !finished {
+			continue
+		}
+		if err := i.recordResult(ctx, entry.Name(), result); err != nil {
+			log.Warnf("Recording result of build %q: %v", entry.Name(), err)
+		}
+		if err := os.RemoveAll(buildDir); err != nil {
+			log.Warnf("Removing build directory %q: %v", buildDir, err)
+		}
+	}
+}
+
+// importBuild returns the result of the build in buildDir and whether it
+// finished
+func (i *Importer) importBuild(ctx context.Context, name, buildDir string) (*Result, bool) {
+	if output, err := os.ReadFile(filepath.Join(buildDir, FailedFile)); err == nil {
+		return &Result{Error: fmt.Sprintf("compilation failed:\n%s", strings.TrimSpace(string(output)))}, true
+	}
+	if _, err := os.Stat(filepath.Join(buildDir, CompleteFile)); err != nil {
+		return nil, false
+	}
+
+	cm, err := i.client.CoreV1().ConfigMaps(i.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		// Without the ConfigMap there is nobody to report to anymore
+		log.Warnf("Getting build %q: %v", name, err)
+		return nil, true
+	}
+	info, err := GetBuildInfo(cm)
+	if err != nil {
+		return &Result{Error: err.Error()}, true
+	}
+
+	opts := &oci.BuildGadgetImageOpts{
+		EBPFSourcePath: filepath.Join(buildDir, info.EBPFSource),
+		ObjectPaths: map[string]*oci.ObjectPath{
+			runtime.GOARCH: {
+				EBPF: filepath.Join(buildDir, runtime.GOARCH+".bpf.o"),
+			},
+		},
+		CreatedDate: time.Now().Format(time.RFC3339),
+	}
+	if info.Metadata != "" {
+		opts.MetadataPath = filepath.Join(buildDir, info.Metadata)
+	}
+
+	desc, err := oci.BuildGadgetImage(ctx, opts, info.Image)
+	if err != nil {
+		return &Result{Error: fmt.Sprintf("creating image: %s", err)}, true
+	}
+	log.Infof("Imported gadget %q (%s) built in-cluster", info.Image, desc.Digest)
+	return &Result{Digest: desc.Digest}, true
+}
+
+func (i *Importer) recordResult(ctx context.Context, name string, result *Result) error {
+	if result == nil {
+		return nil
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshaling result: %w", err)
+	}
+	patch, err := json.Marshal(&corev1.ConfigMap{
+		Data: map[string]string{ResultKey(i.node): string(resultJSON)},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling patch: %w", err)
+	}
+	_, err = i.client.CoreV1().ConfigMaps(i.namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
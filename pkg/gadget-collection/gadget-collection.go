@@ -17,6 +17,7 @@ package gadgetcollection
 import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	seccomp "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/advise/seccomp"
+	auditfile "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/audit/file"
 	auditseccomp "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/audit/seccomp"
 	biolatency "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/profile/block-io"
 	profile "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/profile/cpu"
@@ -44,6 +45,7 @@ import (
 
 func TraceFactories() map[string]gadgets.TraceFactory {
 	return map[string]gadgets.TraceFactory{
+		"audit-file":        auditfile.NewFactory(),
 		"audit-seccomp":     auditseccomp.NewFactory(),
 		"bindsnoop":         bindsnoop.NewFactory(),
 		"biolatency":        biolatency.NewFactory(),
@@ -22,11 +22,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	seccompprofile "sigs.k8s.io/security-profiles-operator/api/seccompprofile/v1beta1"
 	k8syaml "sigs.k8s.io/yaml"
@@ -109,6 +111,12 @@ SeccompProfiles will have the following annotations:
 SeccompProfiles will have the same labels as the Trace custom resource that
 generated them. They don't have meaning for the seccomp gadget. They are
 merely copied for convenience.
+
+Trace.Spec.Output can be a template like
+` + "`seccomp-{{.Namespace}}-{{.Pod}}-{{.Date}}`" + ` so profiles generated by different
+runs don't overwrite each other. The variables are Namespace, Pod, Container,
+Node, Trace, Date (2006-01-02), Time (150405) and Timestamp (seconds since the
+epoch). Templated names are used as they are instead of as a prefix.
 `
 }
 
@@ -299,16 +307,31 @@ func getSeccompProfileNsName(
 // generateSeccompPolicy generates a seccomp policy which is ready to be
 // created.
 func generateSeccompPolicy(client client.Client, trace *gadgetv1alpha1.Trace, syscallNames []string, podname, containername, fullPodName string, ownerReference *metav1.OwnerReference) (*seccompprofile.SeccompProfile, error) {
+	podNamespace, _, _ := strings.Cut(fullPodName, "/")
+	outputName, err := gadgets.RenderOutputName(trace.Spec.Output,
+		gadgets.NewOutputNameData(trace, podNamespace, podname, containername, time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
 	profileName, err := getSeccompProfileNsName(
 		client,
 		trace.ObjectMeta.Namespace,
-		trace.Spec.Output,
+		outputName,
 		podname,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("getting the profile name: %w", err)
 	}
 
+	// Templated names are used as they are, so they are predictable
+	if gadgets.IsOutputNameTemplate(trace.Spec.Output) {
+		if errs := validation.IsDNS1123Subdomain(profileName.name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid profile name %q: %s", profileName.name, strings.Join(errs, ", "))
+		}
+		profileName.generateName = false
+	}
+
 	r := syscallNamesToSeccompPolicy(profileName, syscallNames)
 	seccompProfileAddLabelsAndAnnotations(r, trace, fullPodName, containername, ownerReference)
 
@@ -388,6 +411,10 @@ func getContainerOwnerReference(c *containercollection.Container) *metav1.OwnerR
 
 func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.Output = ""
+	if err := gadgets.ValidateOutputName(trace.Spec.Output); err != nil {
+		trace.Status.OperationError = err.Error()
+		return
+	}
 	if t.started {
 		trace.Status.State = gadgetv1alpha1.TraceStateStarted
 		t.policyGenerated = false
@@ -0,0 +1,208 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auditfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
+	auditfiletracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/tracer"
+	types "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type Trace struct {
+	helpers gadgets.GadgetHelpers
+	tracer  *auditfiletracer.Tracer
+
+	started bool
+}
+
+type TraceFactory struct {
+	gadgets.BaseFactory
+}
+
+func NewFactory() gadgets.TraceFactory {
+	return &TraceFactory{
+		BaseFactory: gadgets.BaseFactory{DeleteTrace: deleteTrace},
+	}
+}
+
+func (f *TraceFactory) Description() string {
+	return `The Audit File gadget guards the files opened and the binaries executed in
+the selected containers with lists of allowed and denied paths given as
+parameters:
+
+* allow-open and allow-exec: when set, only these paths can be opened or
+  executed.
+* deny-open and deny-exec: these paths cannot be opened or executed.
+* kill-open and kill-exec: these paths cannot be opened or executed either,
+  and the processes trying to are killed.
+
+Lists are comma-separated. Paths ending with a slash match all the paths under
+them, the longest path matching a file decides. The accesses breaking the rules
+are reported. With enforce=true, which requires a container selector, the
+action of the matching rule is also taken: the access is denied with EPERM,
+which requires a kernel with BPF LSM enabled, or the process is killed with
+SIGKILL for the kill rules. At most max-kills processes (10 by default, 0 for
+no limit) are killed, the accesses are only denied afterwards. Otherwise, the
+gadget only reports them.
+
+With dry-run=true, the gadget only reports whether the accesses would have
+been denied or the processes killed.
+`
+}
+
+func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
+	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
+		gadgetv1alpha1.TraceOutputModeStream: {},
+	}
+}
+
+func deleteTrace(name string, t interface{}) {
+	trace := t.(*Trace)
+	if trace.started {
+		trace.tracer.Close()
+		trace.tracer = nil
+	}
+}
+
+func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOperation {
+	n := func() interface{} {
+		return &Trace{
+			helpers: f.Helpers,
+		}
+	}
+	return map[gadgetv1alpha1.Operation]gadgets.TraceOperation{
+		gadgetv1alpha1.OperationStart: {
+			Doc: "Start audit file",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Start(trace)
+			},
+		},
+		gadgetv1alpha1.OperationStop: {
+			Doc: "Stop audit file",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Stop(trace)
+			},
+		},
+	}
+}
+
+func pathsParam(params map[string]string, key string) []string {
+	if val := params[key]; val != "" {
+		return strings.Split(val, ",")
+	}
+	return nil
+}
+
+func boolParam(params map[string]string, key string) (bool, error) {
+	val, ok := params[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("%q is not valid for %q", val, key)
+	}
+	return b, nil
+}
+
+func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
+	if t.started {
+		trace.Status.State = gadgetv1alpha1.TraceStateStarted
+		return
+	}
+
+	params := trace.Spec.Parameters
+	flags := make(map[string]bool)
+	for _, key := range []string{
+		auditfiletracer.ParamEnforce,
+		auditfiletracer.ParamDryRun,
+	} {
+		b, err := boolParam(params, key)
+		if err != nil {
+			trace.Status.OperationError = err.Error()
+			return
+		}
+		flags[key] = b
+	}
+
+	maxKills := uint64(10)
+	if val, ok := params[auditfiletracer.ParamMaxKills]; ok {
+		var err error
+		maxKills, err = strconv.ParseUint(val, 10, 32)
+		if err != nil {
+			trace.Status.OperationError = fmt.Sprintf("%q is not valid for %q", val, auditfiletracer.ParamMaxKills)
+			return
+		}
+	}
+
+	traceName := gadgets.TraceName(trace.ObjectMeta.Namespace, trace.ObjectMeta.Name)
+	eventCallback := func(event *types.Event) {
+		event.K8s.Node = trace.Spec.Node
+
+		t.helpers.PublishEvent(
+			traceName,
+			eventtypes.EventString(event),
+		)
+	}
+
+	var err error
+
+	mountNsMap, err := t.helpers.TracerMountNsMap(traceName)
+	if err != nil {
+		trace.Status.OperationError = fmt.Sprintf("failed to find tracer's mount ns map: %s", err)
+		return
+	}
+
+	config := &auditfiletracer.Config{
+		MountnsMap: mountNsMap,
+		Enforce:    flags[auditfiletracer.ParamEnforce],
+		MaxKills:   uint32(maxKills),
+		DryRun:     flags[auditfiletracer.ParamDryRun],
+		AllowOpen:  pathsParam(params, auditfiletracer.ParamAllowOpen),
+		DenyOpen:   pathsParam(params, auditfiletracer.ParamDenyOpen),
+		KillOpen:   pathsParam(params, auditfiletracer.ParamKillOpen),
+		AllowExec:  pathsParam(params, auditfiletracer.ParamAllowExec),
+		DenyExec:   pathsParam(params, auditfiletracer.ParamDenyExec),
+		KillExec:   pathsParam(params, auditfiletracer.ParamKillExec),
+	}
+	t.tracer, err = auditfiletracer.NewTracer(config, t.helpers, eventCallback)
+	if err != nil {
+		trace.Status.OperationError = fmt.Sprintf("Failed to start audit file tracer: %s", err)
+		return
+	}
+	t.started = true
+
+	trace.Status.State = gadgetv1alpha1.TraceStateStarted
+}
+
+func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	t.tracer.Close()
+	t.tracer = nil
+
+	t.started = false
+
+	trace.Status.State = gadgetv1alpha1.TraceStateStopped
+}
@@ -15,10 +15,19 @@
 package gadgets
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
 	k8sTypes "k8s.io/apimachinery/pkg/types"
 
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/errcodes"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -30,6 +39,86 @@ func TraceNameFromNamespacedName(n k8sTypes.NamespacedName) string {
 	return TraceName(n.Namespace, n.Name)
 }
 
+// IsTransientError returns whether err is likely to go away if the operation
+// is retried, e.g. because the verifier or a pinned map was busy.
+func IsTransientError(err error) bool {
+	return errors.Is(err, unix.EAGAIN) ||
+		errors.Is(err, unix.EBUSY) ||
+		errors.Is(err, unix.EEXIST) ||
+		errors.Is(err, unix.EINTR)
+}
+
+// SetOperationError sets the operation error of the trace to "msg: err",
+// along with its error code and verifier log, and flags it as transient if
+// retrying the operation could succeed.
+func SetOperationError(trace *gadgetv1alpha1.Trace, msg string, err error) {
+	trace.Status.OperationError = fmt.Sprintf("%s: %s", msg, err)
+	trace.Status.OperationErrorCode = string(errcodes.Classify(err))
+	trace.Status.VerifierLog = errcodes.VerifierLog(err, errcodes.MaxVerifierLogLines)
+	trace.Status.OperationErrorTransient = IsTransientError(err)
+}
+
+// ValidateParameters checks the parameters of a trace against the parameters
+// the gadget accepts: unknown parameters, missing mandatory ones and invalid
+// values are reported.
+func ValidateParameters(descs params.ParamDescs, parameters map[string]string) error {
+	known := make(map[string]*params.ParamDesc, len(descs))
+	for _, desc := range descs {
+		known[desc.Key] = desc
+	}
+
+	keys := make([]string, 0, len(parameters))
+	for key := range parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		desc, ok := known[key]
+		if !ok {
+			validKeys := make([]string, 0, len(descs))
+			for _, desc := range descs {
+				validKeys = append(validKeys, desc.Key)
+			}
+			sort.Strings(validKeys)
+			return fmt.Errorf("unknown parameter %q, valid parameters are: %s", key, strings.Join(validKeys, ", "))
+		}
+		if err := desc.Validate(parameters[key]); err != nil {
+			return err
+		}
+	}
+
+	for _, desc := range descs {
+		if _, ok := parameters[desc.Key]; !ok && desc.IsMandatory {
+			return fmt.Errorf("missing parameter %q", desc.Key)
+		}
+	}
+
+	return nil
+}
+
+// TopParamDescs returns the parameters shared by the top gadgets
+func TopParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          top.MaxRowsParam,
+			Description:  "Maximum number of rows to return",
+			DefaultValue: fmt.Sprint(top.MaxRowsDefault),
+			TypeHint:     params.TypeInt,
+		},
+		{
+			Key:          top.IntervalParam,
+			Description:  "Interval (in seconds)",
+			DefaultValue: fmt.Sprint(top.IntervalDefault),
+			TypeHint:     params.TypeInt,
+		},
+		{
+			Key:         top.SortByParam,
+			Description: "Sort by columns. Join multiple columns with ','. Prefix a column with '-' to sort in descending order",
+		},
+	}
+}
+
 func ContainerSelectorFromContainerFilter(f *gadgetv1alpha1.ContainerFilter) *containercollection.ContainerSelector {
 	if f == nil {
 		return &containercollection.ContainerSelector{}
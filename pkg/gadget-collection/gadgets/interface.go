@@ -24,6 +24,7 @@ import (
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 
 	log "github.com/sirupsen/logrus"
 	apimachineryruntime "k8s.io/apimachinery/pkg/runtime"
@@ -62,6 +63,13 @@ type TraceFactoryWithDocumentation interface {
 	Description() string
 }
 
+type TraceFactoryWithParams interface {
+	// ParamDescs describes the parameters the gadget accepts in
+	// Trace.Spec.Parameters. The Trace controller validates them before
+	// calling any operation.
+	ParamDescs() params.ParamDescs
+}
+
 // TraceOperation packages an operation on a gadget that users can call via the
 // annotation gadget.kinvolk.io/operation.
 type TraceOperation struct {
@@ -0,0 +1,92 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+)
+
+// OutputNameData contains the variables available in templated output names,
+// like "seccomp-{{.Namespace}}-{{.Pod}}-{{.Date}}"
+type OutputNameData struct {
+	// Namespace, Pod and Container identify the container the output is
+	// generated for
+	Namespace string
+	Pod       string
+	Container string
+
+	// Node is the node the trace runs on and Trace the name of the trace
+	Node  string
+	Trace string
+
+	// Date ("2006-01-02"), Time ("150405") and Timestamp (seconds since the
+	// epoch) are the time at which the output is generated
+	Date      string
+	Time      string
+	Timestamp int64
+}
+
+// NewOutputNameData returns the variables to render the output name of trace
+// for the given container at time now
+func NewOutputNameData(trace *gadgetv1alpha1.Trace, namespace, pod, container string, now time.Time) *OutputNameData {
+	now = now.UTC()
+	return &OutputNameData{
+		Namespace: namespace,
+		Pod:       pod,
+		Container: container,
+		Node:      trace.Spec.Node,
+		Trace:     trace.Name,
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("150405"),
+		Timestamp: now.Unix(),
+	}
+}
+
+// IsOutputNameTemplate returns whether output has to be rendered with
+// RenderOutputName
+func IsOutputNameTemplate(output string) bool {
+	return strings.Contains(output, "{{")
+}
+
+// RenderOutputName renders the templated output name with data. Outputs
+// without template actions are returned as they are.
+func RenderOutputName(output string, data *OutputNameData) (string, error) {
+	if !IsOutputNameTemplate(output) {
+		return output, nil
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(output)
+	if err != nil {
+		return "", fmt.Errorf("parsing output name %q: %w", output, err)
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("rendering output name %q: %w", output, err)
+	}
+	return sb.String(), nil
+}
+
+// ValidateOutputName checks that the templated output name only uses known
+// variables
+func ValidateOutputName(output string) error {
+	_, err := RenderOutputName(output, &OutputNameData{})
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
+)
+
+func TestRenderOutputName(t *testing.T) {
+	trace := &gadgetv1alpha1.Trace{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "gadget"},
+		Spec:       gadgetv1alpha1.TraceSpec{Node: "node1"},
+	}
+	now := time.Date(2024, time.March, 15, 10, 20, 30, 0, time.UTC)
+	data := NewOutputNameData(trace, "default", "mypod", "nginx", now)
+
+	tests := []struct {
+		output   string
+		expected string
+		err      bool
+	}{
+		{output: "", expected: ""},
+		{output: "myprofile", expected: "myprofile"},
+		{output: "seccomp-{{.Namespace}}-{{.Pod}}-{{.Date}}", expected: "seccomp-default-mypod-2024-03-15"},
+		{output: "profiles/{{.Pod}}-{{.Container}}-{{.Time}}", expected: "profiles/mypod-nginx-102030"},
+		{output: "{{.Trace}}-{{.Node}}-{{.Timestamp}}", expected: "nightly-node1-1710498030"},
+		{output: "{{.Unknown}}", err: true},
+		{output: "{{.Pod", err: true},
+	}
+
+	for _, test := range tests {
+		got, err := RenderOutputName(test.output, data)
+		if test.err {
+			require.Error(t, err, test.output)
+			require.Error(t, ValidateOutputName(test.output), test.output)
+			continue
+		}
+		require.NoError(t, err, test.output)
+		require.NoError(t, ValidateOutputName(test.output), test.output)
+		assert.Equal(t, test.expected, got)
+	}
+}
@@ -15,8 +15,6 @@
 package biolatency
 
 import (
-	"fmt"
-
 	gadgetv1alpha1 "github.com/inspektor-gadget/inspektor-gadget/pkg/apis/gadget/v1alpha1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/profile"
@@ -77,6 +75,12 @@ func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOp
 				f.LookupOrCreate(name, n).(*Trace).Stop(trace)
 			},
 		},
+		gadgetv1alpha1.OperationClear: {
+			Doc: "Clear the histogram collected by biolatency without stopping it",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Clear(trace)
+			},
+		},
 	}
 }
 
@@ -94,7 +98,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	var err error
 	t.tracer, err = tracer.NewTracer()
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 	t.started = true
@@ -103,6 +107,18 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.State = gadgetv1alpha1.TraceStateStarted
 }
 
+func (t *Trace) Clear(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	if err := t.tracer.Clear(); err != nil {
+		gadgets.SetOperationError(trace, "failed to clear results", err)
+		return
+	}
+}
+
 func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
 	if !t.started {
 		trace.Status.OperationError = "Not started"
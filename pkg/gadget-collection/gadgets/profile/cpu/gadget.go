@@ -78,6 +78,12 @@ func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOp
 				f.LookupOrCreate(name, n).(*Trace).Stop(trace)
 			},
 		},
+		gadgetv1alpha1.OperationClear: {
+			Doc: "Clear the samples collected by profile without stopping it",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Clear(trace)
+			},
+		},
 	}
 }
 
@@ -105,7 +111,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 
 	t.tracer, err = tracer.NewTracer(t.helpers, config)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 	t.started = true
@@ -114,6 +120,18 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.State = gadgetv1alpha1.TraceStateStarted
 }
 
+func (t *Trace) Clear(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	if err := t.tracer.Clear(); err != nil {
+		gadgets.SetOperationError(trace, "failed to clear results", err)
+		return
+	}
+}
+
 func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
 	if !t.started {
 		trace.Status.OperationError = "Not started"
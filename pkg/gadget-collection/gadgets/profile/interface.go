@@ -16,4 +16,5 @@ package profile
 
 type Tracer interface {
 	Stop() (string, error)
+	Clear() error
 }
@@ -23,6 +23,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/process/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/process/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 type Trace struct {
@@ -41,6 +42,17 @@ func (f *TraceFactory) Description() string {
 	return `The process-collector gadget gathers information about running processes`
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          types.ShowThreadsParam,
+			Description:  "Show all threads",
+			DefaultValue: "false",
+			TypeHint:     params.TypeBool,
+		},
+	}
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStatus: {},
@@ -24,6 +24,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/socket/tracer"
 	socketcollectortypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/socket/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 type Trace struct {
@@ -39,7 +40,21 @@ func NewFactory() gadgets.TraceFactory {
 }
 
 func (f *TraceFactory) Description() string {
-	return `The socket-collector gadget gathers information about TCP and UDP sockets.`
+	return `The socket-collector gadget gathers information about TCP, UDP and UNIX domain sockets.`
+}
+
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          "protocol",
+			Description:  "Show only sockets using these comma-separated protocols (all, tcp, udp, unix)",
+			DefaultValue: "all",
+			Validator: func(value string) error {
+				_, err := socketcollectortypes.ParseProtocol(value)
+				return err
+			},
+		},
+	}
 }
 
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
@@ -57,7 +72,7 @@ func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOp
 
 	return map[gadgetv1alpha1.Operation]gadgets.TraceOperation{
 		gadgetv1alpha1.OperationCollect: {
-			Doc: "Create a snapshot of the currently open TCP and UDP sockets. " +
+			Doc: "Create a snapshot of the currently open TCP, UDP and UNIX domain sockets. " +
 				"Once taken, the snapshot is not updated automatically. " +
 				"However one can call the collect operation again at any time to update the snapshot.",
 			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
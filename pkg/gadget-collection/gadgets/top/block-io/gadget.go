@@ -29,6 +29,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top"
 	biotoptracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/block-io/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/block-io/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 type Trace struct {
@@ -63,6 +64,10 @@ The following parameters are supported:
 		top.SortByParam, strings.Join(validCols, ","), strings.Join(types.SortByDefault, ","))
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return gadgets.TopParamDescs()
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStream: {},
@@ -96,6 +101,24 @@ func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOp
 				f.LookupOrCreate(name, n).(*Trace).Stop(trace)
 			},
 		},
+		gadgetv1alpha1.OperationPause: {
+			Doc: "Pause biotop gadget, keeping the statistics collected by the kernel",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Pause(trace)
+			},
+		},
+		gadgetv1alpha1.OperationResume: {
+			Doc: "Resume biotop gadget",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Resume(trace)
+			},
+		},
+		gadgetv1alpha1.OperationClear: {
+			Doc: "Clear the statistics collected by biotop gadget without stopping it",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Clear(trace)
+			},
+		},
 	}
 }
 
@@ -167,7 +190,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 
 	tracer, err := biotoptracer.NewTracer(config, t.helpers, eventCallback)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 
@@ -177,6 +200,40 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.State = gadgetv1alpha1.TraceStateStarted
 }
 
+func (t *Trace) Pause(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	t.tracer.Pause()
+
+	trace.Status.State = gadgetv1alpha1.TraceStatePaused
+}
+
+func (t *Trace) Resume(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	t.tracer.Resume()
+
+	trace.Status.State = gadgetv1alpha1.TraceStateStarted
+}
+
+func (t *Trace) Clear(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	if err := t.tracer.Clear(); err != nil {
+		gadgets.SetOperationError(trace, "failed to clear statistics", err)
+		return
+	}
+}
+
 func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
 	if !t.started {
 		trace.Status.OperationError = "Not started"
@@ -30,6 +30,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top"
 	ebpftoptracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/ebpf/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 type Trace struct {
@@ -67,6 +68,10 @@ The following parameters are supported:
 		top.SortByParam, strings.Join(validCols, ","), strings.Join(types.SortByDefault, ","))
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return gadgets.TopParamDescs()
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStream: {},
@@ -100,6 +105,18 @@ func (f *TraceFactory) Operations() map[gadgetv1alpha1.Operation]gadgets.TraceOp
 				f.LookupOrCreate(name, n).(*Trace).Stop(trace)
 			},
 		},
+		gadgetv1alpha1.OperationPause: {
+			Doc: "Pause ebpftop gadget, keeping the statistics collected by the kernel",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Pause(trace)
+			},
+		},
+		gadgetv1alpha1.OperationResume: {
+			Doc: "Resume ebpftop gadget",
+			Operation: func(name string, trace *gadgetv1alpha1.Trace) {
+				f.LookupOrCreate(name, n).(*Trace).Resume(trace)
+			},
+		},
 	}
 }
 
@@ -166,7 +183,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 
 	tracer, err := ebpftoptracer.NewTracer(config, t.helpers, eventCallback)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 
@@ -176,6 +193,28 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	trace.Status.State = gadgetv1alpha1.TraceStateStarted
 }
 
+func (t *Trace) Pause(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	t.tracer.Pause()
+
+	trace.Status.State = gadgetv1alpha1.TraceStatePaused
+}
+
+func (t *Trace) Resume(trace *gadgetv1alpha1.Trace) {
+	if !t.started {
+		trace.Status.OperationError = "Not started"
+		return
+	}
+
+	t.tracer.Resume()
+
+	trace.Status.State = gadgetv1alpha1.TraceStateStarted
+}
+
 func (t *Trace) Stop(trace *gadgetv1alpha1.Trace) {
 	if !t.started {
 		trace.Status.OperationError = "Not started"
@@ -24,6 +24,7 @@ import (
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/trace"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/bind/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/bind/types"
@@ -52,6 +53,27 @@ func (f *TraceFactory) Description() string {
 	return `bindsnoop traces the kernel functions performing socket binding.`
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:         "pid",
+			Description: "Which particular pid to trace",
+			TypeHint:    params.TypeInt32,
+		},
+		{
+			Key:         "ports",
+			Description: "Trace only these ports. Join multiple ports with ','",
+			Validator:   params.ValidateSlice(params.ValidateUint(16)),
+		},
+		{
+			Key:          "ignore_errors",
+			Description:  "Don't show binds that failed",
+			DefaultValue: "false",
+			TypeHint:     params.TypeBool,
+		},
+	}
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStream: {},
@@ -159,7 +181,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	}
 	t.tracer, err = tracer.NewTracer(config, t.helpers, eventCallback)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 
@@ -23,6 +23,7 @@ import (
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/trace"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/types"
@@ -51,6 +52,23 @@ func (f *TraceFactory) Description() string {
 	return `capabilities traces security capability checks"`
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          types.AuditOnlyParam,
+			Description:  "Only show capability checks that are audited",
+			DefaultValue: fmt.Sprint(types.AuditOnlyDefault),
+			TypeHint:     params.TypeBool,
+		},
+		{
+			Key:          types.UniqueParam,
+			Description:  "Only show a capability once on the same container",
+			DefaultValue: fmt.Sprint(types.UniqueDefault),
+			TypeHint:     params.TypeBool,
+		},
+	}
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStream: {},
@@ -142,7 +160,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 
 	t.tracer, err = tracer.NewTracer(config, t.helpers, eventCallback)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 
@@ -22,6 +22,7 @@ import (
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-collection/gadgets/trace"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/fsslower/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/fsslower/types"
@@ -58,6 +59,23 @@ The following parameters are supported:
 	return fmt.Sprintf(t, strings.Join(validFilesystems, ", "), types.MinLatencyDefault)
 }
 
+func (f *TraceFactory) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:            "filesystem",
+			Description:    "Which filesystem to trace",
+			IsMandatory:    true,
+			PossibleValues: validFilesystems,
+		},
+		{
+			Key:          "minlatency",
+			Description:  "Min latency to trace, in ms",
+			DefaultValue: fmt.Sprint(types.MinLatencyDefault),
+			TypeHint:     params.TypeUint32,
+		},
+	}
+}
+
 func (f *TraceFactory) OutputModesSupported() map[gadgetv1alpha1.TraceOutputMode]struct{} {
 	return map[gadgetv1alpha1.TraceOutputMode]struct{}{
 		gadgetv1alpha1.TraceOutputModeStream: {},
@@ -151,7 +169,7 @@ func (t *Trace) Start(trace *gadgetv1alpha1.Trace) {
 	}
 	t.tracer, err = tracer.NewTracer(config, t.helpers, eventCallback)
 	if err != nil {
-		trace.Status.OperationError = fmt.Sprintf("failed to create tracer: %s", err)
+		gadgets.SetOperationError(trace, "failed to create tracer", err)
 		return
 	}
 
@@ -73,6 +73,10 @@ type GadgetContext struct {
 	imageName        string
 	metadata         []byte
 	orasTarget       oras.ReadOnlyTarget
+
+	// localOperatorsWg is used to wait until the local operators started by
+	// LoadGadgetInfo are stopped
+	localOperatorsWg sync.WaitGroup
 }
 
 func NewBuiltIn(
@@ -363,7 +367,9 @@ func (c *GadgetContext) LoadGadgetInfo(info *api.GadgetInfo, paramValues api.Par
 
 		c.Logger().Debugf("running...")
 
+		c.localOperatorsWg.Add(1)
 		go func() {
+			defer c.localOperatorsWg.Done()
 			// TODO: Client shouldn't need to wait for the timeout. It should be
 			// managed only on the server side.
 			WaitForTimeoutOrDone(c)
@@ -374,6 +380,12 @@ func (c *GadgetContext) LoadGadgetInfo(info *api.GadgetInfo, paramValues api.Par
 	return nil
 }
 
+// WaitForLocalOperators waits until the local operators started by
+// LoadGadgetInfo are stopped, which happens once the context is done.
+func (c *GadgetContext) WaitForLocalOperators() {
+	c.localOperatorsWg.Wait()
+}
+
 func (c *GadgetContext) OrasTarget() oras.ReadOnlyTarget {
 	return c.orasTarget
 }
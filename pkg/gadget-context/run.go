@@ -18,9 +18,12 @@ import (
 	"fmt"
 	"sort"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/selftracing"
 )
 
 func (c *GadgetContext) initAndPrepareOperators(paramValues api.ParamValues) ([]operators.DataOperatorInstance, error) {
@@ -59,7 +62,10 @@ func (c *GadgetContext) initAndPrepareOperators(paramValues api.ParamValues) ([]
 			return nil, fmt.Errorf("validating instance params for operator %q: %w", op.Name(), err)
 		}
 
+		_, span := selftracing.StartSpan(c.Context(), "gadgetcontext.InstantiateOperator",
+			attribute.String("operator", op.Name()))
 		opInst, err := op.InstantiateDataOperator(c, opParamValues)
+		selftracing.End(span, err)
 		if err != nil {
 			return nil, fmt.Errorf("instantiating operator %q: %w", op.Name(), err)
 		}
@@ -98,7 +104,10 @@ func (c *GadgetContext) start(dataOperatorInstances []operators.DataOperatorInst
 			continue
 		}
 		c.Logger().Debugf("pre-starting op %q", opInst.Name())
+		_, span := selftracing.StartSpan(c.Context(), "gadgetcontext.PreStartOperator",
+			attribute.String("operator", opInst.Name()))
 		err := preStart.PreStart(c)
+		selftracing.End(span, err)
 		if err != nil {
 			c.cancel()
 			return fmt.Errorf("pre-starting operator %q: %w", opInst.Name(), err)
@@ -106,7 +115,10 @@ func (c *GadgetContext) start(dataOperatorInstances []operators.DataOperatorInst
 	}
 	for _, opInst := range dataOperatorInstances {
 		c.Logger().Debugf("starting op %q", opInst.Name())
+		_, span := selftracing.StartSpan(c.Context(), "gadgetcontext.StartOperator",
+			attribute.String("operator", opInst.Name()))
 		err := opInst.Start(c)
+		selftracing.End(span, err)
 		if err != nil {
 			c.cancel()
 			return fmt.Errorf("starting operator %q: %w", opInst.Name(), err)
@@ -0,0 +1,187 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gadgetlibrary allows Go programs to embed the tracers of the
+// built-in gadgets, like pkg/gadgets/trace/exec/tracer, without the ig or
+// kubectl-gadget CLIs. It takes care of the container collection used to
+// enrich the events and of the mount namespace maps used to filter them by
+// container, and delivers the events through channels.
+//
+//	lib, err := gadgetlibrary.New()
+//	...
+//	defer lib.Close()
+//
+//	trace, err := gadgetlibrary.Start(lib,
+//		func(mountnsMap *ebpf.Map, enricher gadgets.DataEnricherByMntNs, cb func(*execTypes.Event)) (gadgetlibrary.Stopper, error) {
+//			return execTracer.NewTracer(&execTracer.Config{MountnsMap: mountnsMap}, enricher, cb)
+//		},
+//		gadgetlibrary.WithContainerName("nginx"),
+//	)
+//	...
+//	defer trace.Stop()
+//	for event := range trace.Events() {
+//		...
+//	}
+package gadgetlibrary
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/rlimit"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
+	tracercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/tracer-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// Library holds the container collection and the tracer collection shared
+// by all the tracers started with it. It's safe for concurrent use.
+type Library struct {
+	containerCollection *containercollection.ContainerCollection
+	tracerCollection    *tracercollection.TracerCollection
+}
+
+type config struct {
+	runtimes []*containerutilsTypes.RuntimeConfig
+	// customRuntimes is set when the runtimes were given by the caller
+	customRuntimes bool
+	nodeName       string
+	hostConfig     *host.Config
+	extraOpts      []containercollection.ContainerCollectionOption
+}
+
+// Option configures a Library
+type Option func(*config)
+
+// WithRuntimes sets the container runtimes used to enrich the containers. All
+// the supported runtimes with their default sockets are used by default.
+func WithRuntimes(runtimes ...*containerutilsTypes.RuntimeConfig) Option {
+	return func(c *config) {
+		c.runtimes = runtimes
+		c.customRuntimes = true
+	}
+}
+
+// WithNodeName sets the node name the events are enriched with
+func WithNodeName(nodeName string) Option {
+	return func(c *config) {
+		c.nodeName = nodeName
+	}
+}
+
+// WithHostConfig calls host.Init() with the given configuration, e.g. to
+// mount the filesystems needed by the tracers when they aren't
+func WithHostConfig(hostConfig host.Config) Option {
+	return func(c *config) {
+		c.hostConfig = &hostConfig
+	}
+}
+
+// WithContainerCollectionOptions adds options to the ones used to initialize
+// the container collection, e.g. containercollection.WithKubernetesEnrichment()
+func WithContainerCollectionOptions(opts ...containercollection.ContainerCollectionOption) Option {
+	return func(c *config) {
+		c.extraOpts = append(c.extraOpts, opts...)
+	}
+}
+
+func defaultRuntimes() []*containerutilsTypes.RuntimeConfig {
+	runtimes := make([]*containerutilsTypes.RuntimeConfig, 0, len(containerutils.AvailableRuntimes))
+	for _, r := range containerutils.AvailableRuntimes {
+		runtimes = append(runtimes, &containerutilsTypes.RuntimeConfig{
+			Name: types.String2RuntimeName(r),
+		})
+	}
+	return runtimes
+}
+
+// New creates a Library. It needs the same privileges as ig: it must run as
+// root in the host pid namespace.
+func New(options ...Option) (*Library, error) {
+	c := &config{
+		runtimes: defaultRuntimes(),
+	}
+	for _, o := range options {
+		o(c)
+	}
+
+	if c.hostConfig != nil {
+		if err := host.Init(*c.hostConfig); err != nil {
+			return nil, fmt.Errorf("initializing host package: %w", err)
+		}
+	}
+
+	// In some kernel versions it's needed to bump the rlimits to use run BPF
+	// programs.
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, fmt.Errorf("removing memlock: %w", err)
+	}
+
+	l := &Library{
+		containerCollection: &containercollection.ContainerCollection{},
+	}
+
+	var err error
+	l.tracerCollection, err = tracercollection.NewTracerCollection(l.containerCollection)
+	if err != nil {
+		return nil, fmt.Errorf("creating tracer collection: %w", err)
+	}
+
+	opts := []containercollection.ContainerCollectionOption{
+		containercollection.WithPubSub(),
+		containercollection.WithOCIConfigEnrichment(),
+		containercollection.WithCgroupEnrichment(),
+		containercollection.WithLinuxNamespaceEnrichment(),
+		containercollection.WithMultipleContainerRuntimesEnrichment(c.runtimes),
+		containercollection.WithNestedContainers(),
+		containercollection.WithContainerFanotifyEbpf(),
+		containercollection.WithTracerCollection(l.tracerCollection),
+		containercollection.WithProcEnrichment(),
+	}
+	if c.nodeName != "" {
+		opts = append(opts, containercollection.WithNodeName(c.nodeName))
+	}
+	opts = append(opts, c.extraOpts...)
+
+	// Don't warn about the runtimes that aren't installed when using the
+	// default ones, like ig does
+	if !c.customRuntimes {
+		opts = append([]containercollection.ContainerCollectionOption{
+			containercollection.WithDisableContainerRuntimeWarnings(),
+		}, opts...)
+	}
+
+	if err := l.containerCollection.Initialize(opts...); err != nil {
+		l.tracerCollection.Close()
+		return nil, fmt.Errorf("initializing container collection: %w", err)
+	}
+
+	return l, nil
+}
+
+// Close releases the resources of the library. The tracers started with it
+// must be stopped before.
+func (l *Library) Close() {
+	l.containerCollection.Close()
+	l.tracerCollection.Close()
+}
+
+// ContainerCollection returns the container collection used to enrich the
+// events, e.g. to list the containers or to subscribe to their creation.
+func (l *Library) ContainerCollection() *containercollection.ContainerCollection {
+	return l.containerCollection
+}
@@ -0,0 +1,273 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetlibrary
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cilium/ebpf"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+// DefaultBufferSize is the number of events buffered by the channel of a
+// trace before dropping them
+const DefaultBufferSize = 1024
+
+// Stopper is implemented by the tracers of the built-in gadgets
+type Stopper interface {
+	Stop()
+}
+
+// TracerFactory creates a tracer of the built-in gadgets filtering the events
+// with the mount namespace map and enriching them with the enricher, like
+// pkg/gadgets/trace/exec/tracer.NewTracer(). mountnsMap is nil when the
+// events of all the processes are traced.
+type TracerFactory[E any] func(
+	mountnsMap *ebpf.Map,
+	enricher gadgets.DataEnricherByMntNs,
+	eventCallback func(*E),
+) (Stopper, error)
+
+// NetworkTracer is implemented by the tracers attached to the network
+// namespaces of the containers, like pkg/gadgets/trace/dns/tracer.Tracer
+type NetworkTracer interface {
+	SetEventHandler(handler any)
+	AttachContainer(container *containercollection.Container) error
+	DetachContainer(container *containercollection.Container) error
+	Close()
+}
+
+type traceConfig struct {
+	selector   containercollection.ContainerSelector
+	host       bool
+	bufferSize int
+}
+
+// TraceOption configures a trace
+type TraceOption func(*traceConfig)
+
+// WithContainerSelector only traces the containers matching the selector
+func WithContainerSelector(selector containercollection.ContainerSelector) TraceOption {
+	return func(c *traceConfig) {
+		c.selector = selector
+	}
+}
+
+// WithContainerName only traces the containers with this name
+func WithContainerName(name string) TraceOption {
+	return func(c *traceConfig) {
+		c.selector.Runtime.ContainerName = name
+	}
+}
+
+// WithHost traces all the processes, including the ones not running in
+// containers. The container selector is ignored.
+func WithHost() TraceOption {
+	return func(c *traceConfig) {
+		c.host = true
+	}
+}
+
+// WithBufferSize sets the size of the channel of the events, see Dropped()
+func WithBufferSize(size int) TraceOption {
+	return func(c *traceConfig) {
+		c.bufferSize = size
+	}
+}
+
+// Trace is a running tracer. Its events are delivered through Events() until
+// Stop() is called.
+type Trace[E any] struct {
+	events  chan *E
+	dropped atomic.Uint64
+
+	// mu protects closed against events sent while the trace is stopped
+	mu     sync.Mutex
+	closed bool
+
+	stopOnce sync.Once
+	stop     func()
+}
+
+func newTrace[E any](bufferSize int) *Trace[E] {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Trace[E]{
+		events: make(chan *E, bufferSize),
+	}
+}
+
+// send never blocks the tracer: events are dropped when the channel is full
+func (t *Trace[E]) send(event *E) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return
+	}
+	select {
+	case t.events <- event:
+	default:
+		t.dropped.Add(1)
+	}
+}
+
+// Events returns the channel of the events. It's closed by Stop().
+func (t *Trace[E]) Events() <-chan *E {
+	return t.events
+}
+
+// Dropped returns the number of events dropped because the channel was full
+func (t *Trace[E]) Dropped() uint64 {
+	return t.dropped.Load()
+}
+
+// Stop stops the tracer and closes the channel of the events. It can be
+// called several times.
+func (t *Trace[E]) Stop() {
+	t.stopOnce.Do(func() {
+		if t.stop != nil {
+			t.stop()
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.closed = true
+		close(t.events)
+	})
+}
+
+// Start starts a tracer filtering the events by mount namespace, like most of
+// the trace and top tracers. The events are enriched with the metadata of
+// their containers.
+func Start[E any](l *Library, newTracer TracerFactory[E], options ...TraceOption) (*Trace[E], error) {
+	c := &traceConfig{}
+	for _, o := range options {
+		o(c)
+	}
+
+	trace := newTrace[E](c.bufferSize)
+
+	var mountnsMap *ebpf.Map
+	id := ""
+	if !c.host {
+		id = uuid.New().String()
+		if err := l.tracerCollection.AddTracer(id, c.selector); err != nil {
+			return nil, fmt.Errorf("adding tracer: %w", err)
+		}
+
+		var err error
+		mountnsMap, err = l.tracerCollection.TracerMountNsMap(id)
+		if err != nil {
+			l.tracerCollection.RemoveTracer(id)
+			return nil, fmt.Errorf("getting mount namespace map: %w", err)
+		}
+	}
+
+	tracer, err := newTracer(mountnsMap, l.containerCollection, trace.send)
+	if err != nil {
+		if id != "" {
+			l.tracerCollection.RemoveTracer(id)
+		}
+		return nil, fmt.Errorf("creating tracer: %w", err)
+	}
+
+	trace.stop = func() {
+		tracer.Stop()
+		if id != "" {
+			l.tracerCollection.RemoveTracer(id)
+		}
+	}
+	return trace, nil
+}
+
+// StartNetwork starts a tracer attached to the network namespaces of the
+// containers matching the selector, like the DNS or network tracers. The
+// tracer is attached to the containers created later too, and it's closed by
+// Stop(). The events are enriched with the metadata of their containers.
+func StartNetwork[E any](l *Library, tracer NetworkTracer, options ...TraceOption) (*Trace[E], error) {
+	c := &traceConfig{}
+	for _, o := range options {
+		o(c)
+	}
+	if c.host {
+		return nil, fmt.Errorf("network tracers can only be attached to containers")
+	}
+
+	trace := newTrace[E](c.bufferSize)
+	cc := l.containerCollection
+
+	tracer.SetEventHandler(func(event *E) {
+		if ev, ok := any(event).(operators.ContainerInfoFromNetNSID); ok {
+			cc.EnrichEventByNetNs(ev)
+		}
+		trace.send(event)
+	})
+
+	// Attach to the containers created while the trace is running. mu
+	// protects attached as the callback can be called concurrently.
+	var mu sync.Mutex
+	attached := map[*containercollection.Container]struct{}{}
+	attach := func(container *containercollection.Container) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := tracer.AttachContainer(container); err != nil {
+			log.Warnf("gadget library: attaching container %s: %s", container.Runtime.ContainerName, err)
+			return
+		}
+		attached[container] = struct{}{}
+	}
+	detach := func(container *containercollection.Container) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := attached[container]; !ok {
+			return
+		}
+		tracer.DetachContainer(container)
+		delete(attached, container)
+	}
+
+	id := uuid.New().String()
+	containers := cc.Subscribe(id, c.selector, func(event containercollection.PubSubEvent) {
+		switch event.Type {
+		case containercollection.EventTypeAddContainer:
+			attach(event.Container)
+		case containercollection.EventTypeRemoveContainer:
+			detach(event.Container)
+		}
+	})
+	for _, container := range containers {
+		attach(container)
+	}
+
+	trace.stop = func() {
+		cc.Unsubscribe(id)
+		mu.Lock()
+		for container := range attached {
+			tracer.DetachContainer(container)
+		}
+		mu.Unlock()
+		tracer.Close()
+	}
+	return trace, nil
+}
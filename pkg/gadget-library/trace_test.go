@@ -0,0 +1,61 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetlibrary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEvent struct {
+	n int
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	stopped := 0
+	trace := newTrace[testEvent](2)
+	trace.stop = func() { stopped++ }
+
+	trace.send(&testEvent{n: 1})
+	trace.send(&testEvent{n: 2})
+	// The channel is full, the event is dropped instead of blocking the
+	// tracer
+	trace.send(&testEvent{n: 3})
+	require.Equal(t, uint64(1), trace.Dropped())
+
+	trace.Stop()
+	trace.Stop()
+	require.Equal(t, 1, stopped)
+
+	// Events sent after stopping are ignored
+	trace.send(&testEvent{n: 4})
+
+	var got []int
+	for ev := range trace.Events() {
+		got = append(got, ev.n)
+	}
+	require.Equal(t, []int{1, 2}, got)
+}
+
+func TestTraceDefaultBufferSize(t *testing.T) {
+	t.Parallel()
+
+	trace := newTrace[testEvent](0)
+	require.Equal(t, DefaultBufferSize, cap(trace.events))
+	trace.Stop()
+}
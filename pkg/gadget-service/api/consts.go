@@ -30,9 +30,19 @@ const (
 	// expected / sent.
 	EventTypeGadgetInfo uint32 = 4
 
+	// EventTypeGadgetPayloadCBOR is like EventTypeGadgetPayload, but the payload is encoded in CBOR
+	// (RFC 8949) instead of JSON. It's only sent if the client requested PayloadEncodingCBOR.
+	EventTypeGadgetPayloadCBOR uint32 = 5
+
 	EventLogShift = 16
 )
 
+const (
+	// PayloadEncodingCBOR requests events to be sent with EventTypeGadgetPayloadCBOR; it uses the same field
+	// names as the JSON encoding but it's cheaper to produce and smaller
+	PayloadEncodingCBOR = "cbor"
+)
+
 const (
 	KindFlagArray Kind = 0x10000000
 )
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/proto"
@@ -202,6 +203,7 @@ func (p *GadgetInstance) Run(
 		gadgetcontext.WithLogger(logger),
 		gadgetcontext.WithDataOperators(ops...),
 		gadgetcontext.WithAsRemoteCall(true),
+		gadgetcontext.WithTimeout(time.Duration(p.request.Timeout)),
 	)
 
 	runtimeParams := runtime.ParamDescs().ToParams()
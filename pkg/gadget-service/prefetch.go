@@ -0,0 +1,70 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgetservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/cilium/ebpf/btf"
+
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+// preloadVar asks the ebpf operator to load the collection of the gadget in
+// advance. Keep in sync with pkg/operators/ebpf.
+const preloadVar = "preload"
+
+// prefetchGadgets prepares the given gadgets like when getting their
+// information: their images are pulled if missing and their eBPF objects are
+// parsed and cached. The kernel BTF is loaded as well. Their eBPF collections
+// are also loaded and pinned with the default parameters, so a run can use
+// them instead of loading its own. This way, the runs of these gadgets don't
+// pay for it.
+func (s *Service) prefetchGadgets(ctx context.Context, images []string) {
+	if len(images) == 0 {
+		return
+	}
+
+	start := time.Now()
+	if _, err := btf.LoadKernelSpec(); err != nil {
+		s.logger.Debugf("prefetch: loading kernel BTF: %v", err)
+	}
+
+	ops := make([]operators.DataOperator, 0)
+	for op := range s.operators {
+		ops = append(ops, op)
+	}
+
+	for _, image := range images {
+		gadgetStart := time.Now()
+		gadgetCtx := gadgetcontext.New(
+			ctx,
+			image,
+			gadgetcontext.WithDataOperators(ops...),
+			gadgetcontext.WithAsRemoteCall(true),
+		)
+		gadgetCtx.SetVar(preloadVar, true)
+		_, err := s.runtime.GetGadgetInfo(gadgetCtx, s.runtime.ParamDescs().ToParams(), api.ParamValues{})
+		if err != nil {
+			s.logger.Warnf("prefetching gadget %q: %v", image, err)
+			continue
+		}
+		s.logger.Debugf("prefetched gadget %q in %s", image, time.Since(gadgetStart))
+	}
+	s.logger.Infof("prefetched %d gadgets in %s", len(images), time.Since(start))
+}
@@ -21,6 +21,7 @@ import (
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/protobuf/proto"
@@ -30,8 +31,10 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	clioperator "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/cli"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/selftracing"
 )
 
 func (s *Service) initOperators() error {
@@ -136,6 +139,10 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 		return fmt.Errorf("expected version to be %d, got %d", api.VersionGadgetRunProtocol, ociRequest.Version)
 	}
 
+	ctx, span := selftracing.StartSpan(runGadget.Context(), "gadgetservice.RunGadget",
+		attribute.String("gadget.image", ociRequest.ImageName))
+	defer func() { selftracing.End(span, err) }()
+
 	// Create a new logger that logs to gRPC and falls back to the standard logger when it failed to send the message
 	logger := logger.NewFromGenericLogger(&Logger{
 		send:           runGadget.Send,
@@ -155,7 +162,10 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 	// Build a simple operator that subscribes to all events and forwards them
 	svc := simple.New("svc",
 		simple.WithPriority(50000),
-		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) (err error) {
+			_, span := selftracing.StartSpan(gadgetCtx.Context(), "gadgetservice.SetupStream")
+			defer func() { selftracing.End(span, err) }()
+
 			// Create payload buffer
 			outputBuffer := make(chan *api.GadgetEvent, s.eventBufferLength)
 
@@ -212,9 +222,23 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 
 			// todo: skip DataSources we're not interested in
 
+			requestedFields := ociRequest.ParamValues["operator.cli."+clioperator.ParamRequestedFields]
+
 			for _, ds := range gadgetCtx.GetDataSources() {
+				if requestedFields != "" {
+					// Only send the fields that the client will show
+					fields, err := clioperator.RequestedFields(requestedFields, ds)
+					if err == nil {
+						err = ds.SetRequestedFields(fields)
+					}
+					if err != nil {
+						log.Warnf("sending all fields of data source %q: %v", ds.Name(), err)
+					}
+				}
+
 				dsID := dsLookup[ds.Name()]
 				ds.SubscribePacket(func(ds datasource.DataSource, packet datasource.Packet) error {
+					ds.PruneUnrequested(packet)
 					d, _ := proto.Marshal(packet.Raw())
 
 					event := &api.GadgetEvent{
@@ -260,7 +284,7 @@ func (s *Service) RunGadget(runGadget api.GadgetManager_RunGadgetServer) error {
 	ops = append(ops, svc)
 
 	gadgetCtx := gadgetcontext.New(
-		runGadget.Context(),
+		ctx,
 		ociRequest.ImageName,
 		gadgetcontext.WithLogger(logger),
 		gadgetcontext.WithDataOperators(ops...),
@@ -27,6 +27,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"github.com/google/uuid"
 	"google.golang.org/grpc"
 
@@ -58,6 +59,10 @@ type RunConfig struct {
 	// If SocketGID != 0 and a unix socket is used, the ownership of that socket
 	// will be changed to the given SocketGID
 	SocketGID int
+
+	// PrefetchGadgets are the images of the gadgets to prepare in the
+	// background once the service is initialized, so they start faster
+	PrefetchGadgets []string
 }
 
 type Service struct {
@@ -168,6 +173,18 @@ func (s *Service) RunBuiltInGadget(runGadget api.BuiltInGadgetManager_RunBuiltIn
 		return fmt.Errorf("setting parameters: %w", err)
 	}
 
+	// Events are sent as JSON unless the client asked for CBOR
+	marshal := json.Marshal
+	payloadType := api.EventTypeGadgetPayload
+	switch request.PayloadEncoding {
+	case "":
+	case api.PayloadEncodingCBOR:
+		marshal = cbor.Marshal
+		payloadType = api.EventTypeGadgetPayloadCBOR
+	default:
+		return fmt.Errorf("unsupported payload encoding %q", request.PayloadEncoding)
+	}
+
 	// Create payload buffer
 	outputBuffer := make(chan *api.GadgetEvent, s.eventBufferLength)
 
@@ -182,13 +199,13 @@ func (s *Service) RunBuiltInGadget(runGadget api.BuiltInGadgetManager_RunBuiltIn
 
 		parser.SetLogCallback(logger.Logf)
 		parser.SetEventCallback(func(ev any) {
-			// Marshal messages to JSON
+			// Marshal messages to JSON or CBOR
 			// Normally, it would be better to have this in the pump below rather than marshaling events that
 			// would be dropped anyway. However, we're optimistic that this occurs rarely and instead prevent using
 			// ev in another thread.
-			data, _ := json.Marshal(ev)
+			data, _ := marshal(ev)
 			event := &api.GadgetEvent{
-				Type:    api.EventTypeGadgetPayload,
+				Type:    payloadType,
 				Payload: data,
 			}
 
@@ -397,6 +414,8 @@ func (s *Service) Run(runConfig RunConfig, serverOptions ...grpc.ServerOption) e
 		}
 	}
 
+	go s.prefetchGadgets(context.Background(), runConfig.PrefetchGadgets)
+
 	return server.Serve(s.listener)
 }
 
@@ -0,0 +1,209 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorReset = "\033[0m"
+)
+
+// PolicyDiff compares a generated network policy to the network policies
+// already applied to the pods it selects. Connections are compared one by
+// one, so rules grouping several peers or ports are compared correctly.
+type PolicyDiff struct {
+	Policy networkingv1.NetworkPolicy
+
+	// Existing are the names of the network policies selecting the same pods
+	Existing []string
+
+	// Added are the connections only allowed by the generated policy
+	Added []string
+	// Removed are the connections allowed by the existing policies but not
+	// by the generated one
+	Removed []string
+}
+
+// DiffPolicies compares each generated policy to the existing policies of its
+// namespace selecting the same pods
+func DiffPolicies(generated, existing []networkingv1.NetworkPolicy) []PolicyDiff {
+	diffs := make([]PolicyDiff, 0, len(generated))
+	for _, policy := range generated {
+		diff := PolicyDiff{Policy: policy}
+
+		podLabels := labels.Set(policy.Spec.PodSelector.MatchLabels)
+		var current []string
+		for _, e := range existing {
+			if e.Namespace != policy.Namespace {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(&e.Spec.PodSelector)
+			if err != nil || !selector.Matches(podLabels) {
+				continue
+			}
+			diff.Existing = append(diff.Existing, e.Name)
+			current = append(current, policyConnections(e.Spec)...)
+		}
+		sort.Strings(diff.Existing)
+
+		wanted := policyConnections(policy.Spec)
+		for _, c := range wanted {
+			if !slices.Contains(current, c) {
+				diff.Added = append(diff.Added, c)
+			}
+		}
+		for _, c := range current {
+			if !slices.Contains(wanted, c) {
+				diff.Removed = append(diff.Removed, c)
+			}
+		}
+		diff.Added = sortedUnique(diff.Added)
+		diff.Removed = sortedUnique(diff.Removed)
+
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// FormatDiffs prints the added connections prefixed by + and the removed ones
+// prefixed by -, in green and red when color is set
+func FormatDiffs(diffs []PolicyDiff, color bool) string {
+	var sb strings.Builder
+	for i, d := range diffs {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "policy %s/%s\n", d.Policy.Namespace, d.Policy.Name)
+		if len(d.Existing) == 0 {
+			sb.WriteString("  no existing network policy selects these pods\n")
+		} else {
+			fmt.Fprintf(&sb, "  compared with: %s\n", strings.Join(d.Existing, ", "))
+		}
+		if len(d.Added) == 0 && len(d.Removed) == 0 {
+			sb.WriteString("  no differences\n")
+			continue
+		}
+		for _, c := range d.Removed {
+			writeDiffLine(&sb, "-", colorRed, c, color)
+		}
+		for _, c := range d.Added {
+			writeDiffLine(&sb, "+", colorGreen, c, color)
+		}
+	}
+	return sb.String()
+}
+
+func writeDiffLine(sb *strings.Builder, prefix, colorCode, line string, color bool) {
+	if color {
+		fmt.Fprintf(sb, "%s  %s %s%s\n", colorCode, prefix, line, colorReset)
+		return
+	}
+	fmt.Fprintf(sb, "  %s %s\n", prefix, line)
+}
+
+func sortedUnique(s []string) []string {
+	sort.Strings(s)
+	return slices.Compact(s)
+}
+
+// policyConnections returns a description of each peer and port allowed by
+// the policy
+func policyConnections(spec networkingv1.NetworkPolicySpec) []string {
+	var ret []string
+	for _, rule := range spec.Ingress {
+		ret = append(ret, ruleConnections("ingress from", rule.From, rule.Ports)...)
+	}
+	for _, rule := range spec.Egress {
+		ret = append(ret, ruleConnections("egress to", rule.To, rule.Ports)...)
+	}
+	return ret
+}
+
+func ruleConnections(direction string, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) []string {
+	peerNames := []string{"any peer"}
+	if len(peers) > 0 {
+		peerNames = peerNames[:0]
+		for _, p := range peers {
+			peerNames = append(peerNames, formatPeer(p))
+		}
+	}
+	portNames := []string{"any port"}
+	if len(ports) > 0 {
+		portNames = portNames[:0]
+		for _, p := range ports {
+			portNames = append(portNames, formatPort(p))
+		}
+	}
+
+	var ret []string
+	for _, peer := range peerNames {
+		for _, port := range portNames {
+			ret = append(ret, fmt.Sprintf("%s %s on %s", direction, peer, port))
+		}
+	}
+	return ret
+}
+
+func formatSelector(kind string, selector *metav1.LabelSelector) string {
+	s := metav1.FormatLabelSelector(selector)
+	if s == "<none>" {
+		return "all " + kind
+	}
+	return fmt.Sprintf("%s(%s)", kind, s)
+}
+
+func formatPeer(peer networkingv1.NetworkPolicyPeer) string {
+	if peer.IPBlock != nil {
+		if len(peer.IPBlock.Except) == 0 {
+			return peer.IPBlock.CIDR
+		}
+		return fmt.Sprintf("%s except %s", peer.IPBlock.CIDR, strings.Join(peer.IPBlock.Except, ","))
+	}
+
+	var parts []string
+	if peer.PodSelector != nil {
+		parts = append(parts, formatSelector("pods", peer.PodSelector))
+	}
+	if peer.NamespaceSelector != nil {
+		parts = append(parts, formatSelector("namespaces", peer.NamespaceSelector))
+	}
+	return strings.Join(parts, " in ")
+}
+
+func formatPort(port networkingv1.NetworkPolicyPort) string {
+	protocol := v1.ProtocolTCP
+	if port.Protocol != nil {
+		protocol = *port.Protocol
+	}
+	if port.Port == nil {
+		return fmt.Sprintf("%s/*", protocol)
+	}
+	if port.EndPort != nil {
+		return fmt.Sprintf("%s/%s-%d", protocol, port.Port.String(), *port.EndPort)
+	}
+	return fmt.Sprintf("%s/%s", protocol, port.Port.String())
+}
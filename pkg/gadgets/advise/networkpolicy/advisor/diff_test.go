@@ -0,0 +1,106 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advisor
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func tcpPort(port int) networkingv1.NetworkPolicyPort {
+	protocol := v1.ProtocolTCP
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
+
+func TestDiffPolicies(t *testing.T) {
+	generated := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-network", Namespace: "default"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{
+					Ports: []networkingv1.NetworkPolicyPort{tcpPort(5432)},
+					To: []networkingv1.NetworkPolicyPeer{
+						{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+					},
+				},
+				{
+					Ports: []networkingv1.NetworkPolicyPort{tcpPort(53)},
+					To:    []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.10/32"}}},
+				},
+			},
+		},
+	}
+	existing := []networkingv1.NetworkPolicy{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{
+						Ports: []networkingv1.NetworkPolicyPort{tcpPort(5432), tcpPort(6379)},
+						To: []networkingv1.NetworkPolicyPeer{
+							{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+						},
+					},
+				},
+			},
+		},
+		{
+			// Doesn't select the pods
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "other"}},
+				Ingress:     []networkingv1.NetworkPolicyIngressRule{{}},
+			},
+		},
+		{
+			// Different namespace
+			ObjectMeta: metav1.ObjectMeta{Name: "all", Namespace: "kube-system"},
+			Spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+			},
+		},
+	}
+
+	diffs := DiffPolicies([]networkingv1.NetworkPolicy{generated}, existing)
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d", len(diffs))
+	}
+
+	expected := `policy default/web-network
+  compared with: web
+  - egress to pods(app=db) on TCP/6379
+  + egress to 10.0.0.10/32 on TCP/53
+`
+	if out := FormatDiffs(diffs, false); out != expected {
+		t.Errorf("unexpected diff:\n%s\nExpected:\n%s", out, expected)
+	}
+
+	diffs = DiffPolicies([]networkingv1.NetworkPolicy{generated}, nil)
+	expected = `policy default/web-network
+  no existing network policy selects these pods
+  + egress to 10.0.0.10/32 on TCP/53
+  + egress to pods(app=db) on TCP/5432
+`
+	if out := FormatDiffs(diffs, false); out != expected {
+		t.Errorf("unexpected diff:\n%s\nExpected:\n%s", out, expected)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+)
+
+// Actions taken for the accesses breaking the rules, GUARD_ACTION_* in
+// file-guard.h
+const (
+	guardActionNone uint8 = 0
+	guardActionDeny uint8 = 1
+	guardActionKill uint8 = 2
+)
+
+// verdict returns the verdict reported for an access given the action taken
+// by the eBPF program
+func verdict(action uint8, dryRun bool) string {
+	switch {
+	case action == guardActionDeny && dryRun:
+		return types.VerdictWouldDeny
+	case action == guardActionDeny:
+		return types.VerdictDenied
+	case action == guardActionKill && dryRun:
+		return types.VerdictWouldKill
+	case action == guardActionKill:
+		return types.VerdictKilled
+	}
+	return types.VerdictAudited
+}
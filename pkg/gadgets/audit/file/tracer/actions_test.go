@@ -0,0 +1,42 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+)
+
+func TestVerdict(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		action   uint8
+		dryRun   bool
+		expected string
+	}{
+		{guardActionNone, false, types.VerdictAudited},
+		{guardActionNone, true, types.VerdictAudited},
+		{guardActionDeny, false, types.VerdictDenied},
+		{guardActionDeny, true, types.VerdictWouldDeny},
+		{guardActionKill, false, types.VerdictKilled},
+		{guardActionKill, true, types.VerdictWouldKill},
+	} {
+		require.Equal(t, test.expected, verdict(test.action, test.dryRun), "action %d, dry run %t", test.action, test.dryRun)
+	}
+}
@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+/* This BPF program uses the GPL-restricted function bpf_probe_read*().
+ */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+
+#include "file-guard.h"
+#include <gadget/mntns_filter.h>
+
+#define EPERM 1
+#define SIGKILL 9
+#define MAX_ERRNO 4095
+#define __FMODE_EXEC 0x20
+#define MAX_PATH_DEPTH 32
+#define MAX_RULES 1024
+
+// Take the action of the rule matching an access, deny or kill, instead of
+// only reporting it
+const volatile bool enforce = false;
+// Only report the action that would be taken, without taking it
+const volatile bool dry_run = false;
+// The process running the gadget, which is never acted upon
+const volatile __u32 self_tgid = 0;
+// Number of processes that can be killed by the kill rules, 0 for no limit.
+// The accesses are only denied, when possible, once it's reached.
+const volatile __u32 max_kills = 0;
+// Deny the accesses matching no rule, set when there are allow rules
+const volatile bool default_deny_open = false;
+const volatile bool default_deny_exec = false;
+
+// The rules are matched against the path of the file relative to the root of
+// the mount namespace of the process, the longest rule wins. Rules ending
+// with a null byte match a single file, the others all the paths starting
+// with them. Their value is GUARD_ALLOW, GUARD_DENY or GUARD_KILL.
+struct {
+	__uint(type, BPF_MAP_TYPE_LPM_TRIE);
+	__uint(max_entries, MAX_RULES);
+	__uint(map_flags, BPF_F_NO_PREALLOC);
+	__type(key, struct rule_key);
+	__type(value, __u8);
+} open_rules SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_LPM_TRIE);
+	__uint(max_entries, MAX_RULES);
+	__uint(map_flags, BPF_F_NO_PREALLOC);
+	__type(key, struct rule_key);
+	__type(value, __u8);
+} exec_rules SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, __u64);
+} kills SEC(".maps");
+
+struct path_buf {
+	__u8 buf[PATH_MAX_LEN * 2];
+};
+
+/* The stack is limited, so use maps to build the path and the event */
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct path_buf);
+} tmp_path SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct rule_key);
+} tmp_key SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERCPU_ARRAY);
+	__uint(max_entries, 1);
+	__type(key, __u32);
+	__type(value, struct event);
+} tmp_event SEC(".maps");
+
+struct {
+	__uint(type, BPF_MAP_TYPE_PERF_EVENT_ARRAY);
+} events SEC(".maps");
+
+// read_path writes the path of file, ending at buf[PATH_MAX_LEN - 1], and
+// returns its offset in buf. Components that don't fit are left out.
+static __always_inline __u32 read_path(struct file *file, __u8 *buf)
+{
+	__u32 mnt_offset = bpf_core_field_offset(struct mount, mnt);
+	struct vfsmount *vfsmnt = BPF_CORE_READ(file, f_path.mnt);
+	struct dentry *dentry = BPF_CORE_READ(file, f_path.dentry);
+	struct mount *mnt = (void *)vfsmnt - mnt_offset;
+	struct mount *mnt_parent = BPF_CORE_READ(mnt, mnt_parent);
+	__u32 off = PATH_MAX_LEN - 1;
+
+	buf[off] = 0;
+
+	for (int i = 0; i < MAX_PATH_DEPTH; i++) {
+		struct dentry *mnt_root = BPF_CORE_READ(vfsmnt, mnt_root);
+		struct dentry *d_parent = BPF_CORE_READ(dentry, d_parent);
+
+		if (dentry == mnt_root || dentry == d_parent) {
+			// Root of the mount namespace, or of a mount that isn't
+			// reachable from it
+			if (dentry != mnt_root || mnt == mnt_parent)
+				break;
+
+			// Continue with the mount point in the parent mount
+			dentry = BPF_CORE_READ(mnt, mnt_mountpoint);
+			mnt = mnt_parent;
+			mnt_parent = BPF_CORE_READ(mnt, mnt_parent);
+			vfsmnt = (void *)mnt + mnt_offset;
+			continue;
+		}
+
+		struct qstr d_name = BPF_CORE_READ(dentry, d_name);
+		if (d_name.len + 1 > off)
+			break;
+
+		off -= d_name.len;
+		bpf_probe_read_kernel(&buf[off & (PATH_MAX_LEN - 1)],
+				      d_name.len & (PATH_MAX_LEN - 1),
+				      d_name.name);
+		off--;
+		buf[off & (PATH_MAX_LEN - 1)] = '/';
+		dentry = d_parent;
+	}
+
+	if (off == PATH_MAX_LEN - 1) {
+		off--;
+		buf[off] = '/';
+	}
+
+	return off;
+}
+
+// take_kill counts a process killed and returns false if max_kills was
+// already reached
+static __always_inline bool take_kill(void)
+{
+	if (max_kills == 0)
+		return true;
+
+	__u32 zero = 0;
+	__u64 *count = bpf_map_lookup_elem(&kills, &zero);
+	if (!count || *count >= max_kills)
+		return false;
+
+	__sync_fetch_and_add(count, 1);
+	return true;
+}
+
+// check_file reports the access to file if it breaks the rules and, when
+// enforcing them, takes the action of the matching rule. can_deny is false when the access can't be denied
+// because the program isn't a BPF LSM one.
+static __always_inline int check_file(void *ctx, struct file *file, __u8 op,
+				      bool can_deny)
+{
+	__u64 mntns_id = gadget_get_mntns_id();
+	if (gadget_should_discard_mntns_id(mntns_id))
+		return 0;
+
+	__u32 zero = 0;
+	struct path_buf *path = bpf_map_lookup_elem(&tmp_path, &zero);
+	if (!path)
+		return 0;
+	struct rule_key *key = bpf_map_lookup_elem(&tmp_key, &zero);
+	if (!key)
+		return 0;
+
+	__u32 off = read_path(file, path->buf);
+	key->prefixlen = PATH_MAX_LEN * 8;
+	bpf_probe_read_kernel_str(key->path, sizeof(key->path),
+				  &path->buf[off & (PATH_MAX_LEN - 1)]);
+
+	__u8 *value;
+	bool default_deny;
+	if (op == GUARD_OP_EXEC) {
+		value = bpf_map_lookup_elem(&exec_rules, key);
+		default_deny = default_deny_exec;
+	} else {
+		value = bpf_map_lookup_elem(&open_rules, key);
+		default_deny = default_deny_open;
+	}
+
+	__u8 rule = value ? *value : default_deny ? GUARD_DENY : GUARD_ALLOW;
+	if (rule == GUARD_ALLOW)
+		return 0;
+
+	struct event *event = bpf_map_lookup_elem(&tmp_event, &zero);
+	if (!event)
+		return 0;
+
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u64 uid_gid = bpf_get_current_uid_gid();
+
+	// Dry runs don't count the processes that would be killed, so they
+	// report all of them and don't use up max_kills
+	__u8 guard_action = GUARD_ACTION_NONE;
+	if (enforce && pid_tgid >> 32 != self_tgid) {
+		if (rule == GUARD_KILL && (dry_run || take_kill()))
+			guard_action = GUARD_ACTION_KILL;
+		else if (can_deny)
+			guard_action = GUARD_ACTION_DENY;
+	}
+	if (guard_action == GUARD_ACTION_KILL && !dry_run)
+		bpf_send_signal(SIGKILL);
+
+	event->timestamp = bpf_ktime_get_boot_ns();
+	event->mntns_id = mntns_id;
+	event->pid = pid_tgid >> 32;
+	event->tid = pid_tgid;
+	event->uid = uid_gid;
+	event->gid = uid_gid >> 32;
+	event->op = op;
+	event->action = guard_action;
+	event->dry_run = dry_run;
+	bpf_get_current_comm(&event->comm, sizeof(event->comm));
+	bpf_probe_read_kernel(event->path, sizeof(event->path), key->path);
+
+	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, event,
+			      sizeof(*event));
+
+	if (guard_action == GUARD_ACTION_NONE || !can_deny || dry_run)
+		return 0;
+	return -EPERM;
+}
+
+SEC("lsm/file_open")
+int BPF_PROG(ig_guard_open, struct file *file, int ret)
+{
+	// Keep the denial of a previous BPF LSM program
+	if (ret < 0 && ret >= -MAX_ERRNO)
+		return ret;
+
+	// Executables are checked by ig_guard_exec
+	if (BPF_CORE_READ(file, f_flags) & __FMODE_EXEC)
+		return 0;
+
+	return check_file(ctx, file, GUARD_OP_OPEN, true);
+}
+
+SEC("lsm/bprm_check_security")
+int BPF_PROG(ig_guard_exec, struct linux_binprm *bprm, int ret)
+{
+	// Keep the denial of a previous BPF LSM program
+	if (ret < 0 && ret >= -MAX_ERRNO)
+		return ret;
+
+	return check_file(ctx, BPF_CORE_READ(bprm, file), GUARD_OP_EXEC, true);
+}
+
+// Used when the kernel doesn't support BPF LSM: the accesses can't be denied,
+// but the processes can still be killed
+SEC("fentry/security_file_open")
+int BPF_PROG(ig_guard_open_fe, struct file *file)
+{
+	__u8 op = BPF_CORE_READ(file, f_flags) & __FMODE_EXEC ? GUARD_OP_EXEC :
+								 GUARD_OP_OPEN;
+
+	check_file(ctx, file, op, false);
+	return 0;
+}
+
+char _license[] SEC("license") = "GPL";
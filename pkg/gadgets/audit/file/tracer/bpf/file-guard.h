@@ -0,0 +1,40 @@
+#ifndef GADGET_FILE_GUARD_H
+#define GADGET_FILE_GUARD_H
+
+#define TASK_COMM_LEN 16
+// The data of LPM trie keys can't be longer than 256 bytes
+#define PATH_MAX_LEN 256
+
+#define GUARD_OP_OPEN 0
+#define GUARD_OP_EXEC 1
+
+// Values of the rules
+#define GUARD_ALLOW 1
+#define GUARD_DENY 2
+#define GUARD_KILL 3
+
+// Action taken for an access breaking the rules
+#define GUARD_ACTION_NONE 0
+#define GUARD_ACTION_DENY 1
+#define GUARD_ACTION_KILL 2
+
+struct rule_key {
+	__u32 prefixlen;
+	__u8 path[PATH_MAX_LEN];
+};
+
+struct event {
+	__u64 timestamp;
+	__u64 mntns_id;
+	__u32 pid;
+	__u32 tid;
+	__u32 uid;
+	__u32 gid;
+	__u8 op;
+	__u8 action;
+	__u8 dry_run;
+	__u8 comm[TASK_COMM_LEN];
+	__u8 path[PATH_MAX_LEN];
+};
+
+#endif
@@ -0,0 +1,143 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"strings"
+
+	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
+)
+
+const (
+	ParamEnforce   = "enforce"
+	ParamMaxKills  = "max-kills"
+	ParamDryRun    = "dry-run"
+	ParamAllowOpen = "allow-open"
+	ParamDenyOpen  = "deny-open"
+	ParamKillOpen  = "kill-open"
+	ParamAllowExec = "allow-exec"
+	ParamDenyExec  = "deny-exec"
+	ParamKillExec  = "kill-exec"
+)
+
+type GadgetDesc struct{}
+
+func (g *GadgetDesc) Name() string {
+	return "file"
+}
+
+func (g *GadgetDesc) Category() string {
+	return gadgets.CategoryAudit
+}
+
+func (g *GadgetDesc) Type() gadgets.GadgetType {
+	return gadgets.TypeTrace
+}
+
+func (g *GadgetDesc) Description() string {
+	return "Audit or deny the files opened and executed in containers according to path rules"
+}
+
+func validatePaths(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, err := newRules(strings.Split(value, ","), nil, nil)
+	return err
+}
+
+func (g *GadgetDesc) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          ParamEnforce,
+			Title:        "Enforce",
+			DefaultValue: "false",
+			Description:  "Deny the accesses breaking the rules, or kill the processes making them for the kill rules, instead of only reporting them. Denying requires BPF LSM. Requires a container filter",
+			TypeHint:     params.TypeBool,
+		},
+		{
+			Key:          ParamMaxKills,
+			Title:        "Maximum kills",
+			DefaultValue: "10",
+			Description:  "Maximum number of processes killed by the kill rules, the accesses are only denied afterwards. 0 for no limit",
+			TypeHint:     params.TypeUint32,
+		},
+		{
+			Key:          ParamDryRun,
+			Title:        "Dry run",
+			DefaultValue: "false",
+			Description:  "Only report the accesses that would be denied or the processes that would be killed",
+			TypeHint:     params.TypeBool,
+		},
+		{
+			Key:         ParamAllowOpen,
+			Title:       "Allowed opened paths",
+			Description: "Comma-separated list of paths that can be opened, the others can't. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+		{
+			Key:         ParamDenyOpen,
+			Title:       "Denied opened paths",
+			Description: "Comma-separated list of paths that can't be opened. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+		{
+			Key:         ParamKillOpen,
+			Title:       "Killing opened paths",
+			Description: "Comma-separated list of paths that can't be opened, the processes opening them are killed with --enforce. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+		{
+			Key:         ParamAllowExec,
+			Title:       "Allowed executed paths",
+			Description: "Comma-separated list of binaries that can be executed, the others can't. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+		{
+			Key:         ParamDenyExec,
+			Title:       "Denied executed paths",
+			Description: "Comma-separated list of binaries that can't be executed. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+		{
+			Key:         ParamKillExec,
+			Title:       "Killing executed paths",
+			Description: "Comma-separated list of binaries that can't be executed, the processes executing them are killed with --enforce. Paths ending with '/' match all the paths under them",
+			TypeHint:    params.TypeStringSlice,
+			Validator:   validatePaths,
+		},
+	}
+}
+
+func (g *GadgetDesc) Parser() parser.Parser {
+	return parser.NewParser[types.Event](types.GetColumns())
+}
+
+func (g *GadgetDesc) EventPrototype() any {
+	return &types.Event{}
+}
+
+func init() {
+	gadgetregistry.Register(&GadgetDesc{})
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// pathMaxLen is PATH_MAX_LEN in file-guard.h
+	pathMaxLen = 256
+	// maxRules is MAX_RULES in file-guard.bpf.c
+	maxRules = 1024
+
+	// Values of the rules, GUARD_* in file-guard.h
+	actionAllow uint8 = 1
+	actionDeny  uint8 = 2
+	actionKill  uint8 = 3
+)
+
+// rule is an entry of the LPM tries of the eBPF program
+type rule struct {
+	// prefixlen is the number of bits of path matched by the rule
+	prefixlen uint32
+	path      [pathMaxLen]byte
+	action    uint8
+}
+
+// newRule creates the rule for path. The null byte ending the path is part
+// of the prefix for files, so only that path matches. It isn't for
+// directories, given with a trailing '/', so all the paths under them match.
+func newRule(path string, action uint8) (rule, error) {
+	if !filepath.IsAbs(path) {
+		return rule{}, fmt.Errorf("path %q is not absolute", path)
+	}
+
+	dir := strings.HasSuffix(path, "/")
+	path = filepath.Clean(path)
+	if dir && path != "/" {
+		path += "/"
+	}
+
+	// Keep room for the null byte
+	if len(path) >= pathMaxLen {
+		return rule{}, fmt.Errorf("path %q is longer than %d characters", path, pathMaxLen-1)
+	}
+
+	r := rule{action: action}
+	copy(r.path[:], path)
+	r.prefixlen = uint32(len(path)) * 8
+	if !dir {
+		r.prefixlen += 8
+	}
+
+	return r, nil
+}
+
+// newRules creates the rules for the allowed, denied and killing paths of an
+// operation
+func newRules(allow, deny, kill []string) ([]rule, error) {
+	// actions of the rules indexed by their path
+	seen := make(map[rule]uint8)
+	rules := make([]rule, 0, len(allow)+len(deny)+len(kill))
+
+	add := func(paths []string, action uint8) error {
+		for _, path := range paths {
+			r, err := newRule(path, action)
+			if err != nil {
+				return err
+			}
+
+			key := r
+			key.action = 0
+			if other, ok := seen[key]; ok {
+				if other != action {
+					return fmt.Errorf("path %q is in several lists of rules", path)
+				}
+				continue
+			}
+
+			seen[key] = action
+			rules = append(rules, r)
+		}
+		return nil
+	}
+
+	if err := add(allow, actionAllow); err != nil {
+		return nil, err
+	}
+	if err := add(deny, actionDeny); err != nil {
+		return nil, err
+	}
+	if err := add(kill, actionKill); err != nil {
+		return nil, err
+	}
+
+	if len(rules) > maxRules {
+		return nil, fmt.Errorf("too many rules: %d, the maximum is %d", len(rules), maxRules)
+	}
+
+	return rules, nil
+}
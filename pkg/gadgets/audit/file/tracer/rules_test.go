@@ -0,0 +1,82 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRule(t *testing.T) {
+	t.Parallel()
+
+	for path, expected := range map[string]struct {
+		path      string
+		prefixlen uint32
+	}{
+		// Files match with their null byte
+		"/bin/sh":         {"/bin/sh", 8 * 8},
+		"/usr//bin/../sh": {"/usr/sh", 8 * 8},
+		// Directories match all the paths under them
+		"/etc/":  {"/etc/", 5 * 8},
+		"/etc//": {"/etc/", 5 * 8},
+		"/":      {"/", 8},
+	} {
+		r, err := newRule(path, actionDeny)
+		require.NoError(t, err, path)
+		require.Equal(t, expected.path, string(r.path[:len(expected.path)]), path)
+		require.Equal(t, expected.prefixlen, r.prefixlen, path)
+		require.Equal(t, actionDeny, r.action, path)
+	}
+}
+
+func TestNewRulesInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, rules := range []struct {
+		allow []string
+		deny  []string
+		kill  []string
+	}{
+		{allow: []string{"bin/sh"}},
+		{deny: []string{""}},
+		{kill: []string{"sh"}},
+		{deny: []string{"/" + strings.Repeat("a", pathMaxLen-1)}},
+		{allow: []string{"/bin/sh"}, deny: []string{"/bin/../bin/sh"}},
+		{deny: []string{"/bin/sh"}, kill: []string{"/bin/sh"}},
+		{allow: []string{"/etc/"}, kill: []string{"/etc//"}},
+	} {
+		_, err := newRules(rules.allow, rules.deny, rules.kill)
+		require.Error(t, err, rules)
+	}
+}
+
+func TestNewRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := newRules(
+		[]string{"/usr/bin/", "/usr/bin/"},
+		[]string{"/usr/bin", "/usr/bin/su"},
+		[]string{"/var/run/docker.sock", "/var/run/docker.sock"},
+	)
+	require.NoError(t, err)
+	require.Len(t, rules, 4)
+	require.Equal(t, actionAllow, rules[0].action)
+	require.Equal(t, actionDeny, rules[1].action)
+	require.Equal(t, actionDeny, rules[2].action)
+	require.Equal(t, actionKill, rules[3].action)
+}
@@ -0,0 +1,407 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -type event -type rule_key -cc clang -cflags ${CFLAGS} fileguard ./bpf/file-guard.bpf.c -- -I./bpf/ -D__KERNEL__
+
+const (
+	// opExec is GUARD_OP_EXEC in file-guard.h
+	opExec = 1
+
+	lsmFile = "/sys/kernel/security/lsm"
+)
+
+// lsmObjects are the objects loaded when the kernel supports BPF LSM: the
+// accesses can be denied.
+type lsmObjects struct {
+	fileguardMaps
+	IgGuardOpen *ebpf.Program `ebpf:"ig_guard_open"`
+	IgGuardExec *ebpf.Program `ebpf:"ig_guard_exec"`
+}
+
+// fentryObjects are the objects loaded otherwise: the accesses can only be
+// reported.
+type fentryObjects struct {
+	fileguardMaps
+	IgGuardOpenFe *ebpf.Program `ebpf:"ig_guard_open_fe"`
+}
+
+type Tracer struct {
+	config        *Config
+	enricher      gadgets.DataEnricherByMntNs
+	eventCallback func(*types.Event)
+	logger        logger.Logger
+
+	maps     fileguardMaps
+	programs []*ebpf.Program
+	reader   *gadgets.BufferReader
+
+	// links keeps references to the links of the BPF programs, so they can
+	// be closed explicitly, otherwise the garbage collector might unlink
+	// them via the finalizer at any moment.
+	links []link.Link
+}
+
+type Config struct {
+	MountnsMap      *ebpf.Map
+	PerfBufferPages uint32
+
+	// Enforce takes the action of the rules matching the accesses breaking
+	// them: they are denied, which is only possible when the kernel
+	// supports BPF LSM, or the processes making them are killed for the
+	// kill rules. At most MaxKills processes are killed if it's not 0, the
+	// accesses are only denied afterwards.
+	Enforce  bool
+	MaxKills uint32
+
+	// DryRun only reports the action that would be taken for the accesses
+	// breaking the rules, without denying them nor killing the processes
+	DryRun bool
+
+	// AllowOpen and AllowExec are the only paths that can be opened and
+	// executed if set. DenyOpen and DenyExec are paths that can't,
+	// KillOpen and KillExec too but the processes accessing them are also
+	// killed. The longest path matching a file decides. Paths ending with
+	// '/' match all the paths under them.
+	AllowOpen []string
+	DenyOpen  []string
+	KillOpen  []string
+	AllowExec []string
+	DenyExec  []string
+	KillExec  []string
+}
+
+func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
+	eventCallback func(*types.Event),
+) (*Tracer, error) {
+	t := &Tracer{
+		config:        config,
+		enricher:      enricher,
+		eventCallback: eventCallback,
+		logger:        logger.DefaultLogger(),
+	}
+
+	if err := t.install(); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+// bpfLSMEnabled tells if BPF LSM is in the list of active LSMs. It's
+// considered enabled when the list can't be read, e.g. because securityfs
+// isn't mounted, then attaching the programs fails if it isn't.
+func bpfLSMEnabled() bool {
+	lsms, err := os.ReadFile(lsmFile)
+	if err != nil {
+		return true
+	}
+	return slices.Contains(strings.Split(strings.TrimSpace(string(lsms)), ","), "bpf")
+}
+
+func (t *Tracer) install() error {
+	// Processes of the host must never be affected. Dry runs can't affect
+	// them, so they can be used to check the rules beforehand.
+	if t.config.Enforce && !t.config.DryRun && t.config.MountnsMap == nil {
+		return errors.New("enforcing the rules requires filtering the containers")
+	}
+
+	openRules, err := newRules(t.config.AllowOpen, t.config.DenyOpen, t.config.KillOpen)
+	if err != nil {
+		return fmt.Errorf("creating open rules: %w", err)
+	}
+	execRules, err := newRules(t.config.AllowExec, t.config.DenyExec, t.config.KillExec)
+	if err != nil {
+		return fmt.Errorf("creating exec rules: %w", err)
+	}
+
+	spec, err := loadFileguard()
+	if err != nil {
+		return fmt.Errorf("loading ebpf program: %w", err)
+	}
+
+	consts := map[string]interface{}{
+		"default_deny_open": len(t.config.AllowOpen) > 0,
+		"default_deny_exec": len(t.config.AllowExec) > 0,
+		// Dry runs report what enforcing the rules would do
+		"enforce":   t.config.Enforce || t.config.DryRun,
+		"max_kills": t.config.MaxKills,
+		"dry_run":   t.config.DryRun,
+		// The gadget must never kill itself, e.g. if it's run in one of
+		// the selected containers
+		"self_tgid": uint32(os.Getpid()),
+	}
+
+	if bpfLSMEnabled() {
+		err = t.installLSM(spec.Copy(), consts, openRules, execRules)
+		if err == nil {
+			return t.startReader()
+		}
+
+		t.closeObjects()
+		err = fmt.Errorf("installing BPF LSM programs: %w", err)
+	} else {
+		err = errors.New("BPF LSM isn't enabled on this kernel")
+	}
+
+	if t.config.Enforce || t.config.DryRun {
+		msg := fmt.Sprintf("%s: the accesses breaking the rules can't be denied", err)
+		t.eventCallback(types.Base(eventtypes.Warn(msg)))
+	}
+
+	if err := t.installFentry(spec, consts, openRules, execRules); err != nil {
+		return err
+	}
+
+	return t.startReader()
+}
+
+func (t *Tracer) installLSM(spec *ebpf.CollectionSpec, consts map[string]interface{},
+	openRules, execRules []rule,
+) error {
+	var objs lsmObjects
+	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &objs); err != nil {
+		return fmt.Errorf("loading ebpf spec: %w", err)
+	}
+	t.maps = objs.fileguardMaps
+	t.programs = []*ebpf.Program{objs.IgGuardOpen, objs.IgGuardExec}
+
+	if err := t.fillRules(openRules, execRules); err != nil {
+		return err
+	}
+
+	for _, prog := range t.programs {
+		l, err := link.AttachLSM(link.LSMOptions{Program: prog})
+		if err != nil {
+			return fmt.Errorf("attaching LSM program: %w", err)
+		}
+		t.links = append(t.links, l)
+	}
+
+	return nil
+}
+
+func (t *Tracer) installFentry(spec *ebpf.CollectionSpec, consts map[string]interface{},
+	openRules, execRules []rule,
+) error {
+	var objs fentryObjects
+	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &objs); err != nil {
+		return fmt.Errorf("loading ebpf spec: %w", err)
+	}
+	t.maps = objs.fileguardMaps
+	t.programs = []*ebpf.Program{objs.IgGuardOpenFe}
+
+	if err := t.fillRules(openRules, execRules); err != nil {
+		return err
+	}
+
+	l, err := link.AttachTracing(link.TracingOptions{Program: objs.IgGuardOpenFe})
+	if err != nil {
+		return fmt.Errorf("attaching fentry program: %w", err)
+	}
+	t.links = append(t.links, l)
+
+	return nil
+}
+
+func (t *Tracer) fillRules(openRules, execRules []rule) error {
+	for _, rules := range []struct {
+		m     *ebpf.Map
+		rules []rule
+	}{
+		{t.maps.OpenRules, openRules},
+		{t.maps.ExecRules, execRules},
+	} {
+		for _, r := range rules.rules {
+			key := fileguardRuleKey{Prefixlen: r.prefixlen, Path: r.path}
+			if err := rules.m.Put(key, r.action); err != nil {
+				return fmt.Errorf("adding rule for %q: %w", gadgets.FromCString(r.path[:]), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (t *Tracer) startReader() error {
+	var err error
+
+	t.reader, err = gadgets.NewBufferReader(t.maps.Events, t.config.PerfBufferPages)
+	if err != nil {
+		return fmt.Errorf("creating buffer reader: %w", err)
+	}
+
+	return gadgets.FreezeMaps(t.maps.Events)
+}
+
+func (t *Tracer) run() {
+	var record gadgets.BufferRecord
+	for {
+		err := t.reader.ReadInto(&record)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				// nothing to do, we're done
+				return
+			}
+
+			msg := fmt.Sprintf("Error reading perf ring buffer: %s", err)
+			t.eventCallback(types.Base(eventtypes.Err(msg)))
+			return
+		}
+
+		if record.LostSamples > 0 {
+			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
+			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			continue
+		}
+
+		eventC := (*fileguardEvent)(unsafe.Pointer(&record.RawSample[0]))
+
+		operation := types.OperationOpen
+		if eventC.Op == opExec {
+			operation = types.OperationExec
+		}
+
+		event := types.Event{
+			Event: eventtypes.Event{
+				Type:      eventtypes.NORMAL,
+				Timestamp: gadgets.WallTimeFromBootTime(eventC.Timestamp),
+			},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: eventC.MntnsId},
+			Pid:           eventC.Pid,
+			Tid:           eventC.Tid,
+			Uid:           eventC.Uid,
+			Gid:           eventC.Gid,
+			Comm:          gadgets.FromCString(eventC.Comm[:]),
+			Operation:     operation,
+			Verdict:       verdict(eventC.Action, eventC.DryRun != 0),
+			Path:          gadgets.FromCString(eventC.Path[:]),
+		}
+
+		if t.enricher != nil {
+			t.enricher.EnrichByMntNs(&event.CommonData, event.MountNsID)
+		}
+
+		if eventC.Action != guardActionNone {
+			t.logAction(&event)
+		}
+
+		t.eventCallback(&event)
+	}
+}
+
+// logAction keeps a trace of the actions taken in the logs, besides the
+// events, as they affect the workloads
+func (t *Tracer) logAction(event *types.Event) {
+	if t.logger == nil {
+		return
+	}
+	t.logger.Infof("audit file: %s: %s of %q by pid %d (%s) in container %q of pod %s/%s",
+		event.Verdict, event.Operation, event.Path, event.Pid, event.Comm,
+		event.K8s.ContainerName, event.K8s.Namespace, event.K8s.PodName)
+}
+
+func (t *Tracer) closeObjects() {
+	for i := range t.links {
+		t.links[i] = gadgets.CloseLink(t.links[i])
+	}
+	t.links = nil
+	for _, prog := range t.programs {
+		if prog != nil {
+			prog.Close()
+		}
+	}
+	t.programs = nil
+	t.maps.Close()
+	t.maps = fileguardMaps{}
+}
+
+// Close closes the tracer
+// TODO: Unexport this function when the refactoring is done
+func (t *Tracer) Close() {
+	if t.reader != nil {
+		t.reader.Close()
+	}
+	t.closeObjects()
+}
+
+// ---
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	params := gadgetCtx.GadgetParams()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
+	t.config.Enforce = params.Get(ParamEnforce).AsBool()
+	t.config.MaxKills = params.Get(ParamMaxKills).AsUint32()
+	t.config.DryRun = params.Get(ParamDryRun).AsBool()
+	t.logger = gadgetCtx.Logger()
+	t.config.AllowOpen = params.Get(ParamAllowOpen).AsStringSlice()
+	t.config.DenyOpen = params.Get(ParamDenyOpen).AsStringSlice()
+	t.config.KillOpen = params.Get(ParamKillOpen).AsStringSlice()
+	t.config.AllowExec = params.Get(ParamAllowExec).AsStringSlice()
+	t.config.DenyExec = params.Get(ParamDenyExec).AsStringSlice()
+	t.config.KillExec = params.Get(ParamKillExec).AsStringSlice()
+
+	defer t.Close()
+	if err := t.install(); err != nil {
+		return fmt.Errorf("installing tracer: %w", err)
+	}
+
+	go t.run()
+	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
+
+	return nil
+}
+
+func (t *Tracer) SetMountNsMap(mountnsMap *ebpf.Map) {
+	t.config.MountnsMap = mountnsMap
+}
+
+func (t *Tracer) SetEventHandler(handler any) {
+	nh, ok := handler.(func(ev *types.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventCallback = nh
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	t := &Tracer{
+		config: &Config{},
+	}
+	return t, nil
+}
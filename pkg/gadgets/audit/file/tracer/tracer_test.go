@@ -0,0 +1,165 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/audit/file/types"
+)
+
+// runGuardedOpens starts a tracer with config and opens path n times from
+// child processes, as the process running the tracer is never acted upon. It
+// returns the verdicts of the events and how the children exited.
+func runGuardedOpens(t *testing.T, config *Config, path string, n int) ([]string, []error) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var verdicts []string
+	tracer, err := NewTracer(config, nil, func(event *types.Event) {
+		if event.Path != path {
+			return
+		}
+		mu.Lock()
+		verdicts = append(verdicts, event.Verdict)
+		mu.Unlock()
+	})
+	require.NoError(t, err)
+	t.Cleanup(tracer.Close)
+
+	results := make([]error, 0, n)
+	for i := 0; i < n; i++ {
+		results = append(results, exec.Command("cat", path).Run())
+	}
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(verdicts) >= n
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var kills uint64
+	require.NoError(t, tracer.maps.Kills.Lookup(uint32(0), &kills))
+	t.Logf("processes killed: %d", kills)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return verdicts, results
+}
+
+func guardedFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "guarded")
+	require.NoError(t, os.WriteFile(path, []byte("secret"), 0o600))
+	return path
+}
+
+func killedBySignal(err error) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == syscall.SIGKILL
+}
+
+func TestAuditFileDryRunKills(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	path := guardedFile(t)
+	config := &Config{
+		DryRun:   true,
+		MaxKills: 2,
+		KillOpen: []string{path},
+	}
+
+	// All the processes that would be killed are reported, even beyond
+	// max-kills, and none is killed nor denied
+	verdicts, results := runGuardedOpens(t, config, path, 4)
+	require.Equal(t, []string{
+		types.VerdictWouldKill,
+		types.VerdictWouldKill,
+		types.VerdictWouldKill,
+		types.VerdictWouldKill,
+	}, verdicts)
+	for _, err := range results {
+		require.NoError(t, err)
+	}
+}
+
+func TestAuditFileKills(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	mntns, err := containerutils.GetMntNs(os.Getpid())
+	require.NoError(t, err)
+
+	path := guardedFile(t)
+	config := &Config{
+		MountnsMap: utilstest.CreateMntNsFilterMap(t, mntns),
+		Enforce:    true,
+		MaxKills:   2,
+		KillOpen:   []string{path},
+	}
+
+	verdicts, results := runGuardedOpens(t, config, path, 3)
+
+	// Once max-kills is reached, the accesses are only denied, when BPF LSM
+	// is available
+	last := types.VerdictAudited
+	if bpfLSMEnabled() {
+		last = types.VerdictDenied
+	}
+	require.Equal(t, []string{types.VerdictKilled, types.VerdictKilled, last}, verdicts)
+	require.True(t, killedBySignal(results[0]), results[0])
+	require.True(t, killedBySignal(results[1]), results[1])
+	require.False(t, killedBySignal(results[2]), results[2])
+}
+
+func TestAuditFileDenyRule(t *testing.T) {
+	utilstest.RequireRoot(t)
+	if !bpfLSMEnabled() {
+		t.Skip("BPF LSM isn't enabled on this kernel")
+	}
+
+	mntns, err := containerutils.GetMntNs(os.Getpid())
+	require.NoError(t, err)
+
+	path := guardedFile(t)
+	config := &Config{
+		MountnsMap: utilstest.CreateMntNsFilterMap(t, mntns),
+		Enforce:    true,
+		DenyOpen:   []string{path},
+	}
+
+	// Deny rules never kill the processes
+	verdicts, results := runGuardedOpens(t, config, path, 2)
+	require.Equal(t, []string{types.VerdictDenied, types.VerdictDenied}, verdicts)
+	for _, err := range results {
+		require.Error(t, err)
+		require.False(t, killedBySignal(err), err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	OperationOpen = "open"
+	OperationExec = "exec"
+)
+
+const (
+	// VerdictDenied is set when the access was denied by the gadget
+	VerdictDenied = "denied"
+	// VerdictKilled is set when the process making the access was killed
+	VerdictKilled = "killed"
+	// VerdictAudited is set when the access would have been denied but the
+	// gadget doesn't enforce the rules, or can't because the kernel doesn't
+	// support BPF LSM
+	VerdictAudited = "audited"
+	// VerdictWouldDeny and VerdictWouldKill are set in dry-run mode, when the
+	// access would have been denied or the process killed
+	VerdictWouldDeny = "would-deny"
+	VerdictWouldKill = "would-kill"
+)
+
+type Event struct {
+	eventtypes.Event
+	eventtypes.WithMountNsID
+
+	Pid       uint32 `json:"pid,omitempty" column:"pid,template:pid"`
+	Tid       uint32 `json:"tid,omitempty" column:"tid,template:pid,hide"`
+	Uid       uint32 `json:"uid" column:"uid,template:uid,hide"`
+	Gid       uint32 `json:"gid" column:"gid,template:gid,hide"`
+	Comm      string `json:"comm,omitempty" column:"comm,template:comm"`
+	Operation string `json:"operation,omitempty" column:"operation,width:9,fixed"`
+	Verdict   string `json:"verdict,omitempty" column:"verdict,width:10,fixed"`
+	Path      string `json:"path,omitempty" column:"path,width:64"`
+}
+
+func GetColumns() *columns.Columns[Event] {
+	return columns.MustCreateColumns[Event]()
+}
+
+func Base(ev eventtypes.Event) *Event {
+	return &Event{
+		Event: ev,
+	}
+}
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -40,7 +39,7 @@ type Tracer struct {
 	eventCallback func(*types.Event)
 
 	objs   auditseccompObjects
-	reader *perf.Reader
+	reader *gadgets.BufferReader
 
 	// progLink links the BPF program to the tracepoint.
 	// A reference is kept so it can be closed it explicitly, otherwise
@@ -50,7 +49,8 @@ type Tracer struct {
 }
 
 type Config struct {
-	MountnsMap *ebpf.Map
+	MountnsMap      *ebpf.Map
+	PerfBufferPages uint32
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -82,9 +82,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = gadgets.NewBufferReader(t.objs.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("getting a perf reader: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.objs.Events); err != nil {
@@ -100,10 +100,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -154,6 +155,8 @@ func (t *Tracer) Close() {
 // ---
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+
 	defer t.Close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
@@ -0,0 +1,415 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package gadgets
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/btf"
+	"github.com/cilium/ebpf/features"
+	"github.com/cilium/ebpf/perf"
+	"github.com/cilium/ebpf/ringbuf"
+)
+
+var (
+	onceRingBuf sync.Once
+	hasRingBuf  bool
+)
+
+// HasRingBuf returns true if the kernel supports BPF ring buffers, added in
+// Linux 5.8.
+func HasRingBuf() bool {
+	onceRingBuf.Do(func() {
+		hasRingBuf = features.HaveMapType(ebpf.RingBuf) == nil
+	})
+	return hasRingBuf
+}
+
+// FixRingBufMaps turns the ring buffers of the given map specs into perf event
+// arrays if the kernel doesn't support ring buffers. The eBPF programs have to
+// write to them with the helpers of include/gadget/buffer.h, which fall back
+// to bpf_perf_event_output() on these kernels.
+func FixRingBufMaps(mapSpecs map[string]*ebpf.MapSpec) {
+	if HasRingBuf() {
+		return
+	}
+
+	for _, m := range mapSpecs {
+		if m.Type != ebpf.RingBuf {
+			continue
+		}
+		m.Type = ebpf.PerfEventArray
+		m.KeySize = 4
+		m.ValueSize = 4
+		// Set to the number of CPUs by cilium/ebpf
+		m.MaxEntries = 0
+	}
+}
+
+// ringBufSize is the size of the ring buffers replacing the perf event arrays
+// of the eBPF programs rewritten by rewriteEventOutput.
+const ringBufSize = 1024 * 1024
+
+// outputFuncName is the name of the function replacing the calls to
+// bpf_perf_event_output() in the eBPF programs rewritten by
+// rewriteEventOutput.
+const outputFuncName = "gadget_output"
+
+// outputFuncType is the BTF of the function named outputFuncName, required by
+// the kernel when the programs have BTF.
+var outputFuncType = &btf.Func{
+	Name: outputFuncName,
+	Type: &btf.FuncProto{
+		Return: &btf.Int{Name: "long", Size: 8, Encoding: btf.Signed},
+		Params: []btf.FuncParam{
+			{Name: "ctx", Type: &btf.Pointer{Target: &btf.Void{}}},
+			{Name: "map", Type: &btf.Pointer{Target: &btf.Void{}}},
+			{Name: "flags", Type: &btf.Int{Name: "__u64", Size: 8}},
+			{Name: "data", Type: &btf.Pointer{Target: &btf.Void{}}},
+			{Name: "size", Type: &btf.Int{Name: "__u64", Size: 8}},
+		},
+	},
+	Linkage: btf.StaticFunc,
+}
+
+// RewriteEventOutput makes the eBPF programs of spec not built with
+// include/gadget/buffer.h behave like the ones using its gadget_output_buf():
+// they send their events with a ring buffer if the kernel supports them, and
+// drop them according to sampling.
+func RewriteEventOutput(spec *ebpf.CollectionSpec, sampling Sampling) {
+	rewriteEventOutput(spec, HasRingBuf(), sampling)
+}
+
+// rewriteEventOutput replaces the calls to bpf_perf_event_output() of the
+// programs of spec by calls to a function added to each program, which drops
+// the events according to sampling like gadget_should_drop_event() does and
+// sends the other ones. If ringBuf is true, the perf event arrays written by
+// the programs are turned into ring buffers, written with
+// bpf_ringbuf_output().
+func rewriteEventOutput(spec *ebpf.CollectionSpec, ringBuf bool, sampling Sampling) {
+	calls := make(map[*ebpf.ProgramSpec][]int)
+	outputMaps := make(map[string]int)
+	for _, prog := range spec.Programs {
+		progCalls := perfEventOutputCalls(spec, prog.Instructions)
+		if len(progCalls) == 0 {
+			continue
+		}
+		calls[prog] = progCalls
+
+		for _, i := range progCalls {
+			m := outputMap(prog.Instructions, i)
+			if m == "" {
+				// The map can't be turned into a ring buffer
+				// without knowing it
+				ringBuf = false
+			}
+			outputMaps[m]++
+		}
+	}
+
+	// The perf event arrays can only be turned into ring buffers if they
+	// aren't used by other instructions than the rewritten calls
+	if ringBuf {
+		for _, prog := range spec.Programs {
+			for _, ins := range prog.Instructions {
+				if _, ok := outputMaps[ins.Reference()]; ok && ins.IsLoadFromMap() {
+					outputMaps[ins.Reference()]--
+				}
+			}
+		}
+		for name, refs := range outputMaps {
+			if m, ok := spec.Maps[name]; !ok || m.Type != ebpf.PerfEventArray || refs != 0 {
+				ringBuf = false
+			}
+		}
+	}
+
+	if len(calls) == 0 || (!ringBuf && !sampling.Enabled()) {
+		return
+	}
+
+	if ringBuf {
+		for name := range outputMaps {
+			m := spec.Maps[name]
+			m.Type = ebpf.RingBuf
+			m.KeySize = 0
+			m.ValueSize = 0
+			m.Key = nil
+			m.Value = nil
+			m.MaxEntries = ringBufSize
+		}
+	}
+
+	for prog, progCalls := range calls {
+		for _, i := range progCalls {
+			call := asm.Call.Label(outputFuncName)
+			call.Metadata = prog.Instructions[i].Metadata
+			prog.Instructions[i] = call.WithReference(outputFuncName)
+		}
+		prog.Instructions = append(prog.Instructions, outputFunc(prog.Instructions, ringBuf, sampling)...)
+	}
+
+	if sampling.MaxEventsPerSecond > 0 {
+		if _, ok := spec.Maps[RateLimitMapName]; !ok {
+			spec.Maps[RateLimitMapName] = &ebpf.MapSpec{
+				Name:       RateLimitMapName,
+				Type:       ebpf.Array,
+				KeySize:    4,
+				ValueSize:  16,
+				MaxEntries: 1,
+			}
+		}
+	}
+}
+
+// perfEventOutputCalls returns the indexes of the calls to
+// bpf_perf_event_output() of insns to rewrite. Programs using tail calls are
+// left untouched, as old kernels don't allow them to call functions.
+func perfEventOutputCalls(spec *ebpf.CollectionSpec, insns asm.Instructions) []int {
+	var calls []int
+	for i := range insns {
+		ins := &insns[i]
+		if !ins.IsBuiltinCall() {
+			continue
+		}
+		switch asm.BuiltinFunc(ins.Constant) {
+		case asm.FnTailCall:
+			return nil
+		case asm.FnPerfEventOutput:
+			// Calls with a ring buffer come from
+			// include/gadget/buffer.h on kernels without ring buffers
+			if m, ok := spec.Maps[outputMap(insns, i)]; ok && m.Type == ebpf.RingBuf {
+				continue
+			}
+			calls = append(calls, i)
+		}
+	}
+	return calls
+}
+
+// outputMap returns the name of the map given to the call at index i of insns,
+// or an empty string if it isn't found in the instructions preceding the call.
+func outputMap(insns asm.Instructions, i int) string {
+	for i--; i >= 0; i-- {
+		ins := &insns[i]
+		if ins.OpCode.Class().IsJump() {
+			return ""
+		}
+		if ins.Dst != asm.R2 || (!ins.OpCode.Class().IsALU() && !ins.OpCode.Class().IsLoad()) {
+			continue
+		}
+		if ins.IsLoadFromMap() {
+			return ins.Reference()
+		}
+		return ""
+	}
+	return ""
+}
+
+// outputFunc returns the instructions of the function called instead of
+// bpf_perf_event_output() by the program made of insns. It has the same
+// arguments and return value.
+func outputFunc(insns asm.Instructions, ringBuf bool, sampling Sampling) asm.Instructions {
+	const (
+		drop   = outputFuncName + "_drop"
+		count  = outputFuncName + "_count"
+		output = outputFuncName + "_output"
+	)
+
+	// Registers holding the map, flags, data and size arguments
+	args := [4]asm.Register{asm.R2, asm.R3, asm.R4, asm.R5}
+
+	var fn asm.Instructions
+	if sampling.Enabled() {
+		// The arguments are kept in callee saved registers across the
+		// helper calls, except the context which is spilled to the
+		// stack
+		args = [4]asm.Register{asm.R6, asm.R7, asm.R8, asm.R9}
+		fn = asm.Instructions{
+			asm.StoreMem(asm.RFP, -8, asm.R1, asm.DWord),
+			asm.Mov.Reg(asm.R6, asm.R2),
+			asm.Mov.Reg(asm.R7, asm.R3),
+			asm.Mov.Reg(asm.R8, asm.R4),
+			asm.Mov.Reg(asm.R9, asm.R5),
+		}
+	}
+
+	if sampling.Rate > 1 {
+		fn = append(fn,
+			asm.FnGetPrandomU32.Call(),
+			asm.Mod.Imm32(asm.R0, int32(sampling.Rate)),
+			asm.JNE.Imm(asm.R0, 0, drop),
+		)
+	}
+
+	if sampling.MaxEventsPerSecond > 0 {
+		countAdd := asm.StoreXAdd(asm.R0, asm.R1, asm.DWord)
+		countAdd.Offset = 8
+
+		fn = append(fn,
+			asm.FnKtimeGetBootNs.Call(),
+			asm.StoreMem(asm.RFP, -16, asm.R0, asm.DWord),
+			asm.StoreImm(asm.RFP, -20, 0, asm.Word),
+			asm.LoadMapPtr(asm.R1, 0).WithReference(RateLimitMapName),
+			asm.Mov.Reg(asm.R2, asm.RFP),
+			asm.Add.Imm(asm.R2, -20),
+			asm.FnMapLookupElem.Call(),
+			asm.JEq.Imm(asm.R0, 0, output),
+			// A new window starts every second
+			asm.LoadMem(asm.R1, asm.RFP, -16, asm.DWord),
+			asm.LoadMem(asm.R2, asm.R0, 0, asm.DWord),
+			asm.Mov.Reg(asm.R3, asm.R1),
+			asm.Sub.Reg(asm.R3, asm.R2),
+			asm.JLT.Imm(asm.R3, 1000000000, count),
+			asm.StoreMem(asm.R0, 0, asm.R1, asm.DWord),
+			asm.StoreImm(asm.R0, 8, 0, asm.DWord),
+			asm.Mov.Imm(asm.R1, 1).WithSymbol(count),
+			countAdd,
+			asm.LoadMem(asm.R1, asm.R0, 8, asm.DWord),
+			asm.Mov.Imm32(asm.R2, int32(sampling.MaxEventsPerSecond)),
+			asm.JGT.Reg(asm.R1, asm.R2, drop),
+		)
+	}
+
+	if ringBuf {
+		// bpf_ringbuf_output(map, data, size, 0)
+		fn = append(fn,
+			asm.Mov.Reg(asm.R1, args[0]).WithSymbol(output),
+			asm.Mov.Reg(asm.R2, args[2]),
+			asm.Mov.Reg(asm.R3, args[3]),
+			asm.Mov.Imm(asm.R4, 0),
+			asm.FnRingbufOutput.Call(),
+			asm.Return(),
+		)
+	} else if sampling.Enabled() {
+		fn = append(fn,
+			asm.LoadMem(asm.R1, asm.RFP, -8, asm.DWord).WithSymbol(output),
+			asm.Mov.Reg(asm.R2, args[0]),
+			asm.Mov.Reg(asm.R3, args[1]),
+			asm.Mov.Reg(asm.R4, args[2]),
+			asm.Mov.Reg(asm.R5, args[3]),
+			asm.FnPerfEventOutput.Call(),
+			asm.Return(),
+		)
+	}
+
+	if sampling.Enabled() {
+		fn = append(fn,
+			asm.Mov.Imm(asm.R0, 0).WithSymbol(drop),
+			asm.Return(),
+		)
+	}
+
+	fn[0] = fn[0].WithSymbol(outputFuncName)
+	if btf.FuncMetadata(&insns[0]) != nil {
+		fn[0] = btf.WithFuncMetadata(fn[0], outputFuncType)
+		// The kernel requires line info at the start of each function
+		for _, ins := range insns {
+			if line, ok := ins.Source().(*btf.Line); ok {
+				fn[0] = fn[0].WithSource(line)
+				break
+			}
+		}
+	}
+
+	return fn
+}
+
+// BufferRecord is an event read by a BufferReader.
+type BufferRecord struct {
+	RawSample []byte
+
+	// LostSamples is the number of events lost because the perf buffer was
+	// full. It's always zero with ring buffers.
+	LostSamples uint64
+
+	perfRecord    perf.Record
+	ringbufRecord ringbuf.Record
+}
+
+// BufferReader reads the events sent to user space by the eBPF programs,
+// from a ring buffer or a perf event array depending on the type of the map.
+// Ring buffers are shared by all the CPUs: they use less memory than perf
+// buffers, which are allocated per CPU, and lose fewer events with bursts on
+// a few CPUs.
+type BufferReader struct {
+	perfReader    *perf.Reader
+	ringbufReader *ringbuf.Reader
+}
+
+// NewBufferReader creates a reader for the given ring buffer or perf event
+// array. perfBufferPages is the number of pages per CPU of perf buffers, see
+// PerfBufferSize.
+func NewBufferReader(m *ebpf.Map, perfBufferPages uint32) (*BufferReader, error) {
+	r := &BufferReader{}
+
+	switch m.Type() {
+	case ebpf.RingBuf:
+		reader, err := ringbuf.NewReader(m)
+		if err != nil {
+			return nil, fmt.Errorf("creating ring buffer reader: %w", err)
+		}
+		r.ringbufReader = reader
+	case ebpf.PerfEventArray:
+		perfBufferSize, err := PerfBufferSize(perfBufferPages)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := perf.NewReader(m, perfBufferSize)
+		if err != nil {
+			return nil, fmt.Errorf("creating perf ring buffer: %w", err)
+		}
+		r.perfReader = reader
+	default:
+		return nil, fmt.Errorf("map of type %s can't be read, expected: ringbuf or perf event array", m.Type())
+	}
+
+	return r, nil
+}
+
+// ReadInto reads the next event into rec, reusing its buffer. It blocks until
+// an event is available and returns an error wrapping os.ErrClosed once the
+// reader is closed.
+func (r *BufferReader) ReadInto(rec *BufferRecord) error {
+	if r.ringbufReader != nil {
+		if err := r.ringbufReader.ReadInto(&rec.ringbufRecord); err != nil {
+			return err
+		}
+		rec.RawSample = rec.ringbufRecord.RawSample
+		rec.LostSamples = 0
+		return nil
+	}
+
+	if err := r.perfReader.ReadInto(&rec.perfRecord); err != nil {
+		return err
+	}
+	rec.RawSample = rec.perfRecord.RawSample
+	rec.LostSamples = rec.perfRecord.LostSamples
+	return nil
+}
+
+// Close interrupts the pending ReadInto calls and frees the resources of the
+// reader.
+func (r *BufferReader) Close() error {
+	if r.ringbufReader != nil {
+		return r.ringbufReader.Close()
+	}
+	return r.perfReader.Close()
+}
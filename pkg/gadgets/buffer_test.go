@@ -0,0 +1,310 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+)
+
+// outputSpec returns a collection with an XDP program sending an event with
+// bpf_perf_event_output() each time it runs, like the tracers not built with
+// include/gadget/buffer.h do.
+func outputSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"events": {
+				Name:      "events",
+				Type:      ebpf.PerfEventArray,
+				KeySize:   4,
+				ValueSize: 4,
+			},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"output": {
+				Name:    "output",
+				Type:    ebpf.XDP,
+				License: "GPL",
+				Instructions: asm.Instructions{
+					asm.StoreImm(asm.RFP, -8, 42, asm.DWord),
+					asm.LoadMapPtr(asm.R2, 0).WithReference("events"),
+					asm.LoadImm(asm.R3, 0xffffffff, asm.DWord),
+					asm.Mov.Reg(asm.R4, asm.RFP),
+					asm.Add.Imm(asm.R4, -8),
+					asm.Mov.Imm(asm.R5, 8),
+					asm.FnPerfEventOutput.Call(),
+					asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+					asm.Return(),
+				},
+			},
+		},
+	}
+}
+
+// runOutputSpec loads spec, runs its program n times and returns the number of
+// events received
+func runOutputSpec(t *testing.T, spec *ebpf.CollectionSpec, n uint32) int {
+	coll, err := ebpf.NewCollection(spec)
+	require.NoError(t, err)
+	t.Cleanup(coll.Close)
+
+	reader, err := NewBufferReader(coll.Maps["events"], 1)
+	require.NoError(t, err)
+	t.Cleanup(func() { reader.Close() })
+
+	_, err = coll.Programs["output"].Run(&ebpf.RunOptions{
+		Data:   make([]byte, 14),
+		Repeat: n,
+	})
+	require.NoError(t, err)
+
+	deadline := time.Now().Add(100 * time.Millisecond)
+	if reader.ringbufReader != nil {
+		reader.ringbufReader.SetDeadline(deadline)
+	} else {
+		reader.perfReader.SetDeadline(deadline)
+	}
+
+	events := 0
+	var record BufferRecord
+	for {
+		err := reader.ReadInto(&record)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return events
+		}
+		require.NoError(t, err)
+		require.Equal(t, []byte{42, 0, 0, 0, 0, 0, 0, 0}, record.RawSample[:8])
+		events++
+	}
+}
+
+func TestRewriteEventOutput(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	type testDefinition struct {
+		sampling Sampling
+		runs     uint32
+		expected int
+	}
+
+	tests := map[string]testDefinition{
+		"no_sampling": {
+			runs:     20,
+			expected: 20,
+		},
+		"sample_rate_1": {
+			sampling: Sampling{Rate: 1},
+			runs:     20,
+			expected: 20,
+		},
+		"sample_rate": {
+			// Keeping an event out of 20 runs is very unlikely
+			sampling: Sampling{Rate: 1 << 31},
+			runs:     20,
+			expected: 0,
+		},
+		"max_events_per_second": {
+			sampling: Sampling{MaxEventsPerSecond: 5},
+			runs:     20,
+			expected: 5,
+		},
+		"max_events_per_second_not_reached": {
+			sampling: Sampling{MaxEventsPerSecond: 50},
+			runs:     20,
+			expected: 20,
+		},
+		"sample_rate_and_max_events_per_second": {
+			sampling: Sampling{Rate: 1 << 31, MaxEventsPerSecond: 5},
+			runs:     20,
+			expected: 0,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		for _, ringBuf := range []bool{false, true} {
+			ringBuf := ringBuf
+			buffer := "perf"
+			if ringBuf {
+				buffer = "ringbuf"
+			}
+			t.Run(name+"/"+buffer, func(t *testing.T) {
+				if ringBuf && !HasRingBuf() {
+					t.Skip("kernel doesn't support ring buffers")
+				}
+
+				spec := outputSpec()
+				rewriteEventOutput(spec, ringBuf, test.sampling)
+
+				expectedType := ebpf.PerfEventArray
+				if ringBuf {
+					expectedType = ebpf.RingBuf
+				}
+				require.Equal(t, expectedType, spec.Maps["events"].Type)
+
+				symbols, err := spec.Programs["output"].Instructions.SymbolOffsets()
+				require.NoError(t, err)
+				_, hasOutputFunc := symbols[outputFuncName]
+				require.Equal(t, ringBuf || test.sampling.Enabled(), hasOutputFunc)
+				_, hasRateLimitMap := spec.Maps[RateLimitMapName]
+				require.Equal(t, test.sampling.MaxEventsPerSecond > 0, hasRateLimitMap)
+
+				require.Equal(t, test.expected, runOutputSpec(t, spec, test.runs))
+			})
+		}
+	}
+}
+
+func TestRewriteEventOutputUnknownMap(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	// The map given to bpf_perf_event_output() isn't known when it's not
+	// loaded right before the call
+	spec := outputSpec()
+	insns := spec.Programs["output"].Instructions
+	insns[1] = asm.LoadMapPtr(asm.R6, 0).WithReference("events")
+	insns = append(insns[:2], append(asm.Instructions{asm.Mov.Reg(asm.R2, asm.R6)}, insns[2:]...)...)
+	spec.Programs["output"].Instructions = insns
+
+	// It's kept as a perf event array, but the events are still dropped
+	rewriteEventOutput(spec, true, Sampling{MaxEventsPerSecond: 5})
+	require.Equal(t, ebpf.PerfEventArray, spec.Maps["events"].Type)
+	require.Equal(t, 5, runOutputSpec(t, spec, 20))
+}
+
+func TestRewriteEventOutputSharedMap(t *testing.T) {
+	utilstest.RequireRoot(t)
+	if !HasRingBuf() {
+		t.Skip("kernel doesn't support ring buffers")
+	}
+
+	// The map is also used by a program that can't be rewritten
+	spec := outputSpec()
+	tailCall := spec.Programs["output"].Copy()
+	tailCall.Name = "tail_call"
+	tailCall.Instructions = append(asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+		asm.LoadMapPtr(asm.R2, 0).WithReference("progs"),
+		asm.Mov.Imm(asm.R3, 0),
+		asm.FnTailCall.Call(),
+		asm.Mov.Reg(asm.R1, asm.R6),
+	}, tailCall.Instructions...)
+	spec.Programs["tail_call"] = tailCall
+	spec.Maps["progs"] = &ebpf.MapSpec{
+		Name:       "progs",
+		Type:       ebpf.ProgramArray,
+		KeySize:    4,
+		ValueSize:  4,
+		MaxEntries: 1,
+	}
+
+	rewriteEventOutput(spec, true, Sampling{})
+	require.Equal(t, ebpf.PerfEventArray, spec.Maps["events"].Type)
+	require.Equal(t, 20, runOutputSpec(t, spec, 20))
+}
+
+func TestSamplingFromConsts(t *testing.T) {
+	spec := outputSpec()
+	consts := map[string]interface{}{
+		"other":                "value",
+		SampleRateName:         uint32(10),
+		MaxEventsPerSecondName: uint32(100),
+	}
+	require.Equal(t, Sampling{Rate: 10, MaxEventsPerSecond: 100}, samplingFromConsts(spec, consts))
+	require.Equal(t, map[string]interface{}{"other": "value"}, consts)
+
+	// Programs built with include/gadget/buffer.h sample the events
+	// themselves
+	spec.Variables = map[string]*ebpf.VariableSpec{
+		SampleRateName:         nil,
+		MaxEventsPerSecondName: nil,
+	}
+	consts = map[string]interface{}{
+		SampleRateName:         uint32(10),
+		MaxEventsPerSecondName: uint32(100),
+	}
+	require.Equal(t, Sampling{}, samplingFromConsts(spec, consts))
+	require.Len(t, consts, 2)
+}
+
+// TestRewriteEventOutputGadgets checks that the programs of the gadgets not
+// built with include/gadget/buffer.h are still accepted by the verifier once
+// rewritten, with perf event arrays and with ring buffers
+func TestRewriteEventOutputGadgets(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	arch := "x86"
+	if runtime.GOARCH == "arm64" {
+		arch = "arm64"
+	}
+
+	objects, err := filepath.Glob("*/*/tracer/*_bpfel.o")
+	require.NoError(t, err)
+
+	for _, object := range objects {
+		base := filepath.Base(object)
+		if strings.Count(base, "_") > 1 && !strings.HasSuffix(base, "_"+arch+"_bpfel.o") {
+			continue
+		}
+
+		spec, err := ebpf.LoadCollectionSpec(object)
+		require.NoError(t, err)
+
+		for name, prog := range spec.Programs {
+			if len(perfEventOutputCalls(spec, prog.Instructions)) == 0 {
+				continue
+			}
+
+			t.Run(object+"/"+name, func(t *testing.T) {
+				load := func(ringBuf bool, sampling Sampling) error {
+					progSpec := spec.Copy()
+					progSpec.Programs = map[string]*ebpf.ProgramSpec{name: progSpec.Programs[name]}
+					rewriteEventOutput(progSpec, ringBuf, sampling)
+					FixBpfKtimeGetBootNs(progSpec.Programs)
+					FixRingBufMaps(progSpec.Maps)
+
+					coll, err := ebpf.NewCollection(progSpec)
+					if err != nil {
+						return err
+					}
+					coll.Close()
+					return nil
+				}
+
+				// Programs that can't be loaded in this kernel anyway
+				if err := load(false, Sampling{}); err != nil {
+					t.Skipf("loading program: %s", err)
+				}
+				sampling := Sampling{Rate: 10, MaxEventsPerSecond: 100}
+				require.NoError(t, load(false, sampling))
+				if HasRingBuf() {
+					require.NoError(t, load(true, Sampling{}))
+					require.NoError(t, load(true, sampling))
+				}
+			})
+		}
+	}
+}
@@ -17,8 +17,23 @@ package gadgets
 const (
 	PinPath = "/sys/fs/bpf/gadget"
 
+	// PerfBufferPages is the default number of pages per CPU of the perf
+	// buffers used to send events to user space
 	PerfBufferPages = 64
 
+	// MaxPerfBufferPages is the maximum number of pages per CPU that can be
+	// set with the perf-buffer-pages param
+	MaxPerfBufferPages = 16384
+
+	// Constants used to sample and rate limit the events in eBPF.
+	// Keep in sync with variables defined in include/gadget/buffer.h.
+	SampleRateName         = "gadget_sample_rate"
+	MaxEventsPerSecondName = "gadget_max_events_per_second"
+
+	// Name of the map used to rate limit the events in eBPF.
+	// Keep in sync with name used in include/gadget/buffer.h.
+	RateLimitMapName = "gadget_rate_limit_map"
+
 	// Constant used to enable filtering by mount namespace inode id in eBPF.
 	// Keep in syn with variable defined in include/gadget/mntns_filter.h.
 	FilterByMntNsName = "gadget_filter_by_mntns"
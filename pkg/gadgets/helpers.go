@@ -18,9 +18,14 @@ package gadgets
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
 	"net/netip"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -74,15 +79,50 @@ func Htons(hs uint16) uint16 {
 	return *(*uint16)(unsafe.Pointer(&ns[0]))
 }
 
+// maxCachedIPStrings is the number of IP strings kept before the cache is
+// emptied
+const maxCachedIPStrings = 4096
+
+type ipKey struct {
+	ip     [16]byte
+	ipType int
+}
+
+// ipStrings keeps the strings built by IPStringFromBytes, as networking gadgets
+// usually see the same addresses many times.
+var ipStrings = struct {
+	mu      sync.RWMutex
+	strings map[ipKey]string
+}{
+	strings: make(map[ipKey]string),
+}
+
 func IPStringFromBytes(ipBytes [16]byte, ipType int) string {
+	key := ipKey{ip: ipBytes, ipType: ipType}
+
+	ipStrings.mu.RLock()
+	s, ok := ipStrings.strings[key]
+	ipStrings.mu.RUnlock()
+	if ok {
+		return s
+	}
+
 	switch ipType {
 	case 4:
-		return netip.AddrFrom4(*(*[4]byte)(ipBytes[0:4])).String()
+		s = netip.AddrFrom4(*(*[4]byte)(ipBytes[0:4])).String()
 	case 6:
-		return netip.AddrFrom16(ipBytes).String()
+		s = netip.AddrFrom16(ipBytes).String()
 	default:
 		return ""
 	}
+
+	ipStrings.mu.Lock()
+	if len(ipStrings.strings) >= maxCachedIPStrings {
+		clear(ipStrings.strings)
+	}
+	ipStrings.strings[key] = s
+	ipStrings.mu.Unlock()
+	return s
 }
 
 // IPStringToByteArray converts an IP address (IPv6 only) string to a uint32
@@ -116,6 +156,35 @@ func IPStringToUint32(ipAddr string) (uint32, error) {
 	return *(*uint32)(unsafe.Pointer(&ip[0])), nil
 }
 
+// PerfBufferSize returns the size in bytes per CPU of a perf buffer with the
+// given number of pages, or PerfBufferPages if pages is 0. It fails if the
+// buffers for all CPUs don't fit in the RLIMIT_MEMLOCK limit.
+func PerfBufferSize(pages uint32) (int, error) {
+	if pages == 0 {
+		pages = PerfBufferPages
+	}
+	if err := ValidatePerfBufferPages(strconv.FormatUint(uint64(pages), 10)); err != nil {
+		return 0, fmt.Errorf("invalid perf buffer pages %d: %w", pages, err)
+	}
+
+	size := int(pages) * os.Getpagesize()
+
+	var limit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &limit); err != nil {
+		return 0, fmt.Errorf("getting memlock limit: %w", err)
+	}
+	if limit.Cur != unix.RLIM_INFINITY {
+		// One extra page per CPU is used for the metadata of the buffer
+		needed := uint64(size+os.Getpagesize()) * uint64(runtime.NumCPU())
+		if needed > limit.Cur {
+			return 0, fmt.Errorf("perf buffer of %d pages per CPU needs %d bytes of locked memory, the memlock limit is %d bytes",
+				pages, needed, limit.Cur)
+		}
+	}
+
+	return size, nil
+}
+
 func IPVerFromAF(af uint16) int {
 	switch af {
 	case unix.AF_INET:
@@ -204,14 +273,24 @@ func FixBpfKtimeGetBootNs(programSpecs map[string]*ebpf.ProgramSpec) {
 
 // LoadeBPFSpec is a helper to load an eBPF spec from gadgets.
 // It replaces filter map and calls the necessary functions to load
-// Maps and Programs into the kernel
+// Maps and Programs into the kernel.
+// The programs not built with include/gadget/buffer.h are rewritten by
+// RewriteEventOutput to use ring buffers where available, and to apply the
+// SampleRateName and MaxEventsPerSecondName constants.
 func LoadeBPFSpec(
 	mountnsMap *ebpf.Map,
 	spec *ebpf.CollectionSpec,
 	consts map[string]interface{},
 	objs interface{},
 ) error {
+	if consts == nil {
+		consts = map[string]interface{}{}
+	}
+
+	RewriteEventOutput(spec, samplingFromConsts(spec, consts))
+
 	FixBpfKtimeGetBootNs(spec.Programs)
+	FixRingBufMaps(spec.Maps)
 
 	mapReplacements := map[string]*ebpf.Map{}
 	filterByMntNs := false
@@ -221,10 +300,6 @@ func LoadeBPFSpec(
 		mapReplacements[MntNsFilterMapName] = mountnsMap
 	}
 
-	if consts == nil {
-		consts = map[string]interface{}{}
-	}
-
 	consts[FilterByMntNsName] = filterByMntNs
 
 	//nolint:staticcheck
@@ -246,8 +321,27 @@ func LoadeBPFSpec(
 	return nil
 }
 
+// samplingFromConsts removes the SampleRateName and MaxEventsPerSecondName
+// constants from consts when spec doesn't have them, and returns their values
+func samplingFromConsts(spec *ebpf.CollectionSpec, consts map[string]interface{}) Sampling {
+	var sampling Sampling
+	if _, ok := spec.Variables[SampleRateName]; !ok {
+		sampling.Rate, _ = consts[SampleRateName].(uint32)
+		delete(consts, SampleRateName)
+	}
+	if _, ok := spec.Variables[MaxEventsPerSecondName]; !ok {
+		sampling.MaxEventsPerSecond, _ = consts[MaxEventsPerSecondName].(uint32)
+		delete(consts, MaxEventsPerSecondName)
+	}
+	return sampling
+}
+
 func FreezeMaps(maps ...*ebpf.Map) error {
 	for _, m := range maps {
+		// Ring buffers can't be frozen once mapped by their reader
+		if m.Type() == ebpf.RingBuf {
+			continue
+		}
 		if err := m.Freeze(); err != nil {
 			if info, _ := m.Info(); info != nil {
 				return fmt.Errorf("freezing map %s: %w", info.Name, err)
@@ -258,3 +352,27 @@ func FreezeMaps(maps ...*ebpf.Map) error {
 
 	return nil
 }
+
+// ClearMaps deletes all the entries of the given maps. The eBPF programs
+// using them can keep running, the entries they add while the maps are
+// being cleared are deleted too.
+func ClearMaps(maps ...*ebpf.Map) error {
+	for _, m := range maps {
+		key := make([]byte, m.KeySize())
+		for {
+			// Always delete the first key, as deleting the current key makes
+			// it impossible to get the next one
+			if err := m.NextKey(nil, key); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					break
+				}
+				return fmt.Errorf("getting next key: %w", err)
+			}
+			if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+				return fmt.Errorf("deleting key: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -18,9 +18,57 @@ package gadgets
 
 import (
 	"fmt"
+	"sync"
 	"syscall"
 )
 
+const (
+	// maxInternedStringLen is the length up to which FromCString and
+	// FromCStringN reuse previously built strings
+	maxInternedStringLen = 64
+	// maxInternedStrings is the number of strings kept before the cache is
+	// emptied
+	maxInternedStrings = 4096
+)
+
+// stringCache keeps the strings built from C strings. Fields like comm take a
+// few different values, so looking them up avoids allocating a new string for
+// each event at high event rates.
+type stringCache struct {
+	mu      sync.RWMutex
+	strings map[string]string
+}
+
+var cStrings = &stringCache{
+	strings: make(map[string]string),
+}
+
+func (c *stringCache) get(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if len(b) > maxInternedStringLen {
+		return string(b)
+	}
+
+	// The compiler doesn't allocate a string to use b as key
+	c.mu.RLock()
+	s, ok := c.strings[string(b)]
+	c.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	s = string(b)
+	c.mu.Lock()
+	if len(c.strings) >= maxInternedStrings {
+		clear(c.strings)
+	}
+	c.strings[s] = s
+	c.mu.Unlock()
+	return s
+}
+
 func ProtoString(proto int) string {
 	// proto definitions:
 	// https://www.iana.org/assignments/protocol-numbers/protocol-numbers.xhtml
@@ -34,15 +82,18 @@ func ProtoString(proto int) string {
 	return protoStr
 }
 
+// FromCString returns the content of in up to the first NUL byte. Short
+// strings are shared with previous calls returning the same content.
 func FromCString(in []byte) string {
 	for i := 0; i < len(in); i++ {
 		if in[i] == 0 {
-			return string(in[:i])
+			return cStrings.get(in[:i])
 		}
 	}
-	return string(in)
+	return cStrings.get(in)
 }
 
+// FromCStringN is like FromCString but reads at most length bytes of in.
 func FromCStringN(in []byte, length int) string {
 	l := len(in)
 	if length < l {
@@ -51,8 +102,8 @@ func FromCStringN(in []byte, length int) string {
 
 	for i := 0; i < l; i++ {
 		if in[i] == 0 {
-			return string(in[:i])
+			return cStrings.get(in[:i])
 		}
 	}
-	return string(in[:l])
+	return cStrings.get(in[:l])
 }
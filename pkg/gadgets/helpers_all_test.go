@@ -0,0 +1,64 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromCString(t *testing.T) {
+	long := strings.Repeat("a", maxInternedStringLen+1)
+
+	tests := []struct {
+		name     string
+		in       []byte
+		expected string
+	}{
+		{name: "empty", in: []byte{}, expected: ""},
+		{name: "only_nul", in: []byte{0, 'a'}, expected: ""},
+		{name: "nul_terminated", in: []byte{'c', 'a', 't', 0, 'x'}, expected: "cat"},
+		{name: "not_terminated", in: []byte("cat"), expected: "cat"},
+		{name: "long", in: append([]byte(long), 0), expected: long},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, FromCString(test.in))
+		})
+	}
+
+	assert.Equal(t, "ca", FromCStringN([]byte{'c', 'a', 't', 0}, 2))
+	assert.Equal(t, "cat", FromCStringN([]byte{'c', 'a', 't', 0}, 10))
+}
+
+func TestFromCStringReusesStrings(t *testing.T) {
+	comm := [16]byte{'c', 'u', 'r', 'l'}
+	FromCString(comm[:])
+
+	allocs := testing.AllocsPerRun(100, func() {
+		FromCString(comm[:])
+	})
+	assert.Zero(t, allocs)
+
+	// The caller can reuse its buffer without changing returned strings
+	s := FromCString(comm[:])
+	comm[0] = 'k'
+	assert.Equal(t, "curl", s)
+	assert.Equal(t, "kurl", FromCString(comm[:]))
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+)
+
+func TestClearMaps(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	newMap := func(typ ebpf.MapType, entries uint32) *ebpf.Map {
+		m, err := ebpf.NewMap(&ebpf.MapSpec{
+			Type:       typ,
+			KeySize:    4,
+			ValueSize:  8,
+			MaxEntries: 1024,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { m.Close() })
+
+		for i := uint32(0); i < entries; i++ {
+			require.NoError(t, m.Put(i, uint64(i)))
+		}
+		return m
+	}
+	count := func(m *ebpf.Map) int {
+		n := 0
+		var key uint32
+		var value uint64
+		iter := m.Iterate()
+		for iter.Next(&key, &value) {
+			n++
+		}
+		require.NoError(t, iter.Err())
+		return n
+	}
+
+	hash := newMap(ebpf.Hash, 100)
+	lru := newMap(ebpf.LRUHash, 1000)
+	empty := newMap(ebpf.Hash, 0)
+	other := newMap(ebpf.Hash, 10)
+
+	require.NoError(t, ClearMaps(hash, lru, empty))
+	require.Zero(t, count(hash))
+	require.Zero(t, count(lru))
+	require.Zero(t, count(empty))
+
+	// Only the given maps are cleared
+	require.Equal(t, 10, count(other))
+
+	// The maps can still be used afterwards
+	require.NoError(t, hash.Put(uint32(1), uint64(1)))
+	require.Equal(t, 1, count(hash))
+}
@@ -85,10 +85,13 @@ type OutputFormats map[string]OutputFormat
 // such a format is used, the result of the gadget will be passed to the Transform()
 // function and returned to the user.
 type OutputFormat struct {
-	Name                   string                    `json:"name"`
-	Description            string                    `json:"description"`
-	RequiresCombinedResult bool                      `json:"requiresCombinedResult"`
-	Transform              func(any) ([]byte, error) `json:"-"`
+	Name                   string `json:"name"`
+	Description            string `json:"description"`
+	RequiresCombinedResult bool   `json:"requiresCombinedResult"`
+	// Flag is the name of a boolean flag selecting this format, as a shortcut
+	// for --output; no flag is added if empty
+	Flag      string                    `json:"flag,omitempty"`
+	Transform func(any) ([]byte, error) `json:"-"`
 }
 
 // Append appends the OutputFormats given in other to of
@@ -16,6 +16,9 @@ package gadgets
 
 import (
 	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
 	"strings"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
@@ -26,8 +29,19 @@ const (
 	ParamInterval = "interval"
 	ParamSortBy   = "sort"
 	ParamMaxRows  = "max-rows"
+
+	ParamPerfBufferPages    = "perf-buffer-pages"
+	ParamSampleRate         = "sample-rate"
+	ParamMaxEventsPerSecond = "max-events-per-second"
+
+	ParamUID = "uid"
+	ParamGID = "gid"
 )
 
+// NoIDFilter is the value of the uid and gid filters when they aren't set. It
+// matches (uid_t)-1, used by the eBPF programs for the same purpose.
+const NoIDFilter = math.MaxUint32
+
 const (
 	LocalContainer   params.ValueHint = "local:container"
 	K8SNodeName      params.ValueHint = "k8s:node"
@@ -54,9 +68,178 @@ func GadgetParams(gadget GadgetDesc, gType GadgetType, parser parser.Parser) par
 	if gType.CanSort() {
 		p.Add(SortableParams(gadget, parser)...)
 	}
+	if gType == TypeTrace {
+		p.Add(PerfBufferParams()...)
+	}
 	return p
 }
 
+func PerfBufferParams() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          ParamPerfBufferPages,
+			Title:        "Perf buffer pages",
+			DefaultValue: strconv.Itoa(PerfBufferPages),
+			TypeHint:     params.TypeUint32,
+			Description:  "Number of memory pages per CPU of the buffer sending events to user space. Bigger buffers lose fewer events at high rates but use more memory",
+			Validator:    ValidatePerfBufferPages,
+		},
+	}
+}
+
+// ValidatePerfBufferPages checks that value is a power of two between 1 and
+// MaxPerfBufferPages
+func ValidatePerfBufferPages(value string) error {
+	pages, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("expected numeric value: %w", err)
+	}
+	if pages == 0 || pages > MaxPerfBufferPages {
+		return fmt.Errorf("expected a number of pages between 1 and %d", MaxPerfBufferPages)
+	}
+	if bits.OnesCount64(pages) != 1 {
+		return fmt.Errorf("expected a power of two number of pages")
+	}
+	return nil
+}
+
+// PerfBufferPagesFromParams returns the number of pages set by the
+// perf-buffer-pages param, or 0 if p doesn't have it
+func PerfBufferPagesFromParams(p *params.Params) uint32 {
+	if param := p.Get(ParamPerfBufferPages); param != nil {
+		return param.AsUint32()
+	}
+	return 0
+}
+
+// UserFilterParams returns the params to show only the events of processes
+// running as a given user or group. They are added by the gadgets whose
+// events have the uid and gid of the process.
+func UserFilterParams() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:         ParamUID,
+			Title:       "UID",
+			Description: "Show only events from processes running with this user ID",
+			Validator:   validateID,
+		},
+		{
+			Key:         ParamGID,
+			Title:       "GID",
+			Description: "Show only events from processes running with this group ID",
+			Validator:   validateID,
+		},
+	}
+}
+
+// validateID checks that value is empty or a valid user or group ID
+func validateID(value string) error {
+	if value == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return fmt.Errorf("expected numeric value: %w", err)
+	}
+	if id == NoIDFilter {
+		return fmt.Errorf("%d isn't a valid ID", id)
+	}
+	return nil
+}
+
+// UserFilter contains the user and group IDs set by the uid and gid params.
+// A nil ID doesn't filter, so the zero value shows all the events.
+type UserFilter struct {
+	UID *uint32
+	GID *uint32
+}
+
+// UserFilterFromParams returns the user and group IDs set by the uid and gid
+// params, if p has them
+func UserFilterFromParams(p *params.Params) UserFilter {
+	var f UserFilter
+	if param := p.Get(ParamUID); param != nil && param.AsString() != "" {
+		uid := param.AsUint32()
+		f.UID = &uid
+	}
+	if param := p.Get(ParamGID); param != nil && param.AsString() != "" {
+		gid := param.AsUint32()
+		f.GID = &gid
+	}
+	return f
+}
+
+// TargetUID returns the user ID to set in the eBPF programs filtering by user,
+// NoIDFilter if not set
+func (f UserFilter) TargetUID() uint32 {
+	if f.UID == nil {
+		return NoIDFilter
+	}
+	return *f.UID
+}
+
+// Matches returns whether an event with the given user and group IDs has to
+// be shown
+func (f UserFilter) Matches(uid, gid uint32) bool {
+	if f.UID != nil && *f.UID != uid {
+		return false
+	}
+	if f.GID != nil && *f.GID != gid {
+		return false
+	}
+	return true
+}
+
+// SamplingParams returns the params to drop events in eBPF before they are
+// sent to user space. They are added by the tracers sending many events.
+func SamplingParams() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          ParamSampleRate,
+			Title:        "Sample rate",
+			Description:  "Keep one event out of this number, randomly, before sending it to user space. 1 keeps all the events",
+			DefaultValue: "1",
+			TypeHint:     params.TypeUint32,
+		},
+		{
+			Key:          ParamMaxEventsPerSecond,
+			Title:        "Max events per second",
+			Description:  "Maximum number of events per second sent to user space, the other ones are dropped. 0 means no limit",
+			DefaultValue: "0",
+			TypeHint:     params.TypeUint32,
+		},
+	}
+}
+
+// Sampling contains the values of the sample-rate and max-events-per-second
+// params. The zero value keeps all the events.
+type Sampling struct {
+	// Rate keeps one event out of Rate, randomly. 0 and 1 keep all the
+	// events.
+	Rate uint32
+	// MaxEventsPerSecond is the maximum number of events sent to user space
+	// per second, 0 means no limit.
+	MaxEventsPerSecond uint32
+}
+
+// SamplingFromParams returns the values of the sample-rate and
+// max-events-per-second params, if p has them
+func SamplingFromParams(p *params.Params) Sampling {
+	var s Sampling
+	if param := p.Get(ParamSampleRate); param != nil {
+		s.Rate = param.AsUint32()
+	}
+	if param := p.Get(ParamMaxEventsPerSecond); param != nil {
+		s.MaxEventsPerSecond = param.AsUint32()
+	}
+	return s
+}
+
+// Enabled returns whether events have to be dropped
+func (s Sampling) Enabled() bool {
+	return s.Rate > 1 || s.MaxEventsPerSecond > 0
+}
+
 func IntervalParams() params.ParamDescs {
 	return params.ParamDescs{
 		{
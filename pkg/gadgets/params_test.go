@@ -0,0 +1,86 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestValidatePerfBufferPages(t *testing.T) {
+	for _, value := range []string{"1", "64", "16384"} {
+		assert.NoError(t, ValidatePerfBufferPages(value), value)
+	}
+	for _, value := range []string{"", "0", "-1", "3", "100", "32768"} {
+		assert.Error(t, ValidatePerfBufferPages(value), value)
+	}
+}
+
+func TestPerfBufferPagesFromParams(t *testing.T) {
+	assert.Zero(t, PerfBufferPagesFromParams(&params.Params{}))
+
+	p := PerfBufferParams().ToParams()
+	assert.Equal(t, uint32(PerfBufferPages), PerfBufferPagesFromParams(p))
+
+	assert.NoError(t, p.Set(ParamPerfBufferPages, "256"))
+	assert.Equal(t, uint32(256), PerfBufferPagesFromParams(p))
+	assert.Error(t, p.Set(ParamPerfBufferPages, "255"))
+}
+
+func TestUserFilterFromParams(t *testing.T) {
+	f := UserFilterFromParams(&params.Params{})
+	assert.Equal(t, UserFilter{}, f)
+	assert.True(t, f.Matches(0, 0))
+	assert.Equal(t, uint32(NoIDFilter), f.TargetUID())
+
+	p := UserFilterParams().ToParams()
+	assert.Equal(t, f, UserFilterFromParams(p))
+
+	assert.NoError(t, p.Set(ParamUID, "1000"))
+	f = UserFilterFromParams(p)
+	assert.Equal(t, uint32(1000), f.TargetUID())
+	assert.Nil(t, f.GID)
+	assert.True(t, f.Matches(1000, 0))
+	assert.False(t, f.Matches(0, 0))
+
+	assert.NoError(t, p.Set(ParamGID, "0"))
+	f = UserFilterFromParams(p)
+	assert.True(t, f.Matches(1000, 0))
+	assert.False(t, f.Matches(1000, 2000))
+
+	assert.Error(t, p.Set(ParamUID, "-1"))
+	assert.Error(t, p.Set(ParamUID, "4294967295"))
+	assert.Error(t, p.Set(ParamGID, "root"))
+}
+
+func TestSamplingFromParams(t *testing.T) {
+	s := SamplingFromParams(&params.Params{})
+	assert.Equal(t, Sampling{}, s)
+	assert.False(t, s.Enabled())
+
+	p := SamplingParams().ToParams()
+	s = SamplingFromParams(p)
+	assert.Equal(t, Sampling{Rate: 1}, s)
+	assert.False(t, s.Enabled())
+
+	assert.NoError(t, p.Set(ParamSampleRate, "10"))
+	assert.NoError(t, p.Set(ParamMaxEventsPerSecond, "1000"))
+	s = SamplingFromParams(p)
+	assert.Equal(t, Sampling{Rate: 10, MaxEventsPerSecond: 1000}, s)
+	assert.True(t, s.Enabled())
+}
@@ -18,6 +18,7 @@ package tracer
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"unsafe"
 
@@ -52,11 +53,15 @@ func NewTracer() (*Tracer, error) {
 
 func getReport(histMap *ebpf.Map) (*types.Report, error) {
 	key := biolatencyHistKey{}
+	hist := biolatencyHist{}
 	if err := histMap.NextKey(nil, unsafe.Pointer(&key)); err != nil {
+		// No I/O since the tracer was started or cleared
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			return types.NewReport(histogram.UnitMicroseconds, hist.Slots[:]), nil
+		}
 		return nil, fmt.Errorf("getting next key: %w", err)
 	}
 
-	hist := biolatencyHist{}
 	if err := histMap.Lookup(key, unsafe.Pointer(&hist)); err != nil {
 		return nil, fmt.Errorf("getting histogram: %w", err)
 	}
@@ -74,6 +79,11 @@ func (t *Tracer) Stop() (string, error) {
 	return string(result), nil
 }
 
+// Clear resets the histogram without stopping the tracer
+func (t *Tracer) Clear() error {
+	return gadgets.ClearMaps(t.objs.Hists)
+}
+
 func (t *Tracer) collectResult() ([]byte, error) {
 	if t.objs.Hists == nil {
 		return nil, nil
@@ -0,0 +1,58 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"encoding/json"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/block-io/types"
+)
+
+func TestTracerClear(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	tracer, err := NewTracer()
+	require.NoError(t, err)
+
+	// Fill the histogram as if I/O happened, real I/O may not reach the
+	// block layer in a test
+	key := biolatencyHistKey{Dev: 1}
+	hist := biolatencyHist{}
+	hist.Slots[3] = 42
+	require.NoError(t, tracer.objs.Hists.Put(unsafe.Pointer(&key), unsafe.Pointer(&hist)))
+
+	report, err := getReport(tracer.objs.Hists)
+	require.NoError(t, err)
+	require.NotEmpty(t, report.Intervals)
+
+	require.NoError(t, tracer.Clear())
+
+	// Clearing keeps the tracer running, the stats are only reset
+	output, err := tracer.Stop()
+	require.NoError(t, err)
+
+	var cleared types.Report
+	require.NoError(t, json.Unmarshal([]byte(output), &cleared))
+	for _, interval := range cleared.Intervals {
+		require.Zero(t, interval.Count)
+	}
+}
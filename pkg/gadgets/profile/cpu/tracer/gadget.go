@@ -25,6 +25,7 @@ import (
 const (
 	ParamUserStack   = "user-stack"
 	ParamKernelStack = "kernel-stack"
+	ParamPush        = "push"
 )
 
 type GadgetDesc struct{}
@@ -63,6 +64,12 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			Description:  "Show stacks from kernel space only (no user space stacks)",
 			TypeHint:     params.TypeBool,
 		},
+		{
+			Key:         ParamPush,
+			Title:       "Push",
+			Description: "Push the profile to a profiling backend, e.g. pyroscope://host:4040 or parca://host:7070",
+			TypeHint:    params.TypeString,
+		},
 	}
 }
 
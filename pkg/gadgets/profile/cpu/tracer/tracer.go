@@ -17,11 +17,13 @@
 package tracer
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"runtime"
 	"sort"
+	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
@@ -32,6 +34,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/cpu/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+	profileexporter "github.com/inspektor-gadget/inspektor-gadget/pkg/profile-exporter"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -41,6 +44,9 @@ type Config struct {
 	MountnsMap      *ebpf.Map
 	UserStackOnly   bool
 	KernelStackOnly bool
+
+	// SampleFreq is the sampling frequency in Hz; it defaults to 49Hz
+	SampleFreq uint64
 }
 
 type Tracer struct {
@@ -59,6 +65,9 @@ const (
 	// From C, we can deduce freq (which permits using frequency not period)
 	// is the 10th bit.
 	frequencyBit = 1 << 10
+
+	pushAppName = "inspektor-gadget"
+	pushTimeout = 30 * time.Second
 )
 
 func NewTracer(enricher gadgets.DataEnricherByMntNs, config *Config) (*Tracer, error) {
@@ -174,6 +183,7 @@ func getReport(t *Tracer, kAllSyms *kallsyms.KAllSyms, stack *ebpf.Map, keyCount
 		UserStack:   userSymbols,
 		KernelStack: kernelSymbols,
 		Count:       v,
+		MntnsID:     k.MntnsId,
 	}
 
 	if t.enricher != nil {
@@ -193,6 +203,19 @@ func (t *Tracer) Stop() (string, error) {
 	return string(result), nil
 }
 
+// Clear resets the collected samples without stopping the tracer
+func (t *Tracer) Clear() error {
+	return gadgets.ClearMaps(t.objs.profileMaps.Counts, t.objs.profileMaps.Stackmap)
+}
+
+// StopAndCollect stops the tracer and returns the collected reports without
+// serializing them.
+func (t *Tracer) StopAndCollect() ([]types.Report, error) {
+	defer t.close()
+
+	return t.collectReports()
+}
+
 func (t *Tracer) close() {
 	t.objs.Close()
 
@@ -211,6 +234,15 @@ func (t *Tracer) close() {
 }
 
 func (t *Tracer) collectResult() ([]byte, error) {
+	reports, err := t.collectReports()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(reports)
+}
+
+func (t *Tracer) collectReports() ([]types.Report, error) {
 	keysCounts, err := t.readCountsMap()
 	if err != nil {
 		return nil, err
@@ -223,7 +255,7 @@ func (t *Tracer) collectResult() ([]byte, error) {
 		return keysCounts[i].value != keysCounts[j].value
 	})
 
-	kAllSyms, err := kallsyms.NewKAllSyms()
+	kAllSyms, err := kallsyms.Cached()
 	if err != nil {
 		return nil, err
 	}
@@ -238,7 +270,7 @@ func (t *Tracer) collectResult() ([]byte, error) {
 		reports[i] = report
 	}
 
-	return json.Marshal(reports)
+	return reports, nil
 }
 
 func (t *Tracer) install() error {
@@ -256,6 +288,11 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
+	sampleFreq := t.config.SampleFreq
+	if sampleFreq == 0 {
+		sampleFreq = perfSampleFreq
+	}
+
 	for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
 		// Highly inspired from:
 		// https://gist.github.com/florianl/5d9cc9dbb3822e03f6f65a073ffbedbb#file-main-go-L101
@@ -266,7 +303,7 @@ func (t *Tracer) install() error {
 				Type:        unix.PERF_TYPE_SOFTWARE,
 				Config:      unix.PERF_COUNT_SW_CPU_CLOCK,
 				Sample_type: unix.PERF_SAMPLE_RAW,
-				Sample:      perfSampleFreq,
+				Sample:      sampleFreq,
 				Bits:        frequencyBit,
 			},
 			-1,
@@ -308,24 +345,42 @@ func (t *TracerWrap) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.UserStackOnly = params.Get(ParamUserStack).AsBool()
 	t.config.KernelStackOnly = params.Get(ParamKernelStack).AsBool()
 
+	var exporter profileexporter.Exporter
+	if pushAddress := params.Get(ParamPush).AsString(); pushAddress != "" {
+		var err error
+		exporter, err = profileexporter.New(pushAddress, pushAppName)
+		if err != nil {
+			return fmt.Errorf("creating profile exporter: %w", err)
+		}
+		defer exporter.Close()
+	}
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
 	}
 
+	start := time.Now()
 	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
+	duration := time.Since(start)
 
-	res, err := t.collectResult()
+	reports, err := t.collectReports()
 	if err != nil {
 		return fmt.Errorf("collecting result: %w", err)
 	}
 
-	var reports []*types.Report
-	if err = json.Unmarshal(res, &reports); err != nil {
-		return fmt.Errorf("unmarshaling report: %w", err)
+	if exporter != nil {
+		// The gadget context is done at this point
+		ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+		defer cancel()
+		err := profileexporter.PushReports(ctx, exporter, reports, start, duration, perfSampleFreq)
+		if err != nil {
+			gadgetCtx.Logger().Warnf("pushing profiles: %v", err)
+		}
 	}
-	for _, report := range reports {
-		t.eventCallback(report)
+
+	for i := range reports {
+		t.eventCallback(&reports[i])
 	}
 
 	return nil
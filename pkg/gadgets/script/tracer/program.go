@@ -0,0 +1,132 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// mntnsExpr returns the mount namespace of the current task in bpftrace. It
+// needs the kernel BTF.
+const mntnsExpr = "(uint64)curtask->nsproxy->mnt_ns->ns.inum"
+
+// instrumentProgram rewrites every printf() call of a bpftrace program to
+// prefix its output with the mount namespace of the current task, so the
+// events can be enriched and filtered by container:
+//
+//	printf("%s\n", comm) -> printf("%llu %s\n", (uint64)curtask->..., comm)
+func instrumentProgram(program string) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(program))
+
+	for i := 0; i < len(program); {
+		switch {
+		case strings.HasPrefix(program[i:], "//"):
+			end := strings.IndexByte(program[i:], '\n')
+			if end == -1 {
+				end = len(program) - i
+			}
+			sb.WriteString(program[i : i+end])
+			i += end
+		case strings.HasPrefix(program[i:], "/*"):
+			end := strings.Index(program[i+2:], "*/")
+			if end == -1 {
+				return "", fmt.Errorf("unterminated comment")
+			}
+			sb.WriteString(program[i : i+2+end+2])
+			i += 2 + end + 2
+		case program[i] == '"':
+			end, err := stringEnd(program, i)
+			if err != nil {
+				return "", err
+			}
+			sb.WriteString(program[i:end])
+			i = end
+		case strings.HasPrefix(program[i:], "printf") && (i == 0 || !isIdentChar(program[i-1])):
+			j := i + len("printf")
+			if j < len(program) && isIdentChar(program[j]) {
+				sb.WriteString(program[i:j])
+				i = j
+				continue
+			}
+			j = skipSpaces(program, j)
+			if j >= len(program) || program[j] != '(' {
+				return "", fmt.Errorf("expected '(' after printf at offset %d", i)
+			}
+			j = skipSpaces(program, j+1)
+			if j >= len(program) || program[j] != '"' {
+				return "", fmt.Errorf("expected format string in printf at offset %d", i)
+			}
+			end, err := stringEnd(program, j)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&sb, "%s%%llu %s, %s", program[i:j+1], program[j+1:end], mntnsExpr)
+			i = end
+		default:
+			sb.WriteByte(program[i])
+			i++
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// stringEnd returns the offset after the string literal starting at start
+func stringEnd(program string, start int) (int, error) {
+	for i := start + 1; i < len(program); i++ {
+		switch program[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at offset %d", start)
+}
+
+func skipSpaces(program string, i int) int {
+	for i < len(program) && strings.ContainsRune(" \t\r\n", rune(program[i])) {
+		i++
+	}
+	return i
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// output is a line printed by bpftrace with "-f json"
+type output struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// parsePrintf splits the output of an instrumented printf() call into the
+// mount namespace and the text printed by the program
+func parsePrintf(data string) (uint64, string, error) {
+	prefix, text, ok := strings.Cut(data, " ")
+	if !ok {
+		return 0, "", fmt.Errorf("missing mount namespace in %q", data)
+	}
+	mntns, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid mount namespace in %q: %w", data, err)
+	}
+	return mntns, strings.TrimSuffix(text, "\n"), nil
+}
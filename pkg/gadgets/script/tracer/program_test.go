@@ -0,0 +1,89 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		program  string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "printf",
+			program:  `kprobe:do_sys_openat2 { printf("%s %s\n", comm, str(arg1)); }`,
+			expected: `kprobe:do_sys_openat2 { printf("%llu %s %s\n", ` + mntnsExpr + `, comm, str(arg1)); }`,
+		},
+		{
+			name:     "printf_without_args",
+			program:  `BEGIN { printf ( "hello\n" ) }`,
+			expected: `BEGIN { printf ( "%llu hello\n", ` + mntnsExpr + ` ) }`,
+		},
+		{
+			name:     "escaped_quote",
+			program:  `BEGIN { printf("say \"hi\"") }`,
+			expected: `BEGIN { printf("%llu say \"hi\"", ` + mntnsExpr + `) }`,
+		},
+		{
+			name:     "strings_and_comments",
+			program:  "// printf(\"x\")\nBEGIN { /* printf */ @[\"printf(\"] = count(); myprintf = 1 }",
+			expected: "// printf(\"x\")\nBEGIN { /* printf */ @[\"printf(\"] = count(); myprintf = 1 }",
+		},
+		{
+			name:    "unterminated_string",
+			program: `BEGIN { printf("hello) }`,
+			err:     true,
+		},
+		{
+			name:    "no_format",
+			program: `BEGIN { printf(comm) }`,
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := instrumentProgram(test.program)
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, got)
+		})
+	}
+}
+
+func TestParsePrintf(t *testing.T) {
+	mntns, text, err := parsePrintf("4026531840 cat /etc/passwd\n")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4026531840), mntns)
+	assert.Equal(t, "cat /etc/passwd", text)
+
+	_, _, err = parsePrintf("nospace")
+	require.Error(t, err)
+	_, _, err = parsePrintf("abc def")
+	require.Error(t, err)
+}
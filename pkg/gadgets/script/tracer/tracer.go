@@ -0,0 +1,194 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/script/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	bpftraceBinary = "bpftrace"
+
+	// stopTimeout is how long bpftrace is given to print its maps after
+	// being interrupted
+	stopTimeout = 5 * time.Second
+
+	// maxStderr bounds the error output of bpftrace kept for error messages
+	maxStderr = 4096
+)
+
+type Config struct {
+	MountnsMap *ebpf.Map
+	Program    string
+}
+
+type Tracer struct {
+	config        *Config
+	eventCallback func(*types.Event)
+}
+
+// limitedBuffer keeps the last bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n, _ := b.Buffer.Write(p)
+	if b.Len() > maxStderr {
+		b.Next(b.Len() - maxStderr)
+	}
+	return n, nil
+}
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	program, err := instrumentProgram(gadgetCtx.GadgetParams().Get(ParamProgram).AsString())
+	if err != nil {
+		return fmt.Errorf("parsing program: %w", err)
+	}
+	t.config.Program = program
+
+	path, err := exec.LookPath(bpftraceBinary)
+	if err != nil {
+		return fmt.Errorf("looking for bpftrace: %w", err)
+	}
+
+	ctx, cancel := gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
+	defer cancel()
+
+	// Interrupt bpftrace instead of killing it, so it prints its maps
+	cmd := exec.CommandContext(ctx, path, "-f", "json", "-e", t.config.Program)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = stopTimeout
+
+	stderr := &limitedBuffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating bpftrace pipe: %w", err)
+	}
+
+	gadgetCtx.Logger().Debugf("running bpftrace program: %s", t.config.Program)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting bpftrace: %w", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		t.handleOutput(gadgetCtx, scanner.Bytes())
+	}
+
+	err = cmd.Wait()
+	if err != nil && ctx.Err() == nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return fmt.Errorf("bpftrace: %s", bytes.TrimSpace(stderr.Bytes()))
+		}
+		return fmt.Errorf("running bpftrace: %w", err)
+	}
+	return nil
+}
+
+func (t *Tracer) handleOutput(gadgetCtx gadgets.GadgetContext, line []byte) {
+	var out output
+	if err := json.Unmarshal(line, &out); err != nil {
+		gadgetCtx.Logger().Debugf("invalid bpftrace output %q: %v", line, err)
+		return
+	}
+
+	switch out.Type {
+	case "printf":
+		var data string
+		if err := json.Unmarshal(out.Data, &data); err != nil {
+			gadgetCtx.Logger().Debugf("invalid printf data %q: %v", out.Data, err)
+			return
+		}
+		mntns, text, err := parsePrintf(data)
+		if err != nil {
+			t.eventCallback(types.Base(eventtypes.Warn(err.Error())))
+			return
+		}
+		if !t.selected(mntns) {
+			return
+		}
+		t.eventCallback(&types.Event{
+			Event: eventtypes.Event{
+				Type:      eventtypes.NORMAL,
+				Timestamp: eventtypes.Time(time.Now().UnixNano()),
+			},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: mntns},
+			Output:        text,
+		})
+	case "attached_probes":
+		gadgetCtx.Logger().Debugf("bpftrace attached probes: %s", out.Data)
+	case "lost_events":
+		t.eventCallback(types.Base(eventtypes.Warn(fmt.Sprintf("bpftrace lost events: %s", out.Data))))
+	default:
+		// Maps, histograms, stats and the rest aren't bound to a container
+		t.eventCallback(&types.Event{
+			Event: eventtypes.Event{
+				Type:      eventtypes.NORMAL,
+				Timestamp: eventtypes.Time(time.Now().UnixNano()),
+			},
+			Output: string(out.Data),
+		})
+	}
+}
+
+// selected returns whether mntns belongs to one of the selected containers
+func (t *Tracer) selected(mntns uint64) bool {
+	if t.config.MountnsMap == nil {
+		return true
+	}
+	var value uint32
+	return t.config.MountnsMap.Lookup(&mntns, &value) == nil
+}
+
+func (t *Tracer) SetMountNsMap(mountnsMap *ebpf.Map) {
+	t.config.MountnsMap = mountnsMap
+}
+
+func (t *Tracer) SetEventHandler(handler any) {
+	nh, ok := handler.(func(ev *types.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventCallback = nh
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	tracer := &Tracer{
+		config: &Config{},
+	}
+	return tracer, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/dnat/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type mappingKey struct {
+	proto       uint8
+	client      string
+	service     string
+	servicePort uint16
+	backend     string
+	backendPort uint16
+}
+
+// isDNAT tells whether the destination of the connection was translated: the
+// replies of a translated connection come from another address or port than
+// the one the client sent its packets to.
+func isDNAT(flow *netlink.ConntrackFlow) bool {
+	return !flow.Forward.DstIP.Equal(flow.Reverse.SrcIP) ||
+		flow.Forward.DstPort != flow.Reverse.SrcPort
+}
+
+func endpoint(ip net.IP) eventtypes.L3Endpoint {
+	version := uint8(6)
+	if ip.To4() != nil {
+		version = 4
+	}
+	return eventtypes.L3Endpoint{
+		Addr:    ip.String(),
+		Version: version,
+	}
+}
+
+// aggregateFlows counts the connections of the flows for each mapping from a
+// service to a backend. The clients are left out of the mappings when
+// perBackend is set.
+func aggregateFlows(flows []*netlink.ConntrackFlow, perBackend bool) []*types.Event {
+	events := []*types.Event{}
+	mappings := make(map[mappingKey]*types.Event)
+
+	for _, flow := range flows {
+		switch flow.Forward.Protocol {
+		case unix.IPPROTO_TCP, unix.IPPROTO_UDP, unix.IPPROTO_SCTP:
+		default:
+			continue
+		}
+
+		if !isDNAT(flow) {
+			continue
+		}
+
+		key := mappingKey{
+			proto:       flow.Forward.Protocol,
+			service:     flow.Forward.DstIP.String(),
+			servicePort: flow.Forward.DstPort,
+			backend:     flow.Reverse.SrcIP.String(),
+			backendPort: flow.Reverse.SrcPort,
+		}
+		if !perBackend {
+			key.client = flow.Forward.SrcIP.String()
+		}
+
+		event, ok := mappings[key]
+		if !ok {
+			event = &types.Event{
+				Event: eventtypes.Event{
+					Type: eventtypes.NORMAL,
+				},
+				Service: eventtypes.L4Endpoint{
+					L3Endpoint: endpoint(flow.Forward.DstIP),
+					Port:       flow.Forward.DstPort,
+					Proto:      uint16(flow.Forward.Protocol),
+				},
+				Backend: eventtypes.L4Endpoint{
+					L3Endpoint: endpoint(flow.Reverse.SrcIP),
+					Port:       flow.Reverse.SrcPort,
+					Proto:      uint16(flow.Forward.Protocol),
+				},
+			}
+			if !perBackend {
+				event.Client = endpoint(flow.Forward.SrcIP)
+			}
+			mappings[key] = event
+			events = append(events, event)
+		}
+
+		event.Connections++
+		event.Packets += flow.Forward.Packets + flow.Reverse.Packets
+		event.Bytes += flow.Forward.Bytes + flow.Reverse.Bytes
+	}
+
+	return events
+}
+
+// listFlows lists the IPv4 and IPv6 connections tracked in the network
+// namespace of the caller
+func listFlows() ([]*netlink.ConntrackFlow, error) {
+	flows := []*netlink.ConntrackFlow{}
+	for _, family := range []netlink.InetFamily{unix.AF_INET, unix.AF_INET6} {
+		familyFlows, err := netlink.ConntrackTableList(netlink.ConntrackTable, family)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, familyFlows...)
+	}
+	return flows, nil
+}
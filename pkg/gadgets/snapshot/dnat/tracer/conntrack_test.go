@@ -0,0 +1,96 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func flow(proto uint8, client, service string, servicePort uint16, backend string, backendPort uint16, packets uint64) *netlink.ConntrackFlow {
+	return &netlink.ConntrackFlow{
+		Forward: netlink.IPTuple{
+			Protocol: proto,
+			SrcIP:    net.ParseIP(client),
+			SrcPort:  40000,
+			DstIP:    net.ParseIP(service),
+			DstPort:  servicePort,
+			Packets:  packets,
+			Bytes:    packets * 100,
+		},
+		Reverse: netlink.IPTuple{
+			Protocol: proto,
+			SrcIP:    net.ParseIP(backend),
+			SrcPort:  backendPort,
+			DstIP:    net.ParseIP(client),
+			DstPort:  40000,
+			Packets:  packets,
+			Bytes:    packets * 100,
+		},
+	}
+}
+
+func TestAggregateFlows(t *testing.T) {
+	t.Parallel()
+
+	flows := []*netlink.ConntrackFlow{
+		flow(unix.IPPROTO_TCP, "10.244.0.5", "10.96.0.10", 80, "10.244.1.7", 8080, 10),
+		flow(unix.IPPROTO_TCP, "10.244.0.5", "10.96.0.10", 80, "10.244.1.7", 8080, 5),
+		flow(unix.IPPROTO_TCP, "10.244.0.6", "10.96.0.10", 80, "10.244.1.7", 8080, 1),
+		flow(unix.IPPROTO_TCP, "10.244.0.6", "10.96.0.10", 80, "10.244.2.3", 8080, 1),
+		flow(unix.IPPROTO_UDP, "fd00::5", "fd00:10::a", 53, "fd00::7", 53, 2),
+		// Not translated
+		flow(unix.IPPROTO_TCP, "10.244.0.5", "10.244.1.7", 8080, "10.244.1.7", 8080, 3),
+		// No ports
+		flow(unix.IPPROTO_ICMP, "10.244.0.5", "10.96.0.10", 0, "10.244.1.7", 0, 1),
+	}
+
+	events := aggregateFlows(flows, false)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, "10.244.0.5", events[0].Client.Addr)
+	assert.Equal(t, uint8(4), events[0].Client.Version)
+	assert.Equal(t, "10.96.0.10", events[0].Service.Addr)
+	assert.Equal(t, uint16(80), events[0].Service.Port)
+	assert.Equal(t, uint16(unix.IPPROTO_TCP), events[0].Service.Proto)
+	assert.Equal(t, "10.244.1.7", events[0].Backend.Addr)
+	assert.Equal(t, uint16(8080), events[0].Backend.Port)
+	assert.Equal(t, uint32(2), events[0].Connections)
+	assert.Equal(t, uint64(30), events[0].Packets)
+	assert.Equal(t, uint64(3000), events[0].Bytes)
+
+	assert.Equal(t, "10.244.0.6", events[1].Client.Addr)
+	assert.Equal(t, uint32(1), events[1].Connections)
+	assert.Equal(t, "10.244.2.3", events[2].Backend.Addr)
+
+	assert.Equal(t, "fd00::5", events[3].Client.Addr)
+	assert.Equal(t, uint8(6), events[3].Client.Version)
+	assert.Equal(t, "fd00::7", events[3].Backend.Addr)
+	assert.Equal(t, uint16(unix.IPPROTO_UDP), events[3].Backend.Proto)
+
+	events = aggregateFlows(flows, true)
+	require.Len(t, events, 3)
+
+	assert.Empty(t, events[0].Client.Addr)
+	assert.Equal(t, "10.244.1.7", events[0].Backend.Addr)
+	assert.Equal(t, uint32(3), events[0].Connections)
+	assert.Equal(t, "10.244.2.3", events[1].Backend.Addr)
+	assert.Equal(t, uint32(1), events[1].Connections)
+}
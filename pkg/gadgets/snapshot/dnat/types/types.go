@@ -0,0 +1,89 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/ellipsis"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// Event is a DNAT mapping, from a service to one of its backends, used by the
+// connections of a client
+type Event struct {
+	eventtypes.Event
+
+	// Client is empty when the connections of all the clients are counted
+	// together
+	Client eventtypes.L3Endpoint `json:"client,omitempty" column:"client"`
+	// Service is the destination the client connects to: the virtual IP of a
+	// Kubernetes service, a node port or a port published by a container
+	Service eventtypes.L4Endpoint `json:"service" column:"service"`
+	// Backend is the destination the connections are translated to
+	Backend eventtypes.L4Endpoint `json:"backend" column:"backend"`
+
+	Connections uint32 `json:"connections" column:"connections,order:4000,minWidth:11,align:right"`
+	// Packets and Bytes are only counted when the conntrack accounting of
+	// the node is enabled (net.netfilter.nf_conntrack_acct sysctl)
+	Packets uint64 `json:"packets,omitempty" column:"packets,order:4001,minWidth:7,align:right,hide"`
+	Bytes   uint64 `json:"bytes,omitempty" column:"bytes,order:4002,minWidth:6,align:right,hide"`
+}
+
+func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
+	endpoints := []*eventtypes.L3Endpoint{&e.Service.L3Endpoint, &e.Backend.L3Endpoint}
+	if e.Client.Addr != "" {
+		endpoints = append(endpoints, &e.Client)
+	}
+	return endpoints
+}
+
+func GetColumns() *columns.Columns[Event] {
+	dnatColumns := columns.MustCreateColumns[Event]()
+
+	eventtypes.MustAddVirtualL3EndpointColumn(
+		dnatColumns,
+		columns.Attributes{
+			Name:         "client",
+			Width:        32,
+			MinWidth:     21,
+			Visible:      true,
+			Order:        1000,
+			EllipsisType: ellipsis.Start,
+		},
+		func(e *Event) eventtypes.L3Endpoint { return e.Client },
+	)
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		dnatColumns,
+		columns.Attributes{
+			Name:     "service",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    2000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.Service },
+	)
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		dnatColumns,
+		columns.Attributes{
+			Name:     "backend",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    3000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.Backend },
+	)
+
+	return dnatColumns
+}
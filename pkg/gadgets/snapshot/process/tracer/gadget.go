@@ -69,10 +69,17 @@ func (g *GadgetDesc) EventPrototype() any {
 }
 
 func (g *GadgetDesc) OutputFormats() (gadgets.OutputFormats, string) {
+	return TreeOutputFormats(), "columns"
+}
+
+// TreeOutputFormats returns the output formats rendering processes as a tree
+// per container. They are shared with the top process gadget.
+func TreeOutputFormats() gadgets.OutputFormats {
 	return gadgets.OutputFormats{
 		"tree": gadgets.OutputFormat{
 			Name:        "Tree",
 			Description: "A pstree like output",
+			Flag:        "tree",
 			Transform: func(data any) ([]byte, error) {
 				processes, ok := data.([]*types.Event)
 				if !ok {
@@ -88,7 +95,7 @@ func (g *GadgetDesc) OutputFormats() (gadgets.OutputFormats, string) {
 				return []byte(builder.String()), nil
 			},
 		},
-	}, "columns"
+	}
 }
 
 func (g *GadgetDesc) SortByDefault() []string {
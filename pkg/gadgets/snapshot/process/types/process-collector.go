@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
@@ -49,8 +50,12 @@ type processTree struct {
 	children []*processTree
 }
 
-func createTree(processes []*Event) (*processTree, error) {
-	var root *processTree
+// createForest links the processes of a container to their parents. Processes
+// whose parent isn't part of the container are roots, like the init process
+// of the container or the processes started by "kubectl exec", which are
+// reparented to the container runtime.
+func createForest(processes []*Event) ([]*processTree, error) {
+	var roots []*processTree
 
 	nodes := make(map[int]*processTree, len(processes))
 	// Create a node for each process.
@@ -66,19 +71,14 @@ func createTree(processes []*Event) (*processTree, error) {
 		node := nodes[process.Pid]
 		ppid := node.process.ParentPid
 		if _, ok := nodes[ppid]; !ok {
-			if root != nil {
-				return nil, fmt.Errorf("tree has two root processes: %v and %v", root, node)
-			}
-
-			root = node
-
+			roots = append(roots, node)
 			continue
 		}
 
 		nodes[ppid].children = append(nodes[ppid].children, node)
 	}
 
-	if root == nil {
+	if len(roots) == 0 {
 		// Even if there are orphan process, they should have a parent process
 		// as they will get the reaper as parent process:
 		// https://elixir.bootlin.com/linux/v6.1.3/source/kernel/exit.c#L653
@@ -87,7 +87,18 @@ func createTree(processes []*Event) (*processTree, error) {
 		return nil, fmt.Errorf("container has no root process")
 	}
 
-	return root, nil
+	return roots, nil
+}
+
+func createTree(processes []*Event) (*processTree, error) {
+	roots, err := createForest(processes)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) > 1 {
+		return nil, fmt.Errorf("tree has two root processes: %v and %v", roots[0], roots[1])
+	}
+	return roots[0], nil
 }
 
 func (t *processTree) String() string {
@@ -104,32 +115,63 @@ func treeToStringBuilder(node *processTree, builder *strings.Builder, depth int)
 	}
 }
 
+// containerKey identifies a container across nodes
+type containerKey struct {
+	node    string
+	mntNsID uint64
+}
+
+func containerName(process *Event) string {
+	var name string
+	if process.K8s.Namespace != "" {
+		name += process.K8s.Namespace + "/"
+	}
+	if process.K8s.PodName != "" && process.K8s.PodName != process.K8s.ContainerName {
+		name += process.K8s.PodName + "/"
+	}
+	if process.K8s.ContainerName != "" {
+		return name + process.K8s.ContainerName
+	}
+	return name + process.Runtime.ContainerName
+}
+
+// WriteTree writes the processes as one tree per container, sorted by
+// container name. Processes are sorted by PID.
 func WriteTree(output io.Writer, processes []*Event) error {
-	containers := make(map[uint64][]*Event, len(processes))
-	for _, process := range processes {
-		mntNsID := process.GetMountNSID()
-		containers[mntNsID] = append(containers[mntNsID], process)
+	sorted := make([]*Event, len(processes))
+	copy(sorted, processes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Pid < sorted[j].Pid
+	})
+
+	var keys []containerKey
+	containers := make(map[containerKey][]*Event, len(processes))
+	for _, process := range sorted {
+		key := containerKey{node: process.K8s.Node, mntNsID: process.GetMountNSID()}
+		if _, ok := containers[key]; !ok {
+			keys = append(keys, key)
+		}
+		containers[key] = append(containers[key], process)
 	}
 
-	for _, container := range containers {
-		tree, err := createTree(container)
+	sort.SliceStable(keys, func(i, j int) bool {
+		ni, nj := containerName(containers[keys[i]][0]), containerName(containers[keys[j]][0])
+		if ni != nj {
+			return ni < nj
+		}
+		return keys[i].node < keys[j].node
+	})
+
+	for _, key := range keys {
+		roots, err := createForest(containers[key])
 		if err != nil {
 			return err
 		}
 
-		if tree.process.K8s.Namespace != "" {
-			fmt.Fprintf(output, "%s/", tree.process.K8s.Namespace)
-		}
-		if tree.process.K8s.PodName != "" && tree.process.K8s.PodName != tree.process.K8s.ContainerName {
-			fmt.Fprintf(output, "%s/", tree.process.K8s.PodName)
+		fmt.Fprintln(output, containerName(roots[0].process))
+		for _, root := range roots {
+			fmt.Fprint(output, root)
 		}
-		if tree.process.K8s.ContainerName != "" {
-			fmt.Fprintln(output, tree.process.K8s.ContainerName)
-		} else {
-			fmt.Fprintln(output, tree.process.Runtime.ContainerName)
-		}
-
-		fmt.Fprint(output, tree)
 	}
 
 	return nil
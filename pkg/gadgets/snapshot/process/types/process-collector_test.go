@@ -21,6 +21,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
 type stsTestCase struct {
@@ -179,3 +181,44 @@ func TestTreeToString(t *testing.T) {
 		i++
 	}
 }
+
+func TestWriteTree(t *testing.T) {
+	t.Parallel()
+
+	process := func(container string, mntns uint64, comm string, pid, ppid int) *Event {
+		ev := &Event{
+			Command:       comm,
+			Pid:           pid,
+			ParentPid:     ppid,
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: mntns},
+		}
+		ev.K8s.Namespace = "default"
+		ev.K8s.PodName = "mypod"
+		ev.K8s.ContainerName = container
+		return ev
+	}
+
+	events := []*Event{
+		process("web", 2, "nginx", 20, 1),
+		process("db", 1, "sh", 12, 3),
+		process("web", 2, "nginx", 21, 20),
+		process("db", 1, "postgres", 10, 2),
+		// Started by "kubectl exec", its parent is outside of the container
+		process("db", 1, "psql", 13, 12),
+		process("db", 1, "postgres", 11, 10),
+	}
+
+	var builder strings.Builder
+	require.NoError(t, WriteTree(&builder, events))
+
+	expected := `default/mypod/db
+|-postgres(10)
+	|-postgres(11)
+|-sh(12)
+	|-psql(13)
+default/mypod/web
+|-nginx(20)
+	|-nginx(21)
+`
+	require.Equal(t, expected, builder.String())
+}
@@ -16,6 +16,7 @@ package tracer
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
@@ -45,7 +46,7 @@ func (g *GadgetDesc) Type() gadgets.GadgetType {
 }
 
 func (g *GadgetDesc) Description() string {
-	return "Gather information about TCP and UDP sockets"
+	return "Gather information about TCP, UDP and UNIX domain sockets"
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
@@ -53,13 +54,17 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 	for protocol := range types.ProtocolsMap {
 		protocols = append(protocols, protocol)
 	}
+	sort.Strings(protocols)
 	return params.ParamDescs{
 		{
-			Key:            ParamProto,
-			Title:          "Protocol",
-			DefaultValue:   "all",
-			Description:    fmt.Sprintf("Show only sockets using this protocol (%s)", strings.Join(protocols, ", ")),
-			PossibleValues: protocols,
+			Key:          ParamProto,
+			Title:        "Protocol",
+			DefaultValue: "all",
+			Description:  fmt.Sprintf("Show only sockets using these comma-separated protocols (%s)", strings.Join(protocols, ", ")),
+			Validator: func(value string) error {
+				_, err := types.ParseProtocol(value)
+				return err
+			},
 		},
 	}
 }
@@ -0,0 +1,191 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	socketcollectortypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/socket/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// __SO_ACCEPTCON from include/linux/net.h, set on listening sockets
+const unixFlagAcceptCon = 0x10000
+
+// Format from unix_seq_show() in net/unix/af_unix.c
+var unixStates = map[uint64]string{
+	1: "UNCONNECTED",
+	2: "CONNECTING",
+	3: "CONNECTED",
+	4: "DISCONNECTING",
+}
+
+var unixTypes = map[uint64]string{
+	1: "STREAM",
+	2: "DGRAM",
+	5: "SEQPACKET",
+}
+
+// readUnixSockets returns the UNIX domain sockets of the network namespace of
+// pid. They are read from procfs because the BPF iterator for UNIX sockets
+// requires Linux 5.17.
+func readUnixSockets(pid uint32) ([]*socketcollectortypes.Event, error) {
+	f, err := os.Open(filepath.Join(host.HostProcFs, fmt.Sprint(pid), "net", "unix"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseUnixSockets(f)
+}
+
+func parseUnixSockets(r io.Reader) ([]*socketcollectortypes.Event, error) {
+	sockets := []*socketcollectortypes.Event{}
+
+	scanner := bufio.NewScanner(r)
+	// Skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		// Num RefCount Protocol Flags Type St Inode [Path]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("invalid UNIX socket line %q", scanner.Text())
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing flags %q: %w", fields[3], err)
+		}
+		sockType, err := strconv.ParseUint(fields[4], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("parsing type %q: %w", fields[4], err)
+		}
+		state, err := strconv.ParseUint(fields[5], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parsing state %q: %w", fields[5], err)
+		}
+		inode, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing inode %q: %w", fields[6], err)
+		}
+
+		status, ok := unixStates[state]
+		if !ok {
+			return nil, fmt.Errorf("invalid UNIX status: %d", state)
+		}
+		if flags&unixFlagAcceptCon != 0 {
+			status = "LISTEN"
+		}
+
+		typeStr, ok := unixTypes[sockType]
+		if !ok {
+			typeStr = fmt.Sprintf("UNKNOWN#%d", sockType)
+		}
+
+		sockets = append(sockets, &socketcollectortypes.Event{
+			Event: eventtypes.Event{
+				Type: eventtypes.NORMAL,
+			},
+			Protocol:    socketcollectortypes.ProtoUnix,
+			Status:      status,
+			InodeNumber: inode,
+			Path:        strings.Join(fields[7:], " "),
+			SockType:    typeStr,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return sockets, nil
+}
+
+type socketOwner struct {
+	pid  uint32
+	comm string
+}
+
+// socketOwners returns the processes of the network namespace netns having
+// sockets open, indexed by the inode of the sockets. When a socket is shared
+// by several processes, the one with the lowest pid is kept.
+func socketOwners(netns uint64) map[uint64]socketOwner {
+	owners := make(map[uint64]socketOwner)
+
+	entries, err := os.ReadDir(host.HostProcFs)
+	if err != nil {
+		return owners
+	}
+	for _, entry := range entries {
+		pid, err := strconv.ParseUint(entry.Name(), 10, 32)
+		if err != nil {
+			continue
+		}
+		if ns, err := containerutils.GetNetNs(int(pid)); err != nil || ns != netns {
+			continue
+		}
+
+		fdDir := filepath.Join(host.HostProcFs, entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			// The process is gone or we aren't allowed to look at it
+			continue
+		}
+
+		var comm string
+		for _, fd := range fds {
+			inode, ok := socketInode(filepath.Join(fdDir, fd.Name()))
+			if !ok {
+				continue
+			}
+			if owner, ok := owners[inode]; ok && owner.pid < uint32(pid) {
+				continue
+			}
+			if comm == "" {
+				comm = host.GetProcComm(int(pid))
+			}
+			owners[inode] = socketOwner{pid: uint32(pid), comm: comm}
+		}
+	}
+
+	return owners
+}
+
+// socketInode returns the inode of the socket the file descriptor fdPath
+// refers to, the link is like "socket:[12345]"
+func socketInode(fdPath string) (uint64, bool) {
+	link, err := os.Readlink(fdPath)
+	if err != nil {
+		return 0, false
+	}
+	link, ok := strings.CutPrefix(link, "socket:[")
+	if !ok {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(strings.TrimSuffix(link, "]"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return inode, true
+}
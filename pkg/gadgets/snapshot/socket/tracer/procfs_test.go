@@ -0,0 +1,121 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package tracer
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/socket/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/nsenter"
+)
+
+func TestParseUnixSockets(t *testing.T) {
+	t.Parallel()
+
+	input := `Num       RefCount Protocol Flags    Type St Inode Path
+00000000d4be4a97: 00000003 00000000 00000000 0001 03   934
+0000000011ad4864: 00000002 00000000 00010000 0001 01 579115 /run/my app.sock
+0000000092759c8c: 00000002 00000000 00000000 0002 01 584801 @abstract
+0000000092759c8d: 00000002 00000000 00000000 0005 02 584802
+`
+	sockets, err := parseUnixSockets(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, sockets, 4)
+
+	expected := []struct {
+		status   string
+		sockType string
+		inode    uint64
+		path     string
+	}{
+		{"CONNECTED", "STREAM", 934, ""},
+		{"LISTEN", "STREAM", 579115, "/run/my app.sock"},
+		{"UNCONNECTED", "DGRAM", 584801, "@abstract"},
+		{"CONNECTING", "SEQPACKET", 584802, ""},
+	}
+	for i, e := range expected {
+		require.Equal(t, types.ProtoUnix, sockets[i].Protocol)
+		require.Equal(t, e.status, sockets[i].Status)
+		require.Equal(t, e.sockType, sockets[i].SockType)
+		require.Equal(t, e.inode, sockets[i].InodeNumber)
+		require.Equal(t, e.path, sockets[i].Path)
+	}
+
+	_, err = parseUnixSockets(strings.NewReader("header\n0000: 00000002 00000000 00000000 0001 09 1\n"))
+	require.Error(t, err)
+}
+
+// Not parallel: the runners of the other tests move threads of the test
+// process, including the main one, to other network namespaces.
+func TestReadUnixSocketsAndOwners(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	// Create the socket in the network namespace procfs shows for the process
+	path := filepath.Join(t.TempDir(), "test.sock")
+	var l net.Listener
+	err := nsenter.NetnsEnter(os.Getpid(), func() error {
+		var err error
+		l, err = net.Listen("unix", path)
+		return err
+	})
+	require.NoError(t, err)
+	defer l.Close()
+
+	sockets, err := readUnixSockets(uint32(os.Getpid()))
+	require.NoError(t, err)
+
+	var found *types.Event
+	for _, s := range sockets {
+		if s.Path == path {
+			found = s
+			break
+		}
+	}
+	require.NotNil(t, found, "socket %s not found", path)
+	require.Equal(t, "LISTEN", found.Status)
+
+	netns, err := containerutils.GetNetNs(os.Getpid())
+	require.NoError(t, err)
+
+	owner, ok := socketOwners(netns)[found.InodeNumber]
+	require.True(t, ok, "owner of socket %d not found", found.InodeNumber)
+	require.Equal(t, uint32(os.Getpid()), owner.pid)
+}
+
+func TestParseProtocol(t *testing.T) {
+	t.Parallel()
+
+	p, err := types.ParseProtocol("tcp,UNIX")
+	require.NoError(t, err)
+	require.Equal(t, types.TCP|types.UNIX, p)
+
+	p, err = types.ParseProtocol("all")
+	require.NoError(t, err)
+	require.Equal(t, types.TCP|types.UDP|types.UNIX, p)
+
+	_, err = types.ParseProtocol("tcp,sctp")
+	require.Error(t, err)
+}
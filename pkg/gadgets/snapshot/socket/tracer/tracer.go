@@ -135,6 +135,25 @@ func (t *Tracer) runCollector(pid uint32, netns uint64) ([]*socketcollectortypes
 		return nil, err
 	}
 
+	if t.protocols&socketcollectortypes.UNIX != 0 {
+		unixSockets, err := readUnixSockets(pid)
+		if err != nil {
+			return nil, fmt.Errorf("reading UNIX sockets: %w", err)
+		}
+		for _, socket := range unixSockets {
+			socket.WithNetNsID = eventtypes.WithNetNsID{NetNsID: netns}
+		}
+		sockets = append(sockets, unixSockets...)
+	}
+
+	owners := socketOwners(netns)
+	for _, socket := range sockets {
+		if owner, ok := owners[socket.InodeNumber]; ok && socket.InodeNumber != 0 {
+			socket.Pid = owner.pid
+			socket.Comm = owner.comm
+		}
+	}
+
 	return sockets, nil
 }
 
@@ -211,6 +230,11 @@ func (t *Tracer) CloseIters() {
 }
 
 func (t *Tracer) openIters() error {
+	// UNIX sockets are read from procfs, see readUnixSockets()
+	if t.protocols&(socketcollectortypes.TCP|socketcollectortypes.UDP) == 0 {
+		return nil
+	}
+
 	// TODO: how to avoid loading programs that aren't needed?
 	objs := &socketObjects{}
 	if err := loadSocketObjects(objs, nil); err != nil {
@@ -219,13 +243,11 @@ func (t *Tracer) openIters() error {
 
 	toAttach := []*ebpf.Program{}
 
-	switch t.protocols {
-	case socketcollectortypes.TCP:
+	if t.protocols&socketcollectortypes.TCP != 0 {
 		toAttach = append(toAttach, objs.IgSnapTcp)
-	case socketcollectortypes.UDP:
+	}
+	if t.protocols&socketcollectortypes.UDP != 0 {
 		toAttach = append(toAttach, objs.IgSnapUdp)
-	case socketcollectortypes.ALL:
-		toAttach = append(toAttach, objs.IgSnapTcp, objs.IgSnapUdp)
 	}
 
 	for _, prog := range toAttach {
@@ -246,8 +268,11 @@ func (t *Tracer) openIters() error {
 }
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
-	protocols := gadgetCtx.GadgetParams().Get(ParamProto).AsString()
-	t.protocols = socketcollectortypes.ProtocolsMap[protocols]
+	protocols, err := socketcollectortypes.ParseProtocol(gadgetCtx.GadgetParams().Get(ParamProto).AsString())
+	if err != nil {
+		return err
+	}
+	t.protocols = protocols
 
 	defer t.CloseIters()
 	if err := t.openIters(); err != nil {
@@ -206,6 +206,30 @@ func TestSnapshotSocket(t *testing.T) {
 				}
 				t.Cleanup(func() { conn.Close() })
 
+				return nil
+			},
+		},
+		{
+			name:  "listen_unix",
+			proto: types.UNIX,
+			addr:  "@ig-test-snapshot-socket",
+			expectedEvent: func(info *utilstest.RunnerInfo, _ any) *types.Event {
+				return &types.Event{
+					Event:       eventtypes.Event{Type: eventtypes.NORMAL},
+					WithNetNsID: eventtypes.WithNetNsID{NetNsID: info.NetworkNsID},
+					Protocol:    "UNIX",
+					Status:      "LISTEN",
+					Path:        "@ig-test-snapshot-socket",
+					SockType:    "STREAM",
+				}
+			},
+			socketCreator: func(addr string, _ int) error {
+				conn, err := net.Listen("unix", addr)
+				if err != nil {
+					return fmt.Errorf("listening to %s: %w", addr, err)
+				}
+				t.Cleanup(func() { conn.Close() })
+
 				return nil
 			},
 		},
@@ -235,6 +259,11 @@ func TestSnapshotSocket(t *testing.T) {
 				// This is hard to guess the inode number, let's normalize it for the
 				// moment.
 				events[i].InodeNumber = 0
+
+				// The process owning the socket depends on the thread the
+				// runner used, don't check it here.
+				events[i].Pid = 0
+				events[i].Comm = ""
 			}
 
 			utilstest.ExpectAtLeastOneEvent(c.expectedEvent)(t, runner.Info, nil, events)
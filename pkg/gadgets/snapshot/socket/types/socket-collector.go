@@ -23,21 +23,27 @@ import (
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
+// Proto is a set of protocols
 type Proto int
 
 const (
-	INVALID Proto = iota
-	ALL
-	TCP
+	INVALID Proto = 0
+	TCP     Proto = 1 << (iota - 1)
 	UDP
+	UNIX
+	ALL = TCP | UDP | UNIX
 )
 
 var ProtocolsMap = map[string]Proto{
-	"all": ALL,
-	"tcp": TCP,
-	"udp": UDP,
+	"all":  ALL,
+	"tcp":  TCP,
+	"udp":  UDP,
+	"unix": UNIX,
 }
 
+// ProtoUnix is the protocol of the events of UNIX domain sockets
+const ProtoUnix = "UNIX"
+
 type Event struct {
 	eventtypes.Event
 	eventtypes.WithNetNsID
@@ -45,11 +51,23 @@ type Event struct {
 	Protocol    string                `json:"protocol" column:"protocol,maxWidth:8"`
 	SrcEndpoint eventtypes.L4Endpoint `json:"src,omitempty" column:"src"`
 	DstEndpoint eventtypes.L4Endpoint `json:"dst,omitempty" column:"dst"`
-	Status      string                `json:"status" column:"status,order:1002,maxWidth:12"`
+	Status      string                `json:"status" column:"status,order:1002,maxWidth:13"`
 	InodeNumber uint64                `json:"inodeNumber" column:"inode,order:1003,hide"`
+
+	// Path and SockType are only set for UNIX domain sockets. Abstract
+	// addresses start with @.
+	Path     string `json:"path,omitempty" column:"path,order:1004,hide"`
+	SockType string `json:"sockType,omitempty" column:"socktype,order:1005,maxWidth:9,hide"`
+
+	// Pid and Comm are the ones of a process having the socket open, if any
+	Pid  uint32 `json:"pid,omitempty" column:"pid,order:1006,template:pid"`
+	Comm string `json:"comm,omitempty" column:"comm,order:1007,template:comm"`
 }
 
 func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
+	if e.Protocol == ProtoUnix {
+		return nil
+	}
 	return []*eventtypes.L3Endpoint{&e.SrcEndpoint.L3Endpoint, &e.DstEndpoint.L3Endpoint}
 }
 
@@ -62,34 +80,49 @@ func GetColumns() *columns.Columns[Event] {
 		col.Visible = false
 	}
 
-	eventtypes.MustAddVirtualL4EndpointColumn(
-		cols,
+	// UNIX domain sockets have no endpoints, show their path instead
+	cols.MustAddColumn(
 		columns.Attributes{
 			Name:     "src",
 			Visible:  true,
 			Template: "ipaddrport",
 			Order:    1000,
 		},
-		func(e *Event) eventtypes.L4Endpoint { return e.SrcEndpoint },
+		func(e *Event) any {
+			if e.Protocol == ProtoUnix {
+				return e.Path
+			}
+			return e.SrcEndpoint.String()
+		},
 	)
-	eventtypes.MustAddVirtualL4EndpointColumn(
-		cols,
+	cols.MustAddColumn(
 		columns.Attributes{
 			Name:     "dst",
 			Visible:  true,
 			Template: "ipaddrport",
 			Order:    1001,
 		},
-		func(e *Event) eventtypes.L4Endpoint { return e.DstEndpoint },
+		func(e *Event) any {
+			if e.Protocol == ProtoUnix {
+				return ""
+			}
+			return e.DstEndpoint.String()
+		},
 	)
 
 	return cols
 }
 
+// ParseProtocol parses a comma-separated list of protocols, e.g. "tcp,unix"
 func ParseProtocol(protocol string) (Proto, error) {
-	if r, ok := ProtocolsMap[strings.ToLower(protocol)]; ok {
-		return r, nil
+	ret := INVALID
+	for _, p := range strings.Split(protocol, ",") {
+		r, ok := ProtocolsMap[strings.ToLower(strings.TrimSpace(p))]
+		if !ok {
+			return INVALID, fmt.Errorf("%q is not a valid protocol value", protocol)
+		}
+		ret |= r
 	}
 
-	return INVALID, fmt.Errorf("%q is not a valid protocol value", protocol)
+	return ret, nil
 }
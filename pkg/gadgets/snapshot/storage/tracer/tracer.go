@@ -0,0 +1,115 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	storagetypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/storage/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+type Tracer struct {
+	// containers is a map where the key is the container ID
+	containers   map[string]*containercollection.Container
+	eventHandler func([]*storagetypes.Event)
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	return &Tracer{
+		containers: make(map[string]*containercollection.Container),
+	}, nil
+}
+
+func (t *Tracer) AttachContainer(container *containercollection.Container) error {
+	t.containers[container.Runtime.ContainerID] = container
+	return nil
+}
+
+func (t *Tracer) DetachContainer(container *containercollection.Container) error {
+	delete(t.containers, container.Runtime.ContainerID)
+	return nil
+}
+
+func (t *Tracer) SetEventHandlerArray(handler any) {
+	nh, ok := handler.(func(ev []*storagetypes.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventHandler = nh
+}
+
+func (t *Tracer) newEvent(container *containercollection.Container, kind, volume, path string) (*storagetypes.Event, error) {
+	bytes, inodes, err := diskUsage(filepath.Join(host.HostRoot, path))
+	if err != nil {
+		return nil, err
+	}
+	return &storagetypes.Event{
+		Event: eventtypes.Event{
+			Type: eventtypes.NORMAL,
+		},
+		WithMountNsID: eventtypes.WithMountNsID{MountNsID: container.Mntns},
+		Kind:          kind,
+		Volume:        volume,
+		Bytes:         bytes,
+		Inodes:        inodes,
+		Path:          path,
+	}, nil
+}
+
+func (t *Tracer) runCollector(gadgetCtx gadgets.GadgetContext, container *containercollection.Container) []*storagetypes.Event {
+	logger := gadgetCtx.Logger()
+	events := []*storagetypes.Event{}
+
+	upperDir, err := containerUpperDir(container.ContainerPid())
+	if err != nil {
+		logger.Warnf("getting writable layer of container %q: %s", container.Runtime.ContainerID, err)
+	} else if upperDir != "" {
+		event, err := t.newEvent(container, storagetypes.KindWritableLayer, "", upperDir)
+		if err != nil {
+			logger.Warnf("measuring writable layer of container %q: %s", container.Runtime.ContainerID, err)
+		} else {
+			events = append(events, event)
+		}
+	}
+
+	for volume, source := range emptyDirs(container.OciConfig) {
+		event, err := t.newEvent(container, storagetypes.KindEmptyDir, volume, source)
+		if err != nil {
+			logger.Warnf("measuring emptyDir %q of container %q: %s", volume, container.Runtime.ContainerID, err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	allEvents := []*storagetypes.Event{}
+	for _, container := range t.containers {
+		if err := gadgetCtx.Context().Err(); err != nil {
+			return fmt.Errorf("snapshotting storage: %w", err)
+		}
+		allEvents = append(allEvents, t.runCollector(gadgetCtx, container)...)
+	}
+
+	t.eventHandler(allEvents)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/mountinfo"
+)
+
+// emptyDirPathPart is part of the host path of emptyDir volumes, see
+// https://github.com/kubernetes/kubernetes/blob/v1.29.0/pkg/volume/emptydir/empty_dir.go
+const emptyDirPathPart = "/volumes/kubernetes.io~empty-dir/"
+
+// containerUpperDir returns the upper directory of the overlay filesystem
+// mounted as root of the container with the given pid. It returns an empty
+// string if the root filesystem isn't an overlay.
+func containerUpperDir(pid uint32) (string, error) {
+	f, err := os.Open(filepath.Join(host.HostProcFs, strconv.FormatUint(uint64(pid), 10), "mountinfo"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return parseUpperDir(f)
+}
+
+// parseUpperDir looks for the root mount in the content of
+// /proc/$pid/mountinfo and returns its upperdir option
+func parseUpperDir(r io.Reader) (string, error) {
+	mounts, err := mountinfo.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	root := mountinfo.Lookup(mounts, "/")
+	if root == nil || root.MountPoint != "/" {
+		return "", errors.New("root mount not found")
+	}
+	if root.FsType != "overlay" {
+		return "", nil
+	}
+	upperDir, _ := root.Option("upperdir")
+	return upperDir, nil
+}
+
+// emptyDirs returns the host path of the emptyDir volumes mounted in the
+// container, indexed by volume name
+func emptyDirs(spec *ocispec.Spec) map[string]string {
+	volumes := make(map[string]string)
+	if spec == nil {
+		return volumes
+	}
+	for _, m := range spec.Mounts {
+		idx := strings.Index(m.Source, emptyDirPathPart)
+		if idx == -1 {
+			continue
+		}
+		// Subpaths of the volume are mounted from a different location,
+		// only the volume itself is measured
+		name := m.Source[idx+len(emptyDirPathPart):]
+		if name == "" || strings.Contains(name, "/") {
+			continue
+		}
+		volumes[name] = m.Source
+	}
+	return volumes
+}
+
+// diskUsage returns the disk space in bytes and the number of inodes used by
+// path, like "du -s" does. Files with several hard links are only counted
+// once. Files removed in the meantime are ignored.
+func diskUsage(path string) (uint64, uint64, error) {
+	type inode struct {
+		dev uint64
+		ino uint64
+	}
+	seen := make(map[inode]struct{})
+
+	var bytes, inodes uint64
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p != path {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return fmt.Errorf("unexpected stat type for %q", p)
+		}
+		if st.Nlink > 1 && !d.IsDir() {
+			key := inode{dev: uint64(st.Dev), ino: st.Ino}
+			if _, ok := seen[key]; ok {
+				return nil
+			}
+			seen[key] = struct{}{}
+		}
+		// st_blocks is in units of 512 bytes, see stat(2)
+		bytes += uint64(st.Blocks) * 512
+		inodes++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return bytes, inodes, nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpperDir(t *testing.T) {
+	t.Parallel()
+
+	type testDefinition struct {
+		mountinfo     string
+		expected      string
+		expectedError bool
+	}
+
+	tests := map[string]testDefinition{
+		"overlay": {
+			mountinfo: "" +
+				"1043 985 0:130 / / rw,relatime master:425 - overlay overlay rw,lowerdir=/var/lib/containerd/l1:/var/lib/containerd/l2,upperdir=/var/lib/containerd/snapshots/42/fs,workdir=/var/lib/containerd/snapshots/42/work\n" +
+				"1044 1043 0:132 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw\n",
+			expected: "/var/lib/containerd/snapshots/42/fs",
+		},
+		"escaped": {
+			mountinfo: "1043 985 0:130 / / rw,relatime - overlay overlay rw,lowerdir=/l,upperdir=/my\\040dir/fs,workdir=/w\n",
+			expected:  "/my dir/fs",
+		},
+		"not_overlay": {
+			mountinfo: "1043 985 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n",
+			expected:  "",
+		},
+		"no_root": {
+			mountinfo:     "1044 1043 0:132 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw\n",
+			expectedError: true,
+		},
+		"invalid": {
+			mountinfo:     "1043 985 0:130 / / rw,relatime overlay overlay\n",
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			upperDir, err := parseUpperDir(strings.NewReader(test.mountinfo))
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, upperDir)
+		})
+	}
+}
+
+func TestEmptyDirs(t *testing.T) {
+	t.Parallel()
+
+	spec := &ocispec.Spec{
+		Mounts: []ocispec.Mount{
+			{Destination: "/cache", Source: "/var/lib/kubelet/pods/1234/volumes/kubernetes.io~empty-dir/cache"},
+			{Destination: "/data/sub", Source: "/var/lib/kubelet/pods/1234/volume-subpaths/cache/app/0"},
+			{Destination: "/etc/hosts", Source: "/var/lib/kubelet/pods/1234/etc-hosts"},
+			{Destination: "/run/secrets", Source: "/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access"},
+		},
+	}
+
+	assert.Equal(t, map[string]string{
+		"cache": "/var/lib/kubelet/pods/1234/volumes/kubernetes.io~empty-dir/cache",
+	}, emptyDirs(spec))
+	assert.Empty(t, emptyDirs(nil))
+}
+
+func TestDiskUsage(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sub", "file"), make([]byte, 64*1024), 0o644))
+	require.NoError(t, os.Link(filepath.Join(dir, "sub", "file"), filepath.Join(dir, "link")))
+
+	bytes, inodes, err := diskUsage(dir)
+	require.NoError(t, err)
+	// dir, sub and file, the hard link isn't counted again
+	assert.Equal(t, uint64(3), inodes)
+	assert.GreaterOrEqual(t, bytes, uint64(64*1024))
+	assert.Less(t, bytes, uint64(2*64*1024))
+
+	_, _, err = diskUsage(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
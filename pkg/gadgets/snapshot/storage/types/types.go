@@ -0,0 +1,54 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+
+	"github.com/docker/go-units"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	// KindWritableLayer is the upper directory of the overlay root
+	// filesystem of the container
+	KindWritableLayer = "writable-layer"
+
+	// KindEmptyDir is an emptyDir volume mounted in the container
+	KindEmptyDir = "emptyDir"
+)
+
+type Event struct {
+	eventtypes.Event
+	eventtypes.WithMountNsID
+
+	Kind   string `json:"kind" column:"kind,maxWidth:14"`
+	Volume string `json:"volume,omitempty" column:"volume,maxWidth:32"`
+	Bytes  uint64 `json:"bytes" column:"bytes,align:right"`
+	Inodes uint64 `json:"inodes" column:"inodes,align:right"`
+	Path   string `json:"path,omitempty" column:"path,hide"`
+}
+
+func GetColumns() *columns.Columns[Event] {
+	cols := columns.MustCreateColumns[Event]()
+
+	cols.MustSetExtractor("bytes", func(event *Event) any {
+		return fmt.Sprint(units.BytesSize(float64(event.Bytes)))
+	})
+
+	return cols
+}
@@ -24,6 +24,7 @@ struct {
 	__type(value, struct who_t);
 } whobyreq SEC(".maps");
 
+/* Turned into a per-CPU map by userspace, the values are summed when read. */
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 10240);
@@ -45,6 +45,8 @@ type Config struct {
 }
 
 type Tracer struct {
+	top.Pauser
+
 	config           *Config
 	objs             biotopObjects
 	ioStartLink      link.Link
@@ -89,6 +91,12 @@ func (t *Tracer) Stop() {
 	t.close()
 }
 
+// Clear resets the statistics aggregated since the last interval without
+// stopping the tracer
+func (t *Tracer) Clear() error {
+	return gadgets.ClearMaps(t.objs.Counts)
+}
+
 func (t *Tracer) close() {
 	close(t.done)
 
@@ -105,11 +113,17 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
+	// Every completed request updates counts, use a per-CPU map to avoid
+	// contention on busy nodes
+	if err := top.UsePerCPUHash(spec, "counts"); err != nil {
+		return fmt.Errorf("using per-CPU map: %w", err)
+	}
+
 	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, nil, &t.objs); err != nil {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
-	kernelSymbols, err := kallsyms.NewKAllSyms()
+	kernelSymbols, err := kallsyms.Cached()
 	if err != nil {
 		return fmt.Errorf("loading kernel symbols: %w", err)
 	}
@@ -200,11 +214,18 @@ func (t *Tracer) nextStats() ([]*types.Stats, error) {
 	}
 
 	for {
-		val := biotopValT{}
-		if err := counts.Lookup(key, unsafe.Pointer(&val)); err != nil {
+		var vals []biotopValT
+		if err := counts.Lookup(key, &vals); err != nil {
 			return nil, err
 		}
 
+		val := biotopValT{}
+		for _, v := range vals {
+			val.Bytes += v.Bytes
+			val.Us += v.Us
+			val.Io += v.Io
+		}
+
 		stat := types.Stats{
 			Write:         key.Rwflag != 0,
 			Major:         int(key.Major),
@@ -253,6 +274,12 @@ func (t *Tracer) run(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
+			// Don't read (and clear) the maps while paused, so the kernel keeps
+			// aggregating the statistics
+			if t.Paused() {
+				continue
+			}
+
 			stats, err := t.nextStats()
 			if err != nil {
 				return fmt.Errorf("getting next stats: %w", err)
@@ -53,6 +53,8 @@ type programStats struct {
 }
 
 type Tracer struct {
+	top.Pauser
+
 	config        *Config
 	enricher      gadgets.DataNodeEnricher
 	eventCallback func(*top.Event[types.Stats])
@@ -411,6 +413,12 @@ func (t *Tracer) run(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
+			// Don't read (and clear) the maps while paused, so the kernel keeps
+			// aggregating the statistics
+			if t.Paused() {
+				continue
+			}
+
 			stats, err := t.nextStats()
 			if err != nil {
 				return fmt.Errorf("getting next stats: %w", err)
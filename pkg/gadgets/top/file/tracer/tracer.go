@@ -46,6 +46,8 @@ type Config struct {
 }
 
 type Tracer struct {
+	top.Pauser
+
 	config        *Config
 	objs          filetopObjects
 	readLink      link.Link
@@ -89,6 +91,12 @@ func (t *Tracer) Stop() {
 	t.close()
 }
 
+// Clear resets the statistics aggregated since the last interval without
+// stopping the tracer
+func (t *Tracer) Clear() error {
+	return gadgets.ClearMaps(t.objs.Entries)
+}
+
 func (t *Tracer) close() {
 	close(t.done)
 
@@ -104,6 +112,10 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
+	// Unlike the other top gadgets, entries isn't made per-CPU: its values
+	// contain the filename (PATH_MAX bytes), and having a copy of them for
+	// each CPU would use too much memory.
+
 	consts := map[string]interface{}{
 		"target_pid":        uint32(t.config.TargetPid),
 		"regular_file_only": !t.config.AllFiles,
@@ -218,6 +230,12 @@ func (t *Tracer) run(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
+			// Don't read (and clear) the maps while paused, so the kernel keeps
+			// aggregating the statistics
+			if t.Paused() {
+				continue
+			}
+
 			stats, err := t.nextStats()
 			if err != nil {
 				return fmt.Errorf("getting next stats: %w", err)
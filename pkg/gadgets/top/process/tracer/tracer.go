@@ -0,0 +1,93 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	processtracer "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/process/tracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/snapshot/process/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top"
+)
+
+// Tracer collects the running processes at every interval. Unlike other top
+// gadgets, it doesn't apply max-rows: a truncated tree would miss parents.
+type Tracer struct {
+	config       *processtracer.Config
+	eventHandler func(ev []*types.Event)
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	tracer := &Tracer{
+		config: &processtracer.Config{},
+	}
+	return tracer, nil
+}
+
+func (t *Tracer) SetEventHandlerArray(handler any) {
+	nh, ok := handler.(func(ev []*types.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventHandler = nh
+}
+
+func (t *Tracer) SetMountNsMap(mntnsMap *ebpf.Map) {
+	t.config.MountnsMap = mntnsMap
+}
+
+func (t *Tracer) collect() error {
+	processes, err := processtracer.RunCollector(t.config, nil)
+	if err != nil {
+		return fmt.Errorf("collecting processes: %w", err)
+	}
+	t.eventHandler(processes)
+	return nil
+}
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	interval := time.Second * time.Duration(gadgetCtx.GadgetParams().Get(gadgets.ParamInterval).AsInt())
+	iterations, err := top.ComputeIterations(interval, gadgetCtx.Timeout())
+	if err != nil {
+		return err
+	}
+
+	// Show the tree right away instead of waiting for the first interval
+	if err := t.collect(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := gadgetCtx.Context()
+	for count := 1; iterations == 0 || count < iterations; count++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := t.collect(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -16,6 +16,7 @@
 const volatile pid_t target_pid = 0;
 const volatile int target_family = -1;
 
+/* Turned into a per-CPU map by userspace, the values are summed when read. */
 struct {
 	__uint(type, BPF_MAP_TYPE_HASH);
 	__uint(max_entries, 10240);
@@ -46,6 +46,8 @@ type Config struct {
 }
 
 type Tracer struct {
+	top.Pauser
+
 	config             *Config
 	objs               tcptopObjects
 	tcpSendmsgLink     link.Link
@@ -89,6 +91,12 @@ func (t *Tracer) Stop() {
 	t.close()
 }
 
+// Clear resets the statistics aggregated since the last interval without
+// stopping the tracer
+func (t *Tracer) Clear() error {
+	return gadgets.ClearMaps(t.objs.IpMap)
+}
+
 func (t *Tracer) close() {
 	close(t.done)
 
@@ -104,6 +112,12 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
+	// ip_map is updated on each send and receive, use a per-CPU map to avoid
+	// contention on busy nodes
+	if err := top.UsePerCPUHash(spec, "ip_map"); err != nil {
+		return fmt.Errorf("using per-CPU map: %w", err)
+	}
+
 	consts := map[string]interface{}{
 		"target_pid":    t.config.TargetPid,
 		"target_family": t.config.TargetFamily,
@@ -162,11 +176,17 @@ func (t *Tracer) nextStats() ([]*types.Stats, error) {
 	}
 
 	for {
-		val := tcptopTrafficT{}
-		if err := ips.Lookup(key, unsafe.Pointer(&val)); err != nil {
+		var vals []tcptopTrafficT
+		if err := ips.Lookup(key, &vals); err != nil {
 			return nil, err
 		}
 
+		val := tcptopTrafficT{}
+		for _, v := range vals {
+			val.Sent += v.Sent
+			val.Received += v.Received
+		}
+
 		ipversion := gadgets.IPVerFromAF(key.Family)
 
 		stat := types.Stats{
@@ -228,6 +248,12 @@ func (t *Tracer) run(ctx context.Context) error {
 		case <-ctx.Done():
 			return nil
 		case <-ticker.C:
+			// Don't read (and clear) the maps while paused, so the kernel keeps
+			// aggregating the statistics
+			if t.Paused() {
+				continue
+			}
+
 			stats, err := t.nextStats()
 			if err != nil {
 				return fmt.Errorf("getting next stats: %w", err)
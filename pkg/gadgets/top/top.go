@@ -16,8 +16,11 @@ package top
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/cilium/ebpf"
+
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 	columnssort "github.com/inspektor-gadget/inspektor-gadget/pkg/columns/sort"
 )
@@ -36,6 +39,27 @@ type Event[T any] struct {
 	Stats []*T   `json:"stats,omitempty"`
 }
 
+// Pauser lets a tracer skip reading its maps while keeping its eBPF programs
+// attached, so statistics keep being aggregated kernel-side until it's resumed.
+type Pauser struct {
+	paused atomic.Bool
+}
+
+// Pause stops reading the statistics until Resume is called
+func (p *Pauser) Pause() {
+	p.paused.Store(true)
+}
+
+// Resume starts reading the statistics again
+func (p *Pauser) Resume() {
+	p.paused.Store(false)
+}
+
+// Paused returns whether the statistics shouldn't be read
+func (p *Pauser) Paused() bool {
+	return p.paused.Load()
+}
+
 func SortStats[T any](stats []*T, sortBy []string, colMap *columns.ColumnMap[T]) {
 	columnssort.SortEntries(*colMap, stats, sortBy)
 }
@@ -54,3 +78,22 @@ func ComputeIterations(interval, timeout time.Duration) (int, error) {
 	}
 	return int(timeout / interval), nil
 }
+
+// UsePerCPUHash turns the hash map name of spec into a per-CPU one. Each CPU
+// then updates its own copy of the entries without contending with the
+// others, and the copies are merged when the map is read. It has to be called
+// before loading the spec.
+func UsePerCPUHash(spec *ebpf.CollectionSpec, name string) error {
+	m, ok := spec.Maps[name]
+	if !ok {
+		return fmt.Errorf("map %q not found", name)
+	}
+	switch m.Type {
+	case ebpf.Hash:
+		m.Type = ebpf.PerCPUHash
+	case ebpf.PerCPUHash:
+	default:
+		return fmt.Errorf("map %q is a %s, not a hash map", name, m.Type)
+	}
+	return nil
+}
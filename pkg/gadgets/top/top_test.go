@@ -0,0 +1,55 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package top
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauser(t *testing.T) {
+	var p Pauser
+	assert.False(t, p.Paused())
+
+	p.Pause()
+	assert.True(t, p.Paused())
+
+	// Pausing twice doesn't need two resumes
+	p.Pause()
+	p.Resume()
+	assert.False(t, p.Paused())
+}
+
+func TestUsePerCPUHash(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"counts": {Type: ebpf.Hash},
+			"events": {Type: ebpf.PerfEventArray},
+		},
+	}
+
+	require.NoError(t, UsePerCPUHash(spec, "counts"))
+	assert.Equal(t, ebpf.PerCPUHash, spec.Maps["counts"].Type)
+
+	// Calling it twice is fine
+	require.NoError(t, UsePerCPUHash(spec, "counts"))
+	assert.Equal(t, ebpf.PerCPUHash, spec.Maps["counts"].Type)
+
+	assert.Error(t, UsePerCPUHash(spec, "events"))
+	assert.Error(t, UsePerCPUHash(spec, "missing"))
+}
@@ -47,7 +47,7 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return params.ParamDescs{
+	p := params.ParamDescs{
 		{
 			Key:          ParamPID,
 			Title:        "PID",
@@ -71,6 +71,8 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			TypeHint:     params.TypeBool,
 		},
 	}
+	p.Add(gadgets.UserFilterParams()...)
+	return p
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 	"github.com/vishvananda/netlink"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
@@ -36,10 +35,12 @@ import (
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -cc clang -cflags ${CFLAGS} -type bind_event bindsnoop ./bpf/bindsnoop.bpf.c -- -I./bpf/
 
 type Config struct {
-	MountnsMap   *ebpf.Map
-	TargetPid    int32
-	TargetPorts  []uint16
-	IgnoreErrors bool
+	MountnsMap      *ebpf.Map
+	TargetPid       int32
+	TargetPorts     []uint16
+	IgnoreErrors    bool
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -52,7 +53,7 @@ type Tracer struct {
 	ipv4Exit  link.Link
 	ipv6Entry link.Link
 	ipv6Exit  link.Link
-	reader    *perf.Reader
+	reader    *gadgets.BufferReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -139,9 +140,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching ipv6 kprobe: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.bindsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = gadgets.NewBufferReader(t.objs.bindsnoopMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.objs.bindsnoopMaps.Events); err != nil {
@@ -215,10 +216,11 @@ func protocolToString(protocol uint16) string {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -236,6 +238,10 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*bindsnoopBindEvent)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		interfaceString := ""
 		interfaceNum := int(bpfEvent.BoundDevIf)
 		if interfaceNum != 0 {
@@ -287,6 +293,8 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.TargetPid = params.Get(ParamPID).AsInt32()
 	t.config.TargetPorts = params.Get(ParamPorts).AsUint16Slice()
 	t.config.IgnoreErrors = params.Get(ParamIgnoreErrors).AsBool()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
+	t.config.UserFilter = gadgets.UserFilterFromParams(params)
 
 	defer t.close()
 	if err := t.install(); err != nil {
@@ -0,0 +1,79 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/capabilities/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestSumCounts(t *testing.T) {
+	t.Parallel()
+
+	uid := uint32(1000)
+	counts := map[capabilitiesCountKey]uint64{
+		{MntnsId: 2, Uid: 0, Gid: 0, Cap: 21, Ret: 0}:        3,
+		{MntnsId: 2, Uid: 1000, Gid: 1000, Cap: 21, Ret: 0}:  4,
+		{MntnsId: 2, Uid: 1000, Gid: 1000, Cap: 21, Ret: -1}: 5,
+		{MntnsId: 1, Uid: 1000, Gid: 1000, Cap: 0, Ret: 0}:   1,
+		{MntnsId: 1, Uid: 1000, Gid: 1000, Cap: 99, Ret: 0}:  2,
+	}
+
+	event := func(mntnsID uint64, capability int, capName, verdict string, count uint64) *types.Event {
+		return &types.Event{
+			Event:         eventtypes.Event{Type: eventtypes.NORMAL},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: mntnsID},
+			Cap:           capability,
+			CapName:       capName,
+			Verdict:       verdict,
+			Count:         count,
+		}
+	}
+
+	for name, test := range map[string]struct {
+		filter   gadgets.UserFilter
+		expected []*types.Event
+	}{
+		"all": {
+			expected: []*types.Event{
+				event(1, 0, "CHOWN", "Allow", 1),
+				event(1, 99, "UNKNOWN (99)", "Allow", 2),
+				event(2, 21, "SYS_ADMIN", "Allow", 7),
+				event(2, 21, "SYS_ADMIN", "Deny", 5),
+			},
+		},
+		"uid": {
+			filter: gadgets.UserFilter{UID: &uid},
+			expected: []*types.Event{
+				event(1, 0, "CHOWN", "Allow", 1),
+				event(1, 99, "UNKNOWN (99)", "Allow", 2),
+				event(2, 21, "SYS_ADMIN", "Allow", 4),
+				event(2, 21, "SYS_ADMIN", "Deny", 5),
+			},
+		},
+	} {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, sumCounts(counts, test.filter))
+		})
+	}
+}
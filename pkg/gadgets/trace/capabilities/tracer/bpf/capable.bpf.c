@@ -24,6 +24,7 @@ const volatile pid_t targ_pid = -1;
 const volatile u32 linux_version_code = 0;
 const volatile bool audit_only = false;
 const volatile bool unique = false;
+const volatile bool aggregate = false;
 
 extern int LINUX_KERNEL_VERSION __kconfig;
 
@@ -63,6 +64,23 @@ struct {
 	__type(value, u64);
 } seen SEC(".maps");
 
+// In aggregate mode, the checks aren't sent to user space but counted in the
+// counts map, that user space reads and clears periodically.
+struct count_key {
+	u64 mntns_id;
+	u32 uid;
+	u32 gid;
+	int cap;
+	int ret;
+};
+
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, struct count_key);
+	__type(value, u64);
+} counts SEC(".maps");
+
 struct syscall_context {
 	// Syscall id
 	// -1 for unknown syscall
@@ -190,6 +208,29 @@ int BPF_KRETPROBE(ig_trace_cap_x)
 	if (!ap)
 		return 0; /* missed entry */
 
+	if (aggregate) {
+		struct count_key key = {};
+		u64 *count;
+
+		key.mntns_id = gadget_get_mntns_id();
+		key.uid = (u32)uid_gid;
+		key.gid = (u32)(uid_gid >> 32);
+		key.cap = ap->cap;
+		key.ret = PT_REGS_RC(ctx);
+
+		count = bpf_map_lookup_elem(&counts, &key);
+		if (!count) {
+			u64 zero = 0;
+
+			bpf_map_update_elem(&counts, &key, &zero, BPF_NOEXIST);
+			count = bpf_map_lookup_elem(&counts, &key);
+			if (!count)
+				goto cleanup;
+		}
+		__sync_fetch_and_add(count, 1);
+		goto cleanup;
+	}
+
 	struct cap_event event = {};
 	event.current_userns = ap->current_userns;
 	event.target_userns = ap->target_userns;
@@ -223,6 +264,7 @@ int BPF_KRETPROBE(ig_trace_cap_x)
 	bpf_perf_event_output(ctx, &events, BPF_F_CURRENT_CPU, &event,
 			      sizeof(event));
 
+cleanup:
 	bpf_map_delete_elem(&start, &pid_tgid);
 
 	return 0;
@@ -38,6 +38,14 @@ type capabilitiesCapEvent struct {
 	Task          [16]uint8
 }
 
+type capabilitiesCountKey struct {
+	MntnsId uint64
+	Uid     uint32
+	Gid     uint32
+	Cap     int32
+	Ret     int32
+}
+
 type capabilitiesUniqueKey struct {
 	Cap     int32
 	_       [4]byte
@@ -97,6 +105,7 @@ type capabilitiesProgramSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type capabilitiesMapSpecs struct {
+	Counts               *ebpf.MapSpec `ebpf:"counts"`
 	CurrentSyscall       *ebpf.MapSpec `ebpf:"current_syscall"`
 	Events               *ebpf.MapSpec `ebpf:"events"`
 	GadgetMntnsFilterMap *ebpf.MapSpec `ebpf:"gadget_mntns_filter_map"`
@@ -123,6 +132,7 @@ func (o *capabilitiesObjects) Close() error {
 //
 // It can be passed to loadCapabilitiesObjects or ebpf.CollectionSpec.LoadAndAssign.
 type capabilitiesMaps struct {
+	Counts               *ebpf.Map `ebpf:"counts"`
 	CurrentSyscall       *ebpf.Map `ebpf:"current_syscall"`
 	Events               *ebpf.Map `ebpf:"events"`
 	GadgetMntnsFilterMap *ebpf.Map `ebpf:"gadget_mntns_filter_map"`
@@ -132,6 +142,7 @@ type capabilitiesMaps struct {
 
 func (m *capabilitiesMaps) Close() error {
 	return _CapabilitiesClose(
+		m.Counts,
 		m.CurrentSyscall,
 		m.Events,
 		m.GadgetMntnsFilterMap,
@@ -25,6 +25,8 @@ import (
 const (
 	ParamAuditOnly = "audit-only"
 	ParamUnique    = "unique"
+	ParamAggregate = "aggregate"
+	ParamInterval  = "interval"
 )
 
 type GadgetDesc struct{}
@@ -46,7 +48,7 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return params.ParamDescs{
+	p := params.ParamDescs{
 		{
 			Key:          ParamAuditOnly,
 			Title:        "Audit Only",
@@ -61,7 +63,23 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			Description:  "Only show a capability once on the same container",
 			TypeHint:     params.TypeBool,
 		},
+		{
+			Key:          ParamAggregate,
+			Title:        "Aggregate",
+			DefaultValue: "false",
+			Description:  "Count the checks per container, capability and verdict in the kernel and show the counters every interval instead of each check",
+			TypeHint:     params.TypeBool,
+		},
+		{
+			Key:          ParamInterval,
+			Title:        "Interval",
+			DefaultValue: "10",
+			Description:  "Interval between two flushes of the counters with --aggregate, in seconds",
+			TypeHint:     params.TypeUint32,
+		},
 	}
+	p.Add(gadgets.UserFilterParams()...)
+	return p
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
@@ -17,14 +17,16 @@
 package tracer
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"time"
 	"unsafe"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 	"github.com/syndtr/gocapability/capability"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
@@ -37,9 +39,13 @@ import (
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target ${TARGET} -cc clang -cflags ${CFLAGS} -type cap_event capabilities ./bpf/capable.bpf.c -- -I./bpf/
 
 type Config struct {
-	MountnsMap *ebpf.Map
-	AuditOnly  bool
-	Unique     bool
+	MountnsMap      *ebpf.Map
+	AuditOnly       bool
+	Unique          bool
+	Aggregate       bool
+	Interval        time.Duration
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -51,7 +57,7 @@ type Tracer struct {
 	tpSysExit     link.Link
 	tpSchedExec   link.Link
 	tpSchedExit   link.Link
-	reader        *perf.Reader
+	reader        *gadgets.BufferReader
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
 }
@@ -149,6 +155,7 @@ func (t *Tracer) install() error {
 	consts := map[string]interface{}{
 		"audit_only": t.config.AuditOnly,
 		"unique":     t.config.Unique,
+		"aggregate":  t.config.Aggregate,
 	}
 
 	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &t.objs); err != nil {
@@ -197,9 +204,9 @@ func (t *Tracer) install() error {
 	}
 	t.capExitLink = kretprobe
 
-	reader, err := perf.NewReader(t.objs.capabilitiesMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.capabilitiesMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -225,11 +232,29 @@ func boolPointer(b bool) *bool {
 	return &b
 }
 
+func capabilityName(capability int32) string {
+	name, ok := capabilitiesNames[capability]
+	if !ok {
+		// If this is printed it may mean a new capability was added to the kernel
+		// and capabilitiesNames map needs to be updated.
+		name = fmt.Sprintf("UNKNOWN (%d)", capability)
+	}
+	return name
+}
+
+func verdict(ret int32) string {
+	if ret == 0 {
+		return "Allow"
+	}
+	return "Deny"
+}
+
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -252,17 +277,8 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*capabilitiesCapEvent)(unsafe.Pointer(&record.RawSample[0]))
 
-		capability := bpfEvent.Cap
-		capabilityName, ok := capabilitiesNames[capability]
-		if !ok {
-			// If this is printed it may mean a new capability was added to the kernel
-			// and capabilitiesNames map needs to be updated.
-			capabilityName = fmt.Sprintf("UNKNOWN (%d)", capability)
-		}
-
-		verdict := "Deny"
-		if bpfEvent.Ret == 0 {
-			verdict = "Allow"
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
 		}
 
 		syscall, ok := syscalls.GetSyscallNameByNumber(int(bpfEvent.Syscall))
@@ -289,36 +305,159 @@ func (t *Tracer) run() {
 			Cap:           int(bpfEvent.Cap),
 			Uid:           bpfEvent.Uid,
 			Gid:           bpfEvent.Gid,
+			ContainerUid:  bpfEvent.Uid,
+			ContainerGid:  bpfEvent.Gid,
 			Audit:         int(bpfEvent.Audit),
 			InsetID:       insetID,
 			Comm:          gadgets.FromCString(bpfEvent.Task[:]),
 			Syscall:       syscall,
-			CapName:       capabilityName,
-			Verdict:       verdict,
+			CapName:       capabilityName(bpfEvent.Cap),
+			Verdict:       verdict(bpfEvent.Ret),
 			Caps:          bpfEvent.CapEffective,
 			CapsNames:     capsNames(bpfEvent.CapEffective),
 		}
 
 		if t.enricher != nil {
 			t.enricher.EnrichByMntNs(&event.CommonData, event.MountNsID)
+			if resolver, ok := t.enricher.(eventtypes.UserNsResolver); ok {
+				event.EnrichUserNs(resolver)
+			}
 		}
 
 		t.eventCallback(&event)
 	}
 }
 
+// sumCounts sums the counters of the capability checks matching the user
+// filter per container, capability and verdict. The events are sorted to
+// always be sent in the same order.
+func sumCounts(counts map[capabilitiesCountKey]uint64, filter gadgets.UserFilter) []*types.Event {
+	type sumKey struct {
+		mntnsID uint64
+		cap     int32
+		verdict string
+	}
+
+	sums := map[sumKey]uint64{}
+	for key, count := range counts {
+		if !filter.Matches(key.Uid, key.Gid) {
+			continue
+		}
+		sums[sumKey{mntnsID: key.MntnsId, cap: key.Cap, verdict: verdict(key.Ret)}] += count
+	}
+
+	events := make([]*types.Event, 0, len(sums))
+	for key, count := range sums {
+		events = append(events, &types.Event{
+			Event: eventtypes.Event{
+				Type: eventtypes.NORMAL,
+			},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: key.mntnsID},
+			Cap:           int(key.cap),
+			CapName:       capabilityName(key.cap),
+			Verdict:       key.verdict,
+			Count:         count,
+		})
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.MountNsID != b.MountNsID {
+			return a.MountNsID < b.MountNsID
+		}
+		if a.Cap != b.Cap {
+			return a.Cap < b.Cap
+		}
+		return a.Verdict < b.Verdict
+	})
+
+	return events
+}
+
+// flushCounts sends the number of capability checks counted in aggregate
+// mode since the last flush and resets the counters.
+func (t *Tracer) flushCounts() {
+	counts := map[capabilitiesCountKey]uint64{}
+
+	var key capabilitiesCountKey
+	var count uint64
+	iter := t.objs.Counts.Iterate()
+	for iter.Next(&key, &count) {
+		counts[key] = count
+	}
+	if err := iter.Err(); err != nil {
+		msg := fmt.Sprintf("Error reading counters: %s", err)
+		t.eventCallback(types.Base(eventtypes.Err(msg)))
+		return
+	}
+
+	for key := range counts {
+		if err := t.objs.Counts.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			msg := fmt.Sprintf("Error deleting counter: %s", err)
+			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+		}
+	}
+
+	now := eventtypes.Time(time.Now().UnixNano())
+	for _, event := range sumCounts(counts, t.config.UserFilter) {
+		event.Timestamp = now
+		if t.enricher != nil {
+			t.enricher.EnrichByMntNs(&event.CommonData, event.MountNsID)
+		}
+		t.eventCallback(event)
+	}
+}
+
+// runAggregate flushes the counters every interval and a last time when the
+// gadget stops.
+func (t *Tracer) runAggregate(ctx context.Context) {
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.flushCounts()
+			return
+		case <-ticker.C:
+			t.flushCounts()
+		}
+	}
+}
+
 // --- Registry changes
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	params := gadgetCtx.GadgetParams()
 	t.config.Unique = params.Get(ParamUnique).AsBool()
 	t.config.AuditOnly = params.Get(ParamAuditOnly).AsBool()
+	t.config.Aggregate = params.Get(ParamAggregate).AsBool()
+	t.config.Interval = time.Second * time.Duration(params.Get(ParamInterval).AsUint32())
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
+	t.config.UserFilter = gadgets.UserFilterFromParams(params)
+
+	if t.config.Aggregate {
+		if t.config.Unique {
+			return fmt.Errorf("--%s can't be used with --%s", ParamUnique, ParamAggregate)
+		}
+		if t.config.Interval == 0 {
+			return fmt.Errorf("interval must be greater than 0")
+		}
+	}
 
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
 	}
 
+	if t.config.Aggregate {
+		ctx, cancel := gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
+		defer cancel()
+
+		t.runAggregate(ctx)
+		return nil
+	}
+
 	go t.run()
 	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
 
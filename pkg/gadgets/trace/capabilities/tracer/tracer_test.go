@@ -93,6 +93,7 @@ func TestCapabilitiesTracer(t *testing.T) {
 					WithMountNsID: eventtypes.WithMountNsID{MountNsID: info.MountNsID},
 					Pid:           uint32(info.Pid),
 					Uid:           uint32(info.Uid),
+					ContainerUid:  uint32(info.Uid),
 					Comm:          info.Comm,
 					Syscall:       "fchownat",
 					CapName:       "CHOWN",
@@ -132,6 +133,7 @@ func TestCapabilitiesTracer(t *testing.T) {
 					WithMountNsID: eventtypes.WithMountNsID{MountNsID: info.MountNsID},
 					Pid:           uint32(info.Pid),
 					Uid:           uint32(info.Uid),
+					ContainerUid:  uint32(info.Uid),
 					Comm:          info.Comm,
 					Syscall:       "fchownat",
 					CapName:       "CHOWN",
@@ -162,6 +164,7 @@ func TestCapabilitiesTracer(t *testing.T) {
 					WithMountNsID: eventtypes.WithMountNsID{MountNsID: info.MountNsID},
 					Pid:           uint32(info.Pid),
 					Uid:           uint32(info.Uid),
+					ContainerUid:  uint32(info.Uid),
 					Comm:          info.Comm,
 					Syscall:       "bind",
 					CapName:       "NET_BIND_SERVICE",
@@ -198,6 +201,7 @@ func TestCapabilitiesTracer(t *testing.T) {
 					WithMountNsID: eventtypes.WithMountNsID{MountNsID: info.MountNsID},
 					Pid:           uint32(info.Pid),
 					Uid:           uint32(info.Uid),
+					ContainerUid:  uint32(info.Uid),
 					Comm:          info.Comm,
 					Syscall:       "fchownat",
 					CapName:       "CHOWN",
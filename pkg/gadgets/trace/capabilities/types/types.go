@@ -36,20 +36,34 @@ type Event struct {
 	eventtypes.Event
 	eventtypes.WithMountNsID
 
-	Pid           uint32   `json:"pid,omitempty" column:"pid,template:pid"`
-	Comm          string   `json:"comm,omitempty" column:"comm,template:comm"`
-	Syscall       string   `json:"syscall,omitempty" column:"syscall,template:syscall"`
-	Uid           uint32   `json:"uid" column:"uid,template:uid,hide"`
-	Gid           uint32   `json:"gid" column:"gid,template:gid,hide"`
-	Cap           int      `json:"cap,omitempty" column:"cap,width:3,fixed"`
-	CapName       string   `json:"capName,omitempty" column:"capName,width:18,fixed"`
-	Audit         int      `json:"audit,omitempty" column:"audit,minWidth:5"`
-	Verdict       string   `json:"verdict,omitempty" column:"verdict,width:7,fixed"`
-	InsetID       *bool    `json:"insetid,omitempty" column:"insetid,width:7,fixed,hide"`
-	TargetUserNs  uint64   `json:"targetuserns,omitempty" column:"targetuserns,template:ns"`
-	CurrentUserNs uint64   `json:"currentuserns,omitempty" column:"currentuserns,template:ns"`
-	Caps          uint64   `json:"caps,omitempty" column:"caps,hide"`
-	CapsNames     []string `json:"capsNames,omitempty" column:"capsnames,hide"`
+	Pid                uint32   `json:"pid,omitempty" column:"pid,template:pid"`
+	Comm               string   `json:"comm,omitempty" column:"comm,template:comm"`
+	Syscall            string   `json:"syscall,omitempty" column:"syscall,template:syscall"`
+	Uid                uint32   `json:"uid" column:"uid,template:uid,hide"`
+	Gid                uint32   `json:"gid" column:"gid,template:gid,hide"`
+	ContainerUid       uint32   `json:"containerUid" column:"containerUid,template:uid,hide"`
+	ContainerGid       uint32   `json:"containerGid" column:"containerGid,template:gid,hide"`
+	Cap                int      `json:"cap,omitempty" column:"cap,width:3,fixed"`
+	CapName            string   `json:"capName,omitempty" column:"capName,width:18,fixed"`
+	Audit              int      `json:"audit,omitempty" column:"audit,minWidth:5"`
+	Verdict            string   `json:"verdict,omitempty" column:"verdict,width:7,fixed"`
+	InsetID            *bool    `json:"insetid,omitempty" column:"insetid,width:7,fixed,hide"`
+	TargetUserNs       uint64   `json:"targetuserns,omitempty" column:"targetuserns,template:ns"`
+	CurrentUserNs      uint64   `json:"currentuserns,omitempty" column:"currentuserns,template:ns"`
+	TargetUserNsOwner  string   `json:"targetUserNsOwner,omitempty" column:"targetUserNsOwner,minWidth:10,hide"`
+	CurrentUserNsOwner string   `json:"currentUserNsOwner,omitempty" column:"currentUserNsOwner,minWidth:10,hide"`
+	Caps               uint64   `json:"caps,omitempty" column:"caps,hide"`
+	CapsNames          []string `json:"capsNames,omitempty" column:"capsnames,hide"`
+	Count              uint64   `json:"count,omitempty" column:"count,hide"`
+}
+
+// EnrichUserNs sets the IDs of the process in the user namespace of its
+// container and the containers owning the user namespaces. Uid and Gid are
+// the IDs in the user namespace of the host.
+func (e *Event) EnrichUserNs(resolver eventtypes.UserNsResolver) {
+	e.ContainerUid, e.ContainerGid = resolver.ContainerIDs(e.MountNsID, e.Uid, e.Gid)
+	e.TargetUserNsOwner = resolver.UserNsOwner(e.TargetUserNs)
+	e.CurrentUserNsOwner = resolver.UserNsOwner(e.CurrentUserNs)
 }
 
 func GetColumns() *columns.Columns[Event] {
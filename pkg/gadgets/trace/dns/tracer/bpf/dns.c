@@ -118,6 +118,87 @@ struct dnshdr {
 	__u16 arcount; // number of additional records
 };
 
+// https://datatracker.ietf.org/doc/html/rfc1035#section-2.3.4
+#define MAX_DNS_NAME 255
+#define MAX_DNS_LABEL 63
+// Each label takes at least 2 bytes: its length and one character
+#define MAX_DNS_LABELS 128
+
+// Name to filter the queries and responses on, in the format used in the
+// packets: "\x08internal\x04corp\x00" for "internal.corp". Lower case only.
+// The names aren't filtered when dns_name_len is 0.
+const volatile __u8 dns_name[MAX_DNS_NAME + 1] = {};
+const volatile __u16 dns_name_len = 0;
+// Only match the subdomains of dns_name, like "*.internal.corp"
+const volatile bool dns_name_wildcard = false;
+
+static __always_inline __u8 to_lower(__u8 c)
+{
+	if (c >= 'A' && c <= 'Z')
+		return c + ('a' - 'A');
+	return c;
+}
+
+// matches_dns_name checks whether the name of the first question of the
+// packet matches dns_name. The match is done on the labels, so that
+// "*.internal.corp" matches "a.internal.corp" but not "a.myinternal.corp".
+static __always_inline bool matches_dns_name(struct __sk_buff *skb,
+					     __u16 dns_off)
+{
+	__u16 qname_off = dns_off + sizeof(struct dnshdr);
+	__u16 off = qname_off;
+	__u16 name_off;
+	__u8 len = 0;
+	int i;
+
+	if (dns_name_len == 0)
+		return true;
+
+	if (load_half(skb, dns_off + offsetof(struct dnshdr, qdcount)) == 0)
+		return false;
+
+	// Find the end of the name
+	for (i = 0; i < MAX_DNS_LABELS; i++) {
+		len = load_byte(skb, off);
+		if (len == 0)
+			break;
+		// Compressed names are not expected in the question
+		if (len > MAX_DNS_LABEL)
+			return false;
+		off += len + 1;
+	}
+	if (len != 0)
+		return false;
+	off += 1;
+
+	if (off - qname_off < dns_name_len)
+		return false;
+	name_off = off - dns_name_len;
+
+	// dns_name itself is only matched without wildcard
+	if ((name_off == qname_off) == dns_name_wildcard)
+		return false;
+
+	// The match has to start on a label
+	off = qname_off;
+	for (i = 0; i < MAX_DNS_LABELS; i++) {
+		if (off >= name_off)
+			break;
+		off += load_byte(skb, off) + 1;
+	}
+	if (off != name_off)
+		return false;
+
+	for (i = 0; i < sizeof(dns_name); i++) {
+		if (i >= dns_name_len)
+			break;
+		if (to_lower(load_byte(skb, name_off + i)) != dns_name[i])
+			return false;
+	}
+
+	return true;
+}
+
 // Map of DNS query to timestamp so we can calculate latency from query sent to answer received.
 struct query_key_t {
 	__u64 pid_tgid;
@@ -220,6 +301,9 @@ int ig_trace_dns(struct __sk_buff *skb)
 	if (!is_dns_port(sport) && !is_dns_port(dport))
 		return 0;
 
+	if (!matches_dns_name(skb, dns_off))
+		return 0;
+
 	// Initialize event here only after we know we're interested in this packet to avoid
 	// spending useless cycles.
 	bpf_map_update_elem(&tmp_events, &zero, &empty_event, BPF_NOEXIST);
@@ -0,0 +1,63 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Keep in sync with values in bpf/dns.c
+const (
+	maxDNSName  = 255
+	maxDNSLabel = 63
+)
+
+// encodeDNSName returns the name the eBPF program filters the DNS packets on,
+// in the format used in the packets, and whether only its subdomains are
+// matched. A name prefixed with "*." matches its subdomains. An empty name
+// means the packets aren't filtered.
+func encodeDNSName(name string) ([]byte, bool, error) {
+	if name == "" {
+		return nil, false, nil
+	}
+
+	domain, wildcard := strings.CutPrefix(name, "*.")
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if domain == "" {
+		return nil, false, fmt.Errorf("invalid DNS name %q: missing domain", name)
+	}
+
+	var encoded []byte
+	for _, label := range strings.Split(domain, ".") {
+		switch {
+		case label == "":
+			return nil, false, fmt.Errorf("invalid DNS name %q: empty label", name)
+		case strings.Contains(label, "*"):
+			return nil, false, fmt.Errorf("invalid DNS name %q: '*' is only supported as first label", name)
+		case len(label) > maxDNSLabel:
+			return nil, false, fmt.Errorf("invalid DNS name %q: label %q longer than %d characters", name, label, maxDNSLabel)
+		}
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+	encoded = append(encoded, 0)
+
+	if len(encoded) > maxDNSName {
+		return nil, false, fmt.Errorf("invalid DNS name %q: longer than %d characters", name, maxDNSName)
+	}
+
+	return encoded, wildcard, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDNSName(t *testing.T) {
+	t.Parallel()
+
+	type testDefinition struct {
+		name             string
+		expectedEncoded  []byte
+		expectedWildcard bool
+		expectedErr      bool
+	}
+
+	tests := map[string]testDefinition{
+		"empty": {
+			name: "",
+		},
+		"exact": {
+			name:            "internal.corp",
+			expectedEncoded: []byte("\x08internal\x04corp\x00"),
+		},
+		"fully_qualified": {
+			name:            "Internal.Corp.",
+			expectedEncoded: []byte("\x08internal\x04corp\x00"),
+		},
+		"wildcard": {
+			name:             "*.internal.corp",
+			expectedEncoded:  []byte("\x08internal\x04corp\x00"),
+			expectedWildcard: true,
+		},
+		"wildcard_only": {
+			name:        "*.",
+			expectedErr: true,
+		},
+		"wildcard_not_first": {
+			name:        "a.*.corp",
+			expectedErr: true,
+		},
+		"partial_wildcard": {
+			name:        "*internal.corp",
+			expectedErr: true,
+		},
+		"empty_label": {
+			name:        "internal..corp",
+			expectedErr: true,
+		},
+		"label_too_long": {
+			name:        strings.Repeat("a", 64) + ".corp",
+			expectedErr: true,
+		},
+		"name_too_long": {
+			name:        strings.Repeat(strings.Repeat("a", 63)+".", 4) + "corp",
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			encoded, wildcard, err := encodeDNSName(test.name)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedEncoded, encoded)
+			require.Equal(t, test.expectedWildcard, wildcard)
+		})
+	}
+}
@@ -29,6 +29,7 @@ const (
 	ParamDNSTimeout = "dns-timeout"
 	ParamPorts      = "ports"
 	ParamPaths      = "paths"
+	ParamDNSName    = "dns-name"
 )
 
 type GadgetDesc struct{}
@@ -84,6 +85,15 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			Description:  "Ports to trace DNS requests on",
 			Validator:    params.ValidateSlice(params.ValidateUintRange(1, 65535)),
 		},
+		{
+			Key:         ParamDNSName,
+			Title:       "DNS name",
+			Description: "Only trace the DNS requests for this name. Prefix it with '*.' to trace the ones for its subdomains, e.g. '*.internal.corp'",
+			Validator: func(value string) error {
+				_, _, err := encodeDNSName(value)
+				return err
+			},
+		},
 	}
 }
 
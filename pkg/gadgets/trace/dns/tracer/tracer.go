@@ -48,6 +48,7 @@ type Config struct {
 	DnsTimeout time.Duration
 	Ports      []uint16
 	GetPaths   bool
+	DNSName    string
 }
 
 type Tracer struct {
@@ -120,6 +121,7 @@ func (t *Tracer) Init(gadgetCtx gadgets.GadgetContext) error {
 		t.Close()
 		return fmt.Errorf("installing tracer: %w", err)
 	}
+	t.Tracer.SetPerfBufferPages(gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams()))
 
 	t.ctx, t.cancel = gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
 
@@ -238,9 +240,19 @@ func (t *Tracer) run(ctx context.Context, logger logger.Logger) error {
 	portsArray := [maxPorts]uint16{0}
 	copy(portsArray[:], t.config.Ports)
 
+	dnsName, wildcard, err := encodeDNSName(t.config.DNSName)
+	if err != nil {
+		return err
+	}
+	dnsNameArray := [maxDNSName + 1]uint8{0}
+	copy(dnsNameArray[:], dnsName)
+
 	constants := map[string]any{
-		"ports":     portsArray,
-		"ports_len": uint16(len(t.config.Ports)),
+		"ports":             portsArray,
+		"ports_len":         uint16(len(t.config.Ports)),
+		"dns_name":          dnsNameArray,
+		"dns_name_len":      uint16(len(dnsName)),
+		"dns_name_wildcard": wildcard,
 	}
 	//nolint:staticcheck
 	if err := spec.RewriteConstants(constants); err != nil {
@@ -268,6 +280,7 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.DnsTimeout = gadgetCtx.GadgetParams().Get(ParamDNSTimeout).AsDuration()
 	t.config.Ports = gadgetCtx.GadgetParams().Get(ParamPorts).AsUint16Slice()
 	t.config.GetPaths = gadgetCtx.GadgetParams().Get(ParamPaths).AsBool()
+	t.config.DNSName = gadgetCtx.GadgetParams().Get(ParamDNSName).AsString()
 
 	if err := t.run(t.ctx, gadgetCtx.Logger()); err != nil {
 		return err
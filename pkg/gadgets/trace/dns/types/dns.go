@@ -103,3 +103,41 @@ func Base(ev eventtypes.Event) *Event {
 		Event: ev,
 	}
 }
+
+// OTelSpan returns a span for DNS responses, starting when the query was
+// seen. Queries aren't exported on their own.
+func (e *Event) OTelSpan() *eventtypes.Span {
+	if e.Qr != DNSPktTypeResponse {
+		return nil
+	}
+
+	// Responses sent by the container are answered by a DNS server running in
+	// it
+	kind := eventtypes.SpanKindClient
+	if e.PktType == "OUTGOING" {
+		kind = eventtypes.SpanKindServer
+	}
+
+	end := time.Unix(0, int64(e.Timestamp))
+	span := &eventtypes.Span{
+		Name:  "DNS " + e.QType,
+		Kind:  kind,
+		Start: end.Add(-e.Latency),
+		End:   end,
+		Attributes: map[string]any{
+			"dns.question.name": e.DNSName,
+			"dns.question.type": e.QType,
+			"dns.response_code": e.Rcode,
+			"dns.answers":       e.Addresses,
+			"server.address":    e.Nameserver,
+			"network.transport": strings.ToLower(e.Protocol),
+			"process.pid":       e.Pid,
+			"process.command":   e.Comm,
+		},
+	}
+	if e.Rcode != "" && e.Rcode != "No Error" {
+		span.Error = e.Rcode
+	}
+	e.AddSpanAttributes(span.Attributes)
+	return span
+}
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -78,9 +77,12 @@ type execsnoopWithLongPathsEventAbbrev struct {
 }
 
 type Config struct {
-	MountnsMap   *ebpf.Map
-	GetPaths     bool
-	IgnoreErrors bool
+	MountnsMap      *ebpf.Map
+	GetPaths        bool
+	IgnoreErrors    bool
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
+	Sampling        gadgets.Sampling
 }
 
 type Tracer struct {
@@ -92,7 +94,7 @@ type Tracer struct {
 	enterLink     link.Link
 	schedExecLink link.Link
 	exitLink      link.Link
-	reader        *perf.Reader
+	reader        *gadgets.BufferReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -146,7 +148,10 @@ func (t *Tracer) install() error {
 	}
 
 	consts := map[string]interface{}{
-		"ignore_failed": t.config.IgnoreErrors,
+		"ignore_failed":                t.config.IgnoreErrors,
+		"targ_uid":                     t.config.UserFilter.TargetUID(),
+		gadgets.SampleRateName:         t.config.Sampling.Rate,
+		gadgets.MaxEventsPerSecondName: t.config.Sampling.MaxEventsPerSecond,
 	}
 
 	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &t.objs); err != nil {
@@ -168,9 +173,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching exit tracepoint: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.execsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.execsnoopMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -182,10 +187,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -205,6 +211,10 @@ func (t *Tracer) run() {
 		// structure. (Just before args that are handled in a different way below)
 		bpfEvent := (*execsnoopEventAbbrev)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		event := types.Event{
 			Event: eventtypes.Event{
 				Type:      eventtypes.NORMAL,
@@ -225,7 +235,7 @@ func (t *Tracer) run() {
 		}
 
 		argsCount := 0
-		buf := []byte{}
+		start := 0
 		args := record.RawSample[unsafe.Offsetof(execsnoopEvent{}.Args):]
 
 		if t.config.GetPaths {
@@ -236,13 +246,10 @@ func (t *Tracer) run() {
 		}
 
 		for i := 0; i < int(bpfEvent.ArgsSize) && argsCount < int(bpfEvent.ArgsCount); i++ {
-			c := args[i]
-			if c == 0 {
-				event.Args = append(event.Args, string(buf))
+			if args[i] == 0 {
+				event.Args = append(event.Args, string(args[start:i]))
 				argsCount = 0
-				buf = []byte{}
-			} else {
-				buf = append(buf, c)
+				start = i + 1
 			}
 		}
 
@@ -259,6 +266,9 @@ func (t *Tracer) run() {
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.GetPaths = gadgetCtx.GadgetParams().Get(ParamPaths).AsBool()
 	t.config.IgnoreErrors = gadgetCtx.GadgetParams().Get(ParamIgnoreErrors).AsBool()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+	t.config.UserFilter = gadgets.UserFilterFromParams(gadgetCtx.GadgetParams())
+	t.config.Sampling = gadgets.SamplingFromParams(gadgetCtx.GadgetParams())
 
 	defer t.close()
 	if err := t.install(); err != nil {
@@ -31,6 +31,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/tracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/types"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
@@ -164,6 +165,86 @@ func TestExecTracer(t *testing.T) {
 				require.Equal(t, uint32(info.Gid), events[0].Gid, "Event has bad GID")
 			},
 		},
+		"captures_events_with_matching_user_filter": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				uid := uint32(unprivilegedUID)
+				gid := uint32(unprivilegedGID)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{UID: &uid, GID: &gid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: generateEvent,
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []types.Event) {
+				require.Len(t, events, 1, "One event expected")
+				require.Equal(t, uint32(info.Uid), events[0].Uid, "Event has bad UID")
+				require.Equal(t, uint32(info.Gid), events[0].Gid, "Event has bad GID")
+			},
+		},
+		"captures_no_events_with_no_matching_uid_filter": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				uid := uint32(unprivilegedUID + 1)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{UID: &uid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: generateEvent,
+			validateEvent: utilstest.ExpectNoEvent[types.Event, int],
+		},
+		"captures_no_events_with_no_matching_gid_filter": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				gid := uint32(unprivilegedGID + 1)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{GID: &gid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: generateEvent,
+			validateEvent: utilstest.ExpectNoEvent[types.Event, int],
+		},
+		"captures_no_events_with_sample_rate": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					// Keeping the event is very unlikely
+					Sampling: gadgets.Sampling{Rate: 1 << 31},
+				}
+			},
+			generateEvent: generateEvent,
+			validateEvent: utilstest.ExpectNoEvent[types.Event, int],
+		},
+		"captures_events_up_to_max_events_per_second": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					Sampling:   gadgets.Sampling{MaxEventsPerSecond: 2},
+				}
+			},
+			generateEvent: func() (int, error) {
+				for i := 0; i < 5; i++ {
+					if _, err := generateEvent(); err != nil {
+						return 0, err
+					}
+				}
+				return 0, nil
+			},
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, _ int, events []types.Event) {
+				require.Len(t, events, 2, "Two events expected")
+			},
+		},
 		"truncates_captured_args_in_trace_to_maximum_possible_length": {
 			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
 				return &tracer.Config{
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -36,8 +35,9 @@ import (
 type Config struct {
 	MountnsMap *ebpf.Map
 
-	Filesystem string
-	MinLatency uint
+	Filesystem      string
+	MinLatency      uint
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -56,7 +56,7 @@ type Tracer struct {
 	syncExitLink    link.Link
 	statfsEnterLink link.Link
 	statfsExitLink  link.Link
-	reader          *perf.Reader
+	reader          *gadgets.BufferReader
 }
 
 type fsConf struct {
@@ -235,9 +235,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching kretprobe: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.fsslowerMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = gadgets.NewBufferReader(t.objs.fsslowerMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.objs.fsslowerMaps.Events); err != nil {
@@ -250,10 +250,11 @@ func (t *Tracer) install() error {
 var ops = []string{"R", "W", "O", "F", "S"}
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -300,6 +301,7 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	params := gadgetCtx.GadgetParams()
 	t.config.Filesystem = params.Get(ParamFilesystem).AsString()
 	t.config.MinLatency = params.Get(ParamMinLatency).AsUint()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
 
 	defer t.close()
 	if err := t.install(); err != nil {
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -35,7 +34,8 @@ import (
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -no-global-types -target bpfel -cc clang -cflags ${CFLAGS} -type event -type op mountsnoop ./bpf/mountsnoop.bpf.c -- -I./bpf/
 
 type Config struct {
-	MountnsMap *ebpf.Map
+	MountnsMap      *ebpf.Map
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -48,7 +48,7 @@ type Tracer struct {
 	umountEnterLink link.Link
 	mountExitLink   link.Link
 	umountExitLink  link.Link
-	reader          *perf.Reader
+	reader          *gadgets.BufferReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -120,9 +120,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching tracepoint: %w", err)
 	}
 
-	t.reader, err = perf.NewReader(t.objs.mountsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = gadgets.NewBufferReader(t.objs.mountsnoopMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.objs.mountsnoopMaps.Events); err != nil {
@@ -133,10 +133,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -193,6 +194,8 @@ func (t *Tracer) run() {
 // --- Registry changes
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
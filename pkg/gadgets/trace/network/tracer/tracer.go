@@ -129,6 +129,7 @@ func (t *Tracer) Init(gadgetCtx gadgets.GadgetContext) error {
 		t.Close()
 		return fmt.Errorf("installing tracer: %w", err)
 	}
+	t.Tracer.SetPerfBufferPages(gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams()))
 
 	t.ctx, t.cancel = gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
 	return nil
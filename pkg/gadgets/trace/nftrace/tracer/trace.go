@@ -0,0 +1,401 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/nftrace/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// Not defined in golang.org/x/sys/unix
+const (
+	nfDrop   = 0
+	nfAccept = 1
+	nfStolen = 2
+	nfQueue  = 3
+	nfRepeat = 4
+	nfStop   = 5
+
+	nfVerdictMask = 0xff
+
+	// NFTNL_UDATA_RULE_COMMENT, the comment of a rule in its user data, set
+	// by nft and iptables-nft
+	udataRuleComment = 0
+)
+
+// trace is an nftables trace event. The kernel sends one each time a traced
+// packet matches a rule, gets the policy of a base chain or returns from a
+// chain.
+type trace struct {
+	id         uint32
+	family     uint8
+	table      string
+	chain      string
+	ruleHandle uint64
+	traceType  uint32
+	verdict    int32
+	jumpTarget string
+
+	packet
+}
+
+// packet is what the kernel copies from the packet in the trace events
+type packet struct {
+	networkHeader   []byte
+	transportHeader []byte
+
+	iif  uint32
+	oif  uint32
+	mark uint32
+}
+
+func be32(b []byte) uint32 {
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func be64(b []byte) uint64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// parseTrace parses the payload of an NFT_MSG_TRACE message
+func parseTrace(data []byte) (*trace, error) {
+	if len(data) < nl.SizeofNfgenmsg {
+		return nil, errors.New("message too short")
+	}
+
+	tr := &trace{
+		family: data[0],
+	}
+
+	attrs, err := nl.ParseRouteAttr(data[nl.SizeofNfgenmsg:])
+	if err != nil {
+		return nil, fmt.Errorf("parsing attributes: %w", err)
+	}
+
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case unix.NFTA_TRACE_ID:
+			tr.id = be32(attr.Value)
+		case unix.NFTA_TRACE_TABLE:
+			tr.table = gadgets.FromCString(attr.Value)
+		case unix.NFTA_TRACE_CHAIN:
+			tr.chain = gadgets.FromCString(attr.Value)
+		case unix.NFTA_TRACE_RULE_HANDLE:
+			tr.ruleHandle = be64(attr.Value)
+		case unix.NFTA_TRACE_TYPE:
+			tr.traceType = be32(attr.Value)
+		case unix.NFTA_TRACE_VERDICT:
+			if err := tr.parseVerdict(attr.Value); err != nil {
+				return nil, err
+			}
+		case unix.NFTA_TRACE_POLICY:
+			tr.verdict = int32(be32(attr.Value))
+		case unix.NFTA_TRACE_NETWORK_HEADER:
+			tr.networkHeader = attr.Value
+		case unix.NFTA_TRACE_TRANSPORT_HEADER:
+			tr.transportHeader = attr.Value
+		case unix.NFTA_TRACE_IIF:
+			tr.iif = be32(attr.Value)
+		case unix.NFTA_TRACE_OIF:
+			tr.oif = be32(attr.Value)
+		case unix.NFTA_TRACE_MARK:
+			tr.mark = be32(attr.Value)
+		}
+	}
+
+	return tr, nil
+}
+
+func (tr *trace) parseVerdict(data []byte) error {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return fmt.Errorf("parsing verdict: %w", err)
+	}
+
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case unix.NFTA_VERDICT_CODE:
+			tr.verdict = int32(be32(attr.Value))
+		case unix.NFTA_VERDICT_CHAIN:
+			tr.jumpTarget = gadgets.FromCString(attr.Value)
+		}
+	}
+
+	return nil
+}
+
+// isDrop tells whether the packet was dropped. Reject rules drop the packet
+// after replying to it.
+func (tr *trace) isDrop() bool {
+	if tr.traceType != unix.NFT_TRACETYPE_RULE && tr.traceType != unix.NFT_TRACETYPE_POLICY {
+		return false
+	}
+	return tr.verdict >= 0 && tr.verdict&nfVerdictMask == nfDrop
+}
+
+func verdictString(verdict int32) string {
+	switch verdict {
+	case unix.NFT_CONTINUE:
+		return "continue"
+	case unix.NFT_BREAK:
+		return "break"
+	case unix.NFT_JUMP:
+		return "jump"
+	case unix.NFT_GOTO:
+		return "goto"
+	case unix.NFT_RETURN:
+		return "return"
+	}
+
+	// The upper bits hold the queue number or the error of the verdict
+	switch verdict & nfVerdictMask {
+	case nfDrop:
+		return "drop"
+	case nfAccept:
+		return "accept"
+	case nfStolen:
+		return "stolen"
+	case nfQueue:
+		return "queue"
+	case nfRepeat:
+		return "repeat"
+	case nfStop:
+		return "stop"
+	}
+
+	return "unknown"
+}
+
+func traceTypeString(traceType uint32) string {
+	switch traceType {
+	case unix.NFT_TRACETYPE_RULE:
+		return "rule"
+	case unix.NFT_TRACETYPE_POLICY:
+		return "policy"
+	case unix.NFT_TRACETYPE_RETURN:
+		return "return"
+	}
+	return "unknown"
+}
+
+func familyString(family uint8) string {
+	switch family {
+	case unix.NFPROTO_IPV4:
+		return "ip"
+	case unix.NFPROTO_IPV6:
+		return "ip6"
+	case unix.NFPROTO_INET:
+		return "inet"
+	case unix.NFPROTO_ARP:
+		return "arp"
+	case unix.NFPROTO_BRIDGE:
+		return "bridge"
+	case unix.NFPROTO_NETDEV:
+		return "netdev"
+	}
+	return "unknown"
+}
+
+// fillEndpoints reads the addresses and the ports of the packet from the
+// headers copied by the kernel. They're missing for the packets which aren't
+// IP, like ARP ones.
+func (tr *trace) fillEndpoints(event *types.Event) {
+	h := tr.networkHeader
+	if len(h) == 0 {
+		return
+	}
+
+	var proto uint8
+	var saddr, daddr net.IP
+
+	switch h[0] >> 4 {
+	case 4:
+		if len(h) < 20 {
+			return
+		}
+		proto = h[9]
+		saddr = net.IP(h[12:16])
+		daddr = net.IP(h[16:20])
+		event.IPVersion = 4
+	case 6:
+		if len(h) < 40 {
+			return
+		}
+		// Extension headers aren't followed
+		proto = h[6]
+		saddr = net.IP(h[8:24])
+		daddr = net.IP(h[24:40])
+		event.IPVersion = 6
+	default:
+		return
+	}
+
+	event.SrcEndpoint.L3Endpoint = eventtypes.L3Endpoint{
+		Addr:    saddr.String(),
+		Version: uint8(event.IPVersion),
+	}
+	event.DstEndpoint.L3Endpoint = eventtypes.L3Endpoint{
+		Addr:    daddr.String(),
+		Version: uint8(event.IPVersion),
+	}
+	event.SrcEndpoint.Proto = uint16(proto)
+	event.DstEndpoint.Proto = uint16(proto)
+
+	switch proto {
+	case unix.IPPROTO_TCP, unix.IPPROTO_UDP, unix.IPPROTO_SCTP:
+		if len(tr.transportHeader) >= 4 {
+			event.SrcEndpoint.Port = binary.BigEndian.Uint16(tr.transportHeader[0:2])
+			event.DstEndpoint.Port = binary.BigEndian.Uint16(tr.transportHeader[2:4])
+		}
+	}
+}
+
+// ruleKey identifies a rule in the ruleset of the network namespace
+type ruleKey struct {
+	family uint8
+	table  string
+	chain  string
+	handle uint64
+}
+
+// getRuleComment fetches a rule from the kernel to get its comment. The rules
+// of kube-proxy and of most network policy implementations tell what they're
+// for in their comments.
+func getRuleComment(key ruleKey) (string, error) {
+	req := nl.NewNetlinkRequest(unix.NFNL_SUBSYS_NFTABLES<<8|unix.NFT_MSG_GETRULE, 0)
+	req.AddData(&nl.Nfgenmsg{NfgenFamily: key.family, Version: unix.NFNETLINK_V0})
+	req.AddData(nl.NewRtAttr(unix.NFTA_RULE_TABLE, nl.ZeroTerminated(key.table)))
+	req.AddData(nl.NewRtAttr(unix.NFTA_RULE_CHAIN, nl.ZeroTerminated(key.chain)))
+	req.AddData(nl.NewRtAttr(unix.NFTA_RULE_HANDLE, nl.BEUint64Attr(key.handle)))
+
+	msgs, err := req.Execute(unix.NETLINK_NETFILTER, unix.NFNL_SUBSYS_NFTABLES<<8|unix.NFT_MSG_NEWRULE)
+	if err != nil {
+		return "", fmt.Errorf("getting rule %d of chain %s: %w", key.handle, key.chain, err)
+	}
+	if len(msgs) == 0 || len(msgs[0]) < nl.SizeofNfgenmsg {
+		return "", fmt.Errorf("rule %d of chain %s not found", key.handle, key.chain)
+	}
+
+	return parseRuleComment(msgs[0][nl.SizeofNfgenmsg:])
+}
+
+// parseRuleComment returns the comment of a rule from its attributes. nft and
+// recent versions of iptables-nft store it in the user data of the rule, and
+// older versions of iptables-nft in a comment match.
+func parseRuleComment(data []byte) (string, error) {
+	attrs, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing rule: %w", err)
+	}
+
+	for _, attr := range attrs {
+		switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+		case unix.NFTA_RULE_USERDATA:
+			// A list of type, length, value entries
+			udata := attr.Value
+			for len(udata) >= 2 {
+				typ, length := udata[0], int(udata[1])
+				if len(udata) < 2+length {
+					break
+				}
+				if typ == udataRuleComment {
+					return gadgets.FromCString(udata[2 : 2+length]), nil
+				}
+				udata = udata[2+length:]
+			}
+		case unix.NFTA_RULE_EXPRESSIONS:
+			comment, err := parseCommentMatch(attr.Value)
+			if err != nil {
+				return "", err
+			}
+			if comment != "" {
+				return comment, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// parseCommentMatch looks for the comment match of iptables-nft in the
+// expressions of a rule
+func parseCommentMatch(data []byte) (string, error) {
+	elems, err := nl.ParseRouteAttr(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing expressions: %w", err)
+	}
+
+	for _, elem := range elems {
+		if elem.Attr.Type&nl.NLA_TYPE_MASK != unix.NFTA_LIST_ELEM {
+			continue
+		}
+
+		exprAttrs, err := nl.ParseRouteAttr(elem.Value)
+		if err != nil {
+			return "", fmt.Errorf("parsing expression: %w", err)
+		}
+
+		var name string
+		var exprData []byte
+		for _, attr := range exprAttrs {
+			switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+			case unix.NFTA_EXPR_NAME:
+				name = gadgets.FromCString(attr.Value)
+			case unix.NFTA_EXPR_DATA:
+				exprData = attr.Value
+			}
+		}
+		if name != "match" {
+			continue
+		}
+
+		matchAttrs, err := nl.ParseRouteAttr(exprData)
+		if err != nil {
+			return "", fmt.Errorf("parsing match: %w", err)
+		}
+
+		var matchName string
+		var info []byte
+		for _, attr := range matchAttrs {
+			switch attr.Attr.Type & nl.NLA_TYPE_MASK {
+			case unix.NFTA_MATCH_NAME:
+				matchName = gadgets.FromCString(attr.Value)
+			case unix.NFTA_MATCH_INFO:
+				info = attr.Value
+			}
+		}
+		// The info of the comment match is struct xt_comment_info
+		if matchName == "comment" {
+			return gadgets.FromCString(info), nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,258 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/nftrace/types"
+)
+
+func serialize(family uint8, attrs ...*nl.RtAttr) []byte {
+	data := (&nl.Nfgenmsg{NfgenFamily: family, Version: unix.NFNETLINK_V0}).Serialize()
+	for _, attr := range attrs {
+		data = append(data, attr.Serialize()...)
+	}
+	return data
+}
+
+func verdictAttr(code int32, chain string) *nl.RtAttr {
+	verdict := nl.NewRtAttr(unix.NFTA_TRACE_VERDICT|int(nl.NLA_F_NESTED), nil)
+	verdict.AddRtAttr(unix.NFTA_VERDICT_CODE, nl.BEUint32Attr(uint32(code)))
+	if chain != "" {
+		verdict.AddRtAttr(unix.NFTA_VERDICT_CHAIN, nl.ZeroTerminated(chain))
+	}
+	return verdict
+}
+
+func TestParseTrace(t *testing.T) {
+	t.Parallel()
+
+	ipv4 := []byte{
+		0x45, 0, 0, 60, 0, 0, 0x40, 0, 64, unix.IPPROTO_TCP, 0, 0,
+		10, 244, 0, 14,
+		10, 96, 135, 7,
+	}
+	ipv6 := make([]byte, 40)
+	ipv6[0] = 0x60
+	ipv6[6] = unix.IPPROTO_UDP
+	ipv6[23] = 1
+	ipv6[39] = 2
+	ports := []byte{0xcc, 0xba, 0, 80}
+
+	type testDefinition struct {
+		data      []byte
+		expected  *types.Event
+		isDrop    bool
+		withError bool
+	}
+
+	tests := map[string]testDefinition{
+		"rule_drop": {
+			data: serialize(unix.NFPROTO_IPV4,
+				nl.NewRtAttr(unix.NFTA_TRACE_ID, nl.BEUint32Attr(42)),
+				nl.NewRtAttr(unix.NFTA_TRACE_TABLE, nl.ZeroTerminated("filter")),
+				nl.NewRtAttr(unix.NFTA_TRACE_CHAIN, nl.ZeroTerminated("KUBE-FORWARD")),
+				nl.NewRtAttr(unix.NFTA_TRACE_RULE_HANDLE, nl.BEUint64Attr(12)),
+				nl.NewRtAttr(unix.NFTA_TRACE_TYPE, nl.BEUint32Attr(unix.NFT_TRACETYPE_RULE)),
+				verdictAttr(nfDrop, ""),
+				nl.NewRtAttr(unix.NFTA_TRACE_NETWORK_HEADER, ipv4),
+				nl.NewRtAttr(unix.NFTA_TRACE_TRANSPORT_HEADER, ports),
+				nl.NewRtAttr(unix.NFTA_TRACE_MARK, nl.BEUint32Attr(0x4000)),
+			),
+			expected: &types.Event{
+				TraceID:    42,
+				Family:     "ip",
+				Table:      "filter",
+				Chain:      "KUBE-FORWARD",
+				RuleHandle: 12,
+				TraceType:  "rule",
+				Verdict:    "drop",
+				IPVersion:  4,
+				Mark:       0x4000,
+			},
+			isDrop: true,
+		},
+		"jump": {
+			data: serialize(unix.NFPROTO_INET,
+				nl.NewRtAttr(unix.NFTA_TRACE_TABLE, nl.ZeroTerminated("kube-proxy")),
+				nl.NewRtAttr(unix.NFTA_TRACE_CHAIN, nl.ZeroTerminated("filter-forward")),
+				nl.NewRtAttr(unix.NFTA_TRACE_RULE_HANDLE, nl.BEUint64Attr(7)),
+				nl.NewRtAttr(unix.NFTA_TRACE_TYPE, nl.BEUint32Attr(unix.NFT_TRACETYPE_RULE)),
+				verdictAttr(unix.NFT_JUMP, "forward"),
+				nl.NewRtAttr(unix.NFTA_TRACE_NETWORK_HEADER, ipv6),
+				nl.NewRtAttr(unix.NFTA_TRACE_TRANSPORT_HEADER, ports),
+			),
+			expected: &types.Event{
+				Family:     "inet",
+				Table:      "kube-proxy",
+				Chain:      "filter-forward",
+				RuleHandle: 7,
+				TraceType:  "rule",
+				Verdict:    "jump",
+				JumpTarget: "forward",
+				IPVersion:  6,
+			},
+		},
+		"policy_drop": {
+			data: serialize(unix.NFPROTO_IPV4,
+				nl.NewRtAttr(unix.NFTA_TRACE_TABLE, nl.ZeroTerminated("filter")),
+				nl.NewRtAttr(unix.NFTA_TRACE_CHAIN, nl.ZeroTerminated("INPUT")),
+				nl.NewRtAttr(unix.NFTA_TRACE_TYPE, nl.BEUint32Attr(unix.NFT_TRACETYPE_POLICY)),
+				nl.NewRtAttr(unix.NFTA_TRACE_POLICY, nl.BEUint32Attr(nfDrop)),
+			),
+			expected: &types.Event{
+				Family:    "ip",
+				Table:     "filter",
+				Chain:     "INPUT",
+				TraceType: "policy",
+				Verdict:   "drop",
+			},
+			isDrop: true,
+		},
+		"return": {
+			data: serialize(unix.NFPROTO_IPV4,
+				nl.NewRtAttr(unix.NFTA_TRACE_TABLE, nl.ZeroTerminated("filter")),
+				nl.NewRtAttr(unix.NFTA_TRACE_CHAIN, nl.ZeroTerminated("KUBE-SERVICES")),
+				nl.NewRtAttr(unix.NFTA_TRACE_TYPE, nl.BEUint32Attr(unix.NFT_TRACETYPE_RETURN)),
+				verdictAttr(unix.NFT_CONTINUE, ""),
+			),
+			expected: &types.Event{
+				Family:    "ip",
+				Table:     "filter",
+				Chain:     "KUBE-SERVICES",
+				TraceType: "return",
+				Verdict:   "continue",
+			},
+		},
+		"too_short": {
+			data:      []byte{unix.NFPROTO_IPV4},
+			withError: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tr, err := parseTrace(test.data)
+			if test.withError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.isDrop, tr.isDrop())
+
+			event := &types.Event{
+				TraceID:    tr.id,
+				Family:     familyString(tr.family),
+				Table:      tr.table,
+				Chain:      tr.chain,
+				RuleHandle: tr.ruleHandle,
+				TraceType:  traceTypeString(tr.traceType),
+				Verdict:    verdictString(tr.verdict),
+				JumpTarget: tr.jumpTarget,
+				Mark:       tr.mark,
+			}
+			tr.fillEndpoints(event)
+
+			switch event.IPVersion {
+			case 4:
+				assert.Equal(t, "10.244.0.14", event.SrcEndpoint.Addr)
+				assert.Equal(t, "10.96.135.7", event.DstEndpoint.Addr)
+				assert.Equal(t, uint16(unix.IPPROTO_TCP), event.DstEndpoint.Proto)
+			case 6:
+				assert.Equal(t, "::1", event.SrcEndpoint.Addr)
+				assert.Equal(t, "::2", event.DstEndpoint.Addr)
+				assert.Equal(t, uint16(unix.IPPROTO_UDP), event.DstEndpoint.Proto)
+			}
+			if event.IPVersion != 0 {
+				assert.Equal(t, uint16(52410), event.SrcEndpoint.Port)
+				assert.Equal(t, uint16(80), event.DstEndpoint.Port)
+			}
+
+			event.SrcEndpoint = test.expected.SrcEndpoint
+			event.DstEndpoint = test.expected.DstEndpoint
+			assert.Equal(t, test.expected, event)
+		})
+	}
+}
+
+func TestVerdictString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "accept", verdictString(nfAccept))
+	assert.Equal(t, "drop", verdictString(nfDrop))
+	// NF_QUEUE_NR(5)
+	assert.Equal(t, "queue", verdictString(5<<16|nfQueue))
+	assert.Equal(t, "return", verdictString(unix.NFT_RETURN))
+	assert.Equal(t, "goto", verdictString(unix.NFT_GOTO))
+}
+
+func TestParseRuleComment(t *testing.T) {
+	t.Parallel()
+
+	userdata := []byte{udataRuleComment, 12}
+	userdata = append(userdata, "my comment\x00\x00"...)
+
+	commentInfo := make([]byte, 256)
+	copy(commentInfo, "default/nginx has no endpoints")
+	expressions := nl.NewRtAttr(unix.NFTA_RULE_EXPRESSIONS|int(nl.NLA_F_NESTED), nil)
+	counter := expressions.AddRtAttr(unix.NFTA_LIST_ELEM|int(nl.NLA_F_NESTED), nil)
+	counter.AddRtAttr(unix.NFTA_EXPR_NAME, nl.ZeroTerminated("counter"))
+	match := expressions.AddRtAttr(unix.NFTA_LIST_ELEM|int(nl.NLA_F_NESTED), nil)
+	match.AddRtAttr(unix.NFTA_EXPR_NAME, nl.ZeroTerminated("match"))
+	matchData := match.AddRtAttr(unix.NFTA_EXPR_DATA|int(nl.NLA_F_NESTED), nil)
+	matchData.AddRtAttr(unix.NFTA_MATCH_NAME, nl.ZeroTerminated("comment"))
+	matchData.AddRtAttr(unix.NFTA_MATCH_REV, nl.BEUint32Attr(0))
+	matchData.AddRtAttr(unix.NFTA_MATCH_INFO, commentInfo)
+
+	type testDefinition struct {
+		data     []byte
+		expected string
+	}
+
+	tests := map[string]testDefinition{
+		"userdata": {
+			data:     nl.NewRtAttr(unix.NFTA_RULE_USERDATA, userdata).Serialize(),
+			expected: "my comment",
+		},
+		"match": {
+			data:     expressions.Serialize(),
+			expected: "default/nginx has no endpoints",
+		},
+		"none": {
+			data:     nl.NewRtAttr(unix.NFTA_RULE_HANDLE, nl.BEUint64Attr(3)).Serialize(),
+			expected: "",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			comment, err := parseRuleComment(test.data)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, comment)
+		})
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/nftrace/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// maxPackets is the number of packets whose headers are kept to complete the
+// trace events of the next rules they match
+const maxPackets = 1024
+
+type Config struct {
+	// AllVerdicts reports all the trace events, not only the ones of the
+	// rules and policies dropping the packets
+	AllVerdicts bool
+}
+
+// Tracer reads the nftables trace events of the network namespace it runs in.
+// The kernel only sends them for the packets with the nftrace flag, set with
+// "meta nftrace set 1" in nft or with the TRACE target of iptables-nft. The
+// rules of iptables-legacy aren't traced.
+type Tracer struct {
+	config        *Config
+	eventCallback func(*types.Event)
+
+	sock *nl.NetlinkSocket
+
+	// comments caches the comments of the rules, fetched from the kernel
+	comments map[ruleKey]string
+
+	// packets are the last headers and interfaces seen for each trace ID
+	packets map[uint32]packet
+}
+
+func NewTracer(config *Config, eventCallback func(*types.Event)) (*Tracer, error) {
+	t := &Tracer{
+		config:        config,
+		eventCallback: eventCallback,
+		comments:      make(map[ruleKey]string),
+		packets:       make(map[uint32]packet),
+	}
+
+	if err := t.install(); err != nil {
+		t.close()
+		return nil, err
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+// Stop stops the tracer
+// TODO: Remove after refactoring
+func (t *Tracer) Stop() {
+	t.close()
+}
+
+func (t *Tracer) close() {
+	if t.sock != nil {
+		t.sock.Close()
+	}
+}
+
+func (t *Tracer) install() error {
+	sock, err := nl.Subscribe(unix.NETLINK_NETFILTER, unix.NFNLGRP_NFTRACE)
+	if err != nil {
+		return fmt.Errorf("subscribing to nftables trace events: %w", err)
+	}
+	t.sock = sock
+
+	return nil
+}
+
+func (t *Tracer) run() {
+	for {
+		msgs, _, err := t.sock.Receive()
+		if err != nil {
+			// Receive() returns the error of its last read attempt, EAGAIN,
+			// when the socket is closed
+			if errors.Is(err, os.ErrClosed) || errors.Is(err, unix.EAGAIN) {
+				// nothing to do, we're done
+				return
+			}
+
+			// The socket buffer overflowed
+			if errors.Is(err, unix.ENOBUFS) {
+				t.eventCallback(types.Base(eventtypes.Warn("lost nftables trace events")))
+				continue
+			}
+
+			msg := fmt.Sprintf("Error reading nftables trace events: %s", err)
+			t.eventCallback(types.Base(eventtypes.Err(msg)))
+			return
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Type != unix.NFNL_SUBSYS_NFTABLES<<8|unix.NFT_MSG_TRACE {
+				continue
+			}
+
+			tr, err := parseTrace(msg.Data)
+			if err != nil {
+				msg := fmt.Sprintf("parsing nftables trace event: %s", err)
+				t.eventCallback(types.Base(eventtypes.Warn(msg)))
+				continue
+			}
+
+			t.fillPacket(tr)
+
+			if !t.config.AllVerdicts && !tr.isDrop() {
+				continue
+			}
+
+			t.eventCallback(t.newEvent(tr))
+		}
+	}
+}
+
+// fillPacket completes tr with the headers and the interfaces of the packet:
+// the kernel only copies them in the first trace event of each base chain the
+// packet goes through.
+func (t *Tracer) fillPacket(tr *trace) {
+	if tr.networkHeader == nil && tr.iif == 0 && tr.oif == 0 {
+		tr.packet = t.packets[tr.id]
+	} else {
+		if len(t.packets) >= maxPackets {
+			clear(t.packets)
+		}
+		t.packets[tr.id] = tr.packet
+	}
+
+	if tr.isDrop() {
+		delete(t.packets, tr.id)
+	}
+}
+
+func (t *Tracer) newEvent(tr *trace) *types.Event {
+	event := &types.Event{
+		Event: eventtypes.Event{
+			Type:      eventtypes.NORMAL,
+			Timestamp: eventtypes.Time(time.Now().UnixNano()),
+		},
+		TraceID:    tr.id,
+		Family:     familyString(tr.family),
+		Table:      tr.table,
+		Chain:      tr.chain,
+		RuleHandle: tr.ruleHandle,
+		TraceType:  traceTypeString(tr.traceType),
+		Verdict:    verdictString(tr.verdict),
+		JumpTarget: tr.jumpTarget,
+		Iif:        interfaceName(tr.iif),
+		Oif:        interfaceName(tr.oif),
+		Mark:       tr.mark,
+	}
+
+	if tr.ruleHandle != 0 {
+		event.Comment = t.ruleComment(tr)
+	}
+
+	tr.fillEndpoints(event)
+
+	return event
+}
+
+func (t *Tracer) ruleComment(tr *trace) string {
+	key := ruleKey{
+		family: tr.family,
+		table:  tr.table,
+		chain:  tr.chain,
+		handle: tr.ruleHandle,
+	}
+
+	if comment, ok := t.comments[key]; ok {
+		return comment
+	}
+
+	// The rule may have been deleted since the packet matched it: the error
+	// is ignored and the rule isn't looked up again
+	comment, _ := getRuleComment(key)
+	t.comments[key] = comment
+	return comment
+}
+
+func interfaceName(index uint32) string {
+	if index == 0 {
+		return ""
+	}
+
+	link, err := netlink.LinkByIndex(int(index))
+	if err != nil {
+		return fmt.Sprint(index)
+	}
+	return link.Attrs().Name
+}
+
+// --- Registry changes
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.AllVerdicts = gadgetCtx.GadgetParams().Get(ParamAllVerdicts).AsBool()
+	t.comments = make(map[ruleKey]string)
+	t.packets = make(map[uint32]packet)
+
+	defer t.close()
+	if err := t.install(); err != nil {
+		return fmt.Errorf("installing tracer: %w", err)
+	}
+
+	go t.run()
+	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
+
+	return nil
+}
+
+func (t *Tracer) SetEventHandler(handler any) {
+	nh, ok := handler.(func(ev *types.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventCallback = nh
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	tracer := &Tracer{
+		config: &Config{},
+	}
+	return tracer, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type Event struct {
+	eventtypes.Event
+
+	// TraceID is the same for all the events of a packet
+	TraceID uint32 `json:"traceID" column:"traceID,width:10,hide"`
+
+	// Family of the table: ip, ip6, inet, arp, bridge or netdev
+	Family string `json:"family" column:"family,width:6,fixed"`
+	Table  string `json:"table" column:"table,minWidth:6,maxWidth:16"`
+	Chain  string `json:"chain" column:"chain,minWidth:6,maxWidth:32"`
+	// RuleHandle is 0 when the verdict is the policy of the chain
+	RuleHandle uint64 `json:"ruleHandle,omitempty" column:"rule,minWidth:4,align:right"`
+	Comment    string `json:"comment,omitempty" column:"comment,minWidth:8,maxWidth:40,ellipsis:end"`
+
+	// TraceType is rule, policy or return
+	TraceType string `json:"traceType" column:"type,width:6,fixed,hide"`
+	Verdict   string `json:"verdict" column:"verdict,width:8,fixed"`
+	// JumpTarget is the chain the packet jumped to, for jump and goto verdicts
+	JumpTarget string `json:"jumpTarget,omitempty" column:"jumpTarget,minWidth:6,maxWidth:32,hide"`
+
+	IPVersion   int                   `json:"ipversion,omitempty" column:"ip,template:ipversion"`
+	SrcEndpoint eventtypes.L4Endpoint `json:"src,omitempty" column:"src"`
+	DstEndpoint eventtypes.L4Endpoint `json:"dst,omitempty" column:"dst"`
+
+	Iif  string `json:"iif,omitempty" column:"iif,minWidth:4,maxWidth:16,hide"`
+	Oif  string `json:"oif,omitempty" column:"oif,minWidth:4,maxWidth:16,hide"`
+	Mark uint32 `json:"mark,omitempty" column:"mark,width:10,hide"`
+}
+
+func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
+	return []*eventtypes.L3Endpoint{&e.SrcEndpoint.L3Endpoint, &e.DstEndpoint.L3Endpoint}
+}
+
+func GetColumns() *columns.Columns[Event] {
+	nftraceColumns := columns.MustCreateColumns[Event]()
+
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		nftraceColumns,
+		columns.Attributes{
+			Name:     "src",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    2000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.SrcEndpoint },
+	)
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		nftraceColumns,
+		columns.Attributes{
+			Name:     "dst",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    3000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.DstEndpoint },
+	)
+
+	return nftraceColumns
+}
+
+func Base(ev eventtypes.Event) *Event {
+	return &Event{
+		Event: ev,
+	}
+}
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -35,14 +34,15 @@ import (
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -cc clang -cflags ${CFLAGS} -type data_t oomkill ./bpf/oomkill.bpf.c -- -I./bpf/
 
 type Config struct {
-	MountnsMap *ebpf.Map
+	MountnsMap      *ebpf.Map
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
 	config        *Config
 	objs          oomkillObjects
 	oomLink       link.Link
-	reader        *perf.Reader
+	reader        *gadgets.BufferReader
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
 }
@@ -96,9 +96,9 @@ func (t *Tracer) install() error {
 	}
 	t.oomLink = kprobe
 
-	reader, err := perf.NewReader(t.objs.oomkillMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.oomkillMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -110,10 +110,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -151,6 +152,8 @@ func (t *Tracer) run() {
 // --- Registry changes
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
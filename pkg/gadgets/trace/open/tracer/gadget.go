@@ -46,7 +46,7 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return params.ParamDescs{
+	p := params.ParamDescs{
 		{
 			Key:          ParamFullPath,
 			Title:        "Absolute full path",
@@ -60,6 +60,9 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			DefaultValue: "",
 		},
 	}
+	p.Add(gadgets.UserFilterParams()...)
+	p.Add(gadgets.SamplingParams()...)
+	return p
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
@@ -26,7 +26,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -60,9 +59,12 @@ type opensnoopEventAbbrev struct {
 }
 
 type Config struct {
-	MountnsMap *ebpf.Map
-	FullPath   bool
-	Prefixes   []string
+	MountnsMap      *ebpf.Map
+	FullPath        bool
+	Prefixes        []string
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
+	Sampling        gadgets.Sampling
 }
 
 type Tracer struct {
@@ -75,7 +77,7 @@ type Tracer struct {
 	openAtEnterLink link.Link
 	openExitLink    link.Link
 	openAtExitLink  link.Link
-	reader          *perf.Reader
+	reader          *gadgets.BufferReader
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -131,6 +133,9 @@ func (t *Tracer) install() error {
 	consts := make(map[string]interface{})
 	consts["get_full_path"] = t.config.FullPath
 	consts["prefixes_nr"] = prefixesNumber
+	consts["targ_uid"] = t.config.UserFilter.TargetUID()
+	consts[gadgets.SampleRateName] = t.config.Sampling.Rate
+	consts[gadgets.MaxEventsPerSecondName] = t.config.Sampling.MaxEventsPerSecond
 
 	for _, prefix := range t.config.Prefixes {
 		var pfx [NAME_MAX]uint8
@@ -184,9 +189,9 @@ func (t *Tracer) install() error {
 	}
 	t.openAtExitLink = openAtExit
 
-	reader, err := perf.NewReader(t.objs.opensnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.opensnoopMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -198,10 +203,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -219,6 +225,10 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*opensnoopEventAbbrev)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		mode := fs.FileMode(bpfEvent.Mode)
 
 		event := types.Event{
@@ -254,6 +264,9 @@ func (t *Tracer) run() {
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.FullPath = gadgetCtx.GadgetParams().Get(ParamFullPath).AsBool()
 	t.config.Prefixes = gadgetCtx.GadgetParams().Get(ParamPrefixes).AsStringSlice()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+	t.config.UserFilter = gadgets.UserFilterFromParams(gadgetCtx.GadgetParams())
+	t.config.Sampling = gadgets.SamplingFromParams(gadgetCtx.GadgetParams())
 
 	defer t.close()
 	if err := t.install(); err != nil {
@@ -0,0 +1,93 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/psi/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
+)
+
+const (
+	ParamInterval        = "interval"
+	ParamCPUThreshold    = "cpu-threshold"
+	ParamMemoryThreshold = "memory-threshold"
+	ParamIOThreshold     = "io-threshold"
+)
+
+type GadgetDesc struct{}
+
+func (g *GadgetDesc) Name() string {
+	return "psi"
+}
+
+func (g *GadgetDesc) Category() string {
+	return gadgets.CategoryTrace
+}
+
+func (g *GadgetDesc) Type() gadgets.GadgetType {
+	return gadgets.TypeTrace
+}
+
+func (g *GadgetDesc) Description() string {
+	return "Trace containers stalled on cpu, memory or io above a threshold, using the pressure stall information of their cgroup"
+}
+
+func (g *GadgetDesc) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:          ParamInterval,
+			Title:        "Interval",
+			DefaultValue: "5",
+			TypeHint:     params.TypeUint32,
+			Description:  "Interval between two reads of the pressure of the containers, in seconds",
+		},
+		{
+			Key:          ParamCPUThreshold,
+			Title:        "CPU Threshold",
+			DefaultValue: "10",
+			TypeHint:     params.TypeFloat64,
+			Description:  "Percentage of time some tasks were stalled on cpu over the last 10 seconds above which an event is emitted",
+		},
+		{
+			Key:          ParamMemoryThreshold,
+			Title:        "Memory Threshold",
+			DefaultValue: "10",
+			TypeHint:     params.TypeFloat64,
+			Description:  "Percentage of time some tasks were stalled on memory over the last 10 seconds above which an event is emitted",
+		},
+		{
+			Key:          ParamIOThreshold,
+			Title:        "IO Threshold",
+			DefaultValue: "10",
+			TypeHint:     params.TypeFloat64,
+			Description:  "Percentage of time some tasks were stalled on io over the last 10 seconds above which an event is emitted",
+		},
+	}
+}
+
+func (g *GadgetDesc) Parser() parser.Parser {
+	return parser.NewParser[types.Event](types.GetColumns())
+}
+
+func (g *GadgetDesc) EventPrototype() any {
+	return &types.Event{}
+}
+
+func init() {
+	gadgetregistry.Register(&GadgetDesc{})
+}
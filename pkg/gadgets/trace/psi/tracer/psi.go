@@ -0,0 +1,81 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pressureLine is a line of a pressure file, like
+// "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+type pressureLine struct {
+	avg10 float64
+	avg60 float64
+	total uint64
+}
+
+type pressure struct {
+	some pressureLine
+	full pressureLine
+}
+
+// parsePressure parses the content of a <resource>.pressure file of a cgroup
+// v2. The full line is missing for cpu before Linux 5.13, it's left to zero.
+func parsePressure(content []byte) (pressure, error) {
+	var p pressure
+	foundSome := false
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var pl *pressureLine
+		switch fields[0] {
+		case "some":
+			pl = &p.some
+			foundSome = true
+		case "full":
+			pl = &p.full
+		default:
+			continue
+		}
+
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return pressure{}, fmt.Errorf("invalid field %q", field)
+			}
+			var err error
+			switch key {
+			case "avg10":
+				pl.avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				pl.avg60, err = strconv.ParseFloat(value, 64)
+			case "total":
+				pl.total, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return pressure{}, fmt.Errorf("parsing %s of %s line: %w", key, fields[0], err)
+			}
+		}
+	}
+	if !foundSome {
+		return pressure{}, fmt.Errorf("some line not found")
+	}
+	return p, nil
+}
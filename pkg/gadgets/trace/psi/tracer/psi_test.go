@@ -0,0 +1,79 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePressure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		expected pressure
+		err      bool
+	}{
+		{
+			name: "some_and_full",
+			content: "some avg10=12.50 avg60=3.20 avg300=0.80 total=123456\n" +
+				"full avg10=4.00 avg60=1.10 avg300=0.20 total=45678\n",
+			expected: pressure{
+				some: pressureLine{avg10: 12.5, avg60: 3.2, total: 123456},
+				full: pressureLine{avg10: 4, avg60: 1.1, total: 45678},
+			},
+		},
+		{
+			name:    "cpu_without_full",
+			content: "some avg10=0.00 avg60=0.00 avg300=0.00 total=42\n",
+			expected: pressure{
+				some: pressureLine{total: 42},
+			},
+		},
+		{
+			name:    "missing_some",
+			content: "full avg10=0.00 avg60=0.00 avg300=0.00 total=0\n",
+			err:     true,
+		},
+		{
+			name:    "invalid_value",
+			content: "some avg10=abc avg60=0.00 avg300=0.00 total=0\n",
+			err:     true,
+		},
+		{
+			name:    "empty",
+			content: "",
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := parsePressure([]byte(test.content))
+			if test.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, p)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	psitypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/psi/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type Config struct {
+	Interval   time.Duration
+	Thresholds map[string]float64
+}
+
+type Tracer struct {
+	config *Config
+
+	// mu protects containers and totals, as containers can be attached
+	// while the tracer is running
+	mu sync.Mutex
+	// containers is a map where the key is the container ID
+	containers map[string]*containercollection.Container
+	// totals holds the stall time read at the previous interval, to compute
+	// the stall time during the interval. The key is the container ID.
+	totals map[string]map[string]uint64
+
+	eventHandler func(*psitypes.Event)
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	return &Tracer{
+		config:     &Config{},
+		containers: make(map[string]*containercollection.Container),
+		totals:     make(map[string]map[string]uint64),
+	}, nil
+}
+
+func (t *Tracer) AttachContainer(container *containercollection.Container) error {
+	if container.CgroupV2 == "" {
+		return fmt.Errorf("container %q not in a cgroup v2", container.Runtime.ContainerID)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.containers[container.Runtime.ContainerID] = container
+	return nil
+}
+
+func (t *Tracer) DetachContainer(container *containercollection.Container) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.containers, container.Runtime.ContainerID)
+	delete(t.totals, container.Runtime.ContainerID)
+	return nil
+}
+
+func (t *Tracer) SetEventHandler(handler any) {
+	nh, ok := handler.(func(ev *psitypes.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventHandler = nh
+}
+
+func readPressure(cgroupPath, resource string) (pressure, error) {
+	dir, err := cgroups.CgroupPathV2AddMountpoint(cgroupPath)
+	if err != nil {
+		return pressure{}, err
+	}
+	content, err := os.ReadFile(filepath.Join(dir, resource+".pressure"))
+	if err != nil {
+		return pressure{}, err
+	}
+	return parsePressure(content)
+}
+
+// collect reads the pressure of the attached containers and returns the
+// events of the resources whose stall percentage is above the threshold
+func (t *Tracer) collect(gadgetCtx gadgets.GadgetContext) []*psitypes.Event {
+	logger := gadgetCtx.Logger()
+	events := []*psitypes.Event{}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, container := range t.containers {
+		totals, ok := t.totals[id]
+		if !ok {
+			totals = make(map[string]uint64)
+			t.totals[id] = totals
+		}
+
+		for _, resource := range psitypes.Resources {
+			p, err := readPressure(container.CgroupV2, resource)
+			if err != nil {
+				logger.Debugf("reading %s pressure of container %q: %s", resource, id, err)
+				continue
+			}
+
+			prevTotal, seen := totals[resource]
+			totals[resource] = p.some.total
+
+			threshold := t.config.Thresholds[resource]
+			if p.some.avg10 < threshold {
+				continue
+			}
+
+			var stalled uint64
+			if seen && p.some.total >= prevTotal {
+				stalled = p.some.total - prevTotal
+			}
+
+			events = append(events, &psitypes.Event{
+				Event: eventtypes.Event{
+					Type:      eventtypes.NORMAL,
+					Timestamp: eventtypes.Time(time.Now().UnixNano()),
+				},
+				WithMountNsID: eventtypes.WithMountNsID{MountNsID: container.Mntns},
+				Resource:      resource,
+				SomeAvg10:     p.some.avg10,
+				SomeAvg60:     p.some.avg60,
+				FullAvg10:     p.full.avg10,
+				FullAvg60:     p.full.avg60,
+				SomeStalledUs: stalled,
+				Threshold:     threshold,
+			})
+		}
+	}
+
+	return events
+}
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	params := gadgetCtx.GadgetParams()
+	t.config.Interval = time.Second * time.Duration(params.Get(ParamInterval).AsUint32())
+	if t.config.Interval == 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+	t.config.Thresholds = map[string]float64{
+		psitypes.ResourceCPU:    params.Get(ParamCPUThreshold).AsFloat64(),
+		psitypes.ResourceMemory: params.Get(ParamMemoryThreshold).AsFloat64(),
+		psitypes.ResourceIO:     params.Get(ParamIOThreshold).AsFloat64(),
+	}
+
+	ctx, cancel := gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
+	defer cancel()
+
+	ticker := time.NewTicker(t.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for _, event := range t.collect(gadgetCtx) {
+			t.eventHandler(event)
+		}
+	}
+}
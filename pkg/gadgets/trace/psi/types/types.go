@@ -0,0 +1,56 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	ResourceCPU    = "cpu"
+	ResourceMemory = "memory"
+	ResourceIO     = "io"
+)
+
+// Resources are the resources whose pressure is reported, in the order they
+// are read
+var Resources = []string{ResourceCPU, ResourceMemory, ResourceIO}
+
+type Event struct {
+	eventtypes.Event
+	eventtypes.WithMountNsID
+
+	Resource string `json:"resource" column:"resource,maxWidth:6"`
+
+	// SomeAvg10 and FullAvg10 are the percentages of time some or all the
+	// tasks of the container were stalled on the resource over the last 10
+	// seconds, as reported by the kernel in the <resource>.pressure file of
+	// the cgroup
+	SomeAvg10 float64 `json:"someAvg10" column:"some10,align:right,precision:2"`
+	SomeAvg60 float64 `json:"someAvg60" column:"some60,align:right,precision:2,hide"`
+	FullAvg10 float64 `json:"fullAvg10" column:"full10,align:right,precision:2"`
+	FullAvg60 float64 `json:"fullAvg60" column:"full60,align:right,precision:2,hide"`
+
+	// SomeStalledUs is the time some tasks were stalled on the resource
+	// since the previous interval, in microseconds
+	SomeStalledUs uint64 `json:"someStalledUs" column:"someStalledUs,align:right"`
+
+	Threshold float64 `json:"threshold" column:"threshold,align:right,precision:2,hide"`
+}
+
+func GetColumns() *columns.Columns[Event] {
+	return columns.MustCreateColumns[Event]()
+}
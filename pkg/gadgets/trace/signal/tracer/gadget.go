@@ -52,7 +52,7 @@ const (
 )
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return params.ParamDescs{
+	p := params.ParamDescs{
 		{
 			Key:          ParamPID,
 			DefaultValue: "0",
@@ -79,6 +79,8 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			TypeHint:     params.TypeBool,
 		},
 	}
+	p.Add(gadgets.UserFilterParams()...)
+	return p
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
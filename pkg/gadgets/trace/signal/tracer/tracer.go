@@ -27,7 +27,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -40,11 +39,13 @@ import (
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags ${CFLAGS} -type event sigsnoop ./bpf/sigsnoop.bpf.c --
 
 type Config struct {
-	MountnsMap   *ebpf.Map
-	TargetSignal string
-	TargetPid    int32
-	FailedOnly   bool
-	KillOnly     bool
+	MountnsMap      *ebpf.Map
+	TargetSignal    string
+	TargetPid       int32
+	FailedOnly      bool
+	KillOnly        bool
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -58,7 +59,7 @@ type Tracer struct {
 	enterTgkillLink    link.Link
 	exitTgkillLink     link.Link
 	signalGenerateLink link.Link
-	reader             *perf.Reader
+	reader             *gadgets.BufferReader
 
 	enricher      gadgets.DataEnricherByMntNs
 	eventCallback func(*types.Event)
@@ -170,9 +171,9 @@ func (t *Tracer) install() error {
 		}
 	}
 
-	t.reader, err = perf.NewReader(t.objs.sigsnoopMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	t.reader, err = gadgets.NewBufferReader(t.objs.sigsnoopMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.objs.sigsnoopMaps.Events); err != nil {
@@ -183,10 +184,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -204,6 +206,10 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*sigsnoopEvent)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		event := types.Event{
 			Event: eventtypes.Event{
 				Type:      eventtypes.NORMAL,
@@ -235,6 +241,8 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	t.config.FailedOnly = params.Get(ParamFailedOnly).AsBool()
 	t.config.KillOnly = params.Get(ParamKillOnly).AsBool()
 	t.config.TargetSignal = params.Get(ParamTargetSignal).AsString()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
+	t.config.UserFilter = gadgets.UserFilterFromParams(params)
 
 	defer t.close()
 	if err := t.install(); err != nil {
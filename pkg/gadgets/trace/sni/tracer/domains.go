@@ -0,0 +1,74 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// domainMatcher matches server names against a list of domains. A domain
+// prefixed with "*." matches its subdomains, but not the domain itself.
+type domainMatcher struct {
+	exact    map[string]struct{}
+	suffixes []string
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+func newDomainMatcher(domains []string) (*domainMatcher, error) {
+	m := &domainMatcher{
+		exact: make(map[string]struct{}),
+	}
+
+	for _, domain := range domains {
+		domain = normalizeDomain(strings.TrimSpace(domain))
+
+		wildcard := strings.HasPrefix(domain, "*.")
+		if wildcard {
+			domain = domain[2:]
+		}
+		if domain == "" {
+			return nil, errors.New("empty domain")
+		}
+		if strings.Contains(domain, "*") {
+			return nil, fmt.Errorf("invalid domain %q: '*' is only supported as '*.' prefix", domain)
+		}
+
+		if wildcard {
+			m.suffixes = append(m.suffixes, "."+domain)
+		} else {
+			m.exact[domain] = struct{}{}
+		}
+	}
+
+	return m, nil
+}
+
+func (m *domainMatcher) matches(name string) bool {
+	name = normalizeDomain(name)
+	if _, ok := m.exact[name]; ok {
+		return true
+	}
+	for _, suffix := range m.suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/sni/types"
+)
+
+func TestDomainMatcher(t *testing.T) {
+	t.Parallel()
+
+	m, err := newDomainMatcher([]string{"example.com", "*.Inspektor-Gadget.io."})
+	require.NoError(t, err)
+
+	for name, expected := range map[string]bool{
+		"example.com":                 true,
+		"EXAMPLE.com.":                true,
+		"www.example.com":             false,
+		"inspektor-gadget.io":         false,
+		"www.inspektor-gadget.io":     true,
+		"a.b.inspektor-gadget.io":     true,
+		"myinspektor-gadget.io":       false,
+		"inspektor-gadget.io.example": false,
+	} {
+		require.Equal(t, expected, m.matches(name), name)
+	}
+}
+
+func TestDomainMatcherInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, domains := range [][]string{
+		{""},
+		{"*."},
+		{"*example.com"},
+		{"www.*.example.com"},
+	} {
+		_, err := newDomainMatcher(domains)
+		require.Error(t, err, domains)
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	t.Parallel()
+
+	domains, err := newDomainMatcher([]string{"*.example.com"})
+	require.NoError(t, err)
+	denyDomains, err := newDomainMatcher([]string{"evil.example.com"})
+	require.NoError(t, err)
+
+	tracer := &Tracer{domains: domains, denyDomains: denyDomains}
+	for name, expected := range map[string]types.Verdict{
+		"www.example.com":  types.VerdictAlert,
+		"evil.example.com": types.VerdictDeny,
+		"example.org":      types.VerdictAllow,
+	} {
+		require.Equal(t, expected, tracer.verdict(name), name)
+	}
+}
@@ -15,6 +15,8 @@
 package tracer
 
 import (
+	"strings"
+
 	gadgetregistry "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-registry"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/sni/types"
@@ -22,6 +24,12 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
 )
 
+const (
+	ParamDomains     = "domains"
+	ParamDenyDomains = "deny-domains"
+	ParamAlertOnly   = "alert-only"
+)
+
 type GadgetDesc struct{}
 
 func (g *GadgetDesc) Name() string {
@@ -41,7 +49,41 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return nil
+	return params.ParamDescs{
+		{
+			Key:         ParamDomains,
+			Title:       "Domains",
+			Description: "Comma-separated list of domains to raise alerts for. Prefix a domain with '*.' to match its subdomains",
+			TypeHint:    params.TypeStringSlice,
+			Validator: func(value string) error {
+				if value == "" {
+					return nil
+				}
+				_, err := newDomainMatcher(strings.Split(value, ","))
+				return err
+			},
+		},
+		{
+			Key:         ParamDenyDomains,
+			Title:       "Deny Domains",
+			Description: "Comma-separated list of domains whose connections get the deny verdict. Prefix a domain with '*.' to match its subdomains",
+			TypeHint:    params.TypeStringSlice,
+			Validator: func(value string) error {
+				if value == "" {
+					return nil
+				}
+				_, err := newDomainMatcher(strings.Split(value, ","))
+				return err
+			},
+		},
+		{
+			Key:          ParamAlertOnly,
+			Title:        "Alert Only",
+			DefaultValue: "false",
+			Description:  "Only show the connections with the alert or deny verdict",
+			TypeHint:     params.TypeBool,
+		},
+	}
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
@@ -40,6 +40,10 @@ type Tracer struct {
 
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	domains     *domainMatcher
+	denyDomains *domainMatcher
+	alertOnly   bool
 }
 
 func NewTracer() (*Tracer, error) {
@@ -97,6 +101,34 @@ func parseSNIEvent(sample []byte, netns uint64) (*types.Event, error) {
 	return &event, nil
 }
 
+// verdict returns the verdict for a server name. The deny domains take
+// precedence over the alert ones.
+func (t *Tracer) verdict(name string) types.Verdict {
+	switch {
+	case t.denyDomains != nil && t.denyDomains.matches(name):
+		return types.VerdictDeny
+	case t.domains != nil && t.domains.matches(name):
+		return types.VerdictAlert
+	}
+	return types.VerdictAllow
+}
+
+// parseEvent parses the event and sets its verdict according to the domains.
+func (t *Tracer) parseEvent(sample []byte, netns uint64) (*types.Event, error) {
+	event, err := parseSNIEvent(sample, netns)
+	if err != nil || event == nil || (t.domains == nil && t.denyDomains == nil) {
+		return event, err
+	}
+
+	event.Verdict = t.verdict(event.Name)
+	event.Alert = event.Verdict != types.VerdictAllow
+	if t.alertOnly && !event.Alert {
+		return nil, nil
+	}
+
+	return event, nil
+}
+
 // --- Registry changes
 
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
@@ -104,10 +136,31 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 }
 
 func (t *Tracer) Init(gadgetCtx gadgets.GadgetContext) error {
+	params := gadgetCtx.GadgetParams()
+	if domains := params.Get(ParamDomains).AsStringSlice(); len(domains) > 0 {
+		matcher, err := newDomainMatcher(domains)
+		if err != nil {
+			return fmt.Errorf("parsing domains: %w", err)
+		}
+		t.domains = matcher
+	}
+	if domains := params.Get(ParamDenyDomains).AsStringSlice(); len(domains) > 0 {
+		matcher, err := newDomainMatcher(domains)
+		if err != nil {
+			return fmt.Errorf("parsing deny domains: %w", err)
+		}
+		t.denyDomains = matcher
+	}
+	t.alertOnly = params.Get(ParamAlertOnly).AsBool()
+	if t.alertOnly && t.domains == nil && t.denyDomains == nil {
+		return fmt.Errorf("--%s requires --%s or --%s", ParamAlertOnly, ParamDomains, ParamDenyDomains)
+	}
+
 	if err := t.install(); err != nil {
 		t.Close()
 		return fmt.Errorf("installing tracer: %w", err)
 	}
+	t.Tracer.SetPerfBufferPages(gadgets.PerfBufferPagesFromParams(params))
 
 	t.ctx, t.cancel = gadgetcontext.WithTimeoutOrCancel(gadgetCtx.Context(), gadgetCtx.Timeout())
 	return nil
@@ -128,7 +181,7 @@ func (t *Tracer) run() error {
 		return fmt.Errorf("loading asset: %w", err)
 	}
 
-	err = t.Tracer.Run(spec, types.Base, parseSNIEvent)
+	err = t.Tracer.Run(spec, types.Base, t.parseEvent)
 	if err != nil {
 		return fmt.Errorf("setting network tracer spec: %w", err)
 	}
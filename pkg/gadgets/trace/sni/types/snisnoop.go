@@ -20,6 +20,17 @@ import (
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
+// Verdict is the decision taken for a connection according to the domains
+// given to the gadget. The gadget doesn't block connections itself: the
+// verdict is meant to be acted upon by an enforcement layer.
+type Verdict string
+
+const (
+	VerdictAllow Verdict = "allow"
+	VerdictAlert Verdict = "alert"
+	VerdictDeny  Verdict = "deny"
+)
+
 type Event struct {
 	eventtypes.Event
 	eventtypes.WithMountNsID
@@ -33,6 +44,13 @@ type Event struct {
 	Gid uint32 `json:"gid" column:"gid,template:gid,hide"`
 
 	Name string `json:"name,omitempty" column:"name,width:30"`
+
+	// Alert is set when the name matches one of the domains given to the
+	// gadget.
+	Alert bool `json:"alert,omitempty" column:"alert,width:5,fixed,hide"`
+
+	// Verdict is only set when domains are given to the gadget
+	Verdict Verdict `json:"verdict,omitempty" column:"verdict,width:7,fixed,hide"`
 }
 
 func GetColumns() *columns.Columns[Event] {
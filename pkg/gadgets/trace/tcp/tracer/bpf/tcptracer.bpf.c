@@ -117,9 +117,9 @@ static __always_inline bool fill_tuple(struct tuple_key_t *tuple,
 }
 
 static __always_inline void fill_event(struct tuple_key_t *tuple,
-				       struct event *event, __u32 pid,
-				       __u64 uid_gid, __u16 family, __u8 type,
-				       __u64 mntns_id)
+				       struct event *event, struct sock *sk,
+				       __u32 pid, __u64 uid_gid, __u16 family,
+				       __u8 type, __u64 mntns_id)
 {
 	event->ts_us = bpf_ktime_get_ns() / 1000;
 	event->type = type;
@@ -139,6 +139,8 @@ static __always_inline void fill_event(struct tuple_key_t *tuple,
 	}
 	event->sport = tuple->sport;
 	event->dport = tuple->dport;
+	/* the cookie is assigned by the socketcookie sock_ops program */
+	event->cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
 }
 
 /* returns true if the event should be skipped */
@@ -275,8 +277,8 @@ int BPF_KPROBE(ig_tcp_close, struct sock *sk)
 	if (!fill_tuple(&tuple, sk, family))
 		return 0;
 
-	fill_event(&tuple, &event, pid, uid_gid, family, TCP_EVENT_TYPE_CLOSE,
-		   mntns_id);
+	fill_event(&tuple, &event, sk, pid, uid_gid, family,
+		   TCP_EVENT_TYPE_CLOSE, mntns_id);
 	bpf_get_current_comm(&event.task, sizeof(event.task));
 	event.timestamp = bpf_ktime_get_boot_ns();
 
@@ -309,7 +311,7 @@ int BPF_KPROBE(ig_tcp_state, struct sock *sk, int state)
 	if (!p)
 		return 0; /* missed entry */
 
-	fill_event(&tuple, &event, p->pid, p->uid_gid, family,
+	fill_event(&tuple, &event, sk, p->pid, p->uid_gid, family,
 		   TCP_EVENT_TYPE_CONNECT, p->mntns_id);
 	__builtin_memcpy(&event.task, p->comm, sizeof(event.task));
 	event.timestamp = bpf_ktime_get_boot_ns();
@@ -352,8 +354,8 @@ int BPF_KRETPROBE(ig_tcp_accept, struct sock *sk)
 	if (t.saddr_v6 == 0 || t.daddr_v6 == 0 || t.dport == 0 || t.sport == 0)
 		return 0;
 
-	fill_event(&t, &event, pid, uid_gid, family, TCP_EVENT_TYPE_ACCEPT,
-		   mntns_id);
+	fill_event(&t, &event, sk, pid, uid_gid, family,
+		   TCP_EVENT_TYPE_ACCEPT, mntns_id);
 
 	bpf_get_current_comm(&event.task, sizeof(event.task));
 	event.timestamp = bpf_ktime_get_boot_ns();
@@ -33,6 +33,7 @@ struct event {
 	__u64 mntns_id;
 	__u64 timestamp;
 	__u64 ts_us;
+	__u64 cookie;
 	__u32 pid;
 	__u32 uid;
 	__u32 gid;
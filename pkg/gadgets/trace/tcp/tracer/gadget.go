@@ -41,7 +41,7 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return nil
+	return gadgets.UserFilterParams()
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
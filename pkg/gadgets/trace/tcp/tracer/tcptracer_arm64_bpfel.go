@@ -19,6 +19,7 @@ type tcptracerEvent struct {
 	MntnsId   uint64
 	Timestamp uint64
 	TsUs      uint64
+	Cookie    uint64
 	Pid       uint32
 	Uid       uint32
 	Gid       uint32
@@ -27,7 +28,7 @@ type tcptracerEvent struct {
 	Dport     uint16
 	Sport     uint16
 	Type      tcptracerEventType
-	_         [1]byte
+	_         [9]byte
 }
 
 type tcptracerEventType uint8
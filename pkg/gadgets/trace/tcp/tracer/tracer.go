@@ -24,18 +24,21 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
+	log "github.com/sirupsen/logrus"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/tcp/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/socketcookie"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
 //go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -cc clang -cflags ${CFLAGS} -no-global-types -type event -type event_type tcptracer ./bpf/tcptracer.bpf.c -- -I./bpf/
 
 type Config struct {
-	MountnsMap *ebpf.Map
+	MountnsMap      *ebpf.Map
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
 }
 
 type Tracer struct {
@@ -53,7 +56,8 @@ type Tracer struct {
 	tcpSetStateEnterLink  link.Link
 	inetCskAcceptExitLink link.Link
 
-	reader *perf.Reader
+	reader         *gadgets.BufferReader
+	cookieAssigner *socketcookie.Assigner
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -94,6 +98,11 @@ func (t *Tracer) close() {
 		t.reader.Close()
 	}
 
+	if t.cookieAssigner != nil {
+		t.cookieAssigner.Close()
+		t.cookieAssigner = nil
+	}
+
 	t.objs.Close()
 }
 
@@ -103,7 +112,11 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
-	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, nil, &t.objs); err != nil {
+	consts := map[string]interface{}{
+		"filter_uid": t.config.UserFilter.TargetUID(),
+	}
+
+	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &t.objs); err != nil {
 		return fmt.Errorf("loading ebpf spec: %w", err)
 	}
 
@@ -143,9 +156,15 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching kprobe: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.tcptracerMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	// The flow IDs are left empty when the cookies can't be assigned
+	t.cookieAssigner, err = socketcookie.NewAssigner()
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		log.Warnf("tcp: flow IDs won't be available: %v", err)
+	}
+
+	reader, err := gadgets.NewBufferReader(t.objs.tcptracerMaps.Events, t.config.PerfBufferPages)
+	if err != nil {
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -157,10 +176,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -178,6 +198,10 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*tcptracerEvent)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		ipversion := gadgets.IPVerFromAF(bpfEvent.Af)
 
 		event := types.Event{
@@ -205,6 +229,7 @@ func (t *Tracer) run() {
 				Port: gadgets.Htons(bpfEvent.Dport),
 			},
 			IPVersion: ipversion,
+			FlowID:    bpfEvent.Cookie,
 		}
 
 		switch bpfEvent.Type {
@@ -227,6 +252,9 @@ func (t *Tracer) run() {
 // --- Registry changes
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+	t.config.UserFilter = gadgets.UserFilterFromParams(gadgetCtx.GadgetParams())
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
@@ -32,6 +32,10 @@ type Event struct {
 
 	SrcEndpoint eventtypes.L4Endpoint `json:"src,omitempty" column:"src"`
 	DstEndpoint eventtypes.L4Endpoint `json:"dst,omitempty" column:"dst"`
+
+	// FlowID is the cookie of the socket. It's the same in the events of
+	// other gadgets about the same connection.
+	FlowID uint64 `json:"flowID,omitempty" column:"flowid,hide"`
 }
 
 func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
@@ -176,6 +176,7 @@ static __always_inline void trace_v4(struct pt_regs *ctx, pid_t pid,
 	BPF_CORE_READ_INTO(&event.daddr_v4, sk, __sk_common.skc_daddr);
 	event.dport = dport;
 	event.sport = BPF_CORE_READ(sk, __sk_common.skc_num);
+	event.cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
 	;
 	event.mntns_id = mntns_id;
 	bpf_get_current_comm(event.task, sizeof(event.task));
@@ -204,6 +205,7 @@ static __always_inline void trace_v6(struct pt_regs *ctx, pid_t pid,
 			   __sk_common.skc_v6_daddr.in6_u.u6_addr32);
 	event.dport = dport;
 	event.sport = BPF_CORE_READ(sk, __sk_common.skc_num);
+	event.cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
 	;
 	bpf_get_current_comm(event.task, sizeof(event.task));
 	event.timestamp = bpf_ktime_get_boot_ns();
@@ -286,6 +288,7 @@ static __always_inline int handle_tcp_rcv_state_process(void *ctx,
 	event.pid = piddatap->pid;
 	event.mntns_id = piddatap->mntns_id;
 	event.sport = BPF_CORE_READ(sk, __sk_common.skc_num);
+	event.cookie = BPF_CORE_READ(sk, __sk_common.skc_cookie.counter);
 	event.dport = BPF_CORE_READ(sk, __sk_common.skc_dport);
 	event.af = BPF_CORE_READ(sk, __sk_common.skc_family);
 	if (event.af == AF_INET) {
@@ -42,6 +42,7 @@ struct event {
 	__u16 sport;
 	__u64 mntns_id;
 	__u64 latency;
+	__u64 cookie;
 };
 
 #endif /* __TCPCONNECT_H */
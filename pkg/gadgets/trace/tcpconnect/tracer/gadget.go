@@ -46,7 +46,7 @@ func (g *GadgetDesc) Description() string {
 }
 
 func (g *GadgetDesc) ParamDescs() params.ParamDescs {
-	return params.ParamDescs{
+	p := params.ParamDescs{
 		{
 			Key:          ParamMin,
 			Title:        "min",
@@ -62,6 +62,9 @@ func (g *GadgetDesc) ParamDescs() params.ParamDescs {
 			TypeHint:     params.TypeBool,
 		},
 	}
+	p.Add(gadgets.UserFilterParams()...)
+	p.Add(gadgets.SamplingParams()...)
+	return p
 }
 
 func (g *GadgetDesc) Parser() parser.Parser {
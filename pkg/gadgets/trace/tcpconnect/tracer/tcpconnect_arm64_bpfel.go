@@ -26,6 +26,7 @@ type tcpconnectEvent struct {
 	_         [6]byte
 	MntnsId   uint64
 	Latency   uint64
+	Cookie    uint64
 }
 
 type tcpconnectIpv4FlowKey struct {
@@ -25,11 +25,12 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
+	log "github.com/sirupsen/logrus"
 
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/tcpconnect/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/socketcookie"
 	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 )
 
@@ -39,6 +40,9 @@ type Config struct {
 	MountnsMap       *ebpf.Map
 	CalculateLatency bool
 	MinLatency       time.Duration
+	UserFilter       gadgets.UserFilter
+	PerfBufferPages  uint32
+	Sampling         gadgets.Sampling
 }
 
 type Tracer struct {
@@ -53,7 +57,8 @@ type Tracer struct {
 	v6ExitLink             link.Link
 	tcpDestroySockLink     link.Link
 	tcpRvcStateProcessLink link.Link
-	reader                 *perf.Reader
+	reader                 *gadgets.BufferReader
+	cookieAssigner         *socketcookie.Assigner
 }
 
 func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
@@ -89,6 +94,11 @@ func (t *Tracer) close() {
 	t.tcpDestroySockLink = gadgets.CloseLink(t.tcpDestroySockLink)
 	t.tcpRvcStateProcessLink = gadgets.CloseLink(t.tcpRvcStateProcessLink)
 
+	if t.cookieAssigner != nil {
+		t.cookieAssigner.Close()
+		t.cookieAssigner = nil
+	}
+
 	t.objs.Close()
 }
 
@@ -100,8 +110,11 @@ func (t *Tracer) install() error {
 	}
 
 	consts := map[string]interface{}{
-		"targ_min_latency_ns": t.config.MinLatency,
-		"calculate_latency":   t.config.CalculateLatency,
+		"targ_min_latency_ns":          t.config.MinLatency,
+		"calculate_latency":            t.config.CalculateLatency,
+		"filter_uid":                   t.config.UserFilter.TargetUID(),
+		gadgets.SampleRateName:         t.config.Sampling.Rate,
+		gadgets.MaxEventsPerSecondName: t.config.Sampling.MaxEventsPerSecond,
 	}
 
 	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &t.objs); err != nil {
@@ -140,9 +153,15 @@ func (t *Tracer) install() error {
 		}
 	}
 
-	reader, err := perf.NewReader(t.objs.tcpconnectMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	// The flow IDs are left empty when the cookies can't be assigned
+	t.cookieAssigner, err = socketcookie.NewAssigner()
+	if err != nil {
+		log.Warnf("tcpconnect: flow IDs won't be available: %v", err)
+	}
+
+	reader, err := gadgets.NewBufferReader(t.objs.tcpconnectMaps.Events, t.config.PerfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -154,10 +173,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -175,6 +195,10 @@ func (t *Tracer) run() {
 
 		bpfEvent := (*tcpconnectEvent)(unsafe.Pointer(&record.RawSample[0]))
 
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
 		ipversion := gadgets.IPVerFromAF(bpfEvent.Af)
 
 		event := types.Event{
@@ -203,6 +227,7 @@ func (t *Tracer) run() {
 			},
 			IPVersion: ipversion,
 			Latency:   time.Duration(int64(bpfEvent.Latency)),
+			FlowID:    bpfEvent.Cookie,
 		}
 
 		if t.enricher != nil {
@@ -219,6 +244,9 @@ func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
 	params := gadgetCtx.GadgetParams()
 	t.config.CalculateLatency = params.Get(ParamLatency).AsBool()
 	t.config.MinLatency = params.Get(ParamMin).AsDuration()
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(params)
+	t.config.UserFilter = gadgets.UserFilterFromParams(params)
+	t.config.Sampling = gadgets.SamplingFromParams(params)
 
 	defer t.close()
 	if err := t.install(); err != nil {
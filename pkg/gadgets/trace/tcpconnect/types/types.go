@@ -15,6 +15,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
@@ -35,6 +36,10 @@ type Event struct {
 	DstEndpoint eventtypes.L4Endpoint `json:"dst,omitempty" column:"dst"`
 
 	Latency time.Duration `json:"latency,omitempty" column:"latency,minWidth:8,align:right,order:4000" columnTags:"param:latency"`
+
+	// FlowID is the cookie of the socket. It's the same in the events of
+	// other gadgets about the same connection.
+	FlowID uint64 `json:"flowID,omitempty" column:"flowid,hide"`
 }
 
 func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
@@ -77,3 +82,27 @@ func Base(ev eventtypes.Event) *Event {
 		Event: ev,
 	}
 }
+
+// OTelSpan returns a span for the connection. It lasts until the connection
+// was established when the latency is calculated.
+func (e *Event) OTelSpan() *eventtypes.Span {
+	end := time.Unix(0, int64(e.Timestamp))
+	span := &eventtypes.Span{
+		Name:  "TCP connect",
+		Kind:  eventtypes.SpanKindClient,
+		Start: end.Add(-e.Latency),
+		End:   end,
+		Attributes: map[string]any{
+			"server.address":    e.DstEndpoint.Addr,
+			"server.port":       e.DstEndpoint.Port,
+			"client.address":    e.SrcEndpoint.Addr,
+			"client.port":       e.SrcEndpoint.Port,
+			"network.transport": "tcp",
+			"network.type":      fmt.Sprintf("ipv%d", e.IPVersion),
+			"process.pid":       e.Pid,
+			"process.command":   e.Comm,
+		},
+	}
+	e.AddSpanAttributes(span.Attributes)
+	return span
+}
@@ -139,6 +139,12 @@ int ig_tcpdrop(struct trace_event_raw_kfree_skb *ctx)
 {
 	struct sk_buff *skb = ctx->skbaddr;
 	struct sock *sk = BPF_CORE_READ(skb, sk);
+
+	// The reason of the drops is only given since Linux 5.17. The tcp_drop
+	// kprobe below is used on older kernels.
+	if (!bpf_core_field_exists(ctx->reason))
+		return 0;
+
 	int reason = ctx->reason;
 
 	// If enum value was not found, bpf_core_enum_value returns 0.
@@ -156,4 +162,11 @@ int ig_tcpdrop(struct trace_event_raw_kfree_skb *ctx)
 	return 0;
 }
 
+// Older kernels drop the TCP packets with tcp_drop(), without giving a reason
+SEC("kprobe/tcp_drop")
+int BPF_KPROBE(ig_tcp_drop, struct sock *sk, struct sk_buff *skb)
+{
+	return __trace_tcp_drop(ctx, sk, skb, 0);
+}
+
 char LICENSE[] SEC("license") = "GPL";
@@ -51,3 +51,12 @@ func TestDropReasons(t *testing.T) {
 		t.Fatalf("Expected error, got: %q", str)
 	}
 }
+
+func TestDropReasonsNotAvailable(t *testing.T) {
+	// The tcp_drop kprobe is used when the drop reasons can't be loaded
+	tracer := &Tracer{}
+
+	str, err := tracer.lookupDropReason(0)
+	require.Nil(t, err, "unexpected error looking up drop reason: %v", err)
+	require.Equal(t, "NOT_SPECIFIED", str)
+}
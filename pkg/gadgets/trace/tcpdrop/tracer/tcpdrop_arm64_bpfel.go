@@ -83,6 +83,7 @@ type tcpdropSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type tcpdropProgramSpecs struct {
+	IgTcpDrop *ebpf.ProgramSpec `ebpf:"ig_tcp_drop"`
 	IgTcpdrop *ebpf.ProgramSpec `ebpf:"ig_tcpdrop"`
 }
 
@@ -128,11 +129,13 @@ func (m *tcpdropMaps) Close() error {
 //
 // It can be passed to loadTcpdropObjects or ebpf.CollectionSpec.LoadAndAssign.
 type tcpdropPrograms struct {
+	IgTcpDrop *ebpf.Program `ebpf:"ig_tcp_drop"`
 	IgTcpdrop *ebpf.Program `ebpf:"ig_tcpdrop"`
 }
 
 func (p *tcpdropPrograms) Close() error {
 	return _TcpdropClose(
+		p.IgTcpDrop,
 		p.IgTcpdrop,
 	)
 }
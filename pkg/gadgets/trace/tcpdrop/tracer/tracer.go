@@ -26,7 +26,6 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
@@ -44,9 +43,11 @@ type Tracer struct {
 
 	eventCallback func(*types.Event)
 
-	objs         tcpdropObjects
-	kfreeSkbLink link.Link
-	reader       *perf.Reader
+	objs            tcpdropObjects
+	kfreeSkbLink    link.Link
+	tcpDropLink     link.Link
+	reader          *gadgets.BufferReader
+	perfBufferPages uint32
 }
 
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
@@ -54,6 +55,8 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 }
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.perfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
@@ -79,6 +82,7 @@ func (t *Tracer) SetSocketEnricherMap(m *ebpf.Map) {
 
 func (t *Tracer) close() {
 	t.kfreeSkbLink = gadgets.CloseLink(t.kfreeSkbLink)
+	t.tcpDropLink = gadgets.CloseLink(t.tcpDropLink)
 
 	if t.reader != nil {
 		t.reader.Close()
@@ -93,12 +97,12 @@ func (t *Tracer) loadDropReasons() error {
 		return fmt.Errorf("loading kernel spec: %w", err)
 	}
 
-	t.dropReasons = make(map[int]string)
 	enum := &btf.Enum{}
 	err = btfSpec.TypeByName("skb_drop_reason", &enum)
 	if err != nil {
 		return fmt.Errorf("looking up skb_drop_reason enum: %w", err)
 	}
+	t.dropReasons = make(map[int]string)
 	for _, v := range enum.Values {
 		str := v.Name
 		str = strings.TrimPrefix(str, "SKB_DROP_REASON_")
@@ -111,16 +115,17 @@ func (t *Tracer) loadDropReasons() error {
 }
 
 func (t *Tracer) install() error {
-	err := t.loadDropReasons()
-	if err != nil {
-		return err
-	}
+	// Kernels older than 5.17 don't give the reason of the drops: tcp_drop()
+	// is traced instead of the kfree_skb tracepoint when the reasons can't be
+	// loaded.
+	reasonsErr := t.loadDropReasons()
 
 	spec, err := loadTcpdrop()
 	if err != nil {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
+	gadgets.RewriteEventOutput(spec, gadgets.Sampling{})
 	gadgets.FixBpfKtimeGetBootNs(spec.Programs)
 
 	opts := ebpf.CollectionOptions{
@@ -137,14 +142,21 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
-	t.kfreeSkbLink, err = link.Tracepoint("skb", "kfree_skb", t.objs.IgTcpdrop, nil)
-	if err != nil {
-		return fmt.Errorf("attaching tracepoint kfree_skb: %w", err)
+	if reasonsErr == nil {
+		t.kfreeSkbLink, err = link.Tracepoint("skb", "kfree_skb", t.objs.IgTcpdrop, nil)
+		if err != nil {
+			return fmt.Errorf("attaching tracepoint kfree_skb: %w", err)
+		}
+	} else {
+		t.tcpDropLink, err = link.Kprobe("tcp_drop", t.objs.IgTcpDrop, nil)
+		if err != nil {
+			return fmt.Errorf("attaching kprobe tcp_drop (drop reasons not available: %s): %w", reasonsErr, err)
+		}
 	}
 
-	reader, err := perf.NewReader(t.objs.tcpdropMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.tcpdropMaps.Events, t.perfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -156,10 +168,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
@@ -223,6 +236,10 @@ func (t *Tracer) run() {
 }
 
 func (t *Tracer) lookupDropReason(reason int) (string, error) {
+	// tcp_drop() doesn't give any reason
+	if t.dropReasons == nil {
+		return "NOT_SPECIFIED", nil
+	}
 	if ret, ok := t.dropReasons[reason]; ok {
 		return ret, nil
 	}
@@ -24,7 +24,6 @@ import (
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
-	"github.com/cilium/ebpf/perf"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfgen"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
@@ -45,7 +44,8 @@ type Tracer struct {
 	objs              tcpretransObjects
 	retransmitSkbLink link.Link
 	lossSkbLink       link.Link
-	reader            *perf.Reader
+	reader            *gadgets.BufferReader
+	perfBufferPages   uint32
 }
 
 func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
@@ -53,6 +53,8 @@ func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
 }
 
 func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.perfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+
 	defer t.close()
 	if err := t.install(); err != nil {
 		return fmt.Errorf("installing tracer: %w", err)
@@ -95,6 +97,7 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("loading ebpf program: %w", err)
 	}
 
+	gadgets.RewriteEventOutput(spec, gadgets.Sampling{})
 	gadgets.FixBpfKtimeGetBootNs(spec.Programs)
 
 	opts := ebpf.CollectionOptions{
@@ -121,9 +124,9 @@ func (t *Tracer) install() error {
 		return fmt.Errorf("attaching kprobe tcp_send_loss_probe: %w", err)
 	}
 
-	reader, err := perf.NewReader(t.objs.tcpretransMaps.Events, gadgets.PerfBufferPages*os.Getpagesize())
+	reader, err := gadgets.NewBufferReader(t.objs.tcpretransMaps.Events, t.perfBufferPages)
 	if err != nil {
-		return fmt.Errorf("creating perf ring buffer: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 	t.reader = reader
 
@@ -135,10 +138,11 @@ func (t *Tracer) install() error {
 }
 
 func (t *Tracer) run() {
+	var record gadgets.BufferRecord
 	for {
-		record, err := t.reader.Read()
+		err := t.reader.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				// nothing to do, we're done
 				return
 			}
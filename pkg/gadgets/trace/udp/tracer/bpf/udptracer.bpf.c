@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+#include <vmlinux.h>
+
+#include <bpf/bpf_helpers.h>
+#include <bpf/bpf_core_read.h>
+#include <bpf/bpf_tracing.h>
+#include <bpf/bpf_endian.h>
+#include "udptracer.h"
+#include <gadget/mntns_filter.h>
+
+#define GADGET_NO_BUF_RESERVE
+#include <gadget/buffer.h>
+
+const volatile uid_t filter_uid = -1;
+
+/* Define here, because there are conflicts with include files */
+#define AF_INET 2
+#define AF_INET6 10
+
+// we need this to make sure the compiler doesn't remove our struct
+const struct event *unusedevent __attribute__((unused));
+
+struct sock_args {
+	struct sock *sk;
+	struct msghdr *msg;
+};
+
+/*
+ * The number of bytes sent or received is only known when the functions
+ * return, and unbound sockets get their port during udp_sendmsg(): the
+ * arguments are kept in this map until then.
+ */
+struct {
+	__uint(type, BPF_MAP_TYPE_HASH);
+	__uint(max_entries, MAX_ENTRIES);
+	__type(key, u32);
+	__type(value, struct sock_args);
+} sock_args SEC(".maps");
+
+/* Turned into a perf event array on kernels without ring buffers */
+struct {
+	__uint(type, BPF_MAP_TYPE_RINGBUF);
+	__uint(max_entries, 1024 * 1024);
+} events SEC(".maps");
+
+/*
+ * The peer of unconnected sockets isn't in the socket but in msg_name: the
+ * destination given to sendmsg(), or the source of the datagram once
+ * recvmsg() returned.
+ */
+static __always_inline void read_peer(struct event *event, struct msghdr *msg)
+{
+	struct sockaddr_in6 *sin6;
+	struct sockaddr_in *sin;
+	__u16 family;
+	void *name;
+
+	name = BPF_CORE_READ(msg, msg_name);
+	if (!name)
+		return;
+
+	bpf_probe_read_kernel(&family, sizeof(family), name);
+	if (family != event->af)
+		return;
+
+	if (family == AF_INET) {
+		sin = name;
+		bpf_probe_read_kernel(&event->dport, sizeof(event->dport),
+				      &sin->sin_port);
+		bpf_probe_read_kernel(&event->daddr_v4, sizeof(event->daddr_v4),
+				      &sin->sin_addr.s_addr);
+	} else {
+		sin6 = name;
+		bpf_probe_read_kernel(&event->dport, sizeof(event->dport),
+				      &sin6->sin6_port);
+		bpf_probe_read_kernel(&event->daddr, sizeof(event->daddr),
+				      &sin6->sin6_addr.in6_u.u6_addr8);
+	}
+}
+
+static __always_inline int enter_udp(struct sock *sk, struct msghdr *msg)
+{
+	__u32 tid = bpf_get_current_pid_tgid();
+	__u32 uid = bpf_get_current_uid_gid();
+	struct sock_args args = {
+		.sk = sk,
+		.msg = msg,
+	};
+
+	if (gadget_should_discard_mntns_id(gadget_get_mntns_id()))
+		return 0;
+
+	if (filter_uid != (uid_t)-1 && uid != filter_uid)
+		return 0;
+
+	bpf_map_update_elem(&sock_args, &tid, &args, BPF_ANY);
+	return 0;
+}
+
+static __always_inline int exit_udp(struct pt_regs *ctx, int ret,
+				    __u16 family, enum event_type type)
+{
+	__u64 pid_tgid = bpf_get_current_pid_tgid();
+	__u32 tid = pid_tgid;
+	__u64 uid_gid;
+	struct sock_args *args;
+	struct event event = {};
+	struct sock *sk;
+
+	args = bpf_map_lookup_elem(&sock_args, &tid);
+	if (!args)
+		return 0;
+
+	sk = args->sk;
+
+	/*
+	 * udpv6_sendmsg() calls udp_sendmsg() for IPv4-mapped destinations:
+	 * the datagram is reported when the outer function returns.
+	 */
+	event.af = BPF_CORE_READ(sk, __sk_common.skc_family);
+	if (event.af != family)
+		return 0;
+
+	bpf_map_delete_elem(&sock_args, &tid);
+
+	if (ret <= 0)
+		return 0;
+
+	uid_gid = bpf_get_current_uid_gid();
+
+	event.mntns_id = gadget_get_mntns_id();
+	event.pid = pid_tgid >> 32;
+	event.uid = (__u32)uid_gid;
+	event.gid = (__u32)(uid_gid >> 32);
+	event.len = ret;
+	event.type = type;
+	event.dport = BPF_CORE_READ(sk, __sk_common.skc_dport);
+	event.sport = bpf_htons(BPF_CORE_READ(sk, __sk_common.skc_num));
+
+	if (family == AF_INET) {
+		BPF_CORE_READ_INTO(&event.saddr_v4, sk,
+				   __sk_common.skc_rcv_saddr);
+		BPF_CORE_READ_INTO(&event.daddr_v4, sk, __sk_common.skc_daddr);
+	} else {
+		BPF_CORE_READ_INTO(&event.saddr, sk,
+				   __sk_common.skc_v6_rcv_saddr);
+		BPF_CORE_READ_INTO(&event.daddr, sk, __sk_common.skc_v6_daddr);
+	}
+
+	if (args->msg)
+		read_peer(&event, args->msg);
+
+	bpf_get_current_comm(&event.task, sizeof(event.task));
+	event.timestamp = bpf_ktime_get_boot_ns();
+
+	gadget_output_buf(ctx, &events, &event, sizeof(event));
+
+	return 0;
+}
+
+/* Attached to udp_sendmsg, udpv6_sendmsg, udp_recvmsg and udpv6_recvmsg */
+SEC("kprobe/udp_sendmsg")
+int BPF_KPROBE(ig_udp_e, struct sock *sk, struct msghdr *msg)
+{
+	return enter_udp(sk, msg);
+}
+
+SEC("kretprobe/udp_sendmsg")
+int BPF_KRETPROBE(ig_udp_send_x, int ret)
+{
+	return exit_udp(ctx, ret, AF_INET, UDP_EVENT_TYPE_SEND);
+}
+
+SEC("kretprobe/udpv6_sendmsg")
+int BPF_KRETPROBE(ig_udp6_send_x, int ret)
+{
+	return exit_udp(ctx, ret, AF_INET6, UDP_EVENT_TYPE_SEND);
+}
+
+SEC("kretprobe/udp_recvmsg")
+int BPF_KRETPROBE(ig_udp_recv_x, int ret)
+{
+	return exit_udp(ctx, ret, AF_INET, UDP_EVENT_TYPE_RECV);
+}
+
+SEC("kretprobe/udpv6_recvmsg")
+int BPF_KRETPROBE(ig_udp6_recv_x, int ret)
+{
+	return exit_udp(ctx, ret, AF_INET6, UDP_EVENT_TYPE_RECV);
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: GPL-2.0
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#ifndef __UDPTRACER_H
+#define __UDPTRACER_H
+
+/* The maximum number of items in maps */
+#define MAX_ENTRIES 8192
+
+#define TASK_COMM_LEN 16
+
+enum event_type : u8 {
+	UDP_EVENT_TYPE_SEND,
+	UDP_EVENT_TYPE_RECV,
+};
+
+/*
+ * saddr and sport are the local end of the socket, daddr and dport the remote
+ * one, whether the datagram was sent or received.
+ */
+struct event {
+	union {
+		__u8 saddr[16];
+		unsigned __int128 saddr_v6;
+		__u32 saddr_v4;
+	};
+	union {
+		__u8 daddr[16];
+		unsigned __int128 daddr_v6;
+		__u32 daddr_v4;
+	};
+	__u8 task[TASK_COMM_LEN];
+	__u64 mntns_id;
+	__u64 timestamp;
+	__u32 pid;
+	__u32 uid;
+	__u32 gid;
+	__u32 len;
+	__u16 af; // AF_INET or AF_INET6
+	__u16 dport;
+	__u16 sport;
+	enum event_type type;
+};
+
+#endif /* __UDPTRACER_H */
@@ -0,0 +1,277 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+package tracer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/udp/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target $TARGET -cc clang -cflags ${CFLAGS} -no-global-types -type event -type event_type udptracer ./bpf/udptracer.bpf.c -- -I./bpf/
+
+type Config struct {
+	MountnsMap      *ebpf.Map
+	UserFilter      gadgets.UserFilter
+	PerfBufferPages uint32
+}
+
+type Tracer struct {
+	config        *Config
+	enricher      gadgets.DataEnricherByMntNs
+	eventCallback func(*types.Event)
+
+	objs udptracerObjects
+
+	udpSendmsgEnterLink   link.Link
+	udpSendmsgExitLink    link.Link
+	udpv6SendmsgEnterLink link.Link
+	udpv6SendmsgExitLink  link.Link
+	udpRecvmsgEnterLink   link.Link
+	udpRecvmsgExitLink    link.Link
+	udpv6RecvmsgEnterLink link.Link
+	udpv6RecvmsgExitLink  link.Link
+
+	reader *gadgets.BufferReader
+}
+
+func NewTracer(config *Config, enricher gadgets.DataEnricherByMntNs,
+	eventCallback func(*types.Event),
+) (*Tracer, error) {
+	t := &Tracer{
+		config:        config,
+		enricher:      enricher,
+		eventCallback: eventCallback,
+	}
+
+	if err := t.install(); err != nil {
+		t.close()
+		return nil, err
+	}
+
+	go t.run()
+
+	return t, nil
+}
+
+// Stop stops the tracer
+// TODO: Remove after refactoring
+func (t *Tracer) Stop() {
+	t.close()
+}
+
+func (t *Tracer) close() {
+	t.udpSendmsgEnterLink = gadgets.CloseLink(t.udpSendmsgEnterLink)
+	t.udpSendmsgExitLink = gadgets.CloseLink(t.udpSendmsgExitLink)
+	t.udpv6SendmsgEnterLink = gadgets.CloseLink(t.udpv6SendmsgEnterLink)
+	t.udpv6SendmsgExitLink = gadgets.CloseLink(t.udpv6SendmsgExitLink)
+	t.udpRecvmsgEnterLink = gadgets.CloseLink(t.udpRecvmsgEnterLink)
+	t.udpRecvmsgExitLink = gadgets.CloseLink(t.udpRecvmsgExitLink)
+	t.udpv6RecvmsgEnterLink = gadgets.CloseLink(t.udpv6RecvmsgEnterLink)
+	t.udpv6RecvmsgExitLink = gadgets.CloseLink(t.udpv6RecvmsgExitLink)
+
+	if t.reader != nil {
+		t.reader.Close()
+	}
+
+	t.objs.Close()
+}
+
+func (t *Tracer) install() error {
+	spec, err := loadUdptracer()
+	if err != nil {
+		return fmt.Errorf("loading ebpf program: %w", err)
+	}
+
+	consts := map[string]interface{}{
+		"filter_uid": t.config.UserFilter.TargetUID(),
+	}
+
+	if err := gadgets.LoadeBPFSpec(t.config.MountnsMap, spec, consts, &t.objs); err != nil {
+		return fmt.Errorf("loading ebpf spec: %w", err)
+	}
+
+	// The same program saves the arguments of the four functions
+	t.udpSendmsgEnterLink, err = link.Kprobe("udp_sendmsg", t.objs.IgUdpE, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kprobe: %w", err)
+	}
+
+	t.udpSendmsgExitLink, err = link.Kretprobe("udp_sendmsg", t.objs.IgUdpSendX, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kretprobe: %w", err)
+	}
+
+	t.udpv6SendmsgEnterLink, err = link.Kprobe("udpv6_sendmsg", t.objs.IgUdpE, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kprobe: %w", err)
+	}
+
+	t.udpv6SendmsgExitLink, err = link.Kretprobe("udpv6_sendmsg", t.objs.IgUdp6SendX, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kretprobe: %w", err)
+	}
+
+	t.udpRecvmsgEnterLink, err = link.Kprobe("udp_recvmsg", t.objs.IgUdpE, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kprobe: %w", err)
+	}
+
+	t.udpRecvmsgExitLink, err = link.Kretprobe("udp_recvmsg", t.objs.IgUdpRecvX, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kretprobe: %w", err)
+	}
+
+	t.udpv6RecvmsgEnterLink, err = link.Kprobe("udpv6_recvmsg", t.objs.IgUdpE, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kprobe: %w", err)
+	}
+
+	t.udpv6RecvmsgExitLink, err = link.Kretprobe("udpv6_recvmsg", t.objs.IgUdp6RecvX, nil)
+	if err != nil {
+		return fmt.Errorf("attaching kretprobe: %w", err)
+	}
+
+	reader, err := gadgets.NewBufferReader(t.objs.udptracerMaps.Events, t.config.PerfBufferPages)
+	if err != nil {
+		return fmt.Errorf("creating buffer reader: %w", err)
+	}
+	t.reader = reader
+
+	if err := gadgets.FreezeMaps(t.objs.udptracerMaps.Events); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (t *Tracer) run() {
+	var record gadgets.BufferRecord
+	for {
+		err := t.reader.ReadInto(&record)
+		if err != nil {
+			if errors.Is(err, os.ErrClosed) {
+				// nothing to do, we're done
+				return
+			}
+
+			msg := fmt.Sprintf("Error reading perf ring buffer: %s", err)
+			t.eventCallback(types.Base(eventtypes.Err(msg)))
+			return
+		}
+
+		if record.LostSamples > 0 {
+			msg := fmt.Sprintf("lost %d samples", record.LostSamples)
+			t.eventCallback(types.Base(eventtypes.Warn(msg)))
+			continue
+		}
+
+		bpfEvent := (*udptracerEvent)(unsafe.Pointer(&record.RawSample[0]))
+
+		if !t.config.UserFilter.Matches(bpfEvent.Uid, bpfEvent.Gid) {
+			continue
+		}
+
+		ipversion := gadgets.IPVerFromAF(bpfEvent.Af)
+
+		event := types.Event{
+			Event: eventtypes.Event{
+				Type:      eventtypes.NORMAL,
+				Timestamp: gadgets.WallTimeFromBootTime(bpfEvent.Timestamp),
+			},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: bpfEvent.MntnsId},
+			Pid:           bpfEvent.Pid,
+			Uid:           bpfEvent.Uid,
+			Gid:           bpfEvent.Gid,
+			Comm:          gadgets.FromCString(bpfEvent.Task[:]),
+			LocalEndpoint: eventtypes.L4Endpoint{
+				L3Endpoint: eventtypes.L3Endpoint{
+					Addr:    gadgets.IPStringFromBytes(bpfEvent.Saddr, ipversion),
+					Version: uint8(ipversion),
+				},
+				Port: gadgets.Htons(bpfEvent.Sport),
+			},
+			RemoteEndpoint: eventtypes.L4Endpoint{
+				L3Endpoint: eventtypes.L3Endpoint{
+					Addr:    gadgets.IPStringFromBytes(bpfEvent.Daddr, ipversion),
+					Version: uint8(ipversion),
+				},
+				Port: gadgets.Htons(bpfEvent.Dport),
+			},
+			IPVersion: ipversion,
+			Len:       bpfEvent.Len,
+		}
+
+		switch bpfEvent.Type {
+		case udptracerEventTypeUDP_EVENT_TYPE_SEND:
+			event.Operation = "send"
+		case udptracerEventTypeUDP_EVENT_TYPE_RECV:
+			event.Operation = "recv"
+		}
+
+		if t.enricher != nil {
+			t.enricher.EnrichByMntNs(&event.CommonData, event.MountNsID)
+		}
+
+		t.eventCallback(&event)
+	}
+}
+
+// --- Registry changes
+
+func (t *Tracer) Run(gadgetCtx gadgets.GadgetContext) error {
+	t.config.PerfBufferPages = gadgets.PerfBufferPagesFromParams(gadgetCtx.GadgetParams())
+	t.config.UserFilter = gadgets.UserFilterFromParams(gadgetCtx.GadgetParams())
+
+	defer t.close()
+	if err := t.install(); err != nil {
+		return fmt.Errorf("installing tracer: %w", err)
+	}
+
+	go t.run()
+	gadgetcontext.WaitForTimeoutOrDone(gadgetCtx)
+
+	return nil
+}
+
+func (t *Tracer) SetMountNsMap(mountnsMap *ebpf.Map) {
+	t.config.MountnsMap = mountnsMap
+}
+
+func (t *Tracer) SetEventHandler(handler any) {
+	nh, ok := handler.(func(ev *types.Event))
+	if !ok {
+		panic("event handler invalid")
+	}
+	t.eventCallback = nh
+}
+
+func (g *GadgetDesc) NewInstance() (gadgets.Gadget, error) {
+	tracer := &Tracer{
+		config: &Config{},
+	}
+	return tracer, nil
+}
@@ -0,0 +1,422 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package tracer_test
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/udp/tracer"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/udp/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// payload is sent by the client to the server in all the tests
+var payload = []byte("inspektor-gadget")
+
+func TestUDPTracerCreate(t *testing.T) {
+	t.Parallel()
+
+	utilstest.RequireRoot(t)
+
+	tracer := createTracer(t, &tracer.Config{}, func(*types.Event) {})
+	if tracer == nil {
+		t.Fatal("Returned tracer was nil")
+	}
+}
+
+func TestUDPTracerStopIdempotent(t *testing.T) {
+	t.Parallel()
+
+	utilstest.RequireRoot(t)
+
+	tracer := createTracer(t, &tracer.Config{}, func(*types.Event) {})
+
+	// Check that a double stop doesn't cause issues
+	tracer.Stop()
+	tracer.Stop()
+}
+
+// ports are the local ports of the client and the server
+type ports struct {
+	client uint16
+	server uint16
+}
+
+func TestUDPTracer(t *testing.T) {
+	t.Parallel()
+
+	utilstest.RequireRoot(t)
+
+	const unprivilegedUID = int(1435)
+	const unprivilegedGID = int(6789)
+
+	// sendEvent and recvEvent are the events of the datagram sent by the
+	// client and received by the server
+	sendEvent := func(info *utilstest.RunnerInfo, ip string, p ports) *types.Event {
+		return &types.Event{
+			Event: eventtypes.Event{
+				Type: eventtypes.NORMAL,
+			},
+			WithMountNsID:  eventtypes.WithMountNsID{MountNsID: info.MountNsID},
+			Operation:      "send",
+			Pid:            uint32(info.Pid),
+			Uid:            uint32(info.Uid),
+			Gid:            uint32(info.Gid),
+			Comm:           info.Comm,
+			IPVersion:      ipVersion(ip),
+			LocalEndpoint:  endpoint(ip, p.client),
+			RemoteEndpoint: endpoint(ip, p.server),
+			Len:            uint32(len(payload)),
+		}
+	}
+	recvEvent := func(info *utilstest.RunnerInfo, ip string, p ports) *types.Event {
+		return &types.Event{
+			Event: eventtypes.Event{
+				Type: eventtypes.NORMAL,
+			},
+			WithMountNsID: eventtypes.WithMountNsID{MountNsID: info.MountNsID},
+			Operation:     "recv",
+			Pid:           uint32(info.Pid),
+			Uid:           uint32(info.Uid),
+			Gid:           uint32(info.Gid),
+			Comm:          info.Comm,
+			IPVersion:     ipVersion(ip),
+			// The remote end of a received datagram is its sender
+			LocalEndpoint:  endpoint(ip, p.server),
+			RemoteEndpoint: endpoint(ip, p.client),
+			Len:            uint32(len(payload)),
+		}
+	}
+
+	type testDefinition struct {
+		getTracerConfig func(info *utilstest.RunnerInfo) *tracer.Config
+		runnerConfig    *utilstest.RunnerConfig
+		generateEvent   func() (ports, error)
+		validateEvent   func(t *testing.T, info *utilstest.RunnerInfo, p ports, events []types.Event)
+	}
+
+	for name, test := range map[string]testDefinition{
+		"captures_all_events_with_no_filters_configured": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{}
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, p ports) *types.Event {
+				return sendEvent(info, "127.0.0.1", p)
+			}),
+		},
+		"captures_no_events_with_no_matching_filter": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, 0),
+				}
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectNoEvent[types.Event, ports],
+		},
+		"send_ipv4": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, p ports) *types.Event {
+				return sendEvent(info, "127.0.0.1", p)
+			}),
+		},
+		"recv_ipv4": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, p ports) *types.Event {
+				return recvEvent(info, "127.0.0.1", p)
+			}),
+		},
+		"send_ipv6": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			generateEvent: sendAndRecvFn("::1"),
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, p ports) *types.Event {
+				return sendEvent(info, "::1", p)
+			}),
+		},
+		"recv_ipv6": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			generateEvent: sendAndRecvFn("::1"),
+			validateEvent: utilstest.ExpectAtLeastOneEvent(func(info *utilstest.RunnerInfo, p ports) *types.Event {
+				return recvEvent(info, "::1", p)
+			}),
+		},
+		"send_and_recv": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, p ports, events []types.Event) {
+				if len(events) != 2 {
+					t.Fatalf("Wrong number of events received %d, expected 2", len(events))
+				}
+			},
+		},
+		"uid_filter_match": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				uid := uint32(unprivilegedUID)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{UID: &uid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, p ports, events []types.Event) {
+				if len(events) != 2 {
+					t.Fatalf("Wrong number of events received %d, expected 2", len(events))
+				}
+			},
+		},
+		"uid_filter_no_match": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				uid := uint32(unprivilegedUID + 1)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{UID: &uid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectNoEvent[types.Event, ports],
+		},
+		"gid_filter_no_match": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				gid := uint32(unprivilegedGID + 1)
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+					UserFilter: gadgets.UserFilter{GID: &gid},
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: utilstest.ExpectNoEvent[types.Event, ports],
+		},
+		"event_has_UID_and_GID_of_user_generating_event": {
+			getTracerConfig: func(info *utilstest.RunnerInfo) *tracer.Config {
+				return &tracer.Config{
+					MountnsMap: utilstest.CreateMntNsFilterMap(t, info.MountNsID),
+				}
+			},
+			runnerConfig: &utilstest.RunnerConfig{
+				Uid: unprivilegedUID,
+				Gid: unprivilegedGID,
+			},
+			generateEvent: sendAndRecvFn("127.0.0.1"),
+			validateEvent: func(t *testing.T, info *utilstest.RunnerInfo, _ ports, events []types.Event) {
+				if len(events) == 0 {
+					t.Fatalf("Events expected")
+				}
+
+				for _, event := range events {
+					utilstest.Equal(t, uint32(info.Uid), event.Uid,
+						"Event has bad UID")
+
+					utilstest.Equal(t, uint32(info.Gid), event.Gid,
+						"Event has bad GID")
+				}
+			},
+		},
+	} {
+		test := test
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			events := []types.Event{}
+			eventCallback := func(event *types.Event) {
+				// normalize
+				event.Timestamp = 0
+
+				mu.Lock()
+				events = append(events, *event)
+				mu.Unlock()
+			}
+
+			runner := utilstest.NewRunnerWithTest(t, test.runnerConfig)
+
+			createTracer(t, test.getTracerConfig(runner.Info), eventCallback)
+
+			var p ports
+
+			utilstest.RunWithRunner(t, runner, func() error {
+				var err error
+				p, err = test.generateEvent()
+				return err
+			})
+
+			// Give some time for the tracer to capture the events
+			time.Sleep(100 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			test.validateEvent(t, runner.Info, p, events)
+		})
+	}
+}
+
+func createTracer(
+	t *testing.T, config *tracer.Config, callback func(*types.Event),
+) *tracer.Tracer {
+	t.Helper()
+
+	tracer, err := tracer.NewTracer(config, nil, callback)
+	if err != nil {
+		t.Fatalf("Error creating tracer: %s", err)
+	}
+	t.Cleanup(tracer.Stop)
+
+	return tracer
+}
+
+func ipVersion(ip string) int {
+	if net.ParseIP(ip).To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+func endpoint(ip string, port uint16) eventtypes.L4Endpoint {
+	return eventtypes.L4Endpoint{
+		L3Endpoint: eventtypes.L3Endpoint{
+			Addr:    ip,
+			Version: uint8(ipVersion(ip)),
+		},
+		Port: port,
+	}
+}
+
+// sendAndRecvFn returns a function that sends a datagram between two sockets
+// bound to ipStr, and returns their ports. The client socket isn't connected,
+// so the server port is taken from the address given to sendto() and the
+// client port from the one recvfrom() returns.
+func sendAndRecvFn(ipStr string) func() (ports, error) {
+	return func() (ports, error) {
+		var p ports
+
+		server, serverAddr, err := udpSocket(ipStr)
+		if err != nil {
+			return p, err
+		}
+		defer unix.Close(server)
+
+		client, clientAddr, err := udpSocket(ipStr)
+		if err != nil {
+			return p, err
+		}
+		defer unix.Close(client)
+
+		if err := unix.Sendto(client, payload, 0, serverAddr); err != nil {
+			return p, fmt.Errorf("Sendto: %w", err)
+		}
+
+		buf := make([]byte, 64)
+		if _, _, err := unix.Recvfrom(server, buf, 0); err != nil {
+			return p, fmt.Errorf("Recvfrom: %w", err)
+		}
+
+		p.client = sockaddrPort(clientAddr)
+		p.server = sockaddrPort(serverAddr)
+		return p, nil
+	}
+}
+
+// udpSocket creates a UDP socket bound to ipStr on a random port
+func udpSocket(ipStr string) (int, unix.Sockaddr, error) {
+	var sa unix.Sockaddr
+	domain := unix.AF_INET
+
+	ip := net.ParseIP(ipStr)
+	if ip.To4() != nil {
+		sa4 := &unix.SockaddrInet4{}
+		copy(sa4.Addr[:], ip.To4())
+		sa = sa4
+	} else if ip.To16() != nil {
+		sa6 := &unix.SockaddrInet6{}
+		copy(sa6.Addr[:], ip.To16())
+		sa = sa6
+		domain = unix.AF_INET6
+	} else {
+		return -1, nil, fmt.Errorf("invalid IP address")
+	}
+
+	fd, err := unix.Socket(domain, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if err := unix.Bind(fd, sa); err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("Bind: %w", err)
+	}
+
+	bound, err := unix.Getsockname(fd)
+	if err != nil {
+		unix.Close(fd)
+		return -1, nil, fmt.Errorf("Getsockname: %w", err)
+	}
+
+	return fd, bound, nil
+}
+
+func sockaddrPort(sa unix.Sockaddr) uint16 {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		return uint16(sa.Port)
+	case *unix.SockaddrInet6:
+		return uint16(sa.Port)
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+type Event struct {
+	eventtypes.Event
+	eventtypes.WithMountNsID
+
+	Operation string `json:"operation,omitempty" column:"t,width:1,fixed"`
+	Pid       uint32 `json:"pid,omitempty" column:"pid,template:pid"`
+	Uid       uint32 `json:"uid" column:"uid,template:uid,hide"`
+	Gid       uint32 `json:"gid" column:"gid,template:gid,hide"`
+	Comm      string `json:"comm,omitempty" column:"comm,template:comm"`
+	IPVersion int    `json:"ipversion,omitempty" column:"ip,template:ipversion"`
+
+	// LocalEndpoint and RemoteEndpoint are the ends of the socket, whatever
+	// the direction of the datagram. For a received datagram, the remote
+	// end is its sender.
+	LocalEndpoint  eventtypes.L4Endpoint `json:"local,omitempty" column:"local"`
+	RemoteEndpoint eventtypes.L4Endpoint `json:"remote,omitempty" column:"remote"`
+
+	Len uint32 `json:"len" column:"len,minWidth:6,align:right,order:4000"`
+}
+
+func (e *Event) GetEndpoints() []*eventtypes.L3Endpoint {
+	return []*eventtypes.L3Endpoint{&e.LocalEndpoint.L3Endpoint, &e.RemoteEndpoint.L3Endpoint}
+}
+
+func GetColumns() *columns.Columns[Event] {
+	udpColumns := columns.MustCreateColumns[Event]()
+
+	udpColumns.MustSetExtractor("t", func(event *Event) any {
+		operations := map[string]string{
+			"send":    "S",
+			"recv":    "R",
+			"unknown": "U",
+		}
+
+		if op, ok := operations[event.Operation]; ok {
+			return op
+		}
+
+		return "U"
+	})
+
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		udpColumns,
+		columns.Attributes{
+			Name:     "local",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    2000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.LocalEndpoint },
+	)
+	eventtypes.MustAddVirtualL4EndpointColumn(
+		udpColumns,
+		columns.Attributes{
+			Name:     "remote",
+			Visible:  true,
+			Template: "ipaddrport",
+			Order:    3000,
+		},
+		func(e *Event) eventtypes.L4Endpoint { return e.RemoteEndpoint },
+	)
+
+	return udpColumns
+}
+
+func Base(ev eventtypes.Event) *Event {
+	return &Event{
+		Event: ev,
+	}
+}
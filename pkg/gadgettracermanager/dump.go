@@ -0,0 +1,111 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgettracermanager
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+)
+
+const containersDumpHeader = "List of containers:"
+
+// ContainerDump is the state of a container as known by the container
+// collection, with the fields useful to debug missing enrichment
+type ContainerDump struct {
+	ContainerID   string `json:"containerId"`
+	ContainerName string `json:"containerName,omitempty"`
+	RuntimeName   string `json:"runtimeName,omitempty"`
+	Image         string `json:"image,omitempty"`
+	ImageDigest   string `json:"imageDigest,omitempty"`
+	Pid           uint32 `json:"pid"`
+
+	Namespace        string            `json:"namespace,omitempty"`
+	PodName          string            `json:"podName,omitempty"`
+	PodUID           string            `json:"podUID,omitempty"`
+	K8sContainerName string            `json:"k8sContainerName,omitempty"`
+	PodLabels        map[string]string `json:"podLabels,omitempty"`
+
+	Mntns       uint64 `json:"mntns"`
+	Netns       uint64 `json:"netns"`
+	HostNetwork bool   `json:"hostNetwork,omitempty"`
+	CgroupPath  string `json:"cgroupPath,omitempty"`
+	CgroupID    uint64 `json:"cgroupID,omitempty"`
+
+	Source string `json:"source,omitempty"`
+}
+
+func NewContainerDump(c *containercollection.Container) *ContainerDump {
+	return &ContainerDump{
+		ContainerID:      c.Runtime.ContainerID,
+		ContainerName:    c.Runtime.ContainerName,
+		RuntimeName:      string(c.Runtime.RuntimeName),
+		Image:            c.Runtime.ContainerImageName,
+		ImageDigest:      c.Runtime.ContainerImageDigest,
+		Pid:              c.Runtime.ContainerPID,
+		Namespace:        c.K8s.Namespace,
+		PodName:          c.K8s.PodName,
+		PodUID:           c.K8s.PodUID,
+		K8sContainerName: c.K8s.ContainerName,
+		PodLabels:        c.K8s.PodLabels,
+		Mntns:            c.Mntns,
+		Netns:            c.Netns,
+		HostNetwork:      c.HostNetwork,
+		CgroupPath:       c.CgroupPath,
+		CgroupID:         c.CgroupID,
+		Source:           string(c.Source),
+	}
+}
+
+// formatContainersDump returns the containers part of the state dump: a
+// header followed by a JSON document per container
+func formatContainersDump(containers []*ContainerDump) string {
+	var sb strings.Builder
+	sb.WriteString(containersDumpHeader + "\n")
+	for _, c := range containers {
+		b, err := json.Marshal(c)
+		if err != nil {
+			continue
+		}
+		sb.Write(b)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// ParseContainersDump parses the containers part of the state dump
+func ParseContainersDump(dump string) ([]*ContainerDump, error) {
+	var containers []*ContainerDump
+	scanner := bufio.NewScanner(strings.NewReader(dump))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line == containersDumpHeader {
+			continue
+		}
+		c := &ContainerDump{}
+		if err := json.Unmarshal([]byte(line), c); err != nil {
+			return nil, fmt.Errorf("parsing container %q: %w", line, err)
+		}
+		containers = append(containers, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return containers, nil
+}
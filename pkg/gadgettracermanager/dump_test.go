@@ -0,0 +1,67 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgettracermanager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestContainersDump(t *testing.T) {
+	c := &containercollection.Container{
+		Runtime: containercollection.RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				RuntimeName:   types.RuntimeNameContainerd,
+				ContainerID:   "abcdef",
+				ContainerName: "nginx",
+				ContainerPID:  1234,
+			},
+		},
+		K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{
+				Namespace:     "default",
+				PodName:       "nginx-1",
+				ContainerName: "nginx",
+				PodLabels:     map[string]string{"app": "nginx"},
+			},
+		},
+		Mntns:    4026531840,
+		Netns:    4026531992,
+		CgroupID: 42,
+		Source:   containercollection.SourceRuncFanotify,
+	}
+
+	dump := formatContainersDump([]*ContainerDump{NewContainerDump(c), {ContainerID: "host"}})
+	assert.True(t, strings.HasPrefix(dump, "List of containers:\n"))
+
+	// Output of exec with a TTY
+	dump = strings.ReplaceAll(dump, "\n", "\r\n")
+
+	containers, err := ParseContainersDump(dump)
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+	assert.Equal(t, NewContainerDump(c), containers[0])
+	assert.Equal(t, "runc-fanotify", containers[0].Source)
+	assert.Equal(t, "host", containers[1].ContainerID)
+
+	_, err = ParseContainersDump("List of containers:\n{invalid\n")
+	assert.Error(t, err)
+}
@@ -19,20 +19,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"runtime"
 	"sync"
 
 	"github.com/cilium/ebpf"
-	"github.com/cilium/ebpf/rlimit"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	ocispec "github.com/opencontainers/runtime-spec/specs-go"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/bpfstats"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	containerhook "github.com/inspektor-gadget/inspektor-gadget/pkg/container-hook"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
 	containersmap "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/containers-map"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/stream"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runcfanotify"
 	tracercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/tracer-collection"
@@ -81,10 +85,10 @@ func (g *GadgetTracerManager) ReceiveStream(tracerID *pb.TracerID, stream pb.Gad
 	gadgetStream, err := g.tracerCollection.Stream(tracerID.Id)
 	if err != nil {
 		g.mu.Unlock()
-		return fmt.Errorf("stream for tracer %q not found", tracerID.Id)
+		return status.Errorf(codes.NotFound, "stream for tracer %q not found", tracerID.Id)
 	}
 
-	ch := gadgetStream.Subscribe()
+	ch := gadgetStream.Subscribe(!tracerID.SkipHistory)
 	defer gadgetStream.Unsubscribe(ch)
 
 	g.mu.Unlock()
@@ -136,6 +140,30 @@ func (g *GadgetTracerManager) PublishEvent(tracerID string, line string) error {
 	return nil
 }
 
+// SetStreamLimit stops publishing events of the given tracer after max events
+// and calls onLimit once that happens.
+func (g *GadgetTracerManager) SetStreamLimit(tracerID string, max uint64, onLimit func()) error {
+	stream, err := g.tracerCollection.Stream(tracerID)
+	if err != nil {
+		return fmt.Errorf("stream for tracer %q not found", tracerID)
+	}
+
+	stream.SetLimit(max, onLimit)
+	return nil
+}
+
+// AddStreamSink sends all the events published by the given tracer to sink as
+// well. The sink is closed when the tracer is removed.
+func (g *GadgetTracerManager) AddStreamSink(tracerID string, sink stream.Sink) error {
+	gadgetStream, err := g.tracerCollection.Stream(tracerID)
+	if err != nil {
+		sink.Close()
+		return fmt.Errorf("stream for tracer %q not found", tracerID)
+	}
+
+	return gadgetStream.AddSink(sink)
+}
+
 func (g *GadgetTracerManager) TracerMountNsMap(tracerID string) (*ebpf.Map, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -176,6 +204,7 @@ func (g *GadgetTracerManager) AddContainer(_ context.Context, containerDefinitio
 				ContainerName: containerDefinition.Name,
 			},
 		},
+		Source: containercollection.SourceHook,
 	}
 	if containerDefinition.LabelsSet {
 		container.K8s.PodLabels = make(map[string]string)
@@ -218,10 +247,11 @@ func (g *GadgetTracerManager) DumpState(_ context.Context, req *pb.DumpStateRequ
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	containers := "List of containers:\n"
+	var dumps []*ContainerDump
 	g.ContainerRange(func(c *containercollection.Container) {
-		containers += fmt.Sprintf("%+v\n", c)
+		dumps = append(dumps, NewContainerDump(c))
 	})
+	containers := formatContainersDump(dumps)
 
 	traces := "List of tracers:\n"
 	traces += g.tracerCollection.TracerDump()
@@ -244,7 +274,8 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 	if conf.TestOnly {
 		g.tracerCollection, err = tracercollection.NewTracerCollectionTest(&g.ContainerCollection)
 	} else {
-		g.tracerCollection, err = tracercollection.NewTracerCollection(&g.ContainerCollection)
+		g.tracerCollection, err = tracercollection.NewTracerCollection(&g.ContainerCollection,
+			tracercollection.WithPinPath(filepath.Join(gadgets.PinPath, "mntns_filters")))
 	}
 	if err != nil {
 		return nil, err
@@ -255,11 +286,12 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 	}
 
 	if !conf.TestOnly {
-		if err := rlimit.RemoveMemlock(); err != nil {
+		memlockMode, err := bpfstats.SetupMemlock()
+		if err != nil {
 			return nil, err
 		}
+		log.Infof("GadgetTracerManager: memlock: %s", memlockMode)
 
-		var err error
 		if g.containersMap, err = containersmap.NewContainersMap(gadgets.PinPath); err != nil {
 			return nil, fmt.Errorf("creating containers map: %w", err)
 		}
@@ -268,6 +300,7 @@ func NewServer(conf *Conf) (*GadgetTracerManager, error) {
 		opts = append(opts, containercollection.WithOCIConfigEnrichment())
 		opts = append(opts, containercollection.WithCgroupEnrichment())
 		opts = append(opts, containercollection.WithLinuxNamespaceEnrichment())
+		opts = append(opts, containercollection.WithNestedContainers())
 		opts = append(opts, containercollection.WithKubernetesEnrichment(g.nodeName, nil))
 		opts = append(opts, containercollection.WithTracerCollection(g.tracerCollection))
 		opts = append(opts, containercollection.WithProcEnrichment())
@@ -0,0 +1,109 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchInterval is the maximum time a line waits in a
+	// BatchWriter before it's written
+	DefaultBatchInterval = 100 * time.Millisecond
+	// DefaultBatchSize is the number of lines after which a BatchWriter is
+	// flushed
+	DefaultBatchSize = 1000
+
+	batchBufferSize = 64 * 1024
+)
+
+// BatchWriter writes lines in batches to reduce the number of writes (and
+// syscalls) at high event rates. Lines are flushed once maxLines are pending
+// or interval elapsed since the first pending line was written. Lines are
+// always written in the order they were given.
+type BatchWriter struct {
+	mu       sync.Mutex
+	w        *bufio.Writer
+	interval time.Duration
+	maxLines int
+
+	pending int
+	timer   *time.Timer
+	err     error
+}
+
+// NewBatchWriter returns a BatchWriter writing to w. With interval 0 or
+// maxLines 1, each line is written right away.
+func NewBatchWriter(w io.Writer, interval time.Duration, maxLines int) *BatchWriter {
+	return &BatchWriter{
+		w:        bufio.NewWriterSize(w, batchBufferSize),
+		interval: interval,
+		maxLines: maxLines,
+	}
+}
+
+// WriteLine writes line followed by a new line. It returns the error of a
+// previous failed flush, if any.
+func (b *BatchWriter) WriteLine(line string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err != nil {
+		return b.err
+	}
+
+	b.w.WriteString(line)
+	if err := b.w.WriteByte('\n'); err != nil {
+		b.err = err
+		return err
+	}
+	b.pending++
+
+	if b.interval == 0 || b.pending >= b.maxLines {
+		return b.flushLocked()
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.flushLocked()
+		})
+	}
+	return nil
+}
+
+// Flush writes the pending lines
+func (b *BatchWriter) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.flushLocked()
+}
+
+func (b *BatchWriter) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.pending = 0
+	if b.err != nil {
+		return b.err
+	}
+	b.err = b.w.Flush()
+	return b.err
+}
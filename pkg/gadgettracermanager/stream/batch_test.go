@@ -0,0 +1,94 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writes int
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes++
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) get() (string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String(), s.writes
+}
+
+func TestBatchWriterMaxLines(t *testing.T) {
+	out := &syncBuffer{}
+	w := NewBatchWriter(out, time.Hour, 3)
+
+	var expected strings.Builder
+	for i := 0; i < 7; i++ {
+		line := fmt.Sprintf(`{"seq":%d}`, i)
+		require.NoError(t, w.WriteLine(line))
+		expected.WriteString(line + "\n")
+	}
+
+	// Two batches of 3 lines were written, one line is pending
+	content, writes := out.get()
+	assert.Equal(t, 2, writes)
+	assert.Equal(t, 6, strings.Count(content, "\n"))
+
+	require.NoError(t, w.Flush())
+	content, writes = out.get()
+	assert.Equal(t, 3, writes)
+	assert.Equal(t, expected.String(), content)
+}
+
+func TestBatchWriterInterval(t *testing.T) {
+	out := &syncBuffer{}
+	w := NewBatchWriter(out, 10*time.Millisecond, DefaultBatchSize)
+
+	require.NoError(t, w.WriteLine("a"))
+	require.NoError(t, w.WriteLine("b"))
+	content, _ := out.get()
+	assert.Empty(t, content)
+
+	require.Eventually(t, func() bool {
+		content, writes := out.get()
+		return content == "a\nb\n" && writes == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestBatchWriterLowLatency(t *testing.T) {
+	out := &syncBuffer{}
+	w := NewBatchWriter(out, 0, 1)
+
+	require.NoError(t, w.WriteLine("a"))
+	require.NoError(t, w.WriteLine("b"))
+	content, writes := out.get()
+	assert.Equal(t, "a\nb\n", content)
+	assert.Equal(t, 2, writes)
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+)
+
+const (
+	// DefaultServicePort is the port the stream service of the gadget pods
+	// listens on when it's enabled
+	DefaultServicePort = 8082
+
+	// DefaultRetryInterval is the time Receive waits before connecting again
+	// the first time. It's doubled after each failed connection, up to
+	// DefaultMaxRetryInterval.
+	DefaultRetryInterval    = time.Second
+	DefaultMaxRetryInterval = 30 * time.Second
+	// DefaultMaxRetries is the number of consecutive failed connections after
+	// which Receive gives up. With the default intervals, it waits for about
+	// three minutes, enough for a node to restart.
+	DefaultMaxRetries = 10
+)
+
+// ReceiveConfig configures Receive
+type ReceiveConfig struct {
+	TracerID    string
+	SkipHistory bool
+
+	// MaxRetries is the number of consecutive failed connections after which
+	// Receive gives up. Receiving a line resets the count. 0 disables the
+	// reconnection.
+	MaxRetries       int
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+
+	// OnReconnect, if set, is called each time the stream is opened again
+	// after a failure
+	OnReconnect func(err error)
+}
+
+// Receive reads the stream of a tracer and calls onLine for each line until
+// the stream ends, ctx is cancelled or onLine returns an error. When the
+// connection breaks, the stream is opened again without the history, so lines
+// received before aren't repeated. Lines published while disconnected are
+// lost, the sequence numbers of the events tell how many, see GapDetector.
+func Receive(ctx context.Context, client pb.GadgetTracerManagerClient, config ReceiveConfig, onLine func(line string) error) error {
+	return Retry(ctx, config,
+		func(ctx context.Context, skipHistory bool) (bool, error) {
+			return receiveOnce(ctx, client, config.TracerID, skipHistory, onLine)
+		},
+		retryable,
+	)
+}
+
+// Retry calls receive until it returns without error or with an error that
+// isn't retryable, waiting longer after each consecutive failure. receive is
+// asked to skip the history of the stream after the first call. It returns
+// whether it received any line, which resets the count of failures.
+// reconnecting tells retryable whether the error happened while connecting
+// again, e.g. a tracer not being found is only temporary when the gadget pod
+// restarted.
+func Retry(
+	ctx context.Context,
+	config ReceiveConfig,
+	receive func(ctx context.Context, skipHistory bool) (bool, error),
+	retryable func(err error, reconnecting bool) bool,
+) error {
+	retryInterval := config.RetryInterval
+	if retryInterval == 0 {
+		retryInterval = DefaultRetryInterval
+	}
+	maxRetryInterval := config.MaxRetryInterval
+	if maxRetryInterval == 0 {
+		maxRetryInterval = DefaultMaxRetryInterval
+	}
+	if maxRetryInterval < retryInterval {
+		maxRetryInterval = retryInterval
+	}
+
+	skipHistory := config.SkipHistory
+	reconnecting := false
+	retries := 0
+	for {
+		received, err := receive(ctx, skipHistory)
+		if err == nil || ctx.Err() != nil {
+			return nil
+		}
+		if !retryable(err, reconnecting) {
+			return err
+		}
+		if received {
+			retries = 0
+		}
+		if retries >= config.MaxRetries {
+			return fmt.Errorf("receiving stream after %d retries: %w", retries, err)
+		}
+
+		wait := retryInterval << retries
+		if wait > maxRetryInterval || wait <= 0 {
+			wait = maxRetryInterval
+		}
+		retries++
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		if config.OnReconnect != nil {
+			config.OnReconnect(err)
+		}
+		skipHistory = true
+		reconnecting = true
+	}
+}
+
+// receiveOnce opens the stream and reads it until it ends. It returns whether
+// any line was received.
+func receiveOnce(ctx context.Context, client pb.GadgetTracerManagerClient, tracerID string, skipHistory bool, onLine func(string) error) (bool, error) {
+	stream, err := client.ReceiveStream(ctx, &pb.TracerID{
+		Id:          tracerID,
+		SkipHistory: skipHistory,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		line, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return received, nil
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+		if err := onLine(line.Line); err != nil {
+			return received, &lineError{err}
+		}
+	}
+}
+
+// lineError wraps the errors returned by the onLine callback, they stop
+// Receive right away
+type lineError struct {
+	err error
+}
+
+func (e *lineError) Error() string { return e.err.Error() }
+func (e *lineError) Unwrap() error { return e.err }
+
+func retryable(err error, reconnecting bool) bool {
+	var lerr *lineError
+	if errors.As(err, &lerr) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable:
+		return true
+	case codes.NotFound:
+		// The tracer is created again once the gadget pod restarted
+		return reconnecting
+	}
+	return false
+}
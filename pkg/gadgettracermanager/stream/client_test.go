@@ -0,0 +1,172 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgettracermanager/api"
+)
+
+// fakeCall is the result of a ReceiveStream call: the lines sent and the
+// error ending the stream
+type fakeCall struct {
+	lines []string
+	err   error
+}
+
+type fakeClient struct {
+	pb.GadgetTracerManagerClient
+	calls    []fakeCall
+	requests []*pb.TracerID
+}
+
+func (c *fakeClient) ReceiveStream(_ context.Context, in *pb.TracerID, _ ...grpc.CallOption) (pb.GadgetTracerManager_ReceiveStreamClient, error) {
+	c.requests = append(c.requests, in)
+	if len(c.calls) == 0 {
+		return nil, status.Error(codes.Unavailable, "no more calls")
+	}
+	call := c.calls[0]
+	c.calls = c.calls[1:]
+	return &fakeStream{call: call}, nil
+}
+
+type fakeStream struct {
+	grpc.ClientStream
+	call fakeCall
+}
+
+func (s *fakeStream) Recv() (*pb.StreamData, error) {
+	if len(s.call.lines) == 0 {
+		if s.call.err != nil {
+			return nil, s.call.err
+		}
+		return nil, io.EOF
+	}
+	line := s.call.lines[0]
+	s.call.lines = s.call.lines[1:]
+	return &pb.StreamData{Line: line}, nil
+}
+
+func receiveAll(client *fakeClient, config ReceiveConfig) ([]string, error) {
+	config.RetryInterval = time.Millisecond
+	var lines []string
+	err := Receive(context.Background(), client, config, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	return lines, err
+}
+
+func TestReceiveReconnects(t *testing.T) {
+	client := &fakeClient{calls: []fakeCall{
+		{lines: []string{"a", "b"}, err: status.Error(codes.Unavailable, "connection reset")},
+		{lines: []string{"c"}},
+	}}
+	reconnects := 0
+	lines, err := receiveAll(client, ReceiveConfig{
+		TracerID:    "trace_gadget_exec",
+		MaxRetries:  1,
+		OnReconnect: func(error) { reconnects++ },
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"a", "b", "c"}, lines)
+	assert.Equal(t, 1, reconnects)
+	require.Len(t, client.requests, 2)
+	assert.False(t, client.requests[0].SkipHistory)
+	assert.True(t, client.requests[1].SkipHistory)
+}
+
+func TestReceiveGivesUp(t *testing.T) {
+	client := &fakeClient{}
+	_, err := receiveAll(client, ReceiveConfig{TracerID: "trace_gadget_exec", MaxRetries: 2})
+	require.Error(t, err)
+	assert.Equal(t, codes.Unavailable, status.Code(errors.Unwrap(err)))
+	assert.Len(t, client.requests, 3)
+}
+
+func TestReceiveNotRetryable(t *testing.T) {
+	client := &fakeClient{calls: []fakeCall{
+		{err: status.Error(codes.Unknown, "stream for tracer \"x\" not found")},
+	}}
+	_, err := receiveAll(client, ReceiveConfig{TracerID: "x", MaxRetries: 5})
+	require.Error(t, err)
+	assert.Len(t, client.requests, 1)
+}
+
+func TestReceiveLineError(t *testing.T) {
+	client := &fakeClient{calls: []fakeCall{{lines: []string{"a", "b"}}}}
+	errStop := errors.New("stop")
+	err := Receive(context.Background(), client, ReceiveConfig{MaxRetries: 5}, func(string) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Len(t, client.requests, 1)
+}
+
+func TestReceiveTracerNotFoundAfterRestart(t *testing.T) {
+	// The gadget pod restarted: the tracer is created again after a while
+	client := &fakeClient{calls: []fakeCall{
+		{lines: []string{"a"}, err: status.Error(codes.Unavailable, "connection reset")},
+		{err: status.Error(codes.NotFound, "stream for tracer \"x\" not found")},
+		{lines: []string{"b"}},
+	}}
+	lines, err := receiveAll(client, ReceiveConfig{TracerID: "x", MaxRetries: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, lines)
+	assert.Len(t, client.requests, 3)
+
+	// The tracer doesn't exist
+	client = &fakeClient{calls: []fakeCall{
+		{err: status.Error(codes.NotFound, "stream for tracer \"x\" not found")},
+	}}
+	_, err = receiveAll(client, ReceiveConfig{TracerID: "x", MaxRetries: 5})
+	require.Error(t, err)
+	assert.Len(t, client.requests, 1)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	var calls []time.Time
+	err := Retry(context.Background(),
+		ReceiveConfig{
+			MaxRetries:       4,
+			RetryInterval:    10 * time.Millisecond,
+			MaxRetryInterval: 30 * time.Millisecond,
+		},
+		func(context.Context, bool) (bool, error) {
+			calls = append(calls, time.Now())
+			return false, errors.New("broken")
+		},
+		func(error, bool) bool { return true },
+	)
+	require.Error(t, err)
+	require.Len(t, calls, 5)
+
+	// 10ms, 20ms, then capped to 30ms
+	for i, interval := range []time.Duration{10, 20, 30, 30} {
+		assert.GreaterOrEqual(t, calls[i+1].Sub(calls[i]), interval*time.Millisecond)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// SeqField is the field of the events holding their sequence number in
+	// the stream. It starts at 1 and is incremented for each event, so a
+	// missing number means an event was lost.
+	SeqField = "seq"
+	// EventIDField is the field of the events holding their unique ID. An
+	// event keeps its ID when it's sent again, e.g. from the history of the
+	// stream, so consumers can drop the duplicates.
+	EventIDField = "eventID"
+)
+
+// stampLine adds the sequence number and the ID to a line holding a JSON
+// object. Other lines are returned unchanged.
+func stampLine(line string, seq uint64, id string) (string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") || !strings.HasSuffix(trimmed, "}") {
+		return line, false
+	}
+
+	fields := fmt.Sprintf("%q:%d,%q:%q", SeqField, seq, EventIDField, id)
+	rest := strings.TrimSpace(trimmed[1:])
+	if rest != "}" {
+		fields += ","
+	}
+	return "{" + fields + rest, true
+}
+
+// LineSeq returns the sequence number of the event in line, or false if it
+// doesn't have one.
+func LineSeq(line string) (uint64, bool) {
+	var event struct {
+		Seq *uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Seq == nil {
+		return 0, false
+	}
+	return *event.Seq, true
+}
+
+// GapDetector finds the events lost in a stream from their sequence numbers.
+// The zero value is ready to use.
+type GapDetector struct {
+	last uint64
+}
+
+// Observe returns the number of events lost before the one in line. A sequence
+// number going back means the stream was started again, e.g. because the
+// gadget pod restarted; nothing is reported as lost then.
+func (d *GapDetector) Observe(line string) uint64 {
+	seq, ok := LineSeq(line)
+	if !ok {
+		return 0
+	}
+
+	var lost uint64
+	if d.last != 0 && seq > d.last+1 {
+		lost = seq - d.last - 1
+	}
+	d.last = seq
+	return lost
+}
@@ -0,0 +1,286 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Sink receives a copy of every line published on a stream, regardless of
+// whether there are subscribers.
+type Sink interface {
+	Write(line string) error
+	Close() error
+}
+
+// rotatedTimeFormat is appended to the name of the rotated files. They are
+// sorted from the oldest to the newest when sorted by name.
+const rotatedTimeFormat = "20060102-150405.000000000"
+
+// FileRotation configures when a FileSink rotates its file. The zero value
+// never rotates it.
+type FileRotation struct {
+	// MaxSize is the size in bytes the file is rotated at
+	MaxSize int64
+	// MaxAge is how long the lines are appended to the same file
+	MaxAge time.Duration
+	// MaxFiles is the number of rotated files kept, 0 keeps all of them
+	MaxFiles int
+	// Compress compresses the rotated files with gzip
+	Compress bool
+}
+
+// FileSink appends the lines to a file, one per line. The file is rotated
+// according to its FileRotation: it's renamed with the time of the rotation
+// appended and a new file is created.
+type FileSink struct {
+	path     string
+	rotation FileRotation
+	now      func() time.Time
+
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	// The rotated files are compressed and removed in the background, one
+	// rotation at a time
+	cleanupMu sync.Mutex
+	cleanupWg sync.WaitGroup
+}
+
+// NewFileSink opens (or creates) the file at path for appending. Missing parent
+// directories are created.
+func NewFileSink(path string, rotation FileRotation) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("creating directory for %q: %w", path, err)
+	}
+	s := &FileSink{
+		path:     path,
+		rotation: rotation,
+		now:      time.Now,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening %q: %w", s.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("getting size of %q: %w", s.path, err)
+	}
+	s.f = f
+	s.size = info.Size()
+	s.opened = s.now()
+	return nil
+}
+
+func (s *FileSink) Write(line string) error {
+	if s.needsRotation(int64(len(line) + 1)) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.WriteString(line + "\n")
+	s.size += int64(n)
+	return err
+}
+
+// needsRotation tells whether the file must be rotated before writing size
+// more bytes. An empty file is never rotated.
+func (s *FileSink) needsRotation(size int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.rotation.MaxSize > 0 && s.size+size > s.rotation.MaxSize {
+		return true
+	}
+	return s.rotation.MaxAge > 0 && s.now().Sub(s.opened) >= s.rotation.MaxAge
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("closing %q: %w", s.path, err)
+	}
+
+	rotated := s.path + "." + s.now().UTC().Format(rotatedTimeFormat)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return fmt.Errorf("rotating %q: %w", s.path, err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if s.rotation.Compress || s.rotation.MaxFiles > 0 {
+		s.cleanupWg.Add(1)
+		go s.cleanup(rotated)
+	}
+	return nil
+}
+
+// cleanup compresses the file that was just rotated and removes the oldest
+// rotated files
+func (s *FileSink) cleanup(rotated string) {
+	defer s.cleanupWg.Done()
+
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+
+	if s.rotation.Compress {
+		if err := compressFile(rotated); err != nil {
+			log.Warnf("Compressing %q: %s", rotated, err)
+		}
+	}
+	if s.rotation.MaxFiles > 0 {
+		if err := s.removeOldFiles(); err != nil {
+			log.Warnf("Removing rotated files of %q: %s", s.path, err)
+		}
+	}
+}
+
+// rotatedFiles returns the rotated files of the sink, from the oldest to the
+// newest
+func (s *FileSink) rotatedFiles() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(s.path))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(s.path) + "."
+	files := []string{}
+	for _, entry := range entries {
+		suffix, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimSuffix(suffix, ".gz")); err != nil {
+			continue
+		}
+		files = append(files, filepath.Join(filepath.Dir(s.path), entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *FileSink) removeOldFiles() error {
+	files, err := s.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(files) > s.rotation.MaxFiles {
+		if err := os.Remove(files[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// compressFile replaces path by its gzip compressed version, path.gz
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := w.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Close closes the file once the rotated files were compressed and removed
+func (s *FileSink) Close() error {
+	err := s.f.Close()
+	s.cleanupWg.Wait()
+	return err
+}
+
+// MetricsSink counts the lines in a counter vector, using the "type" field of
+// the events as the "type" label.
+type MetricsSink struct {
+	counter *prometheus.CounterVec
+	labels  prometheus.Labels
+}
+
+// NewMetricsSink returns a sink incrementing counter with the given labels. The
+// counter must have a "type" label in addition to the given ones. The metrics
+// of the sink are removed when it's closed.
+func NewMetricsSink(counter *prometheus.CounterVec, labels prometheus.Labels) *MetricsSink {
+	return &MetricsSink{counter: counter, labels: labels}
+}
+
+func (s *MetricsSink) Write(line string) error {
+	var event struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return fmt.Errorf("decoding event: %w", err)
+	}
+
+	labels := make(prometheus.Labels, len(s.labels)+1)
+	for k, v := range s.labels {
+		labels[k] = v
+	}
+	labels["type"] = event.Type
+	counter, err := s.counter.GetMetricWith(labels)
+	if err != nil {
+		return err
+	}
+	counter.Inc()
+	return nil
+}
+
+func (s *MetricsSink) Close() error {
+	s.counter.DeletePartialMatch(s.labels)
+	return nil
+}
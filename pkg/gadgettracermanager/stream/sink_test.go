@@ -0,0 +1,122 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestFileSink returns a FileSink whose clock is advanced by a second at
+// each write
+func newTestFileSink(t *testing.T, rotation FileRotation) (*FileSink, string) {
+	path := filepath.Join(t.TempDir(), "traces", "exec.json")
+	s, err := NewFileSink(path, rotation)
+	require.NoError(t, err)
+
+	now := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	s.opened = now
+	t.Cleanup(func() { s.Close() })
+
+	return s, path
+}
+
+func writeLines(t *testing.T, s *FileSink, lines ...string) {
+	for _, line := range lines {
+		now := s.now().Add(time.Second)
+		s.now = func() time.Time { return now }
+		require.NoError(t, s.Write(line))
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		require.NoError(t, err)
+		defer gz.Close()
+		r = gz
+	}
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestFileSinkNoRotation(t *testing.T) {
+	s, path := newTestFileSink(t, FileRotation{})
+	writeLines(t, s, "a", "b", "c")
+	require.NoError(t, s.Close())
+
+	assert.Equal(t, "a\nb\nc\n", readFile(t, path))
+	files, err := s.rotatedFiles()
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestFileSinkRotationMaxSize(t *testing.T) {
+	s, path := newTestFileSink(t, FileRotation{MaxSize: 6})
+	writeLines(t, s, "aa", "bb", "cc", "dd", "eeeeeeeee")
+	require.NoError(t, s.Close())
+
+	files, err := s.rotatedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "aa\nbb\n", readFile(t, files[0]))
+	assert.Equal(t, "cc\ndd\n", readFile(t, files[1]))
+	// A line bigger than MaxSize is written to its own file
+	assert.Equal(t, "eeeeeeeee\n", readFile(t, path))
+}
+
+func TestFileSinkRotationMaxAge(t *testing.T) {
+	s, path := newTestFileSink(t, FileRotation{MaxAge: 2 * time.Second})
+	writeLines(t, s, "a", "b", "c", "d")
+	require.NoError(t, s.Close())
+
+	files, err := s.rotatedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "a\n", readFile(t, files[0]))
+	assert.Equal(t, "b\nc\n", readFile(t, files[1]))
+	assert.Equal(t, "d\n", readFile(t, path))
+}
+
+func TestFileSinkRotationMaxFilesCompress(t *testing.T) {
+	s, path := newTestFileSink(t, FileRotation{MaxSize: 1, MaxFiles: 2, Compress: true})
+	writeLines(t, s, "a", "b", "c", "d", "e")
+	require.NoError(t, s.Close())
+
+	files, err := s.rotatedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, file := range files {
+		assert.True(t, strings.HasSuffix(file, ".gz"), file)
+	}
+	assert.Equal(t, "c\n", readFile(t, files[0]))
+	assert.Equal(t, "d\n", readFile(t, files[1]))
+	assert.Equal(t, "e\n", readFile(t, path))
+}
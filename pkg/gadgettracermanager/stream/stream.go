@@ -15,7 +15,11 @@
 package stream
 
 import (
+	"errors"
 	"sync"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -37,6 +41,18 @@ type GadgetStream struct {
 	subs map[chan Record]struct{}
 
 	closed bool
+
+	// limit is the number of lines after which onLimit is called and
+	// further lines are dropped. 0 means no limit.
+	limit     uint64
+	published uint64
+	onLimit   func()
+
+	// sinks receive every published line
+	sinks []Sink
+
+	// seq is the sequence number of the last event published
+	seq uint64
 }
 
 func NewGadgetStream() *GadgetStream {
@@ -45,7 +61,10 @@ func NewGadgetStream() *GadgetStream {
 	}
 }
 
-func (g *GadgetStream) Subscribe() chan Record {
+// Subscribe returns a channel receiving the lines published from now on, after
+// the last HistorySize lines already published if history is set. Several
+// subscribers can read the stream at the same time.
+func (g *GadgetStream) Subscribe(history bool) chan Record {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
@@ -54,8 +73,10 @@ func (g *GadgetStream) Subscribe() chan Record {
 	}
 
 	ch := make(chan Record, SubChannelSize)
-	for _, l := range g.previousLines {
-		ch <- l
+	if history {
+		for _, l := range g.previousLines {
+			ch <- l
+		}
 	}
 	g.subs[ch] = struct{}{}
 
@@ -77,6 +98,34 @@ func (g *GadgetStream) Unsubscribe(ch chan Record) {
 	}
 }
 
+// SetLimit configures the stream to drop lines after max lines were published.
+// onLimit is called (in a separate goroutine) once the limit is reached.
+func (g *GadgetStream) SetLimit(max uint64, onLimit func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.limit = max
+	g.onLimit = onLimit
+}
+
+// AddSink adds a sink receiving all the lines published from now on. The sink
+// is closed together with the stream.
+func (g *GadgetStream) AddSink(sink Sink) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.closed {
+		sink.Close()
+		return errors.New("stream is closed")
+	}
+
+	g.sinks = append(g.sinks, sink)
+	return nil
+}
+
+// Publish sends line to the subscribers and the sinks. Lines holding an event
+// (a JSON object) get the next sequence number of the stream and a unique ID,
+// see SeqField and EventIDField.
 func (g *GadgetStream) Publish(line string) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
@@ -85,6 +134,30 @@ func (g *GadgetStream) Publish(line string) {
 		return
 	}
 
+	if g.limit > 0 {
+		if g.published >= g.limit {
+			return
+		}
+		g.published++
+		if g.published == g.limit && g.onLimit != nil {
+			go g.onLimit()
+		}
+	}
+
+	if stamped, ok := stampLine(line, g.seq+1, uuid.NewString()); ok {
+		line = stamped
+		g.seq++
+	}
+
+	for i := 0; i < len(g.sinks); i++ {
+		if err := g.sinks[i].Write(line); err != nil {
+			log.Warnf("Removing stream sink after error: %s", err)
+			g.sinks[i].Close()
+			g.sinks = append(g.sinks[:i], g.sinks[i+1:]...)
+			i--
+		}
+	}
+
 	newLine := Record{
 		Line: line,
 	}
@@ -117,5 +190,11 @@ func (g *GadgetStream) Close() {
 	for ch := range g.subs {
 		close(ch)
 	}
+	for _, sink := range g.sinks {
+		if err := sink.Close(); err != nil {
+			log.Warnf("Closing stream sink: %s", err)
+		}
+	}
+	g.sinks = nil
 	g.closed = true
 }
@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLines(ch chan Record, count int) []string {
+	lines := []string{}
+	for i := 0; i < count; i++ {
+		lines = append(lines, (<-ch).Line)
+	}
+	return lines
+}
+
+func TestGadgetStreamSubscribe(t *testing.T) {
+	s := NewGadgetStream()
+	for i := 0; i < HistorySize+2; i++ {
+		s.Publish(fmt.Sprint(i))
+	}
+
+	withHistory := s.Subscribe(true)
+	withoutHistory := s.Subscribe(false)
+	require.Len(t, withHistory, HistorySize)
+	require.Len(t, withoutHistory, 0)
+
+	history := readLines(withHistory, HistorySize)
+	assert.Equal(t, "2", history[0])
+	assert.Equal(t, fmt.Sprint(HistorySize+1), history[HistorySize-1])
+
+	// All the subscribers get the new lines
+	s.Publish("new")
+	assert.Equal(t, []string{"new"}, readLines(withHistory, 1))
+	assert.Equal(t, []string{"new"}, readLines(withoutHistory, 1))
+
+	s.Unsubscribe(withHistory)
+	s.Publish("last")
+	assert.Equal(t, []string{"last"}, readLines(withoutHistory, 1))
+
+	s.Close()
+	_, ok := <-withoutHistory
+	assert.False(t, ok)
+	assert.Nil(t, s.Subscribe(true))
+}
+
+func TestGadgetStreamSeq(t *testing.T) {
+	s := NewGadgetStream()
+	ch := s.Subscribe(false)
+
+	s.Publish(`{"type":"normal"}`)
+	s.Publish("not an event")
+	s.Publish(` { } `)
+
+	lines := readLines(ch, 3)
+	assert.Regexp(t, `^\{"seq":1,"eventID":"[0-9a-f-]{36}","type":"normal"\}$`, lines[0])
+	assert.Equal(t, "not an event", lines[1])
+	assert.Regexp(t, `^\{"seq":2,"eventID":"[0-9a-f-]{36}"\}$`, lines[2])
+	assert.NotEqual(t, lines[0][20:56], lines[2][20:56])
+
+	// The history keeps the IDs
+	history := s.Subscribe(true)
+	assert.Equal(t, lines, readLines(history, 3))
+
+	seq, ok := LineSeq(lines[2])
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), seq)
+	_, ok = LineSeq(lines[1])
+	assert.False(t, ok)
+
+	s.Close()
+}
+
+func TestGapDetector(t *testing.T) {
+	d := &GapDetector{}
+	line := func(seq uint64) string {
+		l, _ := stampLine(`{}`, seq, "id")
+		return l
+	}
+
+	// Starting after the beginning of the stream isn't a gap
+	assert.Zero(t, d.Observe(line(5)))
+	assert.Zero(t, d.Observe(line(6)))
+	assert.Zero(t, d.Observe("not an event"))
+	assert.Equal(t, uint64(3), d.Observe(line(10)))
+	// The stream started again
+	assert.Zero(t, d.Observe(line(1)))
+	assert.Equal(t, uint64(1), d.Observe(line(3)))
+}
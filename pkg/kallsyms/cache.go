@@ -0,0 +1,119 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kallsyms
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+)
+
+// modulesCheckInterval is how often the loaded modules are checked to
+// invalidate the cached symbols. Reading /proc/modules is much cheaper than
+// parsing /proc/kallsyms, but it's still not worth doing it for each lookup.
+const modulesCheckInterval = 5 * time.Second
+
+var (
+	kallsymsPath = "/proc/kallsyms"
+	modulesPath  = "/proc/modules"
+)
+
+type symsCache struct {
+	mu sync.Mutex
+
+	kAllSyms *KAllSyms
+
+	// modules is the hash of /proc/modules when kAllSyms was read
+	modules   [sha256.Size]byte
+	checkedAt time.Time
+}
+
+var cache symsCache
+
+// Cached returns the kernel symbols shared by all the gadgets. /proc/kallsyms
+// is only read again when kernel modules were loaded or unloaded since the
+// last time it was read. The returned KAllSyms must not be modified.
+func Cached() (*KAllSyms, error) {
+	return cache.get(time.Now())
+}
+
+// Invalidate forces the next call to Cached to read /proc/kallsyms again.
+func Invalidate() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.kAllSyms = nil
+}
+
+func (c *symsCache) get(now time.Time) (*KAllSyms, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.kAllSyms != nil && now.Sub(c.checkedAt) < modulesCheckInterval {
+		return c.kAllSyms, nil
+	}
+
+	modules, err := os.ReadFile(modulesPath)
+	if err != nil {
+		// Kernels without module support don't have /proc/modules, the
+		// symbols never change then.
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		modules = nil
+	}
+	sum := modulesFingerprint(modules)
+	c.checkedAt = now
+
+	if c.kAllSyms != nil && sum == c.modules {
+		return c.kAllSyms, nil
+	}
+
+	file, err := os.Open(kallsymsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	kAllSyms, err := NewKAllSymsFromReader(file)
+	if err != nil {
+		return nil, err
+	}
+	c.kAllSyms = kAllSyms
+	c.modules = sum
+	return kAllSyms, nil
+}
+
+// modulesFingerprint hashes the name and load address of the modules listed in
+// /proc/modules. The other columns, like the reference count, change without
+// affecting the symbols.
+func modulesFingerprint(modules []byte) [sha256.Size]byte {
+	h := sha256.New()
+	for _, line := range bytes.Split(modules, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		h.Write(fields[0])
+		h.Write([]byte{' '})
+		h.Write(fields[len(fields)-1])
+		h.Write([]byte{'\n'})
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kallsyms
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymsCache(t *testing.T) {
+	dir := t.TempDir()
+
+	oldKallsymsPath, oldModulesPath := kallsymsPath, modulesPath
+	kallsymsPath = filepath.Join(dir, "kallsyms")
+	modulesPath = filepath.Join(dir, "modules")
+	t.Cleanup(func() {
+		kallsymsPath, modulesPath = oldKallsymsPath, oldModulesPath
+	})
+
+	write := func(path, content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	write(kallsymsPath, "ffffffffb4231f40 D bpf_prog_fops\n")
+	write(modulesPath, "nf_tables 372736 1 nft_compat, Live 0xffffffffc0a00000\n")
+
+	c := &symsCache{}
+	now := time.Now()
+
+	k1, err := c.get(now)
+	require.NoError(t, err)
+	require.True(t, k1.SymbolExists("bpf_prog_fops"))
+
+	// Same modules: the symbols aren't read again, even if the reference
+	// count of a module changed
+	write(kallsymsPath, "ffffffffc0a01000 t nft_do_chain [nf_tables]\n")
+	write(modulesPath, "nf_tables 372736 2 nft_compat, Live 0xffffffffc0a00000\n")
+	k2, err := c.get(now.Add(modulesCheckInterval))
+	require.NoError(t, err)
+	require.Same(t, k1, k2)
+
+	// A module was loaded, but it's not checked yet
+	write(modulesPath, "nf_tables 372736 2 nft_compat, Live 0xffffffffc0a00000\n"+
+		"veth 40960 0 - Live 0xffffffffc0b00000\n")
+	k3, err := c.get(now.Add(modulesCheckInterval + time.Second))
+	require.NoError(t, err)
+	require.Same(t, k1, k3)
+
+	k4, err := c.get(now.Add(2 * modulesCheckInterval))
+	require.NoError(t, err)
+	require.NotSame(t, k1, k4)
+	require.True(t, k4.SymbolExists("nft_do_chain"))
+	require.False(t, k4.SymbolExists("bpf_prog_fops"))
+
+	// Without module support the symbols are read once
+	require.NoError(t, os.Remove(modulesPath))
+	k5, err := c.get(now.Add(3 * modulesCheckInterval))
+	require.NoError(t, err)
+	require.NotSame(t, k4, k5)
+	k6, err := c.get(now.Add(4 * modulesCheckInterval))
+	require.NoError(t, err)
+	require.Same(t, k5, k6)
+}
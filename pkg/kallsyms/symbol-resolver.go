@@ -52,7 +52,7 @@ func (r *kAllSymsResolver) resolve(symbol string) (uint64, error) {
 }
 
 func (r *kAllSymsResolver) init() error {
-	kAllSyms, err := NewKAllSyms()
+	kAllSyms, err := Cached()
 	if err != nil {
 		return err
 	}
@@ -65,4 +65,7 @@ type GadgetMetadata struct {
 	DataSources map[string]*DataSource `yaml:"datasources,omitempty"`
 	// Params exposed by this gadget. It includes params for different operators
 	Params map[string]map[string]params.ParamDesc `yaml:"params,omitempty"`
+	// Capabilities used to load the eBPF programs of the gadget, e.g. bpf,
+	// perfmon or net_admin
+	Capabilities []string `yaml:"capabilities,omitempty"`
 }
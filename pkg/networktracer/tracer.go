@@ -43,7 +43,6 @@ import (
 	"unsafe"
 
 	"github.com/cilium/ebpf"
-	"github.com/cilium/ebpf/perf"
 	"golang.org/x/sys/unix"
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
@@ -74,7 +73,8 @@ type Tracer[Event any] struct {
 	dispatcherMap     *ebpf.Map
 	collection        *ebpf.Collection
 	prog              *ebpf.Program
-	perfRd            *perf.Reader
+	perfRd            *gadgets.BufferReader
+	perfBufferPages   uint32
 
 	// key: network namespace inode number
 	// value: Tracelet
@@ -161,6 +161,12 @@ func (t *Tracer[Event]) SetSocketEnricherMap(m *ebpf.Map) {
 	t.socketEnricherMap = m
 }
 
+// SetPerfBufferPages sets the number of pages per CPU of the perf buffer
+// created by Run. 0 uses gadgets.PerfBufferPages.
+func (t *Tracer[Event]) SetPerfBufferPages(pages uint32) {
+	t.perfBufferPages = pages
+}
+
 func (t *Tracer[Event]) Run(
 	spec *ebpf.CollectionSpec,
 	baseEvent func(ev types.Event) *Event,
@@ -228,9 +234,9 @@ func (t *Tracer[Event]) Run(
 		return fmt.Errorf("creating BPF collection: %w", err)
 	}
 
-	t.perfRd, err = perf.NewReader(t.collection.Maps[bpfPerfMapName], gadgets.PerfBufferPages*os.Getpagesize())
+	t.perfRd, err = gadgets.NewBufferReader(t.collection.Maps[bpfPerfMapName], t.perfBufferPages)
 	if err != nil {
-		return fmt.Errorf("getting a perf reader: %w", err)
+		return fmt.Errorf("creating buffer reader: %w", err)
 	}
 
 	if err := gadgets.FreezeMaps(t.collection.Maps[bpfPerfMapName]); err != nil {
@@ -321,9 +327,10 @@ func (t *Tracer[Event]) listen(
 	processEvent func(rawSample []byte, netns uint64) (*Event, error),
 ) {
 	for {
-		record, err := t.perfRd.Read()
+		var record gadgets.BufferRecord
+		err := t.perfRd.ReadInto(&record)
 		if err != nil {
-			if errors.Is(err, perf.ErrClosed) {
+			if errors.Is(err, os.ErrClosed) {
 				return
 			}
 
@@ -41,11 +41,24 @@ const (
 	ParamFields = "fields"
 	ParamMode   = "output"
 
+	// ParamRequestedFields is set by the client to the value of ParamFields
+	// when it's given explicitly. Remote nodes use it to only send the
+	// requested fields, see RequestedFields.
+	ParamRequestedFields = "requested-fields"
+
 	ModeJSON       = "json"
 	ModeJSONPretty = "jsonpretty"
 	ModeColumns    = "columns"
 	ModeYAML       = "yaml"
 
+	// ModeJSONPath prints the fields selected by a JSONPath template, given
+	// as jsonpath=<template>
+	ModeJSONPath = "jsonpath"
+
+	// ModeCustomColumns prints columns whose values are selected by JSONPath
+	// expressions, given as custom-columns=<header>:<jsonpath>,...
+	ModeCustomColumns = "custom-columns"
+
 	DefaultOutputMode = ModeColumns
 
 	// AnnotationClearScreenBefore can be used to clear the screen before printing a new event; usually used for
@@ -60,7 +73,7 @@ const (
 	AnnotationDefaultOutputMode = "cli.default-output-mode"
 )
 
-var DefaultSupportedOutputModes = []string{ModeColumns, ModeJSON, ModeJSONPretty, ModeYAML}
+var DefaultSupportedOutputModes = []string{ModeColumns, ModeCustomColumns, ModeJSON, ModeJSONPath, ModeJSONPretty, ModeYAML}
 
 type cliOperator struct{}
 
@@ -274,13 +287,11 @@ func parseFields(fieldsString string, defaultFields []string) []string {
 	return result
 }
 
-func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
-	params := apihelpers.ToParamDescs(o.ExtraParams(gadgetCtx)).ToParams()
-	params.CopyFromMap(o.paramValues, "")
-
-	fieldValues := strings.Split(params.Get(ParamFields).AsString(), ";")
+// parseFieldsParam returns the fields of ParamFields by data source name. The
+// fields for all data sources use the empty name.
+func parseFieldsParam(value string) map[string]string {
 	fieldLookup := make(map[string]string)
-	for _, v := range fieldValues {
+	for _, v := range strings.Split(value, ";") {
 		dsFieldValues := strings.SplitN(v, ":", 2)
 		var dsName string
 		dsFields := dsFieldValues[0]
@@ -290,8 +301,39 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 		}
 		fieldLookup[dsName] = dsFields
 	}
+	return fieldLookup
+}
+
+// RequestedFields returns the fields of ds selected by value, with the format
+// of ParamFields, or nil if all of them are.
+func RequestedFields(value string, ds datasource.DataSource) ([]string, error) {
+	fieldLookup := parseFieldsParam(value)
+	fields, ok := fieldLookup[ds.Name()]
+	if !ok {
+		fields, ok = fieldLookup[""]
+		if !ok {
+			return nil, nil
+		}
+	}
+
+	var defCols []string
+	if strings.ContainsAny(fields, "+-") {
+		p, err := ds.Parser()
+		if err != nil {
+			return nil, fmt.Errorf("getting parser: %w", err)
+		}
+		defCols = p.GetDefaultColumns()
+	}
+	return parseFields(fields, defCols), nil
+}
+
+func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	params := apihelpers.ToParamDescs(o.ExtraParams(gadgetCtx)).ToParams()
+	params.CopyFromMap(o.paramValues, "")
 
-	modes, err := apihelpers.GetStringValuesPerDataSource(params.Get(ParamMode).AsString())
+	fieldLookup := parseFieldsParam(params.Get(ParamFields).AsString())
+
+	modes, err := parseModes(params.Get(ParamMode).AsString())
 	if err != nil {
 		return fmt.Errorf("parsing default output modes: %w", err)
 	}
@@ -315,6 +357,7 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 			}
 		}
 
+		mode, modeArg := splitMode(mode)
 		if !slices.Contains(o.supportedOutputModes[ds.Name()], mode) {
 			gadgetCtx.Logger().Warnf("output mode %q for data source %q is not supported; skipping data source",
 				mode, ds.Name())
@@ -415,6 +458,37 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 					return nil
 				}, Priority)
 			}
+		case ModeJSONPath, ModeCustomColumns:
+			printer, err := NewEventPrinter(mode + "=" + modeArg)
+			if err != nil {
+				return fmt.Errorf("data source %q: %w", ds.Name(), err)
+			}
+			if header := printer.Header(); header != "" {
+				fmt.Println(header)
+			}
+
+			jsonFormatter, err := json.New(ds, json.WithShowAll(true))
+			if err != nil {
+				gadgetCtx.Logger().Warnf("failed to initialize JSON formatter: %v; skipping data source %q", err, ds.Name())
+				continue
+			}
+
+			// Each element of arrays is printed like a single event
+			switch ds.Type() {
+			case datasource.TypeSingle:
+				ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+					return printer.Print(os.Stdout, jsonFormatter.Marshal(data))
+				}, Priority)
+			case datasource.TypeArray:
+				ds.SubscribeArray(func(ds datasource.DataSource, dataArray datasource.DataArray) error {
+					for i := 0; i < dataArray.Len(); i++ {
+						if err := printer.Print(os.Stdout, jsonFormatter.Marshal(dataArray.Get(i))); err != nil {
+							return err
+						}
+					}
+					return nil
+				}, Priority)
+			}
 		case ModeJSON, ModeJSONPretty, ModeYAML:
 			// var opts []json.Option
 			// if hasFields {
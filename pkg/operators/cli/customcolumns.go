@@ -0,0 +1,149 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	// customColumnsMinWidth and customColumnsPadding are the ones used by
+	// kubectl
+	customColumnsMinWidth = 10
+	customColumnsPadding  = 3
+
+	// customColumnsNone is printed for missing values, like kubectl does
+	customColumnsNone = "<none>"
+)
+
+type customColumn struct {
+	header string
+	jp     *jsonpath.JSONPath
+	width  int
+}
+
+// customColumnsPrinter prints the events formatted as JSON in columns whose
+// values are selected by JSONPath expressions, like the custom-columns output
+// of kubectl. As events are streamed, the widths of the columns can't be
+// known in advance: a column is widened when a longer value is printed.
+type customColumnsPrinter struct {
+	mu      sync.Mutex
+	columns []*customColumn
+}
+
+// relaxedJSONPath accepts the expressions of kubectl's custom columns, like
+// ".proc.pid", "proc.pid" and "{.proc.pid}"
+func relaxedJSONPath(expr string) string {
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		return expr
+	}
+	if !strings.HasPrefix(expr, ".") {
+		expr = "." + expr
+	}
+	return "{" + expr + "}"
+}
+
+// newCustomColumnsPrinter parses a spec like "PID:.proc.pid,COMM:.proc.comm"
+func newCustomColumnsPrinter(spec string) (*customColumnsPrinter, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("missing columns, use %s=<header>:<jsonpath>,...", ModeCustomColumns)
+	}
+
+	p := &customColumnsPrinter{}
+	for _, col := range strings.Split(spec, ",") {
+		header, expr, ok := strings.Cut(col, ":")
+		if !ok || header == "" || expr == "" {
+			return nil, fmt.Errorf("invalid column %q, expected <header>:<jsonpath>", col)
+		}
+
+		jp := jsonpath.New(header).AllowMissingKeys(true)
+		if err := jp.Parse(relaxedJSONPath(expr)); err != nil {
+			return nil, fmt.Errorf("parsing expression of column %q: %w", header, err)
+		}
+		p.columns = append(p.columns, &customColumn{
+			header: header,
+			jp:     jp,
+			width:  max(len(header), customColumnsMinWidth),
+		})
+	}
+	return p, nil
+}
+
+// formatRow pads the values to the width of their columns
+func (p *customColumnsPrinter) formatRow(values []string) string {
+	var sb strings.Builder
+	for i, v := range values {
+		if i == len(values)-1 {
+			sb.WriteString(v)
+			break
+		}
+		fmt.Fprintf(&sb, "%-*s", p.columns[i].width+customColumnsPadding, v)
+	}
+	return sb.String()
+}
+
+func (p *customColumnsPrinter) Header() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	headers := make([]string, 0, len(p.columns))
+	for _, col := range p.columns {
+		headers = append(headers, col.header)
+	}
+	return p.formatRow(headers)
+}
+
+func (p *customColumnsPrinter) Print(w io.Writer, event []byte) error {
+	objs, err := decodeEvents(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Get all the values first, so the rows of an array are aligned
+	var buf bytes.Buffer
+	rows := make([][]string, 0, len(objs))
+	for _, obj := range objs {
+		values := make([]string, len(p.columns))
+		for i, col := range p.columns {
+			buf.Reset()
+			if err := col.jp.Execute(&buf, obj); err != nil {
+				return fmt.Errorf("executing expression of column %q: %w", col.header, err)
+			}
+			values[i] = buf.String()
+			if values[i] == "" {
+				values[i] = customColumnsNone
+			}
+			col.width = max(col.width, len(values[i]))
+		}
+		rows = append(rows, values)
+	}
+
+	var out bytes.Buffer
+	for _, values := range rows {
+		out.WriteString(p.formatRow(values))
+		out.WriteByte('\n')
+	}
+	_, err = w.Write(out.Bytes())
+	return err
+}
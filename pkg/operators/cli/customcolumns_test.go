@@ -0,0 +1,64 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomColumnsPrinter(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewEventPrinter("custom-columns=PID:.proc.pid,COMM:proc.comm,DST:{.dst.addr},MISSING:.foo")
+	require.NoError(t, err)
+	require.Equal(t, "PID          COMM         DST          MISSING", p.Header())
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Print(&buf, []byte(`{"proc":{"pid":1234,"comm":"wget"},"dst":{"addr":"1.1.1.1"}}`)))
+	require.Equal(t, "1234         wget         1.1.1.1      <none>\n", buf.String())
+
+	// Columns are widened for longer values, and the elements of arrays are
+	// printed as single events
+	buf.Reset()
+	require.NoError(t, p.Print(&buf, []byte(`[
+		{"proc":{"pid":1,"comm":"a-very-long-command"},"dst":{"addr":"2001:db8::1"}},
+		{"proc":{"pid":2,"comm":"curl"},"dst":{"addr":"1.1.1.1"}}
+	]`)))
+	require.Equal(t, ""+
+		"1            a-very-long-command   2001:db8::1   <none>\n"+
+		"2            curl                  1.1.1.1       <none>\n",
+		buf.String())
+	require.Equal(t, "PID          COMM                  DST           MISSING", p.Header())
+}
+
+func TestCustomColumnsPrinterBadSpec(t *testing.T) {
+	t.Parallel()
+
+	for _, spec := range []string{"", "PID", "PID:", ":.pid", "PID:{.pid"} {
+		_, err := NewEventPrinter(ModeCustomColumns + "=" + spec)
+		require.Error(t, err, spec)
+	}
+}
+
+func TestParseModesCustomColumns(t *testing.T) {
+	t.Parallel()
+
+	modes, err := parseModes("custom-columns=PID:.proc.pid,COMM:.proc.comm")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"": "custom-columns=PID:.proc.pid,COMM:.proc.comm"}, modes)
+}
@@ -0,0 +1,127 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+)
+
+// EventPrinter prints events encoded as JSON, shaping them like the jsonpath
+// and custom-columns outputs of kubectl. It's also used by the commands of the
+// built-in gadgets.
+type EventPrinter interface {
+	// Header returns the line to print before the events, or an empty
+	// string if there isn't one
+	Header() string
+
+	// Print prints an event followed by a new line. The elements of an
+	// array are printed like single events.
+	Print(w io.Writer, event []byte) error
+}
+
+// NewEventPrinter returns the EventPrinter of an output mode given as
+// <mode>=<argument>, with mode being ModeJSONPath or ModeCustomColumns
+func NewEventPrinter(mode string) (EventPrinter, error) {
+	name, arg := splitMode(mode)
+	switch name {
+	case ModeJSONPath:
+		return newJSONPathPrinter(arg)
+	case ModeCustomColumns:
+		return newCustomColumnsPrinter(arg)
+	}
+	return nil, fmt.Errorf("output mode %q doesn't use a printer", name)
+}
+
+// decodeEvents decodes an event, or the elements of an array of events,
+// encoded as JSON
+func decodeEvents(event []byte) ([]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(event))
+	// Keep the numbers as they are, 64-bit integers would lose precision as
+	// float64
+	dec.UseNumber()
+
+	var obj any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("decoding event: %w", err)
+	}
+	if arr, ok := obj.([]any); ok {
+		return arr, nil
+	}
+	return []any{obj}, nil
+}
+
+// jsonPathPrinter prints the events formatted as JSON using a JSONPath
+// template, like the jsonpath output of kubectl
+type jsonPathPrinter struct {
+	jp *jsonpath.JSONPath
+}
+
+func newJSONPathPrinter(template string) (*jsonPathPrinter, error) {
+	if template == "" {
+		return nil, fmt.Errorf("missing template, use %s=<template>", ModeJSONPath)
+	}
+
+	jp := jsonpath.New(ModeJSONPath).AllowMissingKeys(true)
+	if err := jp.Parse(template); err != nil {
+		return nil, fmt.Errorf("parsing template %q: %w", template, err)
+	}
+	return &jsonPathPrinter{jp: jp}, nil
+}
+
+func (p *jsonPathPrinter) Header() string {
+	return ""
+}
+
+func (p *jsonPathPrinter) Print(w io.Writer, event []byte) error {
+	objs, err := decodeEvents(event)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, obj := range objs {
+		if err := p.jp.Execute(&buf, obj); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+		buf.WriteByte('\n')
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// splitMode splits an output mode like jsonpath=<template> into its name and
+// its argument
+func splitMode(mode string) (string, string) {
+	name, arg, _ := strings.Cut(mode, "=")
+	return name, arg
+}
+
+// parseModes parses the value of ParamMode. JSONPath templates and custom
+// columns for all data sources are taken as they are, as they can contain
+// commas and colons.
+func parseModes(value string) (map[string]string, error) {
+	if strings.HasPrefix(value, ModeJSONPath+"=") || strings.HasPrefix(value, ModeCustomColumns+"=") {
+		return map[string]string{"": value}, nil
+	}
+	return apihelpers.GetStringValuesPerDataSource(value)
+}
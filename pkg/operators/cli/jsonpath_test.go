@@ -0,0 +1,90 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONPathPrinter(t *testing.T) {
+	t.Parallel()
+
+	event := []byte(`{"comm":"wget","dst":{"addr":"1.1.1.1","port":80},"mntns_id":18446744073709551615}`)
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+	}{
+		{
+			name:     "fields",
+			template: "{.comm} {.dst.addr}:{.dst.port}",
+			expected: "wget 1.1.1.1:80\n",
+		},
+		{
+			name:     "big_number",
+			template: "{.mntns_id}",
+			expected: "18446744073709551615\n",
+		},
+		{
+			name:     "missing_field",
+			template: "{.comm}-{.foo}",
+			expected: "wget-\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			p, err := newJSONPathPrinter(test.template)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, p.Print(&buf, event))
+			require.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestJSONPathPrinterBadTemplate(t *testing.T) {
+	t.Parallel()
+
+	_, err := newJSONPathPrinter("")
+	require.Error(t, err)
+
+	_, err = newJSONPathPrinter("{.comm")
+	require.Error(t, err)
+}
+
+func TestParseModes(t *testing.T) {
+	t.Parallel()
+
+	modes, err := parseModes("jsonpath={.comm},{.dst.addr}:{.dst.port}")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"": "jsonpath={.comm},{.dst.addr}:{.dst.port}"}, modes)
+
+	modes, err = parseModes("exec:jsonpath={.comm},open:json")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"exec": "jsonpath={.comm}", "open": "json"}, modes)
+
+	name, arg := splitMode("jsonpath={.comm}")
+	require.Equal(t, ModeJSONPath, name)
+	require.Equal(t, "{.comm}", arg)
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
 )
@@ -50,6 +51,28 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 		attachTo = attachToCfg
 	}
 
+	if ap, ok := i.attachParams[p.Name]; ok {
+		attachToParam := i.paramValues[ap.Key]
+		if attachToParam == "" {
+			attachToParam = ap.DefaultValue
+		}
+		switch {
+		case ap.Part == attachPartBinary:
+			// Only replace the binary, an empty value keeps the one of the
+			// section name
+			if attachToParam != "" {
+				_, symbol, _ := strings.Cut(attachTo, ":")
+				attachToParam = attachToParam + ":" + symbol
+			} else {
+				attachToParam = attachTo
+			}
+		case attachToParam == "":
+			return nil, fmt.Errorf("parameter %q is required to attach program %q", ap.Key, p.Name)
+		}
+		i.logger.Debugf("Overriding attachTo with %q (from parameter %q) for program %q", attachToParam, ap.Key, p.Name)
+		attachTo = attachToParam
+	}
+
 	if attachTo == disabledProgram {
 		i.logger.Debugf("Skipping program %q as it is disabled", p.Name)
 		return nil, nil
@@ -149,3 +172,63 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 		return nil, fmt.Errorf("unsupported program %q of type %q", p.Name, p.Type)
 	}
 }
+
+// attachParam describes a parameter, defined in the params.attach section of
+// the gadget metadata, whose value is used as attach target of some programs.
+// It allows users to choose e.g. the function an uprobe is attached to:
+//
+//	params:
+//	  attach:
+//	    function:
+//	      description: Function to trace, as binary:symbol
+//	      programs:
+//	        - ig_funclat_entry
+//	        - ig_funclat_exit
+//
+// With part set to "binary", the value only replaces the binary of uprobes,
+// keeping the symbol of the section name.
+type attachParam struct {
+	Key          string   `mapstructure:"key"`
+	Description  string   `mapstructure:"description"`
+	DefaultValue string   `mapstructure:"defaultValue"`
+	Part         string   `mapstructure:"part"`
+	Programs     []string `mapstructure:"programs"`
+}
+
+const attachPartBinary = "binary"
+
+func (i *ebpfInstance) populateAttachParams() error {
+	attachParams := map[string]*attachParam{}
+	if err := i.config.UnmarshalKey("params.attach", &attachParams); err != nil {
+		return fmt.Errorf("unmarshalling attach params: %w", err)
+	}
+
+	for name, ap := range attachParams {
+		if ap.Key == "" {
+			ap.Key = name
+		}
+		key := ap.Key
+		if ap.Part != "" && ap.Part != attachPartBinary {
+			return fmt.Errorf("attach param %q: invalid part %q", key, ap.Part)
+		}
+		if len(ap.Programs) == 0 {
+			return fmt.Errorf("attach param %q: no programs given", key)
+		}
+		for _, progName := range ap.Programs {
+			if _, ok := i.collectionSpec.Programs[progName]; !ok {
+				return fmt.Errorf("attach param %q: program %q not found", key, progName)
+			}
+			i.attachParams[progName] = ap
+		}
+
+		i.params[key] = &param{
+			Param: &api.Param{
+				Key:          key,
+				Description:  ap.Description,
+				DefaultValue: ap.DefaultValue,
+				TypeHint:     api.TypeString,
+			},
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/syndtr/gocapability/capability"
+)
+
+const capabilitiesKey = "capabilities"
+
+// programCapabilities returns the capabilities the kernel requires, besides
+// CAP_BPF, to load a program of the given type. See is_net_admin_prog_type()
+// and is_perfmon_prog_type() in kernel/bpf/syscall.c.
+func programCapabilities(progType ebpf.ProgramType) []capability.Cap {
+	switch progType {
+	case ebpf.Kprobe, ebpf.TracePoint, ebpf.PerfEvent, ebpf.RawTracepoint,
+		ebpf.RawTracepointWritable, ebpf.Tracing, ebpf.LSM, ebpf.StructOps:
+		return []capability.Cap{capability.CAP_PERFMON}
+	case ebpf.SchedCLS, ebpf.SchedACT, ebpf.XDP, ebpf.LWTIn, ebpf.LWTOut,
+		ebpf.LWTXmit, ebpf.LWTSeg6Local, ebpf.SkSKB, ebpf.SkMsg,
+		ebpf.FlowDissector, ebpf.CGroupDevice, ebpf.CGroupSock,
+		ebpf.CGroupSockAddr, ebpf.CGroupSockopt, ebpf.CGroupSysctl,
+		ebpf.SockOps, ebpf.Netfilter:
+		return []capability.Cap{capability.CAP_NET_ADMIN}
+	case ebpf.Extension:
+		// Extensions can replace programs of any type
+		return []capability.Cap{capability.CAP_NET_ADMIN, capability.CAP_PERFMON}
+	}
+	return nil
+}
+
+// requiredCapabilities returns the capabilities needed to load all programs
+// of the collection.
+func requiredCapabilities(spec *ebpf.CollectionSpec) []capability.Cap {
+	caps := []capability.Cap{capability.CAP_BPF}
+	for _, p := range spec.Programs {
+		for _, c := range programCapabilities(p.Type) {
+			if !slices.Contains(caps, c) {
+				caps = append(caps, c)
+			}
+		}
+	}
+	slices.Sort(caps)
+	return caps
+}
+
+func parseCapability(name string) (capability.Cap, error) {
+	name = strings.TrimPrefix(strings.ToLower(name), "cap_")
+	for _, c := range capability.List() {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown capability %q", name)
+}
+
+// loadCapabilities returns the capabilities used to load the collection. If
+// the gadget declares its capabilities in the metadata, they must include the
+// ones required by its programs. Otherwise, CAP_PERFMON is added to the
+// required ones, as the verifier only allows pointer arithmetic, speculative
+// execution and bounded loops in a relaxed way with it.
+func (i *ebpfInstance) loadCapabilities() ([]capability.Cap, error) {
+	required := requiredCapabilities(i.collectionSpec)
+
+	declaredNames := i.config.GetStringSlice(capabilitiesKey)
+	if len(declaredNames) == 0 {
+		if !slices.Contains(required, capability.CAP_PERFMON) {
+			required = append(required, capability.CAP_PERFMON)
+		}
+		return required, nil
+	}
+
+	declared := make([]capability.Cap, 0, len(declaredNames))
+	for _, name := range declaredNames {
+		c, err := parseCapability(name)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", capabilitiesKey, err)
+		}
+		declared = append(declared, c)
+	}
+	for name, p := range i.collectionSpec.Programs {
+		for _, c := range append([]capability.Cap{capability.CAP_BPF}, programCapabilities(p.Type)...) {
+			if !slices.Contains(declared, c) {
+				return nil, fmt.Errorf("program %q of type %s requires CAP_%s, which isn't declared in %s",
+					name, p.Type, strings.ToUpper(c.String()), capabilitiesKey)
+			}
+		}
+	}
+	return declared, nil
+}
+
+// withCapabilities runs fn on a thread whose effective capabilities are
+// restricted to caps. Capabilities are a per-thread attribute, hence the
+// restriction doesn't affect other goroutines. fn is run with the current
+// capabilities if the kernel doesn't know CAP_BPF (Linux < 5.8) or if the
+// process doesn't have it, as CAP_SYS_ADMIN is needed to use eBPF then.
+func withCapabilities(caps []capability.Cap, fn func() error) error {
+	if capability.CAP_LAST_CAP < capability.CAP_BPF {
+		return fn()
+	}
+
+	runtime.LockOSThread()
+
+	current, err := capability.NewPid2(0)
+	if err == nil {
+		err = current.Load()
+	}
+	if err != nil || !current.Get(capability.PERMITTED, capability.CAP_BPF) {
+		runtime.UnlockOSThread()
+		return fn()
+	}
+
+	restricted, err := capability.NewPid2(0)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("getting capabilities: %w", err)
+	}
+	if err := restricted.Load(); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("getting capabilities: %w", err)
+	}
+	// Clear() only works on all the sets at once
+	for _, c := range capability.List() {
+		if !slices.Contains(caps, c) {
+			restricted.Unset(capability.EFFECTIVE, c)
+		}
+	}
+	if err := restricted.Apply(capability.CAPS); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("restricting capabilities: %w", err)
+	}
+
+	fnErr := fn()
+
+	if err := current.Apply(capability.CAPS); err != nil {
+		// Don't unlock the thread: it's terminated once the goroutine exits
+		// instead of being reused with the restricted capabilities.
+		return fmt.Errorf("restoring capabilities: %w", err)
+	}
+	runtime.UnlockOSThread()
+	return fnErr
+}
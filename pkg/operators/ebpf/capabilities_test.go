@@ -0,0 +1,151 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/syndtr/gocapability/capability"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+)
+
+func TestProgramCapabilities(t *testing.T) {
+	netAdmin := []capability.Cap{capability.CAP_NET_ADMIN}
+	perfmon := []capability.Cap{capability.CAP_PERFMON}
+
+	// Every program type known by the library must be listed
+	expected := map[ebpf.ProgramType][]capability.Cap{
+		ebpf.UnspecifiedProgram:    nil,
+		ebpf.SocketFilter:          nil,
+		ebpf.Kprobe:                perfmon,
+		ebpf.SchedCLS:              netAdmin,
+		ebpf.SchedACT:              netAdmin,
+		ebpf.TracePoint:            perfmon,
+		ebpf.XDP:                   netAdmin,
+		ebpf.PerfEvent:             perfmon,
+		ebpf.CGroupSKB:             nil,
+		ebpf.CGroupSock:            netAdmin,
+		ebpf.LWTIn:                 netAdmin,
+		ebpf.LWTOut:                netAdmin,
+		ebpf.LWTXmit:               netAdmin,
+		ebpf.SockOps:               netAdmin,
+		ebpf.SkSKB:                 netAdmin,
+		ebpf.CGroupDevice:          netAdmin,
+		ebpf.SkMsg:                 netAdmin,
+		ebpf.RawTracepoint:         perfmon,
+		ebpf.CGroupSockAddr:        netAdmin,
+		ebpf.LWTSeg6Local:          netAdmin,
+		ebpf.LircMode2:             nil,
+		ebpf.SkReuseport:           nil,
+		ebpf.FlowDissector:         netAdmin,
+		ebpf.CGroupSysctl:          netAdmin,
+		ebpf.RawTracepointWritable: perfmon,
+		ebpf.CGroupSockopt:         netAdmin,
+		ebpf.Tracing:               perfmon,
+		ebpf.StructOps:             perfmon,
+		ebpf.Extension:             {capability.CAP_NET_ADMIN, capability.CAP_PERFMON},
+		ebpf.LSM:                   perfmon,
+		ebpf.SkLookup:              nil,
+		ebpf.Syscall:               nil,
+		ebpf.Netfilter:             netAdmin,
+	}
+
+	for progType := ebpf.UnspecifiedProgram; progType <= ebpf.Netfilter; progType++ {
+		t.Run(progType.String(), func(t *testing.T) {
+			caps, ok := expected[progType]
+			require.True(t, ok, "program type %s not listed", progType)
+			require.Equal(t, caps, programCapabilities(progType))
+		})
+	}
+}
+
+func TestLoadCapabilities(t *testing.T) {
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_sched_cls": {Type: ebpf.SchedCLS},
+			"ig_socket":    {Type: ebpf.SocketFilter},
+		},
+	}
+
+	require.Equal(t, []capability.Cap{capability.CAP_NET_ADMIN, capability.CAP_BPF}, requiredCapabilities(spec))
+
+	type testCase struct {
+		declared    []string
+		expected    []capability.Cap
+		expectedErr bool
+	}
+	tests := map[string]testCase{
+		"not_declared": {
+			expected: []capability.Cap{capability.CAP_NET_ADMIN, capability.CAP_BPF, capability.CAP_PERFMON},
+		},
+		"declared": {
+			declared: []string{"bpf", "CAP_NET_ADMIN"},
+			expected: []capability.Cap{capability.CAP_BPF, capability.CAP_NET_ADMIN},
+		},
+		"missing": {
+			declared:    []string{"bpf"},
+			expectedErr: true,
+		},
+		"unknown": {
+			declared:    []string{"bpf", "net_admin", "foo"},
+			expectedErr: true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := viper.New()
+			if test.declared != nil {
+				config.Set(capabilitiesKey, test.declared)
+			}
+			i := &ebpfInstance{config: config, collectionSpec: spec}
+			caps, err := i.loadCapabilities()
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, caps)
+		})
+	}
+}
+
+func TestWithCapabilities(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	if capability.CAP_LAST_CAP < capability.CAP_BPF {
+		t.Skip("CAP_BPF not supported")
+	}
+
+	hasCap := func(c capability.Cap) bool {
+		caps, err := capability.NewPid2(0)
+		require.NoError(t, err)
+		require.NoError(t, caps.Load())
+		return caps.Get(capability.EFFECTIVE, c)
+	}
+	if !hasCap(capability.CAP_SYS_ADMIN) || !hasCap(capability.CAP_BPF) {
+		t.Skip("CAP_SYS_ADMIN and CAP_BPF required")
+	}
+
+	err := withCapabilities([]capability.Cap{capability.CAP_BPF}, func() error {
+		require.True(t, hasCap(capability.CAP_BPF))
+		require.False(t, hasCap(capability.CAP_SYS_ADMIN))
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -26,6 +26,7 @@ import (
 	"os"
 	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -34,11 +35,13 @@ import (
 	"github.com/cilium/ebpf/link"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
 	"oras.land/oras-go/v2"
 
 	"github.com/inspektor-gadget/inspektor-gadget/internal/version"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/errcodes"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -48,6 +51,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/selftracing"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/tchandler"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
@@ -58,14 +62,21 @@ const (
 
 	typeSplitter = "___"
 
-	ParamIface       = "iface"
-	ParamTraceKernel = "trace-pipe"
+	ParamIface              = "iface"
+	ParamTraceKernel        = "trace-pipe"
+	ParamPerfBufferPages    = gadgets.ParamPerfBufferPages
+	ParamSampleRate         = gadgets.ParamSampleRate
+	ParamMaxEventsPerSecond = gadgets.ParamMaxEventsPerSecond
 
 	// Keep in sync with `include/gadget/kernel_stack_map.h`
 	KernelStackMapName       = "ig_kstack"
 	KernelStackMapMaxEntries = 10000
 	PerfMaxStackDepth        = 127
 
+	// Keep in sync with `include/gadget/user_stack_map.h`
+	UserStackMapName       = "ig_ustack"
+	UserStackMapMaxEntries = 10000
+
 	kernelTypesVar = "kernelTypes"
 )
 
@@ -110,8 +121,9 @@ func (o *ebpfOperator) InstantiateImageOperator(
 		gadgetCtx: gadgetCtx, // context usually should not be stored, but should we really carry it through all funcs?
 		done:      make(chan struct{}),
 
-		logger:  gadgetCtx.Logger(),
-		program: program,
+		logger:        gadgetCtx.Logger(),
+		program:       program,
+		programDigest: desc.Digest.String(),
 
 		// Preallocate maps
 		tracers:      make(map[string]*Tracer),
@@ -131,6 +143,8 @@ func (o *ebpfOperator) InstantiateImageOperator(
 		tcHandlers:     make(map[string]*tchandler.Handler),
 		uprobeTracers:  make(map[string]*uprobetracer.Tracer[api.GadgetData]),
 
+		attachParams: make(map[string]*attachParam),
+
 		paramValues: paramValues,
 	}
 
@@ -154,6 +168,14 @@ func (o *ebpfOperator) InstantiateImageOperator(
 		return nil, fmt.Errorf("evaluating map params: %w", err)
 	}
 
+	if preload, _ := gadgetCtx.GetVar(preloadVar); preload == true {
+		if err := newInstance.preload(gadgetCtx); err != nil {
+			gadgetCtx.Logger().Warnf("preloading eBPF collection: %v", err)
+		} else {
+			gadgetCtx.Logger().Debugf("preloaded eBPF collection")
+		}
+	}
+
 	return newInstance, nil
 }
 
@@ -163,6 +185,7 @@ type ebpfInstance struct {
 	config *viper.Viper
 
 	program        []byte
+	programDigest  string
 	logger         logger.Logger
 	collectionSpec *ebpf.CollectionSpec
 	collection     *ebpf.Collection
@@ -178,6 +201,10 @@ type ebpfInstance struct {
 	tcHandlers     map[string]*tchandler.Handler
 	uprobeTracers  map[string]*uprobetracer.Tracer[api.GadgetData]
 
+	// map from program name to the key of the param overriding its attach
+	// target
+	attachParams map[string]*attachParam
+
 	// map from ebpf variable name to ebpfVar struct
 	vars map[string]*ebpfVar
 
@@ -185,10 +212,18 @@ type ebpfInstance struct {
 
 	containers map[string]*containercollection.Container
 
+	// mntnsFilterMap is the filter map of a preloaded collection, filled
+	// with the mount namespaces of the attached containers
+	mntnsFilterMap *ebpf.Map
+
 	enums      []*enum
 	formatters map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error
 
-	stackIdMap *ebpf.Map
+	stackIdMap     *ebpf.Map
+	userStackIdMap *ebpf.Map
+
+	// number of pages per CPU of the perf buffers of tracers
+	perfBufferPages uint32
 
 	gadgetCtx operators.GadgetContext
 	done      chan struct{}
@@ -198,6 +233,12 @@ type ebpfInstance struct {
 }
 
 func (i *ebpfInstance) loadSpec() error {
+	if spec := specs.get(i.programDigest); spec != nil {
+		i.logger.Debugf("using cached ebpf spec %s", i.programDigest)
+		i.collectionSpec = spec
+		return nil
+	}
+
 	progReader := bytes.NewReader(i.program)
 	spec, err := ebpf.LoadCollectionSpecFromReader(progReader)
 	if err != nil {
@@ -208,6 +249,10 @@ func (i *ebpfInstance) loadSpec() error {
 		return fmt.Errorf("missing types in ebpf spec")
 	}
 
+	// Gadgets use ring buffers where available, and perf buffers otherwise
+	gadgets.FixRingBufMaps(spec.Maps)
+
+	specs.add(i.programDigest, spec)
 	i.collectionSpec = spec
 	return nil
 }
@@ -308,6 +353,21 @@ func (i *ebpfInstance) analyze() error {
 		}
 	}
 
+	// same for user stacks
+	if _, ok := i.collectionSpec.Maps[UserStackMapName]; ok {
+		userStackIdMapSpec := ebpf.MapSpec{
+			Name:       UserStackMapName,
+			Type:       ebpf.StackTrace,
+			KeySize:    4,
+			ValueSize:  8 * PerfMaxStackDepth,
+			MaxEntries: UserStackMapMaxEntries,
+		}
+		i.userStackIdMap, err = ebpf.NewMap(&userStackIdMapSpec)
+		if err != nil {
+			return fmt.Errorf("creating user stack id map: %w", err)
+		}
+	}
+
 	// Iterate over programs
 	for name, program := range i.collectionSpec.Programs {
 		i.logger.Debugf("program %q", name)
@@ -519,6 +579,11 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 		}
 	}
 
+	if err := i.populateAttachParams(); err != nil {
+		i.Close()
+		return err
+	}
+
 	i.params[ParamTraceKernel] = &param{
 		Param: &api.Param{
 			Key:          ParamTraceKernel,
@@ -526,6 +591,45 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 			TypeHint:     api.TypeBool,
 		},
 	}
+
+	for _, tracer := range i.tracers {
+		m, ok := i.collectionSpec.Maps[tracer.mapName]
+		if !ok || m.Type != ebpf.PerfEventArray {
+			continue
+		}
+		i.params[ParamPerfBufferPages] = &param{
+			Param: &api.Param{
+				Key:          ParamPerfBufferPages,
+				Description:  "Number of memory pages per CPU of the buffer sending events to user space. Bigger buffers lose fewer events at high rates but use more memory",
+				DefaultValue: strconv.Itoa(gadgets.PerfBufferPages),
+				TypeHint:     api.TypeUint32,
+			},
+		}
+		break
+	}
+
+	// Gadgets built with an older include/gadget/buffer.h can't sample nor
+	// rate limit their events
+	if _, ok := i.collectionSpec.Variables[gadgets.SampleRateName]; ok {
+		i.params[ParamSampleRate] = &param{
+			Param: &api.Param{
+				Key:          ParamSampleRate,
+				Description:  "Keep one event out of this number, randomly, before sending it to user space. 1 keeps all the events",
+				DefaultValue: "1",
+				TypeHint:     api.TypeUint32,
+			},
+		}
+	}
+	if _, ok := i.collectionSpec.Variables[gadgets.MaxEventsPerSecondName]; ok {
+		i.params[ParamMaxEventsPerSecond] = &param{
+			Param: &api.Param{
+				Key:          ParamMaxEventsPerSecond,
+				Description:  "Maximum number of events per second sent to user space, the other ones are dropped. 0 means no limit",
+				DefaultValue: "0",
+				TypeHint:     api.TypeUint32,
+			},
+		}
+	}
 	return nil
 }
 
@@ -550,11 +654,9 @@ func (i *ebpfInstance) tracePipe(gadgetCtx operators.GadgetContext) error {
 	return nil
 }
 
-func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
-	i.logger.Debugf("starting ebpfInstance")
-
-	gadgets.FixBpfKtimeGetBootNs(i.collectionSpec.Programs)
-
+// parseParams returns the params of the gadget, set to the values given for
+// the run
+func (i *ebpfInstance) parseParams() (map[string]*params.Param, error) {
 	parameters := params.Params{}              // used to CopyFromMap
 	paramMap := make(map[string]*params.Param) // used for second iteration
 	for name, p := range i.params {
@@ -564,23 +666,34 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 	}
 	err := parameters.CopyFromMap(i.paramValues, "")
 	if err != nil {
-		return fmt.Errorf("parsing parameter values: %w", err)
-	}
-
-	if paramMap[ParamTraceKernel].AsBool() {
-		err := i.tracePipe(gadgetCtx)
-		if err != nil {
-			return err
-		}
+		return nil, fmt.Errorf("parsing parameter values: %w", err)
 	}
+	return paramMap, nil
+}
 
+// replacements returns the maps and the values of the constants of the
+// collection to use for the run
+func (i *ebpfInstance) replacements(
+	gadgetCtx operators.GadgetContext,
+	paramMap map[string]*params.Param,
+) (map[string]*ebpf.Map, map[string]any, error) {
 	mapReplacements := make(map[string]*ebpf.Map)
 	constReplacements := make(map[string]any)
 
+	if p, ok := paramMap[ParamSampleRate]; ok {
+		constReplacements[gadgets.SampleRateName] = p.AsUint32()
+	}
+	if p, ok := paramMap[ParamMaxEventsPerSecond]; ok {
+		constReplacements[gadgets.MaxEventsPerSecondName] = p.AsUint32()
+	}
+
 	// create map for kernel stack
 	if i.stackIdMap != nil {
 		mapReplacements[KernelStackMapName] = i.stackIdMap
 	}
+	if i.userStackIdMap != nil {
+		mapReplacements[UserStackMapName] = i.userStackIdMap
+	}
 
 	// Set gadget params
 	for name, p := range i.params {
@@ -601,7 +714,7 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 				copy(ipAddr.V6[:], ip)
 				ipAddr.Version = 6
 			} else {
-				return fmt.Errorf("invalid IP address: %v", ipParam)
+				return nil, nil, fmt.Errorf("invalid IP address: %v", ipParam)
 			}
 			constReplacements[name] = ipAddr
 		}
@@ -636,13 +749,79 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 			constReplacements[v.name] = res
 		}
 	}
+	return mapReplacements, constReplacements, nil
+}
+
+func (i *ebpfInstance) loadCollection(gadgetCtx operators.GadgetContext, opts ebpf.CollectionOptions) error {
+	i.logger.Debugf("creating ebpf collection")
+
+	// Load the programs with the capabilities they need only, so a
+	// networking gadget can't e.g. read kernel memory.
+	caps, err := i.loadCapabilities()
+	if err != nil {
+		return err
+	}
+	i.logger.Debugf("loading eBPF collection with capabilities %v", caps)
+
+	_, span := selftracing.StartSpan(gadgetCtx.Context(), "ebpf.LoadCollection",
+		attribute.String("gadget.image", gadgetCtx.ImageName()),
+		attribute.Int("ebpf.programs", len(i.collectionSpec.Programs)),
+		attribute.Int("ebpf.maps", len(i.collectionSpec.Maps)),
+		attribute.Bool("ebpf.btfhub", opts.Programs.KernelTypes != nil),
+	)
+	var collection *ebpf.Collection
+	err = withCapabilities(caps, func() error {
+		var err error
+		collection, err = ebpf.NewCollectionWithOptions(i.collectionSpec, opts)
+		return err
+	})
+	selftracing.End(span, err)
+	if err != nil {
+		if verifierLog := errcodes.VerifierLog(err, errcodes.MaxVerifierLogLines); verifierLog != "" {
+			gadgetCtx.Logger().Debugf("running gadget: verifier error: %s\n", verifierLog)
+		}
+		if errors.Is(err, os.ErrPermission) {
+			return errcodes.Wrap(fmt.Errorf("creating eBPF collection with capabilities %v (check the %q section of the metadata): %w",
+				caps, capabilitiesKey, err))
+		}
+
+		return errcodes.Wrap(fmt.Errorf("creating eBPF collection: %w", err))
+	}
+	i.collection = collection
+	return nil
+}
+
+func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
+	i.logger.Debugf("starting ebpfInstance")
+
+	gadgets.FixBpfKtimeGetBootNs(i.collectionSpec.Programs)
+
+	paramMap, err := i.parseParams()
+	if err != nil {
+		return err
+	}
+
+	if p, ok := paramMap[ParamPerfBufferPages]; ok {
+		i.perfBufferPages = p.AsUint32()
+	}
+
+	if paramMap[ParamTraceKernel].AsBool() {
+		err := i.tracePipe(gadgetCtx)
+		if err != nil {
+			return err
+		}
+	}
+
+	mapReplacements, constReplacements, err := i.replacements(gadgetCtx, paramMap)
+	if err != nil {
+		return err
+	}
 
 	//nolint:staticcheck
 	if err := i.collectionSpec.RewriteConstants(constReplacements); err != nil {
 		return fmt.Errorf("rewriting constants: %w", err)
 	}
 
-	i.logger.Debugf("creating ebpf collection")
 	opts := ebpf.CollectionOptions{
 		MapReplacements: mapReplacements,
 	}
@@ -656,23 +835,42 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 		}
 		opts.Programs.KernelTypes = btfSpec
 	}
-	collection, err := ebpf.NewCollectionWithOptions(i.collectionSpec, opts)
-	if err != nil {
-		var verifierErr *ebpf.VerifierError
-		if errors.As(err, &verifierErr) {
-			gadgetCtx.Logger().Debugf("running gadget: verifier error: %+v\n", verifierErr)
-		}
 
-		return fmt.Errorf("creating eBPF collection: %w", err)
+	if collection := i.takePreloaded(constReplacements, mapReplacements, opts); collection != nil {
+		i.logger.Debugf("using preloaded ebpf collection")
+		i.collection = collection
+	} else if err := i.loadCollection(gadgetCtx, opts); err != nil {
+		return err
 	}
-	i.collection = collection
 
 	for _, tracer := range i.tracers {
 		i.logger.Debugf("starting tracer %q", tracer.mapName)
 		err := i.runTracer(gadgetCtx, tracer)
 		if err != nil {
 			i.Close()
-			return fmt.Errorf("running tracer %q: %w", tracer.mapName, err)
+			return errcodes.Wrap(fmt.Errorf("running tracer %q: %w", tracer.mapName, err))
+		}
+	}
+
+	// Make the arguments of USDT probes available to the programs using the
+	// helpers of gadget/usdt_argument.h
+	if m, ok := i.collection.Maps[uprobetracer.UsdtArgSpecsMapName]; ok {
+		usdtArgSpecs := uprobetracer.NewUsdtArgSpecs(m)
+		for _, uprobeTracer := range i.uprobeTracers {
+			uprobeTracer.SetUsdtArgSpecs(usdtArgSpecs)
+		}
+	}
+
+	// Make the offsets of the Go struct fields listed in the metadata
+	// available to the programs using the helpers of gadget/golang.h
+	if m, ok := i.collection.Maps[uprobetracer.GoOffsetsMapName]; ok {
+		goOffsets, err := uprobetracer.NewGoOffsets(m, i.config.GetStringSlice("goOffsets"))
+		if err != nil {
+			i.Close()
+			return fmt.Errorf("creating Go offsets: %w", err)
+		}
+		for _, uprobeTracer := range i.uprobeTracers {
+			uprobeTracer.SetGoOffsets(goOffsets)
 		}
 	}
 
@@ -681,7 +879,7 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 		l, err := i.attachProgram(gadgetCtx, p, i.collection.Programs[progName])
 		if err != nil {
 			i.Close()
-			return fmt.Errorf("attaching eBPF program %q: %w", progName, err)
+			return errcodes.Wrap(fmt.Errorf("attaching eBPF program %q: %w", progName, err))
 		}
 
 		if l == nil {
@@ -743,6 +941,10 @@ func (i *ebpfInstance) Close() {
 		t.close()
 	}
 
+	i.mu.Lock()
+	i.mntnsFilterMap = nil
+	i.mu.Unlock()
+
 	if i.collection != nil {
 		i.collection.Close()
 		i.collection = nil
@@ -761,6 +963,15 @@ func (i *ebpfInstance) Close() {
 	for _, uprobeTracer := range i.uprobeTracers {
 		uprobeTracer.Close()
 	}
+
+	if i.stackIdMap != nil {
+		i.stackIdMap.Close()
+		i.stackIdMap = nil
+	}
+	if i.userStackIdMap != nil {
+		i.userStackIdMap.Close()
+		i.userStackIdMap = nil
+	}
 }
 
 // Using Attacher interface for network tracers for now
@@ -768,6 +979,12 @@ func (i *ebpfInstance) Close() {
 func (i *ebpfInstance) AttachContainer(container *containercollection.Container) error {
 	i.mu.Lock()
 	i.containers[container.Runtime.ContainerID] = container
+	if i.mntnsFilterMap != nil {
+		if err := i.mntnsFilterMap.Put(container.Mntns, uint32(1)); err != nil {
+			i.mu.Unlock()
+			return fmt.Errorf("adding container to the filter map: %w", err)
+		}
+	}
 	i.mu.Unlock()
 
 	for _, networkTracer := range i.networkTracers {
@@ -796,6 +1013,12 @@ func (i *ebpfInstance) AttachContainer(container *containercollection.Container)
 func (i *ebpfInstance) DetachContainer(container *containercollection.Container) error {
 	i.mu.Lock()
 	delete(i.containers, container.Runtime.ContainerID)
+	if i.mntnsFilterMap != nil {
+		if err := i.mntnsFilterMap.Delete(container.Mntns); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			i.mu.Unlock()
+			return fmt.Errorf("removing container from the filter map: %w", err)
+		}
+	}
 	i.mu.Unlock()
 
 	for _, networkTracer := range i.networkTracers {
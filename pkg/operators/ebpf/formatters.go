@@ -26,11 +26,14 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/usersyms"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/annotations"
 )
 
 const (
 	kernelStackTargetNameAnnotation = "ebpf.formatter.kstack"
+	userStackTargetNameAnnotation   = "ebpf.formatter.ustack"
+	userStackPidAnnotation          = "ebpf.formatter.ustack.pid"
 	enumTargetNameAnnotation        = "ebpf.formatter.enum"
 	enumBitfieldSeparatorAnnotation = "ebpf.formatter.bitfield.separator"
 )
@@ -160,7 +163,7 @@ func (i *ebpfInstance) initStackConverter(gadgetCtx operators.GadgetContext) err
 
 			if kernelSymbolResolver == nil {
 				var err error
-				kernelSymbolResolver, err = kallsyms.NewKAllSyms()
+				kernelSymbolResolver, err = kallsyms.Cached()
 				if err != nil {
 					return err
 				}
@@ -184,6 +187,11 @@ func (i *ebpfInstance) initStackConverter(gadgetCtx operators.GadgetContext) err
 			converter := func(ds datasource.DataSource, data datasource.Data) error {
 				inBytes := in.Get(data)
 				stackId := ds.ByteOrder().Uint32(inBytes)
+				if int32(stackId) < 0 {
+					// the stack wasn't captured or it couldn't be walked
+					out.Set(data, []byte{})
+					return nil
+				}
 
 				stack := [PerfMaxStackDepth]uint64{}
 				err = i.stackIdMap.Lookup(stackId, &stack)
@@ -210,6 +218,96 @@ func (i *ebpfInstance) initStackConverter(gadgetCtx operators.GadgetContext) err
 	return nil
 }
 
+// userStackPidField returns the field with the pid of the process whose
+// address space the user stack in belongs to: the field set by the
+// userStackPidAnnotation annotation or the first field of type gadget_pid
+func userStackPidField(ds datasource.DataSource, in datasource.FieldAccessor) (datasource.FieldAccessor, error) {
+	if name, ok := in.Annotations()[userStackPidAnnotation]; ok {
+		pidField := ds.GetField(name)
+		if pidField == nil {
+			return nil, fmt.Errorf("pid field %q not found", name)
+		}
+		return pidField, nil
+	}
+	pidFields := ds.GetFieldsWithTag("type:" + ebpftypes.PidTypeName)
+	if len(pidFields) == 0 {
+		return nil, fmt.Errorf("no field of type %s found: set the %q annotation", ebpftypes.PidTypeName, userStackPidAnnotation)
+	}
+	return pidFields[0], nil
+}
+
+func (i *ebpfInstance) initUserStackConverter(gadgetCtx operators.GadgetContext) error {
+	var userSymbolResolver *usersyms.Resolver = nil
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, in := range ds.GetFieldsWithTag("type:" + ebpftypes.UserStackTypeName) {
+			if in == nil {
+				continue
+			}
+			in.SetHidden(true, false)
+
+			if userSymbolResolver == nil {
+				userSymbolResolver = usersyms.NewResolver()
+			}
+
+			if i.userStackIdMap == nil {
+				return errors.New("user stack map is not initialized but used. " +
+					"if you are using `gadget_user_stack` as event field, " +
+					"try to include <gadget/user_stack_map.h>")
+			}
+
+			pidField, err := userStackPidField(ds, in)
+			if err != nil {
+				return fmt.Errorf("user stack field %q: %w", in.Name(), err)
+			}
+
+			targetName, err := annotations.GetTargetNameFromAnnotation(i.logger, "ustack", in, userStackTargetNameAnnotation)
+			if err != nil {
+				i.logger.Warnf("Failed to get target name for user stack field %q: %v", in.Name(), err)
+				continue
+			}
+			out, err := ds.AddField(targetName, api.Kind_String, datasource.WithSameParentAs(in))
+			if err != nil {
+				return err
+			}
+			converter := func(ds datasource.DataSource, data datasource.Data) error {
+				stackId := int32(ds.ByteOrder().Uint32(in.Get(data)))
+				if stackId < 0 {
+					// the stack wasn't captured or it couldn't be walked
+					out.Set(data, []byte{})
+					return nil
+				}
+
+				stack := [PerfMaxStackDepth]uint64{}
+				err := i.userStackIdMap.Lookup(uint32(stackId), &stack)
+				if err != nil {
+					i.logger.Warnf("user stack with ID %d is lost: %s", stackId, err.Error())
+					out.Set(data, []byte{})
+					return nil
+				}
+
+				addrs := make([]uint64, 0, PerfMaxStackDepth)
+				for _, addr := range stack {
+					if addr == 0 {
+						break
+					}
+					addrs = append(addrs, addr)
+				}
+
+				pid := uint32(byteSliceAsUint64(pidField.Get(data), false, ds))
+				outString := ""
+				for depth, sym := range userSymbolResolver.Resolve(pid, addrs) {
+					outString += fmt.Sprintf("[%d]%s; ", depth, sym)
+				}
+
+				out.Set(data, []byte(outString))
+				return nil
+			}
+			i.formatters[ds] = append(i.formatters[ds], converter)
+		}
+	}
+	return nil
+}
+
 func (i *ebpfInstance) initFormatters(gadgetCtx operators.GadgetContext) error {
 	if err := i.initEnumFormatter(gadgetCtx); err != nil {
 		return fmt.Errorf("initializing enum formatter: %w", err)
@@ -219,5 +317,9 @@ func (i *ebpfInstance) initFormatters(gadgetCtx operators.GadgetContext) error {
 		return fmt.Errorf("initializing stack converters: %w", err)
 	}
 
+	if err := i.initUserStackConverter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing user stack converters: %w", err)
+	}
+
 	return nil
 }
@@ -17,11 +17,8 @@ package ebpfoperator
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
-	"sync"
 
-	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 )
 
@@ -42,26 +39,6 @@ func hasPrefix(prefix string) prefixFunc {
 	}
 }
 
-var (
-	onceRingbuf      sync.Once
-	ringbufAvailable bool
-)
-
-func isRingbufAvailable() bool {
-	onceRingbuf.Do(func() {
-		ringbuf, err := ebpf.NewMap(&ebpf.MapSpec{
-			Type:       ebpf.RingBuf,
-			MaxEntries: uint32(os.Getpagesize()),
-		})
-
-		ringbuf.Close()
-
-		ringbufAvailable = err == nil
-	})
-
-	return ringbufAvailable
-}
-
 func (i *ebpfInstance) validateGlobalConstVoidPtrVar(t btf.Type, varName string) error {
 	btfVar, ok := t.(*btf.Var)
 	if !ok {
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -81,7 +82,7 @@ func (i *ebpfInstance) mapParams() api.Params {
 		},
 		{
 			Key:          ParamMapIterCount,
-			Description:  "number of map fetch cycles - use 0 for unlimited",
+			Description:  "number of map fetch cycles - use 0 for unlimited; the gadget stops after the last cycle",
 			DefaultValue: "0",
 			TypeHint:     api.TypeInt,
 			Title:        "Map fetch count",
@@ -143,6 +144,17 @@ func (i *ebpfInstance) evaluateMapParams(paramValues api.ParamValues) error {
 }
 
 func (i *ebpfInstance) runMapIterators() error {
+	// The gadget is stopped once all the map iterators fetched their maps
+	// the requested number of times, unless one of them runs indefinitely
+	var remaining atomic.Int32
+	for _, iter := range i.mapIters {
+		if iter.interval == 0 || iter.count == 0 {
+			remaining.Store(0)
+			break
+		}
+		remaining.Add(1)
+	}
+
 	for _, iter := range i.mapIters {
 		iterMap, ok := i.collection.Maps[iter.mapName]
 		if !ok {
@@ -220,6 +232,10 @@ func (i *ebpfInstance) runMapIterators() error {
 					ctr++
 					if iter.count > 0 && ctr >= iter.count {
 						// TODO: close DS
+						if remaining.Add(-1) == 0 {
+							i.logger.Debugf("all map iterators done, stopping gadget")
+							i.gadgetCtx.Cancel()
+						}
 						return
 					}
 				}
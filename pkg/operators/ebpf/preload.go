@@ -0,0 +1,284 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+	"github.com/syndtr/gocapability/capability"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+// preloadVar is set by the gadget service when prefetching a gadget, to get
+// its collection loaded in advance
+const preloadVar = "preload"
+
+// preloadedCollection is the eBPF collection of a gadget loaded in advance with
+// the default values of its parameters, waiting for a run to use it
+type preloadedCollection struct {
+	collection *ebpf.Collection
+
+	// spec, consts and caps are the ones the collection was loaded with, to
+	// load it again once it's used
+	spec   *ebpf.CollectionSpec
+	consts map[string]any
+	caps   []capability.Cap
+
+	logger logger.Logger
+
+	// pinned is the directory where the programs and maps are pinned, if
+	// pinning them succeeded
+	pinned string
+}
+
+// preloadCache keeps a preloaded collection for each prefetched gadget, indexed
+// by the digest of its eBPF object
+type preloadCache struct {
+	mu sync.Mutex
+
+	// pinPath is the bpffs directory where the collections are pinned
+	pinPath string
+	cleaned bool
+
+	collections map[string]*preloadedCollection
+}
+
+var preloaded = &preloadCache{
+	pinPath:     filepath.Join(gadgets.PinPath, "preloaded"),
+	collections: make(map[string]*preloadedCollection),
+}
+
+// load loads spec with the given constants and capabilities, pins its programs
+// and maps and keeps it until a run of the gadget with the given digest takes
+// it. The spec is modified.
+func (c *preloadCache) load(
+	logger logger.Logger,
+	digest string,
+	spec *ebpf.CollectionSpec,
+	consts map[string]any,
+	caps []capability.Cap,
+) error {
+	if digest == "" {
+		return errors.New("missing digest")
+	}
+
+	//nolint:staticcheck
+	if err := spec.RewriteConstants(consts); err != nil {
+		return fmt.Errorf("rewriting constants: %w", err)
+	}
+
+	var collection *ebpf.Collection
+	err := withCapabilities(caps, func() error {
+		var err error
+		collection, err = ebpf.NewCollection(spec)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("creating eBPF collection: %w", err)
+	}
+
+	p := &preloadedCollection{
+		collection: collection,
+		spec:       spec,
+		consts:     consts,
+		caps:       caps,
+		logger:     logger,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if old, ok := c.collections[digest]; ok {
+		old.close()
+	}
+
+	// Pinning is only useful to inspect the preloaded programs and maps, so
+	// they are preloaded even if it fails
+	if err := c.pin(digest, p); err != nil {
+		logger.Warnf("pinning preloaded eBPF collection: %v", err)
+	}
+	c.collections[digest] = p
+	return nil
+}
+
+// take returns the collection preloaded for the gadget with the given digest,
+// if it was loaded with the same constants, and removes it from the cache
+func (c *preloadCache) take(digest string, consts map[string]any) *preloadedCollection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.collections[digest]
+	if !ok || !reflect.DeepEqual(p.consts, consts) {
+		return nil
+	}
+	delete(c.collections, digest)
+	p.unpin()
+	return p
+}
+
+// pin pins the programs and maps of p under a directory named after digest.
+// Collections pinned by a previous instance are removed first.
+func (c *preloadCache) pin(digest string, p *preloadedCollection) error {
+	if !c.cleaned {
+		if err := os.RemoveAll(c.pinPath); err != nil {
+			return fmt.Errorf("removing stale preloaded collections: %w", err)
+		}
+		c.cleaned = true
+	}
+
+	dir := filepath.Join(c.pinPath, strings.ReplaceAll(digest, ":", "_"))
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("removing %q: %w", dir, err)
+	}
+	for _, sub := range []string{"programs", "maps"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return fmt.Errorf("creating folder for pinning: %w", err)
+		}
+	}
+	p.pinned = dir
+
+	for name, prog := range p.collection.Programs {
+		if err := prog.Pin(filepath.Join(dir, "programs", name)); err != nil {
+			p.unpin()
+			return fmt.Errorf("pinning program %q: %w", name, err)
+		}
+	}
+	for name, m := range p.collection.Maps {
+		if err := m.Pin(filepath.Join(dir, "maps", name)); err != nil {
+			p.unpin()
+			return fmt.Errorf("pinning map %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (p *preloadedCollection) unpin() {
+	if p.pinned == "" {
+		return
+	}
+	for _, prog := range p.collection.Programs {
+		prog.Unpin()
+	}
+	for _, m := range p.collection.Maps {
+		m.Unpin()
+	}
+	os.RemoveAll(p.pinned)
+	p.pinned = ""
+}
+
+func (p *preloadedCollection) close() {
+	p.unpin()
+	p.collection.Close()
+}
+
+// preload loads the collection of the gadget with the default values of its
+// parameters, like a run with a container filter would do, and keeps it for
+// the next run. Gadgets needing maps created for each run, e.g. by the socket
+// enricher or for stacks, can't be preloaded.
+func (i *ebpfInstance) preload(gadgetCtx operators.GadgetContext) error {
+	if _, ok := gadgetCtx.GetVar(kernelTypesVar); ok {
+		return errors.New("gadgets using kernel types from BTFHub can't be preloaded")
+	}
+
+	paramMap, err := i.parseParams()
+	if err != nil {
+		return err
+	}
+	mapReplacements, constReplacements, err := i.replacements(gadgetCtx, paramMap)
+	if err != nil {
+		return err
+	}
+	if len(mapReplacements) > 0 {
+		return errors.New("gadgets needing maps created for each run can't be preloaded")
+	}
+	for name, v := range i.vars {
+		if v.refType == reflect.TypeOf(&ebpf.Map{}) && name != gadgets.MntNsFilterMapName {
+			return fmt.Errorf("map %q is provided by another operator on each run", name)
+		}
+	}
+
+	// Runs in Kubernetes and ig daemon filter by containers by default. The
+	// filter map of the preloaded collection is then kept in sync by the run.
+	if _, ok := i.vars[gadgets.FilterByMntNsName]; ok {
+		if _, ok := i.collectionSpec.Maps[gadgets.MntNsFilterMapName]; ok {
+			constReplacements[gadgets.FilterByMntNsName] = true
+		}
+	}
+
+	caps, err := i.loadCapabilities()
+	if err != nil {
+		return err
+	}
+
+	spec := i.collectionSpec.Copy()
+	gadgets.FixBpfKtimeGetBootNs(spec.Programs)
+	return preloaded.load(i.logger, i.programDigest, spec, constReplacements, caps)
+}
+
+// takePreloaded returns the collection preloaded for the gadget, if any and if
+// it can be used by this run: it must have been loaded with the same constants
+// and the only map the run can provide is the mount namespace filter map. The
+// preloaded filter map is then filled with the containers attached to the
+// instance instead. The collection is loaded again in the background for the
+// next run.
+func (i *ebpfInstance) takePreloaded(
+	constReplacements map[string]any,
+	mapReplacements map[string]*ebpf.Map,
+	opts ebpf.CollectionOptions,
+) *ebpf.Collection {
+	if opts.Programs.KernelTypes != nil {
+		return nil
+	}
+	for name := range mapReplacements {
+		if name != gadgets.MntNsFilterMapName {
+			return nil
+		}
+	}
+
+	p := preloaded.take(i.programDigest, constReplacements)
+	if p == nil {
+		return nil
+	}
+
+	go func() {
+		err := preloaded.load(p.logger, i.programDigest, p.spec.Copy(), p.consts, p.caps)
+		if err != nil {
+			p.logger.Warnf("preloading eBPF collection again: %v", err)
+		}
+	}()
+
+	if _, ok := mapReplacements[gadgets.MntNsFilterMapName]; ok {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		i.mntnsFilterMap = p.collection.Maps[gadgets.MntNsFilterMapName]
+		for _, container := range i.containers {
+			if err := i.mntnsFilterMap.Put(container.Mntns, uint32(1)); err != nil {
+				i.logger.Warnf("adding container %q to the filter map: %v", container.Runtime.ContainerName, err)
+			}
+		}
+	}
+	return p.collection
+}
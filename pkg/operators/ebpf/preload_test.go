@@ -0,0 +1,180 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const preloadDigest = "sha256:0123"
+
+func preloadSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			gadgets.MntNsFilterMapName: {
+				Name:       gadgets.MntNsFilterMapName,
+				Type:       ebpf.Hash,
+				KeySize:    8,
+				ValueSize:  4,
+				MaxEntries: 16,
+			},
+		},
+		Programs: map[string]*ebpf.ProgramSpec{
+			"prog": {
+				Name:    "prog",
+				Type:    ebpf.XDP,
+				License: "GPL",
+				Instructions: asm.Instructions{
+					asm.LoadMapPtr(asm.R1, 0).WithReference(gadgets.MntNsFilterMapName),
+					asm.Mov.Imm(asm.R0, 2), // XDP_PASS
+					asm.Return(),
+				},
+			},
+		},
+	}
+}
+
+// newTestPreloadCache returns a cache pinning the collections in a temporary
+// bpffs directory
+func newTestPreloadCache(t *testing.T) *preloadCache {
+	dir, err := os.MkdirTemp("/sys/fs/bpf", "preload-test-")
+	if err != nil {
+		t.Skipf("creating bpffs directory: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	c := &preloadCache{
+		pinPath:     dir,
+		collections: make(map[string]*preloadedCollection),
+	}
+	t.Cleanup(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for _, p := range c.collections {
+			p.close()
+		}
+	})
+	return c
+}
+
+func TestPreloadCache(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	c := newTestPreloadCache(t)
+	l := logger.DefaultLogger()
+	caps := requiredCapabilities(preloadSpec())
+	pinned := filepath.Join(c.pinPath, "sha256_0123")
+
+	require.NoError(t, c.load(l, preloadDigest, preloadSpec(), map[string]any{}, caps))
+	assert.FileExists(t, filepath.Join(pinned, "programs", "prog"))
+	assert.FileExists(t, filepath.Join(pinned, "maps", gadgets.MntNsFilterMapName))
+
+	// Collections loaded with other constants can't be used
+	assert.Nil(t, c.take(preloadDigest, map[string]any{"gadget_param": uint32(1)}))
+	assert.Nil(t, c.take("sha256:4567", map[string]any{}))
+	assert.FileExists(t, filepath.Join(pinned, "programs", "prog"))
+
+	p := c.take(preloadDigest, map[string]any{})
+	require.NotNil(t, p)
+	t.Cleanup(p.collection.Close)
+	assert.NoDirExists(t, pinned)
+	assert.Nil(t, c.take(preloadDigest, map[string]any{}))
+
+	// Loading the collection again replaces the previous one
+	require.NoError(t, c.load(l, preloadDigest, preloadSpec(), map[string]any{}, caps))
+	old := c.collections[preloadDigest].collection
+	require.NoError(t, c.load(l, preloadDigest, preloadSpec(), map[string]any{}, caps))
+	assert.NotSame(t, old, c.collections[preloadDigest].collection)
+	assert.Len(t, c.collections, 1)
+	assert.DirExists(t, pinned)
+}
+
+func TestTakePreloaded(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	c := newTestPreloadCache(t)
+	orig := preloaded
+	preloaded = c
+	t.Cleanup(func() { preloaded = orig })
+
+	consts := map[string]any{}
+	caps := requiredCapabilities(preloadSpec())
+	require.NoError(t, c.load(logger.DefaultLogger(), preloadDigest, preloadSpec(), consts, caps))
+
+	container := func(id string, mntns uint64) *containercollection.Container {
+		return &containercollection.Container{
+			Runtime: containercollection.RuntimeMetadata{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{ContainerID: id},
+			},
+			Mntns: mntns,
+		}
+	}
+
+	i := &ebpfInstance{
+		programDigest: preloadDigest,
+		logger:        logger.DefaultLogger(),
+		containers:    make(map[string]*containercollection.Container),
+	}
+	require.NoError(t, i.AttachContainer(container("a", 41)))
+
+	runFilterMap, err := ebpf.NewMap(preloadSpec().Maps[gadgets.MntNsFilterMapName])
+	require.NoError(t, err)
+	t.Cleanup(func() { runFilterMap.Close() })
+
+	// Maps provided by other operators can't be used by the preloaded programs
+	mapReplacements := map[string]*ebpf.Map{"gadget_sockets": runFilterMap}
+	assert.Nil(t, i.takePreloaded(consts, mapReplacements, ebpf.CollectionOptions{}))
+
+	mapReplacements = map[string]*ebpf.Map{gadgets.MntNsFilterMapName: runFilterMap}
+	collection := i.takePreloaded(consts, mapReplacements, ebpf.CollectionOptions{})
+	require.NotNil(t, collection)
+	t.Cleanup(collection.Close)
+
+	// The filter map of the preloaded collection follows the attached
+	// containers
+	filterMap := collection.Maps[gadgets.MntNsFilterMapName]
+	var value uint32
+	require.NoError(t, filterMap.Lookup(uint64(41), &value))
+	require.NoError(t, i.AttachContainer(container("b", 42)))
+	require.NoError(t, filterMap.Lookup(uint64(42), &value))
+	require.NoError(t, i.DetachContainer(container("a", 41)))
+	require.ErrorIs(t, filterMap.Lookup(uint64(41), &value), ebpf.ErrKeyNotExist)
+
+	// The collection is loaded again for the next run
+	require.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		_, ok := c.collections[preloadDigest]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	next := c.take(preloadDigest, consts)
+	require.NotNil(t, next)
+	t.Cleanup(next.collection.Close)
+	assert.NotSame(t, collection, next.collection)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// maxCachedSpecs is the number of eBPF collection specs kept in the cache
+const maxCachedSpecs = 32
+
+// specCache keeps the collection specs parsed from the eBPF objects of the
+// last gadgets run, so running them again, or running the gadgets prefetched
+// at startup, doesn't parse their ELF objects again
+type specCache struct {
+	mu sync.Mutex
+
+	specs map[string]*ebpf.CollectionSpec
+
+	// digests are the keys of specs, least recently used first
+	digests []string
+}
+
+var specs = &specCache{
+	specs: make(map[string]*ebpf.CollectionSpec),
+}
+
+// get returns a copy of the spec of the eBPF object with the given digest, or
+// nil if it's not cached
+func (c *specCache) get(digest string) *ebpf.CollectionSpec {
+	if digest == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	spec, ok := c.specs[digest]
+	if !ok {
+		return nil
+	}
+	c.touch(digest)
+	return spec.Copy()
+}
+
+// add caches a copy of spec, evicting the least recently used spec if the
+// cache is full
+func (c *specCache) add(digest string, spec *ebpf.CollectionSpec) {
+	if digest == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.specs[digest]; ok {
+		c.touch(digest)
+		return
+	}
+	if len(c.digests) >= maxCachedSpecs {
+		delete(c.specs, c.digests[0])
+		c.digests = c.digests[1:]
+	}
+	c.specs[digest] = spec.Copy()
+	c.digests = append(c.digests, digest)
+}
+
+func (c *specCache) touch(digest string) {
+	idx := slices.Index(c.digests, digest)
+	c.digests = append(slices.Delete(c.digests, idx, idx+1), digest)
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpecCache(t *testing.T) {
+	c := &specCache{specs: make(map[string]*ebpf.CollectionSpec)}
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{"events": {Name: "events", MaxEntries: 1}},
+	}
+
+	assert.Nil(t, c.get("sha256:a"))
+	c.add("sha256:a", spec)
+
+	// Changes to the specs returned or added must not modify the cached one
+	spec.Maps["events"].MaxEntries = 2
+	cached := c.get("sha256:a")
+	require.NotNil(t, cached)
+	assert.EqualValues(t, 1, cached.Maps["events"].MaxEntries)
+	cached.Maps["events"].MaxEntries = 3
+	assert.EqualValues(t, 1, c.get("sha256:a").Maps["events"].MaxEntries)
+
+	// Specs without digest aren't cached
+	c.add("", spec)
+	assert.Nil(t, c.get(""))
+
+	// The least recently used spec is evicted
+	for i := 0; i < maxCachedSpecs; i++ {
+		c.add(fmt.Sprintf("sha256:%d", i), spec)
+		if i == 0 {
+			require.NotNil(t, c.get("sha256:a"))
+		}
+	}
+	assert.NotNil(t, c.get("sha256:a"))
+	assert.Nil(t, c.get("sha256:0"))
+	assert.Len(t, c.specs, maxCachedSpecs)
+}
@@ -16,14 +16,11 @@ package ebpfoperator
 
 import (
 	"fmt"
-	"os"
 	"strings"
 	"sync"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
-	"github.com/cilium/ebpf/perf"
-	"github.com/cilium/ebpf/ringbuf"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
@@ -37,10 +34,8 @@ type Tracer struct {
 	ds       datasource.DataSource
 	accessor datasource.FieldAccessor
 
-	mapType       ebpf.MapType
-	eventSize     uint32 // needed to trim trailing bytes when reading for perf event array
-	ringbufReader *ringbuf.Reader
-	perfReader    *perf.Reader
+	eventSize uint32 // needed to trim trailing bytes when reading for perf event array
+	reader    *gadgets.BufferReader
 }
 
 func validateTracerMap(traceMap *ebpf.MapSpec) error {
@@ -98,61 +93,11 @@ func (i *ebpfInstance) populateTracer(t btf.Type, varName string) error {
 
 func (t *Tracer) receiveEvents(gadgetCtx operators.GadgetContext, wg *sync.WaitGroup) error {
 	defer wg.Done()
-	switch t.mapType {
-	case ebpf.RingBuf:
-		return t.receiveEventsFromRingReader(gadgetCtx)
-	case ebpf.PerfEventArray:
-		return t.receiveEventsFromPerfReader(gadgetCtx)
-	default:
-		return fmt.Errorf("invalid map type")
-	}
-}
-
-func (t *Tracer) receiveEventsFromRingReader(gadgetCtx operators.GadgetContext) error {
-	slowBuf := make([]byte, t.eventSize)
-	lastSlowLen := 0
-	for {
-		rec, err := t.ringbufReader.Read()
-		if err != nil {
-			return err
-		}
-		pSingle, err := t.ds.NewPacketSingle()
-		if err != nil {
-			gadgetCtx.Logger().Warnf("error creating new packet: %v", err)
-			continue
-		}
-		sample := rec.RawSample
-		if uint32(len(rec.RawSample)) < t.eventSize {
-			// event is truncated; we need to copy
-			copy(slowBuf, rec.RawSample)
-
-			// zero difference; TODO: improve
-			if len(rec.RawSample) < lastSlowLen {
-				for i := len(rec.RawSample); i < lastSlowLen; i++ {
-					slowBuf[i] = 0
-				}
-			}
-			lastSlowLen = len(rec.RawSample)
-			sample = slowBuf
-		}
-		err = t.accessor.Set(pSingle, sample)
-		if err != nil {
-			gadgetCtx.Logger().Warnf("error setting buffer: %v", err)
-			t.ds.Release(pSingle)
-			continue
-		}
-		err = t.ds.EmitAndRelease(pSingle)
-		if err != nil {
-			gadgetCtx.Logger().Warnf("error emitting data: %v", err)
-		}
-	}
-}
-
-func (t *Tracer) receiveEventsFromPerfReader(gadgetCtx operators.GadgetContext) error {
 	slowBuf := make([]byte, t.eventSize)
 	lastSlowLen := 0
+	var rec gadgets.BufferRecord
 	for {
-		rec, err := t.perfReader.Read()
+		err := t.reader.ReadInto(&rec)
 		if err != nil {
 			return err
 		}
@@ -196,11 +141,8 @@ func (t *Tracer) receiveEventsFromPerfReader(gadgetCtx operators.GadgetContext)
 }
 
 func (t *Tracer) close() {
-	if t.ringbufReader != nil {
-		t.ringbufReader.Close()
-	}
-	if t.perfReader != nil {
-		t.perfReader.Close()
+	if t.reader != nil {
+		t.reader.Close()
 	}
 }
 
@@ -214,28 +156,15 @@ func (i *ebpfInstance) runTracer(gadgetCtx operators.GadgetContext, tracer *Trac
 		return fmt.Errorf("looking up tracer map %q: not found", tracer.mapName)
 	}
 
-	tracer.mapType = m.Type()
-
+	i.logger.Debugf("creating %s reader for map %q", m.Type(), tracer.mapName)
 	var err error
-	switch m.Type() {
-	case ebpf.RingBuf:
-		i.logger.Debugf("creating ringbuf reader for map %q", tracer.mapName)
-		tracer.ringbufReader, err = ringbuf.NewReader(m)
-	case ebpf.PerfEventArray:
-		i.logger.Debugf("creating perf reader for map %q", tracer.mapName)
-		tracer.perfReader, err = perf.NewReader(m, gadgets.PerfBufferPages*os.Getpagesize())
-	default:
-		return fmt.Errorf("unknown type for tracer map %q", tracer.mapName)
-	}
+	tracer.reader, err = gadgets.NewBufferReader(m, i.perfBufferPages)
 	if err != nil {
 		return fmt.Errorf("creating BPF map reader: %w", err)
 	}
 
-	// TODO: freezing ringbuf doesn't work: "device or resource busy"
-	if m.Type() == ebpf.PerfEventArray {
-		if err := gadgets.FreezeMaps(m); err != nil {
-			return err
-		}
+	if err := gadgets.FreezeMaps(m); err != nil {
+		return err
 	}
 
 	i.wg.Add(1)
@@ -0,0 +1,279 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package evictionrisk is a data operator that tells how likely the pod of
+// the process generating an event is to be evicted by the kubelet because the
+// node is running out of memory, and why. It compares the memory available on
+// the node with the eviction thresholds of the kubelet, and takes the QoS
+// class, the memory pressure (PSI) and the limit of the pod into account. It
+// runs on the node, where the cgroups of the pods can be read.
+package evictionrisk
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "evictionrisk"
+
+	// EnableAnnotation enables the operator on a data source
+	EnableAnnotation = "evictionrisk.enable"
+
+	ParamEvictionHard   = "eviction-hard"
+	ParamEvictionSoft   = "eviction-soft"
+	ParamEvictionMargin = "eviction-margin"
+	ParamPSIThreshold   = "psi-threshold"
+
+	// Priority runs early, before the filter operator, so events can be
+	// filtered by risk
+	Priority = 10
+)
+
+type evictionRiskOperator struct{}
+
+func (o *evictionRiskOperator) Name() string {
+	return name
+}
+
+func (o *evictionRiskOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *evictionRiskOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *evictionRiskOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamEvictionHard,
+			Title:        "Eviction hard thresholds",
+			Description:  "Hard eviction thresholds of the kubelet, as in its --eviction-hard flag. Only memory.available is used",
+			DefaultValue: "memory.available<100Mi",
+		},
+		{
+			Key:         ParamEvictionSoft,
+			Title:       "Eviction soft thresholds",
+			Description: "Soft eviction thresholds of the kubelet, as in its --eviction-soft flag. Only memory.available is used",
+		},
+		{
+			Key:          ParamEvictionMargin,
+			Title:        "Eviction margin",
+			Description:  "Memory available above the hard eviction threshold under which pods are at risk of being evicted",
+			DefaultValue: "500Mi",
+		},
+		{
+			Key:          ParamPSIThreshold,
+			Title:        "PSI threshold",
+			Description:  "Percentage of time a pod stalled on memory in the last 10 seconds (PSI some avg10) over which its risk is raised. 0 disables it",
+			DefaultValue: "10",
+			TypeHint:     api.TypeFloat64,
+		},
+	}
+}
+
+func parseConfig(instanceParamValues api.ParamValues) (*config, error) {
+	cfg := &config{}
+
+	var err error
+	if cfg.hard, err = parseThreshold(instanceParamValues[ParamEvictionHard]); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamEvictionHard, err)
+	}
+	if cfg.soft, err = parseThreshold(instanceParamValues[ParamEvictionSoft]); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamEvictionSoft, err)
+	}
+	if margin := instanceParamValues[ParamEvictionMargin]; margin != "" {
+		q, err := resource.ParseQuantity(margin)
+		if err != nil || q.Sign() < 0 {
+			return nil, fmt.Errorf("invalid %s %q", ParamEvictionMargin, margin)
+		}
+		cfg.margin = uint64(q.Value())
+	}
+	if psi := instanceParamValues[ParamPSIThreshold]; psi != "" {
+		if cfg.psi, err = strconv.ParseFloat(psi, 64); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", ParamPSIThreshold, err)
+		}
+	}
+	return cfg, nil
+}
+
+type dsFields struct {
+	pid datasource.FieldAccessor
+
+	nodeAvailable datasource.FieldAccessor
+	workingSet    datasource.FieldAccessor
+	limit         datasource.FieldAccessor
+	psi           datasource.FieldAccessor
+	qos           datasource.FieldAccessor
+	risk          datasource.FieldAccessor
+	reason        datasource.FieldAccessor
+}
+
+func addFields(ds datasource.DataSource, df *dsFields) error {
+	for _, f := range []struct {
+		acc         *datasource.FieldAccessor
+		name        string
+		kind        api.Kind
+		description string
+		width       string
+		hidden      bool
+	}{
+		{&df.nodeAvailable, "node_memory_available", api.Kind_Uint64, "Memory available on the node in bytes, as MemAvailable in /proc/meminfo", "12", true},
+		{&df.workingSet, "working_set", api.Kind_Uint64, "Memory used by the pod in bytes, without the inactive file pages", "12", true},
+		{&df.limit, "memory_limit", api.Kind_Uint64, "Memory limit of the pod in bytes, 0 without limit", "12", true},
+		{&df.psi, "memory_psi", api.Kind_Float64, "Percentage of time the pod stalled on memory in the last 10 seconds", "8", true},
+		{&df.qos, "qos_class", api.Kind_String, "QoS class of the pod", "10", false},
+		{&df.risk, "eviction_risk", api.Kind_String, "Risk of eviction of the pod: none, low, medium or high", "8", false},
+		{&df.reason, "eviction_reason", api.Kind_String, "Why the pod is at risk of eviction", "48", false},
+	} {
+		annotations := map[string]string{
+			"description":   f.description,
+			"columns.width": f.width,
+		}
+		if f.hidden {
+			annotations["columns.hidden"] = "true"
+		}
+		acc, err := ds.AddField(f.name, f.kind, datasource.WithAnnotations(annotations))
+		if err != nil {
+			return fmt.Errorf("adding field %q: %w", f.name, err)
+		}
+		*f.acc = acc
+	}
+	return nil
+}
+
+func (o *evictionRiskOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	logger := gadgetCtx.Logger()
+	fields := make(map[datasource.DataSource]*dsFields)
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		if ds.Annotations()[EnableAnnotation] != "true" {
+			continue
+		}
+
+		pid := ds.GetField("proc.pid")
+		if pid == nil {
+			pid = ds.GetField("pid")
+		}
+		if pid == nil {
+			logger.Warnf("evictionrisk: data source %q has no pid field, ignoring it", ds.Name())
+			continue
+		}
+
+		df := &dsFields{pid: pid}
+		if err := addFields(ds, df); err != nil {
+			return nil, err
+		}
+		fields[ds] = df
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	cfg, err := parseConfig(instanceParamValues)
+	if err != nil {
+		return nil, err
+	}
+
+	return &evictionRiskOperatorInstance{
+		fields: fields,
+		config: cfg,
+		stats:  newStats(),
+	}, nil
+}
+
+func (o *evictionRiskOperator) Priority() int {
+	return Priority
+}
+
+type evictionRiskOperatorInstance struct {
+	fields map[datasource.DataSource]*dsFields
+	config *config
+	stats  *stats
+}
+
+func (o *evictionRiskOperatorInstance) Name() string {
+	return name
+}
+
+func getUint(f datasource.FieldAccessor, data datasource.Data) uint64 {
+	switch f.Type() {
+	case api.Kind_Uint32:
+		v, _ := f.Uint32(data)
+		return uint64(v)
+	case api.Kind_Uint64:
+		v, _ := f.Uint64(data)
+		return v
+	case api.Kind_Int32:
+		v, _ := f.Int32(data)
+		return uint64(v)
+	}
+	return 0
+}
+
+func (o *evictionRiskOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	logger := gadgetCtx.Logger()
+	for ds, df := range o.fields {
+		df := df
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			pid := uint32(getUint(df.pid, data))
+			if pid == 0 {
+				return nil
+			}
+			node, cg, err := o.stats.get(pid)
+			if err != nil {
+				// The process could be gone already
+				logger.Debugf("evictionrisk: %s", err)
+			}
+			level, reason := assess(o.config, node, cg)
+
+			df.nodeAvailable.PutUint64(data, node.available)
+			df.workingSet.PutUint64(data, cg.workingSet)
+			df.limit.PutUint64(data, cg.limit)
+			df.psi.PutFloat64(data, cg.psi)
+			df.qos.PutString(data, cg.qos)
+			df.risk.PutString(data, level.String())
+			df.reason.PutString(data, reason)
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *evictionRiskOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *evictionRiskOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *evictionRiskOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &evictionRiskOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
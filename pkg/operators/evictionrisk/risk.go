@@ -0,0 +1,196 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evictionrisk
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// memoryAvailableSignal is the only eviction signal of the kubelet about
+// memory
+const memoryAvailableSignal = "memory.available"
+
+// oomRatio is the percentage of its memory limit a cgroup has to use to be
+// reported as likely to be OOM killed
+const oomRatio = 90
+
+// Quality of service classes of the pods, see
+// https://kubernetes.io/docs/concepts/workloads/pods/pod-qos/
+const (
+	qosBestEffort = "BestEffort"
+	qosBurstable  = "Burstable"
+	qosGuaranteed = "Guaranteed"
+)
+
+type riskLevel int
+
+const (
+	riskNone riskLevel = iota
+	riskLow
+	riskMedium
+	riskHigh
+)
+
+func (r riskLevel) String() string {
+	switch r {
+	case riskLow:
+		return "low"
+	case riskMedium:
+		return "medium"
+	case riskHigh:
+		return "high"
+	}
+	return "none"
+}
+
+// threshold is a memory.available eviction threshold of the kubelet, either
+// an absolute quantity or a percentage of the memory of the node
+type threshold struct {
+	bytes   uint64
+	percent float64
+}
+
+// value returns the threshold in bytes for a node with total bytes of memory
+func (t threshold) value(total uint64) uint64 {
+	if t.percent > 0 {
+		return uint64(float64(total) * t.percent / 100)
+	}
+	return t.bytes
+}
+
+// parseThreshold parses the memory.available threshold of eviction
+// thresholds given in the format of the --eviction-hard and --eviction-soft
+// flags of the kubelet, like "memory.available<100Mi,nodefs.available<10%".
+// The other signals are ignored.
+func parseThreshold(s string) (threshold, error) {
+	for _, t := range strings.Split(s, ",") {
+		signal, value, ok := strings.Cut(strings.TrimSpace(t), "<")
+		if !ok {
+			if strings.TrimSpace(t) == "" {
+				continue
+			}
+			return threshold{}, fmt.Errorf("invalid eviction threshold %q: expected signal<value", t)
+		}
+		if strings.TrimSpace(signal) != memoryAvailableSignal {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if p, ok := strings.CutSuffix(value, "%"); ok {
+			percent, err := strconv.ParseFloat(p, 64)
+			if err != nil || percent < 0 || percent > 100 {
+				return threshold{}, fmt.Errorf("invalid percentage %q in eviction threshold", value)
+			}
+			return threshold{percent: percent}, nil
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return threshold{}, fmt.Errorf("invalid quantity %q in eviction threshold: %w", value, err)
+		}
+		if q.Sign() < 0 {
+			return threshold{}, fmt.Errorf("negative quantity %q in eviction threshold", value)
+		}
+		return threshold{bytes: uint64(q.Value())}, nil
+	}
+	return threshold{}, nil
+}
+
+type config struct {
+	hard   threshold
+	soft   threshold
+	margin uint64
+	// psi is the PSI percentage over which the risk is raised, 0 disables it
+	psi float64
+}
+
+type nodeStats struct {
+	total     uint64
+	available uint64
+}
+
+type cgroupStats struct {
+	qos        string
+	workingSet uint64
+	// limit is 0 without limit
+	limit uint64
+	// psi is the "some avg10" memory pressure of the cgroup, the percentage
+	// of the last 10 seconds some of its tasks were stalled on memory
+	psi float64
+}
+
+func formatBytes(b uint64) string {
+	return resource.NewQuantity(int64(b>>20)<<20, resource.BinarySI).String()
+}
+
+// assess tells how likely the pod of a cgroup is to be evicted by the
+// kubelet, and why. The kubelet starts evicting pods when the memory
+// available on the node goes below its eviction thresholds, starting with
+// the pods using more memory than their requests: BestEffort pods always do,
+// Burstable pods may, and Guaranteed pods never do.
+func assess(cfg *config, node nodeStats, cg cgroupStats) (riskLevel, string) {
+	level := riskNone
+	var reasons []string
+
+	if cg.qos != "" && node.total != 0 {
+		hard := cfg.hard.value(node.total)
+		soft := cfg.soft.value(node.total)
+
+		pressure := riskNone
+		switch {
+		case hard > 0 && node.available < hard:
+			pressure = riskMedium
+			reasons = append(reasons, fmt.Sprintf("node memory.available %s below eviction-hard threshold %s",
+				formatBytes(node.available), formatBytes(hard)))
+		case soft > 0 && node.available < soft:
+			pressure = riskLow
+			reasons = append(reasons, fmt.Sprintf("node memory.available %s below eviction-soft threshold %s",
+				formatBytes(node.available), formatBytes(soft)))
+		case node.available < hard+cfg.margin:
+			pressure = riskLow
+			reasons = append(reasons, fmt.Sprintf("node memory.available %s within %s of eviction-hard threshold %s",
+				formatBytes(node.available), formatBytes(cfg.margin), formatBytes(hard)))
+		}
+
+		if pressure != riskNone {
+			switch cg.qos {
+			case qosBestEffort:
+				level = pressure + 1
+				reasons = append(reasons, "BestEffort pods are evicted first")
+			case qosBurstable:
+				level = pressure
+				reasons = append(reasons, "Burstable pods using more than their requests are evicted before Guaranteed ones")
+			case qosGuaranteed:
+				level = pressure - 1
+				reasons = append(reasons, "Guaranteed pods are evicted last")
+			}
+		}
+	}
+
+	if cfg.psi > 0 && cg.psi >= cfg.psi {
+		reasons = append(reasons, fmt.Sprintf("stalled on memory %.1f%% of the time", cg.psi))
+		level = min(level+1, riskHigh)
+	}
+
+	if cg.limit > 0 && cg.workingSet*100 >= cg.limit*oomRatio {
+		reasons = append(reasons, fmt.Sprintf("working set %s at %d%% of memory limit %s, OOM kill likely",
+			formatBytes(cg.workingSet), cg.workingSet*100/cg.limit, formatBytes(cg.limit)))
+		level = max(level, riskMedium)
+	}
+
+	return level, strings.Join(reasons, "; ")
+}
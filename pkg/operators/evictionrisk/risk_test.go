@@ -0,0 +1,202 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evictionrisk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const mi = 1 << 20
+
+func TestParseThreshold(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in       string
+		expected threshold
+		err      bool
+	}{
+		{in: "", expected: threshold{}},
+		{in: "memory.available<100Mi", expected: threshold{bytes: 100 * mi}},
+		{in: "nodefs.available<10%, memory.available<5%", expected: threshold{percent: 5}},
+		{in: "nodefs.available<10%", expected: threshold{}},
+		{in: "memory.available<1Gi,nodefs.available<10%", expected: threshold{bytes: 1024 * mi}},
+		{in: "memory.available=100Mi", err: true},
+		{in: "memory.available<lots", err: true},
+		{in: "memory.available<200%", err: true},
+	}
+	for _, test := range tests {
+		th, err := parseThreshold(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		require.Equal(t, test.expected, th, test.in)
+	}
+
+	require.Equal(t, uint64(400*mi), threshold{percent: 10}.value(4000*mi))
+}
+
+func TestCgroupPaths(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		qos  string
+		pod  string
+	}{
+		{
+			path: "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234abcd_5678_90ab_cdef_1234567890ab.slice/cri-containerd-0123.scope",
+			qos:  qosBurstable,
+			pod:  "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234abcd_5678_90ab_cdef_1234567890ab.slice",
+		},
+		{
+			path: "/kubepods/besteffort/pod1234abcd-5678-90ab-cdef-1234567890ab/0123",
+			qos:  qosBestEffort,
+			pod:  "/kubepods/besteffort/pod1234abcd-5678-90ab-cdef-1234567890ab",
+		},
+		{
+			path: "/kubepods.slice/kubepods-pod1234abcd_5678_90ab_cdef_1234567890ab.slice/cri-containerd-0123.scope",
+			qos:  qosGuaranteed,
+			pod:  "/kubepods.slice/kubepods-pod1234abcd_5678_90ab_cdef_1234567890ab.slice",
+		},
+		{
+			path: "/system.slice/docker-0123.scope",
+			qos:  "",
+			pod:  "/system.slice/docker-0123.scope",
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.qos, qosFromCgroup(test.path), test.path)
+		require.Equal(t, test.pod, podCgroup(test.path), test.path)
+	}
+}
+
+func TestParseStats(t *testing.T) {
+	t.Parallel()
+
+	m := parseKeyValues([]byte("MemTotal:        4000000 kB\nMemFree:  1000 kB\nMemAvailable:    2000000 kB\n"))
+	require.Equal(t, uint64(4000000*1024), m["MemTotal"])
+	require.Equal(t, uint64(2000000*1024), m["MemAvailable"])
+
+	m = parseKeyValues([]byte("anon 1234\ninactive_file 5678\n"))
+	require.Equal(t, uint64(5678), m["inactive_file"])
+
+	psi, err := parsePSI([]byte("some avg10=12.34 avg60=1.00 avg300=0.10 total=1234\nfull avg10=1.00 avg60=0.00 avg300=0.00 total=12\n"))
+	require.NoError(t, err)
+	require.Equal(t, 12.34, psi)
+
+	_, err = parsePSI([]byte(""))
+	require.Error(t, err)
+}
+
+func TestAssess(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config{
+		hard:   threshold{bytes: 100 * mi},
+		margin: 500 * mi,
+		psi:    10,
+	}
+	evicting := nodeStats{total: 4096 * mi, available: 50 * mi}
+	near := nodeStats{total: 4096 * mi, available: 300 * mi}
+	fine := nodeStats{total: 4096 * mi, available: 2048 * mi}
+
+	tests := []struct {
+		name     string
+		node     nodeStats
+		cgroup   cgroupStats
+		expected riskLevel
+		reason   string
+	}{
+		{
+			name:     "besteffort-evicting",
+			node:     evicting,
+			cgroup:   cgroupStats{qos: qosBestEffort},
+			expected: riskHigh,
+			reason:   "node memory.available 50Mi below eviction-hard threshold 100Mi; BestEffort pods are evicted first",
+		},
+		{
+			name:     "burstable-evicting",
+			node:     evicting,
+			cgroup:   cgroupStats{qos: qosBurstable},
+			expected: riskMedium,
+		},
+		{
+			name:     "guaranteed-evicting",
+			node:     evicting,
+			cgroup:   cgroupStats{qos: qosGuaranteed},
+			expected: riskLow,
+		},
+		{
+			name:     "besteffort-close",
+			node:     near,
+			cgroup:   cgroupStats{qos: qosBestEffort},
+			expected: riskMedium,
+			reason:   "node memory.available 300Mi within 500Mi of eviction-hard threshold 100Mi; BestEffort pods are evicted first",
+		},
+		{
+			name:     "guaranteed-close",
+			node:     near,
+			cgroup:   cgroupStats{qos: qosGuaranteed},
+			expected: riskNone,
+			reason:   "node memory.available 300Mi within 500Mi of eviction-hard threshold 100Mi; Guaranteed pods are evicted last",
+		},
+		{
+			name:     "besteffort-fine",
+			node:     fine,
+			cgroup:   cgroupStats{qos: qosBestEffort},
+			expected: riskNone,
+			reason:   "",
+		},
+		{
+			name:     "not-a-pod",
+			node:     evicting,
+			cgroup:   cgroupStats{},
+			expected: riskNone,
+			reason:   "",
+		},
+		{
+			name:     "psi",
+			node:     near,
+			cgroup:   cgroupStats{qos: qosBestEffort, psi: 25},
+			expected: riskHigh,
+		},
+		{
+			name:     "near-limit",
+			node:     fine,
+			cgroup:   cgroupStats{qos: qosGuaranteed, workingSet: 120 * mi, limit: 128 * mi},
+			expected: riskMedium,
+			reason:   "working set 120Mi at 93% of memory limit 128Mi, OOM kill likely",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			level, reason := assess(cfg, test.node, test.cgroup)
+			require.Equal(t, test.expected, level)
+			if test.reason != "" || test.expected == riskNone {
+				require.Equal(t, test.reason, reason)
+			} else {
+				require.NotEmpty(t, reason)
+			}
+		})
+	}
+}
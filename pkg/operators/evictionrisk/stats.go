@@ -0,0 +1,259 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evictionrisk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/cgroups"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// cacheTTL is how long the statistics are kept. Reclaims come in bursts, so
+// the files are read at most once per second.
+const cacheTTL = time.Second
+
+// podCgroupRegex matches the cgroup of a pod with the cgroupfs driver, like
+// pod1234abcd-..., and with the systemd one, like
+// kubepods-burstable-pod1234abcd_....slice
+var podCgroupRegex = regexp.MustCompile(`(^|-)pod[0-9a-f]{8}[-_][0-9a-f]{4}`)
+
+// qosFromCgroup returns the QoS class of the pod from the path of its
+// cgroup, which the kubelet creates under kubepods/besteffort,
+// kubepods/burstable or directly under kubepods for Guaranteed pods. It's
+// empty if the cgroup doesn't belong to a pod.
+func qosFromCgroup(path string) string {
+	switch {
+	case !strings.Contains(path, "kubepods"):
+		return ""
+	case strings.Contains(path, "besteffort"):
+		return qosBestEffort
+	case strings.Contains(path, "burstable"):
+		return qosBurstable
+	}
+	return qosGuaranteed
+}
+
+// podCgroup returns the cgroup of the pod containing the cgroup, or the
+// cgroup itself when it's not in a pod
+func podCgroup(path string) string {
+	for p := path; p != "/" && p != "."; p = filepath.Dir(p) {
+		if podCgroupRegex.MatchString(filepath.Base(p)) {
+			return p
+		}
+	}
+	return path
+}
+
+// parseKeyValues parses files with a "key value" per line, like memory.stat
+// or /proc/meminfo, whose keys end with a colon
+func parseKeyValues(content []byte) map[string]uint64 {
+	ret := map[string]uint64{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) == 3 && fields[2] == "kB" {
+			v *= 1024
+		}
+		ret[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	return ret
+}
+
+// parsePSI returns the "some avg10" value of a pressure file, like
+// "some avg10=1.23 avg60=0.50 avg300=0.10 total=12345"
+func parsePSI(content []byte) (float64, error) {
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		v, ok := strings.CutPrefix(fields[1], "avg10=")
+		if !ok {
+			break
+		}
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("some avg10 not found")
+}
+
+func readUint(path string) (uint64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(content))
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+func readNodeStats() (nodeStats, error) {
+	content, err := os.ReadFile(filepath.Join(host.HostProcFs, "meminfo"))
+	if err != nil {
+		return nodeStats{}, err
+	}
+	m := parseKeyValues(content)
+	return nodeStats{
+		total:     m["MemTotal"],
+		available: m["MemAvailable"],
+	}, nil
+}
+
+// readCgroupStats reads the memory statistics of a cgroup v2. The working set
+// is computed like the kubelet does: the usage minus the inactive file
+// pages, which can be reclaimed easily.
+func readCgroupStats(path string) (cgroupStats, error) {
+	dir, err := cgroups.CgroupPathV2AddMountpoint(path)
+	if err != nil {
+		return cgroupStats{}, err
+	}
+
+	cg := cgroupStats{qos: qosFromCgroup(path)}
+
+	current, err := readUint(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return cgroupStats{}, err
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "memory.stat")); err == nil {
+		if inactive := parseKeyValues(content)["inactive_file"]; inactive < current {
+			current -= inactive
+		} else {
+			current = 0
+		}
+	}
+	cg.workingSet = current
+
+	cg.limit, _ = readUint(filepath.Join(dir, "memory.max"))
+	if content, err := os.ReadFile(filepath.Join(dir, "memory.pressure")); err == nil {
+		cg.psi, _ = parsePSI(content)
+	}
+	return cg, nil
+}
+
+type cachedNode struct {
+	stats nodeStats
+	err   error
+	ts    time.Time
+}
+
+type cachedCgroup struct {
+	path  string
+	stats cgroupStats
+	err   error
+	ts    time.Time
+}
+
+// stats reads the statistics of the node and of the cgroups of the
+// processes, caching them for cacheTTL
+type stats struct {
+	mu      sync.Mutex
+	node    cachedNode
+	pids    map[uint32]*cachedCgroup
+	cgroups map[string]*cachedCgroup
+	expired time.Time
+}
+
+func newStats() *stats {
+	return &stats{
+		pids:    map[uint32]*cachedCgroup{},
+		cgroups: map[string]*cachedCgroup{},
+	}
+}
+
+func (s *stats) nodeStats(now time.Time) (nodeStats, error) {
+	if now.Sub(s.node.ts) > cacheTTL {
+		s.node.stats, s.node.err = readNodeStats()
+		s.node.ts = now
+	}
+	return s.node.stats, s.node.err
+}
+
+// cgroupPath returns the path of the cgroup of the pod of the process
+func (s *stats) cgroupPath(now time.Time, pid uint32) (string, error) {
+	if c, ok := s.pids[pid]; ok && now.Sub(c.ts) <= cacheTTL {
+		return c.path, c.err
+	}
+	_, path, err := cgroups.GetCgroupPaths(int(pid))
+	if err == nil && path == "" {
+		err = fmt.Errorf("process %d not in a cgroup v2", pid)
+	}
+	path = podCgroup(path)
+	s.pids[pid] = &cachedCgroup{path: path, err: err, ts: now}
+	return path, err
+}
+
+func (s *stats) get(pid uint32) (nodeStats, cgroupStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.expired) > 10*cacheTTL {
+		s.expire(now)
+		s.expired = now
+	}
+
+	node, err := s.nodeStats(now)
+	if err != nil {
+		return nodeStats{}, cgroupStats{}, fmt.Errorf("reading node memory: %w", err)
+	}
+
+	path, err := s.cgroupPath(now, pid)
+	if err != nil {
+		return node, cgroupStats{}, fmt.Errorf("getting cgroup: %w", err)
+	}
+	c, ok := s.cgroups[path]
+	if !ok || now.Sub(c.ts) > cacheTTL {
+		c = &cachedCgroup{path: path, ts: now}
+		c.stats, c.err = readCgroupStats(path)
+		s.cgroups[path] = c
+	}
+	if c.err != nil {
+		return node, cgroupStats{}, fmt.Errorf("reading cgroup %q: %w", path, c.err)
+	}
+	return node, c.stats, nil
+}
+
+// expire drops the entries of the processes and cgroups gone, so the maps
+// don't grow forever
+func (s *stats) expire(now time.Time) {
+	for pid, c := range s.pids {
+		if now.Sub(c.ts) > 10*cacheTTL {
+			delete(s.pids, pid)
+		}
+	}
+	for path, c := range s.cgroups {
+		if now.Sub(c.ts) > 10*cacheTTL {
+			delete(s.cgroups, path)
+		}
+	}
+}
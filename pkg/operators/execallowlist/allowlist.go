@@ -0,0 +1,156 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execallowlist
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// AnyImage is the key of the executables expected in all containers
+const AnyImage = "*"
+
+// Allowlist is the content of an allowlist file: the executables expected in
+// the containers of each image. Images are identified by their name, with or
+// without tag, or by their digest. Executables are absolute paths and can
+// contain the wildcards supported by path.Match.
+type Allowlist struct {
+	Images map[string][]string `json:"images"`
+}
+
+func loadAllowlist(filename string) (*Allowlist, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	al := &Allowlist{}
+	if err := yaml.UnmarshalStrict(content, al); err != nil {
+		return nil, fmt.Errorf("parsing %q: %w", filename, err)
+	}
+	for image, executables := range al.Images {
+		for _, exe := range executables {
+			if !path.IsAbs(exe) {
+				return nil, fmt.Errorf("executable %q of image %q isn't an absolute path", exe, image)
+			}
+			if _, err := path.Match(exe, "/"); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q of image %q: %w", exe, image, err)
+			}
+		}
+	}
+	return al, nil
+}
+
+// repository returns the image name without tag and digest
+func repository(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+	}
+	// The tag is after the last slash, a colon before it separates the port
+	// of the registry
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+func matchAny(patterns []string, exe string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, exe); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns whether exe is expected in containers of the image with the
+// given name and digest
+func (al *Allowlist) Allowed(image, digest, exe string) bool {
+	keys := []string{AnyImage}
+	if image != "" {
+		keys = append(keys, image, repository(image))
+	}
+	if digest != "" {
+		keys = append(keys, digest)
+	}
+	for _, key := range keys {
+		if matchAny(al.Images[key], exe) {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder collects the executables seen in each image, to create a baseline
+// allowlist
+type recorder struct {
+	mu     sync.Mutex
+	images map[string]map[string]struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{images: make(map[string]map[string]struct{})}
+}
+
+func (r *recorder) add(image, exe string) {
+	if image == "" {
+		image = AnyImage
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	executables, ok := r.images[image]
+	if !ok {
+		executables = make(map[string]struct{})
+		r.images[image] = executables
+	}
+	executables[exe] = struct{}{}
+}
+
+// allowlist returns the executables seen, merged with the ones of base
+func (r *recorder) allowlist(base *Allowlist) *Allowlist {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	al := &Allowlist{Images: make(map[string][]string)}
+	if base != nil {
+		for image, executables := range base.Images {
+			al.Images[image] = slices.Clone(executables)
+		}
+	}
+	for image, executables := range r.images {
+		for exe := range executables {
+			if !slices.Contains(al.Images[image], exe) {
+				al.Images[image] = append(al.Images[image], exe)
+			}
+		}
+	}
+	for _, executables := range al.Images {
+		slices.Sort(executables)
+	}
+	return al
+}
+
+func writeAllowlist(filename string, al *Allowlist) error {
+	content, err := yaml.Marshal(al)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, content, 0o644)
+}
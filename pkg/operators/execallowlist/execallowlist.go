@@ -0,0 +1,191 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package execallowlist is a data operator that compares the executables
+// run in containers with an allowlist of the ones expected for each image,
+// and only keeps the events of unexpected executables. It can also record
+// the executables seen to create a baseline allowlist. It's meant to be used
+// on the client side.
+package execallowlist
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "execallowlist"
+
+	ParamAllowlist = "exec-allowlist"
+	ParamRecord    = "exec-allowlist-record"
+
+	// PathAnnotation marks the field containing the path of the executable
+	PathAnnotation = "execallowlist.path"
+
+	// Priority is before the filter operator, so only deviations are
+	// filtered
+	Priority = 8950
+)
+
+type execAllowlistOperator struct{}
+
+func (o *execAllowlistOperator) Name() string {
+	return name
+}
+
+func (o *execAllowlistOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *execAllowlistOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *execAllowlistOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:         ParamAllowlist,
+			Title:       "Executables allowlist",
+			Description: "Path of a file with the executables expected for each image. Only executions of other executables are reported",
+		},
+		{
+			Key:         ParamRecord,
+			Title:       "Record executables allowlist",
+			Description: "Path of a file where the executables seen for each image are written when the gadget stops, to be used as allowlist",
+		},
+	}
+}
+
+type dsFields struct {
+	path   datasource.FieldAccessor
+	image  datasource.FieldAccessor
+	digest datasource.FieldAccessor
+}
+
+func (o *execAllowlistOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	fields := make(map[datasource.DataSource]*dsFields)
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, f := range ds.Accessors(false) {
+			if f.Annotations()[PathAnnotation] != "true" {
+				continue
+			}
+			fields[ds] = &dsFields{
+				path:   f,
+				image:  ds.GetField("runtime.containerImageName"),
+				digest: ds.GetField("runtime.containerImageDigest"),
+			}
+			break
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	inst := &execAllowlistOperatorInstance{
+		fields:     fields,
+		recordPath: instanceParamValues[ParamRecord],
+	}
+
+	if filename := instanceParamValues[ParamAllowlist]; filename != "" {
+		al, err := loadAllowlist(filename)
+		if err != nil {
+			return nil, fmt.Errorf("loading executables allowlist: %w", err)
+		}
+		inst.allowlist = al
+	}
+	if inst.recordPath != "" {
+		inst.recorder = newRecorder()
+	}
+	return inst, nil
+}
+
+func (o *execAllowlistOperator) Priority() int {
+	return Priority
+}
+
+type execAllowlistOperatorInstance struct {
+	fields     map[datasource.DataSource]*dsFields
+	allowlist  *Allowlist
+	recorder   *recorder
+	recordPath string
+}
+
+func (o *execAllowlistOperatorInstance) Name() string {
+	return name
+}
+
+func (o *execAllowlistOperatorInstance) handle(df *dsFields, data datasource.Data) error {
+	exe, _ := df.path.String(data)
+	if exe == "" {
+		// Nothing to compare with, like when the execution failed
+		return nil
+	}
+	var image, digest string
+	if df.image != nil {
+		image, _ = df.image.String(data)
+	}
+	if df.digest != nil {
+		digest, _ = df.digest.String(data)
+	}
+
+	if o.recorder != nil {
+		o.recorder.add(image, exe)
+	}
+	if o.allowlist != nil && o.allowlist.Allowed(image, digest, exe) {
+		return datasource.ErrDiscard
+	}
+	return nil
+}
+
+func (o *execAllowlistOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if o.allowlist == nil && o.recorder == nil {
+		return nil
+	}
+	for ds, df := range o.fields {
+		df := df
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			return o.handle(df, data)
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *execAllowlistOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *execAllowlistOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *execAllowlistOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	if o.recorder == nil {
+		return nil
+	}
+	if err := writeAllowlist(o.recordPath, o.recorder.allowlist(o.allowlist)); err != nil {
+		return fmt.Errorf("writing executables allowlist: %w", err)
+	}
+	gadgetCtx.Logger().Infof("executables allowlist written to %q", o.recordPath)
+	return nil
+}
+
+var Operator = &execAllowlistOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
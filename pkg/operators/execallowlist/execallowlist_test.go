@@ -0,0 +1,158 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execallowlist
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+const allowlistContent = `images:
+  docker.io/library/nginx:
+  - /usr/sbin/nginx
+  - /docker-entrypoint.d/*.sh
+  localhost:5000/app:v2:
+  - /app
+  sha256:0123456789abcdef:
+  - /usr/bin/curl
+  "*":
+  - /bin/sh
+`
+
+func TestRepository(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                                "nginx",
+		"nginx:1.25":                           "nginx",
+		"docker.io/library/nginx:1.25":         "docker.io/library/nginx",
+		"docker.io/library/nginx@sha256:abcd":  "docker.io/library/nginx",
+		"localhost:5000/app":                   "localhost:5000/app",
+		"localhost:5000/app:v2":                "localhost:5000/app",
+		"localhost:5000/app:v2@sha256:abcdef0": "localhost:5000/app",
+	} {
+		assert.Equal(t, expected, repository(image), image)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "allowlist.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(allowlistContent), 0o600))
+	al, err := loadAllowlist(filename)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		image, digest, exe string
+		allowed            bool
+	}{
+		{"docker.io/library/nginx:1.25", "", "/usr/sbin/nginx", true},
+		{"docker.io/library/nginx:1.25", "", "/docker-entrypoint.d/10-listen.sh", true},
+		{"docker.io/library/nginx:1.25", "", "/docker-entrypoint.d/conf/x.sh", false},
+		{"docker.io/library/nginx:1.25", "", "/bin/sh", true},
+		{"docker.io/library/nginx:1.25", "", "/tmp/xmrig", false},
+		{"localhost:5000/app:v2", "", "/app", true},
+		{"localhost:5000/app:v3", "", "/app", false},
+		{"busybox", "sha256:0123456789abcdef", "/usr/bin/curl", true},
+		{"busybox", "", "/usr/bin/curl", false},
+		{"", "", "/bin/sh", true},
+	} {
+		assert.Equal(t, tc.allowed, al.Allowed(tc.image, tc.digest, tc.exe), "%s %s", tc.image, tc.exe)
+	}
+
+	for _, content := range []string{
+		"images:\n  nginx:\n  - usr/sbin/nginx\n",
+		"images:\n  nginx:\n  - /usr/[\n",
+		"executables:\n  - /bin/sh\n",
+	} {
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0o600))
+		_, err := loadAllowlist(filename)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestExecAllowlist(t *testing.T) {
+	dir := t.TempDir()
+	allowlistPath := filepath.Join(dir, "allowlist.yaml")
+	recordPath := filepath.Join(dir, "record.yaml")
+	require.NoError(t, os.WriteFile(allowlistPath, []byte(allowlistContent), 0o600))
+
+	var ds datasource.DataSource
+	var image, exepath datasource.FieldAccessor
+	var results []string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "exec")
+			require.NoError(t, err)
+			runtime, err := ds.AddField("runtime", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+			require.NoError(t, err)
+			image, err = runtime.AddSubField("containerImageName", api.Kind_String)
+			require.NoError(t, err)
+			exepath, err = ds.AddField("exepath", api.Kind_String,
+				datasource.WithAnnotations(map[string]string{PathAnnotation: "true"}))
+			require.NoError(t, err)
+			return nil
+		}),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			defer cancel()
+
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				exe, _ := exepath.String(data)
+				results = append(results, exe)
+				return nil
+			}, Priority+1)
+
+			for _, exe := range []string{"/usr/sbin/nginx", "/tmp/xmrig", "/bin/sh", ""} {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				image.PutString(data, "docker.io/library/nginx:1.25")
+				exepath.PutString(data, exe)
+				require.NoError(t, ds.EmitAndRelease(data))
+			}
+			return nil
+		}),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	require.NoError(t, gadgetCtx.Run(api.ParamValues{
+		"operator.execallowlist.exec-allowlist":        allowlistPath,
+		"operator.execallowlist.exec-allowlist-record": recordPath,
+	}))
+
+	assert.Equal(t, []string{"/tmp/xmrig", ""}, results)
+
+	// The recorded allowlist contains the executables seen and the ones of
+	// the allowlist, so it can be extended over several runs
+	recorded, err := loadAllowlist(recordPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/bin/sh", "/tmp/xmrig", "/usr/sbin/nginx"}, recorded.Images["docker.io/library/nginx:1.25"])
+	assert.Equal(t, []string{"/bin/sh"}, recorded.Images[AnyImage])
+	assert.Len(t, recorded.Images, 5)
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package exechash is a data operator that computes the SHA-256 of the
+// binaries executed by processes. It runs on the node, where the binaries
+// can be read through the proc filesystem.
+package exechash
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+const (
+	name = "exechash"
+
+	// TargetAnnotation enables the operator on a field containing the path of
+	// the binary executed by the process. Its value is the name of the field
+	// added next to it with the hash.
+	TargetAnnotation = "exechash.target"
+
+	Priority = 7
+)
+
+type execHashOperator struct {
+	hasher *hasher
+}
+
+func (o *execHashOperator) Name() string {
+	return name
+}
+
+func (o *execHashOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *execHashOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *execHashOperator) InstanceParams() api.Params {
+	return nil
+}
+
+type hashField struct {
+	path datasource.FieldAccessor
+	hash datasource.FieldAccessor
+}
+
+type dsFields struct {
+	pid    datasource.FieldAccessor
+	hashes []hashField
+}
+
+func (o *execHashOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	logger := gadgetCtx.Logger()
+	fields := make(map[datasource.DataSource]*dsFields)
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		pid := ds.GetField("proc.pid")
+		if pid == nil {
+			pid = ds.GetField("pid")
+		}
+
+		var hashes []hashField
+		for _, f := range ds.Accessors(false) {
+			target, ok := f.Annotations()[TargetAnnotation]
+			if !ok {
+				continue
+			}
+			if pid == nil {
+				logger.Warnf("exechash: data source %q has no pid field, ignoring it", ds.Name())
+				break
+			}
+			if f.Type() != api.Kind_String && f.Type() != api.Kind_CString {
+				logger.Debugf("exechash: ignoring non-string field %q of data source %q", f.FullName(), ds.Name())
+				continue
+			}
+			hash, err := ds.AddField(target, api.Kind_String,
+				datasource.WithSameParentAs(f),
+				datasource.WithAnnotations(map[string]string{
+					"description":   fmt.Sprintf("SHA-256 of the binary in %s", f.Name()),
+					"columns.width": "16",
+				}),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("adding field %q: %w", target, err)
+			}
+			hashes = append(hashes, hashField{path: f, hash: hash})
+		}
+		if len(hashes) > 0 {
+			fields[ds] = &dsFields{pid: pid, hashes: hashes}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	h := o.hasher
+	if h == nil {
+		h = newHasher(host.HostProcFs)
+	}
+
+	return &execHashOperatorInstance{
+		fields: fields,
+		hasher: h,
+	}, nil
+}
+
+func (o *execHashOperator) Priority() int {
+	return Priority
+}
+
+type execHashOperatorInstance struct {
+	fields map[datasource.DataSource]*dsFields
+	hasher *hasher
+}
+
+func (o *execHashOperatorInstance) Name() string {
+	return name
+}
+
+func getPid(f datasource.FieldAccessor, data datasource.Data) uint32 {
+	switch f.Type() {
+	case api.Kind_Uint32:
+		v, _ := f.Uint32(data)
+		return v
+	case api.Kind_Int32:
+		v, _ := f.Int32(data)
+		return uint32(v)
+	case api.Kind_Uint64:
+		v, _ := f.Uint64(data)
+		return uint32(v)
+	}
+	return 0
+}
+
+func (o *execHashOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	logger := gadgetCtx.Logger()
+
+	for ds, df := range o.fields {
+		df := df
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			pid := getPid(df.pid, data)
+			if pid == 0 {
+				return nil
+			}
+			for _, hf := range df.hashes {
+				path, _ := hf.path.String(data)
+				sum, err := o.hasher.hash(pid, path)
+				if err != nil {
+					// The process exiting before the event is received is
+					// common for short-lived processes
+					logger.Debugf("exechash: hashing %q of pid %d: %v", path, pid, err)
+					continue
+				}
+				hf.hash.PutString(data, sum)
+			}
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *execHashOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *execHashOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *execHashOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &execHashOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
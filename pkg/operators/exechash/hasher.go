@@ -0,0 +1,136 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exechash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	// maxFileSize is the size above which binaries aren't hashed, to avoid
+	// blocking the events for too long
+	maxFileSize = 256 * 1024 * 1024
+
+	// maxCacheEntries bounds the number of hashes kept in memory. The cache
+	// is emptied once it's reached.
+	maxCacheEntries = 4096
+)
+
+// fileKey identifies a version of a file: a file modified in place gets a
+// different size or modification time
+type fileKey struct {
+	dev   uint64
+	ino   uint64
+	size  int64
+	mtime int64
+}
+
+type hasher struct {
+	mu    sync.Mutex
+	cache map[fileKey]string
+
+	// procFs can be overridden for testing
+	procFs string
+}
+
+func newHasher(procFs string) *hasher {
+	return &hasher{
+		cache:  make(map[fileKey]string),
+		procFs: procFs,
+	}
+}
+
+// open opens the binary executed by the process. /proc/$pid/exe is used when
+// it still points to path, as it works even if the binary was removed.
+// Otherwise, the process executed another binary in the meantime and path is
+// looked up in its root directory.
+func (h *hasher) open(pid uint32, path string) (*os.File, error) {
+	procDir := filepath.Join(h.procFs, strconv.FormatUint(uint64(pid), 10))
+	exe := filepath.Join(procDir, "exe")
+
+	if path == "" {
+		return os.Open(exe)
+	}
+	if link, err := os.Readlink(exe); err == nil && strings.TrimSuffix(link, " (deleted)") == path {
+		return os.Open(exe)
+	}
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("relative path %q", path)
+	}
+	return os.Open(filepath.Join(procDir, "root", path))
+}
+
+// hash returns the SHA-256 of the binary executed by the process, as a hex
+// string
+func (h *hasher) hash(pid uint32, path string) (string, error) {
+	f, err := h.open(pid, path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%q isn't a regular file", path)
+	}
+	if fi.Size() > maxFileSize {
+		return "", fmt.Errorf("%q is too big: %d bytes", path, fi.Size())
+	}
+
+	var key fileKey
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if ok {
+		key = fileKey{
+			dev:   uint64(st.Dev),
+			ino:   st.Ino,
+			size:  st.Size,
+			mtime: fi.ModTime().UnixNano(),
+		}
+		h.mu.Lock()
+		sum, found := h.cache[key]
+		h.mu.Unlock()
+		if found {
+			return sum, nil
+		}
+	}
+
+	s := sha256.New()
+	if _, err := io.Copy(s, f); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(s.Sum(nil))
+
+	if ok {
+		h.mu.Lock()
+		if len(h.cache) >= maxCacheEntries {
+			clear(h.cache)
+		}
+		h.cache[key] = sum
+		h.mu.Unlock()
+	}
+	return sum, nil
+}
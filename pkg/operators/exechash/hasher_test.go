@@ -0,0 +1,67 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exechash
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256File(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHasher(t *testing.T) {
+	h := newHasher("/proc")
+	pid := uint32(os.Getpid())
+
+	// The binary of the process itself, through /proc/$pid/exe
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	sum, err := h.hash(pid, "")
+	require.NoError(t, err)
+	assert.Equal(t, sha256File(t, exe), sum)
+
+	// Another path is looked up in the root of the process
+	path := filepath.Join(t.TempDir(), "payload")
+	require.NoError(t, os.WriteFile(path, []byte("v1"), 0o755))
+	sum, err = h.hash(pid, path)
+	require.NoError(t, err)
+	assert.Equal(t, sha256File(t, path), sum)
+	assert.Len(t, h.cache, 2)
+
+	// Modified files are hashed again
+	require.NoError(t, os.WriteFile(path, []byte("version 2"), 0o755))
+	sum, err = h.hash(pid, path)
+	require.NoError(t, err)
+	assert.Equal(t, sha256File(t, path), sum)
+	assert.Len(t, h.cache, 3)
+
+	_, err = h.hash(pid, "relative/path")
+	assert.Error(t, err)
+	_, err = h.hash(pid, filepath.Dir(path))
+	assert.Error(t, err, "directory")
+	_, err = h.hash(0x7fffffff, "")
+	assert.Error(t, err, "gone process")
+}
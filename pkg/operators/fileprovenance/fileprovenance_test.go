@@ -0,0 +1,133 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileprovenance
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/mountinfo"
+)
+
+func testResolver(t *testing.T) *resolver {
+	root := t.TempDir()
+	upperDir := "/overlay/abc/diff"
+	require.NoError(t, os.MkdirAll(filepath.Join(root, upperDir, "usr/bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, upperDir, "usr/bin/ls"), nil, 0o755))
+
+	info := fmt.Sprintf(`1 0 0:1 / / rw - overlay overlay rw,lowerdir=/overlay/l/A,upperdir=%s,workdir=/overlay/abc/work
+2 1 0:2 / /proc rw - proc proc rw
+3 1 0:3 / /tmp rw - tmpfs tmpfs rw
+4 1 8:1 /volumes/data /data rw - ext4 /dev/sda1 rw
+`, upperDir)
+	mounts, err := mountinfo.Parse(strings.NewReader(info))
+	require.NoError(t, err)
+
+	return &resolver{
+		mounts: mountinfo.NewCache(cacheTTL, func(pid uint32) ([]mountinfo.Mount, error) {
+			if pid != 42 {
+				return nil, os.ErrNotExist
+			}
+			return mounts, nil
+		}),
+		root: root,
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	r := testResolver(t)
+
+	for path, expected := range map[string]Provenance{
+		"/usr/bin/ls":          WritableLayer,
+		"/usr/bin/../bin/ls":   WritableLayer,
+		"/usr/bin/cat":         Image,
+		"/proc/self/status":    Virtual,
+		"/tmp/payload":         Tmpfs,
+		"/data/db.sqlite":      Volume,
+		"usr/bin/ls":           Unknown,
+		"/etc/../usr/bin/sort": Image,
+	} {
+		assert.Equal(t, expected, r.provenance(42, 1234, path), path)
+	}
+	assert.Equal(t, Unknown, r.provenance(43, 0, "/usr/bin/ls"), "gone process")
+}
+
+func TestFileProvenance(t *testing.T) {
+	r := testResolver(t)
+	Operator.resolver = r
+	defer func() { Operator.resolver = nil }()
+
+	var ds datasource.DataSource
+	var pid, fname datasource.FieldAccessor
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var results []string
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "open")
+			require.NoError(t, err)
+			proc, err := ds.AddField("proc", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+			require.NoError(t, err)
+			pid, err = proc.AddSubField("pid", api.Kind_Uint32)
+			require.NoError(t, err)
+			fname, err = ds.AddField("fname", api.Kind_String,
+				datasource.WithAnnotations(map[string]string{TargetAnnotation: "fname_source"}))
+			require.NoError(t, err)
+			return nil
+		}),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			defer cancel()
+
+			source := ds.GetField("fname_source")
+			require.NotNil(t, source)
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				s, _ := source.String(data)
+				results = append(results, s)
+				return nil
+			}, Priority+1)
+
+			for _, path := range []string{"/usr/bin/ls", "/usr/bin/cat", "/data/x", ""} {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				pid.PutUint32(data, 42)
+				fname.PutString(data, path)
+				require.NoError(t, ds.EmitAndRelease(data))
+			}
+			return nil
+		}),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	require.NoError(t, gadgetCtx.Run(api.ParamValues{}))
+
+	assert.Equal(t, []string{"writable-layer", "image", "volume", ""}, results)
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileprovenance
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/mountinfo"
+)
+
+type Provenance string
+
+const (
+	// Unknown is used for relative paths, processes that already exited and
+	// root filesystems that aren't overlays
+	Unknown       Provenance = ""
+	Image         Provenance = "image"
+	WritableLayer Provenance = "writable-layer"
+	Volume        Provenance = "volume"
+	Tmpfs         Provenance = "tmpfs"
+	// Virtual is used for filesystems provided by the kernel, like /proc
+	Virtual Provenance = "virtual"
+)
+
+// virtualFsTypes are the filesystems whose content is provided by the kernel
+var virtualFsTypes = map[string]struct{}{
+	"proc":       {},
+	"sysfs":      {},
+	"cgroup":     {},
+	"cgroup2":    {},
+	"devpts":     {},
+	"devtmpfs":   {},
+	"mqueue":     {},
+	"debugfs":    {},
+	"tracefs":    {},
+	"securityfs": {},
+	"bpf":        {},
+}
+
+// cacheTTL is how long the mounts of a mount namespace are kept. Mounts
+// rarely change during the life of a container.
+const cacheTTL = 10 * time.Second
+
+type resolver struct {
+	mounts *mountinfo.Cache
+
+	// root can be overridden for testing
+	root string
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		mounts: mountinfo.NewCache(cacheTTL, nil),
+		root:   host.HostRoot,
+	}
+}
+
+// provenance returns where path, as seen by the process, comes from
+func (r *resolver) provenance(pid uint32, mntns uint64, path string) Provenance {
+	if !filepath.IsAbs(path) {
+		return Unknown
+	}
+	path = filepath.Clean(path)
+
+	m := mountinfo.Lookup(r.mounts.Get(pid, mntns), path)
+	if m == nil {
+		return Unknown
+	}
+	if _, ok := virtualFsTypes[m.FsType]; ok {
+		return Virtual
+	}
+	if m.MountPoint != "/" {
+		if m.FsType == "tmpfs" {
+			return Tmpfs
+		}
+		return Volume
+	}
+	if m.FsType != "overlay" {
+		return Unknown
+	}
+
+	upperDir, ok := m.Option("upperdir")
+	if !ok {
+		return Image
+	}
+	// Files created, modified or removed by the container are in the upper
+	// directory, removed ones as whiteouts
+	if _, err := os.Lstat(filepath.Join(r.root, upperDir, path)); err == nil {
+		return WritableLayer
+	}
+	return Image
+}
@@ -229,9 +229,24 @@ nextChar:
 }
 
 func (f *filterOperatorInstance) addFilter(gadgetCtx operators.GadgetContext, filter string) error {
+	filterds, ff, err := NewMatcher(gadgetCtx, filter)
+	if err != nil {
+		return err
+	}
+
+	f.ffns[filterds] = append(f.ffns[filterds], ff)
+	return nil
+}
+
+// NewMatcher parses a single filter rule (using the same syntax as the filter
+// operator) and returns the data source it applies to alongside a function that
+// reports whether a given data entry matches the rule.
+func NewMatcher(gadgetCtx operators.GadgetContext, filter string) (
+	datasource.DataSource, func(datasource.DataSource, datasource.Data) bool, error,
+) {
 	dsName, fieldName, op, negate, value, err := extractFilter(filter)
 	if err != nil {
-		return fmt.Errorf("extracting filter rule %q: %w", filter, err)
+		return nil, nil, fmt.Errorf("extracting filter rule %q: %w", filter, err)
 	}
 
 	var filterds datasource.DataSource
@@ -245,23 +260,21 @@ func (f *filterOperatorInstance) addFilter(gadgetCtx operators.GadgetContext, fi
 			continue
 		}
 		if field != nil {
-			return fmt.Errorf("ambiguous field name, please specify the datasource")
+			return nil, nil, fmt.Errorf("ambiguous field name, please specify the datasource")
 		}
 		field = nf
 		filterds = ds
 	}
 
 	if field == nil {
-		return fmt.Errorf("field %q not found", fieldName)
+		return nil, nil, fmt.Errorf("field %q not found", fieldName)
 	}
 
 	ff, err := getFilterFunc(field, op, negate, value)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-
-	f.ffns[filterds] = append(f.ffns[filterds], ff)
-	return nil
+	return filterds, ff, nil
 }
 
 func getFilterFunc(f datasource.FieldAccessor, op comparisonType, negate bool, stringVal string) (
@@ -159,6 +159,13 @@ func handleL3Endpoint(in datasource.FieldAccessor) (func(entry datasource.Data)
 	in.SetHidden(false, false)
 
 	return func(entry datasource.Data) (string, error) {
+		// A zeroed endpoint wasn't set by the gadget, like the addresses
+		// used to group histograms only on demand
+		if v, _ := versions[0].Uint8(entry); v == 0 {
+			addrF.PutString(entry, "")
+			return "", nil
+		}
+
 		addrStr, err := common.GetIPForVersion(entry, versions[0], ips[0])
 		if err != nil {
 			return "", fmt.Errorf("getting IP address: %w", err)
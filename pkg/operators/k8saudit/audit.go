@@ -0,0 +1,219 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8saudit
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// auditEvent holds the fields of an audit.k8s.io/v1 Event that are needed to
+// correlate API actions with node events. The full type lives in
+// k8s.io/apiserver, which isn't worth the dependency.
+type auditEvent struct {
+	AuditID          string           `json:"auditID"`
+	Stage            string           `json:"stage"`
+	RequestURI       string           `json:"requestURI"`
+	User             auditUser        `json:"user"`
+	ImpersonatedUser *auditUser       `json:"impersonatedUser,omitempty"`
+	SourceIPs        []string         `json:"sourceIPs,omitempty"`
+	ObjectRef        *auditObjectRef  `json:"objectRef,omitempty"`
+	ResponseStatus   *auditStatus     `json:"responseStatus,omitempty"`
+	RequestReceived  metav1.MicroTime `json:"requestReceivedTimestamp"`
+	StageTimestamp   metav1.MicroTime `json:"stageTimestamp"`
+}
+
+type auditUser struct {
+	Username string `json:"username"`
+}
+
+type auditObjectRef struct {
+	Resource    string `json:"resource"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Subresource string `json:"subresource"`
+}
+
+type auditStatus struct {
+	Code int32 `json:"code"`
+}
+
+// auditEventList is the body sent by the API server to audit webhooks
+type auditEventList struct {
+	Items []auditEvent `json:"items"`
+}
+
+const (
+	stageResponseComplete = "ResponseComplete"
+	stagePanic            = "Panic"
+)
+
+// session is an exec or attach call made through the API server
+type session struct {
+	id        string
+	user      string
+	action    string
+	sourceIP  string
+	namespace string
+	pod       string
+	container string
+	command   string
+	start     time.Time
+	// end is zero while the session is still open
+	end time.Time
+}
+
+// newSession returns the session described by ev, or nil if ev isn't an exec
+// or attach call on a pod
+func newSession(ev *auditEvent) *session {
+	ref := ev.ObjectRef
+	if ref == nil || ref.Resource != "pods" || ref.Name == "" {
+		return nil
+	}
+	if ref.Subresource != "exec" && ref.Subresource != "attach" {
+		return nil
+	}
+
+	s := &session{
+		id:        ev.AuditID,
+		user:      ev.User.Username,
+		action:    ref.Subresource,
+		namespace: ref.Namespace,
+		pod:       ref.Name,
+		start:     ev.RequestReceived.Time,
+	}
+	if ev.ImpersonatedUser != nil && ev.ImpersonatedUser.Username != "" {
+		s.user += " as " + ev.ImpersonatedUser.Username
+	}
+	if len(ev.SourceIPs) > 0 {
+		s.sourceIP = ev.SourceIPs[0]
+	}
+	if u, err := url.Parse(ev.RequestURI); err == nil {
+		query := u.Query()
+		s.container = query.Get("container")
+		s.command = strings.Join(query["command"], " ")
+	}
+	return s
+}
+
+// cache keeps the exec and attach sessions seen in the audit log and finds the
+// one a node event belongs to
+type cache struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+
+	// window is how long a session that didn't complete yet is considered
+	// open
+	window time.Duration
+	// grace absorbs the clock skew between the API server and the client, and
+	// the latency of node events
+	grace time.Duration
+}
+
+func newCache(window, grace time.Duration) *cache {
+	return &cache{
+		sessions: make(map[string]*session),
+		window:   window,
+		grace:    grace,
+	}
+}
+
+func (c *cache) add(ev *auditEvent, now time.Time) {
+	if ev.AuditID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+
+	s, ok := c.sessions[ev.AuditID]
+	if !ok {
+		s = newSession(ev)
+		if s == nil {
+			return
+		}
+		c.sessions[ev.AuditID] = s
+	}
+
+	// Calls rejected by the API server (e.g. by RBAC) didn't run anything
+	if ev.ResponseStatus != nil && ev.ResponseStatus.Code >= 400 {
+		delete(c.sessions, ev.AuditID)
+		return
+	}
+
+	if ev.Stage == stageResponseComplete || ev.Stage == stagePanic {
+		s.end = ev.StageTimestamp.Time
+	}
+}
+
+// prune removes the sessions that can't match new events anymore. c.mu must
+// be held.
+func (c *cache) prune(now time.Time) {
+	for id, s := range c.sessions {
+		if now.After(s.expiry(c.window, c.grace)) {
+			delete(c.sessions, id)
+		}
+	}
+}
+
+// expiry returns the time after which no event can belong to the session
+func (s *session) expiry(window, grace time.Duration) time.Time {
+	if s.end.IsZero() {
+		return s.start.Add(window)
+	}
+	return s.end.Add(grace)
+}
+
+func (s *session) covers(t time.Time, window, grace time.Duration) bool {
+	if t.Before(s.start.Add(-grace)) {
+		return false
+	}
+	return !t.After(s.expiry(window, grace))
+}
+
+// lookup returns the most recent session on the given pod and container that
+// was open at t
+func (c *cache) lookup(namespace, pod, container string, t time.Time) *session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []*session
+	for _, s := range c.sessions {
+		if s.namespace != namespace || s.pod != pod {
+			continue
+		}
+		if s.container != "" && container != "" && s.container != container {
+			continue
+		}
+		if !s.covers(t, c.window, c.grace) {
+			continue
+		}
+		matches = append(matches, s)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start.After(matches[j].start)
+	})
+	return matches[0]
+}
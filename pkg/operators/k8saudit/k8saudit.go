@@ -0,0 +1,282 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8saudit is a data operator that correlates the exec and attach
+// calls found in the Kubernetes audit log with the events of the gadget, so
+// they show which API user started the processes. It's meant to be used on
+// the client side.
+package k8saudit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "k8saudit"
+
+	ParamLog     = "k8s-audit-log"
+	ParamWebhook = "k8s-audit-webhook"
+	ParamWindow  = "k8s-audit-window"
+
+	// Priority is before the filter operator, so events can be filtered by
+	// API user
+	Priority = 8900
+
+	// grace is the tolerance used when comparing the time of node events with
+	// the timestamps of the API server
+	grace = 5 * time.Second
+)
+
+type k8sAuditOperator struct {
+	// now returns the time of the events; it can be overridden for testing
+	now func() time.Time
+}
+
+func (o *k8sAuditOperator) Name() string {
+	return name
+}
+
+func (o *k8sAuditOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *k8sAuditOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *k8sAuditOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:         ParamLog,
+			Title:       "Kubernetes audit log",
+			Description: "Path of a Kubernetes audit log (JSON format) used to find the API user behind exec and attach sessions",
+		},
+		{
+			Key:         ParamWebhook,
+			Title:       "Kubernetes audit webhook",
+			Description: "Address to listen on for events sent by an audit webhook backend of the API server, e.g. :8090",
+		},
+		{
+			Key:          ParamWindow,
+			Title:        "Kubernetes audit window",
+			Description:  "How long an exec or attach session is considered open when the audit log doesn't show its end",
+			DefaultValue: "10m",
+			TypeHint:     api.TypeDuration,
+		},
+	}
+}
+
+// auditFields are the fields added to data sources with pod information
+type auditFields struct {
+	namespace datasource.FieldAccessor
+	pod       datasource.FieldAccessor
+	container datasource.FieldAccessor
+
+	user     datasource.FieldAccessor
+	action   datasource.FieldAccessor
+	command  datasource.FieldAccessor
+	id       datasource.FieldAccessor
+	sourceIP datasource.FieldAccessor
+}
+
+func addAuditFields(ds datasource.DataSource) (*auditFields, error) {
+	af := &auditFields{
+		namespace: ds.GetField("k8s.namespace"),
+		pod:       ds.GetField("k8s.podName"),
+		container: ds.GetField("k8s.containerName"),
+	}
+
+	parent, err := ds.AddField("audit", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		name   string
+		acc    *datasource.FieldAccessor
+		hidden bool
+	}{
+		{"user", &af.user, false},
+		{"action", &af.action, false},
+		{"command", &af.command, true},
+		{"id", &af.id, true},
+		{"sourceIP", &af.sourceIP, true},
+	} {
+		opts := []datasource.FieldOption{}
+		if f.hidden {
+			opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+		}
+		*f.acc, err = parent.AddSubField(f.name, api.Kind_String, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return af, nil
+}
+
+func (o *k8sAuditOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	var dataSources []datasource.DataSource
+	for _, ds := range gadgetCtx.GetDataSources() {
+		if ds.GetField("k8s.namespace") != nil && ds.GetField("k8s.podName") != nil {
+			dataSources = append(dataSources, ds)
+		}
+	}
+	if len(dataSources) == 0 {
+		return nil, nil
+	}
+
+	// When getting the GadgetInfo, the params aren't set yet; return an
+	// instance to expose them
+	if _, ok := instanceParamValues[ParamWindow]; !ok {
+		return &k8sAuditOperatorInstance{}, nil
+	}
+
+	logPath := instanceParamValues[ParamLog]
+	webhookAddr := instanceParamValues[ParamWebhook]
+	if logPath == "" && webhookAddr == "" {
+		return nil, nil
+	}
+
+	window := 10 * time.Minute
+	if val := instanceParamValues[ParamWindow]; val != "" {
+		var err error
+		window, err = time.ParseDuration(val)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid value for %s: %q", ParamWindow, val)
+		}
+	}
+
+	now := o.now
+	if now == nil {
+		now = time.Now
+	}
+
+	inst := &k8sAuditOperatorInstance{
+		logPath:     logPath,
+		webhookAddr: webhookAddr,
+		cache:       newCache(window, grace),
+		now:         now,
+		fields:      make(map[datasource.DataSource]*auditFields),
+	}
+	for _, ds := range dataSources {
+		af, err := addAuditFields(ds)
+		if err != nil {
+			return nil, fmt.Errorf("adding audit fields to %q: %w", ds.Name(), err)
+		}
+		inst.fields[ds] = af
+	}
+	return inst, nil
+}
+
+func (o *k8sAuditOperator) Priority() int {
+	return Priority
+}
+
+type k8sAuditOperatorInstance struct {
+	logPath     string
+	webhookAddr string
+	cache       *cache
+	now         func() time.Time
+	fields      map[datasource.DataSource]*auditFields
+	cancel      context.CancelFunc
+}
+
+func (o *k8sAuditOperatorInstance) Name() string {
+	return name
+}
+
+func (o *k8sAuditOperatorInstance) add(ev *auditEvent) {
+	o.cache.add(ev, o.now())
+}
+
+func (o *k8sAuditOperatorInstance) enrich(af *auditFields, data datasource.Data) error {
+	namespace, _ := af.namespace.String(data)
+	pod, _ := af.pod.String(data)
+	if pod == "" {
+		return nil
+	}
+	var container string
+	if af.container != nil {
+		container, _ = af.container.String(data)
+	}
+
+	s := o.cache.lookup(namespace, pod, container, o.now())
+	if s == nil {
+		return nil
+	}
+	af.user.PutString(data, s.user)
+	af.action.PutString(data, s.action)
+	af.command.PutString(data, s.command)
+	af.id.PutString(data, s.id)
+	af.sourceIP.PutString(data, s.sourceIP)
+	return nil
+}
+
+func (o *k8sAuditOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if o.cache == nil {
+		return nil
+	}
+
+	// Start reading the audit events before the gadget produces events, so
+	// the sessions are already known
+	ctx, cancel := context.WithCancel(gadgetCtx.Context())
+	o.cancel = cancel
+	log := gadgetCtx.Logger()
+	if o.logPath != "" {
+		if err := tailLog(ctx, o.logPath, o.add, log); err != nil {
+			cancel()
+			return err
+		}
+	}
+	if o.webhookAddr != "" {
+		if err := serveWebhook(ctx, o.webhookAddr, o.add, log); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	for ds, af := range o.fields {
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			return o.enrich(af, data)
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *k8sAuditOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *k8sAuditOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	return nil
+}
+
+func (o *k8sAuditOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &k8sAuditOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
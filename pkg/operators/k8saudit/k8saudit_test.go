@@ -0,0 +1,207 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8saudit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+var t0 = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func execEvent(id, stage, ns, pod, uri string, received, stageTime time.Time, code int32) auditEvent {
+	ev := auditEvent{
+		AuditID:         id,
+		Stage:           stage,
+		RequestURI:      uri,
+		User:            auditUser{Username: "alice"},
+		SourceIPs:       []string{"10.0.0.5"},
+		ObjectRef:       &auditObjectRef{Resource: "pods", Namespace: ns, Name: pod, Subresource: "exec"},
+		RequestReceived: metav1.NewMicroTime(received),
+		StageTimestamp:  metav1.NewMicroTime(stageTime),
+	}
+	if code != 0 {
+		ev.ResponseStatus = &auditStatus{Code: code}
+	}
+	return ev
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(10*time.Minute, grace)
+	uri := "/api/v1/namespaces/default/pods/web/exec?command=sh&command=-c&command=id&container=app&stdin=true"
+
+	ev := execEvent("1", "ResponseStarted", "default", "web", uri, t0, t0, 101)
+	c.add(&ev, t0)
+
+	s := c.lookup("default", "web", "app", t0.Add(time.Second))
+	require.NotNil(t, s)
+	assert.Equal(t, "alice", s.user)
+	assert.Equal(t, "exec", s.action)
+	assert.Equal(t, "sh -c id", s.command)
+	assert.Equal(t, "10.0.0.5", s.sourceIP)
+
+	assert.Nil(t, c.lookup("default", "web", "sidecar", t0.Add(time.Second)), "other container")
+	assert.Nil(t, c.lookup("default", "db", "app", t0.Add(time.Second)), "other pod")
+	assert.Nil(t, c.lookup("default", "web", "app", t0.Add(-time.Minute)), "before the session")
+
+	// Once the session ends, it only matches during the grace period
+	ev = execEvent("1", "ResponseComplete", "default", "web", uri, t0, t0.Add(time.Minute), 101)
+	c.add(&ev, t0.Add(time.Minute))
+	assert.NotNil(t, c.lookup("default", "web", "app", t0.Add(time.Minute+grace)))
+	assert.Nil(t, c.lookup("default", "web", "app", t0.Add(2*time.Minute)))
+
+	// Rejected calls are ignored
+	ev = execEvent("2", "ResponseComplete", "default", "db", uri, t0, t0, 403)
+	c.add(&ev, t0)
+	assert.Nil(t, c.lookup("default", "db", "app", t0))
+
+	// Other API calls are ignored
+	ev = execEvent("3", "ResponseComplete", "default", "web", uri, t0, t0, 200)
+	ev.ObjectRef.Subresource = "log"
+	c.add(&ev, t0)
+	assert.Len(t, c.sessions, 1)
+
+	// Sessions that can't match anymore are removed
+	c.add(&auditEvent{AuditID: "4"}, t0.Add(time.Hour))
+	assert.Empty(t, c.sessions)
+}
+
+func TestK8sAudit(t *testing.T) {
+	Operator.now = func() time.Time { return t0.Add(2 * time.Second) }
+	defer func() { Operator.now = nil }()
+
+	uri := "/api/v1/namespaces/default/pods/web/exec?command=cat&command=%2Fetc%2Fshadow&container=app"
+	ev := execEvent("abc", "ResponseStarted", "default", "web", uri, t0, t0, 101)
+	ev.ImpersonatedUser = &auditUser{Username: "bob"}
+	line, err := json.Marshal(ev)
+	require.NoError(t, err)
+
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(logPath, append(line, '\n'), 0o600))
+
+	var ds datasource.DataSource
+	var ns, pod, container datasource.FieldAccessor
+
+	prepare := func(gadgetCtx operators.GadgetContext) error {
+		var err error
+		ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "events")
+		require.NoError(t, err)
+		k8s, err := ds.AddField("k8s", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+		require.NoError(t, err)
+		ns, err = k8s.AddSubField("namespace", api.Kind_String)
+		require.NoError(t, err)
+		pod, err = k8s.AddSubField("podName", api.Kind_String)
+		require.NoError(t, err)
+		container, err = k8s.AddSubField("containerName", api.Kind_String)
+		require.NoError(t, err)
+		return nil
+	}
+
+	type result struct {
+		user, action, command string
+	}
+	var results []result
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(prepare),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			defer cancel()
+
+			user := ds.GetField("audit.user")
+			action := ds.GetField("audit.action")
+			command := ds.GetField("audit.command")
+			require.NotNil(t, user)
+			require.NotNil(t, action)
+			require.NotNil(t, command)
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				var r result
+				r.user, _ = user.String(data)
+				r.action, _ = action.String(data)
+				r.command, _ = command.String(data)
+				results = append(results, r)
+				return nil
+			}, Priority+1)
+
+			// Give the operator the time to read the audit log
+			time.Sleep(2 * pollInterval)
+
+			for _, p := range []string{"web", "db"} {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				ns.PutString(data, "default")
+				pod.PutString(data, p)
+				container.PutString(data, "app")
+				require.NoError(t, ds.EmitAndRelease(data))
+			}
+			return nil
+		}),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	err = gadgetCtx.Run(api.ParamValues{
+		"operator.k8saudit.k8s-audit-log":    logPath,
+		"operator.k8saudit.k8s-audit-window": "10m",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []result{
+		{"alice as bob", "exec", "cat /etc/shadow"},
+		{"", "", ""},
+	}, results)
+}
+
+func TestK8sAuditDisabled(t *testing.T) {
+	var ds datasource.DataSource
+	producer := simple.New("producer",
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "events")
+			require.NoError(t, err)
+			k8s, err := ds.AddField("k8s", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+			require.NoError(t, err)
+			_, err = k8s.AddSubField("namespace", api.Kind_String)
+			require.NoError(t, err)
+			_, err = k8s.AddSubField("podName", api.Kind_String)
+			return err
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	go cancel()
+	require.NoError(t, gadgetCtx.Run(api.ParamValues{
+		"operator.k8saudit.k8s-audit-window": "10m",
+	}))
+	assert.Nil(t, ds.GetField("audit.user"))
+}
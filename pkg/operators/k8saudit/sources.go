@@ -0,0 +1,170 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8saudit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+const (
+	pollInterval = 250 * time.Millisecond
+
+	// maxWebhookBody limits the size of the batches accepted by the webhook
+	maxWebhookBody = 32 << 20
+)
+
+// tailLog reads the audit log at path, one JSON event per line, and keeps
+// following it until ctx is done. The whole file is read first, so sessions
+// started before the gadget are known too. The file is reopened when it's
+// rotated or truncated.
+func tailLog(ctx context.Context, path string, add func(*auditEvent), log logger.Logger) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %w", err)
+	}
+
+	go func() {
+		defer func() { f.Close() }()
+
+		var offset int64
+		var pending []byte
+		buf := make([]byte, 64*1024)
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			n, err := f.Read(buf)
+			if n > 0 {
+				offset += int64(n)
+				pending = append(pending, buf[:n]...)
+				pending = parseLines(pending, add, log)
+				continue
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				log.Warnf("k8saudit: reading audit log: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if reopened := reopenIfRotated(f, path, offset); reopened != nil {
+				f.Close()
+				f = reopened
+				offset = 0
+				pending = pending[:0]
+			}
+		}
+	}()
+	return nil
+}
+
+// reopenIfRotated returns a new file if the one at path isn't f anymore or if
+// it was truncated
+func reopenIfRotated(f *os.File, path string, offset int64) *os.File {
+	cur, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if os.SameFile(cur, st) && st.Size() >= offset {
+		return nil
+	}
+	nf, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	return nf
+}
+
+// parseLines decodes the complete lines of data and returns what's left
+func parseLines(data []byte, add func(*auditEvent), log logger.Logger) []byte {
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return data
+		}
+		line := bytes.TrimSpace(data[:i])
+		data = data[i+1:]
+		if len(line) == 0 {
+			continue
+		}
+		var ev auditEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			log.Debugf("k8saudit: ignoring invalid audit log line: %v", err)
+			continue
+		}
+		add(&ev)
+	}
+}
+
+// webhookHandler receives the batches of events sent by the API server to an
+// audit webhook backend
+func webhookHandler(add func(*auditEvent), log logger.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var list auditEventList
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxWebhookBody)).Decode(&list); err != nil {
+			log.Debugf("k8saudit: invalid webhook request: %v", err)
+			http.Error(w, "invalid event list", http.StatusBadRequest)
+			return
+		}
+		for i := range list.Items {
+			add(&list.Items[i])
+		}
+	})
+}
+
+// serveWebhook listens on addr and serves the audit webhook until ctx is done
+func serveWebhook(ctx context.Context, addr string, add func(*auditEvent), log logger.Logger) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for audit webhook: %w", err)
+	}
+
+	srv := &http.Server{
+		Handler:           webhookHandler(add, log),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warnf("k8saudit: serving audit webhook: %v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	return nil
+}
@@ -333,6 +333,9 @@ func (m *KubeManagerInstance) enrich(ev any) {
 	if event, canEnrichEventFromNetNs := ev.(operators.ContainerInfoFromNetNSID); canEnrichEventFromNetNs {
 		m.manager.gadgetTracerManager.ContainerCollection.EnrichEventByNetNs(event)
 	}
+	if event, canEnrichEventFromUserNs := ev.(operators.ContainerInfoFromUserNSID); canEnrichEventFromUserNs {
+		event.EnrichUserNs(&m.manager.gadgetTracerManager.ContainerCollection)
+	}
 }
 
 func (m *KubeManagerInstance) EnrichEvent(ev any) error {
@@ -0,0 +1,209 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubevolume is a data operator that tells which Kubernetes volume,
+// like a Secret or a ConfigMap, contains the files accessed by containers. It
+// runs on the node, where the mounts of the containers and of the kubelet can
+// be inspected.
+package kubevolume
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "kubevolume"
+
+	// TargetAnnotation enables the operator on a field containing a path. Its
+	// value is the name of the field added next to it with the volume.
+	TargetAnnotation = "kubevolume.target"
+
+	// Priority runs early, before operators like the redactor change paths
+	Priority = 9
+)
+
+type kubeVolumeOperator struct {
+	resolver *resolver
+}
+
+func (o *kubeVolumeOperator) Name() string {
+	return name
+}
+
+func (o *kubeVolumeOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *kubeVolumeOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *kubeVolumeOperator) InstanceParams() api.Params {
+	return nil
+}
+
+type pathField struct {
+	path   datasource.FieldAccessor
+	volume datasource.FieldAccessor
+}
+
+type dsFields struct {
+	pid   datasource.FieldAccessor
+	mntns datasource.FieldAccessor
+	paths []pathField
+}
+
+func (o *kubeVolumeOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	logger := gadgetCtx.Logger()
+	fields := make(map[datasource.DataSource]*dsFields)
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		var paths []datasource.FieldAccessor
+		for _, f := range ds.Accessors(false) {
+			if _, ok := f.Annotations()[TargetAnnotation]; ok {
+				paths = append(paths, f)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+
+		pid := ds.GetField("proc.pid")
+		if pid == nil {
+			pid = ds.GetField("pid")
+		}
+		if pid == nil {
+			logger.Warnf("kubevolume: data source %q has no pid field, ignoring it", ds.Name())
+			continue
+		}
+
+		df := &dsFields{pid: pid}
+		if mntns := ds.GetFieldsWithTag("type:" + ebpftypes.MntNsTypeName); len(mntns) > 0 {
+			df.mntns = mntns[0]
+		}
+
+		for _, f := range paths {
+			if f.Type() != api.Kind_String && f.Type() != api.Kind_CString {
+				logger.Debugf("kubevolume: ignoring non-string field %q of data source %q", f.FullName(), ds.Name())
+				continue
+			}
+			target := f.Annotations()[TargetAnnotation]
+			volume, err := ds.AddField(target, api.Kind_String,
+				datasource.WithSameParentAs(f),
+				datasource.WithAnnotations(map[string]string{
+					"description":   fmt.Sprintf("Kubernetes volume containing %s, as plugin/name", f.Name()),
+					"columns.width": "24",
+				}),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("adding field %q: %w", target, err)
+			}
+			df.paths = append(df.paths, pathField{path: f, volume: volume})
+		}
+		if len(df.paths) > 0 {
+			fields[ds] = df
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	r := o.resolver
+	if r == nil {
+		r = newResolver()
+	}
+
+	return &kubeVolumeOperatorInstance{
+		fields:   fields,
+		resolver: r,
+	}, nil
+}
+
+func (o *kubeVolumeOperator) Priority() int {
+	return Priority
+}
+
+type kubeVolumeOperatorInstance struct {
+	fields   map[datasource.DataSource]*dsFields
+	resolver *resolver
+}
+
+func (o *kubeVolumeOperatorInstance) Name() string {
+	return name
+}
+
+func getUint(f datasource.FieldAccessor, data datasource.Data) uint64 {
+	switch f.Type() {
+	case api.Kind_Uint32:
+		v, _ := f.Uint32(data)
+		return uint64(v)
+	case api.Kind_Uint64:
+		v, _ := f.Uint64(data)
+		return v
+	case api.Kind_Int32:
+		v, _ := f.Int32(data)
+		return uint64(v)
+	}
+	return 0
+}
+
+func (o *kubeVolumeOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for ds, df := range o.fields {
+		df := df
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			pid := uint32(getUint(df.pid, data))
+			if pid == 0 {
+				return nil
+			}
+			var mntns uint64
+			if df.mntns != nil {
+				mntns = getUint(df.mntns, data)
+			}
+			for _, pf := range df.paths {
+				path, _ := pf.path.String(data)
+				if path == "" {
+					continue
+				}
+				pf.volume.PutString(data, o.resolver.volume(pid, mntns, path))
+			}
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *kubeVolumeOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *kubeVolumeOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *kubeVolumeOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &kubeVolumeOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubevolume
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/mountinfo"
+)
+
+// volumeRegex matches the host path of volumes set up by the kubelet, like
+// /var/lib/kubelet/pods/$uid/volumes/kubernetes.io~secret/$name. The plugin
+// name and the volume name are captured.
+var volumeRegex = regexp.MustCompile(`/volumes/kubernetes\.io~([^/]+)/([^/]+)`)
+
+// cacheTTL is how long the mounts of a mount namespace are kept
+const cacheTTL = 10 * time.Second
+
+type resolver struct {
+	containers *mountinfo.Cache
+	host       *mountinfo.Cache
+}
+
+func newResolver() *resolver {
+	return &resolver{
+		containers: mountinfo.NewCache(cacheTTL, nil),
+		host:       mountinfo.NewCache(cacheTTL, nil),
+	}
+}
+
+func matchVolume(path string) string {
+	m := volumeRegex.FindStringSubmatch(path)
+	if m == nil {
+		return ""
+	}
+	return m[1] + "/" + m[2]
+}
+
+// volume returns the Kubernetes volume containing path, as seen by the
+// process, in the "plugin/name" format, e.g. "secret/db-password". It's empty
+// if path isn't in a volume set up by the kubelet.
+func (r *resolver) volume(pid uint32, mntns uint64, path string) string {
+	if !filepath.IsAbs(path) {
+		return ""
+	}
+	path = filepath.Clean(path)
+
+	m := mountinfo.Lookup(r.containers.Get(pid, mntns), path)
+	if m == nil || m.MountPoint == "/" {
+		return ""
+	}
+	return lookupVolume(m, r.host.Get(1, 0))
+}
+
+// lookupVolume returns the volume mounted by m. Volumes stored on the disk of
+// the node, like ConfigMaps, are bind mounts of a directory of the host
+// filesystem, which is visible in the root of the mount. Volumes stored in
+// memory, like Secrets, are a tmpfs mounted by the kubelet, so the mount
+// point of the same filesystem on the host is needed.
+func lookupVolume(m *mountinfo.Mount, hostMounts []mountinfo.Mount) string {
+	if v := matchVolume(m.Root); v != "" {
+		return v
+	}
+	for _, hm := range hostMounts {
+		if hm.Device != m.Device {
+			continue
+		}
+		// A subdirectory of the filesystem may be mounted in the container
+		if m.Root != hm.Root && !strings.HasPrefix(m.Root, strings.TrimSuffix(hm.Root, "/")+"/") {
+			continue
+		}
+		if v := matchVolume(hm.MountPoint); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubevolume
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/mountinfo"
+)
+
+const containerMountInfo = `1013 954 0:120 / / rw,relatime - overlay overlay rw,lowerdir=/l/A,upperdir=/u/diff,workdir=/u/work
+1014 1013 0:123 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw
+1020 1013 8:1 /var/lib/kubelet/pods/1234/volumes/kubernetes.io~configmap/app-config /etc/app ro,relatime - ext4 /dev/sda1 rw
+1021 1013 0:60 / /etc/db ro,relatime - tmpfs tmpfs rw,size=1024k
+1022 1013 0:61 / /run/secrets/kubernetes.io/serviceaccount ro,relatime - tmpfs tmpfs rw,size=1024k
+1023 1013 8:1 /var/lib/kubelet/pods/1234/volumes/kubernetes.io~empty-dir/cache /cache rw,relatime - ext4 /dev/sda1 rw
+1024 1013 8:1 /var/lib/containerd/io.containerd.grpc.v1.cri/sandboxes/abc/hosts /etc/hosts rw,relatime - ext4 /dev/sda1 rw
+`
+
+const hostMountInfo = `25 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+620 25 0:60 / /var/lib/kubelet/pods/1234/volumes/kubernetes.io~secret/db-password rw,relatime shared:300 - tmpfs tmpfs rw,size=1024k
+621 25 0:61 / /var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access-x7k2p rw,relatime shared:301 - tmpfs tmpfs rw,size=1024k
+`
+
+func TestVolume(t *testing.T) {
+	containerMounts, err := mountinfo.Parse(strings.NewReader(containerMountInfo))
+	require.NoError(t, err)
+	hostMounts, err := mountinfo.Parse(strings.NewReader(hostMountInfo))
+	require.NoError(t, err)
+
+	r := &resolver{
+		containers: mountinfo.NewCache(cacheTTL, func(pid uint32) ([]mountinfo.Mount, error) {
+			if pid != 42 {
+				return nil, os.ErrNotExist
+			}
+			return containerMounts, nil
+		}),
+		host: mountinfo.NewCache(cacheTTL, func(pid uint32) ([]mountinfo.Mount, error) {
+			return hostMounts, nil
+		}),
+	}
+
+	for path, expected := range map[string]string{
+		"/etc/app/config.yaml": "configmap/app-config",
+		"/etc/app":             "configmap/app-config",
+		"/etc/db/password":     "secret/db-password",
+		"/run/secrets/kubernetes.io/serviceaccount/token": "projected/kube-api-access-x7k2p",
+		"/cache/../etc/db/password":                       "secret/db-password",
+		"/cache/x":                                        "empty-dir/cache",
+		"/etc/hosts":                                      "",
+		"/etc/passwd":                                     "",
+		"/proc/self/status":                               "",
+		"etc/db/password":                                 "",
+	} {
+		assert.Equal(t, expected, r.volume(42, 1234, path), path)
+	}
+	assert.Equal(t, "", r.volume(43, 0, "/etc/db/password"), "gone process")
+}
@@ -16,10 +16,15 @@
 // batch of data. This operator is only enabled for data sources of type array.
 // A great scenario for this operator is when you are already sorting data
 // within an array of data and you want to filter out the top `X` entries.
+//
+// It can also stop the gadget after a given number of events were emitted by
+// its single data sources.
 package limiter
 
 import (
 	"fmt"
+	"strconv"
+	"sync/atomic"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
@@ -31,6 +36,7 @@ import (
 const (
 	name            = "limiter"
 	ParamMaxEntries = "max-entries"
+	ParamMaxEvents  = "max-events"
 	Priority        = 9600
 )
 
@@ -60,6 +66,14 @@ func (l *limiterOperator) InstanceParams() api.Params {
 			DefaultValue: "-1",
 			TypeHint:     api.TypeString,
 		},
+		{
+			Key:   ParamMaxEvents,
+			Title: "Max Events",
+			Description: "The number of events after which the gadget is stopped. " +
+				"Events of all non-array data sources are counted. Use 0 to disable.",
+			DefaultValue: "0",
+			TypeHint:     api.TypeUint64,
+		},
 	}
 }
 
@@ -67,18 +81,24 @@ func (l *limiterOperator) InstantiateDataOperator(gadgetCtx operators.GadgetCont
 	// When getting the GadgetInfo, InstantiateDataOperator is called with an
 	// empty instanceParamValues. In such cases, we don't need to validate the
 	// ParamMaxEntries parameter, but just return an instance if there is, at
-	// least, one array data source; otherwise return nil (disabling the
-	// operator).
+	// least, one data source; otherwise return nil (disabling the operator).
 	maxEntries, ok := instanceParamValues[ParamMaxEntries]
 	if !ok {
-		for _, ds := range gadgetCtx.GetDataSources() {
-			if ds.Type() == datasource.TypeArray {
-				return &limiterOperatorInstance{}, nil
-			}
+		if len(gadgetCtx.GetDataSources()) > 0 {
+			return &limiterOperatorInstance{}, nil
 		}
 		return nil, nil
 	}
 
+	var maxEvents uint64
+	if val := instanceParamValues[ParamMaxEvents]; val != "" {
+		var err error
+		maxEvents, err = strconv.ParseUint(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s (%q): %w", ParamMaxEvents, val, err)
+		}
+	}
+
 	// If ParamMaxEntries is set, it must be valid.
 	valuesPerDs, err := apihelpers.GetIntValuesPerDataSource(maxEntries)
 	if err != nil {
@@ -97,7 +117,7 @@ func (l *limiterOperator) InstantiateDataOperator(gadgetCtx operators.GadgetCont
 			}
 			// Disable for all data sources
 			if val == -1 {
-				return nil, nil
+				continue
 			}
 			maxEntries = val
 		} else if val, ok := valuesPerDs[ds.Name()]; ok {
@@ -117,13 +137,14 @@ func (l *limiterOperator) InstantiateDataOperator(gadgetCtx operators.GadgetCont
 		maxEntriesPerDs[ds] = maxEntries
 	}
 
-	if len(maxEntriesPerDs) == 0 {
-		gadgetCtx.Logger().Infof("limiter: no array data sources need to be limited. Skipping operator")
+	if len(maxEntriesPerDs) == 0 && maxEvents == 0 {
+		gadgetCtx.Logger().Debugf("limiter: no data sources need to be limited. Skipping operator")
 		return nil, nil
 	}
 
 	return &limiterOperatorInstance{
 		maxEntries: maxEntriesPerDs,
+		maxEvents:  maxEvents,
 	}, nil
 }
 
@@ -133,6 +154,9 @@ func (l *limiterOperator) Priority() int {
 
 type limiterOperatorInstance struct {
 	maxEntries map[datasource.DataSource]int
+
+	maxEvents uint64
+	events    atomic.Uint64
 }
 
 func (l *limiterOperatorInstance) Name() string {
@@ -151,6 +175,26 @@ func (l *limiterOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) er
 			return nil
 		}, Priority)
 	}
+
+	if l.maxEvents == 0 {
+		return nil
+	}
+	for _, ds := range gadgetCtx.GetDataSources() {
+		if ds.Type() != datasource.TypeSingle {
+			continue
+		}
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			events := l.events.Add(1)
+			if events > l.maxEvents {
+				return datasource.ErrDiscard
+			}
+			if events == l.maxEvents {
+				gadgetCtx.Logger().Debugf("limiter: got %d events, stopping gadget", events)
+				gadgetCtx.Cancel()
+			}
+			return nil
+		}, Priority)
+	}
 	return nil
 }
 
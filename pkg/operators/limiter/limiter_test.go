@@ -0,0 +1,75 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+func TestMaxEvents(t *testing.T) {
+	var ds datasource.DataSource
+	received := 0
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "events")
+			return err
+		}),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			for i := 0; i < 10; i++ {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				require.NoError(t, ds.EmitAndRelease(data))
+			}
+			return nil
+		}),
+	)
+	consumer := simple.New("consumer",
+		simple.WithPriority(Priority+1),
+		simple.OnPreStart(func(gadgetCtx operators.GadgetContext) error {
+			return ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				received++
+				return nil
+			}, Priority+1)
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer, consumer))
+	err := gadgetCtx.Run(api.ParamValues{
+		"operator.limiter.max-entries": "-1",
+		"operator.limiter.max-events":  "3",
+	})
+	require.NoError(t, err)
+
+	// The gadget must be stopped by the limiter and not by the timeout
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, 3, received)
+}
@@ -465,6 +465,9 @@ func (l *localManagerTrace) enrich(ev any) {
 	if event, canEnrichEventFromNetNs := ev.(operators.ContainerInfoFromNetNSID); canEnrichEventFromNetNs {
 		l.manager.igManager.ContainerCollection.EnrichEventByNetNs(event)
 	}
+	if event, canEnrichEventFromUserNs := ev.(operators.ContainerInfoFromUserNSID); canEnrichEventFromUserNs {
+		event.EnrichUserNs(&l.manager.igManager.ContainerCollection)
+	}
 }
 
 func (l *localManagerTrace) EnrichEvent(ev any) error {
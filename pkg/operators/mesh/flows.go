@@ -0,0 +1,213 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type hop string
+
+const (
+	hopNone              hop = ""
+	hopAppToSidecar      hop = "app_to_sidecar"
+	hopSidecarToUpstream hop = "sidecar_to_upstream"
+	hopSidecarToApp      hop = "sidecar_to_app"
+)
+
+const (
+	// matchWindow is how long a connection of the application waits for the
+	// sidecar to connect to the destination on its behalf
+	matchWindow = 2 * time.Second
+
+	// maxPending is the maximum number of connections of the applications of
+	// a pod waiting for the sidecar
+	maxPending = 64
+
+	// meshedTTL is how long a pod is considered to be part of the mesh after
+	// the last event of its sidecar
+	meshedTTL = 10 * time.Minute
+)
+
+// endpoint is one side of a connection
+type endpoint struct {
+	ip   net.IP
+	port uint16
+}
+
+func (e endpoint) String() string {
+	return net.JoinHostPort(e.ip.String(), strconv.Itoa(int(e.port)))
+}
+
+// flow is a connection, or a DNS query, seen from the container making it
+type flow struct {
+	pod       string
+	container string
+	comm      string
+	pid       uint32
+	local     endpoint
+	remote    endpoint
+
+	// response is set for the DNS responses: they aren't connections the
+	// application is waiting for the sidecar to make
+	response bool
+}
+
+// origin is the connection of the application a connection of the sidecar
+// was made for
+type origin struct {
+	comm   string
+	pid    uint32
+	remote endpoint
+}
+
+type pending struct {
+	origin
+	ts time.Time
+}
+
+type config struct {
+	sidecars map[string]struct{}
+	ports    map[uint16]struct{}
+	merge    bool
+}
+
+// tracker classifies the flows of the pods of a service mesh and links the
+// connections made by the sidecars to the ones of the applications they were
+// made for. A pod is part of the mesh once an event of its sidecar was seen,
+// or once its application connected to a port of the sidecar.
+type tracker struct {
+	cfg *config
+	now func() time.Time
+
+	mu      sync.Mutex
+	meshed  map[string]time.Time
+	pending map[string][]pending
+}
+
+func newTracker(cfg *config) *tracker {
+	return &tracker{
+		cfg:     cfg,
+		now:     time.Now,
+		meshed:  make(map[string]time.Time),
+		pending: make(map[string][]pending),
+	}
+}
+
+func (t *tracker) isSidecar(container string) bool {
+	_, ok := t.cfg.sidecars[container]
+	return ok
+}
+
+func (t *tracker) isSidecarPort(port uint16) bool {
+	_, ok := t.cfg.ports[port]
+	return ok
+}
+
+// classify returns the hop of the mesh f is. For the connections of the
+// sidecars to the destinations, it also returns the connection of the
+// application they were made for, if it was seen.
+func (t *tracker) classify(f *flow) (hop, *origin) {
+	if f.pod == "" || f.container == "" {
+		return hopNone, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+
+	if t.isSidecar(f.container) {
+		t.markMeshed(f.pod, now)
+
+		switch {
+		case f.remote.ip.IsLoopback() && t.isSidecarPort(f.remote.port):
+			// Between the processes of the sidecar, like Envoy and the
+			// Istio agent
+			return hopNone, nil
+		case f.remote.ip.IsLoopback(), f.local.ip.IsLoopback(), f.remote.ip.Equal(f.local.ip):
+			// Inbound traffic forwarded to the application, e.g. from
+			// 127.0.0.6 to the IP of the pod for Istio
+			return hopSidecarToApp, nil
+		}
+		if f.response {
+			return hopSidecarToUpstream, nil
+		}
+		return hopSidecarToUpstream, t.popPending(f.pod, now)
+	}
+
+	explicit := f.remote.ip.IsLoopback() && t.isSidecarPort(f.remote.port)
+	if explicit {
+		t.markMeshed(f.pod, now)
+	} else if f.remote.ip.IsLoopback() || !t.isMeshed(f.pod, now) {
+		return hopNone, nil
+	}
+
+	if !f.response {
+		t.pushPending(f.pod, pending{
+			origin: origin{comm: f.comm, pid: f.pid, remote: f.remote},
+			ts:     now,
+		})
+	}
+	return hopAppToSidecar, nil
+}
+
+func (t *tracker) markMeshed(pod string, now time.Time) {
+	t.meshed[pod] = now
+
+	// Forget the pods whose sidecar wasn't seen for a while, like the
+	// deleted ones
+	if len(t.meshed) > 1024 {
+		for p, ts := range t.meshed {
+			if now.Sub(ts) > meshedTTL {
+				delete(t.meshed, p)
+				delete(t.pending, p)
+			}
+		}
+	}
+}
+
+func (t *tracker) isMeshed(pod string, now time.Time) bool {
+	ts, ok := t.meshed[pod]
+	return ok && now.Sub(ts) <= meshedTTL
+}
+
+func (t *tracker) pushPending(pod string, p pending) {
+	q := t.pending[pod]
+	if len(q) >= maxPending {
+		q = q[1:]
+	}
+	t.pending[pod] = append(q, p)
+}
+
+// popPending returns the oldest connection of the application of the pod
+// still waiting for the sidecar. The sidecar handles the connections in the
+// order they were accepted.
+func (t *tracker) popPending(pod string, now time.Time) *origin {
+	q := t.pending[pod]
+	for len(q) > 0 {
+		p := q[0]
+		q = q[1:]
+		if now.Sub(p.ts) <= matchWindow {
+			t.pending[pod] = q
+			return &p.origin
+		}
+	}
+	delete(t.pending, pod)
+	return nil
+}
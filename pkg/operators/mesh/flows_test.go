@@ -0,0 +1,113 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mesh
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+func ep(s string) endpoint {
+	host, port, _ := net.SplitHostPort(s)
+	p, _ := net.LookupPort("tcp", port)
+	return endpoint{ip: net.ParseIP(host), port: uint16(p)}
+}
+
+func TestClassify(t *testing.T) {
+	cfg, err := parseConfig(api.ParamValues{
+		ParamSidecars: "istio-proxy",
+		ParamPorts:    "15001,15006,15053",
+	})
+	require.NoError(t, err)
+
+	tr := newTracker(cfg)
+	now := time.Unix(1000, 0)
+	tr.now = func() time.Time { return now }
+
+	app := func(local, remote string) *flow {
+		return &flow{pod: "default/web", container: "web", comm: "curl", pid: 42, local: ep(local), remote: ep(remote)}
+	}
+	sidecar := func(local, remote string) *flow {
+		return &flow{pod: "default/web", container: "istio-proxy", comm: "envoy", pid: 7, local: ep(local), remote: ep(remote)}
+	}
+
+	// The pod isn't known to be meshed yet
+	h, o := tr.classify(app("10.244.0.5:40000", "10.96.12.3:80"))
+	assert.Equal(t, hopNone, h)
+	assert.Nil(t, o)
+
+	// Between Envoy and the Istio agent
+	h, _ = tr.classify(sidecar("127.0.0.1:50000", "127.0.0.1:15053"))
+	assert.Equal(t, hopNone, h)
+
+	// Inbound traffic forwarded to the application
+	h, o = tr.classify(sidecar("127.0.0.6:50001", "10.244.0.5:8080"))
+	assert.Equal(t, hopSidecarToApp, h)
+	assert.Nil(t, o)
+
+	// The application connects to a service, its connection is redirected
+	// to the sidecar, which connects to a pod of the service
+	h, o = tr.classify(app("10.244.0.5:40001", "10.96.12.3:80"))
+	assert.Equal(t, hopAppToSidecar, h)
+	assert.Nil(t, o)
+	h, _ = tr.classify(app("127.0.0.1:40002", "127.0.0.1:9090"))
+	assert.Equal(t, hopNone, h, "loopback connections of the application")
+
+	now = now.Add(10 * time.Millisecond)
+	h, o = tr.classify(sidecar("10.244.0.5:50002", "10.244.1.9:8080"))
+	assert.Equal(t, hopSidecarToUpstream, h)
+	require.NotNil(t, o)
+	assert.Equal(t, "curl", o.comm)
+	assert.Equal(t, uint32(42), o.pid)
+	assert.Equal(t, "10.96.12.3:80", o.remote.String())
+
+	// Already matched
+	h, o = tr.classify(sidecar("10.244.0.5:50003", "10.244.1.9:8080"))
+	assert.Equal(t, hopSidecarToUpstream, h)
+	assert.Nil(t, o)
+
+	// Too old to be matched
+	tr.classify(app("10.244.0.5:40003", "10.96.12.4:5432"))
+	now = now.Add(matchWindow + time.Second)
+	_, o = tr.classify(sidecar("10.244.0.5:50004", "10.244.2.3:5432"))
+	assert.Nil(t, o)
+
+	// Application explicitly connecting to the sidecar in another pod
+	h, _ = tr.classify(&flow{pod: "default/db", container: "db", local: ep("127.0.0.1:40000"), remote: ep("127.0.0.1:15001")})
+	assert.Equal(t, hopAppToSidecar, h)
+
+	// No Kubernetes metadata
+	h, _ = tr.classify(&flow{local: ep("10.0.0.1:1"), remote: ep("10.0.0.2:2")})
+	assert.Equal(t, hopNone, h)
+}
+
+func TestParseConfig(t *testing.T) {
+	_, err := parseConfig(api.ParamValues{ParamSidecars: " , "})
+	assert.Error(t, err)
+
+	_, err = parseConfig(api.ParamValues{ParamSidecars: "envoy", ParamPorts: "70000"})
+	assert.Error(t, err)
+
+	cfg, err := parseConfig(api.ParamValues{ParamSidecars: "envoy, linkerd-proxy", ParamMerge: "true"})
+	require.NoError(t, err)
+	assert.Len(t, cfg.sidecars, 2)
+	assert.True(t, cfg.merge)
+}
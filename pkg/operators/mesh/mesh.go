@@ -0,0 +1,355 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mesh is a data operator that makes the connections and the DNS
+// requests of the pods of a service mesh, like Istio or Linkerd, meaningful.
+// The traffic of the applications is redirected to a sidecar proxy, which
+// connects to the destinations on their behalf: the operator tells the hop
+// of the mesh each event is, and links the connections of the sidecars to the
+// ones of the applications they were made for, so they can be merged into one
+// logical flow.
+package mesh
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "mesh"
+
+	ParamEnable   = "mesh"
+	ParamSidecars = "mesh-sidecars"
+	ParamPorts    = "mesh-ports"
+	ParamMerge    = "mesh-merge"
+
+	// Priority runs after the enrichment of the containers and before the
+	// filter operator, so events can be filtered by hop
+	Priority = 20
+
+	ipAddrType = "gadget_ip_addr_t"
+)
+
+type meshOperator struct{}
+
+func (o *meshOperator) Name() string {
+	return name
+}
+
+func (o *meshOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *meshOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *meshOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamEnable,
+			Title:        "Service mesh",
+			Description:  "Tell the hop of the service mesh the connections and DNS requests are, and link the connections of the sidecars to the ones of the applications",
+			DefaultValue: "false",
+			TypeHint:     api.TypeBool,
+		},
+		{
+			Key:          ParamSidecars,
+			Title:        "Sidecar containers",
+			Description:  "Comma-separated names of the containers of the sidecar proxies",
+			DefaultValue: "istio-proxy,linkerd-proxy,envoy",
+		},
+		{
+			Key:          ParamPorts,
+			Title:        "Sidecar ports",
+			Description:  "Comma-separated ports the sidecar proxies listen on in the pods, for the traffic redirected to them",
+			DefaultValue: "15001,15006,15053,4140,4143",
+		},
+		{
+			Key:          ParamMerge,
+			Title:        "Merge flows",
+			Description:  "Drop the events of the applications going through the sidecars, and report them in the events of the sidecars connecting to the destinations",
+			DefaultValue: "false",
+			TypeHint:     api.TypeBool,
+		},
+	}
+}
+
+func parseConfig(instanceParamValues api.ParamValues) (*config, error) {
+	cfg := &config{
+		sidecars: make(map[string]struct{}),
+		ports:    make(map[uint16]struct{}),
+	}
+
+	for _, s := range strings.Split(instanceParamValues[ParamSidecars], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			cfg.sidecars[s] = struct{}{}
+		}
+	}
+	if len(cfg.sidecars) == 0 {
+		return nil, fmt.Errorf("%s: no sidecar containers", ParamSidecars)
+	}
+
+	for _, p := range strings.Split(instanceParamValues[ParamPorts], ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", ParamPorts, err)
+		}
+		cfg.ports[uint16(port)] = struct{}{}
+	}
+
+	cfg.merge = instanceParamValues[ParamMerge] == "true"
+	return cfg, nil
+}
+
+type endpointFields struct {
+	addr    datasource.FieldAccessor
+	port    datasource.FieldAccessor
+	version datasource.FieldAccessor
+}
+
+func getEndpointFields(ds datasource.DataSource, name string) (*endpointFields, error) {
+	ep := ds.GetField(name)
+	if ep == nil {
+		return nil, nil
+	}
+	addr := ep.GetSubFieldsWithTag("type:" + ipAddrType)
+	port := ep.GetSubFieldsWithTag("name:port")
+	version := ep.GetSubFieldsWithTag("name:version")
+	if len(addr) != 1 || len(port) != 1 || len(version) != 1 {
+		return nil, nil
+	}
+	if addr[0].Size() != 16 || port[0].Size() != 2 {
+		return nil, fmt.Errorf("%s: unexpected size of the address or the port", name)
+	}
+	return &endpointFields{addr: addr[0], port: port[0], version: version[0]}, nil
+}
+
+func (e *endpointFields) get(data datasource.Data) endpoint {
+	var ep endpoint
+	ep.port, _ = e.port.Uint16(data)
+	ip := e.addr.Get(data)
+	if len(ip) != 16 {
+		return ep
+	}
+	switch v, _ := e.version.Uint8(data); v {
+	case 4:
+		ep.ip = net.IP(ip[:4])
+	case 6:
+		ep.ip = net.IP(ip)
+	}
+	return ep
+}
+
+type dsFields struct {
+	src, dst *endpointFields
+
+	namespace datasource.FieldAccessor
+	podName   datasource.FieldAccessor
+	container datasource.FieldAccessor
+	comm      datasource.FieldAccessor
+	pid       datasource.FieldAccessor
+
+	// response is the qr_raw field of the DNS gadgets
+	response datasource.FieldAccessor
+
+	hop     datasource.FieldAccessor
+	appComm datasource.FieldAccessor
+	appPid  datasource.FieldAccessor
+	appDst  datasource.FieldAccessor
+}
+
+func addFields(ds datasource.DataSource, df *dsFields) error {
+	mesh, err := ds.AddField("mesh", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+	if err != nil {
+		return fmt.Errorf("adding field %q: %w", "mesh", err)
+	}
+	for _, f := range []struct {
+		acc         *datasource.FieldAccessor
+		name        string
+		kind        api.Kind
+		description string
+		width       string
+		hidden      bool
+	}{
+		{&df.hop, "hop", api.Kind_String, "Hop of the service mesh: app_to_sidecar, sidecar_to_upstream or sidecar_to_app", "19", false},
+		{&df.appComm, "app_comm", api.Kind_String, "Command of the application the sidecar connected for", "16", false},
+		{&df.appPid, "app_pid", api.Kind_Uint32, "PID of the application the sidecar connected for", "7", true},
+		{&df.appDst, "app_dst", api.Kind_String, "Destination the application connected to before being redirected to the sidecar", "22", false},
+	} {
+		annotations := map[string]string{
+			metadatav1.DescriptionAnnotation:  f.description,
+			metadatav1.ColumnsWidthAnnotation: f.width,
+		}
+		if f.hidden {
+			annotations[metadatav1.ColumnsHiddenAnnotation] = "true"
+		}
+		*f.acc, err = mesh.AddSubField(f.name, f.kind, datasource.WithAnnotations(annotations))
+		if err != nil {
+			return fmt.Errorf("adding field %q: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+func (o *meshOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	if instanceParamValues[ParamEnable] != "true" {
+		return nil, nil
+	}
+
+	cfg, err := parseConfig(instanceParamValues)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := gadgetCtx.Logger()
+	fields := make(map[datasource.DataSource]*dsFields)
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		src, err := getEndpointFields(ds, "src")
+		if err != nil {
+			return nil, err
+		}
+		dst, err := getEndpointFields(ds, "dst")
+		if err != nil {
+			return nil, err
+		}
+		if src == nil || dst == nil {
+			continue
+		}
+
+		df := &dsFields{
+			src:       src,
+			dst:       dst,
+			namespace: ds.GetField("k8s.namespace"),
+			podName:   ds.GetField("k8s.podName"),
+			container: ds.GetField("k8s.containerName"),
+			comm:      ds.GetField("proc.comm"),
+			pid:       ds.GetField("proc.pid"),
+			response:  ds.GetField("qr_raw"),
+		}
+		if df.namespace == nil || df.podName == nil || df.container == nil {
+			logger.Warnf("mesh: data source %q has no Kubernetes metadata, ignoring it", ds.Name())
+			continue
+		}
+
+		if err := addFields(ds, df); err != nil {
+			return nil, err
+		}
+		fields[ds] = df
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return &meshOperatorInstance{
+		fields:  fields,
+		tracker: newTracker(cfg),
+	}, nil
+}
+
+func (o *meshOperator) Priority() int {
+	return Priority
+}
+
+type meshOperatorInstance struct {
+	fields  map[datasource.DataSource]*dsFields
+	tracker *tracker
+}
+
+func (o *meshOperatorInstance) Name() string {
+	return name
+}
+
+func (df *dsFields) flow(data datasource.Data) *flow {
+	namespace, _ := df.namespace.String(data)
+	podName, _ := df.podName.String(data)
+	if namespace == "" || podName == "" {
+		return &flow{}
+	}
+
+	f := &flow{
+		pod:    namespace + "/" + podName,
+		local:  df.src.get(data),
+		remote: df.dst.get(data),
+	}
+	f.container, _ = df.container.String(data)
+	if df.comm != nil {
+		f.comm, _ = df.comm.String(data)
+	}
+	if df.pid != nil {
+		f.pid, _ = df.pid.Uint32(data)
+	}
+	// DNS responses are sent by the remote side
+	if df.response != nil {
+		if f.response, _ = df.response.Bool(data); f.response {
+			f.local, f.remote = f.remote, f.local
+		}
+	}
+	return f
+}
+
+func (o *meshOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for ds, df := range o.fields {
+		df := df
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			hop, origin := o.tracker.classify(df.flow(data))
+			if hop == hopNone {
+				return nil
+			}
+			if hop == hopAppToSidecar && o.tracker.cfg.merge {
+				return datasource.ErrDiscard
+			}
+
+			df.hop.PutString(data, string(hop))
+			if origin != nil {
+				df.appComm.PutString(data, origin.comm)
+				df.appPid.PutUint32(data, origin.pid)
+				df.appDst.PutString(data, origin.remote.String())
+			}
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *meshOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *meshOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *meshOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &meshOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
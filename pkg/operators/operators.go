@@ -177,6 +177,13 @@ type ContainerInfoFromNetNSID interface {
 	GetNetNSID() uint64
 }
 
+// ContainerInfoFromUserNSID is implemented by events having user namespaces or
+// user and group IDs that can be resolved using the user namespaces of the
+// containers
+type ContainerInfoFromUserNSID interface {
+	EnrichUserNs(resolver types.UserNsResolver)
+}
+
 type ContainerInfoSetters interface {
 	NodeSetter
 	SetPodMetadata(types.Container)
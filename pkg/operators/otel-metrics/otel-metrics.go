@@ -43,6 +43,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	ebpftypes "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf/types"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	gadgettls "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/tls"
 )
 
 const (
@@ -51,6 +52,9 @@ const (
 	Priority                      = 9995 // slightly before CLI so we can reroute output there
 	ParamOtelMetricsListen        = "otel-metrics-listen"
 	ParamOtelMetricsListenAddress = "otel-metrics-listen-address"
+	ParamOtelMetricsCertFile      = "otel-metrics-listen-cert-file"
+	ParamOtelMetricsKeyFile       = "otel-metrics-listen-key-file"
+	ParamOtelMetricsClientCAFile  = "otel-metrics-listen-client-ca-file"
 	ParamOtelMetricsName          = "otel-metrics-name"
 	ParamOtelMetricsExporter      = "otel-metrics-exporter"
 	ParamOtelMetricsPrintInterval = "otel-metrics-print-interval"
@@ -188,10 +192,34 @@ func (m *otelMetricsOperator) Init(globalParams *params.Params) error {
 
 	// Start HTTP listener for the global exporter
 	if !m.skipListen {
+		certFile := globalParams.Get(ParamOtelMetricsCertFile).AsString()
+		keyFile := globalParams.Get(ParamOtelMetricsKeyFile).AsString()
+		clientCAFile := globalParams.Get(ParamOtelMetricsClientCAFile).AsString()
+		if (certFile == "") != (keyFile == "") || (clientCAFile != "" && certFile == "") {
+			return fmt.Errorf("%s and %s are required to serve metrics using TLS", ParamOtelMetricsCertFile, ParamOtelMetricsKeyFile)
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		server := &http.Server{
+			Addr:    globalParams.Get(ParamOtelMetricsListenAddress).AsString(),
+			Handler: mux,
+		}
+		if certFile != "" {
+			reloader, err := gadgettls.NewReloader(certFile, keyFile, clientCAFile)
+			if err != nil {
+				return fmt.Errorf("loading TLS configuration for otel metrics: %w", err)
+			}
+			server.TLSConfig = reloader.ServerConfig()
+		}
+
 		go func() {
-			mux := http.NewServeMux()
-			mux.Handle("/metrics", promhttp.Handler())
-			err := http.ListenAndServe(globalParams.Get(ParamOtelMetricsListenAddress).AsString(), mux)
+			var err error
+			if server.TLSConfig != nil {
+				err = server.ListenAndServeTLS("", "")
+			} else {
+				err = server.ListenAndServe()
+			}
 			if err != nil {
 				log.Errorf("serving otel metrics on: %s", err)
 				return
@@ -215,6 +243,21 @@ func (m *otelMetricsOperator) GlobalParams() api.Params {
 			TypeHint:     api.TypeString,
 			Description:  "address and port to create the OpenTelemetry metrics listener (Prometheus compatible) on",
 		},
+		{
+			Key:         ParamOtelMetricsCertFile,
+			TypeHint:    api.TypeString,
+			Description: "certificate to serve the OpenTelemetry metrics listener using TLS",
+		},
+		{
+			Key:         ParamOtelMetricsKeyFile,
+			TypeHint:    api.TypeString,
+			Description: "key of the certificate given in " + ParamOtelMetricsCertFile,
+		},
+		{
+			Key:         ParamOtelMetricsClientCAFile,
+			TypeHint:    api.TypeString,
+			Description: "CA certificate used to verify client certificates; if set, clients are required to authenticate (mTLS)",
+		},
 	}
 }
 
@@ -370,8 +413,16 @@ func asFloat64(f datasource.FieldAccessor) func(datasource.Data) float64 {
 	}
 }
 
-func (mc *metricsCollector) addKeyFunc(f datasource.FieldAccessor) error {
+// addKeyFunc adds a key named after the field. Fields rendered by another one,
+// like the endpoints whose address is set by the formatters operator, use the
+// value of that field.
+func (mc *metricsCollector) addKeyFunc(ds datasource.DataSource, f datasource.FieldAccessor) error {
 	name := f.Name()
+	if replacement := f.Annotations()[datasource.ColumnsReplaceAnnotation]; replacement != "" {
+		if rf := ds.GetField(replacement); rf != nil {
+			f = rf
+		}
+	}
 	switch f.Type() {
 	default:
 		return fmt.Errorf("unsupported field type for metrics key: %s", f.Type())
@@ -617,7 +668,6 @@ func (mc *metricsCollector) Collect(ctx context.Context, data datasource.Data) {
 }
 
 func (m *otelMetricsOperatorInstance) shutdown() {
-	close(m.done)
 	ctx := context.Background()
 	for _, collector := range m.collectors {
 		if collector.meterProvider != nil {
@@ -766,7 +816,7 @@ func (m *otelMetricsOperatorInstance) PreStart(gadgetCtx operators.GadgetContext
 			default:
 				continue
 			case MetricTypeKey:
-				err := collector.addKeyFunc(f)
+				err := collector.addKeyFunc(ds, f)
 				if err != nil {
 					return fmt.Errorf("adding key for %q: %w", fieldName, err)
 				}
@@ -816,75 +866,109 @@ func (m *otelMetricsOperatorInstance) PrintMetrics(gadgetCtx operators.GadgetCon
 	for {
 		select {
 		case <-m.done:
+			// Print a last time, so the output includes the data collected
+			// since the last tick. The gadget context is already done here.
+			m.printMetrics(context.Background(), gadgetCtx)
 			return
 		case <-ticker.C:
-			// collect metrics
-			md := make(map[*otelprometheus.Exporter]*metricdata.ResourceMetrics)
-
-			var out strings.Builder
-			for _, collector := range m.collectors {
-				exporter := m.op.exporter
-				if collector.exporter != nil {
-					exporter = collector.exporter
-				}
-				if exporter == nil {
-					continue
-				}
+			m.printMetrics(gadgetCtx.Context(), gadgetCtx)
+		}
+	}
+}
 
-				rm, ok := md[exporter]
-				if !ok {
-					// Not yet collected, so collect
-					rm = &metricdata.ResourceMetrics{}
-					err := exporter.Collect(gadgetCtx.Context(), rm)
-					if err != nil {
-						gadgetCtx.Logger().Errorf("collecting metrics: %v", err)
-						return
-					}
-					md[exporter] = rm
-				}
+func (m *otelMetricsOperatorInstance) printMetrics(ctx context.Context, gadgetCtx operators.GadgetContext) {
+	// collect metrics
+	md := make(map[*otelprometheus.Exporter]*metricdata.ResourceMetrics)
 
-				// Find metric in ResourceMetrics
-				for _, sm := range rm.ScopeMetrics {
-					if sm.Scope.Name != collector.mappedName {
-						continue
-					}
+	var out strings.Builder
+	for _, collector := range m.collectors {
+		exporter := m.op.exporter
+		if collector.exporter != nil {
+			exporter = collector.exporter
+		}
+		if exporter == nil {
+			continue
+		}
 
-					for _, metric := range sm.Metrics {
-						fmt.Fprintln(&out, metric.Name)
-						switch t := metric.Data.(type) {
-						case metricdata.Histogram[int64]:
-							for _, dp := range t.DataPoints {
-								last := uint64(0)
-								v := make([]histogram.Interval, 0, len(dp.Bounds))
-								for bucket, high := range dp.Bounds {
-									v = append(v, histogram.Interval{
-										Count: dp.BucketCounts[bucket],
-										Start: last,
-										End:   uint64(high),
-									})
-									last = uint64(high)
-								}
-								h := histogram.Histogram{
-									Unit:      histogram.Unit(metric.Unit),
-									Intervals: v,
-								}
-								fmt.Fprintln(&out, h.String())
-							}
+		rm, ok := md[exporter]
+		if !ok {
+			// Not yet collected, so collect
+			rm = &metricdata.ResourceMetrics{}
+			err := exporter.Collect(ctx, rm)
+			if err != nil {
+				gadgetCtx.Logger().Errorf("collecting metrics: %v", err)
+				return
+			}
+			md[exporter] = rm
+		}
+
+		// Find metric in ResourceMetrics
+		for _, sm := range rm.ScopeMetrics {
+			if sm.Scope.Name != collector.mappedName {
+				continue
+			}
+
+			for _, metric := range sm.Metrics {
+				fmt.Fprintln(&out, metric.Name)
+				switch t := metric.Data.(type) {
+				case metricdata.Sum[int64]:
+					for _, dp := range t.DataPoints {
+						if labels := attributesString(dp.Attributes); labels != "" {
+							fmt.Fprintf(&out, "        %s: %d\n", labels, dp.Value)
+						} else {
+							fmt.Fprintf(&out, "        %d\n", dp.Value)
+						}
+					}
+				case metricdata.Histogram[int64]:
+					for _, dp := range t.DataPoints {
+						if labels := attributesString(dp.Attributes); labels != "" {
+							fmt.Fprintln(&out, labels)
 						}
+						last := uint64(0)
+						v := make([]histogram.Interval, 0, len(dp.Bounds))
+						for bucket, high := range dp.Bounds {
+							v = append(v, histogram.Interval{
+								Count: dp.BucketCounts[bucket],
+								Start: last,
+								End:   uint64(high),
+							})
+							last = uint64(high)
+						}
+						h := histogram.Histogram{
+							Unit:      histogram.Unit(metric.Unit),
+							Intervals: v,
+						}
+						fmt.Fprintln(&out, h.String())
 					}
-					break
 				}
 			}
+			break
+		}
+	}
 
-			ps, err := m.outputDS.NewPacketSingle()
-			if err != nil {
-				gadgetCtx.Logger().Errorf("error creating packet: %v", err)
-				return
-			}
-			m.outputField.PutString(ps, out.String())
-			m.outputDS.EmitAndRelease(ps)
+	ps, err := m.outputDS.NewPacketSingle()
+	if err != nil {
+		gadgetCtx.Logger().Errorf("error creating packet: %v", err)
+		return
+	}
+	m.outputField.PutString(ps, out.String())
+	m.outputDS.EmitAndRelease(ps)
+}
+
+// attributesString returns the keys of a data point as "key=value" pairs,
+// skipping the empty ones
+func attributesString(set attribute.Set) string {
+	var parts []string
+	iter := set.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		v := kv.Value.Emit()
+		if v == "" {
+			continue
 		}
+		parts = append(parts, fmt.Sprintf("%s=%s", kv.Key, v))
 	}
+	return strings.Join(parts, " ")
 }
 
 func (m *otelMetricsOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
@@ -892,8 +976,10 @@ func (m *otelMetricsOperatorInstance) Start(gadgetCtx operators.GadgetContext) e
 }
 
 func (m *otelMetricsOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
-	m.shutdown()
+	// Wait for the printer before shutting down the exporters it reads from
+	close(m.done)
 	m.wg.Wait()
+	m.shutdown()
 	gadgetCtx.Logger().Debug("shutting down metrics")
 	return nil
 }
@@ -21,6 +21,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
@@ -194,3 +195,110 @@ func TestMetricsHistogram(t *testing.T) {
 		assert.True(t, found)
 	}
 }
+
+func TestMetricsHistogramByKey(t *testing.T) {
+	o := &otelMetricsOperator{skipListen: true}
+	globalParams := apihelpers.ToParamDescs(o.GlobalParams()).ToParams()
+	globalParams.Set(ParamOtelMetricsListen, "true")
+	err := o.Init(globalParams)
+	require.NoError(t, err)
+
+	var ds datasource.DataSource
+	var addr datasource.FieldAccessor
+	var value datasource.FieldAccessor
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	expectedCounts := map[string]uint64{
+		"10.0.0.1": 3,
+		"10.0.0.2": 2,
+	}
+
+	prepare := func(gadgetCtx operators.GadgetContext) error {
+		var err error
+		ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "metrics")
+		require.NoError(t, err)
+		ds.AddAnnotation(AnnotationMetricsCollect, "true")
+
+		// Like an endpoint rendered by the formatters operator
+		raddr, err := ds.AddField("raddr", api.Kind_Invalid,
+			datasource.WithFlags(datasource.FieldFlagContainer|datasource.FieldFlagEmpty),
+			datasource.WithAnnotations(map[string]string{
+				AnnotationMetricsType:               MetricTypeKey,
+				datasource.ColumnsReplaceAnnotation: "raddr.addr",
+			}))
+		require.NoError(t, err)
+		addr, err = raddr.AddSubField("addr", api.Kind_String)
+		require.NoError(t, err)
+
+		value, err = ds.AddField("duration", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+			AnnotationMetricsType: MetricTypeHistogram,
+		}))
+		require.NoError(t, err)
+		return nil
+	}
+	produce := func(operators.GadgetContext) error {
+		for a, count := range expectedCounts {
+			for range count {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				err = addr.PutString(data, a)
+				assert.NoError(t, err)
+				err = value.PutUint32(data, 1000)
+				assert.NoError(t, err)
+				err = ds.EmitAndRelease(data)
+				assert.NoError(t, err)
+			}
+		}
+		cancel()
+		return nil
+	}
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(prepare),
+		simple.OnStart(produce),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(o, producer))
+
+	err = gadgetCtx.Run(api.ParamValues{
+		"operator.otel-metrics.otel-metrics-name": "metrics:metrics",
+	})
+	require.NoError(t, err)
+
+	md := &metricdata.ResourceMetrics{}
+
+	err = o.exporter.Collect(context.Background(), md)
+	require.NoError(t, err)
+
+	found := false
+	for _, sm := range md.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "duration" {
+				continue
+			}
+			found = true
+			data, ok := (m.Data).(metricdata.Histogram[int64])
+			require.True(t, ok)
+			require.Len(t, data.DataPoints, len(expectedCounts))
+			for _, dp := range data.DataPoints {
+				v, ok := dp.Attributes.Value("raddr")
+				require.True(t, ok)
+				assert.Equal(t, expectedCounts[v.AsString()], dp.Count)
+			}
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestAttributesString(t *testing.T) {
+	set := attribute.NewSet(
+		attribute.String("raddr", "10.0.0.1"),
+		attribute.String("empty", ""),
+		attribute.Int64("mntns_id", 4026531840),
+	)
+	assert.Equal(t, "mntns_id=4026531840 raddr=10.0.0.1", attributesString(set))
+	assert.Equal(t, "", attributesString(attribute.NewSet()))
+}
@@ -0,0 +1,234 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package oteltraces provides an operator that exports the events of the
+// gadgets describing operations, like DNS lookups or TCP connections, as
+// OpenTelemetry spans to an OTLP receiver. This allows correlating them with
+// the traces of the applications.
+package oteltraces
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+const (
+	OperatorName = "OTelTraces"
+
+	ParamEndpoint    = "otel-endpoint"
+	ParamInsecure    = "otel-insecure"
+	ParamServiceName = "otel-service-name"
+
+	DefaultServiceName = "inspektor-gadget"
+
+	instrumentationName = "github.com/inspektor-gadget/inspektor-gadget"
+
+	// shutdownTimeout is how long the pending spans are flushed for when
+	// the gadget stops
+	shutdownTimeout = 5 * time.Second
+)
+
+// managers are the operators adding the Kubernetes and container metadata
+// to the events. They must enrich the events before they are exported.
+var managers = []string{"KubeManager", "LocalManager"}
+
+type OTelTraces struct{}
+
+func (o *OTelTraces) Name() string {
+	return OperatorName
+}
+
+func (o *OTelTraces) Description() string {
+	return "OTelTraces exports events as OpenTelemetry spans"
+}
+
+func (o *OTelTraces) GlobalParamDescs() params.ParamDescs {
+	return nil
+}
+
+func (o *OTelTraces) ParamDescs() params.ParamDescs {
+	return params.ParamDescs{
+		{
+			Key:         ParamEndpoint,
+			Description: "Address of an OTLP gRPC receiver to export the events as spans to, e.g. otel-collector.observability:4317",
+		},
+		{
+			Key:          ParamInsecure,
+			Description:  "Don't use TLS to connect to the OTLP receiver",
+			DefaultValue: "false",
+			TypeHint:     params.TypeBool,
+		},
+		{
+			Key:          ParamServiceName,
+			Description:  "Service name of the exported spans",
+			DefaultValue: DefaultServiceName,
+		},
+	}
+}
+
+func (o *OTelTraces) Dependencies() []string {
+	var deps []string
+	for _, name := range managers {
+		if operators.GetRaw(name) != nil {
+			deps = append(deps, name)
+		}
+	}
+	return deps
+}
+
+func (o *OTelTraces) CanOperateOn(gadget gadgets.GadgetDesc) bool {
+	_, isSpanEvent := gadget.EventPrototype().(types.SpanEvent)
+	return isSpanEvent
+}
+
+func (o *OTelTraces) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *OTelTraces) Close() error {
+	return nil
+}
+
+func (o *OTelTraces) Instantiate(gadgetCtx operators.GadgetContext, gadgetInstance any, params *params.Params) (operators.OperatorInstance, error) {
+	endpoint := params.Get(ParamEndpoint).AsString()
+	if endpoint == "" {
+		return nil, nil
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if params.Get(ParamInsecure).AsBool() {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(gadgetCtx.Context(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp trace exporter: %w", err)
+	}
+
+	serviceName := params.Get(ParamServiceName).AsString()
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("ig.gadget", gadgetCtx.GadgetDesc().Name()),
+		)),
+	)
+
+	return &OTelTracesInstance{
+		provider: provider,
+		tracer:   provider.Tracer(instrumentationName),
+	}, nil
+}
+
+type OTelTracesInstance struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+func (i *OTelTracesInstance) Name() string {
+	return OperatorName
+}
+
+func (i *OTelTracesInstance) PreGadgetRun() error {
+	return nil
+}
+
+func (i *OTelTracesInstance) PostGadgetRun() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := i.provider.Shutdown(ctx); err != nil {
+		log.Warnf("%s: flushing spans: %v", OperatorName, err)
+	}
+	return nil
+}
+
+var spanKinds = map[types.SpanKind]trace.SpanKind{
+	types.SpanKindInternal: trace.SpanKindInternal,
+	types.SpanKindClient:   trace.SpanKindClient,
+	types.SpanKindServer:   trace.SpanKindServer,
+}
+
+func toAttributes(attrs map[string]any) []attribute.KeyValue {
+	ret := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		switch v := v.(type) {
+		case string:
+			if v == "" {
+				continue
+			}
+			ret = append(ret, attribute.String(k, v))
+		case []string:
+			if len(v) == 0 {
+				continue
+			}
+			ret = append(ret, attribute.StringSlice(k, v))
+		case bool:
+			ret = append(ret, attribute.Bool(k, v))
+		case int:
+			ret = append(ret, attribute.Int(k, v))
+		case int64:
+			ret = append(ret, attribute.Int64(k, v))
+		case uint16:
+			ret = append(ret, attribute.Int64(k, int64(v)))
+		case uint32:
+			ret = append(ret, attribute.Int64(k, int64(v)))
+		default:
+			ret = append(ret, attribute.String(k, fmt.Sprint(v)))
+		}
+	}
+	return ret
+}
+
+func (i *OTelTracesInstance) export(span *types.Span) {
+	_, s := i.tracer.Start(context.Background(), span.Name,
+		trace.WithTimestamp(span.Start),
+		trace.WithSpanKind(spanKinds[span.Kind]),
+		trace.WithAttributes(toAttributes(span.Attributes)...),
+	)
+	if span.Error != "" {
+		s.SetStatus(codes.Error, span.Error)
+	}
+	s.End(trace.WithTimestamp(span.End))
+}
+
+func (i *OTelTracesInstance) EnrichEvent(ev any) error {
+	event, ok := ev.(types.SpanEvent)
+	if !ok {
+		return nil
+	}
+	if span := event.OTelSpan(); span != nil {
+		i.export(span)
+	}
+	return nil
+}
+
+func init() {
+	operators.Register(&OTelTraces{})
+}
@@ -0,0 +1,162 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oteltraces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	dnsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/dns/types"
+	tcpconnectTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/tcpconnect/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func newTestInstance() (*OTelTracesInstance, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return &OTelTracesInstance{
+		provider: provider,
+		tracer:   provider.Tracer(instrumentationName),
+	}, exporter
+}
+
+func attributesMap(attrs []attribute.KeyValue) map[string]any {
+	ret := map[string]any{}
+	for _, a := range attrs {
+		ret[string(a.Key)] = a.Value.AsInterface()
+	}
+	return ret
+}
+
+var testCommonData = eventtypes.CommonData{
+	K8s: eventtypes.K8sMetadata{
+		Node: "node1",
+		BasicK8sMetadata: eventtypes.BasicK8sMetadata{
+			Namespace:     "default",
+			PodName:       "mypod",
+			ContainerName: "app",
+		},
+	},
+}
+
+func TestExportDNS(t *testing.T) {
+	t.Parallel()
+
+	inst, exporter := newTestInstance()
+	ts := time.Date(2024, 11, 15, 10, 0, 0, 0, time.UTC)
+
+	query := &dnsTypes.Event{
+		Event: eventtypes.Event{
+			CommonData: testCommonData,
+			Timestamp:  eventtypes.Time(ts.Add(-5 * time.Millisecond).UnixNano()),
+		},
+		Qr:      dnsTypes.DNSPktTypeQuery,
+		QType:   "A",
+		DNSName: "inspektor-gadget.io.",
+	}
+	require.NoError(t, inst.EnrichEvent(query))
+	require.Empty(t, exporter.GetSpans(), "queries must not be exported")
+
+	response := &dnsTypes.Event{
+		Event: eventtypes.Event{
+			CommonData: testCommonData,
+			Timestamp:  eventtypes.Time(ts.UnixNano()),
+		},
+		Pid:        1234,
+		Comm:       "curl",
+		Protocol:   "UDP",
+		Qr:         dnsTypes.DNSPktTypeResponse,
+		PktType:    "HOST",
+		QType:      "A",
+		DNSName:    "inspektor-gadget.io.",
+		Nameserver: "10.96.0.10",
+		Rcode:      "Non-Existent Domain",
+		Latency:    5 * time.Millisecond,
+	}
+	require.NoError(t, inst.EnrichEvent(response))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "DNS A", span.Name)
+	require.Equal(t, trace.SpanKindClient, span.SpanKind)
+	require.Equal(t, ts.Add(-5*time.Millisecond), span.StartTime.UTC())
+	require.Equal(t, ts, span.EndTime.UTC())
+	require.Equal(t, codes.Error, span.Status.Code)
+	require.Equal(t, "Non-Existent Domain", span.Status.Description)
+	require.Equal(t, map[string]any{
+		"dns.question.name":  "inspektor-gadget.io.",
+		"dns.question.type":  "A",
+		"dns.response_code":  "Non-Existent Domain",
+		"server.address":     "10.96.0.10",
+		"network.transport":  "udp",
+		"process.pid":        int64(1234),
+		"process.command":    "curl",
+		"k8s.node.name":      "node1",
+		"k8s.namespace.name": "default",
+		"k8s.pod.name":       "mypod",
+		"k8s.container.name": "app",
+	}, attributesMap(span.Attributes))
+}
+
+func TestExportTCPConnect(t *testing.T) {
+	t.Parallel()
+
+	inst, exporter := newTestInstance()
+	ts := time.Date(2024, 11, 15, 10, 0, 0, 0, time.UTC)
+
+	ev := &tcpconnectTypes.Event{
+		Event: eventtypes.Event{
+			CommonData: testCommonData,
+			Timestamp:  eventtypes.Time(ts.UnixNano()),
+		},
+		Pid:       1234,
+		Comm:      "wget",
+		IPVersion: 4,
+		SrcEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{Addr: "10.244.0.5", Version: 4},
+			Port:       47250,
+		},
+		DstEndpoint: eventtypes.L4Endpoint{
+			L3Endpoint: eventtypes.L3Endpoint{Addr: "1.1.1.1", Version: 4},
+			Port:       80,
+		},
+		Latency: 15 * time.Millisecond,
+	}
+	require.NoError(t, inst.EnrichEvent(ev))
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	require.Equal(t, "TCP connect", span.Name)
+	require.Equal(t, trace.SpanKindClient, span.SpanKind)
+	require.Equal(t, ts.Add(-15*time.Millisecond), span.StartTime.UTC())
+	require.Equal(t, codes.Unset, span.Status.Code)
+
+	attrs := attributesMap(span.Attributes)
+	require.Equal(t, "1.1.1.1", attrs["server.address"])
+	require.Equal(t, int64(80), attrs["server.port"])
+	require.Equal(t, "10.244.0.5", attrs["client.address"])
+	require.Equal(t, int64(47250), attrs["client.port"])
+	require.Equal(t, "ipv4", attrs["network.type"])
+	require.Equal(t, "mypod", attrs["k8s.pod.name"])
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redactor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// encryptedFieldSuffix is appended to the name of encrypted fields to get
+	// the name of the field holding the ciphertext
+	encryptedFieldSuffix = "_enc"
+
+	encryptionVersion = "v1"
+	keySize           = 32
+)
+
+// EncryptedFromAnnotation is set on fields holding encrypted values and
+// contains the full name of the original field
+const EncryptedFromAnnotation = "redactor.encrypted-from"
+
+// EncryptionKeyIDAnnotation is set on fields holding encrypted values and
+// contains the ID of the key used to encrypt them
+const EncryptionKeyIDAnnotation = "redactor.key-id"
+
+type encryptionKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// KeyID returns the ID of a key as included in encrypted values: the first 8
+// hex characters of the SHA-256 hash of the key
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// ParseKey decodes a base64 encoded 256 bit key, as generated with
+// `head -c 32 /dev/urandom | base64`
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("decoding key: %w", err)
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid key size: expected %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
+
+func loadEncryptionKey(path string) (*encryptionKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading encryption key: %w", err)
+	}
+	key, err := ParseKey(string(content))
+	if err != nil {
+		return nil, err
+	}
+	return newEncryptionKey(key)
+}
+
+func newEncryptionKey(key []byte) (*encryptionKey, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptionKey{id: KeyID(key), aead: aead}, nil
+}
+
+// encrypt encrypts val using AES-256-GCM and returns it as
+// "v1:<key id>:<base64(nonce|ciphertext)>"
+func (k *encryptionKey) encrypt(val string) (string, error) {
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(val)+k.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating nonce: %w", err)
+	}
+	sealed := k.aead.Seal(nonce, nonce, []byte(val), nil)
+	return encryptionVersion + ":" + k.id + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted by the redactor operator using the given
+// key
+func Decrypt(key []byte, val string) (string, error) {
+	parts := strings.SplitN(val, ":", 3)
+	if len(parts) != 3 {
+		return "", errors.New("invalid encrypted value")
+	}
+	if parts[0] != encryptionVersion {
+		return "", fmt.Errorf("unsupported version %q", parts[0])
+	}
+	k, err := newEncryptionKey(key)
+	if err != nil {
+		return "", err
+	}
+	if parts[1] != k.id {
+		return "", fmt.Errorf("value was encrypted with key %q, got key %q", parts[1], k.id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("decoding value: %w", err)
+	}
+	if len(sealed) < k.aead.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plain, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: %w", err)
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,415 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redactor is a data operator that masks, hashes, encrypts or removes
+// the content of string fields before events leave the node. Rules are configured
+// globally in the configuration file (operator.redactor.rules) and can be
+// extended, but not relaxed, using the redact instance parameter.
+package redactor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name        = "redactor"
+	ParamRedact = "redact"
+	Priority    = 9050 // after the filter operator, so filters still see the original values
+
+	ConfigKeyRules             = "operator.redactor.rules"
+	ConfigKeyEncryptionKeyFile = "operator.redactor.encryption-key-file"
+)
+
+const (
+	ActionMask    = "mask"
+	ActionHash    = "hash"
+	ActionRemove  = "remove"
+	ActionReplace = "replace"
+	ActionEncrypt = "encrypt"
+
+	maskValue = "***"
+	hashLen   = 16
+)
+
+// RuleConfig describes a redaction rule as given in the configuration file
+type RuleConfig struct {
+	// Field is the full name of the field(s) the rule applies to, optionally
+	// prefixed with "datasource:". Glob patterns (see path.Match) are
+	// supported, e.g. "proc.*" or "*path*".
+	Field string `json:"field" yaml:"field"`
+	// Action is one of mask, hash, remove, replace or encrypt. It defaults to
+	// replace if Regex is set and to mask otherwise.
+	Action string `json:"action" yaml:"action"`
+	// Regex restricts the action to the matching parts of the value. It's
+	// required for the replace action.
+	Regex string `json:"regex" yaml:"regex"`
+	// Replacement is used by the replace action (and by mask, if set). It can
+	// reference capture groups of Regex, e.g. "${1}***".
+	Replacement string `json:"replacement" yaml:"replacement"`
+	// Salt is prepended to the value before hashing
+	Salt string `json:"salt" yaml:"salt"`
+}
+
+type rule struct {
+	dsName string
+	field  string
+	action string
+	re     *regexp.Regexp
+	repl   string
+	salt   string
+}
+
+func newRule(cfg RuleConfig) (*rule, error) {
+	r := &rule{
+		field:  cfg.Field,
+		action: strings.ToLower(cfg.Action),
+		repl:   cfg.Replacement,
+		salt:   cfg.Salt,
+	}
+	if dsName, field, ok := strings.Cut(cfg.Field, ":"); ok {
+		r.dsName, r.field = dsName, field
+	}
+	if r.field == "" {
+		return nil, fmt.Errorf("missing field")
+	}
+	if _, err := path.Match(r.field, ""); err != nil {
+		return nil, fmt.Errorf("invalid field pattern %q: %w", r.field, err)
+	}
+	if cfg.Regex != "" {
+		re, err := regexp.Compile(cfg.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", cfg.Regex, err)
+		}
+		r.re = re
+	}
+	if r.action == "" {
+		r.action = ActionMask
+		if r.re != nil {
+			r.action = ActionReplace
+		}
+	}
+	switch r.action {
+	case ActionMask:
+		if r.repl == "" {
+			r.repl = maskValue
+		}
+	case ActionHash, ActionRemove:
+	case ActionReplace:
+		if r.re == nil {
+			return nil, fmt.Errorf("action %q requires a regex", ActionReplace)
+		}
+	case ActionEncrypt:
+		if r.re != nil {
+			return nil, fmt.Errorf("action %q doesn't support a regex", ActionEncrypt)
+		}
+	default:
+		return nil, fmt.Errorf("invalid action %q", cfg.Action)
+	}
+	return r, nil
+}
+
+// parseRules parses the value of the redact instance parameter, a comma
+// separated list of [datasource:]field[=action] entries.
+func parseRules(s string) ([]*rule, error) {
+	var rules []*rule
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, action, _ := strings.Cut(entry, "=")
+		if action == ActionReplace {
+			return nil, fmt.Errorf("action %q is only supported in the configuration file", ActionReplace)
+		}
+		r, err := newRule(RuleConfig{Field: field, Action: action})
+		if err != nil {
+			return nil, fmt.Errorf("parsing rule %q: %w", entry, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (r *rule) matches(ds datasource.DataSource, f datasource.FieldAccessor) bool {
+	if r.dsName != "" && r.dsName != ds.Name() {
+		return false
+	}
+	ok, _ := path.Match(r.field, f.FullName())
+	return ok
+}
+
+func (r *rule) redactValue(val string) string {
+	switch r.action {
+	case ActionMask:
+		return r.repl
+	case ActionHash:
+		sum := sha256.Sum256([]byte(r.salt + val))
+		return hex.EncodeToString(sum[:])[:hashLen]
+	case ActionRemove:
+		return ""
+	}
+	return val
+}
+
+func (r *rule) apply(val string) string {
+	if r.re == nil {
+		if val == "" {
+			return val
+		}
+		return r.redactValue(val)
+	}
+	if r.action == ActionReplace {
+		return r.re.ReplaceAllString(val, r.repl)
+	}
+	return r.re.ReplaceAllStringFunc(val, r.redactValue)
+}
+
+type redactorOperator struct {
+	rules []*rule
+	key   *encryptionKey
+}
+
+func (o *redactorOperator) Name() string {
+	return name
+}
+
+func (o *redactorOperator) Init(params *params.Params) error {
+	o.rules = nil
+	o.key = nil
+	if config.Config == nil {
+		return nil
+	}
+
+	if path := config.Config.GetString(ConfigKeyEncryptionKeyFile); path != "" {
+		key, err := loadEncryptionKey(path)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", ConfigKeyEncryptionKeyFile, err)
+		}
+		o.key = key
+		log.Infof("redactor: loaded encryption key %s", key.id)
+	}
+
+	var cfgs []RuleConfig
+	if err := config.Config.UnmarshalKey(ConfigKeyRules, &cfgs); err != nil {
+		return fmt.Errorf("loading %s: %w", ConfigKeyRules, err)
+	}
+	for i, cfg := range cfgs {
+		r, err := newRule(cfg)
+		if err != nil {
+			return fmt.Errorf("parsing %s[%d]: %w", ConfigKeyRules, i, err)
+		}
+		if r.action == ActionEncrypt && o.key == nil {
+			return fmt.Errorf("parsing %s[%d]: action %q requires %s", ConfigKeyRules, i, ActionEncrypt, ConfigKeyEncryptionKeyFile)
+		}
+		o.rules = append(o.rules, r)
+	}
+	if len(o.rules) > 0 {
+		log.Infof("redactor: loaded %d rules", len(o.rules))
+	}
+	return nil
+}
+
+func (o *redactorOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *redactorOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key: ParamRedact,
+			Description: "Comma separated list of fields to redact in addition to the rules configured on the node, " +
+				"using the syntax [datasource:]field[=mask|hash|remove|encrypt]. Field names support glob patterns; " +
+				"the action defaults to mask.",
+			TypeHint: api.TypeString,
+		},
+	}
+}
+
+func (o *redactorOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	// When getting the GadgetInfo, InstantiateDataOperator is called with an
+	// empty instanceParamValues. In such cases, return an instance if there
+	// is at least one string field, so the parameter is exposed to clients.
+	val, ok := instanceParamValues[ParamRedact]
+	if !ok {
+		for _, ds := range gadgetCtx.GetDataSources() {
+			for _, f := range ds.Accessors(false) {
+				if f.Type() == api.Kind_String || f.Type() == api.Kind_CString {
+					return &redactorOperatorInstance{}, nil
+				}
+			}
+		}
+		return nil, nil
+	}
+
+	rules := o.rules
+	if val != "" {
+		extra, err := parseRules(val)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", ParamRedact, err)
+		}
+		for _, r := range extra {
+			if r.action == ActionEncrypt && o.key == nil {
+				return nil, fmt.Errorf("action %q can't be used: no encryption key configured on the node", ActionEncrypt)
+			}
+		}
+		rules = append(rules[:len(rules):len(rules)], extra...)
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	inst := &redactorOperatorInstance{
+		fields: make(map[datasource.DataSource][]*fieldRules),
+	}
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, f := range ds.Accessors(false) {
+			var matching []*rule
+			for _, r := range rules {
+				if r.matches(ds, f) {
+					matching = append(matching, r)
+				}
+			}
+			if len(matching) == 0 {
+				continue
+			}
+			if f.Type() != api.Kind_String && f.Type() != api.Kind_CString {
+				gadgetCtx.Logger().Debugf("redactor: ignoring non-string field %q of data source %q", f.FullName(), ds.Name())
+				continue
+			}
+			fr := &fieldRules{field: f, rules: matching}
+			for _, r := range matching {
+				if r.action != ActionEncrypt {
+					continue
+				}
+				// The ciphertext is longer than the value, so it's stored in
+				// a new field and the original one is cleared
+				encField, err := ds.AddField(f.Name()+encryptedFieldSuffix, api.Kind_String,
+					datasource.WithSameParentAs(f),
+					datasource.WithAnnotations(map[string]string{
+						EncryptedFromAnnotation:   f.FullName(),
+						EncryptionKeyIDAnnotation: o.key.id,
+					}),
+				)
+				if err != nil {
+					return nil, fmt.Errorf("adding encrypted field for %q: %w", f.FullName(), err)
+				}
+				f.SetHidden(true, false)
+				fr.encField = encField
+				fr.key = o.key
+				break
+			}
+			inst.fields[ds] = append(inst.fields[ds], fr)
+		}
+	}
+
+	if len(inst.fields) == 0 {
+		return nil, nil
+	}
+	return inst, nil
+}
+
+func (o *redactorOperator) Priority() int {
+	return Priority
+}
+
+type fieldRules struct {
+	field datasource.FieldAccessor
+	rules []*rule
+
+	// encField and key are set if the field is encrypted
+	encField datasource.FieldAccessor
+	key      *encryptionKey
+}
+
+func (fr *fieldRules) redact(data datasource.Data) error {
+	val, err := fr.field.String(data)
+	if err != nil {
+		return err
+	}
+	redacted := val
+	for _, r := range fr.rules {
+		if r.action == ActionEncrypt {
+			if redacted == "" {
+				continue
+			}
+			encrypted, err := fr.key.encrypt(redacted)
+			if err != nil {
+				return err
+			}
+			if err := fr.encField.PutString(data, encrypted); err != nil {
+				return err
+			}
+			redacted = ""
+			continue
+		}
+		redacted = r.apply(redacted)
+	}
+	if redacted == val {
+		return nil
+	}
+	// Statically sized fields can't grow
+	if size := fr.field.Size(); size > 0 && uint32(len(redacted)) > size {
+		redacted = redacted[:size]
+	}
+	return fr.field.PutString(data, redacted)
+}
+
+type redactorOperatorInstance struct {
+	fields map[datasource.DataSource][]*fieldRules
+}
+
+func (o *redactorOperatorInstance) Name() string {
+	return name
+}
+
+func (o *redactorOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for ds, fields := range o.fields {
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			for _, fr := range fields {
+				if err := fr.redact(data); err != nil {
+					return fmt.Errorf("redacting field %q: %w", fr.field.FullName(), err)
+				}
+			}
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *redactorOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *redactorOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+var Operator = &redactorOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
@@ -0,0 +1,309 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/config"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+func TestRuleApply(t *testing.T) {
+	type testCase struct {
+		name     string
+		cfg      RuleConfig
+		in       string
+		expected string
+		error    bool
+	}
+	testCases := []testCase{
+		{
+			name:     "mask",
+			cfg:      RuleConfig{Field: "user"},
+			in:       "alice",
+			expected: "***",
+		},
+		{
+			name:     "mask empty value",
+			cfg:      RuleConfig{Field: "user"},
+			in:       "",
+			expected: "",
+		},
+		{
+			name:     "mask with replacement",
+			cfg:      RuleConfig{Field: "user", Action: "mask", Replacement: "<redacted>"},
+			in:       "alice",
+			expected: "<redacted>",
+		},
+		{
+			name:     "hash",
+			cfg:      RuleConfig{Field: "user", Action: "hash"},
+			in:       "alice",
+			expected: "2bd806c97f0e00af",
+		},
+		{
+			name:     "hash with salt",
+			cfg:      RuleConfig{Field: "user", Action: "hash", Salt: "pepper"},
+			in:       "alice",
+			expected: "b1b68da447843a65",
+		},
+		{
+			name:     "remove",
+			cfg:      RuleConfig{Field: "user", Action: "remove"},
+			in:       "alice",
+			expected: "",
+		},
+		{
+			name:     "replace querystring",
+			cfg:      RuleConfig{Field: "path", Regex: `\?.*$`, Replacement: "?***"},
+			in:       "/login?user=alice&token=secret",
+			expected: "/login?***",
+		},
+		{
+			name:     "replace env values",
+			cfg:      RuleConfig{Field: "args", Action: "replace", Regex: `\b([A-Z_]+)=\S+`, Replacement: "${1}=***"},
+			in:       "env PASSWORD=secret USER=alice /bin/sh",
+			expected: "env PASSWORD=*** USER=*** /bin/sh",
+		},
+		{
+			name:     "mask matches only",
+			cfg:      RuleConfig{Field: "args", Action: "mask", Regex: `[0-9]{4}-[0-9]{4}`},
+			in:       "card 1234-5678 ok",
+			expected: "card *** ok",
+		},
+		{
+			name:  "replace without regex",
+			cfg:   RuleConfig{Field: "args", Action: "replace"},
+			error: true,
+		},
+		{
+			name:  "invalid action",
+			cfg:   RuleConfig{Field: "args", Action: "scramble"},
+			error: true,
+		},
+		{
+			name:  "encrypt with regex",
+			cfg:   RuleConfig{Field: "args", Action: "encrypt", Regex: "foo"},
+			error: true,
+		},
+		{
+			name:  "invalid regex",
+			cfg:   RuleConfig{Field: "args", Regex: "("},
+			error: true,
+		},
+		{
+			name:  "missing field",
+			cfg:   RuleConfig{Field: "foo:"},
+			error: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newRule(tc.cfg)
+			if tc.error {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, r.apply(tc.in))
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules("user, foo:proc.*=hash,path=remove")
+	require.NoError(t, err)
+	require.Len(t, rules, 3)
+	assert.Equal(t, "user", rules[0].field)
+	assert.Equal(t, ActionMask, rules[0].action)
+	assert.Equal(t, "foo", rules[1].dsName)
+	assert.Equal(t, "proc.*", rules[1].field)
+	assert.Equal(t, ActionHash, rules[1].action)
+	assert.Equal(t, ActionRemove, rules[2].action)
+
+	_, err = parseRules("path=replace")
+	require.Error(t, err)
+	_, err = parseRules("path=foo")
+	require.Error(t, err)
+}
+
+func TestRedactor(t *testing.T) {
+	oldConfig := config.Config
+	defer func() {
+		config.Config = oldConfig
+		Operator.Init(nil)
+	}()
+
+	key := bytes.Repeat([]byte{0x42}, keySize)
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600))
+
+	config.Config = viper.New()
+	config.Config.SetConfigType("yaml")
+	err := config.Config.ReadConfig(bytes.NewBufferString(fmt.Sprintf(`
+operator:
+  redactor:
+    encryption-key-file: %s
+    rules:
+    - field: path
+      regex: '\?.*$'
+      replacement: '?***'
+    - field: "proc.*"
+      action: hash
+    - field: secret
+      action: encrypt
+`, keyFile)))
+	require.NoError(t, err)
+	require.NoError(t, Operator.Init(nil))
+
+	var ds datasource.DataSource
+	var path, comm, user, other, secret datasource.FieldAccessor
+	var pid datasource.FieldAccessor
+
+	prepare := func(gadgetCtx operators.GadgetContext) error {
+		var err error
+		ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "foo")
+		require.NoError(t, err)
+		path, err = ds.AddField("path", api.Kind_String)
+		require.NoError(t, err)
+		proc, err := ds.AddField("proc", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+		require.NoError(t, err)
+		comm, err = proc.AddSubField("comm", api.Kind_String)
+		require.NoError(t, err)
+		pid, err = proc.AddSubField("pid", api.Kind_Uint32)
+		require.NoError(t, err)
+		user, err = ds.AddField("user", api.Kind_String)
+		require.NoError(t, err)
+		other, err = ds.AddField("other", api.Kind_String)
+		require.NoError(t, err)
+		secret, err = ds.AddField("secret", api.Kind_String)
+		require.NoError(t, err)
+		return nil
+	}
+
+	produce := func(gadgetCtx operators.GadgetContext) error {
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		path.PutString(data, "/login?token=secret")
+		comm.PutString(data, "curl")
+		pid.PutUint32(data, 1234)
+		user.PutString(data, "alice")
+		other.PutString(data, "unchanged")
+		secret.PutString(data, "/home/alice/.ssh/id_rsa")
+		require.NoError(t, ds.EmitAndRelease(data))
+		return nil
+	}
+
+	done := make(chan struct{})
+	verify := func(gadgetCtx operators.GadgetContext) error {
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			defer close(done)
+
+			val, _ := path.String(data)
+			assert.Equal(t, "/login?***", val)
+			val, _ = comm.String(data)
+			assert.Equal(t, "427e4b79b1f0fc90", val)
+			val, _ = user.String(data)
+			assert.Equal(t, "***", val)
+			val, _ = other.String(data)
+			assert.Equal(t, "unchanged", val)
+			pidVal, _ := pid.Uint32(data)
+			assert.Equal(t, uint32(1234), pidVal)
+
+			val, _ = secret.String(data)
+			assert.Equal(t, "", val)
+			assert.True(t, datasource.FieldFlagHidden.In(secret.Flags()))
+			encField := ds.GetField("secret_enc")
+			require.NotNil(t, encField)
+			assert.Equal(t, "secret", encField.Annotations()[EncryptedFromAnnotation])
+			assert.Equal(t, KeyID(key), encField.Annotations()[EncryptionKeyIDAnnotation])
+			val, _ = encField.String(data)
+			decrypted, err := Decrypt(key, val)
+			require.NoError(t, err)
+			assert.Equal(t, "/home/alice/.ssh/id_rsa", decrypted)
+			return nil
+		}, Priority+1)
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(prepare),
+		simple.OnStart(produce),
+	)
+	verifier := simple.New("verifier",
+		simple.WithPriority(Priority+1),
+		simple.OnInit(verify),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			<-done
+			cancel()
+			return nil
+		}),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer, verifier))
+	err = gadgetCtx.Run(api.ParamValues{
+		"operator.redactor.redact": "foo:user",
+	})
+	require.NoError(t, err)
+}
+
+func TestEncryption(t *testing.T) {
+	key, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, keySize)))
+	require.NoError(t, err)
+	otherKey := bytes.Repeat([]byte{2}, keySize)
+
+	k, err := newEncryptionKey(key)
+	require.NoError(t, err)
+
+	enc1, err := k.encrypt("/etc/shadow")
+	require.NoError(t, err)
+	enc2, err := k.encrypt("/etc/shadow")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc1, enc2, "nonces must differ")
+
+	val, err := Decrypt(key, enc1)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/shadow", val)
+
+	_, err = Decrypt(otherKey, enc1)
+	require.Error(t, err)
+	_, err = Decrypt(key, "v0:abc:def")
+	require.Error(t, err)
+	_, err = Decrypt(key, enc1[:len(enc1)-4]+"AAAA")
+	require.Error(t, err)
+
+	_, err = ParseKey("dG9vIHNob3J0")
+	require.Error(t, err)
+}
@@ -0,0 +1,323 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package summary is a data operator that aggregates the events of streaming
+// data sources and prints the top pods, destinations and errors once the
+// gadget stops. It's meant to be used on the client side.
+package summary
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name = "summary"
+
+	ParamSummary = "summary"
+	ParamTop     = "summary-top"
+
+	// Priority is right before the cli operator, so events dropped by other
+	// operators (e.g. the filter) aren't counted
+	Priority = 9900
+
+	endpointTag = "endpoint"
+)
+
+type summaryOperator struct {
+	// out is where the summaries are printed; it can be overridden for
+	// testing
+	out io.Writer
+}
+
+func (o *summaryOperator) Name() string {
+	return name
+}
+
+func (o *summaryOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *summaryOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *summaryOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamSummary,
+			Title:        "Summary",
+			Description:  "Print the top pods, destinations and errors by number of events when the gadget stops",
+			DefaultValue: "false",
+			TypeHint:     api.TypeBool,
+		},
+		{
+			Key:          ParamTop,
+			Title:        "Summary entries",
+			Description:  "Number of entries shown per table of the summary",
+			DefaultValue: "5",
+			TypeHint:     api.TypeUint32,
+		},
+	}
+}
+
+func (o *summaryOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	hasStreams := false
+	for _, ds := range gadgetCtx.GetDataSources() {
+		if ds.Type() == datasource.TypeSingle {
+			hasStreams = true
+			break
+		}
+	}
+	if !hasStreams {
+		return nil, nil
+	}
+
+	// When getting the GadgetInfo, the params aren't set yet; return an
+	// instance to expose them
+	enabled, ok := instanceParamValues[ParamSummary]
+	if !ok {
+		return &summaryOperatorInstance{}, nil
+	}
+	if enabled != "true" {
+		return nil, nil
+	}
+
+	top := uint64(5)
+	if val, ok := instanceParamValues[ParamTop]; ok && val != "" {
+		var err error
+		top, err = strconv.ParseUint(val, 10, 32)
+		if err != nil || top == 0 {
+			return nil, fmt.Errorf("invalid value for %s: %q", ParamTop, val)
+		}
+	}
+
+	out := o.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	return &summaryOperatorInstance{
+		enabled: true,
+		top:     int(top),
+		out:     out,
+	}, nil
+}
+
+func (o *summaryOperator) Priority() int {
+	return Priority
+}
+
+// counter counts the events per key of a data source
+type counter struct {
+	title  string
+	keys   func(data datasource.Data) []string
+	counts map[string]uint64
+}
+
+func (c *counter) add(data datasource.Data) {
+	for _, key := range c.keys(data) {
+		if key != "" {
+			c.counts[key]++
+		}
+	}
+}
+
+type entry struct {
+	key   string
+	count uint64
+}
+
+// topEntries returns the n entries with the most events
+func (c *counter) topEntries(n int) []entry {
+	entries := make([]entry, 0, len(c.counts))
+	for k, v := range c.counts {
+		entries = append(entries, entry{key: k, count: v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return entries[i].key < entries[j].key
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+type dsSummary struct {
+	mu       sync.Mutex
+	ds       datasource.DataSource
+	events   uint64
+	counters []*counter
+}
+
+func stringKeys(fields ...datasource.FieldAccessor) func(data datasource.Data) []string {
+	return func(data datasource.Data) []string {
+		keys := make([]string, 0, len(fields))
+		for _, f := range fields {
+			val, _ := f.String(data)
+			keys = append(keys, val)
+		}
+		return keys
+	}
+}
+
+// newDsSummary creates the counters that make sense for the fields of ds
+func newDsSummary(ds datasource.DataSource) *dsSummary {
+	s := &dsSummary{ds: ds}
+
+	if ns, pod := ds.GetField("k8s.namespace"), ds.GetField("k8s.podName"); ns != nil && pod != nil {
+		s.counters = append(s.counters, &counter{
+			title: "POD",
+			keys: func(data datasource.Data) []string {
+				podName, _ := pod.String(data)
+				if podName == "" {
+					return nil
+				}
+				namespace, _ := ns.String(data)
+				return []string{namespace + "/" + podName}
+			},
+		})
+	} else if container := ds.GetField("runtime.containerName"); container != nil {
+		s.counters = append(s.counters, &counter{
+			title: "CONTAINER",
+			keys:  stringKeys(container),
+		})
+	}
+
+	var destinations []datasource.FieldAccessor
+	for _, f := range ds.GetFieldsWithTag(endpointTag) {
+		if strings.HasPrefix(f.FullName(), "dst") {
+			destinations = append(destinations, f)
+		}
+	}
+	if len(destinations) > 0 {
+		s.counters = append(s.counters, &counter{
+			title: "DESTINATION",
+			keys:  stringKeys(destinations...),
+		})
+	}
+
+	var errorFields []datasource.FieldAccessor
+	for _, f := range ds.Accessors(false) {
+		if f.Annotations()[metadatav1.TemplateAnnotation] == "errorString" {
+			errorFields = append(errorFields, f)
+		}
+	}
+	if len(errorFields) > 0 {
+		s.counters = append(s.counters, &counter{
+			title: "ERROR",
+			keys:  stringKeys(errorFields...),
+		})
+	}
+
+	for _, c := range s.counters {
+		c.counts = make(map[string]uint64)
+	}
+	return s
+}
+
+func (s *dsSummary) add(data datasource.Data) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events++
+	for _, c := range s.counters {
+		c.add(data)
+	}
+}
+
+func (s *dsSummary) print(w io.Writer, top int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintf(w, "\nSummary of %q: %d events\n", s.ds.Name(), s.events)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, c := range s.counters {
+		entries := c.topEntries(top)
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\nTOP %s\tEVENTS\n", c.title)
+		for _, e := range entries {
+			fmt.Fprintf(tw, "%s\t%d\n", e.key, e.count)
+		}
+	}
+	return tw.Flush()
+}
+
+type summaryOperatorInstance struct {
+	enabled   bool
+	top       int
+	out       io.Writer
+	summaries []*dsSummary
+}
+
+func (o *summaryOperatorInstance) Name() string {
+	return name
+}
+
+func (o *summaryOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if !o.enabled {
+		return nil
+	}
+	for _, ds := range gadgetCtx.GetDataSources() {
+		if ds.Type() != datasource.TypeSingle {
+			continue
+		}
+		s := newDsSummary(ds)
+		o.summaries = append(o.summaries, s)
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			s.add(data)
+			return nil
+		}, Priority)
+	}
+	return nil
+}
+
+func (o *summaryOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *summaryOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *summaryOperatorInstance) PostStop(gadgetCtx operators.GadgetContext) error {
+	for _, s := range o.summaries {
+		if err := s.print(o.out, o.top); err != nil {
+			return fmt.Errorf("printing summary of %q: %w", s.ds.Name(), err)
+		}
+	}
+	return nil
+}
+
+var Operator = &summaryOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
@@ -0,0 +1,142 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package summary
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+func TestSummary(t *testing.T) {
+	var out bytes.Buffer
+	Operator.out = &out
+	defer func() { Operator.out = nil }()
+
+	var ds datasource.DataSource
+	var ns, pod, dst, errField datasource.FieldAccessor
+
+	prepare := func(gadgetCtx operators.GadgetContext) error {
+		var err error
+		ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "events")
+		require.NoError(t, err)
+		k8s, err := ds.AddField("k8s", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+		require.NoError(t, err)
+		ns, err = k8s.AddSubField("namespace", api.Kind_String)
+		require.NoError(t, err)
+		pod, err = k8s.AddSubField("podName", api.Kind_String)
+		require.NoError(t, err)
+		dstParent, err := ds.AddField("dst", api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+		require.NoError(t, err)
+		dst, err = dstParent.AddSubField("endpoint", api.Kind_String, datasource.WithTags(endpointTag))
+		require.NoError(t, err)
+		errField, err = ds.AddField("error", api.Kind_String, datasource.WithAnnotations(map[string]string{
+			metadatav1.TemplateAnnotation: "errorString",
+		}))
+		require.NoError(t, err)
+		return nil
+	}
+
+	events := []struct {
+		ns, pod, dst, err string
+	}{
+		{"default", "web", "10.0.0.1:80", ""},
+		{"default", "web", "10.0.0.1:80", "ECONNREFUSED"},
+		{"default", "web", "10.0.0.2:443", ""},
+		{"kube-system", "coredns", "10.0.0.1:80", "ECONNREFUSED"},
+		{"", "", "10.0.0.3:53", "ETIMEDOUT"},
+	}
+
+	produce := func(gadgetCtx operators.GadgetContext) error {
+		for _, ev := range events {
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			ns.PutString(data, ev.ns)
+			pod.PutString(data, ev.pod)
+			dst.PutString(data, ev.dst)
+			errField.PutString(data, ev.err)
+			require.NoError(t, ds.EmitAndRelease(data))
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(prepare),
+		simple.OnStart(func(gadgetCtx operators.GadgetContext) error {
+			err := produce(gadgetCtx)
+			cancel()
+			return err
+		}),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	err := gadgetCtx.Run(api.ParamValues{
+		"operator.summary.summary":     "true",
+		"operator.summary.summary-top": "2",
+	})
+	require.NoError(t, err)
+
+	expected := `
+Summary of "events": 5 events
+
+TOP POD              EVENTS
+default/web          3
+kube-system/coredns  1
+
+TOP DESTINATION  EVENTS
+10.0.0.1:80      3
+10.0.0.2:443     1
+
+TOP ERROR     EVENTS
+ECONNREFUSED  2
+ETIMEDOUT     1
+`
+	assert.Equal(t, expected, out.String())
+}
+
+func TestSummaryDisabled(t *testing.T) {
+	var out bytes.Buffer
+	Operator.out = &out
+	defer func() { Operator.out = nil }()
+
+	producer := simple.New("producer",
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			_, err := gadgetCtx.RegisterDataSource(datasource.TypeSingle, "events")
+			return err
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(Operator, producer))
+	go cancel()
+	require.NoError(t, gadgetCtx.Run(api.ParamValues{}))
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,375 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trigger is a data operator that watches the events of a (usually
+// lightweight) gadget and starts another (usually heavier) gadget for a fixed
+// amount of time once a condition fired often enough within a time window.
+// This allows using Inspektor Gadget as a flight recorder that only captures
+// detailed information when an anomaly happens, e.g. running a CPU profile when
+// the DNS failure rate goes up or dumping traceloop after an OOM kill.
+package trigger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/filter"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name     = "trigger"
+	Priority = 9100 // after the filter operator, so only filtered events are considered
+
+	ParamTriggerOn       = "trigger-on"
+	ParamTriggerCount    = "trigger-count"
+	ParamTriggerWindow   = "trigger-window"
+	ParamTriggerGadget   = "trigger-gadget"
+	ParamTriggerParams   = "trigger-params"
+	ParamTriggerDuration = "trigger-duration"
+	ParamTriggerCooldown = "trigger-cooldown"
+
+	// TriggerTag is added to the tags of all gadget instances started by this
+	// operator, so they can be found easily
+	TriggerTag = "trigger"
+)
+
+// Runner is able to start gadget instances in the background. It is
+// implemented by the instance manager of the gadget service.
+type Runner interface {
+	RunGadget(instance *api.GadgetInstance)
+}
+
+var (
+	runnerLock sync.Mutex
+	runner     Runner
+)
+
+// SetRunner sets the Runner that will be used to start triggered gadgets. If no
+// runner is set, the operator can't be used.
+func SetRunner(r Runner) {
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	runner = r
+}
+
+func getRunner() Runner {
+	runnerLock.Lock()
+	defer runnerLock.Unlock()
+	return runner
+}
+
+type triggerOperator struct{}
+
+func (t *triggerOperator) Name() string {
+	return name
+}
+
+func (t *triggerOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (t *triggerOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (t *triggerOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:   ParamTriggerOn,
+			Title: "Trigger On",
+			Description: "Condition that needs to match an event to count towards the trigger. " +
+				"Uses the same syntax as a single filter rule, e.g. 'rcode!=Success' or 'latency>1000000'. " +
+				"If empty, every event counts.",
+			TypeHint: api.TypeString,
+		},
+		{
+			Key:          ParamTriggerCount,
+			Title:        "Trigger Count",
+			Description:  "Number of matching events within trigger-window needed to fire the trigger",
+			DefaultValue: "1",
+			TypeHint:     api.TypeUint,
+		},
+		{
+			Key:          ParamTriggerWindow,
+			Title:        "Trigger Window",
+			Description:  "Time window in which trigger-count matching events need to be seen",
+			DefaultValue: "10s",
+			TypeHint:     api.TypeDuration,
+		},
+		{
+			Key:         ParamTriggerGadget,
+			Title:       "Trigger Gadget",
+			Description: "Image of the gadget to start once the trigger fires. If empty, the operator is disabled.",
+			TypeHint:    api.TypeString,
+		},
+		{
+			Key:   ParamTriggerParams,
+			Title: "Trigger Params",
+			Description: "Parameters to pass to the triggered gadget as 'key=value' pairs separated by ','. " +
+				"Keys are the same as used on the command line without the leading '--', e.g. 'operator.ebpf.map-fetch-interval=1s'",
+			TypeHint: api.TypeString,
+		},
+		{
+			Key:          ParamTriggerDuration,
+			Title:        "Trigger Duration",
+			Description:  "How long the triggered gadget should run",
+			DefaultValue: "30s",
+			TypeHint:     api.TypeDuration,
+		},
+		{
+			Key:   ParamTriggerCooldown,
+			Title: "Trigger Cooldown",
+			Description: "Minimum time between two triggered runs; defaults to trigger-duration if not set. " +
+				"Matching events seen during the cooldown are ignored.",
+			TypeHint: api.TypeDuration,
+		},
+	}
+}
+
+func parseParamValues(s string) (map[string]string, error) {
+	res := make(map[string]string)
+	if s == "" {
+		return res, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+		res[strings.TrimPrefix(k, "--")] = v
+	}
+	return res, nil
+}
+
+func (t *triggerOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	gadget := instanceParamValues[ParamTriggerGadget]
+	if gadget == "" {
+		// When getting the GadgetInfo, we still want the params to show up
+		if _, ok := instanceParamValues[ParamTriggerGadget]; !ok && getRunner() != nil {
+			return &triggerOperatorInstance{}, nil
+		}
+		return nil, nil
+	}
+
+	r := getRunner()
+	if r == nil {
+		return nil, fmt.Errorf("%s can only be used when running gadgets using a gadget service (e.g. ig daemon or kubectl gadget)", ParamTriggerGadget)
+	}
+
+	count, err := strconv.ParseUint(instanceParamValues[ParamTriggerCount], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamTriggerCount, err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", ParamTriggerCount)
+	}
+
+	window, err := time.ParseDuration(instanceParamValues[ParamTriggerWindow])
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamTriggerWindow, err)
+	}
+
+	duration, err := time.ParseDuration(instanceParamValues[ParamTriggerDuration])
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamTriggerDuration, err)
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", ParamTriggerDuration)
+	}
+
+	cooldown := duration
+	if val := instanceParamValues[ParamTriggerCooldown]; val != "" {
+		cooldown, err = time.ParseDuration(val)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", ParamTriggerCooldown, err)
+		}
+	}
+
+	paramValues, err := parseParamValues(instanceParamValues[ParamTriggerParams])
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", ParamTriggerParams, err)
+	}
+
+	return &triggerOperatorInstance{
+		runner:      r,
+		condition:   instanceParamValues[ParamTriggerOn],
+		window:      newWindow(int(count), window),
+		gadget:      gadget,
+		paramValues: paramValues,
+		duration:    duration,
+		cooldown:    cooldown,
+	}, nil
+}
+
+func (t *triggerOperator) Priority() int {
+	return Priority
+}
+
+type triggerOperatorInstance struct {
+	runner      Runner
+	condition   string
+	window      *window
+	gadget      string
+	paramValues map[string]string
+	duration    time.Duration
+	cooldown    time.Duration
+
+	mu        sync.Mutex
+	lastFired time.Time
+	fired     int
+}
+
+func (t *triggerOperatorInstance) Name() string {
+	return name
+}
+
+func (t *triggerOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if t.runner == nil {
+		return nil
+	}
+
+	var match func(datasource.DataSource, datasource.Data) bool
+	dss := make([]datasource.DataSource, 0)
+	if t.condition != "" {
+		ds, fn, err := filter.NewMatcher(gadgetCtx, t.condition)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", ParamTriggerOn, err)
+		}
+		match = fn
+		dss = append(dss, ds)
+	} else {
+		for _, ds := range gadgetCtx.GetDataSources() {
+			dss = append(dss, ds)
+		}
+	}
+
+	for _, ds := range dss {
+		switch ds.Type() {
+		case datasource.TypeSingle:
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				if match == nil || match(ds, data) {
+					t.observe(gadgetCtx, time.Now())
+				}
+				return nil
+			}, Priority)
+		case datasource.TypeArray:
+			ds.SubscribeArray(func(ds datasource.DataSource, data datasource.DataArray) error {
+				now := time.Now()
+				for i := 0; i < data.Len(); i++ {
+					if match == nil || match(ds, data.Get(i)) {
+						t.observe(gadgetCtx, now)
+					}
+				}
+				return nil
+			}, Priority)
+		}
+	}
+	return nil
+}
+
+// observe records a matching event and fires the trigger if needed
+func (t *triggerOperatorInstance) observe(gadgetCtx operators.GadgetContext, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.lastFired.IsZero() && now.Sub(t.lastFired) < t.cooldown {
+		return
+	}
+	if !t.window.add(now) {
+		return
+	}
+
+	t.lastFired = now
+	t.fired++
+	t.window.reset()
+
+	id, err := api.NewInstanceID()
+	if err != nil {
+		gadgetCtx.Logger().Warnf("trigger: creating instance ID: %v", err)
+		return
+	}
+
+	instanceName := fmt.Sprintf("%s-trigger-%d", gadgetCtx.ID(), t.fired)
+	gadgetCtx.Logger().Infof("trigger: condition fired, starting %q as %q for %s", t.gadget, instanceName, t.duration)
+
+	t.runner.RunGadget(&api.GadgetInstance{
+		Id:          id,
+		Name:        instanceName,
+		Tags:        []string{TriggerTag},
+		TimeCreated: now.Unix(),
+		GadgetConfig: &api.GadgetRunRequest{
+			ImageName:   t.gadget,
+			ParamValues: t.paramValues,
+			Version:     api.VersionGadgetRunProtocol,
+			Timeout:     int64(t.duration),
+		},
+	})
+}
+
+func (t *triggerOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (t *triggerOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+// window counts the events seen within a sliding time window
+type window struct {
+	threshold int
+	size      time.Duration
+	events    []time.Time
+}
+
+func newWindow(threshold int, size time.Duration) *window {
+	return &window{
+		threshold: threshold,
+		size:      size,
+		events:    make([]time.Time, 0, threshold),
+	}
+}
+
+// add adds an event at the given time and returns true if the threshold has
+// been reached within the window
+func (w *window) add(now time.Time) bool {
+	// Drop events that are outside the window
+	cutoff := now.Add(-w.size)
+	i := 0
+	for ; i < len(w.events); i++ {
+		if w.events[i].After(cutoff) {
+			break
+		}
+	}
+	w.events = append(w.events[:0], w.events[i:]...)
+
+	w.events = append(w.events, now)
+	return len(w.events) >= w.threshold
+}
+
+func (w *window) reset() {
+	w.events = w.events[:0]
+}
+
+var Operator = &triggerOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
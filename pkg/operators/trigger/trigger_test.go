@@ -0,0 +1,51 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trigger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow(t *testing.T) {
+	w := newWindow(3, time.Second)
+	now := time.Now()
+
+	assert.False(t, w.add(now))
+	assert.False(t, w.add(now.Add(100*time.Millisecond)))
+	// The first two events are outside the window now
+	assert.False(t, w.add(now.Add(1500*time.Millisecond)))
+	assert.False(t, w.add(now.Add(1600*time.Millisecond)))
+	assert.True(t, w.add(now.Add(1700*time.Millisecond)))
+
+	w.reset()
+	assert.False(t, w.add(now.Add(1800*time.Millisecond)))
+}
+
+func TestParseParamValues(t *testing.T) {
+	vals, err := parseParamValues("")
+	require.NoError(t, err)
+	assert.Empty(t, vals)
+
+	vals, err = parseParamValues("--operator.ebpf.foo=bar,baz=")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"operator.ebpf.foo": "bar", "baz": ""}, vals)
+
+	_, err = parseParamValues("foo")
+	require.Error(t, err)
+}
@@ -28,6 +28,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
@@ -87,6 +88,11 @@ type Parser interface {
 	JSONHandlerFunc(enrichers ...func(any) error) func([]byte)
 	JSONHandlerFuncArray(key string, enrichers ...func(any) error) func([]byte)
 
+	// CBORHandlerFunc and CBORHandlerFuncArray are like JSONHandlerFunc and JSONHandlerFuncArray, but for
+	// events encoded in CBOR
+	CBORHandlerFunc(enrichers ...func(any) error) func([]byte)
+	CBORHandlerFuncArray(key string, enrichers ...func(any) error) func([]byte)
+
 	// SetEventCallback sets the downstream callback
 	SetEventCallback(eventCallback any)
 
@@ -292,6 +298,22 @@ func (p *parser[T]) combineEventsCallback(event *T) {
 }
 
 func (p *parser[T]) JSONHandlerFunc(enrichers ...func(any) error) func([]byte) {
+	return p.decodingHandlerFunc(json.Unmarshal, enrichers...)
+}
+
+func (p *parser[T]) JSONHandlerFuncArray(key string, enrichers ...func(any) error) func([]byte) {
+	return p.decodingHandlerFuncArray(json.Unmarshal, key, enrichers...)
+}
+
+func (p *parser[T]) CBORHandlerFunc(enrichers ...func(any) error) func([]byte) {
+	return p.decodingHandlerFunc(cbor.Unmarshal, enrichers...)
+}
+
+func (p *parser[T]) CBORHandlerFuncArray(key string, enrichers ...func(any) error) func([]byte) {
+	return p.decodingHandlerFuncArray(cbor.Unmarshal, key, enrichers...)
+}
+
+func (p *parser[T]) decodingHandlerFunc(unmarshal func([]byte, any) error, enrichers ...func(any) error) func([]byte) {
 	cb := p.eventCallback
 	if p.eventCombinerEnabled {
 		cb = p.combineEventsCallback
@@ -300,7 +322,7 @@ func (p *parser[T]) JSONHandlerFunc(enrichers ...func(any) error) func([]byte) {
 	handler := p.eventHandler(cb, enrichers...)
 	return func(event []byte) {
 		ev := new(T)
-		err := json.Unmarshal(event, ev)
+		err := unmarshal(event, ev)
 		if err != nil {
 			p.writeLogMessage(logger.WarnLevel, "unmarshalling: %s", err)
 			return
@@ -309,7 +331,7 @@ func (p *parser[T]) JSONHandlerFunc(enrichers ...func(any) error) func([]byte) {
 	}
 }
 
-func (p *parser[T]) JSONHandlerFuncArray(key string, enrichers ...func(any) error) func([]byte) {
+func (p *parser[T]) decodingHandlerFuncArray(unmarshal func([]byte, any) error, key string, enrichers ...func(any) error) func([]byte) {
 	cb := p.eventCallbackArray
 	if p.eventCombinerEnabled {
 		cb = p.combineEventsArrayCallback
@@ -323,7 +345,7 @@ func (p *parser[T]) JSONHandlerFuncArray(key string, enrichers ...func(any) erro
 
 	return func(event []byte) {
 		var ev []*T
-		err := json.Unmarshal(event, &ev)
+		err := unmarshal(event, &ev)
 		if err != nil {
 			p.writeLogMessage(logger.WarnLevel, "unmarshalling: %s", err)
 			return
@@ -0,0 +1,106 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parser_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tcptoptypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/top/tcp/types"
+	exectypes "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/trace/exec/types"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/parser"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestCBORHandlerFunc(t *testing.T) {
+	ev := &exectypes.Event{
+		Event: eventtypes.Event{
+			CommonData: eventtypes.CommonData{
+				K8s: eventtypes.K8sMetadata{
+					Node: "node1",
+					BasicK8sMetadata: eventtypes.BasicK8sMetadata{
+						Namespace:     "default",
+						PodName:       "mypod",
+						PodLabels:     map[string]string{"app": "myapp", "tier": "web"},
+						ContainerName: "nginx",
+					},
+				},
+			},
+			Timestamp: 1710496800000000000,
+			Type:      eventtypes.NORMAL,
+		},
+		WithMountNsID: eventtypes.WithMountNsID{MountNsID: 4026531840},
+		Pid:           1234,
+		Comm:          "sh",
+		Retval:        -2,
+		Args:          []string{"/bin/sh", "-c", "ls"},
+		UpperLayer:    true,
+	}
+
+	var got *exectypes.Event
+	p := parser.NewParser(exectypes.GetColumns())
+	p.SetEventCallback(func(ev *exectypes.Event) { got = ev })
+
+	data, err := cbor.Marshal(ev)
+	require.NoError(t, err)
+	p.CBORHandlerFunc()(data)
+	require.NotNil(t, got)
+	assert.Equal(t, ev, got)
+
+	// Both encodings must give the same event
+	jsonData, err := json.Marshal(ev)
+	require.NoError(t, err)
+	fromCBOR := got
+	got = nil
+	p.JSONHandlerFunc()(jsonData)
+	assert.Equal(t, got, fromCBOR)
+
+	// The CBOR encoding is smaller
+	assert.Less(t, len(data), len(jsonData))
+}
+
+func TestCBORHandlerFuncArray(t *testing.T) {
+	stats := []*tcptoptypes.Stats{
+		{
+			Pid:       42,
+			Comm:      "curl",
+			IPVersion: 4,
+			SrcEndpoint: eventtypes.L4Endpoint{
+				L3Endpoint: eventtypes.L3Endpoint{Addr: "10.0.0.1", Version: 4},
+				Port:       43210,
+			},
+			DstEndpoint: eventtypes.L4Endpoint{
+				L3Endpoint: eventtypes.L3Endpoint{Addr: "10.0.0.2", Version: 4},
+				Port:       443,
+			},
+			Sent:     1 << 40,
+			Received: 12,
+		},
+		{Pid: 43, Comm: "wget"},
+	}
+
+	var got []*tcptoptypes.Stats
+	p := parser.NewParser(tcptoptypes.GetColumns())
+	p.SetEventCallback(func(stats []*tcptoptypes.Stats) { got = stats })
+
+	data, err := cbor.Marshal(stats)
+	require.NoError(t, err)
+	p.CBORHandlerFuncArray("node1")(data)
+	assert.Equal(t, stats, got)
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profileexporter converts the reports of the profile cpu gadget to
+// the pprof format and pushes them to continuous profiling backends.
+package profileexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/cpu/types"
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+// Exporter pushes profiles to a profiling backend
+type Exporter interface {
+	// Push sends prof to the backend. labels identify the profiled target,
+	// e.g. the namespace, pod and container.
+	Push(ctx context.Context, labels map[string]string, prof *profile.Profile) error
+	Close() error
+}
+
+// New returns the exporter for address, whose scheme selects the backend:
+//   - pyroscope://host:port (or pyroscopes:// for https) for the Pyroscope
+//     ingest API; http:// and https:// are accepted as well
+//   - parca://host:port (or parcas:// for TLS) for the Parca gRPC API
+//
+// appName is the application name the profiles are stored under.
+func New(address, appName string) (Exporter, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("parsing address %q: %w", address, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host in address %q", address)
+	}
+
+	switch u.Scheme {
+	case "pyroscope", "http":
+		u.Scheme = "http"
+		return NewPyroscope(u.String(), appName)
+	case "pyroscopes", "https":
+		u.Scheme = "https"
+		return NewPyroscope(u.String(), appName)
+	case "parca":
+		return NewParca(u.Host, appName, false)
+	case "parcas":
+		return NewParca(u.Host, appName, true)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q in address %q: expected pyroscope, pyroscopes, parca or parcas", u.Scheme, address)
+	}
+}
+
+// Labels returns the labels identifying the container an event comes from:
+// node, namespace, pod and container, plus the labels of the pod.
+func Labels(data *eventtypes.CommonData) map[string]string {
+	labels := map[string]string{}
+	for k, v := range data.K8s.PodLabels {
+		labels[sanitizeLabelName(k)] = v
+	}
+
+	labels["node"] = data.K8s.Node
+	labels["namespace"] = data.K8s.Namespace
+	labels["pod"] = data.K8s.PodName
+	labels["container"] = data.K8s.ContainerName
+	if labels["container"] == "" {
+		labels["container"] = data.Runtime.ContainerName
+	}
+	return labels
+}
+
+// sanitizeLabelName replaces the characters not allowed in label names by
+// both Pyroscope and Parca (like "/" and "." in Kubernetes label keys) with "_"
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// PushReports pushes one profile per container found in reports. The reports
+// must be enriched with the container information.
+func PushReports(ctx context.Context, exporter Exporter, reports []types.Report, start time.Time, duration time.Duration, sampleFreq uint64) error {
+	type container struct {
+		labels  map[string]string
+		reports []types.Report
+	}
+	containers := map[string]*container{}
+	var order []string
+
+	for _, report := range reports {
+		key := report.Runtime.ContainerID
+		if key == "" {
+			key = fmt.Sprintf("mntns-%d", report.MntnsID)
+		}
+		c, ok := containers[key]
+		if !ok {
+			c = &container{labels: Labels(&report.CommonData)}
+			containers[key] = c
+			order = append(order, key)
+		}
+		c.reports = append(c.reports, report)
+	}
+
+	var errs []error
+	for _, key := range order {
+		c := containers[key]
+		prof := NewProfile(c.reports, start, duration, sampleFreq)
+		if err := exporter.Push(ctx, c.labels, prof); err != nil {
+			errs = append(errs, fmt.Errorf("pushing profile of %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
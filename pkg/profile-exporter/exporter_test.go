@@ -0,0 +1,74 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profileexporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	eventtypes "github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		address  string
+		expected any
+		endpoint string
+	}{
+		{address: "pyroscope://pyroscope:4040", expected: &Pyroscope{}, endpoint: "http://pyroscope:4040/ingest"},
+		{address: "pyroscopes://pyroscope.example.com", expected: &Pyroscope{}, endpoint: "https://pyroscope.example.com/ingest"},
+		{address: "http://pyroscope:4040/prefix", expected: &Pyroscope{}, endpoint: "http://pyroscope:4040/prefix/ingest"},
+		{address: "parca://parca:7070", expected: &Parca{}},
+		{address: "parcas://parca.example.com:443", expected: &Parca{}},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			exporter, err := New(test.address, "app")
+			require.NoError(t, err)
+			defer exporter.Close()
+			assert.IsType(t, test.expected, exporter)
+			if p, ok := exporter.(*Pyroscope); ok {
+				assert.Equal(t, test.endpoint, p.endpoint.String())
+			}
+		})
+	}
+
+	for _, address := range []string{"foo://bar", "pyroscope://", "parca"} {
+		_, err := New(address, "app")
+		assert.Error(t, err, address)
+	}
+}
+
+func TestLabels(t *testing.T) {
+	data := &eventtypes.CommonData{}
+	data.K8s.Node = "node1"
+	data.K8s.Namespace = "default"
+	data.K8s.PodName = "web-1"
+	data.K8s.ContainerName = "nginx"
+	data.K8s.PodLabels = map[string]string{
+		"app.kubernetes.io/name": "web",
+		"pod":                    "overridden",
+	}
+
+	assert.Equal(t, map[string]string{
+		"node":                   "node1",
+		"namespace":              "default",
+		"pod":                    "web-1",
+		"container":              "nginx",
+		"app_kubernetes_io_name": "web",
+	}, Labels(data))
+}
@@ -0,0 +1,90 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profileexporter
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// fields returns the length-delimited fields of a protobuf message
+func fields(t *testing.T, b []byte) map[protowire.Number][][]byte {
+	ret := map[protowire.Number][][]byte{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		require.Equal(t, protowire.BytesType, typ)
+		b = b[n:]
+		v, n := protowire.ConsumeBytes(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		ret[num] = append(ret[num], v)
+	}
+	return ret
+}
+
+func TestParcaPush(t *testing.T) {
+	received := make(chan []byte, 1)
+	srv := grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+			method, _ := grpc.MethodFromServerStream(stream)
+			assert.Equal(t, parcaWriteRawMethod, method)
+			msg := &rawMessage{}
+			if err := stream.RecvMsg(msg); err != nil {
+				return err
+			}
+			received <- *msg
+			return stream.SendMsg(&rawMessage{})
+		}),
+	)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	exporter, err := New("parca://"+lis.Addr().String(), "myapp")
+	require.NoError(t, err)
+	defer exporter.Close()
+
+	prof := NewProfile(nil, time.Now(), time.Second, 19)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, exporter.Push(ctx, map[string]string{"pod": "web", "container": ""}, prof))
+
+	req := fields(t, <-received)
+	require.Len(t, req[2], 1)
+	series := fields(t, req[2][0])
+
+	labels := map[string]string{}
+	for _, l := range fields(t, series[1][0])[1] {
+		label := fields(t, l)
+		labels[string(label[1][0])] = string(label[2][0])
+	}
+	assert.Equal(t, map[string]string{"__name__": parcaProfileName, "job": "myapp", "pod": "web"}, labels)
+
+	sample := fields(t, series[2][0])
+	parsed, err := profile.ParseData(sample[1][0])
+	require.NoError(t, err)
+	assert.Equal(t, "cpu", parsed.PeriodType.Type)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profileexporter
+
+import (
+	"time"
+
+	"github.com/google/pprof/profile"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/cpu/types"
+)
+
+// NewProfile builds a pprof CPU profile from the given reports. start and
+// duration delimit the time the samples were collected in and sampleFreq is
+// the frequency (in Hz) the stacks were sampled at.
+func NewProfile(reports []types.Report, start time.Time, duration time.Duration, sampleFreq uint64) *profile.Profile {
+	period := int64(time.Second) / int64(sampleFreq)
+
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        period,
+		TimeNanos:     start.UnixNano(),
+		DurationNanos: duration.Nanoseconds(),
+	}
+
+	// Symbols are already resolved, so every frame gets a location with a
+	// single function named after the symbol
+	locations := map[string]*profile.Location{}
+	location := func(symbol string) *profile.Location {
+		if loc, ok := locations[symbol]; ok {
+			return loc
+		}
+		fn := &profile.Function{
+			ID:         uint64(len(prof.Function) + 1),
+			Name:       symbol,
+			SystemName: symbol,
+		}
+		prof.Function = append(prof.Function, fn)
+		loc := &profile.Location{
+			ID:   uint64(len(prof.Location) + 1),
+			Line: []profile.Line{{Function: fn}},
+		}
+		prof.Location = append(prof.Location, loc)
+		locations[symbol] = loc
+		return loc
+	}
+
+	for _, report := range reports {
+		if report.Count == 0 {
+			continue
+		}
+
+		// Locations go from the leaf to the root: the kernel stack is on top
+		// of the user one and the process name is used as root frame.
+		sample := &profile.Sample{
+			Value:    []int64{int64(report.Count), int64(report.Count) * period},
+			Label:    map[string][]string{"comm": {report.Comm}},
+			NumLabel: map[string][]int64{"pid": {int64(report.Pid)}},
+		}
+		for _, symbol := range report.KernelStack {
+			sample.Location = append(sample.Location, location(symbol))
+		}
+		for _, symbol := range report.UserStack {
+			sample.Location = append(sample.Location, location(symbol))
+		}
+		sample.Location = append(sample.Location, location(report.Comm))
+
+		prof.Sample = append(prof.Sample, sample)
+	}
+
+	return prof
+}
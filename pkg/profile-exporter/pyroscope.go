@@ -0,0 +1,131 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profileexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+const pyroscopeSpyName = "inspektor-gadget"
+
+// Pyroscope pushes profiles using the ingest API of Pyroscope, see
+// https://grafana.com/docs/pyroscope/latest/configure-server/about-server-api/
+type Pyroscope struct {
+	endpoint *url.URL
+	appName  string
+	client   *http.Client
+}
+
+// NewPyroscope returns an exporter pushing to the Pyroscope server at address
+// (e.g. http://pyroscope.monitoring:4040). Profiles are stored under the
+// application name appName.
+func NewPyroscope(address, appName string) (*Pyroscope, error) {
+	endpoint, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pyroscope address %q: %w", address, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q in pyroscope address %q", endpoint.Scheme, address)
+	}
+	endpoint = endpoint.JoinPath("ingest")
+
+	return &Pyroscope{
+		endpoint: endpoint,
+		appName:  appName,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// seriesName returns the name of the series in the format Pyroscope expects:
+// app.cpu{key=value,...}
+func (p *Pyroscope) seriesName(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+labels[k])
+	}
+	return p.appName + ".cpu{" + strings.Join(pairs, ",") + "}"
+}
+
+func (p *Pyroscope) Push(ctx context.Context, labels map[string]string, prof *profile.Profile) error {
+	start := time.Unix(0, prof.TimeNanos)
+	end := start.Add(time.Duration(prof.DurationNanos))
+
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("profile", "profile.pprof")
+	if err != nil {
+		return fmt.Errorf("creating form file: %w", err)
+	}
+	if err := prof.Write(part); err != nil {
+		return fmt.Errorf("writing profile: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return fmt.Errorf("closing multipart writer: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("name", p.seriesName(labels))
+	query.Set("from", strconv.FormatInt(start.Unix(), 10))
+	query.Set("until", strconv.FormatInt(end.Unix(), 10))
+	query.Set("format", "pprof")
+	query.Set("spyName", pyroscopeSpyName)
+
+	endpoint := *p.endpoint
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("pushing profile: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushing profile: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (p *Pyroscope) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
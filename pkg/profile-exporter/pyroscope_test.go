@@ -0,0 +1,76 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profileexporter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/profile/cpu/types"
+)
+
+func TestPyroscopePush(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	reports := []types.Report{
+		{Comm: "nginx", Pid: 42, KernelStack: []string{"do_syscall_64", "entry_SYSCALL_64"}, Count: 3},
+		{Comm: "nginx", Pid: 42, UserStack: []string{"[unknown]"}, Count: 2},
+		{Comm: "idle", Count: 0},
+	}
+
+	var received *profile.Profile
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ingest", r.URL.Path)
+		assert.Equal(t, "myapp.cpu{namespace=default,pod=web}", r.URL.Query().Get("name"))
+		assert.Equal(t, "1700000000", r.URL.Query().Get("from"))
+		assert.Equal(t, "1700000010", r.URL.Query().Get("until"))
+		assert.Equal(t, "pprof", r.URL.Query().Get("format"))
+
+		f, _, err := r.FormFile("profile")
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received, err = profile.Parse(f)
+		assert.NoError(t, err)
+	}))
+	defer srv.Close()
+
+	exporter, err := NewPyroscope(srv.URL, "myapp")
+	require.NoError(t, err)
+
+	prof := NewProfile(reports, start, 10*time.Second, 19)
+	labels := map[string]string{"namespace": "default", "pod": "web", "container": ""}
+	require.NoError(t, exporter.Push(context.Background(), labels, prof))
+
+	require.NotNil(t, received)
+	require.Len(t, received.Sample, 2)
+	assert.Equal(t, []int64{3, 3 * (int64(time.Second) / 19)}, received.Sample[0].Value)
+	require.Len(t, received.Sample[0].Location, 3)
+	assert.Equal(t, "do_syscall_64", received.Sample[0].Location[0].Line[0].Function.Name)
+	assert.Equal(t, "nginx", received.Sample[0].Location[2].Line[0].Function.Name)
+	assert.Equal(t, []int64{42}, received.Sample[1].NumLabel["pid"])
+}
+
+func TestNewPyroscopeInvalid(t *testing.T) {
+	_, err := NewPyroscope("ftp://foo", "myapp")
+	require.Error(t, err)
+}
@@ -21,6 +21,9 @@ import (
 //go:embed crd/bases/gadget.kinvolk.io_traces.yaml
 var TracesCustomResource string
 
+//go:embed crd/bases/gadget.kinvolk.io_traceschedules.yaml
+var TraceSchedulesCustomResource string
+
 //go:embed manifests/deploy.yaml
 var GadgetDeployment string
 
@@ -68,12 +68,21 @@ const (
 	ParamTags              = "tags"
 	ParamName              = "name"
 	ParamEventBufferLength = "event-buffer-length"
+	ParamSummaryByNode     = "summary-by-node"
+	ParamSummaryOnly       = "summary-only"
 
 	ParamTLSKey        = "tls-key-file"
 	ParamTLSCert       = "tls-cert-file"
 	ParamTLSServerCA   = "tls-server-ca-file"
 	ParamTLSServerName = "tls-server-name"
 
+	// The kubeconfig flags already use the tls- prefix, hence a different
+	// set of keys is used in the Kubernetes connection mode
+	ParamGadgetTLSKey        = "gadget-tls-key-file"
+	ParamGadgetTLSCert       = "gadget-tls-cert-file"
+	ParamGadgetTLSServerCA   = "gadget-tls-server-ca-file"
+	ParamGadgetTLSServerName = "gadget-tls-server-name"
+
 	// ParamGadgetServiceTCPPort is only used in combination with KubernetesProxyConnectionMethodTCP
 	ParamGadgetServiceTCPPort = "tcp-port"
 
@@ -87,6 +96,10 @@ const (
 
 	ParamGadgetNamespace   string = "gadget-namespace"
 	DefaultGadgetNamespace string = "gadget"
+
+	// DefaultTLSServerName is the name the certificates of the gadget pods
+	// are issued for when connecting through the Kubernetes API server
+	DefaultTLSServerName string = "gadget"
 )
 
 type Runtime struct {
@@ -185,6 +198,18 @@ func (r *Runtime) ParamDescs() params.ParamDescs {
 			DefaultValue: "0",
 			Tags:         []string{"!attach"},
 		},
+		{
+			Key:          ParamSummaryByNode,
+			Description:  "Print the number of events, dropped messages, warnings and errors per node when the gadget stops",
+			TypeHint:     params.TypeBool,
+			DefaultValue: "false",
+		},
+		{
+			Key:          ParamSummaryOnly,
+			Description:  "Only print the summary per node instead of the events; implies --summary-by-node",
+			TypeHint:     params.TypeBool,
+			DefaultValue: "false",
+		},
 	}...)
 	switch r.connectionMode {
 	case ConnectionModeDirect:
@@ -256,6 +281,27 @@ func (r *Runtime) GlobalParamDescs() params.ParamDescs {
 				DefaultValue: DefaultGadgetNamespace,
 				TypeHint:     params.TypeString,
 			},
+			{
+				Key:         ParamGadgetTLSKey,
+				Description: "TLS client key (required if mTLS is enabled on the gadget pods)",
+				TypeHint:    params.TypeString,
+			},
+			{
+				Key:         ParamGadgetTLSCert,
+				Description: "TLS client certificate (required if mTLS is enabled on the gadget pods)",
+				TypeHint:    params.TypeString,
+			},
+			{
+				Key:         ParamGadgetTLSServerCA,
+				Description: "TLS server CA certificate (required if mTLS is enabled on the gadget pods)",
+				TypeHint:    params.TypeString,
+			},
+			{
+				Key:          ParamGadgetTLSServerName,
+				Description:  "TLS server name to verify the certificate of the gadget pods against",
+				DefaultValue: DefaultTLSServerName,
+				TypeHint:     params.TypeString,
+			},
 		}...)
 		return p
 	}
@@ -451,9 +497,14 @@ func (r *Runtime) dialContext(dialCtx context.Context, target target, timeout ti
 		grpc.WithReturnConnectionError(),
 	}
 
-	tlsKey := r.globalParams.Get(ParamTLSKey).String()
-	tlsCert := r.globalParams.Get(ParamTLSCert).String()
-	tlsCA := r.globalParams.Get(ParamTLSServerCA).String()
+	paramTLSKey, paramTLSCert, paramTLSServerCA, paramTLSServerName := ParamTLSKey, ParamTLSCert, ParamTLSServerCA, ParamTLSServerName
+	if r.connectionMode == ConnectionModeKubernetesProxy {
+		paramTLSKey, paramTLSCert, paramTLSServerCA, paramTLSServerName = ParamGadgetTLSKey, ParamGadgetTLSCert, ParamGadgetTLSServerCA, ParamGadgetTLSServerName
+	}
+
+	tlsKey := r.globalParams.Get(paramTLSKey).String()
+	tlsCert := r.globalParams.Get(paramTLSCert).String()
+	tlsCA := r.globalParams.Get(paramTLSServerCA).String()
 
 	tlsOptionsSet := 0
 	for _, tlsOption := range []string{tlsKey, tlsCert, tlsCA} {
@@ -469,9 +520,9 @@ missing at least one the TLS related options:
 	* %s: %q
 	* %s: %q
 All these options should be set at the same time to enable TLS connection`,
-			ParamTLSKey, tlsKey,
-			ParamTLSCert, tlsCert,
-			ParamTLSServerCA, tlsCA)
+			paramTLSKey, tlsKey,
+			paramTLSCert, tlsCert,
+			paramTLSServerCA, tlsCA)
 	}
 
 	if tlsOptionsSet == 3 {
@@ -496,12 +547,12 @@ All these options should be set at the same time to enable TLS connection`,
 			RootCAs:      ca,
 		}
 
-		if serverName := r.globalParams.Get(ParamTLSServerName).String(); serverName != "" {
+		if serverName := r.globalParams.Get(paramTLSServerName).String(); serverName != "" {
 			tlsConfig.ServerName = serverName
 		}
 
 		if tlsConfig.ServerName == "" {
-			return nil, fmt.Errorf("invalid hostname, use %s to override", ParamTLSServerName)
+			return nil, fmt.Errorf("invalid hostname, use %s to override", paramTLSServerName)
 		}
 
 		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
@@ -557,6 +608,9 @@ func (r *Runtime) runBuiltInGadget(gadgetCtx runtime.GadgetContext, target targe
 		FanOut:         false,
 		LogLevel:       uint32(gadgetCtx.Logger().GetLevel()),
 		Timeout:        int64(gadgetCtx.Timeout()),
+
+		// Events are decoded here, so there's no need to have them in JSON
+		PayloadEncoding: api.PayloadEncodingCBOR,
 	}
 
 	runClient, err := client.RunBuiltInGadget(connCtx)
@@ -574,6 +628,8 @@ func (r *Runtime) runBuiltInGadget(gadgetCtx runtime.GadgetContext, target targe
 
 	jsonHandler := func([]byte) {}
 	jsonArrayHandler := func([]byte) {}
+	cborHandler := func([]byte) {}
+	cborArrayHandler := func([]byte) {}
 
 	if parser != nil {
 		var enrichers []func(any) error
@@ -587,6 +643,8 @@ func (r *Runtime) runBuiltInGadget(gadgetCtx runtime.GadgetContext, target targe
 
 		jsonHandler = parser.JSONHandlerFunc(enrichers...)
 		jsonArrayHandler = parser.JSONHandlerFuncArray(target.node, enrichers...)
+		cborHandler = parser.CBORHandlerFunc(enrichers...)
+		cborArrayHandler = parser.CBORHandlerFuncArray(target.node, enrichers...)
 	}
 
 	doneChan := make(chan error)
@@ -617,6 +675,17 @@ func (r *Runtime) runBuiltInGadget(gadgetCtx runtime.GadgetContext, target targe
 					continue
 				}
 				jsonHandler(ev.Payload)
+			case api.EventTypeGadgetPayloadCBOR:
+				if expectedSeq != ev.Seq {
+					gadgetCtx.Logger().Warnf("%-20s | expected seq %d, got %d, %d messages dropped", target.node, expectedSeq, ev.Seq, ev.Seq-expectedSeq)
+				}
+				expectedSeq = ev.Seq + 1
+				// Major type 4 is an array
+				if len(ev.Payload) > 0 && ev.Payload[0]>>5 == 4 {
+					cborArrayHandler(ev.Payload)
+					continue
+				}
+				cborHandler(ev.Payload)
 			case api.EventTypeGadgetResult:
 				gadgetCtx.Logger().Debugf("%-20s | got result from server", target.node)
 				result = ev.Payload
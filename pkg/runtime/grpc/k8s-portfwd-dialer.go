@@ -109,6 +109,11 @@ func NewK8SPortFwdConn(ctx context.Context, config *rest.Config, namespace strin
 	return conn, nil
 }
 
+// NewK8SPortFwdConnToPod is like NewK8SPortFwdConn, for a pod given by its name
+func NewK8SPortFwdConnToPod(ctx context.Context, config *rest.Config, namespace string, podName string, targetPort uint16, timeout time.Duration) (net.Conn, error) {
+	return NewK8SPortFwdConn(ctx, config, namespace, target{addressOrPod: podName}, targetPort, timeout)
+}
+
 func (k *k8sPortFwdDialer) Close() error {
 	k.stream.Close()
 	return k.conn.Close()
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"time"
 
@@ -88,29 +89,62 @@ func (r *Runtime) RunGadget(gadgetCtx runtime.GadgetContext, runtimeParams *para
 
 	gadgetCtx.SetVar(runtime.NumRunTargets, len(targets))
 
-	_, err = r.runGadgetOnTargets(gadgetCtx, paramValues, targets)
+	summaryOnly := runtimeParams.Get(ParamSummaryOnly).AsBool()
+	var summaries map[string]*nodeSummary
+	if summaryOnly || runtimeParams.Get(ParamSummaryByNode).AsBool() {
+		summaries = make(map[string]*nodeSummary, len(targets))
+		for _, t := range targets {
+			summaries[t.node] = &nodeSummary{node: t.node}
+		}
+	}
+
+	_, err = r.runGadgetOnTargets(gadgetCtx, paramValues, targets, summaries, !summaryOnly)
+
+	// All targets are done: stop the local operators and give them the
+	// chance to flush their output (e.g. the summary operator)
+	gadgetCtx.Cancel()
+	gadgetCtx.WaitForLocalOperators()
+
+	if summaries != nil {
+		if !summaryOnly {
+			fmt.Println()
+		}
+		if err := printNodeSummaries(os.Stdout, summaries); err != nil {
+			gadgetCtx.Logger().Warnf("printing summary: %v", err)
+		}
+	}
 	return err
 }
 
+// runGadgetOnTargets runs the gadget on all targets. summaries (by node) is
+// optional. If emit is false, the events are only counted and not emitted to
+// the local data sources.
 func (r *Runtime) runGadgetOnTargets(
 	gadgetCtx runtime.GadgetContext,
 	paramMap map[string]string,
 	targets []target,
+	summaries map[string]*nodeSummary,
+	emit bool,
 ) (runtime.CombinedGadgetResult, error) {
 	results := make(runtime.CombinedGadgetResult, len(targets))
 	var resultsLock sync.Mutex
 
 	wg := sync.WaitGroup{}
 	for _, t := range targets {
+		summary := summaries[t.node]
+
 		wg.Add(1)
 		go func(target target) {
 			gadgetCtx.Logger().Debugf("running gadget on node %q", target.node)
-			res, err := r.runGadget(gadgetCtx, target, paramMap)
+			res, err := r.runGadget(gadgetCtx, target, paramMap, summary, emit)
 			resultsLock.Lock()
 			results[target.node] = &runtime.GadgetResult{
 				Payload: res,
 				Error:   err,
 			}
+			if summary != nil {
+				summary.err = err
+			}
 			resultsLock.Unlock()
 			wg.Done()
 		}(t)
@@ -120,7 +154,13 @@ func (r *Runtime) runGadgetOnTargets(
 	return results, results.Err()
 }
 
-func (r *Runtime) runGadget(gadgetCtx runtime.GadgetContext, target target, allParams map[string]string) ([]byte, error) {
+func (r *Runtime) runGadget(
+	gadgetCtx runtime.GadgetContext,
+	target target,
+	allParams map[string]string,
+	summary *nodeSummary,
+	emit bool,
+) ([]byte, error) {
 	// Notice that we cannot use gadgetCtx.Context() here, as that would - when cancelled by the user - also cancel the
 	// underlying gRPC connection. That would then lead to results not being received anymore (mostly for profile
 	// gadgets.)
@@ -205,6 +245,7 @@ func (r *Runtime) runGadget(gadgetCtx runtime.GadgetContext, target target, allP
 				}
 				if expectedSeq != ev.Seq {
 					gadgetCtx.Logger().Warnf("%-20s | expected seq %d, got %d, %d messages dropped", target.node, expectedSeq, ev.Seq, ev.Seq-expectedSeq)
+					summary.addDropped(ev.Seq - expectedSeq)
 				}
 				expectedSeq = ev.Seq + 1
 				if ds, ok := dsMap[ev.DataSourceID]; ok && ds != nil {
@@ -222,6 +263,15 @@ func (r *Runtime) runGadget(gadgetCtx runtime.GadgetContext, target target, allP
 						gadgetCtx.Logger().Debugf("error unmarshaling payload: %v", err)
 						continue
 					}
+					if pa, ok := p.(datasource.PacketArray); ok {
+						summary.addEvents(pa.Len())
+					} else {
+						summary.addEvents(1)
+					}
+					if !emit {
+						ds.Release(p)
+						continue
+					}
 					ds.EmitAndRelease(p)
 				}
 			case api.EventTypeGadgetResult:
@@ -258,6 +308,12 @@ func (r *Runtime) runGadget(gadgetCtx runtime.GadgetContext, target target, allP
 				initialized = true
 			default:
 				if ev.Type >= 1<<api.EventLogShift {
+					switch level := logger.Level(ev.Type >> api.EventLogShift); {
+					case level <= logger.ErrorLevel:
+						summary.addError()
+					case level == logger.WarnLevel:
+						summary.addWarning()
+					}
 					gadgetCtx.Logger().Log(logger.Level(ev.Type>>api.EventLogShift), fmt.Sprintf("%-20s | %s", target.node, string(ev.Payload)))
 					continue
 				}
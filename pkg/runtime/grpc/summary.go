@@ -0,0 +1,89 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcruntime
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"text/tabwriter"
+)
+
+// nodeSummary counts what was received from a single node while running a
+// gadget. All methods can be called on a nil summary.
+type nodeSummary struct {
+	node     string
+	events   atomic.Uint64
+	dropped  atomic.Uint64
+	warnings atomic.Uint64
+	errors   atomic.Uint64
+	err      error
+}
+
+func (s *nodeSummary) addEvents(n int) {
+	if s != nil {
+		s.events.Add(uint64(n))
+	}
+}
+
+func (s *nodeSummary) addDropped(n uint32) {
+	if s != nil {
+		s.dropped.Add(uint64(n))
+	}
+}
+
+func (s *nodeSummary) addWarning() {
+	if s != nil {
+		s.warnings.Add(1)
+	}
+}
+
+func (s *nodeSummary) addError() {
+	if s != nil {
+		s.errors.Add(1)
+	}
+}
+
+func (s *nodeSummary) status() string {
+	if s.err != nil {
+		return s.err.Error()
+	}
+	return "ok"
+}
+
+// printNodeSummaries writes a table with a line per node, sorted by the number
+// of events in descending order, so the nodes exhibiting a problem are shown
+// first.
+func printNodeSummaries(w io.Writer, summariesByNode map[string]*nodeSummary) error {
+	summaries := make([]*nodeSummary, 0, len(summariesByNode))
+	for _, s := range summariesByNode {
+		summaries = append(summaries, s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].events.Load() != summaries[j].events.Load() {
+			return summaries[i].events.Load() > summaries[j].events.Load()
+		}
+		return summaries[i].node < summaries[j].node
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tEVENTS\tDROPPED\tWARNINGS\tERRORS\tSTATUS")
+	for _, s := range summaries {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", s.node, s.events.Load(), s.dropped.Load(),
+			s.warnings.Load(), s.errors.Load(), s.status())
+	}
+	return tw.Flush()
+}
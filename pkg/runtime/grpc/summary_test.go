@@ -0,0 +1,150 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcruntime
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// nodeUpdates is what is received from a node while the gadget runs
+type nodeUpdates struct {
+	events   []int
+	dropped  []uint32
+	warnings int
+	errors   int
+	err      error
+}
+
+func TestPrintNodeSummaries(t *testing.T) {
+	t.Parallel()
+
+	type testDefinition struct {
+		updates  map[string]nodeUpdates
+		expected string
+	}
+
+	tests := map[string]testDefinition{
+		"no_nodes": {
+			updates: map[string]nodeUpdates{},
+			expected: "" +
+				"NODE  EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n",
+		},
+		"events_are_added_up": {
+			updates: map[string]nodeUpdates{
+				"node1": {events: []int{1, 10, 5}, dropped: []uint32{2, 3}, warnings: 2},
+			},
+			expected: "" +
+				"NODE   EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n" +
+				"node1  16      5        2         0       ok\n",
+		},
+		"sorted_by_events_then_node": {
+			updates: map[string]nodeUpdates{
+				"node-c": {events: []int{3}},
+				"node-b": {events: []int{7}},
+				"node-a": {events: []int{3}},
+			},
+			expected: "" +
+				"NODE    EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n" +
+				"node-b  7       0        0         0       ok\n" +
+				"node-a  3       0        0         0       ok\n" +
+				"node-c  3       0        0         0       ok\n",
+		},
+		"node_without_events": {
+			updates: map[string]nodeUpdates{
+				"node1": {events: []int{4}},
+				"node2": {},
+			},
+			expected: "" +
+				"NODE   EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n" +
+				"node1  4       0        0         0       ok\n" +
+				"node2  0       0        0         0       ok\n",
+		},
+		"node_with_error": {
+			updates: map[string]nodeUpdates{
+				"node1": {events: []int{4}},
+				"node2": {events: []int{1}, errors: 3, err: errors.New("connection refused")},
+			},
+			expected: "" +
+				"NODE   EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n" +
+				"node1  4       0        0         0       ok\n" +
+				"node2  1       0        0         3       connection refused\n",
+		},
+		"node_failing_without_events": {
+			updates: map[string]nodeUpdates{
+				"node1": {err: errors.New("gadget not found")},
+			},
+			expected: "" +
+				"NODE   EVENTS  DROPPED  WARNINGS  ERRORS  STATUS\n" +
+				"node1  0       0        0         0       gadget not found\n",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			summaries := make(map[string]*nodeSummary, len(test.updates))
+			for node, u := range test.updates {
+				s := &nodeSummary{node: node}
+				summaries[node] = s
+
+				// Events of a node are received concurrently with
+				// the ones of the other nodes
+				var wg sync.WaitGroup
+				for _, n := range u.events {
+					wg.Add(1)
+					go func(n int) {
+						defer wg.Done()
+						s.addEvents(n)
+					}(n)
+				}
+				wg.Wait()
+				for _, n := range u.dropped {
+					s.addDropped(n)
+				}
+				for i := 0; i < u.warnings; i++ {
+					s.addWarning()
+				}
+				for i := 0; i < u.errors; i++ {
+					s.addError()
+				}
+				s.err = u.err
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, printNodeSummaries(&buf, summaries))
+			require.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestNilNodeSummary(t *testing.T) {
+	t.Parallel()
+
+	// Summaries are nil when they aren't requested
+	var s *nodeSummary
+	require.NotPanics(t, func() {
+		s.addEvents(1)
+		s.addDropped(1)
+		s.addWarning()
+		s.addError()
+	})
+}
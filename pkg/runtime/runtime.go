@@ -57,6 +57,7 @@ type GadgetContext interface {
 	GetVar(string) (any, bool)
 	SerializeGadgetInfo() (*api.GadgetInfo, error)
 	LoadGadgetInfo(info *api.GadgetInfo, paramValues api.ParamValues, run bool) error
+	WaitForLocalOperators()
 	Params() []*api.Param
 	SetMetadata([]byte)
 	SetParams([]*api.Param)
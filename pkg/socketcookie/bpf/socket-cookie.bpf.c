@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: (GPL-2.0 WITH Linux-syscall-note) OR Apache-2.0
+/* Copyright (c) 2024 The Inspektor Gadget authors */
+
+#include <vmlinux.h>
+#include <bpf/bpf_helpers.h>
+
+/*
+ * The kernel only assigns a cookie to a socket the first time it's asked for
+ * it. Gadgets tracing TCP sockets with kprobes can't ask for it but they can
+ * read sk->__sk_common.skc_cookie, so ask for it as soon as the sockets
+ * connect or are accepted.
+ */
+SEC("sockops")
+int ig_sock_cookie(struct bpf_sock_ops *ctx)
+{
+	switch (ctx->op) {
+	case BPF_SOCK_OPS_TCP_CONNECT_CB:
+	case BPF_SOCK_OPS_PASSIVE_ESTABLISHED_CB:
+		bpf_get_socket_cookie(ctx);
+		break;
+	}
+
+	return 1;
+}
+
+char LICENSE[] SEC("license") = "GPL";
@@ -0,0 +1,104 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !withoutebpf
+
+// Package socketcookie makes the kernel assign a cookie to TCP sockets as
+// they connect or are accepted.
+//
+// The cookie of a socket identifies it until the system reboots, so gadgets
+// use it as the ID of the flows. The kernel only assigns it the first time
+// it's asked for, which kprobes can't do, but they can read it afterwards
+// from sk->__sk_common.skc_cookie.
+package socketcookie
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+//go:generate go run github.com/cilium/ebpf/cmd/bpf2go -target bpfel -cc clang -cflags ${CFLAGS} socketcookie ./bpf/socket-cookie.bpf.c -- -I./bpf/
+
+// Assigner attaches a sock_ops program to the root cgroup, asking for the
+// cookies of all the TCP sockets of the system.
+type Assigner struct {
+	objs socketcookieObjects
+	link link.Link
+}
+
+// NewAssigner starts assigning cookies to TCP sockets. It requires cgroup v2.
+func NewAssigner() (*Assigner, error) {
+	a := &Assigner{}
+
+	if err := a.start(); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// rootCgroup returns the path of the root of the cgroup v2 hierarchy of the
+// host. It's looked up from the init process of the host first, as the gadget
+// could run in a cgroup namespace.
+func rootCgroup() (string, error) {
+	for _, base := range []string{
+		filepath.Join(host.HostProcFs, "1", "root", "sys", "fs", "cgroup"),
+		"/sys/fs/cgroup",
+	} {
+		for _, path := range []string{filepath.Join(base, "unified"), base} {
+			if _, err := os.Stat(filepath.Join(path, "cgroup.controllers")); err == nil {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 not found")
+}
+
+func (a *Assigner) start() error {
+	cgroup, err := rootCgroup()
+	if err != nil {
+		return err
+	}
+
+	if err := loadSocketcookieObjects(&a.objs, nil); err != nil {
+		return fmt.Errorf("loading ebpf program: %w", err)
+	}
+
+	a.link, err = link.AttachCgroup(link.CgroupOptions{
+		Path:    cgroup,
+		Attach:  ebpf.AttachCGroupSockOps,
+		Program: a.objs.IgSockCookie,
+	})
+	if err != nil {
+		return fmt.Errorf("attaching sock_ops program to %s: %w", cgroup, err)
+	}
+
+	return nil
+}
+
+// Close stops assigning cookies. The sockets keep the cookies they got.
+func (a *Assigner) Close() {
+	if a.link != nil {
+		a.link.Close()
+		a.link = nil
+	}
+	a.objs.Close()
+}
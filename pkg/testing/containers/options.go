@@ -66,6 +66,14 @@ func WithLimits(limits map[string]string) ContainerOption {
 	}
 }
 
+// WithReadinessProbe adds an HTTP readiness probe on the given port to the
+// container. Only supported by Kubernetes.
+func WithReadinessProbe(port int) ContainerOption {
+	return func(opts *cOptions) {
+		opts.options = append(opts.options, testutils.WithReadinessProbe(port))
+	}
+}
+
 func WithCleanup() ContainerOption {
 	return func(opts *cOptions) {
 		opts.cleanup = true
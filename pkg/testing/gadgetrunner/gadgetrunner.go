@@ -28,6 +28,7 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/stretchr/testify/require"
 
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	igjson "github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/formatters/json"
 	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
@@ -38,6 +39,7 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/runtime/local"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
 
 	// TODO: create a common package with all operators
 	_ "github.com/inspektor-gadget/inspektor-gadget/pkg/operators/ebpf"
@@ -204,3 +206,31 @@ func GetGadgetImageName(gadget string) string {
 	}
 	return gadget
 }
+
+// AttachContainer attaches the programs of the gadget that are attached per
+// container, like uprobes, to a fake container with the given init process and
+// mount namespace, as the local manager does when a container is created. It
+// must be called once the gadget is started, e.g. from OnGadgetRun.
+func AttachContainer(gadgetCtx operators.GadgetContext, name string, pid int, mntns uint64) error {
+	instance, ok := gadgetCtx.GetVar("ebpfInstance")
+	if !ok {
+		return fmt.Errorf("getting ebpfInstance")
+	}
+	attacher, ok := instance.(interface {
+		AttachContainer(container *containercollection.Container) error
+	})
+	if !ok {
+		return fmt.Errorf("ebpfInstance can't attach containers")
+	}
+
+	return attacher.AttachContainer(&containercollection.Container{
+		Runtime: containercollection.RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				ContainerID:   name,
+				ContainerName: name,
+				ContainerPID:  uint32(pid),
+			},
+		},
+		Mntns: mntns,
+	})
+}
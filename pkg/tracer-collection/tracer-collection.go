@@ -15,8 +15,14 @@
 package tracercollection
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 
 	"github.com/cilium/ebpf"
 	log "github.com/sirupsen/logrus"
@@ -31,7 +37,16 @@ const (
 )
 
 type TracerCollection struct {
-	tracers             map[string]tracer
+	mu      sync.Mutex
+	tracers map[string]tracer
+
+	// filters are the mount namespace filter maps by selector. Tracers
+	// with identical selectors share the same map.
+	filters map[string]*mntnsFilter
+
+	// pinPath is the directory where the filter maps are pinned, if set
+	pinPath string
+
 	containerCollection *containercollection.ContainerCollection
 	testOnly            bool
 }
@@ -41,32 +56,128 @@ type tracer struct {
 
 	containerSelector containercollection.ContainerSelector
 
-	mntnsSetMap *ebpf.Map
+	filter *mntnsFilter
 
 	gadgetStream *stream.GadgetStream
 }
 
-func NewTracerCollection(cc *containercollection.ContainerCollection) (*TracerCollection, error) {
-	return &TracerCollection{
+// mntnsFilter is a map of the mount namespaces of the containers matching a
+// selector, used by gadgets as gadget_mntns_filter_map
+type mntnsFilter struct {
+	key         string
+	selector    containercollection.ContainerSelector
+	mntnsSetMap *ebpf.Map
+	pinned      string
+
+	// refs is the number of tracers using the map
+	refs int
+}
+
+type Option func(*TracerCollection) error
+
+// WithPinPath pins the filter maps in the given bpffs directory, so they can
+// be inspected with bpftool or used by other programs. Stale maps pinned by a
+// previous instance are removed.
+func WithPinPath(pinPath string) Option {
+	return func(tc *TracerCollection) error {
+		if err := os.RemoveAll(pinPath); err != nil {
+			return fmt.Errorf("removing stale filter maps: %w", err)
+		}
+		if err := os.MkdirAll(pinPath, 0o700); err != nil {
+			return fmt.Errorf("creating folder for pinning filter maps: %w", err)
+		}
+		tc.pinPath = pinPath
+		return nil
+	}
+}
+
+func NewTracerCollection(cc *containercollection.ContainerCollection, options ...Option) (*TracerCollection, error) {
+	tc := &TracerCollection{
 		tracers:             make(map[string]tracer),
+		filters:             make(map[string]*mntnsFilter),
 		containerCollection: cc,
-	}, nil
+	}
+	for _, o := range options {
+		if err := o(tc); err != nil {
+			return nil, err
+		}
+	}
+	return tc, nil
 }
 
 func NewTracerCollectionTest(cc *containercollection.ContainerCollection) (*TracerCollection, error) {
 	return &TracerCollection{
 		tracers:             make(map[string]tracer),
+		filters:             make(map[string]*mntnsFilter),
 		containerCollection: cc,
 		testOnly:            true,
 	}, nil
 }
 
+// selectorKey returns a key identifying the containers matched by selector
+func selectorKey(selector *containercollection.ContainerSelector) (string, error) {
+	b, err := json.Marshal(selector)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+func (tc *TracerCollection) newFilter(key string, selector containercollection.ContainerSelector) (*mntnsFilter, error) {
+	mntnsSpec := &ebpf.MapSpec{
+		Name:       MountMapPrefix + key,
+		Type:       ebpf.Hash,
+		KeySize:    8,
+		ValueSize:  4,
+		MaxEntries: MaxContainersPerNode,
+	}
+	mntnsSetMap, err := ebpf.NewMap(mntnsSpec)
+	if err != nil {
+		return nil, fmt.Errorf("creating mntnsset map: %w", err)
+	}
+
+	f := &mntnsFilter{
+		key:         key,
+		selector:    selector,
+		mntnsSetMap: mntnsSetMap,
+	}
+	if tc.pinPath != "" {
+		f.pinned = filepath.Join(tc.pinPath, MountMapPrefix+key)
+		if err := mntnsSetMap.Pin(f.pinned); err != nil {
+			mntnsSetMap.Close()
+			return nil, fmt.Errorf("pinning mntnsset map: %w", err)
+		}
+	}
+
+	tc.containerCollection.ContainerRangeWithSelector(&selector, func(c *containercollection.Container) {
+		one := uint32(1)
+		mntnsC := uint64(c.Mntns)
+		if mntnsC != 0 {
+			mntnsSetMap.Put(mntnsC, one)
+		}
+	})
+	return f, nil
+}
+
+func (f *mntnsFilter) close() {
+	if f.pinned != "" {
+		if err := f.mntnsSetMap.Unpin(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("unpinning %s: %v", f.pinned, err)
+		}
+	}
+	f.mntnsSetMap.Close()
+}
+
 func (tc *TracerCollection) TracerMapsUpdater() containercollection.FuncNotify {
 	if tc.testOnly {
 		return func(event containercollection.PubSubEvent) {}
 	}
 
 	return func(event containercollection.PubSubEvent) {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+
 		switch event.Type {
 		case containercollection.EventTypeAddContainer:
 			// Skip the pause container, only if it is not a standalone
@@ -75,12 +186,12 @@ func (tc *TracerCollection) TracerMapsUpdater() containercollection.FuncNotify {
 				return
 			}
 
-			for _, t := range tc.tracers {
-				if containercollection.ContainerSelectorMatches(&t.containerSelector, event.Container) {
+			for _, f := range tc.filters {
+				if containercollection.ContainerSelectorMatches(&f.selector, event.Container) {
 					mntnsC := uint64(event.Container.Mntns)
 					one := uint32(1)
 					if mntnsC != 0 {
-						t.mntnsSetMap.Put(mntnsC, one)
+						f.mntnsSetMap.Put(mntnsC, one)
 					} else {
 						log.Errorf("new container with mntns=0")
 					}
@@ -88,10 +199,10 @@ func (tc *TracerCollection) TracerMapsUpdater() containercollection.FuncNotify {
 			}
 
 		case containercollection.EventTypeRemoveContainer:
-			for _, t := range tc.tracers {
-				if containercollection.ContainerSelectorMatches(&t.containerSelector, event.Container) {
+			for _, f := range tc.filters {
+				if containercollection.ContainerSelectorMatches(&f.selector, event.Container) {
 					mntnsC := uint64(event.Container.Mntns)
-					t.mntnsSetMap.Delete(mntnsC)
+					f.mntnsSetMap.Delete(mntnsC)
 				}
 			}
 		}
@@ -99,36 +210,32 @@ func (tc *TracerCollection) TracerMapsUpdater() containercollection.FuncNotify {
 }
 
 func (tc *TracerCollection) AddTracer(id string, containerSelector containercollection.ContainerSelector) error {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	if _, ok := tc.tracers[id]; ok {
 		return fmt.Errorf("tracer id %q: %w", id, os.ErrExist)
 	}
-	var mntnsSetMap *ebpf.Map
+	var filter *mntnsFilter
 	if !tc.testOnly {
-		mntnsSpec := &ebpf.MapSpec{
-			Name:       MountMapPrefix + id,
-			Type:       ebpf.Hash,
-			KeySize:    8,
-			ValueSize:  4,
-			MaxEntries: MaxContainersPerNode,
-		}
-		var err error
-		mntnsSetMap, err = ebpf.NewMap(mntnsSpec)
+		key, err := selectorKey(&containerSelector)
 		if err != nil {
-			return fmt.Errorf("creating mntnsset map: %w", err)
+			return fmt.Errorf("hashing container selector: %w", err)
 		}
-
-		tc.containerCollection.ContainerRangeWithSelector(&containerSelector, func(c *containercollection.Container) {
-			one := uint32(1)
-			mntnsC := uint64(c.Mntns)
-			if mntnsC != 0 {
-				mntnsSetMap.Put(mntnsC, one)
+		filter = tc.filters[key]
+		if filter == nil {
+			filter, err = tc.newFilter(key, containerSelector)
+			if err != nil {
+				return err
 			}
-		})
+			tc.filters[key] = filter
+		}
+		filter.refs++
 	}
 	tc.tracers[id] = tracer{
 		tracerID:          id,
 		containerSelector: containerSelector,
-		mntnsSetMap:       mntnsSetMap,
+		filter:            filter,
 		gadgetStream:      stream.NewGadgetStream(),
 	}
 	return nil
@@ -139,13 +246,20 @@ func (tc *TracerCollection) RemoveTracer(id string) error {
 		return fmt.Errorf("container id not set")
 	}
 
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	t, ok := tc.tracers[id]
 	if !ok {
 		return fmt.Errorf("unknown tracer %q", id)
 	}
 
-	if t.mntnsSetMap != nil {
-		t.mntnsSetMap.Close()
+	if t.filter != nil {
+		t.filter.refs--
+		if t.filter.refs == 0 {
+			t.filter.close()
+			delete(tc.filters, t.filter.key)
+		}
 	}
 
 	t.gadgetStream.Close()
@@ -155,6 +269,9 @@ func (tc *TracerCollection) RemoveTracer(id string) error {
 }
 
 func (tc *TracerCollection) Stream(id string) (*stream.GadgetStream, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	t, ok := tc.tracers[id]
 	if !ok {
 		return nil, fmt.Errorf("unknown tracer %q", id)
@@ -163,10 +280,25 @@ func (tc *TracerCollection) Stream(id string) (*stream.GadgetStream, error) {
 }
 
 func (tc *TracerCollection) TracerCount() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	return len(tc.tracers)
 }
 
+// FilterMapCount returns the number of mount namespace filter maps, shared by
+// the tracers with identical selectors
+func (tc *TracerCollection) FilterMapCount() int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	return len(tc.filters)
+}
+
 func (tc *TracerCollection) TracerDump() (out string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	for i, t := range tc.tracers {
 		out += fmt.Sprintf("%v -> %q/%q (%s) Labels: \n",
 			i,
@@ -176,6 +308,9 @@ func (tc *TracerCollection) TracerDump() (out string) {
 		for k, v := range t.containerSelector.K8s.PodLabels {
 			out += fmt.Sprintf("                  %v: %v\n", k, v)
 		}
+		if t.filter != nil && t.filter.pinned != "" {
+			out += fmt.Sprintf("        Filter map: %s (%d tracers)\n", t.filter.pinned, t.filter.refs)
+		}
 		out += "        Matches:\n"
 		tc.containerCollection.ContainerRangeWithSelector(&t.containerSelector, func(c *containercollection.Container) {
 			out += fmt.Sprintf("        - %s/%s [Mntns=%v CgroupID=%v]\n", c.K8s.Namespace, c.K8s.PodName, c.Mntns, c.CgroupID)
@@ -185,17 +320,29 @@ func (tc *TracerCollection) TracerDump() (out string) {
 }
 
 func (tc *TracerCollection) TracerExists(id string) bool {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	_, ok := tc.tracers[id]
 	return ok
 }
 
 func (tc *TracerCollection) Close() {}
 
+// TracerMountNsMap returns the mount namespace filter map of the tracer. It's
+// shared with the tracers with identical selectors, so it must not be
+// modified nor closed by the caller.
 func (tc *TracerCollection) TracerMountNsMap(id string) (*ebpf.Map, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
 	t, ok := tc.tracers[id]
 	if !ok {
 		return nil, fmt.Errorf("unknown tracer %q", id)
 	}
+	if t.filter == nil {
+		return nil, nil
+	}
 
-	return t.mntnsSetMap, nil
+	return t.filter.mntnsSetMap, nil
 }
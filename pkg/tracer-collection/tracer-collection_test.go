@@ -0,0 +1,101 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracercollection
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	utilstest "github.com/inspektor-gadget/inspektor-gadget/internal/test"
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func selector(namespace string) containercollection.ContainerSelector {
+	return containercollection.ContainerSelector{
+		K8s: containercollection.K8sSelector{
+			BasicK8sMetadata: types.BasicK8sMetadata{Namespace: namespace},
+		},
+	}
+}
+
+func TestSharedFilterMaps(t *testing.T) {
+	utilstest.RequireRoot(t)
+
+	pinPath, err := os.MkdirTemp("/sys/fs/bpf", "tracer-collection-test-")
+	if err != nil {
+		t.Skipf("bpffs not available: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(pinPath) })
+
+	// Stale maps of a previous instance are removed
+	require.NoError(t, os.Mkdir(filepath.Join(pinPath, "stale"), 0o700))
+
+	cc := &containercollection.ContainerCollection{}
+	tc, err := NewTracerCollection(cc, WithPinPath(pinPath))
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(pinPath, "stale"))
+
+	require.NoError(t, tc.AddTracer("a", selector("default")))
+	require.NoError(t, tc.AddTracer("b", selector("default")))
+	require.NoError(t, tc.AddTracer("c", selector("kube-system")))
+	assert.Equal(t, 2, tc.FilterMapCount())
+
+	mapA, err := tc.TracerMountNsMap("a")
+	require.NoError(t, err)
+	mapB, err := tc.TracerMountNsMap("b")
+	require.NoError(t, err)
+	mapC, err := tc.TracerMountNsMap("c")
+	require.NoError(t, err)
+	assert.Same(t, mapA, mapB)
+	assert.NotSame(t, mapA, mapC)
+
+	pinned, err := os.ReadDir(pinPath)
+	require.NoError(t, err)
+	assert.Len(t, pinned, 2)
+
+	tc.TracerMapsUpdater()(containercollection.PubSubEvent{
+		Type: containercollection.EventTypeAddContainer,
+		Container: &containercollection.Container{
+			Runtime: containercollection.RuntimeMetadata{
+				BasicRuntimeMetadata: types.BasicRuntimeMetadata{ContainerID: "abc", ContainerName: "nginx"},
+			},
+			K8s: containercollection.K8sMetadata{
+				BasicK8sMetadata: types.BasicK8sMetadata{Namespace: "default", ContainerName: "nginx"},
+			},
+			Mntns: 4026532000,
+		},
+	})
+	var one uint32
+	assert.NoError(t, mapA.Lookup(uint64(4026532000), &one))
+	assert.Error(t, mapC.Lookup(uint64(4026532000), &one))
+
+	// The map is kept until the last tracer using it is removed
+	require.NoError(t, tc.RemoveTracer("a"))
+	assert.Equal(t, 2, tc.FilterMapCount())
+	assert.NoError(t, mapB.Lookup(uint64(4026532000), &one))
+
+	require.NoError(t, tc.RemoveTracer("b"))
+	require.NoError(t, tc.RemoveTracer("c"))
+	assert.Equal(t, 0, tc.FilterMapCount())
+
+	pinned, err = os.ReadDir(pinPath)
+	require.NoError(t, err)
+	assert.Empty(t, pinned)
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+)
+
+// SpanKind tells whether the operation of a span was done by a client or by a
+// server
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindClient
+	SpanKindServer
+)
+
+// Span describes an operation observed by a gadget, like a DNS lookup or a
+// TCP connection, to export it as an OpenTelemetry span
+type Span struct {
+	Name  string
+	Kind  SpanKind
+	Start time.Time
+	End   time.Time
+
+	// Error is set when the operation failed
+	Error string
+
+	// Attributes can hold strings, integers, booleans and slices of strings
+	Attributes map[string]any
+}
+
+// SpanEvent is implemented by the events describing an operation that can be
+// exported as a span
+type SpanEvent interface {
+	// OTelSpan returns the span of the event, or nil if the event doesn't
+	// end an operation, like a DNS query waiting for its response
+	OTelSpan() *Span
+}
+
+// AddSpanAttributes adds the Kubernetes and container metadata to the
+// attributes of a span, using the names of the OpenTelemetry semantic
+// conventions
+func (c *CommonData) AddSpanAttributes(attrs map[string]any) {
+	for k, v := range map[string]string{
+		"k8s.node.name":      c.K8s.Node,
+		"k8s.namespace.name": c.K8s.Namespace,
+		"k8s.pod.name":       c.K8s.PodName,
+		"k8s.container.name": c.K8s.ContainerName,
+		"container.id":       c.Runtime.ContainerID,
+		"container.name":     c.Runtime.ContainerName,
+		"container.runtime":  c.Runtime.RuntimeName.String(),
+	} {
+		if v != "" {
+			attrs[k] = v
+		}
+	}
+}
@@ -115,6 +115,18 @@ type Container interface {
 	ContainerPid() uint32
 }
 
+// UserNsResolver resolves user namespaces and the IDs of the processes
+// running in them using the known containers
+type UserNsResolver interface {
+	// UserNsOwner returns a description of the containers running in the user
+	// namespace, "host" for the user namespace of the host, or an empty
+	// string if the user namespace isn't known.
+	UserNsOwner(usernsid uint64) string
+	// ContainerIDs returns the IDs in the user namespace of the container
+	// running in the mount namespace given the uid and gid of the host.
+	ContainerIDs(mountnsid uint64, uid, gid uint32) (uint32, uint32)
+}
+
 type BasicRuntimeMetadata struct {
 	// RuntimeName is the name of the container runtime. It is useful to distinguish
 	// who is the "owner" of each container in a list of containers collected
@@ -148,6 +160,12 @@ type BasicRuntimeMetadata struct {
 
 	// ContainerStartedAt is the unix timestamp at which the container was started at
 	ContainerStartedAt Time `json:"containerStartedAt,omitempty" column:"containerStartedAt,template:timestamp,stringer,hide"`
+
+	// ContainerParents are the names of the containers this container is
+	// nested in, like a kind node or a Docker-in-Docker builder, from the
+	// outermost to the innermost one and separated by "/". It's empty for
+	// the containers that aren't nested.
+	ContainerParents string `json:"containerParents,omitempty" column:"containerParents,hide"`
 }
 
 func (b *BasicRuntimeMetadata) IsEnriched() bool {
@@ -0,0 +1,208 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uprobetracer
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"debug/gosym"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// The types and constants below must be kept in sync with
+// include/gadget/golang.h
+const goMaxOffsets = 16
+
+// GoOffsetsMapName is the name of the map storing the offsets of the struct
+// fields the programs attached to Go binaries need. Programs use it (through
+// the helpers in gadget/golang.h) to read these fields, the offsets being
+// selected by the attach cookie.
+const GoOffsetsMapName = "gadget_go_offsets"
+
+type goOffsetsValue struct {
+	Offsets [goMaxOffsets]int64
+}
+
+// GoOffsets stores the offsets of struct fields in Go binaries in the
+// gadget_go_offsets map. The offsets are read from the DWARF information of
+// each binary, since they depend on the version of the package the struct
+// belongs to. It's shared by all tracers of a gadget, since they share the
+// map.
+type GoOffsets struct {
+	offsetsMap *ebpf.Map
+	// fields are the fields to look for, as "<package path>.<type>.<field>",
+	// e.g. "google.golang.org/grpc/internal/transport.Stream.method"
+	fields []string
+
+	mu  sync.Mutex
+	ids map[goOffsetsValue]uint32
+}
+
+func NewGoOffsets(offsetsMap *ebpf.Map, fields []string) (*GoOffsets, error) {
+	if len(fields) > goMaxOffsets {
+		return nil, fmt.Errorf("too many Go struct fields (max %d)", goMaxOffsets)
+	}
+	for _, field := range fields {
+		if _, _, err := splitGoField(field); err != nil {
+			return nil, err
+		}
+	}
+	return &GoOffsets{
+		offsetsMap: offsetsMap,
+		fields:     fields,
+		ids:        make(map[goOffsetsValue]uint32),
+	}, nil
+}
+
+// splitGoField splits "<package path>.<type>.<field>" into the type name (as
+// found in the DWARF information) and the field name
+func splitGoField(field string) (string, string, error) {
+	idx := strings.LastIndex(field, ".")
+	if idx <= 0 || !strings.Contains(field[strings.LastIndex(field[:idx], "/")+1:idx], ".") {
+		return "", "", fmt.Errorf("invalid Go struct field %q: expected <package path>.<type>.<field>", field)
+	}
+	return field[:idx], field[idx+1:], nil
+}
+
+// add stores the offsets of the fields in the given binary and returns their
+// id. Binaries with the same offsets share the same id.
+func (g *GoOffsets) add(f *elf.File) (uint32, error) {
+	offsets, err := goStructOffsets(f, g.fields)
+	if err != nil {
+		return 0, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if id, ok := g.ids[offsets]; ok {
+		return id, nil
+	}
+
+	id := uint32(len(g.ids))
+	if id >= g.offsetsMap.MaxEntries() {
+		return 0, fmt.Errorf("too many Go binaries with different offsets (max %d)", g.offsetsMap.MaxEntries())
+	}
+	if err := g.offsetsMap.Put(id, offsets); err != nil {
+		return 0, fmt.Errorf("storing Go offsets: %w", err)
+	}
+	g.ids[offsets] = id
+	return id, nil
+}
+
+// goStructOffsets returns the offsets of the given struct fields, -1 for the
+// fields that can't be found (e.g. because they don't exist in the version of
+// the package used by this binary)
+func goStructOffsets(f *elf.File, fields []string) (goOffsetsValue, error) {
+	var res goOffsetsValue
+	for i := range res.Offsets {
+		res.Offsets[i] = -1
+	}
+
+	wanted := make(map[string]map[string]int, len(fields))
+	for i, field := range fields {
+		typ, member, err := splitGoField(field)
+		if err != nil {
+			return res, err
+		}
+		if wanted[typ] == nil {
+			wanted[typ] = make(map[string]int)
+		}
+		wanted[typ][member] = i
+	}
+
+	d, err := f.DWARF()
+	if err != nil {
+		return res, fmt.Errorf("reading DWARF information (was the binary built with -ldflags=-w?): %w", err)
+	}
+
+	r := d.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return res, fmt.Errorf("reading DWARF entry: %w", err)
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag != dwarf.TagStructType {
+			if entry.Children && entry.Tag != dwarf.TagCompileUnit {
+				r.SkipChildren()
+			}
+			continue
+		}
+
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		members, ok := wanted[name]
+		if !ok || !entry.Children {
+			if entry.Children {
+				r.SkipChildren()
+			}
+			continue
+		}
+
+		for {
+			child, err := r.Next()
+			if err != nil {
+				return res, fmt.Errorf("reading DWARF entry: %w", err)
+			}
+			if child == nil || child.Tag == 0 {
+				break
+			}
+			memberName, _ := child.Val(dwarf.AttrName).(string)
+			idx, ok := members[memberName]
+			if child.Tag != dwarf.TagMember || !ok {
+				continue
+			}
+			if off, ok := child.Val(dwarf.AttrDataMemberLoc).(int64); ok {
+				res.Offsets[idx] = off
+			}
+		}
+	}
+	return res, nil
+}
+
+// goSymbolOffset returns the offset in the file of a function of a Go binary,
+// using the Go symbol table (.gopclntab). It's used for binaries stripped of
+// their ELF symbol table (built with -ldflags=-s).
+func goSymbolOffset(f *elf.File, symbol string) (uint64, error) {
+	pclntab := f.Section(".gopclntab")
+	if pclntab == nil {
+		return 0, errors.New("not a Go binary: .gopclntab section not found")
+	}
+	text := f.Section(".text")
+	if text == nil {
+		return 0, errors.New(".text section not found")
+	}
+
+	data, err := pclntab.Data()
+	if err != nil {
+		return 0, fmt.Errorf("reading .gopclntab: %w", err)
+	}
+	table, err := gosym.NewTable(nil, gosym.NewLineTable(data, text.Addr))
+	if err != nil {
+		return 0, fmt.Errorf("parsing .gopclntab: %w", err)
+	}
+	fn := table.LookupFunc(symbol)
+	if fn == nil {
+		return 0, fmt.Errorf("symbol %q not found in .gopclntab", symbol)
+	}
+	return vaddr2ElfOffset(f, fn.Entry)
+}
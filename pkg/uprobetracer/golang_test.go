@@ -0,0 +1,98 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uprobetracer
+
+import (
+	"debug/elf"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildGoProg builds testdata/goprog with the given extra linker flags
+func buildGoProg(t *testing.T, ldflags string) *elf.File {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	out := filepath.Join(t.TempDir(), "goprog")
+	cmd := exec.Command(goBin, "build", "-o", out, "-ldflags", ldflags, "./testdata/goprog")
+	cmd.Env = append(cmd.Environ(), "CGO_ENABLED=0", "GOFLAGS=")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "building test program: %s", output)
+
+	f, err := elf.Open(out)
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestGoStructOffsets(t *testing.T) {
+	t.Parallel()
+
+	f := buildGoProg(t, "")
+
+	offsets, err := goStructOffsets(f, []string{
+		"main.request.method",
+		"main.request.code",
+		"main.request.doesNotExist",
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, offsets.Offsets[0])
+	assert.EqualValues(t, 24, offsets.Offsets[1])
+	assert.EqualValues(t, -1, offsets.Offsets[2])
+	assert.EqualValues(t, -1, offsets.Offsets[3])
+
+	_, err = goStructOffsets(f, []string{"request"})
+	require.Error(t, err)
+
+	// Without DWARF information
+	f = buildGoProg(t, "-w")
+	_, err = goStructOffsets(f, []string{"main.request.method"})
+	require.Error(t, err)
+}
+
+func TestGoSymbolOffset(t *testing.T) {
+	t.Parallel()
+
+	f := buildGoProg(t, "")
+
+	symbols, err := f.Symbols()
+	require.NoError(t, err)
+	var expected uint64
+	for _, s := range symbols {
+		if s.Name == "main.handle" {
+			expected, err = vaddr2ElfOffset(f, s.Value)
+			require.NoError(t, err)
+		}
+	}
+	require.NotZero(t, expected, "main.handle not found in ELF symbol table")
+
+	// The offset must be found even if the binary is stripped
+	f = buildGoProg(t, "-s -w")
+	_, err = f.Symbols()
+	require.ErrorIs(t, err, elf.ErrNoSymbols)
+
+	offset, err := goSymbolOffset(f, "main.handle")
+	require.NoError(t, err)
+	assert.Equal(t, expected, offset)
+
+	_, err = goSymbolOffset(f, "main.doesNotExist")
+	require.Error(t, err)
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This program is built by the tests to check the lookup of symbols and struct
+// offsets in Go binaries
+package main
+
+import "fmt"
+
+type request struct {
+	id     uint64
+	method string
+	code   int32
+}
+
+//go:noinline
+func handle(r *request) int32 {
+	return r.code
+}
+
+func main() {
+	fmt.Println(handle(&request{id: 1, method: "/test", code: 0}))
+}
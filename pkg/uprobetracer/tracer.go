@@ -32,11 +32,13 @@
 package uprobetracer
 
 import (
+	"debug/elf"
 	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -78,7 +80,16 @@ type Tracer[Event any] struct {
 	progType       ProgType
 	attachFilePath string
 	attachSymbol   string
-	prog           *ebpf.Program
+	// attachAddress is set when the program is attached to an offset in the
+	// file (e.g. "/app/server:0x4a2f0") rather than to a symbol
+	attachAddress uint64
+	prog          *ebpf.Program
+	// usdtArgSpecs is used to make the arguments of USDT probes available to
+	// the program
+	usdtArgSpecs *UsdtArgSpecs
+	// goOffsets is used to make the offsets of Go struct fields available to
+	// the program
+	goOffsets *GoOffsets
 
 	// keeps the inodes for each attached container
 	// when users write library names in ebpf section names, it's possible to
@@ -137,11 +148,27 @@ func (t *Tracer[Event]) AttachProg(progName string, progType ProgType, attachTo
 	if len(parts) < 2 {
 		return fmt.Errorf("invalid section name %q", attachTo)
 	}
+	if progType == ProgUSDT {
+		switch len(strings.Split(attachTo, ":")) {
+		case 2:
+			// "provider:probe": the probe is looked up in the main executable
+			// of the container
+			parts = []string{"", attachTo}
+		case 3:
+		default:
+			return fmt.Errorf("invalid USDT section name: %q", attachTo)
+		}
+	}
 	if !filepath.IsAbs(parts[0]) && strings.Contains(parts[0], "/") {
 		return fmt.Errorf("section name must be either an absolute path or a library name: %q", parts[0])
 	}
-	if progType == ProgUSDT && len(strings.Split(parts[1], ":")) != 2 {
-		return fmt.Errorf("invalid USDT section name: %q", attachTo)
+	var attachAddress uint64
+	if progType != ProgUSDT && strings.HasPrefix(parts[1], "0x") {
+		var err error
+		attachAddress, err = strconv.ParseUint(parts[1][2:], 16, 64)
+		if err != nil || attachAddress == 0 {
+			return fmt.Errorf("invalid offset %q in %q", parts[1], attachTo)
+		}
 	}
 
 	t.mu.Lock()
@@ -155,6 +182,7 @@ func (t *Tracer[Event]) AttachProg(progName string, progType ProgType, attachTo
 	t.progType = progType
 	t.attachFilePath = parts[0]
 	t.attachSymbol = parts[1]
+	t.attachAddress = attachAddress
 	t.prog = prog
 
 	// attach to pending containers, then release the pending list
@@ -166,8 +194,37 @@ func (t *Tracer[Event]) AttachProg(progName string, progType ProgType, attachTo
 	return nil
 }
 
+// SetUsdtArgSpecs sets where the argument specs of USDT probes are stored.
+// It must be called before AttachProg. If it's not called, the arguments of
+// the probes aren't available to the program.
+func (t *Tracer[Event]) SetUsdtArgSpecs(specs *UsdtArgSpecs) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.usdtArgSpecs = specs
+}
+
+// SetGoOffsets sets where the offsets of the Go struct fields used by the
+// program are stored. It must be called before AttachProg.
+func (t *Tracer[Event]) SetGoOffsets(goOffsets *GoOffsets) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.goOffsets = goOffsets
+}
+
 func (t *Tracer[Event]) searchForLibrary(containerPid uint32) ([]string, error) {
 	filePath := t.attachFilePath
+	if filePath == "" {
+		// Use the executable of the container's init process. The link is
+		// resolved in its mount namespace, so it can be opened in the
+		// container like any other path.
+		exe, err := os.Readlink(filepath.Join(host.HostProcFs, fmt.Sprint(containerPid), "exe"))
+		if err != nil {
+			return nil, fmt.Errorf("reading executable of container: %w", err)
+		}
+		return []string{exe}, nil
+	}
 	if filepath.IsAbs(filePath) {
 		return []string{filePath}, nil
 	}
@@ -187,26 +244,85 @@ func (t *Tracer[Event]) attachUprobe(file *os.File) (link.Link, error) {
 	if err != nil {
 		return nil, fmt.Errorf("opening %q: %w", attachPath, err)
 	}
+	var opts *link.UprobeOptions
+	if t.attachAddress != 0 {
+		opts = &link.UprobeOptions{Address: t.attachAddress}
+	}
 	switch t.progType {
-	case ProgUprobe:
-		return ex.Uprobe(t.attachSymbol, t.prog, nil)
-	case ProgUretprobe:
-		return ex.Uretprobe(t.attachSymbol, t.prog, nil)
+	case ProgUprobe, ProgUretprobe:
+		attach := ex.Uprobe
+		if t.progType == ProgUretprobe {
+			attach = ex.Uretprobe
+		}
+		if t.goOffsets != nil {
+			opts, err = t.addGoOffsets(attachPath, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		l, err := attach(t.attachSymbol, t.prog, opts)
+		if errors.Is(err, link.ErrNoSymbol) && t.attachAddress == 0 {
+			// Go binaries are often stripped of their ELF symbol table but
+			// still have the Go one
+			f, goErr := elf.Open(attachPath)
+			if goErr != nil {
+				return nil, err
+			}
+			defer f.Close()
+			address, goErr := goSymbolOffset(f, t.attachSymbol)
+			if goErr != nil {
+				t.logger.Debugf("looking up %q in Go symbol table: %s", t.attachSymbol, goErr)
+				return nil, err
+			}
+			if opts == nil {
+				opts = &link.UprobeOptions{}
+			}
+			opts.Address = address
+			return attach(t.attachSymbol, t.prog, opts)
+		}
+		return l, err
 	case ProgUSDT:
 		attachInfo, err := getUsdtInfo(attachPath, t.attachSymbol)
 		if err != nil {
 			return nil, fmt.Errorf("reading USDT metadata: %w", err)
 		}
-		return ex.Uprobe(t.attachSymbol, t.prog,
-			&link.UprobeOptions{
-				Address:      attachInfo.attachAddress,
-				RefCtrOffset: attachInfo.semaphoreAddress,
-			})
+		opts = &link.UprobeOptions{
+			Address:      attachInfo.attachAddress,
+			RefCtrOffset: attachInfo.semaphoreAddress,
+		}
+		if t.usdtArgSpecs != nil {
+			specID, err := t.usdtArgSpecs.add(attachInfo.args)
+			if err != nil {
+				return nil, fmt.Errorf("reading USDT arguments: %w", err)
+			}
+			opts.Cookie = uint64(specID)
+		}
+		return ex.Uprobe(t.attachSymbol, t.prog, opts)
 	default:
 		return nil, fmt.Errorf("attaching to inode: unsupported prog type: %q", t.progType)
 	}
 }
 
+// addGoOffsets stores the offsets of the Go struct fields used by the program
+// for the given binary and sets the cookie accordingly
+func (t *Tracer[Event]) addGoOffsets(attachPath string, opts *link.UprobeOptions) (*link.UprobeOptions, error) {
+	f, err := elf.Open(attachPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening ELF file: %w", err)
+	}
+	defer f.Close()
+
+	id, err := t.goOffsets.add(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading Go struct offsets: %w", err)
+	}
+	if opts == nil {
+		opts = &link.UprobeOptions{}
+	}
+	opts.Cookie = uint64(id)
+	return opts, nil
+}
+
 // try attaching to a container, will update `containerPid2Inodes`
 func (t *Tracer[Event]) attach(containerPid uint32) {
 	var attachedRealInodes []uint64
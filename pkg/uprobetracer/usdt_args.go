@@ -0,0 +1,310 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uprobetracer
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+)
+
+// The types and constants below must be kept in sync with
+// include/gadget/usdt_argument.h
+const (
+	usdtMaxArgs = 12
+
+	usdtArgConst    uint32 = 0
+	usdtArgReg      uint32 = 1
+	usdtArgRegDeref uint32 = 2
+)
+
+// UsdtArgSpecsMapName is the name of the map storing the argument specs of USDT
+// probes. Programs use it (through the helpers in gadget/usdt_argument.h) to
+// read the probe arguments, the spec being selected by the attach cookie.
+const UsdtArgSpecsMapName = "gadget_usdt_specs"
+
+type usdtArgSpec struct {
+	ValOff      uint64
+	ArgType     uint32
+	RegOff      int16
+	ArgSigned   bool
+	ArgBitshift int8
+}
+
+type usdtSpec struct {
+	Args   [usdtMaxArgs]usdtArgSpec
+	ArgCnt int16
+	_      [6]byte
+}
+
+// UsdtArgSpecs stores the argument specs of attached USDT probes in the
+// gadget_usdt_specs map. It's shared by all tracers of a gadget, since they
+// share the map.
+type UsdtArgSpecs struct {
+	specsMap *ebpf.Map
+
+	mu  sync.Mutex
+	ids map[string]uint32
+}
+
+func NewUsdtArgSpecs(specsMap *ebpf.Map) *UsdtArgSpecs {
+	return &UsdtArgSpecs{
+		specsMap: specsMap,
+		ids:      make(map[string]uint32),
+	}
+}
+
+// add stores the spec of the given arguments description (as found in the
+// USDT note) and returns its id. Probes with the same description share the
+// same id.
+func (s *UsdtArgSpecs) add(args string) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.ids[args]; ok {
+		return id, nil
+	}
+
+	spec, err := parseUsdtArgs(runtime.GOARCH, args)
+	if err != nil {
+		return 0, err
+	}
+
+	id := uint32(len(s.ids))
+	if id >= s.specsMap.MaxEntries() {
+		return 0, fmt.Errorf("too many USDT argument specs (max %d)", s.specsMap.MaxEntries())
+	}
+	if err := s.specsMap.Put(id, spec); err != nil {
+		return 0, fmt.Errorf("storing USDT argument spec: %w", err)
+	}
+	s.ids[args] = id
+	return id, nil
+}
+
+// parseUsdtArgs parses the arguments description of a USDT probe, e.g.
+// "-4@%edi 8@-16(%rbp) 8@$5" on amd64 or "-4@x0 8@[sp, 16] 8@5" on arm64.
+func parseUsdtArgs(arch string, args string) (*usdtSpec, error) {
+	spec := &usdtSpec{}
+	for _, arg := range splitUsdtArgs(args) {
+		if int(spec.ArgCnt) >= usdtMaxArgs {
+			return nil, fmt.Errorf("too many USDT arguments in %q (max %d)", args, usdtMaxArgs)
+		}
+		sizeStr, loc, ok := strings.Cut(arg, "@")
+		if !ok {
+			return nil, fmt.Errorf("invalid USDT argument %q", arg)
+		}
+		size, err := strconv.Atoi(sizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size of USDT argument %q", arg)
+		}
+
+		argSpec := &spec.Args[spec.ArgCnt]
+		if size < 0 {
+			argSpec.ArgSigned = true
+			size = -size
+		}
+		switch size {
+		case 1, 2, 4, 8:
+		default:
+			return nil, fmt.Errorf("invalid size %d of USDT argument %q", size, arg)
+		}
+		argSpec.ArgBitshift = int8(64 - size*8)
+
+		switch arch {
+		case "amd64":
+			err = parseUsdtArgAmd64(loc, argSpec)
+		case "arm64":
+			err = parseUsdtArgArm64(loc, argSpec)
+		default:
+			err = fmt.Errorf("USDT arguments are not supported on %s", arch)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing USDT argument %q: %w", arg, err)
+		}
+		spec.ArgCnt++
+	}
+	return spec, nil
+}
+
+// splitUsdtArgs splits the arguments description on spaces, except the ones
+// within brackets (arm64 uses "[sp, 16]")
+func splitUsdtArgs(args string) []string {
+	var res []string
+	depth := 0
+	start := -1
+	for i, c := range args {
+		switch {
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ' ' && depth == 0:
+			if start >= 0 {
+				res = append(res, args[start:i])
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		res = append(res, args[start:])
+	}
+	return res
+}
+
+func parseOffset(s string) (uint64, error) {
+	v, err := strconv.ParseInt(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	return uint64(v), nil
+}
+
+// Offsets of the registers in struct pt_regs on amd64
+var amd64Regs = map[string]int16{
+	"r15": 0, "r14": 8, "r13": 16, "r12": 24, "bp": 32, "bx": 40, "r11": 48,
+	"r10": 56, "r9": 64, "r8": 72, "ax": 80, "cx": 88, "dx": 96, "si": 104,
+	"di": 112, "ip": 128, "sp": 152,
+}
+
+// amd64RegOffset returns the offset of the given register (e.g. "rax", "eax",
+// "ax", "al", "r8d") in struct pt_regs
+func amd64RegOffset(reg string) (int16, error) {
+	name := reg
+	if strings.HasPrefix(name, "r") && len(name) > 1 && name[1] >= '0' && name[1] <= '9' {
+		name = strings.TrimRight(name, "dwb")
+	} else {
+		name = strings.TrimPrefix(name, "r")
+		name = strings.TrimPrefix(name, "e")
+		switch name {
+		case "al", "bl", "cl", "dl":
+			name = name[:1] + "x"
+		case "sil", "dil", "bpl", "spl":
+			name = name[:2]
+		}
+	}
+	off, ok := amd64Regs[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported register %q", reg)
+	}
+	return off, nil
+}
+
+func parseUsdtArgAmd64(loc string, spec *usdtArgSpec) error {
+	switch {
+	case strings.HasPrefix(loc, "$"):
+		// Constant: "$5"
+		val, err := parseOffset(loc[1:])
+		if err != nil {
+			return err
+		}
+		spec.ArgType = usdtArgConst
+		spec.ValOff = val
+	case strings.HasPrefix(loc, "%"):
+		// Register: "%rdi"
+		off, err := amd64RegOffset(loc[1:])
+		if err != nil {
+			return err
+		}
+		spec.ArgType = usdtArgReg
+		spec.RegOff = off
+	case strings.HasSuffix(loc, ")"):
+		// Memory: "-16(%rbp)" or "(%rax)"
+		offStr, reg, ok := strings.Cut(strings.TrimSuffix(loc, ")"), "(%")
+		if !ok {
+			return fmt.Errorf("invalid location %q", loc)
+		}
+		var val uint64
+		if offStr != "" {
+			var err error
+			if val, err = parseOffset(offStr); err != nil {
+				return err
+			}
+		}
+		off, err := amd64RegOffset(reg)
+		if err != nil {
+			return err
+		}
+		spec.ArgType = usdtArgRegDeref
+		spec.RegOff = off
+		spec.ValOff = val
+	default:
+		return fmt.Errorf("invalid location %q", loc)
+	}
+	return nil
+}
+
+// arm64RegOffset returns the offset of the given register (e.g. "x0", "sp") in
+// struct pt_regs
+func arm64RegOffset(reg string) (int16, error) {
+	if reg == "sp" {
+		return 31 * 8, nil
+	}
+	if strings.HasPrefix(reg, "x") {
+		n, err := strconv.Atoi(reg[1:])
+		if err == nil && n >= 0 && n <= 30 {
+			return int16(n * 8), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported register %q", reg)
+}
+
+func parseUsdtArgArm64(loc string, spec *usdtArgSpec) error {
+	switch {
+	case strings.HasPrefix(loc, "[") && strings.HasSuffix(loc, "]"):
+		// Memory: "[sp, 16]" or "[x0]"
+		reg, offStr, hasOff := strings.Cut(loc[1:len(loc)-1], ",")
+		off, err := arm64RegOffset(strings.TrimSpace(reg))
+		if err != nil {
+			return err
+		}
+		var val uint64
+		if hasOff {
+			if val, err = parseOffset(strings.TrimSpace(offStr)); err != nil {
+				return err
+			}
+		}
+		spec.ArgType = usdtArgRegDeref
+		spec.RegOff = off
+		spec.ValOff = val
+	case loc == "":
+		return errors.New("empty location")
+	case loc[0] == '-' || (loc[0] >= '0' && loc[0] <= '9'):
+		// Constant: "5"
+		val, err := parseOffset(loc)
+		if err != nil {
+			return err
+		}
+		spec.ArgType = usdtArgConst
+		spec.ValOff = val
+	default:
+		// Register: "x0"
+		off, err := arm64RegOffset(loc)
+		if err != nil {
+			return err
+		}
+		spec.ArgType = usdtArgReg
+		spec.RegOff = off
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uprobetracer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUsdtArgs(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		arch        string
+		args        string
+		expected    []usdtArgSpec
+		expectedErr bool
+	}
+
+	tests := map[string]testCase{
+		"no_args": {
+			arch: "amd64",
+			args: "",
+		},
+		"amd64": {
+			arch: "amd64",
+			args: "-4@%edi 8@-16(%rbp) 8@$5 1@%sil 2@(%r8)",
+			expected: []usdtArgSpec{
+				{ArgType: usdtArgReg, RegOff: 112, ArgSigned: true, ArgBitshift: 32},
+				{ArgType: usdtArgRegDeref, RegOff: 32, ValOff: uint64(0xfffffffffffffff0), ArgBitshift: 0},
+				{ArgType: usdtArgConst, ValOff: 5, ArgBitshift: 0},
+				{ArgType: usdtArgReg, RegOff: 104, ArgBitshift: 56},
+				{ArgType: usdtArgRegDeref, RegOff: 72, ArgBitshift: 48},
+			},
+		},
+		"arm64": {
+			arch: "arm64",
+			args: "-4@x0 8@[sp, 16] 8@[x19] 4@5",
+			expected: []usdtArgSpec{
+				{ArgType: usdtArgReg, RegOff: 0, ArgSigned: true, ArgBitshift: 32},
+				{ArgType: usdtArgRegDeref, RegOff: 248, ValOff: 16, ArgBitshift: 0},
+				{ArgType: usdtArgRegDeref, RegOff: 152, ArgBitshift: 0},
+				{ArgType: usdtArgConst, ValOff: 5, ArgBitshift: 32},
+			},
+		},
+		"invalid_size": {
+			arch:        "amd64",
+			args:        "3@%edi",
+			expectedErr: true,
+		},
+		"invalid_register": {
+			arch:        "amd64",
+			args:        "8@%xmm0",
+			expectedErr: true,
+		},
+		"rip_relative": {
+			arch:        "amd64",
+			args:        "8@counter(%rip)",
+			expectedErr: true,
+		},
+		"unsupported_arch": {
+			arch:        "riscv64",
+			args:        "8@a0",
+			expectedErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			spec, err := parseUsdtArgs(test.arch, test.args)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, len(test.expected), int(spec.ArgCnt))
+			for i, expected := range test.expected {
+				assert.Equal(t, expected, spec.Args[i], "argument %d", i)
+			}
+		})
+	}
+}
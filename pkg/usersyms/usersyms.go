@@ -0,0 +1,269 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package usersyms resolves the instruction pointers of user space stacks to
+// the symbols of the ELF files mapped by the processes.
+package usersyms
+
+import (
+	"bufio"
+	"debug/elf"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+// Unknown is returned for the addresses that couldn't be resolved
+const Unknown = "[unknown]"
+
+// maxCachedFiles is the number of ELF files whose symbols are kept in memory
+const maxCachedFiles = 64
+
+// mapping is an executable mapping of /proc/<pid>/maps
+type mapping struct {
+	start  uint64
+	end    uint64
+	offset uint64
+	path   string
+}
+
+type symbol struct {
+	addr uint64
+	size uint64
+	name string
+}
+
+// elfSymbols are the function symbols of an ELF file, sorted by address
+type elfSymbols struct {
+	loads   []elf.ProgHeader
+	symbols []symbol
+}
+
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// Resolver resolves user space addresses. It caches the symbols of the ELF
+// files, so it should be shared by all the stacks to resolve. It's safe for
+// concurrent use.
+type Resolver struct {
+	procPath string
+
+	mu    sync.Mutex
+	files map[fileKey]*elfSymbols
+}
+
+// NewResolver returns a Resolver looking for the processes in the proc
+// filesystem of the host.
+func NewResolver() *Resolver {
+	return newResolver(host.HostProcFs)
+}
+
+func newResolver(procPath string) *Resolver {
+	return &Resolver{
+		procPath: procPath,
+		files:    make(map[fileKey]*elfSymbols),
+	}
+}
+
+// Resolve returns the symbols of the addresses in the address space of the
+// process pid. The process must still be running.
+func (r *Resolver) Resolve(pid uint32, addrs []uint64) []string {
+	ret := make([]string, len(addrs))
+	for i := range ret {
+		ret[i] = Unknown
+	}
+
+	mappings, err := r.readMappings(pid)
+	if err != nil {
+		return ret
+	}
+
+	for i, addr := range addrs {
+		m := findMapping(mappings, addr)
+		if m == nil {
+			continue
+		}
+		fileOffset := addr - m.start + m.offset
+
+		syms, err := r.fileSymbols(pid, m.path)
+		if err != nil {
+			ret[i] = fmt.Sprintf("%s+0x%x", filepath.Base(m.path), fileOffset)
+			continue
+		}
+		name, ok := syms.lookup(fileOffset)
+		if !ok {
+			ret[i] = fmt.Sprintf("%s+0x%x", filepath.Base(m.path), fileOffset)
+			continue
+		}
+		ret[i] = name
+	}
+
+	return ret
+}
+
+func (r *Resolver) readMappings(pid uint32) ([]mapping, error) {
+	f, err := os.Open(filepath.Join(r.procPath, strconv.FormatUint(uint64(pid), 10), "maps"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseMappings(f)
+}
+
+// parseMappings returns the executable mappings backed by a file
+func parseMappings(reader io.Reader) ([]mapping, error) {
+	var mappings []mapping
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		// 55d0a7a0e000-55d0a7a2d000 r-xp 00004000 fd:01 1835024   /usr/bin/cat
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 || !strings.Contains(fields[1], "x") || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], "-")
+		if !ok {
+			continue
+		}
+		m := mapping{path: strings.Join(fields[5:], " ")}
+		var err error
+		if m.start, err = strconv.ParseUint(start, 16, 64); err != nil {
+			continue
+		}
+		if m.end, err = strconv.ParseUint(end, 16, 64); err != nil {
+			continue
+		}
+		if m.offset, err = strconv.ParseUint(fields[2], 16, 64); err != nil {
+			continue
+		}
+		mappings = append(mappings, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func findMapping(mappings []mapping, addr uint64) *mapping {
+	for i := range mappings {
+		if addr >= mappings[i].start && addr < mappings[i].end {
+			return &mappings[i]
+		}
+	}
+	return nil
+}
+
+// fileSymbols returns the symbols of the file at path in the mount namespace
+// of the process pid
+func (r *Resolver) fileSymbols(pid uint32, path string) (*elfSymbols, error) {
+	fullPath := filepath.Join(r.procPath, strconv.FormatUint(uint64(pid), 10), "root", path)
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(fullPath, &st); err != nil {
+		return nil, err
+	}
+	key := fileKey{dev: uint64(st.Dev), ino: st.Ino}
+
+	r.mu.Lock()
+	syms, ok := r.files[key]
+	r.mu.Unlock()
+	if ok {
+		return syms, nil
+	}
+
+	syms, err := readELFSymbols(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.files) >= maxCachedFiles {
+		r.files = make(map[fileKey]*elfSymbols)
+	}
+	r.files[key] = syms
+	return syms, nil
+}
+
+func readELFSymbols(path string) (*elfSymbols, error) {
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	syms := &elfSymbols{}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 {
+			syms.loads = append(syms.loads, prog.ProgHeader)
+		}
+	}
+
+	// Stripped binaries only have dynamic symbols
+	all, _ := f.Symbols()
+	dynamic, _ := f.DynamicSymbols()
+	all = append(all, dynamic...)
+
+	for _, s := range all {
+		if elf.ST_TYPE(s.Info) != elf.STT_FUNC || s.Value == 0 {
+			continue
+		}
+		syms.symbols = append(syms.symbols, symbol{addr: s.Value, size: s.Size, name: s.Name})
+	}
+	sort.Slice(syms.symbols, func(i, j int) bool {
+		return syms.symbols[i].addr < syms.symbols[j].addr
+	})
+
+	return syms, nil
+}
+
+// lookup returns the symbol containing the address mapped from fileOffset
+func (s *elfSymbols) lookup(fileOffset uint64) (string, bool) {
+	var addr uint64
+	found := false
+	for _, prog := range s.loads {
+		if fileOffset >= prog.Off && fileOffset < prog.Off+prog.Filesz {
+			addr = fileOffset - prog.Off + prog.Vaddr
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", false
+	}
+
+	i := sort.Search(len(s.symbols), func(i int) bool {
+		return s.symbols[i].addr > addr
+	})
+	if i == 0 {
+		return "", false
+	}
+	sym := s.symbols[i-1]
+	if sym.size != 0 && addr >= sym.addr+sym.size {
+		return "", false
+	}
+	return sym.name, true
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package usersyms
+
+import (
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMappings(t *testing.T) {
+	maps := strings.Join([]string{
+		"55d0a7a0a000-55d0a7a0e000 r--p 00000000 fd:01 1835024                    /usr/bin/cat",
+		"55d0a7a0e000-55d0a7a2d000 r-xp 00004000 fd:01 1835024                    /usr/bin/cat",
+		"55d0a8b2c000-55d0a8b4d000 rw-p 00000000 00:00 0                          [heap]",
+		"7f2c1c3a8000-7f2c1c53d000 r-xp 00028000 fd:01 1835100                    /usr/lib/my lib.so",
+		"7ffd5a1e1000-7ffd5a1e3000 r-xp 00000000 00:00 0                          [vdso]",
+	}, "\n")
+
+	mappings, err := parseMappings(strings.NewReader(maps))
+	require.NoError(t, err)
+	require.Equal(t, []mapping{
+		{start: 0x55d0a7a0e000, end: 0x55d0a7a2d000, offset: 0x4000, path: "/usr/bin/cat"},
+		{start: 0x7f2c1c3a8000, end: 0x7f2c1c53d000, offset: 0x28000, path: "/usr/lib/my lib.so"},
+	}, mappings)
+
+	require.Equal(t, "/usr/bin/cat", findMapping(mappings, 0x55d0a7a0e010).path)
+	require.Nil(t, findMapping(mappings, 0x55d0a7a0a010))
+}
+
+func TestLookup(t *testing.T) {
+	syms := &elfSymbols{
+		loads: []elf.ProgHeader{
+			{Off: 0x1000, Vaddr: 0x401000, Filesz: 0x3000},
+		},
+		symbols: []symbol{
+			{addr: 0x401000, size: 0x100, name: "main"},
+			{addr: 0x401200, size: 0x50, name: "foo"},
+			{addr: 0x401300, name: "bar"},
+		},
+	}
+
+	for _, tt := range []struct {
+		fileOffset uint64
+		name       string
+		ok         bool
+	}{
+		{0x1000, "main", true},
+		{0x10ff, "main", true},
+		{0x1100, "", false},
+		{0x1210, "foo", true},
+		{0x1310, "bar", true},
+		{0x0500, "", false},
+		{0x5000, "", false},
+	} {
+		name, ok := syms.lookup(tt.fileOffset)
+		require.Equal(t, tt.ok, ok, "lookup(0x%x)", tt.fileOffset)
+		require.Equal(t, tt.name, name, "lookup(0x%x)", tt.fileOffset)
+	}
+}
+
+func resolveMe() {}
+
+func TestResolveSelf(t *testing.T) {
+	r := newResolver("/proc")
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	// go test strips the symbols of the test binary, only the file and the
+	// offset are known
+	addr := uint64(reflect.ValueOf(resolveMe).Pointer())
+	syms := r.Resolve(uint32(os.Getpid()), []uint64{addr, 0x10})
+	require.Len(t, syms, 2)
+	require.True(t, strings.HasPrefix(syms[0], filepath.Base(exe)+"+0x"), syms[0])
+	require.Equal(t, Unknown, syms[1])
+
+	// Processes that don't exist can't be resolved
+	syms = r.Resolve(0, []uint64{addr})
+	require.Equal(t, []string{Unknown}, syms)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mountinfo
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	mounts  []Mount
+	expires time.Time
+}
+
+// Cache keeps the mounts of processes for some time, indexed by mount
+// namespace. Mounts rarely change during the life of a container, so it
+// avoids reading mountinfo for every event.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[uint64]*cacheEntry
+	ttl     time.Duration
+	read    func(pid uint32) ([]Mount, error)
+	now     func() time.Time
+}
+
+// NewCache returns a cache keeping mounts for ttl. read is used to get the
+// mounts of a process, Read is used if it's nil.
+func NewCache(ttl time.Duration, read func(pid uint32) ([]Mount, error)) *Cache {
+	if read == nil {
+		read = Read
+	}
+	return &Cache{
+		entries: make(map[uint64]*cacheEntry),
+		ttl:     ttl,
+		read:    read,
+		now:     time.Now,
+	}
+}
+
+// Get returns the mounts of the process, or nil if they can't be read. They
+// are cached by mount namespace, or by pid if mntns is 0.
+func (c *Cache) Get(pid uint32, mntns uint64) []Mount {
+	key := mntns
+	if key == 0 {
+		key = uint64(pid) << 32
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+		return e.mounts
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	mounts, err := c.read(pid)
+	if err != nil {
+		// Don't cache failures: the process may have exited while others
+		// in the same mount namespace are still running
+		return nil
+	}
+	c.entries[key] = &cacheEntry{mounts: mounts, expires: now.Add(c.ttl)}
+	return mounts
+}
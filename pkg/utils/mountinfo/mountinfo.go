@@ -0,0 +1,156 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mountinfo parses /proc/$pid/mountinfo, see proc(5)
+package mountinfo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
+)
+
+type Mount struct {
+	// Device is the major:minor device ID of the filesystem
+	Device string
+	// Root is the path of the directory of the filesystem mounted
+	Root string
+	// MountPoint is the path where the filesystem is mounted, in the mount
+	// namespace of the process
+	MountPoint string
+	FsType     string
+	Source     string
+	// SuperOptions are the options of the filesystem, like the layers of
+	// overlay filesystems
+	SuperOptions []string
+}
+
+// Option returns the value of the given filesystem option
+func (m *Mount) Option(name string) (string, bool) {
+	for _, opt := range m.SuperOptions {
+		if value, ok := strings.CutPrefix(opt, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Read returns the mounts seen by the process with the given pid
+func Read(pid uint32) ([]Mount, error) {
+	f, err := os.Open(filepath.Join(host.HostProcFs, strconv.FormatUint(uint64(pid), 10), "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse parses the content of a mountinfo file. Mounts are returned in the
+// same order, so the last mount on a mount point is the visible one.
+func Parse(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", scanner.Text())
+		}
+
+		// Optional fields are terminated by a single hyphen
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep == -1 || len(fields) < sep+4 {
+			return nil, fmt.Errorf("invalid mountinfo line %q", scanner.Text())
+		}
+
+		var superOptions []string
+		for _, opt := range strings.Split(fields[sep+3], ",") {
+			superOptions = append(superOptions, Unescape(opt))
+		}
+
+		mounts = append(mounts, Mount{
+			Device:       fields[2],
+			Root:         Unescape(fields[3]),
+			MountPoint:   Unescape(fields[4]),
+			FsType:       fields[sep+1],
+			Source:       Unescape(fields[sep+2]),
+			SuperOptions: superOptions,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mounts, nil
+}
+
+// Lookup returns the mount containing path, that is the visible mount with
+// the longest mount point being a parent of path. path must be absolute and
+// clean.
+func Lookup(mounts []Mount, path string) *Mount {
+	var found *Mount
+	for i := range mounts {
+		m := &mounts[i]
+		if !isUnder(path, m.MountPoint) {
+			continue
+		}
+		// Mounts on the same mount point hide the previous ones
+		if found == nil || len(m.MountPoint) >= len(found.MountPoint) {
+			found = m
+		}
+	}
+	return found
+}
+
+func isUnder(path, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// Unescape replaces the octal escapes (\040 for a space, for instance) used
+// by the kernel in mountinfo
+func Unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mountinfo
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `1013 954 0:120 / / rw,relatime master:406 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/A:/var/lib/docker/overlay2/l/B,upperdir=/var/lib/docker/overlay2/abc/diff,workdir=/var/lib/docker/overlay2/abc/work
+1014 1013 0:123 / /proc rw,nosuid,nodev,noexec,relatime - proc proc rw
+1015 1013 0:124 / /dev rw,nosuid - tmpfs tmpfs rw,size=65536k,mode=755
+1020 1013 8:1 /var/lib/docker/volumes/data/_data /data rw,relatime - ext4 /dev/sda1 rw
+1021 1013 8:1 /var/lib/docker/volumes/my\040vol/_data /my\040vol rw,relatime - ext4 /dev/sda1 rw
+1022 1020 0:125 / /data rw,relatime - tmpfs tmpfs rw
+`
+
+func TestParse(t *testing.T) {
+	mounts, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+	require.Len(t, mounts, 6)
+
+	assert.Equal(t, "0:120", mounts[0].Device)
+	assert.Equal(t, "/", mounts[0].MountPoint)
+	assert.Equal(t, "overlay", mounts[0].FsType)
+	upperDir, ok := mounts[0].Option("upperdir")
+	assert.True(t, ok)
+	assert.Equal(t, "/var/lib/docker/overlay2/abc/diff", upperDir)
+	_, ok = mounts[0].Option("redirect_dir")
+	assert.False(t, ok)
+
+	assert.Equal(t, "/my vol", mounts[4].MountPoint)
+	assert.Equal(t, "/var/lib/docker/volumes/my vol/_data", mounts[4].Root)
+	assert.Equal(t, "/dev/sda1", mounts[4].Source)
+
+	_, err = Parse(strings.NewReader("1 2 3\n"))
+	assert.Error(t, err)
+	_, err = Parse(strings.NewReader("1013 954 0:120 / / rw,relatime master:406 overlay overlay rw\n"))
+	assert.Error(t, err)
+}
+
+func TestLookup(t *testing.T) {
+	mounts, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+
+	for path, expected := range map[string]string{
+		"/":              "overlay",
+		"/usr/bin/ls":    "overlay",
+		"/proc/1/status": "proc",
+		"/processes":     "overlay",
+		"/dev":           "tmpfs",
+		// The tmpfs is mounted over the volume, the last one wins
+		"/data/file":   "tmpfs",
+		"/my vol/file": "ext4",
+	} {
+		m := Lookup(mounts, path)
+		require.NotNil(t, m, path)
+		assert.Equal(t, expected, m.FsType, path)
+	}
+
+	assert.Nil(t, Lookup(mounts[1:2], "/usr"))
+}
+
+func TestCache(t *testing.T) {
+	mounts, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+
+	reads := 0
+	c := NewCache(time.Minute, func(pid uint32) ([]Mount, error) {
+		if pid != 42 {
+			return nil, os.ErrNotExist
+		}
+		reads++
+		return mounts, nil
+	})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	assert.Len(t, c.Get(42, 1234), 6)
+	assert.Len(t, c.Get(42, 1234), 6)
+	assert.Equal(t, 1, reads, "mounts are cached")
+
+	assert.Nil(t, c.Get(43, 0), "gone process")
+	assert.Nil(t, c.Get(43, 1234+1))
+
+	now = now.Add(time.Minute)
+	assert.Len(t, c.Get(42, 1234), 6)
+	assert.Equal(t, 2, reads, "cache expired")
+}
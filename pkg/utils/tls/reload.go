@@ -0,0 +1,135 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reloader holds a certificate and, optionally, a client CA loaded from files.
+// The files are checked on each new connection and reloaded when they change,
+// so rotated certificates (e.g. by cert-manager) are picked up without a
+// restart.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu       sync.Mutex
+	modTimes [3]time.Time
+	cert     *tls.Certificate
+	ca       *x509.CertPool
+}
+
+// NewReloader loads the given certificate, key and client CA. caFile can be
+// empty if clients don't need to be authenticated.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// reload loads the files again if any of them changed since the last call
+func (r *Reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := false
+	var modTimes [3]time.Time
+	for i, file := range r.files() {
+		st, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("checking %q: %w", file, err)
+		}
+		modTimes[i] = st.ModTime()
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed && r.cert != nil {
+		return nil
+	}
+
+	cert, err := LoadTLSCert(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	var ca *x509.CertPool
+	if r.caFile != "" {
+		ca, err = LoadTLSCA(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.cert = &cert
+	r.ca = ca
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.ca
+}
+
+// ServerConfig returns a TLS configuration using the current certificate. If
+// a client CA was given, clients are required to present a certificate signed
+// by it (mTLS).
+func (r *Reloader) ServerConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			// Keep serving the previous certificate if the new files are
+			// invalid, e.g. because they're being updated
+			if err := r.reload(); err != nil {
+				log.Warnf("reloading TLS certificate: %v", err)
+			}
+
+			cert, ca := r.current()
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if ca != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = ca
+			}
+			return config, nil
+		},
+	}
+}